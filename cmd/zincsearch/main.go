@@ -83,6 +83,8 @@ func main() {
 	//init index list
 	core.NewIndexList(cfg)
 	core.NewIndexShardWalList(cfg.Shard.GoroutineNum, cfg.WalSyncInterval)
+	core.NewLifecycle(cfg.LifecycleInterval)
+	core.NewTaskCleaner(cfg.TaskResultTTL)
 
 	// HTTP init
 	app := gin.New()
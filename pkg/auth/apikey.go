@@ -0,0 +1,165 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/ider"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+)
+
+var ZINC_CACHED_API_KEYS = cachedAPIKeys{keys: map[string]*meta.APIKey{}}
+
+type cachedAPIKeys struct {
+	keys map[string]*meta.APIKey
+	lock sync.RWMutex
+}
+
+func (t *cachedAPIKeys) Get(id string) (*meta.APIKey, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	key, ok := t.keys[id]
+	return key, ok
+}
+
+func (t *cachedAPIKeys) Set(id string, key *meta.APIKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.keys[id] = key
+}
+
+func (t *cachedAPIKeys) Delete(id string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.keys, id)
+}
+
+// CreateAPIKey creates a key scoped to the role, expiration 0 means the key never expires.
+// It returns the secret of the key, which can't be recovered later.
+func CreateAPIKey(name, role, createdBy string, expiration time.Duration, node *ider.Node) (*meta.APIKey, string, error) {
+	role = strings.ToLower(role)
+	if role == "" {
+		return nil, "", errors.New(errors.ErrorTypeInvalidArgument, "api key role is required")
+	}
+	if _, ok := ZINC_CACHED_PERMISSIONS.Get(role); !ok && role != "admin" {
+		return nil, "", errors.New(errors.ErrorTypeInvalidArgument, "api key role ["+role+"] does not exist")
+	}
+	if expiration < 0 {
+		return nil, "", errors.New(errors.ErrorTypeInvalidArgument, "api key expiration should be positive")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+
+	key := &meta.APIKey{
+		ID:        node.Generate(),
+		Name:      name,
+		Role:      role,
+		Hash:      hashAPIKeySecret(secret),
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	if expiration > 0 {
+		expiresAt := key.CreatedAt.Add(expiration)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := metadata.APIKey.Set(key.ID, *key); err != nil {
+		return nil, "", err
+	}
+	ZINC_CACHED_API_KEYS.Set(key.ID, key)
+
+	return key, secret, nil
+}
+
+func GetAPIKeys() ([]*meta.APIKey, error) {
+	return metadata.APIKey.List(0, 0)
+}
+
+// GetAPIKey returns the key without checking its secret
+func GetAPIKey(id string) (*meta.APIKey, bool) {
+	return ZINC_CACHED_API_KEYS.Get(id)
+}
+
+// DeleteAPIKey revokes the key
+func DeleteAPIKey(id string) error {
+	ZINC_CACHED_API_KEYS.Delete(id)
+	return metadata.APIKey.Delete(id)
+}
+
+// EncodeAPIKey returns the credential used in the Authorization header: base64(id:secret)
+func EncodeAPIKey(id, secret string) string {
+	return base64.StdEncoding.EncodeToString([]byte(id + ":" + secret))
+}
+
+// VerifyAPIKey checks the credential of the Authorization header: base64(id:secret)
+func VerifyAPIKey(credential string) (*meta.APIKey, bool) {
+	data, err := base64.StdEncoding.DecodeString(credential)
+	if err != nil {
+		return nil, false
+	}
+	id, secret, ok := strings.Cut(string(data), ":")
+	if !ok {
+		return nil, false
+	}
+	key, ok := ZINC_CACHED_API_KEYS.Get(id)
+	if !ok || key.Expired() {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.Hash)) != 1 {
+		return nil, false
+	}
+	// the key can't do more than its creator could do now, it stops working when the creator
+	// is deleted or doesn't have the role of the key anymore
+	user, ok := ZINC_CACHED_USERS.Get(key.CreatedBy)
+	if !ok {
+		return nil, false
+	}
+	if role := strings.ToLower(user.Role); role != key.Role && role != "admin" {
+		return nil, false
+	}
+	return key, true
+}
+
+// hashAPIKeySecret uses sha256 rather than argon2, the secret is random and long enough,
+// and it keeps the verification cheap on every ingest request.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func initAPIKeyCache() error {
+	keys, err := GetAPIKeys()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		ZINC_CACHED_API_KEYS.Set(key.ID, key)
+	}
+	return nil
+}
@@ -21,6 +21,12 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
+// the authenticated user id and role are stored in the gin context with these keys
+const (
+	ContextKeyUserID = "zinc_user_id"
+	ContextKeyRole   = "zinc_user_role"
+)
+
 func VerifyCredentials(userID, password string) (*meta.User, bool) {
 	userID = strings.ToLower(userID)
 	user, ok := ZINC_CACHED_USERS.Get(userID)
@@ -41,10 +47,15 @@ func VerifyRoleHasPermission(roleId, permission string) bool {
 	if roleId == "admin" {
 		return true
 	}
-	pm, ok := ZINC_CACHED_PERMISSIONS.Get(roleId)
-	if !ok {
-		return false
+	if pm, ok := ZINC_CACHED_PERMISSIONS.Get(roleId); ok {
+		if _, ok = pm[permission]; ok {
+			return true
+		}
+	}
+	// index scoped permissions are also granted by the index privileges of the role,
+	// the indexes of the request are checked by VerifyRoleHasIndexPermission
+	if privilege, ok := IndexPrivilege(permission); ok {
+		return roleHasIndexPrivilege(roleId, privilege)
 	}
-	_, ok = pm[permission]
-	return ok
+	return false
 }
@@ -20,6 +20,7 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/ider"
 	"github.com/zinclabs/zincsearch/pkg/metadata"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -98,3 +99,75 @@ func TestVerifyCredentials(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyAPIKey(t *testing.T) {
+	node, _ := ider.NewNode(1)
+
+	key, secret, err := CreateAPIKey("shipper", "admin", "admin", 0, node)
+	assert.NoError(t, err)
+	assert.Nil(t, key.ExpiresAt)
+	assert.NotEqual(t, secret, key.Hash)
+
+	expiredKey, expiredSecret, err := CreateAPIKey("expired", "admin", "admin", time.Millisecond, node)
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, _, err = CreateAPIKey("norole", "role-not-exists", "admin", 0, node)
+	assert.Error(t, err)
+	_, _, err = CreateAPIKey("norole", "", "admin", 0, node)
+	assert.Error(t, err)
+
+	tests := []struct {
+		name       string
+		credential string
+		want       bool
+	}{
+		{"valid key", EncodeAPIKey(key.ID, secret), true},
+		{"error secret", EncodeAPIKey(key.ID, "xxx"), false},
+		{"not exists key", EncodeAPIKey("xxx", secret), false},
+		{"expired key", EncodeAPIKey(expiredKey.ID, expiredSecret), false},
+		{"not base64", "!!!", false},
+		{"no secret", EncodeAPIKey(key.ID, "")[:4], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := VerifyAPIKey(tt.credential)
+			assert.Equal(t, tt.want, ok)
+			if tt.want {
+				assert.Equal(t, "admin", got.Role)
+			}
+		})
+	}
+
+	t.Run("creator role", func(t *testing.T) {
+		creator := &meta.User{ID: "apikey-user", Role: "admin"}
+		ZINC_CACHED_USERS.Set(creator.ID, creator)
+		defer ZINC_CACHED_USERS.Delete(creator.ID)
+		key, secret, err := CreateAPIKey("creator", "admin", creator.ID, 0, node)
+		assert.NoError(t, err)
+		defer func() { _ = DeleteAPIKey(key.ID) }()
+		credential := EncodeAPIKey(key.ID, secret)
+
+		_, ok := VerifyAPIKey(credential)
+		assert.True(t, ok)
+		// the creator isn't admin anymore
+		ZINC_CACHED_USERS.Set(creator.ID, &meta.User{ID: creator.ID, Role: "other-role"})
+		_, ok = VerifyAPIKey(credential)
+		assert.False(t, ok)
+		// the creator is deleted
+		ZINC_CACHED_USERS.Delete(creator.ID)
+		_, ok = VerifyAPIKey(credential)
+		assert.False(t, ok)
+	})
+
+	t.Run("list and revoke", func(t *testing.T) {
+		keys, err := GetAPIKeys()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, len(keys), 2)
+
+		assert.NoError(t, DeleteAPIKey(key.ID))
+		assert.NoError(t, DeleteAPIKey(expiredKey.ID))
+		_, ok := VerifyAPIKey(EncodeAPIKey(key.ID, secret))
+		assert.False(t, ok)
+	})
+}
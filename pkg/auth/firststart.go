@@ -36,6 +36,9 @@ func FirstStart(node *ider.Node) {
 	if err := initPermissionCache(); err != nil {
 		log.Print(err)
 	}
+	if err := initAPIKeyCache(); err != nil {
+		log.Print(err)
+	}
 }
 
 func isFirstStart() (bool, error) {
@@ -63,6 +66,7 @@ func initPermissionCache() error {
 
 	for _, role := range roles {
 		ZINC_CACHED_PERMISSIONS.Set(role.ID, strArrayToMap(role.Permission))
+		ZINC_CACHED_INDEX_PERMISSIONS.Set(role.ID, role.Indices)
 	}
 
 	return nil
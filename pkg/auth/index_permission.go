@@ -0,0 +1,235 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+var ZINC_CACHED_INDEX_PERMISSIONS = cachedIndexPermissions{pm: map[string][]meta.RoleIndices{}}
+
+type cachedIndexPermissions struct {
+	pm   map[string][]meta.RoleIndices
+	lock sync.RWMutex
+}
+
+func (t *cachedIndexPermissions) Get(id string) ([]meta.RoleIndices, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	indices, ok := t.pm[id]
+	return indices, ok
+}
+
+func (t *cachedIndexPermissions) Set(id string, indices []meta.RoleIndices) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(indices) == 0 {
+		delete(t.pm, id)
+		return
+	}
+	t.pm[id] = indices
+}
+
+func (t *cachedIndexPermissions) Delete(id string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.pm, id)
+}
+
+// indexPrivileges maps the index scoped permissions to the privilege they require
+var indexPrivileges = map[string]string{
+	"index.Get":             meta.IndexPrivilegeRead,
+	"index.Exists":          meta.IndexPrivilegeRead,
+	"index.GetMapping":      meta.IndexPrivilegeRead,
+	"index.GetESMapping":    meta.IndexPrivilegeRead,
+	"index.GetSettings":     meta.IndexPrivilegeRead,
+	"index.GetESAliases":    meta.IndexPrivilegeRead,
+	"index.Analyze":         meta.IndexPrivilegeRead,
+	"lifecycle.Explain":     meta.IndexPrivilegeRead,
+	"search.SearchV1":       meta.IndexPrivilegeRead,
+	"search.SearchDSL":      meta.IndexPrivilegeRead,
+	"search.MultipleSearch": meta.IndexPrivilegeRead,
+	"search.Scroll":         meta.IndexPrivilegeRead,
+	"search.OpenPIT":        meta.IndexPrivilegeRead,
+	"search.ClosePIT":       meta.IndexPrivilegeRead,
+	"search.Count":          meta.IndexPrivilegeRead,
+	"search.ValidateQuery":  meta.IndexPrivilegeRead,
+	"search.Explain":        meta.IndexPrivilegeRead,
+	"document.Get":          meta.IndexPrivilegeRead,
+
+	"document.Bulk":          meta.IndexPrivilegeWrite,
+	"document.ESBulk":        meta.IndexPrivilegeWrite,
+	"document.Multi":         meta.IndexPrivilegeWrite,
+	"document.Create":        meta.IndexPrivilegeWrite,
+	"document.CreateUpdate":  meta.IndexPrivilegeWrite,
+	"document.Update":        meta.IndexPrivilegeWrite,
+	"document.Delete":        meta.IndexPrivilegeWrite,
+	"search.DeleteByQuery":   meta.IndexPrivilegeWrite,
+	"document.Reindex":       meta.IndexPrivilegeWrite,
+	"document.UpdateByQuery": meta.IndexPrivilegeWrite,
+
+	"index.Create":       meta.IndexPrivilegeManage,
+	"index.CreateES":     meta.IndexPrivilegeManage,
+	"index.Delete":       meta.IndexPrivilegeManage,
+	"index.Refresh":      meta.IndexPrivilegeManage,
+	"index.SetMapping":   meta.IndexPrivilegeManage,
+	"index.SetSettings":  meta.IndexPrivilegeManage,
+	"lifecycle.Remove":   meta.IndexPrivilegeManage,
+	"lifecycle.Rollover": meta.IndexPrivilegeManage,
+
+	"datastream.GetDataStream":    meta.IndexPrivilegeRead,
+	"datastream.DataStreamStats":  meta.IndexPrivilegeRead,
+	"datastream.PutDataStream":    meta.IndexPrivilegeManage,
+	"datastream.DeleteDataStream": meta.IndexPrivilegeManage,
+}
+
+// IndexPrivilege returns the index privilege required by the permission,
+// false means the permission is not scoped to indexes.
+func IndexPrivilege(permission string) (string, bool) {
+	privilege, ok := indexPrivileges[permission]
+	return privilege, ok
+}
+
+// NeedVerifyIndexPermission returns true if the role is restricted by index grants for the permission
+func NeedVerifyIndexPermission(roleId, permission string) bool {
+	roleId = strings.ToLower(roleId)
+	if roleId == "admin" {
+		return false
+	}
+	if _, ok := IndexPrivilege(permission); !ok {
+		return false
+	}
+	_, ok := ZINC_CACHED_INDEX_PERMISSIONS.Get(roleId)
+	return ok
+}
+
+// VerifyRoleHasIndexPermission checks the index grants of the role for all the indexNames,
+// an empty index name means all the indexes. It returns the first index which is not permitted.
+func VerifyRoleHasIndexPermission(roleId, permission string, indexNames []string) (string, bool) {
+	if !NeedVerifyIndexPermission(roleId, permission) {
+		return "", true
+	}
+	privilege, _ := IndexPrivilege(permission)
+	indices, _ := ZINC_CACHED_INDEX_PERMISSIONS.Get(strings.ToLower(roleId))
+	for _, name := range indexNames {
+		if !indicesAllow(indices, name, privilege) {
+			return name, false
+		}
+	}
+	return "", true
+}
+
+// roleHasIndexPrivilege returns true if any index grant of the role contains the privilege
+func roleHasIndexPrivilege(roleId, privilege string) bool {
+	indices, ok := ZINC_CACHED_INDEX_PERMISSIONS.Get(roleId)
+	if !ok {
+		return false
+	}
+	for _, grant := range indices {
+		if hasPrivilege(grant.Privileges, privilege) {
+			return true
+		}
+	}
+	return false
+}
+
+func indicesAllow(indices []meta.RoleIndices, indexName, privilege string) bool {
+	for _, grant := range indices {
+		if !hasPrivilege(grant.Privileges, privilege) {
+			continue
+		}
+		for _, pattern := range grant.Names {
+			if indexPatternCovers(pattern, indexName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasPrivilege(privileges []string, privilege string) bool {
+	for _, p := range privileges {
+		if p == privilege || p == meta.IndexPrivilegeAll {
+			return true
+		}
+	}
+	return false
+}
+
+// indexPatternCovers returns true if every index matched by indexName is also matched by pattern
+// indexPatternCovers("logs-*", "logs-2022") true
+// indexPatternCovers("logs-*", "logs-2022*") true
+// indexPatternCovers("logs-*", "logs*") false
+// indexPatternCovers("*-prod", "app-prod") true
+// indexPatternCovers("logs-*", "") false, empty means all the indexes
+func indexPatternCovers(pattern, indexName string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if indexName == "" || indexName == "_all" || indexName == "*" {
+		return false
+	}
+
+	// eg.: *-prod
+	if strings.HasPrefix(pattern, "*") {
+		if strings.HasSuffix(indexName, "*") {
+			return false
+		}
+		if strings.HasPrefix(indexName, "*") {
+			return strings.HasSuffix(indexName[1:], pattern[1:])
+		}
+		return strings.HasSuffix(indexName, pattern[1:])
+	}
+
+	// eg.: logs-*
+	if strings.HasSuffix(pattern, "*") {
+		if strings.HasPrefix(indexName, "*") {
+			return false
+		}
+		return strings.HasPrefix(indexName, pattern[:len(pattern)-1])
+	}
+
+	return pattern == indexName
+}
+
+func checkRoleIndices(indices []meta.RoleIndices) error {
+	for _, grant := range indices {
+		if len(grant.Names) == 0 {
+			return errors.New(errors.ErrorTypeInvalidArgument, "role indices names should be not empty")
+		}
+		for _, name := range grant.Names {
+			if name == "" {
+				return errors.New(errors.ErrorTypeInvalidArgument, "role indices name should be not empty")
+			}
+		}
+		if len(grant.Privileges) == 0 {
+			return errors.New(errors.ErrorTypeInvalidArgument, "role indices privileges should be not empty")
+		}
+		for _, privilege := range grant.Privileges {
+			switch privilege {
+			case meta.IndexPrivilegeRead, meta.IndexPrivilegeWrite, meta.IndexPrivilegeManage, meta.IndexPrivilegeAll:
+			default:
+				return errors.New(errors.ErrorTypeInvalidArgument, fmt.Sprintf("role indices privilege [%s] not supported", privilege))
+			}
+		}
+	}
+	return nil
+}
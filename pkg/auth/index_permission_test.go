@@ -0,0 +1,114 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestIndexPatternCovers(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		indexName string
+		want      bool
+	}{
+		{"*", "", true},
+		{"*", "logs-2022", true},
+		{"logs-*", "logs-2022", true},
+		{"logs-*", "logs-2022*", true},
+		{"logs-*", "logs*", false},
+		{"logs-*", "app-logs", false},
+		{"logs-*", "*", false},
+		{"logs-*", "", false},
+		{"*-prod", "app-prod", true},
+		{"*-prod", "*-prod", true},
+		{"*-prod", "app-*", false},
+		{"app", "app", true},
+		{"app", "app2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.indexName, func(t *testing.T) {
+			assert.Equal(t, tt.want, indexPatternCovers(tt.pattern, tt.indexName))
+		})
+	}
+}
+
+func TestVerifyRoleHasIndexPermission(t *testing.T) {
+	roleID := "testindexrole"
+	ZINC_CACHED_PERMISSIONS.Set(roleID, strArrayToMap([]string{"index.List"}))
+	ZINC_CACHED_INDEX_PERMISSIONS.Set(roleID, []meta.RoleIndices{
+		{Names: []string{"logs-*"}, Privileges: []string{meta.IndexPrivilegeRead}},
+		{Names: []string{"app-*"}, Privileges: []string{meta.IndexPrivilegeWrite}},
+		{Names: []string{"full"}, Privileges: []string{meta.IndexPrivilegeAll}},
+	})
+	defer func() {
+		ZINC_CACHED_PERMISSIONS.Delete(roleID)
+		ZINC_CACHED_INDEX_PERMISSIONS.Delete(roleID)
+	}()
+
+	t.Run("permission", func(t *testing.T) {
+		assert.True(t, VerifyRoleHasPermission(roleID, "index.List"))
+		assert.True(t, VerifyRoleHasPermission(roleID, "search.SearchDSL"))
+		assert.True(t, VerifyRoleHasPermission(roleID, "document.ESBulk"))
+		assert.True(t, VerifyRoleHasPermission(roleID, "index.Delete"))
+		assert.False(t, VerifyRoleHasPermission(roleID, "auth.ListUser"))
+	})
+
+	tests := []struct {
+		name       string
+		permission string
+		indexNames []string
+		want       bool
+		denied     string
+	}{
+		{"read logs", "search.SearchDSL", []string{"logs-2022", "logs-2023"}, true, ""},
+		{"read logs wildcard", "search.SearchDSL", []string{"logs-*"}, true, ""},
+		{"read app", "search.SearchDSL", []string{"logs-2022", "app-1"}, false, "app-1"},
+		{"read all", "search.SearchDSL", []string{""}, false, ""},
+		{"write app", "document.ESBulk", []string{"app-1", "app-2"}, true, ""},
+		{"write logs", "document.ESBulk", []string{"app-1", "logs-2022"}, false, "logs-2022"},
+		{"manage full", "index.Delete", []string{"full"}, true, ""},
+		{"manage app", "index.Delete", []string{"app-1"}, false, "app-1"},
+		{"not index scoped", "index.List", []string{"other"}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denied, ok := VerifyRoleHasIndexPermission(roleID, tt.permission, tt.indexNames)
+			assert.Equal(t, tt.want, ok)
+			assert.Equal(t, tt.denied, denied)
+		})
+	}
+
+	t.Run("role without index grants", func(t *testing.T) {
+		ZINC_CACHED_INDEX_PERMISSIONS.Delete(roleID)
+		assert.False(t, NeedVerifyIndexPermission(roleID, "search.SearchDSL"))
+		assert.False(t, VerifyRoleHasPermission(roleID, "search.SearchDSL"))
+		_, ok := VerifyRoleHasIndexPermission(roleID, "search.SearchDSL", []string{"other"})
+		assert.True(t, ok)
+	})
+}
+
+func TestCheckRoleIndices(t *testing.T) {
+	assert.NoError(t, checkRoleIndices(nil))
+	assert.NoError(t, checkRoleIndices([]meta.RoleIndices{{Names: []string{"logs-*"}, Privileges: []string{"read", "write"}}}))
+	assert.Error(t, checkRoleIndices([]meta.RoleIndices{{Names: []string{"logs-*"}}}))
+	assert.Error(t, checkRoleIndices([]meta.RoleIndices{{Privileges: []string{"read"}}}))
+	assert.Error(t, checkRoleIndices([]meta.RoleIndices{{Names: []string{"logs-*"}, Privileges: []string{"delete"}}}))
+}
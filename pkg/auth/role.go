@@ -59,11 +59,14 @@ func strArrayToMap(ss []string) map[string]struct{} {
 	return m
 }
 
-func CreateRole(id, name string, permissions []string) (*meta.Role, error) {
+func CreateRole(id, name string, permissions []string, indices []meta.RoleIndices) (*meta.Role, error) {
 	id = strings.ToLower(id)
 	if id == "admin" {
 		return nil, errors.New(errors.ErrorTypeInvalidArgument, "role id admin not allowed")
 	}
+	if err := checkRoleIndices(indices); err != nil {
+		return nil, err
+	}
 	var newRole *meta.Role
 	existingRole, roleExists, err := GetRole(id)
 	if err != nil && !errors.Is(err, errors.ErrKeyNotFound) {
@@ -74,12 +77,14 @@ func CreateRole(id, name string, permissions []string) (*meta.Role, error) {
 		newRole = existingRole
 		newRole.Name = name
 		newRole.Permission = permissions
+		newRole.Indices = indices
 		newRole.UpdatedAt = time.Now()
 	} else {
 		newRole = &meta.Role{
 			ID:         id,
 			Name:       name,
 			Permission: permissions,
+			Indices:    indices,
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
 		}
@@ -91,6 +96,7 @@ func CreateRole(id, name string, permissions []string) (*meta.Role, error) {
 	}
 
 	ZINC_CACHED_PERMISSIONS.Set(newRole.ID, strArrayToMap(permissions))
+	ZINC_CACHED_INDEX_PERMISSIONS.Set(newRole.ID, indices)
 
 	return newRole, nil
 }
@@ -117,5 +123,6 @@ func SetRole(id string, role meta.Role) error {
 func DeleteRole(id string) error {
 	id = strings.ToLower(id)
 	ZINC_CACHED_PERMISSIONS.Delete(id)
+	ZINC_CACHED_INDEX_PERMISSIONS.Delete(id)
 	return metadata.Role.Delete(id)
 }
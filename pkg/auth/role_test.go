@@ -75,7 +75,7 @@ func TestCreateRole(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CreateRole(tt.args.id, tt.args.name, tt.args.permission)
+			got, err := CreateRole(tt.args.id, tt.args.name, tt.args.permission, nil)
 			if tt.wantErr {
 				assert.Error(t, err)
 				return
@@ -129,7 +129,7 @@ func TestGetRole(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if tt.input != nil {
-				got, err := CreateRole(tt.input.ID, tt.input.Name, tt.input.Permission)
+				got, err := CreateRole(tt.input.ID, tt.input.Name, tt.input.Permission, tt.input.Indices)
 				assert.NoError(t, err)
 				assert.NotNil(t, got)
 			}
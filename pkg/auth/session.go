@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type sessionPayload struct {
+	UserID    string `json:"uid"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	sessionSecret     []byte
+	sessionSecretLock sync.Mutex
+)
+
+// CreateSessionToken returns a token signed by the secret for the user, which is valid for ttl.
+// An empty secret uses the secret generated and stored in metadata.
+func CreateSessionToken(userID, secret string, ttl time.Duration) (string, error) {
+	key, err := getSessionSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(sessionPayload{UserID: strings.ToLower(userID), ExpiresAt: time.Now().Add(ttl).Unix()})
+	if err != nil {
+		return "", err
+	}
+	data := base64.RawURLEncoding.EncodeToString(payload)
+	return data + "." + signSession(key, data), nil
+}
+
+// VerifySessionToken checks the signature and expiry of the token and returns the user of it
+func VerifySessionToken(token, secret string) (*meta.User, bool) {
+	data, sign, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+	key, err := getSessionSecret(secret)
+	if err != nil {
+		return nil, false
+	}
+	if !hmac.Equal([]byte(sign), []byte(signSession(key, data))) {
+		return nil, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, false
+	}
+	payload := new(sessionPayload)
+	if err = json.Unmarshal(raw, payload); err != nil {
+		return nil, false
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return nil, false
+	}
+	// the user may be deleted after login
+	return ZINC_CACHED_USERS.Get(payload.UserID)
+}
+
+func signSession(key []byte, data string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func getSessionSecret(secret string) ([]byte, error) {
+	if secret != "" {
+		return []byte(secret), nil
+	}
+
+	sessionSecretLock.Lock()
+	defer sessionSecretLock.Unlock()
+	if sessionSecret != nil {
+		return sessionSecret, nil
+	}
+
+	val, err := metadata.KV.Get("session_secret")
+	if err != nil && !errors.Is(err, errors.ErrKeyNotFound) {
+		return nil, err
+	}
+	if len(val) == 0 {
+		buf := make([]byte, 32)
+		if _, err = rand.Read(buf); err != nil {
+			return nil, err
+		}
+		val = []byte(hex.EncodeToString(buf))
+		if err = metadata.KV.Set("session_secret", val); err != nil {
+			return nil, err
+		}
+	}
+	sessionSecret = val
+	return sessionSecret, nil
+}
@@ -0,0 +1,64 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionToken(t *testing.T) {
+	token, err := CreateSessionToken("Admin", "", time.Hour)
+	assert.NoError(t, err)
+
+	t.Run("valid token", func(t *testing.T) {
+		user, ok := VerifySessionToken(token, "")
+		assert.True(t, ok)
+		assert.Equal(t, "admin", user.ID)
+	})
+	t.Run("stored secret is reused", func(t *testing.T) {
+		sessionSecret = nil
+		_, ok := VerifySessionToken(token, "")
+		assert.True(t, ok)
+	})
+	t.Run("other secret", func(t *testing.T) {
+		_, ok := VerifySessionToken(token, "other-secret")
+		assert.False(t, ok)
+	})
+	t.Run("tampered token", func(t *testing.T) {
+		// payload with a longer expiry and the signature of the valid token
+		forged, err := CreateSessionToken("admin", "other-secret", 2*time.Hour)
+		assert.NoError(t, err)
+		_, ok := VerifySessionToken(strings.Split(forged, ".")[0]+"."+strings.Split(token, ".")[1], "")
+		assert.False(t, ok)
+		_, ok = VerifySessionToken("xxx", "")
+		assert.False(t, ok)
+	})
+	t.Run("expired token", func(t *testing.T) {
+		expired, err := CreateSessionToken("admin", "secret", -time.Second)
+		assert.NoError(t, err)
+		_, ok := VerifySessionToken(expired, "secret")
+		assert.False(t, ok)
+	})
+	t.Run("user not exists", func(t *testing.T) {
+		token, err := CreateSessionToken("user-not-exists", "secret", time.Hour)
+		assert.NoError(t, err)
+		_, ok := VerifySessionToken(token, "secret")
+		assert.False(t, ok)
+	})
+}
@@ -22,7 +22,6 @@ import (
 
 	"github.com/blugelabs/bluge/search"
 	"github.com/blugelabs/bluge/search/aggregations"
-	"github.com/rs/zerolog/log"
 
 	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
@@ -173,58 +172,46 @@ func (a *AutoDateHistogramCalculator) Consume(d *search.DocumentMatch) {
 
 func (a *AutoDateHistogramCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*AutoDateHistogramCalculator); ok {
-		// first sum to the totals and others
+		// first sum to the totals and extend the range
 		a.total += other.total
-		// now, walk all of the other buckets
-		// if we have a local match, merge otherwise append
-		for i := range other.bucketsList {
-			var foundLocal bool
-			for j := range a.bucketsList {
-				if other.bucketsList[i].Name() == a.bucketsList[j].Name() {
-					a.bucketsList[j].Merge(other.bucketsList[i])
-					foundLocal = true
-				}
-			}
-			if !foundLocal {
-				// a.bucketsList = append(a.bucketsList, other.bucketsList[i])
-				keyTime, err := time.ParseInLocation(a.format, other.bucketsList[i].Name(), a.timeZone)
-				if err != nil {
-					log.Error().Msgf("auto_date_histogram: Failed to parse time: %v", err)
-					continue
-				}
-				key := keyTime.UnixNano()
-				a.bucketsMap[key] = other.bucketsList[i]
-				if a.minValue > key {
-					a.minValue = key
-				}
-				if a.maxValue < key {
-					a.maxValue = key
-				}
-				if a.currentInterval < other.currentInterval {
-					a.currentInterval = other.currentInterval
-				}
+		if other.minValue < a.minValue {
+			a.minValue = other.minValue
+		}
+		if other.maxValue > a.maxValue {
+			a.maxValue = other.maxValue
+		}
+		if a.currentInterval < other.currentInterval {
+			a.currentInterval = other.currentInterval
+		}
+		// now, walk all of the other buckets by their keys
+		// if we have a local match, merge otherwise add
+		for key, bucket := range other.bucketsMap {
+			if local, ok := a.bucketsMap[key]; ok {
+				local.Merge(bucket)
+				continue
 			}
+			a.bucketsMap[key] = bucket
 		}
-		// now re-invoke finish, this should trim to correct size again
-		// and recalculate other
+		// the buckets of the other calculator may have a smaller interval, rebucket them,
+		// then re-invoke finish, this should trim to correct size again and recalculate other
 		a.afterMerge()
 		a.Finish()
 	}
 }
 
+// afterMerge moves the buckets into the buckets of the current interval
 func (a *AutoDateHistogramCalculator) afterMerge() {
+	bucketsMap := make(map[int64]*search.Bucket, len(a.bucketsMap))
 	for key, bucket := range a.bucketsMap {
-		delete(a.bucketsMap, key)
 		termKey, termStr := a.bucketKey(key)
-		newBucket, ok := a.bucketsMap[termKey]
-		if ok {
-			newBucket.Merge(bucket)
-		} else {
+		newBucket, ok := bucketsMap[termKey]
+		if !ok {
 			newBucket = search.NewBucket(termStr, a.aggregations)
-			newBucket.Merge(bucket)
-			a.bucketsMap[termKey] = newBucket
+			bucketsMap[termKey] = newBucket
 		}
+		newBucket.Merge(bucket)
 	}
+	a.bucketsMap = bucketsMap
 }
 
 func (a *AutoDateHistogramCalculator) Finish() {
@@ -163,19 +163,21 @@ func (a *DateHistogramCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*DateHistogramCalculator); ok {
 		// first sum to the totals and others
 		a.total += other.total
+		if other.minValue < a.minValue {
+			a.minValue = other.minValue
+		}
+		if other.maxValue > a.maxValue {
+			a.maxValue = other.maxValue
+		}
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
-		for i := range other.bucketsList {
-			var foundLocal bool
-			for j := range a.bucketsList {
-				if other.bucketsList[i].Name() == a.bucketsList[j].Name() {
-					a.bucketsList[j].Merge(other.bucketsList[i])
-					foundLocal = true
-				}
-			}
-			if !foundLocal {
-				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+				continue
 			}
+			a.bucketsMap[bucket.Name()] = bucket
+			a.bucketsList = append(a.bucketsList, bucket)
 		}
 		// now re-invoke finish, this should trim to correct size again
 		// and recalculate other
@@ -205,7 +207,9 @@ func (a *DateHistogramCalculator) Finish() {
 			for value := a.minValue; value < a.maxValue; {
 				termStr := a.bucketKey(value)
 				if _, ok := a.bucketsMap[termStr]; !ok {
-					a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+					newBucket := search.NewBucket(termStr, a.aggregations)
+					a.bucketsMap[termStr] = newBucket
+					a.bucketsList = append(a.bucketsList, newBucket)
 				}
 				t := time.Unix(0, value).In(a.timeZone)
 				switch a.calendarInterval {
@@ -226,7 +230,9 @@ func (a *DateHistogramCalculator) Finish() {
 			for value := a.minValue; value < a.maxValue; value += a.fixedInterval {
 				termStr := a.bucketKey(value)
 				if _, ok := a.bucketsMap[termStr]; !ok {
-					a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+					newBucket := search.NewBucket(termStr, a.aggregations)
+					a.bucketsMap[termStr] = newBucket
+					a.bucketsList = append(a.bucketsList, newBucket)
 				}
 			}
 		}
@@ -256,6 +262,7 @@ func (a *DateHistogramCalculator) Finish() {
 		trimTopN = len(a.bucketsList)
 	}
 	a.bucketsList = a.bucketsList[:trimTopN]
+	syncBucketsMap(a.bucketsMap, a.bucketsList)
 
 	var notOther int
 	for _, bucket := range a.bucketsList {
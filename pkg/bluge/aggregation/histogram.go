@@ -66,7 +66,9 @@ func NewHistogramAggregation(
 		hardBounds:     hardBounds,
 		desc:           false,
 		lessFunc: func(a, b *search.Bucket) bool {
-			return a.Name() < b.Name()
+			x, _ := strconv.ParseFloat(a.Name(), 64)
+			y, _ := strconv.ParseFloat(b.Name(), 64)
+			return x < y
 		},
 		aggregations: make(map[string]search.Aggregation),
 		sortFunc:     sort.Sort,
@@ -157,19 +159,21 @@ func (a *HistogramCalculator) Merge(other search.Calculator) {
 	if other, ok := other.(*HistogramCalculator); ok {
 		// first sum to the totals and others
 		a.total += other.total
+		if other.minValue < a.minValue {
+			a.minValue = other.minValue
+		}
+		if other.maxValue > a.maxValue {
+			a.maxValue = other.maxValue
+		}
 		// now, walk all of the other buckets
 		// if we have a local match, merge otherwise append
-		for i := range other.bucketsList {
-			var foundLocal bool
-			for j := range a.bucketsList {
-				if other.bucketsList[i].Name() == a.bucketsList[j].Name() {
-					a.bucketsList[j].Merge(other.bucketsList[i])
-					foundLocal = true
-				}
-			}
-			if !foundLocal {
-				a.bucketsList = append(a.bucketsList, other.bucketsList[i])
+		for _, bucket := range other.bucketsList {
+			if local, ok := a.bucketsMap[bucket.Name()]; ok {
+				local.Merge(bucket)
+				continue
 			}
+			a.bucketsMap[bucket.Name()] = bucket
+			a.bucketsList = append(a.bucketsList, bucket)
 		}
 		// now re-invoke finish, this should trim to correct size again
 		// and recalculate other
@@ -196,7 +200,9 @@ func (a *HistogramCalculator) Finish() {
 		for value := a.minValue; value < a.maxValue; value += a.interval {
 			termStr := a.bucketKey(value)
 			if _, ok := a.bucketsMap[termStr]; !ok {
-				a.bucketsList = append(a.bucketsList, search.NewBucket(termStr, a.aggregations))
+				newBucket := search.NewBucket(termStr, a.aggregations)
+				a.bucketsMap[termStr] = newBucket
+				a.bucketsList = append(a.bucketsList, newBucket)
 			}
 		}
 	} else {
@@ -225,6 +231,7 @@ func (a *HistogramCalculator) Finish() {
 		trimTopN = len(a.bucketsList)
 	}
 	a.bucketsList = a.bucketsList[:trimTopN]
+	syncBucketsMap(a.bucketsMap, a.bucketsList)
 
 	var notOther int
 	for _, bucket := range a.bucketsList {
@@ -257,3 +264,17 @@ func (a *HistogramCalculator) bucketKey(value float64) string {
 	f := math.Floor((value-a.offset)/a.interval)*a.interval + a.offset
 	return strconv.FormatFloat(f, 'f', -1, 64)
 }
+
+// syncBucketsMap removes the buckets dropped from the list by min_doc_count or size,
+// then merging the buckets of other shards only finds the buckets still in the list.
+func syncBucketsMap(bucketsMap map[string]*search.Bucket, bucketsList []*search.Bucket) {
+	if len(bucketsMap) == len(bucketsList) {
+		return
+	}
+	for name := range bucketsMap {
+		delete(bucketsMap, name)
+	}
+	for _, bucket := range bucketsList {
+		bucketsMap[bucket.Name()] = bucket
+	}
+}
@@ -0,0 +1,297 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/caio/go-tdigest"
+)
+
+// PercentilesAggregation estimates the percentiles with a t-digest, the digests of the shards can be merged.
+// With ranks it returns the percentile ranks of the values instead.
+type PercentilesAggregation struct {
+	src         search.NumericValuesSource
+	keys        []float64
+	ranks       bool
+	compression float64
+}
+
+// NewPercentilesAggregation returns a percentilesAggregation
+// percents are in [0, 100], compression use to set the accuracy of the t-digest, default is 100
+func NewPercentilesAggregation(field search.NumericValuesSource, percents []float64, compression float64) (*PercentilesAggregation, error) {
+	for _, p := range percents {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percent [%v] must be in [0, 100]", p)
+		}
+	}
+	return newPercentilesAggregation(field, percents, false, compression)
+}
+
+// NewPercentileRanksAggregation returns a percentilesAggregation calculates the percentile ranks of the values
+func NewPercentileRanksAggregation(field search.NumericValuesSource, values []float64, compression float64) (*PercentilesAggregation, error) {
+	return newPercentilesAggregation(field, values, true, compression)
+}
+
+func newPercentilesAggregation(field search.NumericValuesSource, keys []float64, ranks bool, compression float64) (*PercentilesAggregation, error) {
+	if compression == 0 {
+		compression = 100
+	}
+	if compression < 1 {
+		return nil, fmt.Errorf("compression must be >= 1")
+	}
+	return &PercentilesAggregation{
+		src:         field,
+		keys:        keys,
+		ranks:       ranks,
+		compression: compression,
+	}, nil
+}
+
+func (t *PercentilesAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *PercentilesAggregation) Calculator() search.Calculator {
+	rv := &PercentilesCalculator{
+		src:   t.src,
+		keys:  t.keys,
+		ranks: t.ranks,
+	}
+	rv.digest, _ = tdigest.New(tdigest.Compression(t.compression))
+	return rv
+}
+
+type PercentilesCalculator struct {
+	src    search.NumericValuesSource
+	keys   []float64
+	ranks  bool
+	digest *tdigest.TDigest
+}
+
+func (a *PercentilesCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range a.src.Numbers(d) {
+		_ = a.digest.Add(val)
+	}
+}
+
+func (a *PercentilesCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*PercentilesCalculator); ok {
+		_ = a.digest.Merge(other.digest)
+	}
+}
+
+func (a *PercentilesCalculator) Finish() {}
+
+// Keys returns the percents, or the values for percentile ranks
+func (a *PercentilesCalculator) Keys() []float64 {
+	return a.keys
+}
+
+// Values returns the percentile (or the rank in [0, 100]) of every key, NaN means there is no value
+func (a *PercentilesCalculator) Values() []float64 {
+	rv := make([]float64, len(a.keys))
+	for i, key := range a.keys {
+		if a.digest.Count() == 0 {
+			rv[i] = math.NaN()
+		} else if a.ranks {
+			rv[i] = a.digest.CDF(key) * 100
+		} else {
+			rv[i] = a.digest.Quantile(key / 100)
+		}
+	}
+	return rv
+}
+
+// StatsAggregation calculates count, min, max, sum and avg in one pass,
+// extended adds sum_of_squares, variance and std_deviation.
+type StatsAggregation struct {
+	src      search.NumericValuesSource
+	extended bool
+	sigma    float64
+}
+
+// NewStatsAggregation returns a statsAggregation
+// sigma is the standard deviations of the bounds for extended stats
+func NewStatsAggregation(field search.NumericValuesSource, extended bool, sigma float64) *StatsAggregation {
+	return &StatsAggregation{
+		src:      field,
+		extended: extended,
+		sigma:    sigma,
+	}
+}
+
+func (t *StatsAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *StatsAggregation) Calculator() search.Calculator {
+	return &StatsCalculator{
+		src:      t.src,
+		extended: t.extended,
+		sigma:    t.sigma,
+		min:      math.Inf(1),
+		max:      math.Inf(-1),
+	}
+}
+
+type StatsCalculator struct {
+	src          search.NumericValuesSource
+	extended     bool
+	sigma        float64
+	count        uint64
+	sum          float64
+	sumOfSquares float64
+	min          float64
+	max          float64
+}
+
+func (a *StatsCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range a.src.Numbers(d) {
+		a.count++
+		a.sum += val
+		a.sumOfSquares += val * val
+		a.min = math.Min(a.min, val)
+		a.max = math.Max(a.max, val)
+	}
+}
+
+func (a *StatsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*StatsCalculator); ok {
+		a.count += other.count
+		a.sum += other.sum
+		a.sumOfSquares += other.sumOfSquares
+		a.min = math.Min(a.min, other.min)
+		a.max = math.Max(a.max, other.max)
+	}
+}
+
+func (a *StatsCalculator) Finish() {}
+
+func (a *StatsCalculator) Extended() bool {
+	return a.extended
+}
+
+func (a *StatsCalculator) Sigma() float64 {
+	return a.sigma
+}
+
+func (a *StatsCalculator) Count() uint64 {
+	return a.count
+}
+
+func (a *StatsCalculator) Sum() float64 {
+	return a.sum
+}
+
+func (a *StatsCalculator) SumOfSquares() float64 {
+	return a.sumOfSquares
+}
+
+// Min returns NaN when there is no value, so do Max and Avg
+func (a *StatsCalculator) Min() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.min
+}
+
+func (a *StatsCalculator) Max() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.max
+}
+
+func (a *StatsCalculator) Avg() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	return a.sum / float64(a.count)
+}
+
+// Variance returns the population variance
+func (a *StatsCalculator) Variance() float64 {
+	if a.count == 0 {
+		return math.NaN()
+	}
+	avg := a.Avg()
+	return math.Max(0, a.sumOfSquares/float64(a.count)-avg*avg)
+}
+
+// VarianceSampling returns the sample variance, it divides by count - 1
+func (a *StatsCalculator) VarianceSampling() float64 {
+	if a.count < 2 {
+		return math.NaN()
+	}
+	return a.Variance() * float64(a.count) / float64(a.count-1)
+}
+
+// ValueCountAggregation counts the values of a field, a document may have many values
+type ValueCountAggregation struct {
+	src     search.FieldSource
+	srcType int
+}
+
+// NewValueCountAggregation returns a valueCountAggregation
+// valueType can be TextValuesSource / NumericValuesSource
+func NewValueCountAggregation(field search.FieldSource, valueType int) *ValueCountAggregation {
+	return &ValueCountAggregation{
+		src:     field,
+		srcType: valueType,
+	}
+}
+
+func (t *ValueCountAggregation) Fields() []string {
+	return t.src.Fields()
+}
+
+func (t *ValueCountAggregation) Calculator() search.Calculator {
+	return &ValueCountCalculator{
+		src:     t.src,
+		srcType: t.srcType,
+	}
+}
+
+type ValueCountCalculator struct {
+	src     search.FieldSource
+	srcType int
+	count   uint64
+}
+
+func (a *ValueCountCalculator) Consume(d *search.DocumentMatch) {
+	switch a.srcType {
+	case NumericValuesSource:
+		// numbers are indexed with many precisions, only the full precision ones are values
+		a.count += uint64(len(a.src.Numbers(d)))
+	default:
+		a.count += uint64(len(a.src.Values(d)))
+	}
+}
+
+func (a *ValueCountCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*ValueCountCalculator); ok {
+		a.count += other.count
+	}
+}
+
+func (a *ValueCountCalculator) Finish() {}
+
+func (a *ValueCountCalculator) Value() float64 {
+	return float64(a.count)
+}
@@ -0,0 +1,318 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+)
+
+// RangeAggregation buckets the numeric values by ranges [from, to),
+// unlike the bluge one it loads the fields of the sub aggregations.
+type RangeAggregation struct {
+	src          search.NumericValuesSource
+	ranges       []numericRange
+	aggregations map[string]search.Aggregation
+}
+
+type numericRange struct {
+	name string
+	from float64
+	to   float64
+}
+
+// NewRangeAggregation returns a rangeAggregation
+// field use to set the field use to range aggregation
+func NewRangeAggregation(field search.NumericValuesSource) *RangeAggregation {
+	rv := &RangeAggregation{
+		src:          field,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *RangeAggregation) AddRange(from, to float64) {
+	t.ranges = append(t.ranges, numericRange{
+		name: fmt.Sprintf("[%f,%f)", from, to),
+		from: from,
+		to:   to,
+	})
+}
+
+func (t *RangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *RangeAggregation) Calculator() search.Calculator {
+	rv := &RangeCalculator{
+		src:    t.src,
+		ranges: t.ranges,
+	}
+	for _, r := range t.ranges {
+		rv.bucketsList = append(rv.bucketsList, search.NewBucket(r.name, t.aggregations))
+	}
+	return rv
+}
+
+func (t *RangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type RangeCalculator struct {
+	src         search.NumericValuesSource
+	ranges      []numericRange
+	bucketsList []*search.Bucket
+}
+
+func (a *RangeCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range a.src.Numbers(d) {
+		for i, r := range a.ranges {
+			if val >= r.from && val < r.to {
+				a.bucketsList[i].Consume(d)
+			}
+		}
+	}
+}
+
+func (a *RangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*RangeCalculator); ok {
+		// the ranges are the same in every shard
+		if len(a.bucketsList) == len(other.bucketsList) {
+			for i := range a.bucketsList {
+				a.bucketsList[i].Merge(other.bucketsList[i])
+			}
+		}
+	}
+}
+
+func (a *RangeCalculator) Finish() {
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
+}
+
+func (a *RangeCalculator) Buckets() []*search.Bucket {
+	return a.bucketsList
+}
+
+// DateRangeAggregation buckets the date values by ranges [from, to),
+// a zero time means the range is unbounded on that side.
+type DateRangeAggregation struct {
+	src          search.DateValuesSource
+	ranges       []dateRange
+	aggregations map[string]search.Aggregation
+}
+
+type dateRange struct {
+	name string
+	from time.Time
+	to   time.Time
+}
+
+// NewDateRangeAggregation returns a dateRangeAggregation
+// field use to set the field use to date range aggregation
+func NewDateRangeAggregation(field search.DateValuesSource) *DateRangeAggregation {
+	rv := &DateRangeAggregation{
+		src:          field,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+func (t *DateRangeAggregation) AddRange(from, to time.Time) {
+	t.ranges = append(t.ranges, dateRange{
+		name: fmt.Sprintf("[%s,%s)", from.Format(time.RFC3339), to.Format(time.RFC3339)),
+		from: from,
+		to:   to,
+	})
+}
+
+func (t *DateRangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *DateRangeAggregation) Calculator() search.Calculator {
+	rv := &DateRangeCalculator{
+		src:    t.src,
+		ranges: t.ranges,
+	}
+	for _, r := range t.ranges {
+		rv.bucketsList = append(rv.bucketsList, search.NewBucket(r.name, t.aggregations))
+	}
+	return rv
+}
+
+func (t *DateRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type DateRangeCalculator struct {
+	src         search.DateValuesSource
+	ranges      []dateRange
+	bucketsList []*search.Bucket
+}
+
+func (a *DateRangeCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range a.src.Dates(d) {
+		for i, r := range a.ranges {
+			if !r.from.IsZero() && val.Before(r.from) {
+				continue
+			}
+			if !r.to.IsZero() && !val.Before(r.to) {
+				continue
+			}
+			a.bucketsList[i].Consume(d)
+		}
+	}
+}
+
+func (a *DateRangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*DateRangeCalculator); ok {
+		// the ranges are the same in every shard
+		if len(a.bucketsList) == len(other.bucketsList) {
+			for i := range a.bucketsList {
+				a.bucketsList[i].Merge(other.bucketsList[i])
+			}
+		}
+	}
+}
+
+func (a *DateRangeCalculator) Finish() {
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
+}
+
+func (a *DateRangeCalculator) Buckets() []*search.Bucket {
+	return a.bucketsList
+}
+
+// IPRangeAggregation buckets the ip values by ranges [from, to),
+// the ips are compared by the indexed terms, a nil bound means the range is unbounded on that side.
+type IPRangeAggregation struct {
+	src          search.TextValuesSource
+	ranges       []ipRange
+	aggregations map[string]search.Aggregation
+}
+
+type ipRange struct {
+	name string
+	from []byte
+	to   []byte
+}
+
+// NewIPRangeAggregation returns an ipRangeAggregation
+// field use to set the field use to ip range aggregation
+func NewIPRangeAggregation(field search.TextValuesSource) *IPRangeAggregation {
+	rv := &IPRangeAggregation{
+		src:          field,
+		aggregations: make(map[string]search.Aggregation),
+	}
+	rv.aggregations["count"] = aggregations.CountMatches()
+	return rv
+}
+
+// AddRange adds a range named by key, from and to are the indexed terms of the ips
+func (t *IPRangeAggregation) AddRange(key string, from, to []byte) {
+	t.ranges = append(t.ranges, ipRange{
+		name: key,
+		from: from,
+		to:   to,
+	})
+}
+
+func (t *IPRangeAggregation) Fields() []string {
+	rv := t.src.Fields()
+	for _, agg := range t.aggregations {
+		rv = append(rv, agg.Fields()...)
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) Calculator() search.Calculator {
+	rv := &IPRangeCalculator{
+		src:    t.src,
+		ranges: t.ranges,
+	}
+	for _, r := range t.ranges {
+		rv.bucketsList = append(rv.bucketsList, search.NewBucket(r.name, t.aggregations))
+	}
+	return rv
+}
+
+func (t *IPRangeAggregation) AddAggregation(name string, aggregation search.Aggregation) {
+	t.aggregations[name] = aggregation
+}
+
+type IPRangeCalculator struct {
+	src         search.TextValuesSource
+	ranges      []ipRange
+	bucketsList []*search.Bucket
+}
+
+func (a *IPRangeCalculator) Consume(d *search.DocumentMatch) {
+	for _, val := range a.src.Values(d) {
+		for i, r := range a.ranges {
+			if r.from != nil && bytes.Compare(val, r.from) < 0 {
+				continue
+			}
+			if r.to != nil && bytes.Compare(val, r.to) >= 0 {
+				continue
+			}
+			a.bucketsList[i].Consume(d)
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*IPRangeCalculator); ok {
+		// the ranges are the same in every shard
+		if len(a.bucketsList) == len(other.bucketsList) {
+			for i := range a.bucketsList {
+				a.bucketsList[i].Merge(other.bucketsList[i])
+			}
+		}
+	}
+}
+
+func (a *IPRangeCalculator) Finish() {
+	for _, bucket := range a.bucketsList {
+		bucket.Finish()
+	}
+}
+
+func (a *IPRangeCalculator) Buckets() []*search.Bucket {
+	return a.bucketsList
+}
+
+// Range returns the bounds of the i-th bucket, nil means unbounded
+func (a *IPRangeCalculator) Range(i int) (from, to []byte) {
+	return a.ranges[i].from, a.ranges[i].to
+}
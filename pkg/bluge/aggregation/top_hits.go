@@ -0,0 +1,170 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// TopHitsAggregation keeps the best documents of a bucket,
+// the stored fields are only loaded for the documents getting into the top.
+type TopHitsAggregation struct {
+	from      int
+	size      int
+	sortOrder search.SortOrder
+}
+
+// NewTopHitsAggregation returns a topHitsAggregation
+// sortOrder use to rank the documents, default is the score
+func NewTopHitsAggregation(from, size int, sortOrder search.SortOrder) *TopHitsAggregation {
+	if len(sortOrder) == 0 {
+		sortOrder = search.SortOrder{search.ParseSearchSortString("_score")}
+	}
+	return &TopHitsAggregation{
+		from:      from,
+		size:      size,
+		sortOrder: sortOrder,
+	}
+}
+
+func (t *TopHitsAggregation) Fields() []string {
+	return t.sortOrder.Fields()
+}
+
+func (t *TopHitsAggregation) Calculator() search.Calculator {
+	return &TopHitsCalculator{
+		from:      t.from,
+		size:      t.size,
+		sortOrder: t.sortOrder,
+		maxScore:  math.NaN(),
+	}
+}
+
+type TopHit struct {
+	ID        string
+	Index     string
+	Score     float64
+	Timestamp time.Time
+	Source    []byte
+	SortValue [][]byte
+	hitNumber int
+}
+
+type TopHitsCalculator struct {
+	from      int
+	size      int
+	sortOrder search.SortOrder
+	total     uint64
+	maxScore  float64
+	hits      []*TopHit // sorted, keeps from + size at most
+	hitNumber int
+}
+
+func (a *TopHitsCalculator) Consume(d *search.DocumentMatch) {
+	a.total++
+	if math.IsNaN(a.maxScore) || d.Score > a.maxScore {
+		a.maxScore = d.Score
+	}
+	if a.from+a.size == 0 {
+		return
+	}
+
+	// don't use sortOrder.Compute, the SortValue of the document is used by the query sort
+	hit := &TopHit{Score: d.Score, SortValue: make([][]byte, len(a.sortOrder)), hitNumber: a.hitNumber}
+	a.hitNumber++
+	for i, s := range a.sortOrder {
+		v := s.Value(d)
+		hit.SortValue[i] = append(make([]byte, 0, len(v)), v...)
+	}
+	if len(a.hits) == a.from+a.size && !a.less(hit, a.hits[len(a.hits)-1]) {
+		return
+	}
+
+	_ = d.VisitStoredFields(func(field string, value []byte) bool {
+		switch field {
+		case "_id":
+			hit.ID = string(value)
+		case "_index":
+			hit.Index = string(value)
+		case "@timestamp":
+			hit.Timestamp, _ = bluge.DecodeDateTime(value)
+		case "_source":
+			hit.Source = append(make([]byte, 0, len(value)), value...)
+		}
+		return true
+	})
+	a.insert(hit)
+}
+
+func (a *TopHitsCalculator) insert(hit *TopHit) {
+	i := sort.Search(len(a.hits), func(i int) bool { return a.less(hit, a.hits[i]) })
+	if i >= a.from+a.size {
+		return
+	}
+	a.hits = append(a.hits, nil)
+	copy(a.hits[i+1:], a.hits[i:])
+	a.hits[i] = hit
+	if len(a.hits) > a.from+a.size {
+		a.hits = a.hits[:a.from+a.size]
+	}
+}
+
+func (a *TopHitsCalculator) less(x, y *TopHit) bool {
+	return a.sortOrder.Compare(
+		&search.DocumentMatch{SortValue: x.SortValue, HitNumber: x.hitNumber},
+		&search.DocumentMatch{SortValue: y.SortValue, HitNumber: y.hitNumber},
+	) < 0
+}
+
+func (a *TopHitsCalculator) Merge(other search.Calculator) {
+	if other, ok := other.(*TopHitsCalculator); ok {
+		a.total += other.total
+		if math.IsNaN(a.maxScore) || other.maxScore > a.maxScore {
+			a.maxScore = other.maxScore
+		}
+		for _, hit := range other.hits {
+			a.insert(hit)
+		}
+	}
+}
+
+func (a *TopHitsCalculator) Finish() {}
+
+func (a *TopHitsCalculator) Total() uint64 {
+	return a.total
+}
+
+// MaxScore returns NaN when there is no document
+func (a *TopHitsCalculator) MaxScore() float64 {
+	return a.maxScore
+}
+
+func (a *TopHitsCalculator) SortOrder() search.SortOrder {
+	return a.sortOrder
+}
+
+// Hits returns the documents after from
+func (a *TopHitsCalculator) Hits() []*TopHit {
+	if a.from >= len(a.hits) {
+		return nil
+	}
+	return a.hits[a.from:]
+}
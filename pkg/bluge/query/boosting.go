@@ -0,0 +1,84 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/searcher"
+)
+
+// BoostingQuery matches the documents of the positive query and
+// multiplies the score of the ones also matching the negative query by negativeBoost.
+type BoostingQuery struct {
+	positive      bluge.Query
+	negative      bluge.Query
+	negativeBoost float64
+	boost         float64
+}
+
+func NewBoostingQuery(positive, negative bluge.Query, negativeBoost float64) *BoostingQuery {
+	return &BoostingQuery{
+		positive:      positive,
+		negative:      negative,
+		negativeBoost: negativeBoost,
+		boost:         1.0,
+	}
+}
+
+func (q *BoostingQuery) SetBoost(b float64) *BoostingQuery {
+	q.boost = b
+	return q
+}
+
+func (q *BoostingQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *BoostingQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	positive, err := q.positive.Searcher(i, options)
+	if err != nil {
+		return nil, err
+	}
+	// a boolean query with only a should clause never restricts the matches
+	negative, err := bluge.NewBooleanQuery().AddShould(q.negative).Searcher(i, options)
+	if err != nil {
+		_ = positive.Close()
+		return nil, err
+	}
+	return searcher.NewBooleanSearcher(positive, negative, nil, q, options)
+}
+
+// ScoreComposite receives the positive match, followed by the negative one when it matched too.
+func (q *BoostingQuery) ScoreComposite(constituents []*search.DocumentMatch) float64 {
+	score := constituents[0].Score * q.boost
+	if len(constituents) > 1 {
+		score *= q.negativeBoost
+	}
+	return score
+}
+
+func (q *BoostingQuery) ExplainComposite(constituents []*search.DocumentMatch) *search.Explanation {
+	score := q.ScoreComposite(constituents)
+	if len(constituents) > 1 {
+		return search.NewExplanation(score,
+			fmt.Sprintf("product of positive score and negative_boost %v", q.negativeBoost),
+			constituents[0].Explanation)
+	}
+	return search.NewExplanation(score, "positive score", constituents[0].Explanation)
+}
@@ -0,0 +1,353 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/analysis/tokenizer"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// CombinedFieldsQuery analyzes the text and scores every term with BM25F,
+// as if all the fields were indexed into one combined field.
+type CombinedFieldsQuery struct {
+	match     string
+	fields    []string
+	weights   []float64
+	analyzer  *analysis.Analyzer
+	operator  bluge.MatchQueryOperator
+	minShould int
+	boost     float64
+}
+
+func NewCombinedFieldsQuery(match string) *CombinedFieldsQuery {
+	return &CombinedFieldsQuery{
+		match:    match,
+		operator: bluge.MatchQueryOperatorOr,
+		boost:    1.0,
+	}
+}
+
+// AddField adds a field, the weight multiplies the term frequencies and the length of the field.
+func (q *CombinedFieldsQuery) AddField(field string, weight float64) *CombinedFieldsQuery {
+	q.fields = append(q.fields, field)
+	q.weights = append(q.weights, weight)
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetAnalyzer(a *analysis.Analyzer) *CombinedFieldsQuery {
+	q.analyzer = a
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetOperator(operator bluge.MatchQueryOperator) *CombinedFieldsQuery {
+	q.operator = operator
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetMinShould(minShould int) *CombinedFieldsQuery {
+	q.minShould = minShould
+	return q
+}
+
+func (q *CombinedFieldsQuery) SetBoost(b float64) *CombinedFieldsQuery {
+	q.boost = b
+	return q
+}
+
+func (q *CombinedFieldsQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *CombinedFieldsQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	var tokens analysis.TokenStream
+	if q.analyzer != nil {
+		tokens = q.analyzer.Analyze([]byte(q.match))
+	} else if options.DefaultAnalyzer != nil {
+		tokens = options.DefaultAnalyzer.Analyze([]byte(q.match))
+	} else {
+		tokens = tokenizer.MakeTokenStream([]byte(q.match))
+	}
+	if len(tokens) == 0 || len(q.fields) == 0 {
+		return bluge.NewMatchNoneQuery().Searcher(i, options)
+	}
+
+	booleanQuery := bluge.NewBooleanQuery()
+	for _, token := range tokens {
+		tq := &combinedFieldsTermQuery{
+			term:    token.Term,
+			fields:  q.fields,
+			weights: q.weights,
+			boost:   q.boost,
+		}
+		switch q.operator {
+		case bluge.MatchQueryOperatorOr:
+			booleanQuery.AddShould(tq)
+		case bluge.MatchQueryOperatorAnd:
+			booleanQuery.AddMust(tq)
+		default:
+			return nil, fmt.Errorf("unhandled operator %d", q.operator)
+		}
+	}
+	if q.operator == bluge.MatchQueryOperatorOr {
+		minShould := q.minShould
+		if minShould < 1 {
+			minShould = 1
+		}
+		booleanQuery.SetMinShould(minShould)
+	}
+	return booleanQuery.Searcher(i, options)
+}
+
+// combinedFieldsTermQuery matches a term in any of the fields.
+type combinedFieldsTermQuery struct {
+	term    []byte
+	fields  []string
+	weights []float64
+	boost   float64
+}
+
+func (q *combinedFieldsTermQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	s := &combinedFieldsSearcher{
+		indexReader: i,
+		term:        string(q.term),
+		weights:     q.weights,
+		boost:       q.boost,
+		options:     options,
+		iterators:   make([]segment.PostingsIterator, len(q.fields)),
+		currs:       make([]segment.Posting, len(q.fields)),
+		avgLens:     make([]float64, len(q.fields)),
+	}
+
+	// the combined field is in a document when one of the fields is,
+	// the term frequency of the combined field is taken as the largest one
+	var docCount, docFreq uint64
+	for n, field := range q.fields {
+		stats, err := i.CollectionStats(field)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		if stats != nil && stats.DocumentCount() > 0 {
+			if stats.DocumentCount() > docCount {
+				docCount = stats.DocumentCount()
+			}
+			s.avgLens[n] = float64(stats.SumTotalTermFrequency()) / float64(stats.DocumentCount())
+			s.avgDocLen += q.weights[n] * s.avgLens[n]
+		}
+		s.iterators[n], err = i.PostingsIterator(q.term, field, true, true, options.IncludeTermVectors)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		if s.iterators[n] != nil && s.iterators[n].Count() > docFreq {
+			docFreq = s.iterators[n].Count()
+		}
+	}
+	s.docCount = docCount
+	s.docFreq = docFreq
+	s.idf = math.Log(1.0 + (float64(docCount)-float64(docFreq)+0.5)/(float64(docFreq)+0.5))
+	return s, nil
+}
+
+type combinedFieldsSearcher struct {
+	indexReader search.Reader
+	term        string
+	weights     []float64
+	boost       float64
+	options     search.SearcherOptions
+	iterators   []segment.PostingsIterator
+	currs       []segment.Posting
+	initialized bool
+
+	docCount  uint64
+	docFreq   uint64
+	idf       float64
+	avgLens   []float64
+	avgDocLen float64
+}
+
+func (s *combinedFieldsSearcher) initIterators() error {
+	var err error
+	for n, it := range s.iterators {
+		if it == nil {
+			continue
+		}
+		if s.currs[n], err = it.Next(); err != nil {
+			return err
+		}
+	}
+	s.initialized = true
+	return nil
+}
+
+func (s *combinedFieldsSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initIterators(); err != nil {
+			return nil, err
+		}
+	}
+
+	var number uint64
+	found := false
+	for _, curr := range s.currs {
+		if curr != nil && (!found || curr.Number() < number) {
+			number = curr.Number()
+			found = true
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	rv := s.buildDocumentMatch(ctx, number)
+	var err error
+	for n, curr := range s.currs {
+		if curr != nil && curr.Number() == number {
+			if s.currs[n], err = s.iterators[n].Next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rv, nil
+}
+
+func (s *combinedFieldsSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initIterators(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	for n, curr := range s.currs {
+		if curr != nil && curr.Number() < number {
+			if s.currs[n], err = s.iterators[n].Advance(number); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.Next(ctx)
+}
+
+func (s *combinedFieldsSearcher) buildDocumentMatch(ctx *search.Context, number uint64) *search.DocumentMatch {
+	rv := ctx.DocumentMatchPool.Get()
+	rv.SetReader(s.indexReader)
+	rv.Number = number
+
+	var freq, docLen float64
+	for n, curr := range s.currs {
+		if curr == nil || curr.Number() != number {
+			// the length of a field without the term is unknown here, assume the average one
+			docLen += s.weights[n] * s.avgLens[n]
+			continue
+		}
+		freq += s.weights[n] * float64(curr.Frequency())
+		docLen += s.weights[n] * float64(math.Float32bits(float32(curr.Norm())))
+		for _, v := range curr.Locations() {
+			rv.FieldTermLocations = append(rv.FieldTermLocations, search.FieldTermLocation{
+				Field: v.Field(),
+				Term:  s.term,
+				Location: search.Location{
+					Pos:   v.Pos(),
+					Start: v.Start(),
+					End:   v.End(),
+				},
+			})
+		}
+	}
+
+	tf := s.tf(freq, docLen)
+	rv.Score = s.boost * s.idf * tf
+	if s.options.Explain {
+		children := []*search.Explanation{
+			search.NewExplanation(s.idf, "idf, computed as log(1 + (N - n + 0.5) / (n + 0.5)) from:",
+				search.NewExplanation(float64(s.docFreq), "n, number of documents containing term"),
+				search.NewExplanation(float64(s.docCount), "N, total number of documents with field")),
+		}
+		if s.boost != 1.0 {
+			children = append(children, search.NewExplanation(s.boost, "boost"))
+		}
+		children = append(children, search.NewExplanation(tf,
+			"tf, computed as freq / (freq + k1 * (1 - b + b * dl / avgdl)) from:",
+			search.NewExplanation(freq, "freq, weighted occurrences of term within the combined fields"),
+			search.NewExplanation(bm25K1, "k1, term saturation parameter"),
+			search.NewExplanation(bm25B, "b, length normalization parameter"),
+			search.NewExplanation(docLen, "dl, weighted length of the combined fields"),
+			search.NewExplanation(s.avgDocLen, "avgdl, average weighted length of the combined fields")))
+		rv.Explanation = search.NewExplanation(rv.Score,
+			fmt.Sprintf("score(freq=%v), computed as boost * idf * tf from:", freq), children...)
+	}
+	return rv
+}
+
+func (s *combinedFieldsSearcher) tf(freq, docLen float64) float64 {
+	norm := bm25K1 * (1 - bm25B)
+	if s.avgDocLen > 0 {
+		norm += bm25K1 * bm25B * docLen / s.avgDocLen
+	}
+	return freq / (freq + norm)
+}
+
+func (s *combinedFieldsSearcher) Close() error {
+	var err error
+	for _, it := range s.iterators {
+		if it == nil {
+			continue
+		}
+		if e := it.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *combinedFieldsSearcher) Count() uint64 {
+	var sum uint64
+	for _, it := range s.iterators {
+		if it != nil {
+			sum += it.Count()
+		}
+	}
+	return sum
+}
+
+func (s *combinedFieldsSearcher) Min() int {
+	return 0
+}
+
+func (s *combinedFieldsSearcher) Size() int {
+	size := 0
+	for _, it := range s.iterators {
+		if it != nil {
+			size += it.Size()
+		}
+	}
+	return size
+}
+
+func (s *combinedFieldsSearcher) DocumentMatchPoolSize() int {
+	return 1
+}
@@ -0,0 +1,118 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+)
+
+// DocumentQuery matches the document with the id if the query matches it,
+// the score and the explanation are the ones of the query.
+type DocumentQuery struct {
+	query bluge.Query
+	id    string
+}
+
+func NewDocumentQuery(query bluge.Query, id string) *DocumentQuery {
+	return &DocumentQuery{query: query, id: id}
+}
+
+func (q *DocumentQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	idOptions := options
+	idOptions.Explain = false
+	idOptions.Score = "none"
+	ids, err := bluge.NewTermQuery(q.id).SetField("_id").Searcher(i, idOptions)
+	if err != nil {
+		return nil, err
+	}
+	s, err := q.query.Searcher(i, options)
+	if err != nil {
+		_ = ids.Close()
+		return nil, err
+	}
+	return &documentSearcher{searcher: s, ids: ids}, nil
+}
+
+type documentSearcher struct {
+	searcher search.Searcher
+	ids      search.Searcher
+	done     bool
+}
+
+func (s *documentSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if s.done {
+		return nil, nil
+	}
+	s.done = true
+	idMatch, err := s.ids.Next(ctx)
+	if err != nil || idMatch == nil {
+		return nil, err
+	}
+	return s.match(ctx, idMatch)
+}
+
+func (s *documentSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	if s.done {
+		return nil, nil
+	}
+	s.done = true
+	idMatch, err := s.ids.Advance(ctx, number)
+	if err != nil || idMatch == nil {
+		return nil, err
+	}
+	return s.match(ctx, idMatch)
+}
+
+// match returns the document of the id if the query matches it
+func (s *documentSearcher) match(ctx *search.Context, idMatch *search.DocumentMatch) (*search.DocumentMatch, error) {
+	number := idMatch.Number
+	ctx.DocumentMatchPool.Put(idMatch)
+
+	rv, err := s.searcher.Advance(ctx, number)
+	if err != nil || rv == nil {
+		return nil, err
+	}
+	if rv.Number != number {
+		ctx.DocumentMatchPool.Put(rv)
+		return nil, nil
+	}
+	return rv, nil
+}
+
+func (s *documentSearcher) Close() error {
+	err := s.searcher.Close()
+	if e := s.ids.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+func (s *documentSearcher) Count() uint64 {
+	return s.ids.Count()
+}
+
+func (s *documentSearcher) Min() int {
+	return 0
+}
+
+func (s *documentSearcher) Size() int {
+	return s.searcher.Size() + s.ids.Size()
+}
+
+func (s *documentSearcher) DocumentMatchPoolSize() int {
+	return s.searcher.DocumentMatchPoolSize() + s.ids.DocumentMatchPoolSize()
+}
@@ -0,0 +1,263 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis/analyzer"
+)
+
+// String renders a query in the lucene syntax, the match queries are shown with their analyzed terms
+func String(q bluge.Query) string {
+	var sb strings.Builder
+	writeQuery(&sb, q, false)
+	return sb.String()
+}
+
+func writeQuery(sb *strings.Builder, q bluge.Query, nested bool) {
+	switch q := q.(type) {
+	case *bluge.BooleanQuery:
+		writeBoolean(sb, q, nested)
+		return
+	case *bluge.MatchAllQuery:
+		sb.WriteString("*:*")
+		writeBoost(sb, q.Boost())
+	case *bluge.MatchNoneQuery:
+		sb.WriteString("MatchNoDocsQuery")
+	case *bluge.TermQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(q.Term())
+		writeBoost(sb, q.Boost())
+	case *bluge.MatchQuery:
+		writeMatch(sb, q, nested)
+	case *bluge.MatchPhraseQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(strconv.Quote(q.Phrase()))
+		if q.Slop() > 0 {
+			sb.WriteString("~" + strconv.Itoa(q.Slop()))
+		}
+		writeBoost(sb, q.Boost())
+	case *bluge.MultiPhraseQuery:
+		writeField(sb, q.Field())
+		terms := make([]string, len(q.Terms()))
+		for i, t := range q.Terms() {
+			terms[i] = strings.Join(t, "|")
+		}
+		sb.WriteString(strconv.Quote(strings.Join(terms, " ")))
+		if q.Slop() > 0 {
+			sb.WriteString("~" + strconv.Itoa(q.Slop()))
+		}
+		writeBoost(sb, q.Boost())
+	case *bluge.FuzzyQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(q.Term() + "~" + strconv.Itoa(q.Fuzziness()))
+		writeBoost(sb, q.Boost())
+	case *bluge.PrefixQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(q.Prefix() + "*")
+		writeBoost(sb, q.Boost())
+	case *bluge.WildcardQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(q.Wildcard())
+		writeBoost(sb, q.Boost())
+	case *bluge.RegexpQuery:
+		writeField(sb, q.Field())
+		sb.WriteString("/" + q.Regexp() + "/")
+		writeBoost(sb, q.Boost())
+	case *bluge.NumericRangeQuery:
+		min, minInclusive := q.Min()
+		max, maxInclusive := q.Max()
+		from, to := "*", "*"
+		if min != bluge.MinNumeric {
+			from = strconv.FormatFloat(min, 'f', -1, 64)
+		}
+		if max != bluge.MaxNumeric {
+			to = strconv.FormatFloat(max, 'f', -1, 64)
+		}
+		writeField(sb, q.Field())
+		writeRange(sb, from, to, minInclusive, maxInclusive)
+		writeBoost(sb, q.Boost())
+	case *bluge.DateRangeQuery:
+		start, startInclusive := q.Start()
+		end, endInclusive := q.End()
+		from, to := "*", "*"
+		if !start.IsZero() {
+			from = start.UTC().Format(time.RFC3339Nano)
+		}
+		if !end.IsZero() {
+			to = end.UTC().Format(time.RFC3339Nano)
+		}
+		writeField(sb, q.Field())
+		writeRange(sb, from, to, startInclusive, endInclusive)
+		writeBoost(sb, q.Boost())
+	case *bluge.TermRangeQuery:
+		min, minInclusive := q.Min()
+		max, maxInclusive := q.Max()
+		if min == "" {
+			min = "*"
+		}
+		if max == "" {
+			max = "*"
+		}
+		writeField(sb, q.Field())
+		writeRange(sb, min, max, minInclusive, maxInclusive)
+		writeBoost(sb, q.Boost())
+	case *bluge.GeoBoundingBoxQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(fmt.Sprintf("GeoBoundingBox(%v, %v)", q.TopLeft(), q.BottomRight()))
+		writeBoost(sb, q.Boost())
+	case *bluge.GeoDistanceQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(fmt.Sprintf("GeoDistance(%v, %s)", q.Location(), q.Distance()))
+		writeBoost(sb, q.Boost())
+	case *bluge.GeoBoundingPolygonQuery:
+		writeField(sb, q.Field())
+		sb.WriteString(fmt.Sprintf("GeoPolygon(%v)", q.Points()))
+		writeBoost(sb, q.Boost())
+	case *BoostingQuery:
+		sb.WriteString("Boosting(positive=")
+		writeQuery(sb, q.positive, false)
+		sb.WriteString(", negative=")
+		writeQuery(sb, q.negative, false)
+		sb.WriteString(", negative_boost=" + strconv.FormatFloat(q.negativeBoost, 'f', -1, 64) + ")")
+		writeBoost(sb, q.boost)
+	case *CombinedFieldsQuery:
+		fields := make([]string, len(q.fields))
+		for i, field := range q.fields {
+			fields[i] = field
+			if q.weights[i] != 1 {
+				fields[i] += "^" + strconv.FormatFloat(q.weights[i], 'f', -1, 64)
+			}
+		}
+		sb.WriteString("CombinedFields((" + strings.Join(fields, " ") + "):" + strconv.Quote(q.match) + ")")
+		writeBoost(sb, q.boost)
+	case *TermsSetQuery:
+		sb.WriteString("TermsSet(")
+		for i, sub := range q.queries {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			writeQuery(sb, sub, true)
+		}
+		if q.field != "" {
+			sb.WriteString(", minimum_should_match_field=" + q.field)
+		} else {
+			sb.WriteString(", minimum_should_match=" + strconv.Itoa(q.minimum))
+		}
+		sb.WriteByte(')')
+		writeBoost(sb, q.boost)
+	default:
+		sb.WriteString(strings.TrimPrefix(fmt.Sprintf("%T", q), "*"))
+	}
+}
+
+func writeBoolean(sb *strings.Builder, q *bluge.BooleanQuery, nested bool) {
+	nested = nested || q.Boost() != 1 || q.MinShould() > 0
+	if nested {
+		sb.WriteByte('(')
+	}
+	clauses := 0
+	write := func(prefix string, queries []bluge.Query) {
+		for _, sub := range queries {
+			if clauses > 0 {
+				sb.WriteByte(' ')
+			}
+			clauses++
+			sb.WriteString(prefix)
+			writeQuery(sb, sub, true)
+		}
+	}
+	write("+", q.Musts())
+	write("", q.Shoulds())
+	write("-", q.MustNots())
+	if nested {
+		sb.WriteByte(')')
+	}
+	if q.MinShould() > 0 {
+		sb.WriteString("~" + strconv.Itoa(q.MinShould()))
+	}
+	writeBoost(sb, q.Boost())
+}
+
+// writeMatch shows the terms the analyzer makes of the text, as the match query is rewritten when searching
+func writeMatch(sb *strings.Builder, q *bluge.MatchQuery, nested bool) {
+	zer := q.Analyzer()
+	if zer == nil {
+		zer = analyzer.NewStandardAnalyzer() // the default analyzer of the searcher
+	}
+	tokens := zer.Analyze([]byte(q.Match()))
+	if len(tokens) == 0 {
+		sb.WriteString("MatchNoDocsQuery")
+		return
+	}
+	prefix := ""
+	if q.Operator() == bluge.MatchQueryOperatorAnd {
+		prefix = "+"
+	}
+	group := len(tokens) > 1 && (nested || q.Boost() != 1)
+	if group {
+		sb.WriteByte('(')
+	}
+	for i, token := range tokens {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if len(tokens) > 1 {
+			sb.WriteString(prefix)
+		}
+		writeField(sb, q.Field())
+		sb.Write(token.Term)
+		if q.Fuzziness() > 0 {
+			sb.WriteString("~" + strconv.Itoa(q.Fuzziness()))
+		}
+	}
+	if group {
+		sb.WriteByte(')')
+	}
+	writeBoost(sb, q.Boost())
+}
+
+func writeField(sb *strings.Builder, field string) {
+	if field == "" {
+		field = "_all"
+	}
+	sb.WriteString(field + ":")
+}
+
+func writeRange(sb *strings.Builder, from, to string, fromInclusive, toInclusive bool) {
+	if fromInclusive {
+		sb.WriteByte('[')
+	} else {
+		sb.WriteByte('{')
+	}
+	sb.WriteString(from + " TO " + to)
+	if toInclusive {
+		sb.WriteByte(']')
+	} else {
+		sb.WriteByte('}')
+	}
+}
+
+func writeBoost(sb *strings.Builder, boost float64) {
+	if boost != 1 {
+		sb.WriteString("^" + strconv.FormatFloat(boost, 'f', -1, 64))
+	}
+}
@@ -0,0 +1,263 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"math"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+	segment "github.com/blugelabs/bluge_segment_api"
+)
+
+// TermsSetQuery matches the documents which match at least a minimum number of the term queries,
+// the minimum is read per document from a numeric field or set for the whole query.
+type TermsSetQuery struct {
+	queries []bluge.Query
+	field   string
+	minimum int
+	boost   float64
+}
+
+func NewTermsSetQuery(queries ...bluge.Query) *TermsSetQuery {
+	return &TermsSetQuery{
+		queries: queries,
+		minimum: 1,
+		boost:   1.0,
+	}
+}
+
+// SetMinimumShouldMatchField reads the minimum from the numeric field of each document,
+// the documents without the field don't match.
+func (q *TermsSetQuery) SetMinimumShouldMatchField(field string) *TermsSetQuery {
+	q.field = field
+	return q
+}
+
+func (q *TermsSetQuery) SetMinimumShouldMatch(minimum int) *TermsSetQuery {
+	q.minimum = minimum
+	return q
+}
+
+func (q *TermsSetQuery) SetBoost(b float64) *TermsSetQuery {
+	q.boost = b
+	return q
+}
+
+func (q *TermsSetQuery) Boost() float64 {
+	return q.boost
+}
+
+func (q *TermsSetQuery) Searcher(i search.Reader, options search.SearcherOptions) (search.Searcher, error) {
+	if len(q.queries) == 0 {
+		return bluge.NewMatchNoneQuery().Searcher(i, options)
+	}
+
+	s := &termsSetSearcher{
+		indexReader: i,
+		minimum:     q.minimum,
+		field:       q.field,
+		boost:       q.boost,
+		options:     options,
+		searchers:   make([]search.Searcher, 0, len(q.queries)),
+		currs:       make([]*search.DocumentMatch, len(q.queries)),
+		matches:     make([]*search.DocumentMatch, 0, len(q.queries)),
+	}
+	for _, query := range q.queries {
+		subs, err := query.Searcher(i, options)
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.searchers = append(s.searchers, subs)
+	}
+	if q.field != "" {
+		dvReader, err := i.DocumentValueReader([]string{q.field})
+		if err != nil {
+			_ = s.Close()
+			return nil, err
+		}
+		s.dvReader = dvReader
+	}
+	return s, nil
+}
+
+type termsSetSearcher struct {
+	indexReader search.Reader
+	dvReader    segment.DocumentValueReader
+	field       string
+	minimum     int
+	boost       float64
+	options     search.SearcherOptions
+	searchers   []search.Searcher
+	currs       []*search.DocumentMatch
+	matches     []*search.DocumentMatch
+	initialized bool
+}
+
+func (s *termsSetSearcher) initSearchers(ctx *search.Context) error {
+	var err error
+	for i, searcher := range s.searchers {
+		if s.currs[i], err = searcher.Next(ctx); err != nil {
+			return err
+		}
+	}
+	s.initialized = true
+	return nil
+}
+
+func (s *termsSetSearcher) Next(ctx *search.Context) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initSearchers(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	for {
+		var number uint64
+		found := false
+		for _, curr := range s.currs {
+			if curr != nil && (!found || curr.Number < number) {
+				number = curr.Number
+				found = true
+			}
+		}
+		if !found {
+			return nil, nil
+		}
+
+		s.matches = s.matches[:0]
+		for i, curr := range s.currs {
+			if curr != nil && curr.Number == number {
+				s.matches = append(s.matches, curr)
+				if s.currs[i], err = s.searchers[i].Next(ctx); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		required, err := s.required(number)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.matches) >= required {
+			return s.buildDocumentMatch(ctx), nil
+		}
+		for _, m := range s.matches {
+			ctx.DocumentMatchPool.Put(m)
+		}
+	}
+}
+
+// required returns the minimum number of matches of the document
+func (s *termsSetSearcher) required(number uint64) (int, error) {
+	if s.dvReader == nil {
+		return s.minimum, nil
+	}
+	required := math.MaxInt32
+	err := s.dvReader.VisitDocumentValues(number, func(field string, term []byte) {
+		prefixCoded := numeric.PrefixCoded(term)
+		if shift, err := prefixCoded.Shift(); err != nil || shift != 0 {
+			return
+		}
+		if i64, err := prefixCoded.Int64(); err == nil {
+			required = int(numeric.Int64ToFloat64(i64))
+		}
+	})
+	if required < 1 {
+		required = 1
+	}
+	return required, err
+}
+
+func (s *termsSetSearcher) buildDocumentMatch(ctx *search.Context) *search.DocumentMatch {
+	rv := s.matches[0]
+	var score float64
+	for _, m := range s.matches {
+		score += m.Score
+	}
+	rv.Score = score * s.boost
+	if s.options.Explain {
+		children := make([]*search.Explanation, 0, len(s.matches))
+		for _, m := range s.matches {
+			children = append(children, m.Explanation)
+		}
+		rv.Explanation = search.NewExplanation(rv.Score, "sum of matched terms multiplied by boost", children...)
+	}
+	rv.FieldTermLocations = search.MergeFieldTermLocations(rv.FieldTermLocations, s.matches[1:])
+	for _, m := range s.matches[1:] {
+		ctx.DocumentMatchPool.Put(m)
+	}
+	return rv
+}
+
+func (s *termsSetSearcher) Advance(ctx *search.Context, number uint64) (*search.DocumentMatch, error) {
+	if !s.initialized {
+		if err := s.initSearchers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	for i, curr := range s.currs {
+		if curr != nil && curr.Number < number {
+			ctx.DocumentMatchPool.Put(curr)
+			if s.currs[i], err = s.searchers[i].Advance(ctx, number); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s.Next(ctx)
+}
+
+func (s *termsSetSearcher) Close() error {
+	var err error
+	for _, searcher := range s.searchers {
+		if e := searcher.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *termsSetSearcher) Count() uint64 {
+	var sum uint64
+	for _, searcher := range s.searchers {
+		sum += searcher.Count()
+	}
+	return sum
+}
+
+func (s *termsSetSearcher) Min() int {
+	return 0
+}
+
+func (s *termsSetSearcher) Size() int {
+	size := 0
+	for _, searcher := range s.searchers {
+		size += searcher.Size()
+	}
+	return size
+}
+
+func (s *termsSetSearcher) DocumentMatchPoolSize() int {
+	size := len(s.searchers) + 1
+	for _, searcher := range s.searchers {
+		size += searcher.DocumentMatchPoolSize()
+	}
+	return size
+}
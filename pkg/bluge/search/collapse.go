@@ -0,0 +1,255 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
+	"github.com/blugelabs/bluge/search"
+	"github.com/blugelabs/bluge/search/aggregations"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery"
+	"github.com/zinclabs/zincsearch/pkg/uquery/query"
+)
+
+// collapseKey is the value of the collapse field, the documents without the field are a group too
+type collapseKey struct {
+	value   string
+	missing bool
+}
+
+func collapseKeyOf(d *search.DocumentMatch, field string) collapseKey {
+	value, ok := CollapseValue(d, field)
+	return collapseKey{value: value, missing: !ok}
+}
+
+// CollapseValue returns the value of the collapse field of a hit of a collapsed search
+func CollapseValue(d *search.DocumentMatch, field string) (string, bool) {
+	values := d.DocValues(field)
+	if len(values) == 0 {
+		return "", false
+	}
+	return string(values[0]), true
+}
+
+// CollapsedList is the result of a collapsed search, it returns the best document of each group
+type CollapsedList struct {
+	docs      []*search.DocumentMatch
+	next      int
+	bucket    *search.Bucket
+	innerHits map[*search.DocumentMatch]search.DocumentMatchIterator
+}
+
+func (d *CollapsedList) Next() (*search.DocumentMatch, error) {
+	if d.next >= len(d.docs) {
+		return nil, nil
+	}
+	d.next++
+	return d.docs[d.next-1], nil
+}
+
+func (d *CollapsedList) Aggregations() *search.Bucket {
+	return d.bucket
+}
+
+// InnerHits returns the top documents of the group of doc, nil without inner_hits
+func (d *CollapsedList) InnerHits(doc *search.DocumentMatch) search.DocumentMatchIterator {
+	return d.innerHits[doc]
+}
+
+// collapseSearch keeps the first document of each collapse key in the merge of the readers,
+// the groups after from and size are dropped, then the inner hits of every group are searched.
+func collapseSearch(
+	ctx context.Context,
+	q *meta.ZincQuery,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	cfg *config.Config,
+	readers ...*bluge.Reader,
+) (search.DocumentMatchIterator, error) {
+	req, err := uquery.ParseQueryDSL(q, mappings, analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
+	if err != nil {
+		return nil, err
+	}
+	var sortOrder search.SortOrder
+	if req, ok := req.(*bluge.TopNSearch); ok {
+		sortOrder = req.SortOrder().Copy()
+	}
+	field := q.Collapse.Field
+	groups := q.From + q.Size
+
+	eg := &errgroup.Group{}
+	eg.SetLimit(cfg.Shard.GoroutineNum)
+	docs := make([][]*search.DocumentMatch, len(readers))
+	buckets := make([]*search.Bucket, len(readers))
+	for i, r := range readers {
+		i, r := i, r
+		eg.Go(func() error {
+			var err error
+			docs[i], buckets[i], err = collapseReader(ctx, r, q, groups, mappings, analyzers, cfg)
+			return err
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	list := &CollapsedList{
+		bucket: search.NewBucket("",
+			map[string]search.Aggregation{
+				"duration": aggregations.Duration(),
+			},
+		),
+	}
+	var all []*search.DocumentMatch
+	for i := range readers {
+		all = append(all, docs[i]...)
+		list.bucket.Merge(buckets[i])
+	}
+	sort.SliceStable(all, func(i, j int) bool { return sortOrder.Compare(all[i], all[j]) < 0 })
+	seen := make(map[collapseKey]struct{}, groups)
+	for _, doc := range all {
+		if len(seen) >= groups {
+			break
+		}
+		key := collapseKeyOf(doc, field)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		if len(seen) > q.From {
+			list.docs = append(list.docs, doc)
+		}
+	}
+
+	if q.Collapse.InnerHits != nil && len(list.docs) > 0 {
+		limit := q.Collapse.MaxConcurrentGroupSearches
+		if limit <= 0 {
+			limit = cfg.Shard.GoroutineNum
+		}
+		innerHits := make([]search.DocumentMatchIterator, len(list.docs))
+		eg := &errgroup.Group{}
+		eg.SetLimit(limit)
+		for i, doc := range list.docs {
+			i, key := i, collapseKeyOf(doc, field)
+			eg.Go(func() error {
+				var err error
+				innerHits[i], err = searchInnerHits(ctx, q, key, mappings, analyzers, readers...)
+				return err
+			})
+		}
+		if err := eg.Wait(); err != nil {
+			return nil, err
+		}
+		list.innerHits = make(map[*search.DocumentMatch]search.DocumentMatchIterator, len(list.docs))
+		for i, doc := range list.docs {
+			list.innerHits[doc] = innerHits[i]
+		}
+	}
+
+	list.bucket.Aggregation("duration").Finish()
+	return list, nil
+}
+
+// collapseReader returns the first document of each collapse key of the reader, up to groups keys.
+// The reader is searched again with twice the size while it has more documents than the keys found.
+func collapseReader(
+	ctx context.Context,
+	r *bluge.Reader,
+	q *meta.ZincQuery,
+	groups int,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	cfg *config.Config,
+) ([]*search.DocumentMatch, *search.Bucket, error) {
+	field := q.Collapse.Field
+	fields := []string{field}
+	for size := groups; ; size *= 2 {
+		rq := *q
+		rq.From, rq.Size, rq.Collapse = 0, size, nil
+		req, err := uquery.ParseQueryDSL(&rq, mappings, analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		dmi, err := r.Search(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		sctx := search.NewSearchContext(0, 0)
+		seen := make(map[collapseKey]struct{}, groups)
+		var docs []*search.DocumentMatch
+		var n int
+		next, err := dmi.Next()
+		for err == nil && next != nil {
+			n++
+			if len(seen) < groups {
+				if err = next.LoadDocumentValues(sctx, fields); err != nil {
+					break
+				}
+				key := collapseKeyOf(next, field)
+				if _, ok := seen[key]; !ok {
+					seen[key] = struct{}{}
+					docs = append(docs, next)
+				}
+			}
+			next, err = dmi.Next()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(seen) >= groups || n < rq.Size || rq.Size >= cfg.MaxResults {
+			return docs, dmi.Aggregations(), nil
+		}
+	}
+}
+
+// searchInnerHits searches the top documents of the group of key on all the readers
+func searchInnerHits(
+	ctx context.Context,
+	q *meta.ZincQuery,
+	key collapseKey,
+	mappings *meta.Mappings,
+	analyzers map[string]*analysis.Analyzer,
+	readers ...*bluge.Reader,
+) (search.DocumentMatchIterator, error) {
+	bq, err := query.Query(q.Query, mappings, analyzers)
+	if err != nil {
+		return nil, err
+	}
+	group := bluge.NewBooleanQuery().AddMust(bq)
+	if key.missing {
+		group.AddMustNot(bluge.NewTermQuery(q.Collapse.Field).SetField(meta.FieldNamesFieldName))
+	} else {
+		group.AddMust(bluge.NewTermQuery(key.value).SetField(q.Collapse.Field).SetBoost(0))
+	}
+
+	inner := q.Collapse.InnerHits
+	request := bluge.NewTopNSearch(inner.Size, group).SetFrom(inner.From).WithStandardAggregations()
+	if sorts, ok := inner.Sort.(search.SortOrder); ok {
+		request.SortByCustom(sorts)
+	}
+	if q.Explain {
+		request.ExplainScores()
+	}
+	return bluge.MultiSearch(ctx, request, readers...)
+}
@@ -48,6 +48,9 @@ func MultiSearch(
 			),
 		}, nil
 	}
+	if query.Collapse != nil {
+		return collapseSearch(ctx, query, mappings, analyzers, cfg, readers...)
+	}
 	if len(readers) == 1 {
 		req, err := uquery.ParseQueryDSL(query, mappings, analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
 		if err != nil {
@@ -60,6 +60,11 @@ type Config struct {
 	MaxDocumentSize           int           `env:"ZINC_MAX_DOCUMENT_SIZE,default=1m"`      // Max size for a single document . Default = 1 MB = 1024 * 1024
 	WalSyncInterval           time.Duration `env:"ZINC_WAL_SYNC_INTERVAL,default=1s"`      // sync wal to disk, 1s, 10ms
 	WalRedoLogNoSync          bool          `env:"ZINC_WAL_REDOLOG_NO_SYNC,default=false"` // control sync after every write
+	SessionSecret             string        `env:"ZINC_SESSION_SECRET"`                    // sign the login session tokens, generated and stored in metadata if empty
+	SessionTTL                time.Duration `env:"ZINC_SESSION_TTL,default=24h"`           // lifetime of the login session tokens
+	SnapshotPathRepo          string        `env:"ZINC_SNAPSHOT_PATH_REPO"`                // root of the fs snapshot repositories, none can be registered if empty
+	LifecycleInterval         time.Duration `env:"ZINC_LIFECYCLE_INTERVAL,default=10m"`    // apply the index lifecycle policies, 0 disables the scheduler
+	TaskResultTTL             time.Duration `env:"ZINC_TASK_RESULT_TTL,default=24h"`       // keep the results of the completed tasks, 0 keeps them forever
 	Cluster                   cluster
 	Shard                     shard
 	Etcd                      Etcd
@@ -85,6 +85,21 @@ func (al *AliasList) RemoveIndexesFromAlias(alias string, removeIndexes []string
 	return nil
 }
 
+// DeleteAlias removes the alias with all its indexes
+func (al *AliasList) DeleteAlias(alias string) error {
+	al.lock.Lock()
+	defer al.lock.Unlock()
+	if _, ok := al.Aliases[alias]; !ok {
+		return nil
+	}
+	delete(al.Aliases, alias)
+	if err := metadata.Alias.Set(al.Aliases); err != nil {
+		log.Err(err).Msg("failed to save alias in metadata after delete operation")
+		return err
+	}
+	return nil
+}
+
 func (al *AliasList) GetIndexesForAlias(aliasName string) ([]string, bool) {
 	al.lock.RLock()
 	idx, ok := al.Aliases[aliasName]
@@ -100,6 +115,19 @@ func (al *AliasList) GetIndexesForAlias(aliasName string) ([]string, bool) {
 	return v, ok
 }
 
+// GetIndexesForAliasPattern returns the indexes of all the aliases matched by the wildcard pattern
+func (al *AliasList) GetIndexesForAliasPattern(pattern string) []string {
+	al.lock.RLock()
+	defer al.lock.RUnlock()
+	var indexes []string
+	for alias, names := range al.Aliases {
+		if isMatchIndex(alias, pattern) {
+			indexes = append(indexes, names...)
+		}
+	}
+	return indexes
+}
+
 func (al *AliasList) GetAliasesForIndex(indexName string) []string {
 	al.lock.RLock()
 	var aliases []string
@@ -0,0 +1,220 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+// the results of a batchAction counted in the task status
+const (
+	batchResultCreated = "created"
+	batchResultUpdated = "updated"
+	batchResultDeleted = "deleted"
+	batchResultNoop    = "noop"
+)
+
+// batchAction processes a hit of the query, it returns the result counted in the status,
+// the error is reported as a failure of the document in the returned index.
+type batchAction func(hit meta.Hit) (result, index string, err error)
+
+// batchTask walks the documents matched by the query with a scroll for reindex, update_by_query
+// and delete_by_query, each hit is processed by the action of the task.
+type batchTask struct {
+	indexes           []string
+	query             *meta.ZincQuery
+	maxDocs           int64  // zero means all the matches
+	conflicts         string // abort or proceed on a version conflict
+	requestsPerSecond float64
+	slices            int // the hits of a batch are split by the document id and processed in parallel
+}
+
+// run processes the documents batch by batch, the progress is reported to the task,
+// it stops before the next document when the task is cancelled.
+func (b *batchTask) run(task *Task, cfg *config.Config, action batchAction) (*meta.ReindexResponse, error) {
+	start := time.Now()
+	resp := &meta.ReindexResponse{Failures: []meta.ReindexFailure{}}
+	resp.RequestsPerSecond = -1
+	if b.requestsPerSecond > 0 {
+		resp.RequestsPerSecond = b.requestsPerSecond
+	}
+	task.SetStatus(resp.TaskStatus)
+
+	s, err := newScroll(b.indexes, b.query, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	aborted := false
+	for !aborted {
+		batchStart := time.Now()
+		page, err := s.nextPage(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Batches == 0 {
+			resp.Total = int64(page.Hits.Total.Value)
+			if b.maxDocs > 0 && resp.Total > b.maxDocs {
+				resp.Total = b.maxDocs
+			}
+		}
+		hits := page.Hits.Hits
+		if b.maxDocs > 0 {
+			remaining := b.maxDocs - batchProcessed(&resp.TaskStatus)
+			if remaining < int64(len(hits)) {
+				hits = hits[:remaining]
+			}
+		}
+		if len(hits) == 0 {
+			break
+		}
+		resp.Batches++
+		if !b.runBatch(task, hits, resp, action) {
+			aborted = true
+		}
+		if task.Context().Err() != nil {
+			resp.Canceled = reindexCanceledMessage
+			aborted = true
+		}
+		task.SetStatus(resp.TaskStatus)
+		if !aborted && !throttleTask(task, b.requestsPerSecond, len(hits), time.Since(batchStart), &resp.TaskStatus) {
+			resp.Canceled = reindexCanceledMessage
+			aborted = true
+		}
+	}
+
+	task.SetStatus(resp.TaskStatus)
+	resp.Took = time.Since(start).Milliseconds()
+	return resp, nil
+}
+
+// runBatch splits the hits into the slices by the document id and runs the action on the slices in parallel,
+// it returns false if the task should abort.
+func (b *batchTask) runBatch(task *Task, hits []meta.Hit, resp *meta.ReindexResponse, action batchAction) bool {
+	slices := make([][]meta.Hit, 1)
+	if b.slices > 1 {
+		slices = make([][]meta.Hit, b.slices)
+	}
+	for _, hit := range hits {
+		i := 0
+		if len(slices) > 1 {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(hit.Index + "/" + hit.ID))
+			i = int(h.Sum32() % uint32(len(slices)))
+		}
+		slices[i] = append(slices[i], hit)
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	aborted := false
+	for _, slice := range slices {
+		if len(slice) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(slice []meta.Hit) {
+			defer wg.Done()
+			for _, hit := range slice {
+				lock.Lock()
+				stop := aborted
+				lock.Unlock()
+				if stop || task.Context().Err() != nil {
+					return
+				}
+
+				result, index, err := action(hit)
+
+				lock.Lock()
+				var e *errors.Error
+				switch {
+				case err == nil:
+					countBatchResult(&resp.TaskStatus, result)
+				case errors.As(err, &e) && e.Type == errors.ErrorTypeVersionConflictEngineException:
+					resp.VersionConflicts++
+					if b.conflicts != meta.ReindexConflictsProceed {
+						resp.Failures = append(resp.Failures, newReindexFailure(index, hit.ID, err))
+						aborted = true
+					}
+				default:
+					resp.Failed++
+					resp.Failures = append(resp.Failures, newReindexFailure(index, hit.ID, err))
+				}
+				lock.Unlock()
+			}
+		}(slice)
+	}
+	wg.Wait()
+	return !aborted
+}
+
+// countBatchResult counts the result of an action in the status
+func countBatchResult(status *meta.TaskStatus, result string) {
+	switch result {
+	case batchResultCreated:
+		status.Created++
+	case batchResultUpdated:
+		status.Updated++
+	case batchResultDeleted:
+		status.Deleted++
+	case batchResultNoop:
+		status.Noops++
+	}
+}
+
+// batchProcessed returns the number of the documents counted for max_docs
+func batchProcessed(status *meta.TaskStatus) int64 {
+	return status.Created + status.Updated + status.Deleted + status.Failed + status.Noops + status.VersionConflicts
+}
+
+// newReindexFailure describes the failure of a document
+func newReindexFailure(index, id string, err error) meta.ReindexFailure {
+	failure := meta.ReindexFailure{Index: index, ID: id, Cause: meta.TaskError{Type: errors.ErrorTypeRuntimeException, Reason: err.Error()}}
+	var e *errors.Error
+	if errors.As(err, &e) {
+		failure.Cause = meta.TaskError{Type: e.Type, Reason: e.Reason}
+	}
+	return failure
+}
+
+// throttleTask waits until the batch meets requestsPerSecond, it returns false if the task is cancelled while waiting
+func throttleTask(task *Task, requestsPerSecond float64, docs int, took time.Duration, status *meta.TaskStatus) bool {
+	if requestsPerSecond <= 0 {
+		return true
+	}
+	wait := time.Duration(float64(docs)/requestsPerSecond*float64(time.Second)) - took
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	start := time.Now()
+	select {
+	case <-timer.C:
+		status.ThrottledMillis += wait.Milliseconds()
+		return true
+	case <-task.Context().Done():
+		status.ThrottledMillis += time.Since(start).Milliseconds()
+		return false
+	}
+}
@@ -0,0 +1,77 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/blugelabs/bluge"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery/query"
+	"github.com/zinclabs/zincsearch/pkg/uquery/timerange"
+)
+
+// Count returns the number of documents matched by the query, it doesn't score nor load any hit
+func Count(indexNames []string, q *meta.ZincQuery, cfg *config.Config) (*meta.CountResponse, error) {
+	timeMin, timeMax := timerange.Query(q.Query)
+	r, err := getReadersForIndexes(indexNames, timeMin, timeMax, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	resp := &meta.CountResponse{
+		Shards: meta.Shards{Total: r.shardNum, Successful: int64(len(r.readers)), Skipped: r.shardNum - int64(len(r.readers))},
+	}
+	if len(r.indexes) > 1 {
+		resp.Shards.Indices = r.shards
+	}
+	if len(r.readers) == 0 {
+		return resp, nil
+	}
+
+	// the queries keep state while searching, every reader builds its own like MultiSearch does
+	if _, err := query.Query(q.Query, r.mappings, r.analyzers); err != nil {
+		return nil, err
+	}
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(cfg.Shard.GoroutineNum)
+	for _, reader := range r.readers {
+		reader := reader
+		eg.Go(func() error {
+			bq, err := query.Query(q.Query, r.mappings, r.analyzers)
+			if err != nil {
+				return err
+			}
+			request := bluge.NewTopNSearch(0, bq).WithStandardAggregations().SetScore("none")
+			dmi, err := reader.Search(ctx, request)
+			if err != nil {
+				return err
+			}
+			atomic.AddInt64(&resp.Count, int64(dmi.Aggregations().Count()))
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
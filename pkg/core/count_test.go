@@ -0,0 +1,98 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestCount(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	indexNames := []string{"count.index_1", "count.index_2"}
+	t.Run("Prepare", func(t *testing.T) {
+		for n, indexName := range indexNames {
+			index, err := NewIndex(indexName, "disk", 2, cfg)
+			assert.NoError(t, err)
+			assert.NoError(t, StoreIndex(index))
+			for i := 0; i < 5+n; i++ {
+				err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+					"num":  float64(i),
+					"name": "name " + strconv.Itoa(i%2),
+				}, false, cfg.EnableTextKeywordMapping)
+				assert.NoError(t, err)
+			}
+
+			// wait for WAL write to index
+			waitForRefresh(t, index)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		indexes []string
+		query   interface{}
+		want    int64
+		wantErr bool
+	}{
+		{
+			name:    "match all",
+			indexes: []string{"count.index_1"},
+			want:    5,
+		},
+		{
+			name:    "match",
+			indexes: []string{"count.index_1"},
+			query:   map[string]interface{}{"match": map[string]interface{}{"name": "1"}},
+			want:    2,
+		},
+		{
+			name:    "several indexes",
+			indexes: []string{"count.index_*"},
+			query:   map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"gte": 3}}},
+			want:    5,
+		},
+		{
+			name:    "invalid query",
+			indexes: []string{"count.index_1"},
+			query:   map[string]interface{}{"unknown": map[string]interface{}{}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Count(tt.indexes, &meta.ZincQuery{Query: tt.query}, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got.Count)
+			assert.Equal(t, got.Shards.Total, got.Shards.Successful+got.Shards.Skipped)
+		})
+	}
+
+	t.Run("Cleanup", func(t *testing.T) {
+		for _, indexName := range indexNames {
+			assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+		}
+	})
+}
@@ -0,0 +1,278 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+)
+
+var dataStreamLock sync.Mutex
+
+var dataStreamIndexRe = regexp.MustCompile(`^\.ds-(.+)-\d{4}\.\d{2}\.\d{2}-\d{6}$`)
+
+func dataStreamIndexName(name string, t time.Time, generation int64) string {
+	return fmt.Sprintf(".ds-%s-%s-%06d", name, t.UTC().Format("2006.01.02"), generation)
+}
+
+// ListDataStreams returns the data streams matching the comma separated names or wildcard patterns, all if empty
+func ListDataStreams(target string) ([]*meta.DataStream, error) {
+	streams, err := metadata.DataStream.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if target == "" || target == "_all" {
+		target = "*"
+	}
+	matched := make([]*meta.DataStream, 0, len(streams))
+	for _, name := range strings.Split(target, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, ds := range streams {
+			if isMatchIndex(ds.Name, name) {
+				matched = append(matched, ds)
+				found = true
+			}
+		}
+		if !found && !strings.Contains(name, "*") {
+			return nil, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("data stream [%s] is missing", name))
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].Name < matched[j].Name
+	})
+	return matched, nil
+}
+
+// GetDataStream returns the data stream, a resource_not_found_exception if it doesn't exist
+func GetDataStream(name string) (*meta.DataStream, error) {
+	ds, err := metadata.DataStream.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("data stream [%s] is missing", name))
+		}
+		return nil, err
+	}
+	return ds, nil
+}
+
+// CreateDataStream creates the data stream and its first backing index,
+// the name has to match an index template with data_stream enabled.
+func CreateDataStream(name string, now time.Time) (*meta.DataStream, error) {
+	dataStreamLock.Lock()
+	defer dataStreamLock.Unlock()
+	return createDataStream(name, now)
+}
+
+func createDataStream(name string, now time.Time) (*meta.DataStream, error) {
+	if err := checkResourceName("data stream", name); err != nil {
+		return nil, err
+	}
+	if _, err := metadata.DataStream.Get(name); err == nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("data stream [%s] already exists", name))
+	}
+	if _, ok := GetIndex(name); ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("an index with the name [%s] already exists", name))
+	}
+	if _, ok := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name); ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("an alias with the name [%s] already exists", name))
+	}
+	tpl, err := useTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	if tpl == nil || tpl.IndexTemplate.DataStream == nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no matching index template with data_stream found for data stream [%s]", name))
+	}
+
+	ds := &meta.DataStream{
+		Name:           name,
+		TimestampField: meta.DataStreamTimestampField{Name: meta.TimeFieldName},
+		Generation:     1,
+		Status:         meta.DataStreamStatusGreen,
+		Template:       tpl.Name,
+		CreatedAt:      now,
+	}
+	index, err := newDataStreamIndex(ds, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(name, []string{index.GetName()}); err != nil {
+		return nil, err
+	}
+	ds.Indices = []meta.DataStreamIndex{{IndexName: index.GetName()}}
+	if err := metadata.DataStream.Set(name, *ds); err != nil {
+		return nil, err
+	}
+	return ds, nil
+}
+
+// newDataStreamIndex creates the backing index of the current generation, it is the write index of the data stream
+func newDataStreamIndex(ds *meta.DataStream, now time.Time) (*Index, error) {
+	name := dataStreamIndexName(ds.Name, now, ds.Generation)
+	if _, exists := GetIndex(name); exists {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("backing index [%s] already exists", name))
+	}
+	index, _, err := ZINC_INDEX_LIST.GetOrCreate(name, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	index.setDataStream(ds.Name)
+	_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{
+		RolloverAlias:   ds.Name,
+		OriginationDate: now.UnixMilli(),
+	}})
+	if err := StoreIndex(index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// autoCreateDataStream creates the data stream on the first write to a name matching a data stream template,
+// it returns false if the name isn't a data stream.
+func autoCreateDataStream(name string) (*Index, bool, error) {
+	if _, ok := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name); ok {
+		return nil, false, nil
+	}
+	tpl, err := useTemplate(name)
+	if err != nil || tpl == nil || tpl.IndexTemplate.DataStream == nil {
+		return nil, false, err
+	}
+
+	dataStreamLock.Lock()
+	defer dataStreamLock.Unlock()
+	// maybe someone else created it while we were waiting for the lock
+	if index, ok := GetWriteIndex(name); ok {
+		return index, true, nil
+	}
+	if _, err := createDataStream(name, time.Now()); err != nil {
+		return nil, true, err
+	}
+	index, _ := GetWriteIndex(name)
+	return index, true, nil
+}
+
+// rolloverDataStream adds the next generation of the backing indexes to the data stream
+func rolloverDataStream(ds *meta.DataStream, now time.Time) (*Index, error) {
+	dataStreamLock.Lock()
+	defer dataStreamLock.Unlock()
+	ds.Generation++
+	index, err := newDataStreamIndex(ds, now)
+	if err != nil {
+		return nil, err
+	}
+	ds.Indices = append(ds.Indices, meta.DataStreamIndex{IndexName: index.GetName()})
+	if err := metadata.DataStream.Set(ds.Name, *ds); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// DeleteDataStream deletes the data stream with all its backing indexes
+func DeleteDataStream(name, dataPath string) error {
+	dataStreamLock.Lock()
+	defer dataStreamLock.Unlock()
+	ds, err := GetDataStream(name)
+	if err != nil {
+		return err
+	}
+	indexes := make([]string, 0, len(ds.Indices))
+	for _, idx := range ds.Indices {
+		indexes = append(indexes, idx.IndexName)
+	}
+	if err := ZINC_INDEX_ALIAS_LIST.DeleteAlias(name); err != nil {
+		return err
+	}
+	for _, idx := range indexes {
+		if _, ok := GetIndex(idx); !ok {
+			continue
+		}
+		if err := deleteIndex(idx, dataPath); err != nil {
+			return err
+		}
+	}
+	return metadata.DataStream.Delete(name)
+}
+
+// removeDataStreamIndex removes a deleted backing index from its data stream, the write index can't be removed
+func removeDataStreamIndex(index *Index) error {
+	name := index.GetDataStream()
+	if name == "" {
+		return nil
+	}
+	dataStreamLock.Lock()
+	defer dataStreamLock.Unlock()
+	ds, err := metadata.DataStream.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	for i, idx := range ds.Indices {
+		if idx.IndexName != index.GetName() {
+			continue
+		}
+		if i == len(ds.Indices)-1 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("index [%s] is the write index for data stream [%s] and cannot be deleted", index.GetName(), name))
+		}
+		ds.Indices = append(ds.Indices[:i], ds.Indices[i+1:]...)
+		if err := ZINC_INDEX_ALIAS_LIST.RemoveIndexesFromAlias(name, []string{index.GetName()}); err != nil {
+			return err
+		}
+		return metadata.DataStream.Set(name, *ds)
+	}
+	return nil
+}
+
+// DataStreamStats returns the storage stats of the data streams matching the comma separated names or patterns
+func DataStreamStats(target string) (*meta.DataStreamStatsResponse, error) {
+	streams, err := ListDataStreams(target)
+	if err != nil {
+		return nil, err
+	}
+	resp := &meta.DataStreamStatsResponse{DataStreams: make([]meta.DataStreamStats, 0, len(streams))}
+	for _, ds := range streams {
+		stats := meta.DataStreamStats{DataStream: ds.Name}
+		for _, idx := range ds.Indices {
+			index, ok := GetIndex(idx.IndexName)
+			if !ok {
+				continue
+			}
+			stats.BackingIndices++
+			stats.StoreSizeBytes += index.GetStats().StorageSize
+			if t := index.GetDocTimeMax() / int64(time.Millisecond); t > stats.MaximumTimestamp {
+				stats.MaximumTimestamp = t
+			}
+			resp.Shards.Total += index.GetShardNum()
+		}
+		resp.DataStreamCount++
+		resp.BackingIndices += stats.BackingIndices
+		resp.TotalStoreSizeBytes += stats.StoreSizeBytes
+		resp.DataStreams = append(resp.DataStreams, stats)
+	}
+	resp.Shards.Successful = resp.Shards.Total
+	return resp, nil
+}
@@ -0,0 +1,154 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestDataStream(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	now := time.Now()
+	name := "datastream.logs"
+	first := dataStreamIndexName(name, now, 1)
+	second := dataStreamIndexName(name, now, 2)
+
+	createDocument := func(t *testing.T, index *Index, id string, doc map[string]interface{}) error {
+		err := index.CreateDocument(id, doc, true, cfg.EnableTextKeywordMapping)
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+		return err
+	}
+	errorType := func(err error) string {
+		var e *errors.Error
+		if errors.As(err, &e) {
+			return e.Type
+		}
+		return ""
+	}
+
+	err := NewTemplate("datastream_tpl", &meta.IndexTemplate{
+		IndexPatterns: []string{"datastream.*"},
+		Priority:      513,
+		DataStream:    &meta.DataStreamTemplate{},
+	})
+	assert.NoError(t, err)
+
+	t.Run("create", func(t *testing.T) {
+		_, err := CreateDataStream("logs_without_template", now)
+		assert.Error(t, err)
+
+		index, exists, err := GetOrCreateIndex(name, "", 0)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		assert.Equal(t, first, index.GetName())
+		assert.Equal(t, name, index.GetDataStream())
+
+		_, err = CreateDataStream(name, now)
+		assert.Error(t, err)
+
+		ds, err := GetDataStream(name)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), ds.Generation)
+		assert.Equal(t, []meta.DataStreamIndex{{IndexName: first}}, ds.Indices)
+		assert.Equal(t, "datastream_tpl", ds.Template)
+	})
+
+	t.Run("append only", func(t *testing.T) {
+		index, exists, err := GetOrCreateIndex(name, "", 0)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		err = createDocument(t, index, "1", map[string]interface{}{"message": "missing timestamp"})
+		assert.Equal(t, errors.ErrorTypeIllegalArgumentException, errorType(err))
+		err = createDocument(t, index, "1", map[string]interface{}{"message": "first", meta.TimeFieldName: now.Format(time.RFC3339)})
+		assert.NoError(t, err)
+		err = createDocument(t, index, "1", map[string]interface{}{"message": "again", meta.TimeFieldName: now.Format(time.RFC3339)})
+		assert.Equal(t, errors.ErrorTypeVersionConflictEngineException, errorType(err))
+		err = index.UpdateDocument("1", map[string]interface{}{"message": "updated"}, false, 1, cfg.EnableTextKeywordMapping)
+		assert.Error(t, err)
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		resp, err := Rollover(name, nil, now)
+		assert.NoError(t, err)
+		assert.True(t, resp.RolledOver)
+		assert.Equal(t, first, resp.OldIndex)
+		assert.Equal(t, second, resp.NewIndex)
+
+		index, exists, err := GetOrCreateIndex(name, "", 0)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, second, index.GetName())
+		err = createDocument(t, index, "2", map[string]interface{}{"message": "second", meta.TimeFieldName: now.Format(time.RFC3339)})
+		assert.NoError(t, err)
+
+		streams, err := ListDataStreams("datastream.*")
+		assert.NoError(t, err)
+		assert.Len(t, streams, 1)
+		assert.Equal(t, int64(2), streams[0].Generation)
+		assert.Equal(t, []meta.DataStreamIndex{{IndexName: first}, {IndexName: second}}, streams[0].Indices)
+	})
+
+	t.Run("read and stats", func(t *testing.T) {
+		// reads of the data stream fan out to the backing indexes through its alias
+		names, ok := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name)
+		assert.True(t, ok)
+		resp, err := MultiSearch(names, &meta.ZincQuery{Size: 10}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, resp.Hits.Total.Value)
+
+		stats, err := DataStreamStats(name)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.DataStreamCount)
+		assert.Equal(t, 2, stats.BackingIndices)
+		assert.Equal(t, now.Unix(), stats.DataStreams[0].MaximumTimestamp/1000)
+
+		_, err = DataStreamStats("datastream.missing")
+		assert.Equal(t, errors.ErrorTypeResourceNotFoundException, errorType(err))
+	})
+
+	t.Run("delete backing index", func(t *testing.T) {
+		assert.Error(t, DeleteIndex(second, cfg.DataPath))
+		assert.NoError(t, DeleteIndex(first, cfg.DataPath))
+
+		ds, err := GetDataStream(name)
+		assert.NoError(t, err)
+		assert.Equal(t, []meta.DataStreamIndex{{IndexName: second}}, ds.Indices)
+		names, _ := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name)
+		assert.Equal(t, []string{second}, names)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.NoError(t, DeleteDataStream(name, cfg.DataPath))
+		_, exists := GetIndex(second)
+		assert.False(t, exists)
+		_, exists = ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name)
+		assert.False(t, exists)
+		_, err := GetDataStream(name)
+		assert.Equal(t, errors.ErrorTypeResourceNotFoundException, errorType(err))
+		assert.Error(t, DeleteDataStream(name, cfg.DataPath))
+	})
+
+	assert.NoError(t, DeleteTemplate("datastream_tpl"))
+}
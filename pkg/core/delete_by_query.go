@@ -0,0 +1,137 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+// DeleteByQueryMaxSlices limits the parallel deletions of slices=auto
+const DeleteByQueryMaxSlices = 8
+
+// DeleteByQuery deletes all the documents matched by the query, the matches are walked with a scroll,
+// so the deleted documents don't move the cursor.
+type DeleteByQuery struct {
+	req               *meta.DeleteByQueryRequest
+	indexes           []string
+	requestsPerSecond float64
+	slices            int
+}
+
+// NewDeleteByQuery checks the request, target is an index, alias, wildcard pattern or a comma list of them,
+// requestsPerSecond throttles the deletions, zero or negative means unlimited. Each batch is deleted by
+// slices workers in parallel, zero means auto: a worker per shard.
+func NewDeleteByQuery(target string, req *meta.DeleteByQueryRequest, requestsPerSecond float64, slices int) (*DeleteByQuery, error) {
+	indexes, err := resolveIndexes(target, req.Indices)
+	if err != nil {
+		return nil, err
+	}
+	d := &DeleteByQuery{req: req, requestsPerSecond: requestsPerSecond, slices: slices}
+	shards := 0
+	for _, index := range indexes {
+		d.indexes = append(d.indexes, index.GetName())
+		shards += int(index.GetShardNum())
+	}
+
+	switch req.Conflicts {
+	case "":
+		req.Conflicts = meta.ReindexConflictsAbort
+	case meta.ReindexConflictsAbort, meta.ReindexConflictsProceed:
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[delete_by_query] conflicts [%s] is invalid, it should be abort or proceed", req.Conflicts))
+	}
+	if req.ScrollSize < 0 || req.MaxDocs < 0 || req.Size < 0 || slices < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[delete_by_query] scroll_size, max_docs and slices should be positive")
+	}
+	if req.MaxDocs == 0 {
+		req.MaxDocs = req.Size
+	}
+	if req.ScrollSize == 0 {
+		req.ScrollSize = ReindexDefaultSize
+	}
+	if d.slices == 0 {
+		d.slices = shards
+		if d.slices > DeleteByQueryMaxSlices {
+			d.slices = DeleteByQueryMaxSlices
+		}
+		if d.slices == 0 {
+			d.slices = 1
+		}
+	}
+	return d, nil
+}
+
+// Description describes the deletion for the task
+func (d *DeleteByQuery) Description() string {
+	return fmt.Sprintf("delete-by-query [%s]", strings.Join(d.indexes, ","))
+}
+
+// Run deletes the documents batch by batch, the progress is reported to the task,
+// it stops before the next document when the task is cancelled.
+func (d *DeleteByQuery) Run(task *Task, cfg *config.Config) (*meta.HTTPResponseDeleteByQuery, error) {
+	size := d.req.ScrollSize
+	if size > cfg.MaxResults {
+		size = cfg.MaxResults
+	}
+	b := &batchTask{
+		indexes: d.indexes,
+		query: &meta.ZincQuery{
+			Query:            d.req.Query,
+			Size:             size,
+			TrackTotalHits:   true,
+			SeqNoPrimaryTerm: true,
+		},
+		maxDocs:           d.req.MaxDocs,
+		conflicts:         d.req.Conflicts,
+		requestsPerSecond: d.requestsPerSecond,
+		slices:            d.slices,
+	}
+	resp, err := b.run(task, cfg, func(hit meta.Hit) (string, string, error) {
+		return d.deleteDocument(hit, cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &meta.HTTPResponseDeleteByQuery{
+		Took:              resp.Took,
+		Total:             resp.Total,
+		Deleted:           resp.Deleted,
+		Batches:           resp.Batches,
+		VersionConflicts:  resp.VersionConflicts,
+		Noops:             resp.Noops,
+		Failures:          resp.Failures,
+		ThrottledMillis:   resp.ThrottledMillis,
+		RequestsPerSecond: resp.RequestsPerSecond,
+		Canceled:          resp.Canceled,
+	}, nil
+}
+
+// deleteDocument deletes the hit if it isn't changed since the query ran, it returns the result and the index name
+func (d *DeleteByQuery) deleteDocument(hit meta.Hit, cfg *config.Config) (string, string, error) {
+	index, ok := GetIndex(hit.Index)
+	if !ok {
+		return "", hit.Index, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("no such index [%s]", hit.Index))
+	}
+	if _, err := index.DeleteDocumentVersioned(hit.ID, hitVersionCondition(hit), cfg.Shard.GoroutineNum); err != nil {
+		return "", index.GetName(), err
+	}
+	return batchResultDeleted, index.GetName(), nil
+}
@@ -0,0 +1,121 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestDeleteByQuery(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	indexName := "delete_by_query.index"
+
+	start := func(t *testing.T, req *meta.DeleteByQueryRequest, requestsPerSecond float64, slices int) *Task {
+		d, err := NewDeleteByQuery(indexName, req, requestsPerSecond, slices)
+		assert.NoError(t, err)
+		return ZINC_TASK_LIST.Start(DeleteByQueryAction, d.Description(), func(task *Task) (interface{}, error) {
+			return d.Run(task, cfg)
+		})
+	}
+	result := func(t *testing.T, task *Task) *meta.HTTPResponseDeleteByQuery {
+		resp, err := task.Result()
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		waitForRefresh(t, ZINC_INDEX_LIST.List()...)
+		return resp.(*meta.HTTPResponseDeleteByQuery)
+	}
+	count := func(t *testing.T) int {
+		return len(searchAll(t, indexName, cfg))
+	}
+	between := func(gte, lte int) map[string]interface{} {
+		return map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"gte": gte, "lte": lte}}}
+	}
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		for i := 1; i <= 30; i++ {
+			doc := map[string]interface{}{"num": float64(i)}
+			assert.NoError(t, index.CreateDocument(fmt.Sprintf("%02d", i), doc, false, cfg.EnableTextKeywordMapping))
+		}
+		waitForRefresh(t, index)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []*meta.DeleteByQueryRequest{
+			{Conflicts: "ignore"},
+			{MaxDocs: -1},
+			{ScrollSize: -1},
+		}
+		for _, req := range invalid {
+			_, err := NewDeleteByQuery(indexName, req, -1, 1)
+			assert.Error(t, err)
+		}
+		_, err := NewDeleteByQuery("delete_by_query.missing", &meta.DeleteByQueryRequest{}, -1, 1)
+		assert.Error(t, err)
+	})
+
+	t.Run("max_docs", func(t *testing.T) {
+		resp := result(t, start(t, &meta.DeleteByQueryRequest{Query: between(1, 10), MaxDocs: 5, ScrollSize: 2}, -1, 1))
+		assert.Equal(t, int64(5), resp.Total)
+		assert.Equal(t, int64(5), resp.Deleted)
+		assert.Equal(t, int64(3), resp.Batches)
+		assert.Empty(t, resp.Failures)
+		assert.Equal(t, 25, count(t))
+	})
+
+	t.Run("all matches with slices", func(t *testing.T) {
+		resp := result(t, start(t, &meta.DeleteByQueryRequest{Query: between(1, 20), ScrollSize: 4}, -1, 3))
+		assert.Equal(t, int64(15), resp.Total)
+		assert.Equal(t, int64(15), resp.Deleted)
+		assert.Equal(t, int64(4), resp.Batches)
+		assert.Equal(t, 10, count(t))
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		// the deletion is throttled to a document per second, the last match is deleted after the first batch
+		task := start(t, &meta.DeleteByQueryRequest{Query: between(21, 23), ScrollSize: 1, Conflicts: meta.ReindexConflictsProceed}, 1, 1)
+		assert.Eventually(t, func() bool {
+			status := task.Info().Status
+			return status != nil && status.Batches > 0
+		}, 10*time.Second, 10*time.Millisecond)
+		index, _ := GetIndex(indexName)
+		assert.NoError(t, index.DeleteDocument("23", cfg.Shard.GoroutineNum))
+		waitForRefresh(t, index)
+		resp := result(t, task)
+		assert.Equal(t, int64(3), resp.Total)
+		assert.Equal(t, int64(2), resp.Deleted)
+		assert.Equal(t, int64(1), resp.VersionConflicts)
+		assert.Empty(t, resp.Failures)
+		assert.Greater(t, resp.ThrottledMillis, int64(0))
+
+		resp = result(t, start(t, &meta.DeleteByQueryRequest{Query: between(24, 30), Conflicts: meta.ReindexConflictsAbort}, -1, 1))
+		assert.Equal(t, int64(7), resp.Deleted)
+		assert.Equal(t, 0, count(t))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+	})
+}
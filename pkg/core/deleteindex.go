@@ -30,6 +30,17 @@ func DeleteIndex(name, dataPath string) error {
 	if !exists {
 		return errors.New("index " + name + " does not exists")
 	}
+	if err := removeDataStreamIndex(index); err != nil {
+		return err
+	}
+	return deleteIndex(name, dataPath)
+}
+
+func deleteIndex(name, dataPath string) error {
+	index, exists := GetIndex(name)
+	if !exists {
+		return errors.New("index " + name + " does not exists")
+	}
 
 	// 2. Close and Delete from cache
 	ZINC_INDEX_LIST.Delete(name)
@@ -0,0 +1,152 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/search"
+
+	zincquery "github.com/zinclabs/zincsearch/pkg/bluge/query"
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery"
+	"github.com/zinclabs/zincsearch/pkg/uquery/query"
+)
+
+// ValidateQuery checks the query against the mappings of every index,
+// with explain the rewritten bluge query of each index is returned.
+func ValidateQuery(indexNames []string, q *meta.ZincQuery, explain bool, cfg *config.Config) (*meta.ValidateQueryResponse, error) {
+	names, err := ResolveIndexNames(indexNames, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &meta.ValidateQueryResponse{Valid: true}
+	for _, name := range names {
+		index, ok := GetIndex(name)
+		if !ok {
+			continue
+		}
+		resp.Shards.Total++
+		resp.Shards.Successful++
+
+		item := meta.ValidateQueryExplanation{Index: name, Valid: true}
+		mappings := index.GetMappings()
+		analyzers := index.GetAnalyzers()
+		// ParseQueryDSL changes the query, so every index gets its own copy
+		var bq bluge.Query
+		_, err := uquery.ParseQueryDSL(&meta.ZincQuery{Query: q.Query}, mappings, analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
+		if err == nil {
+			bq, err = query.Query(q.Query, mappings, analyzers)
+		}
+		if err == nil {
+			item.Explanation = zincquery.String(bq)
+		} else {
+			resp.Valid = false
+			item.Valid = false
+			item.Error = fmt.Sprintf("[%s] %s", name, errorReason(err))
+			if resp.Error == "" {
+				resp.Error = item.Error
+			}
+		}
+		resp.Explanations = append(resp.Explanations, item)
+	}
+
+	if !explain {
+		resp.Explanations = nil
+		resp.Error = ""
+	}
+	return resp, nil
+}
+
+// Explain computes how the query scores the document id of the index target
+func Explain(target, id string, q *meta.ZincQuery, cfg *config.Config) (*meta.ExplainResponse, error) {
+	names, err := ResolveIndexNames([]string{target}, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(names) != 1 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[%s] must resolve to a single index, but resolved to %d indexes", target, len(names)))
+	}
+	index, ok := GetIndex(names[0])
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeIndexNotFoundException, fmt.Sprintf("no such index [%s]", names[0]))
+	}
+	if _, err := index.GetDocument(id, cfg.Shard.GoroutineNum); err != nil {
+		return nil, err
+	}
+
+	bq, err := query.Query(q.Query, index.GetMappings(), index.GetAnalyzers())
+	if err != nil {
+		return nil, err
+	}
+
+	readers, err := index.GetReaders(0, 0, cfg.Shard.GoroutineNum)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+
+	resp := &meta.ExplainResponse{Index: index.GetName(), ID: id}
+	for _, reader := range readers {
+		request := bluge.NewTopNSearch(1, zincquery.NewDocumentQuery(bq, id)).ExplainScores()
+		dmi, err := reader.Search(context.Background(), request)
+		if err != nil {
+			return nil, err
+		}
+		next, err := dmi.Next()
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			resp.Matched = true
+			resp.Explanation = newExplanation(next.Explanation)
+			break
+		}
+	}
+	return resp, nil
+}
+
+// newExplanation converts the bluge explanation tree
+func newExplanation(e *search.Explanation) *meta.Explanation {
+	if e == nil {
+		return nil
+	}
+	rv := &meta.Explanation{Value: e.Value, Description: e.Message, Details: make([]*meta.Explanation, 0, len(e.Children))}
+	for _, child := range e.Children {
+		if v := newExplanation(child); v != nil {
+			rv.Details = append(rv.Details, v)
+		}
+	}
+	return rv
+}
+
+func errorReason(err error) string {
+	var e *errors.Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return err.Error()
+}
@@ -0,0 +1,166 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestExplain(t *testing.T) {
+	var index *Index
+	indexName := "explain.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		for i := 0; i < 5; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"name": "zinc search " + strconv.Itoa(i),
+			}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	matchZinc := map[string]interface{}{"match": map[string]interface{}{"name": "zinc"}}
+
+	t.Run("matched document", func(t *testing.T) {
+		got, err := Explain(indexName, "3", &meta.ZincQuery{Query: matchZinc}, cfg)
+		assert.NoError(t, err)
+		assert.True(t, got.Matched)
+		assert.Equal(t, "3", got.ID)
+		assert.NotNil(t, got.Explanation)
+		assert.Greater(t, got.Explanation.Value, 0.0)
+		assert.NotEmpty(t, got.Explanation.Description)
+	})
+
+	t.Run("unmatched document", func(t *testing.T) {
+		query := map[string]interface{}{"match": map[string]interface{}{"name": "1"}}
+		got, err := Explain(indexName, "3", &meta.ZincQuery{Query: query}, cfg)
+		assert.NoError(t, err)
+		assert.False(t, got.Matched)
+		assert.Nil(t, got.Explanation)
+	})
+
+	t.Run("missing document", func(t *testing.T) {
+		_, err := Explain(indexName, "10", &meta.ZincQuery{Query: matchZinc}, cfg)
+		assert.ErrorIs(t, err, errors.ErrorIDNotFound)
+	})
+
+	t.Run("several indexes", func(t *testing.T) {
+		_, err := Explain("explain.*,"+indexName+"_missing", "3", &meta.ZincQuery{Query: matchZinc}, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("hits explanation", func(t *testing.T) {
+		got, err := index.Search(&meta.ZincQuery{Query: matchZinc, Explain: true, Size: 10}, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, got.Hits.Hits, 5)
+		for _, hit := range got.Hits.Hits {
+			assert.NotNil(t, hit.Explanation)
+			assert.InDelta(t, hit.Score, hit.Explanation.Value, 1e-9)
+		}
+
+		got, err = index.Search(&meta.ZincQuery{Query: matchZinc, Size: 10}, cfg)
+		assert.NoError(t, err)
+		assert.Nil(t, got.Hits.Hits[0].Explanation)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+	})
+}
+
+func TestValidateQuery(t *testing.T) {
+	indexName := "validate.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err := NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		index.GetMappings().SetProperty("name", meta.NewProperty("text"))
+		index.GetMappings().SetProperty("num", meta.NewProperty("numeric"))
+	})
+
+	tests := []struct {
+		name        string
+		query       interface{}
+		explain     bool
+		valid       bool
+		explanation string
+	}{
+		{
+			name:        "match all",
+			explain:     true,
+			valid:       true,
+			explanation: "*:*",
+		},
+		{
+			name: "bool",
+			query: map[string]interface{}{"bool": map[string]interface{}{
+				"must":     []interface{}{map[string]interface{}{"match": map[string]interface{}{"name": "Zinc Search"}}},
+				"must_not": []interface{}{map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"gte": 1, "lt": 5}}}},
+			}},
+			explain:     true,
+			valid:       true,
+			explanation: "+(name:zinc name:search) -num:[1 TO 5}",
+		},
+		{
+			name:    "invalid query",
+			query:   map[string]interface{}{"unknown": map[string]interface{}{}},
+			explain: true,
+		},
+		{
+			name:  "invalid query without explain",
+			query: map[string]interface{}{"unknown": map[string]interface{}{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateQuery([]string{indexName}, &meta.ZincQuery{Query: tt.query}, tt.explain, cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, got.Valid)
+			if !tt.explain {
+				assert.Empty(t, got.Explanations)
+				assert.Empty(t, got.Error)
+				return
+			}
+			assert.Len(t, got.Explanations, 1)
+			assert.Equal(t, indexName, got.Explanations[0].Index)
+			if tt.valid {
+				assert.Equal(t, tt.explanation, got.Explanations[0].Explanation)
+			} else {
+				assert.NotEmpty(t, got.Error)
+				assert.Contains(t, got.Explanations[0].Error, indexName)
+			}
+		})
+	}
+
+	t.Run("Cleanup", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+	})
+}
@@ -18,6 +18,7 @@ package core
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
@@ -69,6 +70,19 @@ func (index *Index) GetStorageType() string {
 	return index.ref.StorageType
 }
 
+// GetDataStream returns the name of the data stream the index is backing, empty if it's a regular index
+func (index *Index) GetDataStream() string {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	return index.ref.DataStream
+}
+
+func (index *Index) setDataStream(name string) {
+	index.lock.Lock()
+	index.ref.DataStream = name
+	index.lock.Unlock()
+}
+
 func (index *Index) GetMappings() *meta.Mappings {
 	index.lock.RLock()
 	m := index.ref.Mappings
@@ -83,6 +97,53 @@ func (index *Index) GetSettings() *meta.IndexSettings {
 	return s
 }
 
+func (index *Index) GetDefaultPipeline() string {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	if index.ref.Settings == nil {
+		return ""
+	}
+	return index.ref.Settings.DefaultPipeline
+}
+
+// GetLifecycle returns a copy of the lifecycle settings, nil if they aren't set
+func (index *Index) GetLifecycle() *meta.IndexLifecycle {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	if index.ref.Settings == nil || index.ref.Settings.Lifecycle == nil {
+		return nil
+	}
+	lifecycle := *index.ref.Settings.Lifecycle
+	return &lifecycle
+}
+
+// RemoveLifecycle detaches the lifecycle policy from the index
+func (index *Index) RemoveLifecycle() {
+	index.lock.Lock()
+	if index.ref.Settings != nil {
+		index.ref.Settings.Lifecycle = nil
+	}
+	index.lock.Unlock()
+}
+
+// GetDocTimeMax returns the time of the newest document in all the shards
+func (index *Index) GetDocTimeMax() int64 {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	var t int64
+	for _, shard := range index.ref.Shards {
+		if shard.Stats.DocTimeMax > t {
+			t = shard.Stats.DocTimeMax
+		}
+		for _, secondShard := range shard.Shards {
+			if secondShard.Stats.DocTimeMax > t {
+				t = secondShard.Stats.DocTimeMax
+			}
+		}
+	}
+	return t
+}
+
 func (index *Index) GetStats() meta.IndexStat {
 	index.lock.RLock()
 	s := index.ref.Stats
@@ -96,6 +157,12 @@ func (index *Index) UpdateWALSize(size uint64) {
 	index.lock.Unlock()
 }
 
+func (index *Index) addOpenPITs(n int64) {
+	index.lock.Lock()
+	index.ref.Stats.OpenPITs += n
+	index.lock.Unlock()
+}
+
 func (index *Index) GetAnalyzers() map[string]*analysis.Analyzer {
 	index.lock.RLock()
 	a := index.analyzers
@@ -140,6 +207,32 @@ func (index *Index) SetSettings(settings *meta.IndexSettings) error {
 	if settings.NumberOfReplicas > 0 {
 		index.ref.Settings.NumberOfReplicas = settings.NumberOfReplicas
 	}
+	if settings.DefaultPipeline == PipelineNone {
+		index.ref.Settings.DefaultPipeline = ""
+	} else if settings.DefaultPipeline != "" {
+		index.ref.Settings.DefaultPipeline = settings.DefaultPipeline
+	}
+	if settings.Lifecycle != nil {
+		if index.ref.Settings.Lifecycle == nil {
+			index.ref.Settings.Lifecycle = new(meta.IndexLifecycle)
+		}
+		lifecycle := index.ref.Settings.Lifecycle
+		if settings.Lifecycle.Name != "" {
+			lifecycle.Name = settings.Lifecycle.Name
+		}
+		if settings.Lifecycle.RolloverAlias != "" {
+			lifecycle.RolloverAlias = settings.Lifecycle.RolloverAlias
+		}
+		if settings.Lifecycle.OriginationDate > 0 {
+			lifecycle.OriginationDate = settings.Lifecycle.OriginationDate
+		}
+		if settings.Lifecycle.IndexingComplete {
+			lifecycle.IndexingComplete = true
+		}
+		if lifecycle.OriginationDate == 0 {
+			lifecycle.OriginationDate = time.Now().UnixMilli()
+		}
+	}
 	if settings.NumberOfShards > 0 && index.ref.Settings.NumberOfShards == 0 {
 		index.ref.Settings.NumberOfShards = settings.NumberOfShards
 	}
@@ -341,6 +434,29 @@ func (index *Index) UpdateStatsBySecondShard(id string, secondIndex int64) {
 	index.lock.Unlock()
 }
 
+// WaitForRefresh blocks until the documents written before the call are consumed from the WAL and visible
+// to the searches, it returns false if the timeout is reached first
+func (index *Index) WaitForRefresh(timeout time.Duration) (bool, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for _, shard := range index.shards {
+		shard.lock.RLock()
+		w := shard.wal
+		shard.lock.RUnlock()
+		if w == nil {
+			continue // nothing written since the WAL is closed
+		}
+		id, err := w.LastIndex()
+		if err != nil {
+			return false, err
+		}
+		if !shard.waitForWAL(id, timer) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 // Reopen just close the index, it will open automatically by trigger
 // Deprecated: it will be removed in the future
 func (index *Index) Reopen() error {
@@ -16,37 +16,86 @@
 package core
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
-	"github.com/zinclabs/zincsearch/pkg/zutils/json"
 )
 
-// CreateDocument inserts or updates a document in the zinc index
+// CreateDocument inserts or updates a document in the zinc index, the version of an updated document
+// isn't looked up in the index so it restarts from 1 unless the document is still in the WAL.
 func (index *Index) CreateDocument(
 	docID string, doc map[string]interface{}, update bool, enableTextKeywordMapping bool,
 ) error {
+	_, err := index.createDocument(docID, doc, update, nil, false, enableTextKeywordMapping)
+	return err
+}
+
+// CreateDocumentVersioned inserts or updates a document in the zinc index, the write fails with a version conflict
+// if the current document doesn't match cond, nil means no condition. It returns the version of the document.
+func (index *Index) CreateDocumentVersioned(
+	docID string, doc map[string]interface{}, update bool, cond *meta.VersionCondition, enableTextKeywordMapping bool,
+) (*meta.DocumentVersion, error) {
+	return index.createDocument(docID, doc, update, cond, true, enableTextKeywordMapping)
+}
+
+// createDocument writes the document, the current version is looked up for an update if lookup is true
+// and always for a condition.
+func (index *Index) createDocument(
+	docID string, doc map[string]interface{}, update bool, cond *meta.VersionCondition, lookup, enableTextKeywordMapping bool,
+) (*meta.DocumentVersion, error) {
 	// metrics
 	IncrMetricStatsByIndex(index.GetName(), "wal_request")
 
+	if err := CheckVersionCondition(cond); err != nil {
+		return nil, err
+	}
+
 	// check WAL
 	shard := index.GetShardByDocID(docID)
 	if err := shard.OpenWAL(); err != nil {
-		return err
+		return nil, err
+	}
+
+	// the data streams are append-only, a document with an existing id is rejected
+	if name := index.GetDataStream(); name != "" {
+		if _, ok := doc[meta.TimeFieldName]; !ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("data stream [%s] requires the timestamp field [%s]", name, meta.TimeFieldName))
+		}
+		if update {
+			if _, err := shard.FindShardByDocID(docID, 1); err == nil {
+				return nil, errors.New(errors.ErrorTypeVersionConflictEngineException,
+					fmt.Sprintf("[%s]: version conflict, document already exists in data stream [%s]", docID, name))
+			}
+			update = false
+		}
 	}
 
+	defer shard.lockDocument(docID)()
+	current, _, found, err := shard.currentVersion(docID, (update && lookup) || cond != nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if current.walID > 0 {
+		// the last write of the document is still in the WAL, this one replaces it like an update
+		update = true
+	}
 	secondShardID := ShardIDNeedLatest
 	if update {
 		secondShardID = ShardIDNeedUpdate
 	}
-	data, err := shard.CheckDocument(docID, doc, update, secondShardID, enableTextKeywordMapping)
+	version, err := nextVersion(docID, current, found, cond)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return shard.wal.Write(data)
+	data, err := shard.checkDocument(docID, doc, update, secondShardID, enableTextKeywordMapping)
+	if err != nil {
+		return nil, err
+	}
+	return shard.writeVersioned(docID, data, version, found)
 }
 
 // GetDocument get a document in the zinc index
@@ -57,6 +106,10 @@ func (index *Index) GetDocument(docID string, goroutineNum int) (*meta.Hit, erro
 		return nil, err
 	}
 
+	// the last write of the document may be still in the WAL
+	if hit, found, err := shard.findPendingDocument(docID); found || err != nil {
+		return hit, err
+	}
 	return shard.FindDocumentByDocID(docID, goroutineNum)
 }
 
@@ -64,47 +117,152 @@ func (index *Index) GetDocument(docID string, goroutineNum int) (*meta.Hit, erro
 func (index *Index) UpdateDocument(
 	docID string, doc map[string]interface{}, insert bool, goroutineNum int, enableTextKeywordMapping bool,
 ) error {
+	_, err := index.UpdateDocumentVersioned(docID, doc, insert, nil, goroutineNum, enableTextKeywordMapping)
+	return err
+}
+
+// UpdateDocumentVersioned updates a document in the zinc index, the update fails with a version conflict
+// if the current document doesn't match cond, nil means no condition. It returns the version of the document.
+func (index *Index) UpdateDocumentVersioned(
+	docID string, doc map[string]interface{}, insert bool, cond *meta.VersionCondition, goroutineNum int, enableTextKeywordMapping bool,
+) (*meta.DocumentVersion, error) {
 	// metrics
 	IncrMetricStatsByIndex(index.GetName(), "wal_request")
 
+	if err := CheckVersionCondition(cond); err != nil {
+		return nil, err
+	}
+
 	// check WAL
 	shard := index.GetShardByDocID(docID)
 	if err := shard.OpenWAL(); err != nil {
-		return err
+		return nil, err
+	}
+
+	if name := index.GetDataStream(); name != "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("data stream [%s] is append-only, documents can't be updated", name))
 	}
 
-	update := true
-	secondShardID, err := shard.FindShardByDocID(docID, goroutineNum)
+	defer shard.lockDocument(docID)()
+	current, secondShardID, found, err := shard.currentVersion(docID, true, goroutineNum)
 	if err != nil {
-		if insert && err == errors.ErrorIDNotFound {
-			update = false
-		} else {
-			return err
-		}
+		return nil, err
+	}
+	version, err := nextVersion(docID, current, found, cond)
+	if err != nil {
+		return nil, err
+	}
+	if !found && !insert {
+		return nil, errors.ErrorIDNotFound
 	}
 
-	data, err := shard.CheckDocument(docID, doc, update, secondShardID, enableTextKeywordMapping)
+	data, err := shard.checkDocument(docID, doc, found, secondShardID, enableTextKeywordMapping)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return shard.writeVersioned(docID, data, version, found)
+}
+
+// MergeDocument merges the partial document into the existing one like the update API of ES, the objects are
+// merged recursively. If the document doesn't exist upsert is inserted instead, nil upsert fails with a document
+// missing error. The result is noop if the merge doesn't change the document, nothing is written then.
+func (index *Index) MergeDocument(
+	docID string, doc, upsert map[string]interface{}, cond *meta.VersionCondition, goroutineNum int, enableTextKeywordMapping bool,
+) (*meta.DocumentVersion, error) {
+	// metrics
+	IncrMetricStatsByIndex(index.GetName(), "wal_request")
 
-	return shard.wal.Write(data)
+	if err := CheckVersionCondition(cond); err != nil {
+		return nil, err
+	}
+
+	// check WAL
+	shard := index.GetShardByDocID(docID)
+	if err := shard.OpenWAL(); err != nil {
+		return nil, err
+	}
+
+	if name := index.GetDataStream(); name != "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("data stream [%s] is append-only, documents can't be updated", name))
+	}
+
+	defer shard.lockDocument(docID)()
+	current, secondShardID, found, err := shard.currentVersion(docID, true, goroutineNum)
+	if err != nil {
+		return nil, err
+	}
+	if !found && upsert == nil {
+		return nil, errors.New(errors.ErrorTypeDocumentMissingException, fmt.Sprintf("[%s]: document missing", docID))
+	}
+	version, err := nextVersion(docID, current, found, cond)
+	if err != nil {
+		return nil, err
+	}
+
+	source := upsert
+	if found {
+		// the document is read from the WAL if its last write isn't consumed yet
+		var hit *meta.Hit
+		var pending bool
+		if current.walID > 0 {
+			hit, pending, err = shard.pendingHit(docID, current)
+		}
+		if err == nil && !pending {
+			hit, err = shard.FindDocumentByDocID(docID, goroutineNum)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if source, _ = hit.Source.(map[string]interface{}); source == nil {
+			source = make(map[string]interface{})
+		}
+		if !mergeDocument(source, doc) {
+			return &meta.DocumentVersion{Version: current.version, SeqNo: current.seqNo, PrimaryTerm: meta.PrimaryTerm, Result: "noop"}, nil
+		}
+	}
+
+	data, err := shard.checkDocument(docID, source, found, secondShardID, enableTextKeywordMapping)
+	if err != nil {
+		return nil, err
+	}
+	return shard.writeVersioned(docID, data, version, found)
 }
 
 // DeleteDocument deletes a document in the zinc index
 func (index *Index) DeleteDocument(docID string, goroutineNum int) error {
+	_, err := index.DeleteDocumentVersioned(docID, nil, goroutineNum)
+	return err
+}
+
+// DeleteDocumentVersioned deletes a document in the zinc index, the deletion fails with a version conflict
+// if the current document doesn't match cond, nil means no condition. It returns the version of the deletion.
+func (index *Index) DeleteDocumentVersioned(docID string, cond *meta.VersionCondition, goroutineNum int) (*meta.DocumentVersion, error) {
 	// metrics
 	IncrMetricStatsByIndex(index.GetName(), "wal_request")
 
+	if err := CheckVersionCondition(cond); err != nil {
+		return nil, err
+	}
+
 	// check WAL
 	shard := index.GetShardByDocID(docID)
 	if err := shard.OpenWAL(); err != nil {
-		return err
+		return nil, err
 	}
 
-	secondShardID, err := shard.FindShardByDocID(docID, goroutineNum)
+	defer shard.lockDocument(docID)()
+	current, secondShardID, found, err := shard.currentVersion(docID, true, goroutineNum)
+	if err != nil {
+		return nil, err
+	}
+	version, err := nextVersion(docID, current, found, cond)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !found {
+		return nil, errors.ErrorIDNotFound
 	}
 
 	data := map[string]interface{}{
@@ -112,11 +270,7 @@ func (index *Index) DeleteDocument(docID string, goroutineNum int) error {
 		meta.ActionFieldName: meta.ActionTypeDelete,
 		meta.ShardFieldName:  secondShardID,
 	}
-	jstr, err := json.Marshal(data)
-	if err != nil {
-		return err
-	}
-	return shard.wal.Write(jstr)
+	return shard.writeVersioned(docID, data, version, found)
 }
 
 // isDateProperty returns true if the given value matches the default date format.
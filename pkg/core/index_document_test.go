@@ -21,8 +21,10 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/zinclabs/zincsearch/pkg/bluge/aggregation"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
@@ -113,7 +115,7 @@ func TestIndex_CreateUpdateDocument(t *testing.T) {
 			}
 
 			// wait for WAL write to index
-			time.Sleep(time.Second)
+			waitForRefresh(t, index)
 
 			assert.NoError(t, err)
 			query := &meta.ZincQuery{
@@ -196,7 +198,7 @@ func TestIndex_UpdateDocument(t *testing.T) {
 		assert.NoError(t, err)
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		waitForRefresh(t, index)
 	})
 
 	for _, tt := range tests {
@@ -254,7 +256,7 @@ func TestIndex_GetDocument(t *testing.T) {
 		assert.NoError(t, err)
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		waitForRefresh(t, index)
 	})
 
 	for _, tt := range tests {
@@ -315,7 +317,7 @@ func TestIndex_DeleteDocument(t *testing.T) {
 		assert.NoError(t, err)
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		waitForRefresh(t, index)
 	})
 
 	for _, tt := range tests {
@@ -335,6 +337,230 @@ func TestIndex_DeleteDocument(t *testing.T) {
 	})
 }
 
+func TestIndex_DocumentVersions(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	indexName := "TestIndex_DocumentVersions.index_1"
+	doc := func(name string) map[string]interface{} {
+		return map[string]interface{}{"name": name}
+	}
+	seqNo := func(seqNo, primaryTerm int64) *meta.VersionCondition {
+		return &meta.VersionCondition{IfSeqNo: &seqNo, IfPrimaryTerm: &primaryTerm}
+	}
+	external := func(versionType string, version int64) *meta.VersionCondition {
+		return &meta.VersionCondition{Version: &version, VersionType: versionType}
+	}
+	assertVersion := func(t *testing.T, want meta.DocumentVersion, got *meta.DocumentVersion, err error) {
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			want.PrimaryTerm = meta.PrimaryTerm
+			assert.Equal(t, want, *got)
+		}
+	}
+	assertConflict := func(t *testing.T, err error) {
+		e, ok := err.(*errors.Error)
+		if assert.True(t, ok, "want a version conflict, got %v", err) {
+			assert.Equal(t, errors.ErrorTypeVersionConflictEngineException, e.Type)
+		}
+	}
+
+	var index *Index
+	var err error
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+	})
+
+	t.Run("writes in the WAL", func(t *testing.T) {
+		v, err := index.CreateDocumentVersioned("1", doc("a"), true, nil, cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 1, SeqNo: 1, Result: "created"}, v, err)
+		v, err = index.CreateDocumentVersioned("1", doc("b"), true, nil, cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 2, SeqNo: 2, Result: "updated"}, v, err)
+
+		_, err = index.CreateDocumentVersioned("1", doc("c"), true, seqNo(1, 1), cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+		_, err = index.CreateDocumentVersioned("1", doc("c"), true, seqNo(2, 2), cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+		_, err = index.UpdateDocumentVersioned("2", doc("c"), true, seqNo(0, 1), 1, cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+
+		// the pending versions are read back from the WAL
+		index.GetShardByDocID("1").resetVersions()
+		v, err = index.UpdateDocumentVersioned("1", doc("c"), false, seqNo(2, 1), 1, cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 3, SeqNo: 3, Result: "updated"}, v, err)
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		hit, err := index.GetDocument("1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), hit.Version)
+		assert.Equal(t, int64(3), hit.SeqNo)
+		assert.Equal(t, int64(meta.PrimaryTerm), hit.PrimaryTerm)
+		assert.Equal(t, "c", hit.Source.(map[string]interface{})["name"])
+	})
+
+	t.Run("writes in the index", func(t *testing.T) {
+		_, err := index.UpdateDocumentVersioned("1", doc("d"), false, seqNo(2, 1), 1, cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+		v, err := index.UpdateDocumentVersioned("1", doc("d"), false, seqNo(3, 1), 1, cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 4, SeqNo: 4, Result: "updated"}, v, err)
+	})
+
+	t.Run("external", func(t *testing.T) {
+		v, err := index.CreateDocumentVersioned("1", doc("e"), true, external(meta.VersionTypeExternal, 10), cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 10, SeqNo: 5, Result: "updated"}, v, err)
+		_, err = index.CreateDocumentVersioned("1", doc("e"), true, external(meta.VersionTypeExternal, 10), cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+		_, err = index.CreateDocumentVersioned("1", doc("e"), true, external(meta.VersionTypeExternalGTE, 9), cfg.EnableTextKeywordMapping)
+		assertConflict(t, err)
+		v, err = index.CreateDocumentVersioned("1", doc("e"), true, external(meta.VersionTypeExternalGTE, 10), cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 10, SeqNo: 6, Result: "updated"}, v, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []*meta.VersionCondition{
+			{IfSeqNo: seqNo(1, 1).IfSeqNo},
+			{Version: external("", 1).Version},
+			{VersionType: meta.VersionTypeExternal},
+			external(meta.VersionTypeExternal, -1),
+			external("unknown", 1),
+		}
+		for _, cond := range invalid {
+			_, err := index.CreateDocumentVersioned("1", doc("f"), true, cond, cfg.EnableTextKeywordMapping)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := index.DeleteDocumentVersioned("1", seqNo(1, 1), 1)
+		assertConflict(t, err)
+		v, err := index.DeleteDocumentVersioned("1", seqNo(6, 1), 1)
+		assertVersion(t, meta.DocumentVersion{Version: 11, SeqNo: 7, Result: "deleted"}, v, err)
+		_, err = index.DeleteDocumentVersioned("1", nil, 1)
+		assert.Equal(t, errors.ErrorIDNotFound, err)
+
+		// the version continues from the pending deletion
+		v, err = index.CreateDocumentVersioned("1", doc("g"), true, nil, cfg.EnableTextKeywordMapping)
+		assertVersion(t, meta.DocumentVersion{Version: 12, SeqNo: 8, Result: "created"}, v, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_GetPendingDocument(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	indexName := "TestIndex_GetPendingDocument.index_1"
+
+	var index *Index
+	var err error
+	t.Run("prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+	})
+
+	t.Run("get written document", func(t *testing.T) {
+		for i, name := range []string{"a", "b"} {
+			err := index.CreateDocument("1", map[string]interface{}{"name": name}, true, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+			hit, err := index.GetDocument("1", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "1", hit.ID)
+			assert.Equal(t, indexName, hit.Index)
+			assert.Equal(t, int64(i+1), hit.Version)
+			assert.Equal(t, name, hit.Source.(map[string]interface{})["name"])
+		}
+	})
+
+	t.Run("get deleted document", func(t *testing.T) {
+		assert.NoError(t, index.DeleteDocument("1", 1))
+		_, err := index.GetDocument("1", 1)
+		assert.Equal(t, errors.ErrorIDNotFound, err)
+	})
+
+	t.Run("wait for refresh", func(t *testing.T) {
+		for _, id := range []string{"2", "3", "4"} {
+			err := index.CreateDocument(id, map[string]interface{}{"name": id}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		waitForRefresh(t, index)
+		assert.Len(t, searchAll(t, indexName, cfg), 3)
+
+		hit, err := index.GetDocument("2", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "2", hit.Source.(map[string]interface{})["name"])
+		_, err = index.GetDocument("1", 1)
+		assert.Equal(t, errors.ErrorIDNotFound, err)
+	})
+
+	t.Run("pending and consumed document are the same", func(t *testing.T) {
+		err := index.CreateDocument("5", map[string]interface{}{"name": "5"}, false, cfg.EnableTextKeywordMapping)
+		assert.NoError(t, err)
+		pending, err := index.GetDocument("5", 1)
+		assert.NoError(t, err)
+		waitForRefresh(t, index)
+
+		// a version left behind by the consumer must not hide the indexed document
+		shard := index.GetShardByDocID("5")
+		shard.versionLock.Lock()
+		shard.versions["5"] = &docVersion{version: 1, seqNo: pending.SeqNo, walID: 1}
+		shard.versionLock.Unlock()
+
+		indexed, err := index.GetDocument("5", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "5", indexed.Source.(map[string]interface{})["name"])
+		assert.Equal(t, pending.Timestamp, indexed.Timestamp)
+		assert.Equal(t, pending.Version, indexed.Version)
+	})
+
+	t.Run("concurrent versioned updates", func(t *testing.T) {
+		// the indexed document is looked up in the second shards by all the writers at once
+		waitForRefresh(t, index)
+		current, err := index.GetDocument("5", 1)
+		assert.NoError(t, err)
+
+		const writers = 8
+		eg := errgroup.Group{}
+		for i := 0; i < writers; i++ {
+			i := i
+			eg.Go(func() error {
+				_, err := index.UpdateDocumentVersioned("5", map[string]interface{}{"name": i}, false, nil, 2, cfg.EnableTextKeywordMapping)
+				return err
+			})
+		}
+		assert.NoError(t, eg.Wait())
+		hit, err := index.GetDocument("5", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, current.Version+writers, hit.Version)
+	})
+
+	t.Run("delete and create again", func(t *testing.T) {
+		// the deletion from the second shard and the creation are consumed together, the order is kept
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, index.CreateDocument("6", map[string]interface{}{"name": "6"}, true, cfg.EnableTextKeywordMapping))
+			waitForRefresh(t, index)
+
+			assert.NoError(t, index.DeleteDocument("6", 1))
+			_, err = index.CreateDocumentVersioned("6", map[string]interface{}{"name": "6"}, false, &meta.VersionCondition{Create: true}, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+			waitForRefresh(t, index)
+			_, err = index.GetDocument("6", 1)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
 func TestDateLayoutDetection(t *testing.T) {
 	type args struct {
 		layout string
@@ -493,7 +719,7 @@ func TestIndex_CreateUpdateDocumentWithDateField(t *testing.T) {
 			assert.NoError(t, err)
 
 			// wait for WAL write to index
-			time.Sleep(time.Second)
+			waitForRefresh(t, index)
 
 			var query *meta.ZincQuery
 			if tt.isRange {
@@ -18,6 +18,8 @@ package core
 import (
 	"context"
 	"fmt"
+	"os"
+	"path"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -53,6 +55,13 @@ type IndexShard struct {
 	shards           []*IndexSecondShard
 	wal              *wal.Log
 	lock             sync.RWMutex
+	consumeLock      sync.Mutex // held while consuming the WAL, the segments and the WAL position don't change meanwhile
+	versionLock      sync.Mutex // held while reading the versions of the documents and writing them to the WAL
+	docLocks         [docLockStripes]sync.Mutex
+	versions         map[string]*docVersion
+	seqNo            int64
+	consumedID       uint64        // the last WAL entry written to the second shards, accessed atomically
+	refreshed        chan struct{} // closed after consuming the WAL, guarded by the version lock
 	close            chan struct{}
 	dataPath         string
 	walRedoLogNoSync bool
@@ -214,6 +223,9 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64, goroutineNum int) ([]*bl
 		s.lock.RLock()
 		secondShard := s.shards[i]
 		s.lock.RUnlock()
+		if secondShard.isDeleted() {
+			continue
+		}
 		sMin := atomic.LoadInt64(&secondShard.ref.Stats.DocTimeMin)
 		sMax := atomic.LoadInt64(&secondShard.ref.Stats.DocTimeMax)
 		if (timeMin > 0 && sMax > 0 && sMax < timeMin) ||
@@ -246,6 +258,55 @@ func (s *IndexShard) GetReaders(timeMin, timeMax int64, goroutineNum int) ([]*bl
 	return rs, nil
 }
 
+// DeleteSecondShard removes the data of a frozen second layer shard, the latest shard can't be deleted.
+// The shard stays in the list as an empty shard because the second shards are addressed by position.
+func (s *IndexShard) DeleteSecondShard(shardID int64) error {
+	if shardID < 0 || shardID >= s.GetLatestShardID() {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("second shard [%d] is not frozen", shardID))
+	}
+	// the WAL consumer writes the updates and deletes to all the second shards
+	s.consumeLock.Lock()
+	defer s.consumeLock.Unlock()
+
+	s.lock.RLock()
+	secondShard := s.shards[shardID]
+	s.lock.RUnlock()
+	secondShard.lock.Lock()
+	if secondShard.writer != nil {
+		if err := secondShard.writer.Close(); err != nil {
+			secondShard.lock.Unlock()
+			return err
+		}
+		secondShard.writer = nil
+	}
+	secondShard.lock.Unlock()
+
+	indexName := fmt.Sprintf("%s/%s/%06x", s.GetIndexName(), s.GetID(), shardID)
+	if err := os.RemoveAll(path.Join(s.dataPath, indexName)); err != nil {
+		return err
+	}
+
+	s.root.lock.Lock()
+	secondShard.ref.Stats = meta.IndexStat{}
+	secondShard.ref.Deleted = true
+	s.root.lock.Unlock()
+
+	log.Info().
+		Str("index", s.GetIndexName()).
+		Str("shard", s.GetID()).
+		Int64("second shard", shardID).
+		Msg("deleted second layer shard")
+
+	s.root.UpdateMetadataByShard(s.GetID())
+	return s.root.UpdateMetadata()
+}
+
+func (s *IndexSecondShard) isDeleted() bool {
+	s.root.lock.RLock()
+	defer s.root.lock.RUnlock()
+	return s.ref.Deleted
+}
+
 func (s *IndexShard) openWriter(shardID int64) error {
 	var defaultSearchAnalyzer *analysis.Analyzer
 	analyzers := s.root.GetAnalyzers()
@@ -406,6 +467,7 @@ func (s *IndexShard) FindDocumentByDocID(docID string, goroutineNum int) (*meta.
 				var indexName string
 				var timestamp time.Time
 				var sourceData map[string]interface{}
+				version, seqNo := int64(1), int64(0) // the documents written before the versioning
 				if next, err := dmi.Next(); err == nil {
 					_ = next.VisitStoredFields(func(field string, value []byte) bool {
 						switch field {
@@ -417,6 +479,12 @@ func (s *IndexShard) FindDocumentByDocID(docID string, goroutineNum int) (*meta.
 							timestamp, _ = bluge.DecodeDateTime(value)
 						case "_source":
 							sourceData = source.Response(&meta.Source{Enable: true}, value)
+						case "_version":
+							v, _ := bluge.DecodeNumericFloat64(value)
+							version = int64(v)
+						case "_seq_no":
+							v, _ := bluge.DecodeNumericFloat64(value)
+							seqNo = int64(v)
 						default: // do nothing
 						}
 						return true
@@ -429,6 +497,10 @@ func (s *IndexShard) FindDocumentByDocID(docID string, goroutineNum int) (*meta.
 					Score:     0,
 					Timestamp: timestamp,
 					Source:    sourceData,
+
+					Version:     version,
+					SeqNo:       seqNo,
+					PrimaryTerm: meta.PrimaryTerm,
 				}
 				return errors.ErrCancelSignal // check err, if returns err with cancel other all gorutines.
 			}
@@ -18,6 +18,7 @@ package core
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blugelabs/bluge"
@@ -39,9 +40,14 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 	delete(doc, meta.ActionFieldName)
 	delete(doc, meta.IDFieldName)
 	delete(doc, meta.ShardFieldName)
+	version, _ := doc[meta.VersionFieldName].(float64)
+	seqNo, _ := doc[meta.SeqNoFieldName].(float64)
+	delete(doc, meta.VersionFieldName)
+	delete(doc, meta.SeqNoFieldName)
 
 	// Create a new bluge document
 	bdoc := bluge.NewDocument(docID)
+	fieldNames := make(map[string]struct{})
 	// Iterate through each field and add it to the bluge document
 	for key, value := range doc {
 		if value == nil || key == meta.TimeFieldName || key == meta.SourceFieldName {
@@ -60,11 +66,18 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 					return nil, err
 				}
 			}
+			if len(v) == 0 {
+				continue
+			}
 		default:
 			if err := s.buildField(mappings, bdoc, key, v); err != nil {
 				return nil, err
 			}
 		}
+		addFieldNames(fieldNames, key, prop)
+	}
+	for name := range fieldNames {
+		bdoc.AddField(bluge.NewKeywordField(meta.FieldNamesFieldName, name))
 	}
 
 	// set timestamp
@@ -85,8 +98,13 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 	}
 	bdoc.AddField(bluge.NewStoredOnlyField("_source", sourceByteVal))
 
-	bdoc.AddField(bluge.NewStoredOnlyField("_index", []byte(s.GetIndexName())))
-	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{"_id", "_index", "_source", meta.TimeFieldName}))
+	// _index is sortable, the scroll breaks the ties of the same _id in different indexes by it
+	bdoc.AddField(bluge.NewKeywordField("_index", s.GetIndexName()).StoreValue().Sortable())
+	if version > 0 {
+		bdoc.AddField(bluge.NewNumericField("_version", version).StoreValue())
+		bdoc.AddField(bluge.NewNumericField("_seq_no", seqNo).StoreValue().Sortable())
+	}
+	bdoc.AddField(bluge.NewCompositeFieldExcluding("_all", []string{"_id", "_index", "_source", "_version", "_seq_no", meta.FieldNamesFieldName, meta.TimeFieldName}))
 
 	// Add time for index
 	bdoc.SetTimestamp(timestamp.UnixNano())
@@ -96,6 +114,18 @@ func (s *IndexShard) BuildBlugeDocumentFromJSON(docID string, doc map[string]int
 	return bdoc, nil
 }
 
+// addFieldNames records key, its parent objects and its multi-fields for the exists query.
+func addFieldNames(names map[string]struct{}, key string, prop meta.Property) {
+	names[key] = struct{}{}
+	for propField := range prop.Fields {
+		names[key+"."+propField] = struct{}{}
+	}
+	for i := strings.LastIndexByte(key, '.'); i > 0; i = strings.LastIndexByte(key, '.') {
+		key = key[:i]
+		names[key] = struct{}{}
+	}
+}
+
 func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, key string, value interface{}) error {
 	var field *bluge.TermField
 	prop, _ := mappings.GetProperty(key)
@@ -126,6 +156,18 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		field = bluge.NewDateTimeField(key, v)
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewGeoPointField(key, lon, lat)
+	case "ip":
+		ip, err := zutils.ParseIP(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		field = bluge.NewKeywordField(key, zutils.IPTerm(ip))
 	}
 	if prop.Store || prop.Highlightable {
 		field.StoreValue()
@@ -155,11 +197,25 @@ func (s *IndexShard) buildField(mappings *meta.Mappings, bdoc *bluge.Document, k
 func (s *IndexShard) CheckDocument(
 	docID string, doc map[string]interface{}, update bool, shard int64, enableTextKeywordMapping bool,
 ) ([]byte, error) {
+	flatDoc, err := s.checkDocument(docID, doc, update, shard, enableTextKeywordMapping)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(flatDoc)
+}
+
+// checkDocument checks if the document is valid and returns the WAL entry
+func (s *IndexShard) checkDocument(
+	docID string, doc map[string]interface{}, update bool, shard int64, enableTextKeywordMapping bool,
+) (map[string]interface{}, error) {
 	// Pick the index mapping from the cache if it already exists
 	mappings := s.root.GetMappings()
 	mappingsNeedsUpdate := false
 
 	flatDoc, _ := flatten.Flatten(doc, "")
+	if err := s.checkGeoPoints(mappings, doc, flatDoc); err != nil {
+		return nil, err
+	}
 	// Iterate through each field and add it to the bluge document
 	for key, value := range flatDoc {
 		if value == nil {
@@ -222,7 +278,7 @@ func (s *IndexShard) CheckDocument(
 	flatDoc[meta.TimeFieldName] = timestamp.UnixNano()
 	flatDoc[meta.SourceFieldName] = doc
 
-	return json.Marshal(flatDoc)
+	return flatDoc, nil
 }
 
 // checkProperty returns if need update mappings
@@ -320,6 +376,17 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
 		}
 		v = value
+	case "geo_point":
+		lon, lat, err := zutils.ParseGeoPoint(value)
+		if err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = zutils.FormatGeoPoint(lon, lat)
+	case "ip":
+		if _, err := zutils.ParseIP(value); err != nil {
+			return fmt.Errorf("field [%s] value [%v] parse err: %s", key, value, err.Error())
+		}
+		v = value
 	}
 	if array {
 		sub := data[key].([]interface{})
@@ -331,3 +398,88 @@ func (s *IndexShard) checkField(mappings *meta.Mappings, data map[string]interfa
 
 	return nil
 }
+
+// checkGeoPoints collapses the flattened geo_point values back to one field.
+// A geo point can be an object {"lat": 41.12, "lon": -71.34} or an array [-71.34, 41.12],
+// Flatten splits them into sub fields, so we rebuild it from the original document
+// and convert all the points to the format "lat,lon".
+func (s *IndexShard) checkGeoPoints(mappings *meta.Mappings, doc, flatDoc map[string]interface{}) error {
+	var geoFields map[string]struct{}
+	for key := range flatDoc {
+		field := key
+		for {
+			if prop, ok := mappings.GetProperty(field); ok && prop.Type == "geo_point" {
+				if geoFields == nil {
+					geoFields = make(map[string]struct{})
+				}
+				geoFields[field] = struct{}{}
+				break
+			}
+			i := strings.LastIndexByte(field, '.')
+			if i <= 0 {
+				break
+			}
+			field = field[:i]
+		}
+	}
+
+	for field := range geoFields {
+		for key := range flatDoc {
+			if strings.HasPrefix(key, field+".") {
+				delete(flatDoc, key)
+			}
+		}
+		value, ok := flatDoc[field]
+		if !ok {
+			value = lookupField(doc, field)
+		}
+		if value == nil {
+			delete(flatDoc, field)
+			continue
+		}
+
+		points, ok := value.([]interface{})
+		if ok && len(points) == 2 {
+			if _, isNumber := points[0].(float64); isNumber {
+				points = nil
+			}
+		}
+		if points == nil {
+			lon, lat, err := zutils.ParseGeoPoint(value)
+			if err != nil {
+				return fmt.Errorf("field [%s] value [%v] parse err: %s", field, value, err.Error())
+			}
+			flatDoc[field] = zutils.FormatGeoPoint(lon, lat)
+			continue
+		}
+		values := make([]interface{}, 0, len(points))
+		for _, point := range points {
+			lon, lat, err := zutils.ParseGeoPoint(point)
+			if err != nil {
+				return fmt.Errorf("field [%s] value [%v] parse err: %s", field, point, err.Error())
+			}
+			values = append(values, zutils.FormatGeoPoint(lon, lat))
+		}
+		flatDoc[field] = values
+	}
+
+	return nil
+}
+
+// lookupField returns the value of a dotted field from a nested document
+func lookupField(doc map[string]interface{}, field string) interface{} {
+	if v, ok := doc[field]; ok {
+		return v
+	}
+	for i := 0; i < len(field); i++ {
+		if field[i] != '.' {
+			continue
+		}
+		if sub, ok := doc[field[:i]].(map[string]interface{}); ok {
+			if v := lookupField(sub, field[i+1:]); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
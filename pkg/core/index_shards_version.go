@@ -0,0 +1,424 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+// docVersion is the version of a document written to the WAL, the shard keeps it until the WAL entry is consumed,
+// after that the version and the document are read from the second shards.
+type docVersion struct {
+	version int64
+	seqNo   int64
+	deleted bool
+	walID   uint64 // the WAL entry of the last write, the document is read from it until it's consumed
+}
+
+// docLockStripes is the number of the locks serializing the writes of the same document in a shard
+const docLockStripes = 64
+
+// CheckVersionCondition checks the optimistic concurrency control of a write request
+func CheckVersionCondition(cond *meta.VersionCondition) error {
+	if cond == nil {
+		return nil
+	}
+	if (cond.IfSeqNo == nil) != (cond.IfPrimaryTerm == nil) {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "if_seq_no and if_primary_term should be set together")
+	}
+	switch cond.VersionType {
+	case "", meta.VersionTypeInternal:
+		if cond.Version != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException,
+				"internal versioning can not be used for optimistic concurrency control, please use if_seq_no and if_primary_term instead")
+		}
+	case meta.VersionTypeExternal, meta.VersionTypeExternalGTE:
+		if cond.Version == nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("version is required for version_type [%s]", cond.VersionType))
+		}
+		if *cond.Version < 0 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("version [%d] should be positive", *cond.Version))
+		}
+		if cond.IfSeqNo != nil {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, "compare and write operations can not be used with external versioning")
+		}
+	default:
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("version_type [%s] is invalid, it should be internal, external or external_gte", cond.VersionType))
+	}
+	return nil
+}
+
+// nextVersion checks the condition against the current version of the document and returns the version to write,
+// found is false if the document doesn't exist, cur is the deleted version if the deletion is still in the WAL.
+func nextVersion(docID string, cur docVersion, found bool, cond *meta.VersionCondition) (int64, error) {
+	next := int64(1)
+	if found || cur.deleted {
+		next = cur.version + 1
+	}
+	if cond == nil {
+		return next, nil
+	}
+	if cond.Create && found {
+		return 0, errors.New(errors.ErrorTypeVersionConflictEngineException,
+			fmt.Sprintf("[%s]: version conflict, document already exists (current version [%d])", docID, cur.version))
+	}
+	if cond.IfSeqNo != nil {
+		if !found {
+			return 0, errors.New(errors.ErrorTypeVersionConflictEngineException,
+				fmt.Sprintf("[%s]: version conflict, required seqNo [%d], primary term [%d] but no document was found", docID, *cond.IfSeqNo, *cond.IfPrimaryTerm))
+		}
+		if cur.seqNo != *cond.IfSeqNo || *cond.IfPrimaryTerm != meta.PrimaryTerm {
+			return 0, errors.New(errors.ErrorTypeVersionConflictEngineException,
+				fmt.Sprintf("[%s]: version conflict, required seqNo [%d], primary term [%d]. current document has seqNo [%d] and primary term [%d]",
+					docID, *cond.IfSeqNo, *cond.IfPrimaryTerm, cur.seqNo, meta.PrimaryTerm))
+		}
+		return next, nil
+	}
+	if cond.Version != nil {
+		if found || cur.deleted {
+			switch {
+			case cond.VersionType == meta.VersionTypeExternal && *cond.Version <= cur.version:
+				return 0, errors.New(errors.ErrorTypeVersionConflictEngineException,
+					fmt.Sprintf("[%s]: version conflict, current version [%d] is higher or equal to the one provided [%d]", docID, cur.version, *cond.Version))
+			case cond.VersionType == meta.VersionTypeExternalGTE && *cond.Version < cur.version:
+				return 0, errors.New(errors.ErrorTypeVersionConflictEngineException,
+					fmt.Sprintf("[%s]: version conflict, current version [%d] is higher than the one provided [%d]", docID, cur.version, *cond.Version))
+			}
+		}
+		return *cond.Version, nil
+	}
+	return next, nil
+}
+
+// loadVersions finds the last sequence number of the shard and the versions of the documents still in the WAL,
+// the versions are loaded once, the caller should hold the version lock.
+func (s *IndexShard) loadVersions() error {
+	if s.versions != nil {
+		return nil
+	}
+
+	// the second shards and the committed WAL position are read without a consumption in between
+	s.consumeLock.Lock()
+	defer s.consumeLock.Unlock()
+	seqNo, err := s.findMaxSeqNo()
+	if err != nil {
+		return err
+	}
+	versions := make(map[string]*docVersion)
+	_, committedID, err := s.readRedoLog(RedoActionWrite)
+	if err != nil && err.Error() != errors.ErrNotFound.Error() {
+		return err
+	}
+	lastID, err := s.wal.LastIndex()
+	if err != nil {
+		return err
+	}
+	for id := committedID + 1; id <= lastID; id++ {
+		entry, err := s.wal.Read(id)
+		if err != nil {
+			return err
+		}
+		data := struct {
+			ID      string `json:"@_id"`
+			Action  string `json:"@_action"`
+			Version int64  `json:"@_version"`
+			SeqNo   int64  `json:"@_seq_no"`
+		}{}
+		if err := json.Unmarshal(entry, &data); err != nil {
+			return err
+		}
+		if data.Version == 0 {
+			data.Version = 1 // written before the versioning
+		}
+		versions[data.ID] = &docVersion{version: data.Version, seqNo: data.SeqNo, deleted: data.Action == meta.ActionTypeDelete, walID: id}
+		if data.SeqNo > seqNo {
+			seqNo = data.SeqNo
+		}
+	}
+	s.versions = versions
+	s.seqNo = seqNo
+	return nil
+}
+
+// findMaxSeqNo returns the largest sequence number stored in the second shards
+func (s *IndexShard) findMaxSeqNo() (int64, error) {
+	writers, err := s.GetWriters()
+	if err != nil {
+		return 0, err
+	}
+	query := bluge.NewNumericRangeInclusiveQuery(0, math.MaxFloat64, true, true).SetField("_seq_no")
+	request := bluge.NewTopNSearch(1, query).SortBy([]string{"-_seq_no"})
+	max := int64(0)
+	for _, w := range writers {
+		r, err := w.Reader()
+		if err != nil {
+			return 0, err
+		}
+		dmi, err := r.Search(context.Background(), request)
+		if err != nil {
+			_ = r.Close()
+			return 0, err
+		}
+		if next, err := dmi.Next(); err == nil && next != nil {
+			_ = next.VisitStoredFields(func(field string, value []byte) bool {
+				if field == "_seq_no" {
+					if v, err := bluge.DecodeNumericFloat64(value); err == nil && int64(v) > max {
+						max = int64(v)
+					}
+				}
+				return true
+			})
+		}
+		_ = r.Close()
+	}
+	return max, nil
+}
+
+// lockDocument serializes the writes of a document, it returns the unlock function.
+// The writes of the other documents go on meanwhile, the lookup of the current version doesn't block them.
+func (s *IndexShard) lockDocument(docID string) func() {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(docID))
+	lock := &s.docLocks[h.Sum32()%docLockStripes]
+	lock.Lock()
+	return lock.Unlock
+}
+
+// currentVersion returns the current version of the document and the second shard stores it. The second shards
+// are searched only if lookup is true and the last write of the document isn't in the WAL, found is false otherwise.
+// The caller should hold the document lock.
+func (s *IndexShard) currentVersion(docID string, lookup bool, goroutineNum int) (docVersion, int64, bool, error) {
+	s.versionLock.Lock()
+	if err := s.loadVersions(); err != nil {
+		s.versionLock.Unlock()
+		return docVersion{}, ShardIDNeedUpdate, false, err
+	}
+	v, pending := s.versions[docID]
+	var cur docVersion
+	if pending {
+		cur = *v
+	}
+	s.versionLock.Unlock()
+	if pending {
+		// the document may be still in the WAL, update it in all the second shards
+		return cur, ShardIDNeedUpdate, !cur.deleted, nil
+	}
+	if !lookup {
+		return docVersion{}, ShardIDNeedUpdate, false, nil
+	}
+
+	shardID, cur, err := s.findVersionByDocID(docID, goroutineNum)
+	if err == errors.ErrorIDNotFound {
+		return docVersion{}, shardID, false, nil
+	}
+	if err != nil {
+		return docVersion{}, shardID, false, err
+	}
+	return cur, shardID, true, nil
+}
+
+// findVersionByDocID finds docID in which second shard and returns the shard id and the stored version,
+// the documents written before the versioning are the version 1.
+func (s *IndexShard) findVersionByDocID(docID string, goroutineNum int) (int64, docVersion, error) {
+	query := bluge.NewBooleanQuery()
+	query.AddMust(bluge.NewTermQuery(docID).SetField("_id"))
+	request := bluge.NewTopNSearch(1, query)
+
+	var lock sync.Mutex
+	shardID := int64(-1)
+	version := docVersion{version: 1}
+	writers, err := s.GetWriters()
+	if err != nil {
+		return shardID, version, err
+	}
+
+	eg, ctx := errgroup.WithContext(context.Background())
+	eg.SetLimit(goroutineNum)
+	for id := int64(len(writers)) - 1; id >= 0; id-- {
+		id := id
+		w := writers[id]
+		eg.Go(func() error {
+			r, err := w.Reader()
+			if err != nil {
+				log.Error().Err(err).
+					Str("index", s.GetIndexName()).
+					Str("shard", s.GetID()).
+					Int64("second shard", id).
+					Msg("failed to get reader")
+				return nil // not check err, if returns err with cancel all gorutines.
+			}
+			defer r.Close()
+			dmi, err := r.Search(ctx, request)
+			if err != nil {
+				log.Error().Err(err).
+					Str("index", s.GetIndexName()).
+					Str("shard", s.GetID()).
+					Int64("second shard", id).
+					Msg("failed to do search")
+				return nil // not check err, if returns err with cancel all gorutines.
+			}
+			next, err := dmi.Next()
+			if err != nil || next == nil {
+				return nil
+			}
+			found := docVersion{version: 1}
+			_ = next.VisitStoredFields(func(field string, value []byte) bool {
+				switch field {
+				case "_version":
+					v, _ := bluge.DecodeNumericFloat64(value)
+					found.version = int64(v)
+				case "_seq_no":
+					v, _ := bluge.DecodeNumericFloat64(value)
+					found.seqNo = int64(v)
+				}
+				return true
+			})
+			lock.Lock()
+			if id > shardID {
+				shardID, version = id, found
+			}
+			lock.Unlock()
+			return errors.ErrCancelSignal // check err, if returns err with cancel other all gorutines.
+		})
+	}
+	_ = eg.Wait()
+	if shardID == -1 {
+		return shardID, version, errors.ErrorIDNotFound
+	}
+	return shardID, version, nil
+}
+
+// writeVersioned writes the WAL entry with the next sequence number and keeps the version of the document,
+// existed tells the result is an update or a creation. The caller should hold the document lock.
+func (s *IndexShard) writeVersioned(docID string, data map[string]interface{}, version int64, existed bool) (*meta.DocumentVersion, error) {
+	s.versionLock.Lock()
+	defer s.versionLock.Unlock()
+	if err := s.loadVersions(); err != nil {
+		return nil, err
+	}
+	seqNo := s.seqNo + 1
+	data[meta.VersionFieldName] = version
+	data[meta.SeqNoFieldName] = seqNo
+	entry, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.wal.Write(entry); err != nil {
+		return nil, err
+	}
+	walID, err := s.wal.LastIndex()
+	if err != nil {
+		return nil, err
+	}
+	s.seqNo = seqNo
+	deleted := data[meta.ActionFieldName] == meta.ActionTypeDelete
+	s.versions[docID] = &docVersion{version: version, seqNo: seqNo, deleted: deleted, walID: walID}
+
+	result := "created"
+	switch {
+	case deleted:
+		result = "deleted"
+	case existed:
+		result = "updated"
+	}
+	return &meta.DocumentVersion{Version: version, SeqNo: seqNo, PrimaryTerm: meta.PrimaryTerm, Result: result}, nil
+}
+
+// findPendingDocument returns the document if its last write is still in the WAL, found is false if
+// the document should be read from the second shards. A pending deletion returns errors.ErrorIDNotFound.
+func (s *IndexShard) findPendingDocument(docID string) (*meta.Hit, bool, error) {
+	s.versionLock.Lock()
+	if err := s.loadVersions(); err != nil {
+		s.versionLock.Unlock()
+		return nil, false, err
+	}
+	v, ok := s.versions[docID]
+	var cur docVersion
+	if ok {
+		cur = *v
+	}
+	s.versionLock.Unlock()
+	if !ok {
+		return nil, false, nil
+	}
+	// the consumer makes the documents searchable before it prunes their versions
+	if cur.walID <= atomic.LoadUint64(&s.consumedID) {
+		return nil, false, nil
+	}
+	return s.pendingHit(docID, cur)
+}
+
+// pendingHit builds the document from the WAL entry of its last write, found is false if the entry is
+// already consumed and removed from the WAL.
+func (s *IndexShard) pendingHit(docID string, v docVersion) (*meta.Hit, bool, error) {
+	if v.deleted {
+		return nil, true, errors.ErrorIDNotFound
+	}
+	entry, err := s.wal.Read(v.walID)
+	if err != nil {
+		return nil, false, nil
+	}
+	// the timestamp is decoded as a float like the consumer does, to return the value the index stores
+	data := struct {
+		Timestamp float64                `json:"@timestamp"`
+		Source    map[string]interface{} `json:"@_source"`
+	}{}
+	if err := json.Unmarshal(entry, &data); err != nil {
+		return nil, true, err
+	}
+	return &meta.Hit{
+		Index:       s.GetIndexName(),
+		Type:        "_doc",
+		ID:          docID,
+		Timestamp:   time.Unix(0, int64(data.Timestamp)).UTC(),
+		Source:      data.Source,
+		Version:     v.version,
+		SeqNo:       v.seqNo,
+		PrimaryTerm: meta.PrimaryTerm,
+	}, true, nil
+}
+
+// pruneVersions forgets the versions of the WAL entries which are consumed, they can be read from the second shards
+func (s *IndexShard) pruneVersions(walID uint64) {
+	s.versionLock.Lock()
+	defer s.versionLock.Unlock()
+	for docID, v := range s.versions {
+		if v.walID <= walID {
+			delete(s.versions, docID)
+		}
+	}
+}
+
+// resetVersions reloads the versions on the next write, the WAL is written bypassing the versioning
+func (s *IndexShard) resetVersions() {
+	s.versionLock.Lock()
+	defer s.versionLock.Unlock()
+	s.versions = nil
+}
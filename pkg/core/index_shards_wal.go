@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	blugeindex "github.com/blugelabs/bluge/index"
@@ -63,6 +64,11 @@ func (s *IndexShard) OpenWAL() error {
 	if err = s.Rollback(); err != nil {
 		return err
 	}
+	_, consumedID, err := s.readRedoLog(RedoActionWrite)
+	if err != nil && err.Error() != errors.ErrNotFound.Error() {
+		return err
+	}
+	atomic.StoreUint64(&s.consumedID, consumedID)
 
 	// set wal to consumer list
 	ZINC_INDEX_SHARD_WAL_LIST.Add(s)
@@ -137,6 +143,51 @@ func (s *IndexShard) Rollback() error {
 
 // ConsumeWAL consume WAL for index returns if there is any data updated
 func (s *IndexShard) ConsumeWAL() bool {
+	updated, consumedID := s.consumeWAL()
+	if updated {
+		// the consumed documents can be found in the second shards with their versions
+		s.pruneVersions(consumedID)
+		s.notifyRefreshed()
+	}
+	return updated
+}
+
+// notifyRefreshed wakes up the requests waiting for the WAL to be consumed
+func (s *IndexShard) notifyRefreshed() {
+	s.versionLock.Lock()
+	if s.refreshed != nil {
+		close(s.refreshed)
+		s.refreshed = nil
+	}
+	s.versionLock.Unlock()
+}
+
+// waitForWAL blocks until the WAL entries up to id are consumed, it returns false if the timer expires first
+func (s *IndexShard) waitForWAL(id uint64, timer *time.Timer) bool {
+	for {
+		s.versionLock.Lock()
+		if s.refreshed == nil {
+			s.refreshed = make(chan struct{})
+		}
+		refreshed := s.refreshed
+		s.versionLock.Unlock()
+
+		if atomic.LoadUint64(&s.consumedID) >= id {
+			return true
+		}
+		select {
+		case <-refreshed:
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// consumeWAL returns if there is any data updated and the last WAL entry consumed
+func (s *IndexShard) consumeWAL() (bool, uint64) {
+	s.consumeLock.Lock()
+	defer s.consumeLock.Unlock()
+
 	if err := s.wal.Sync(); err != nil {
 		log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.Sync()")
 	}
@@ -147,16 +198,17 @@ func (s *IndexShard) ConsumeWAL() bool {
 	maxID, err = s.wal.LastIndex()
 	if err != nil {
 		log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.LastIndex()")
-		return false
+		return false, 0
 	}
 	// read last committed ID
 	_, minID, err = s.readRedoLog(RedoActionWrite)
 	if err != nil && err.Error() != errors.ErrNotFound.Error() {
 		log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.readRedoLog()")
-		return false
+		return false, 0
 	}
+	atomic.StoreUint64(&s.consumedID, minID)
 	if minID == maxID {
-		return false // no new entries
+		return false, 0 // no new entries
 	}
 	log.Debug().Str("index", s.GetIndexName()).Str("shard", s.GetID()).Uint64("minID", minID).Uint64("maxID", maxID).Msg("consume wal begin")
 
@@ -172,29 +224,30 @@ func (s *IndexShard) ConsumeWAL() bool {
 		entry, err = s.wal.Read(minID)
 		if err != nil {
 			log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.Read()")
-			return false
+			return false, 0
 		}
 
 		doc := make(map[string]interface{})
 		err = json.Unmarshal(entry, &doc)
 		if err != nil {
 			log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.entry.Unmarshal()")
-			return false
+			return false, 0
 		}
 		docs.AddDocument(doc)
 		if docs.MaxShardLen() >= s.batchSize {
 			if err = s.writeRedoLog(RedoActionRead, startID, minID); err != nil {
 				log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Str("stage", "read").Msg("consume wal.redolog.Write()")
-				return false
+				return false, 0
 			}
 			if err = docs.WriteTo(s, batch, false); err != nil {
 				log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.docs.WriteTo()")
-				return false
+				return false, 0
 			}
 			if err = s.writeRedoLog(RedoActionWrite, startID, minID); err != nil {
 				log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Str("stage", "write").Msg("consume wal.redolog.Write()")
-				return false
+				return false, 0
 			}
+			atomic.StoreUint64(&s.consumedID, minID)
 			// Reset startID to nextID
 			startID = minID + 1
 		}
@@ -206,34 +259,35 @@ func (s *IndexShard) ConsumeWAL() bool {
 	if docs.MaxShardLen() > 0 {
 		if err = s.writeRedoLog(RedoActionRead, startID, minID); err != nil {
 			log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Str("stage", "read").Msg("consume wal.redolog.Write()")
-			return false
+			return false, 0
 		}
 		if err := docs.WriteTo(s, batch, false); err != nil {
 			log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume wal.docs.WriteTo()")
-			return false
+			return false, 0
 		}
 		if err = s.writeRedoLog(RedoActionWrite, startID, minID); err != nil {
 			log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Str("stage", "write").Msg("consume wal.redolog.Write()")
-			return false
+			return false, 0
 		}
+		atomic.StoreUint64(&s.consumedID, minID)
 	}
 	log.Debug().Str("index", s.GetIndexName()).Str("shard", s.GetID()).Uint64("minID", minID).Uint64("maxID", maxID).Msg("consume wal end")
 
 	// Truncate log
 	if err = s.wal.TruncateFront(minID); err != nil {
 		log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Uint64("id", minID).Msg("consume wal.Truncate()")
-		return true
+		return true, minID
 	}
 
 	// check shards
 	if err = s.CheckShards(s.maxSize); err != nil {
 		log.Error().Err(err).Str("index", s.GetIndexName()).Str("shard", s.GetID()).Msg("consume index.CheckShards()")
-		return true
+		return true, minID
 	}
 
 	//  update metadata
 	s.root.UpdateMetadataByShard(s.GetID())
-	return true
+	return true, minID
 }
 
 const (
@@ -325,8 +379,18 @@ func (w *walMergeDocs) AddDocument(data map[string]interface{}) {
 // need split by shards
 // need merge actions by docID
 func (w *walMergeDocs) WriteTo(shard *IndexShard, batch *blugeindex.Batch, rollback bool) error {
-	var err error
+	// the writes to all the second shards go last, they follow the writes of the same documents to a second shard
+	shardIDs := make([]int64, 0, len(*w))
 	for shardID := range *w {
+		if shardID != ShardIDNeedUpdate {
+			shardIDs = append(shardIDs, shardID)
+		}
+	}
+	if _, ok := (*w)[ShardIDNeedUpdate]; ok {
+		shardIDs = append(shardIDs, ShardIDNeedUpdate)
+	}
+	var err error
+	for _, shardID := range shardIDs {
 		if !rollback {
 			err = w.WriteToShard(shard, shardID, batch)
 		} else {
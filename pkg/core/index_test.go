@@ -355,3 +355,12 @@ func TestIndex_Settings(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+// waitForRefresh waits until the WAL of the indexes is written to the index.
+func waitForRefresh(t *testing.T, indexes ...*Index) {
+	for _, index := range indexes {
+		ok, err := index.WaitForRefresh(10 * time.Second)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+}
@@ -16,13 +16,17 @@
 package core
 
 import (
-	"github.com/zinclabs/zincsearch/pkg/config"
+	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/rs/zerolog/log"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/metadata"
 )
@@ -184,3 +188,124 @@ func (t *IndexList) Close() error {
 func (t *IndexList) GC() error {
 	return nil // TODO: implement GC
 }
+
+// ParseIndicesOptions parses the ignore_unavailable and allow_no_indices params, an empty value keeps the default
+func ParseIndicesOptions(ignoreUnavailable, allowNoIndices string) (meta.IndicesOptions, error) {
+	opts := meta.IndicesOptions{}
+	var err error
+	if ignoreUnavailable != "" {
+		if opts.IgnoreUnavailable, err = strconv.ParseBool(ignoreUnavailable); err != nil {
+			return opts, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ignore_unavailable] should be a boolean, got [%s]", ignoreUnavailable))
+		}
+	}
+	if allowNoIndices != "" {
+		allow, err := strconv.ParseBool(allowNoIndices)
+		if err != nil {
+			return opts, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[allow_no_indices] should be a boolean, got [%s]", allowNoIndices))
+		}
+		opts.AllowNoIndices = &allow
+	}
+	return opts, nil
+}
+
+// ResolveIndexNames returns the sorted names of the indexes of the targets. A target is a comma separated list of
+// index names, aliases, wildcard patterns, _all and -exclusions, no target means all the indexes.
+func ResolveIndexNames(targets []string, opts meta.IndicesOptions) ([]string, error) {
+	allowNoIndices := opts.AllowNoIndices == nil || *opts.AllowNoIndices
+	expressions := make([]string, 0, len(targets))
+	for _, target := range targets {
+		for _, name := range strings.Split(target, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				expressions = append(expressions, name)
+			}
+		}
+	}
+	if len(expressions) == 0 {
+		expressions = append(expressions, "_all")
+	}
+
+	resolved := make(map[string]bool)
+	for i, expression := range expressions {
+		exclude := len(expression) > 1 && expression[0] == '-'
+		if exclude {
+			expression = expression[1:]
+			if i == 0 {
+				// an exclusion first excludes from all the indexes
+				for _, name := range ZINC_INDEX_LIST.ListName() {
+					resolved[name] = true
+				}
+			}
+		}
+		names, ok := matchIndexExpression(expression)
+		switch {
+		case exclude:
+			for _, name := range names {
+				delete(resolved, name)
+			}
+			continue
+		case !ok && !opts.IgnoreUnavailable, ok && len(names) == 0 && !allowNoIndices:
+			return nil, errors.New(errors.ErrorTypeIndexNotFoundException, fmt.Sprintf("no such index [%s]", expression))
+		}
+		for _, name := range names {
+			resolved[name] = true
+		}
+	}
+	if len(resolved) == 0 && !allowNoIndices {
+		return nil, errors.New(errors.ErrorTypeIndexNotFoundException, fmt.Sprintf("no such index [%s]", strings.Join(expressions, ",")))
+	}
+
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// matchIndexExpression returns the indexes of an index name, alias or wildcard pattern,
+// ok is false if a name is neither an index nor an alias.
+func matchIndexExpression(expression string) (names []string, ok bool) {
+	if expression == "_all" || expression == "*" {
+		return ZINC_INDEX_LIST.ListName(), true
+	}
+	if strings.ContainsAny(expression, "*?") {
+		for _, name := range ZINC_INDEX_LIST.ListName() {
+			if isMatchIndex(name, expression) {
+				names = append(names, name)
+			}
+		}
+		for _, name := range ZINC_INDEX_ALIAS_LIST.GetIndexesForAliasPattern(expression) {
+			if _, exists := GetIndex(name); exists {
+				names = append(names, name)
+			}
+		}
+		return names, true
+	}
+	if aliasIndexes, isAlias := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(expression); isAlias {
+		for _, name := range aliasIndexes {
+			if _, exists := GetIndex(name); exists {
+				names = append(names, name)
+			}
+		}
+		return names, true
+	}
+	if _, exists := GetIndex(expression); exists {
+		return []string{expression}, true
+	}
+	return nil, false
+}
+
+// resolveIndexes returns the indexes of a comma separated list of indexes, aliases or wildcard patterns
+func resolveIndexes(target string, opts meta.IndicesOptions) ([]*Index, error) {
+	names, err := ResolveIndexNames([]string{target}, opts)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]*Index, 0, len(names))
+	for _, name := range names {
+		if index, ok := GetIndex(name); ok {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes, nil
+}
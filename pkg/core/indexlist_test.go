@@ -16,10 +16,12 @@
 package core
 
 import (
-	"github.com/zinclabs/zincsearch/pkg/config"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
 func TestIndexList_List(t *testing.T) {
@@ -48,3 +50,127 @@ func TestIndexList_List(t *testing.T) {
 	err = ZINC_INDEX_LIST.GC()
 	assert.NoError(t, err)
 }
+
+func TestResolveIndexNames(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	prefix := "TestResolveIndexNames."
+	names := []string{prefix + "logs-2022.10.01", prefix + "logs-2022.10.02", prefix + "logs-2022.11.01", prefix + "audit-1"}
+	t.Run("prepare", func(t *testing.T) {
+		for _, name := range names {
+			index, err := NewIndex(name, "disk", 1, cfg)
+			assert.NoError(t, err)
+			err = StoreIndex(index)
+			assert.NoError(t, err)
+		}
+		err := ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(prefix+"alias", []string{prefix + "audit-1", prefix + "logs-2022.11.01"})
+		assert.NoError(t, err)
+	})
+
+	allow := false
+	tests := []struct {
+		name    string
+		targets []string
+		opts    meta.IndicesOptions
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "comma list",
+			targets: []string{prefix + "audit-1, " + prefix + "logs-2022.10.01"},
+			want:    []string{names[3], names[0]},
+		},
+		{
+			name:    "wildcards",
+			targets: []string{prefix + "logs-2022.10.*," + prefix + "audit-?"},
+			want:    []string{names[3], names[0], names[1]},
+		},
+		{
+			name:    "exclusion",
+			targets: []string{prefix + "logs-*,-" + prefix + "logs-2022.10.02"},
+			want:    []string{names[0], names[2]},
+		},
+		{
+			name:    "alias",
+			targets: []string{prefix + "alias," + prefix + "audit-1"},
+			want:    []string{names[3], names[2]},
+		},
+		{
+			name:    "alias wildcard",
+			targets: []string{prefix + "ali*"},
+			want:    []string{names[3], names[2]},
+		},
+		{
+			name:    "several targets",
+			targets: []string{prefix + "logs-2022.11.01", prefix + "logs-2022.1?.01"},
+			want:    []string{names[0], names[2]},
+		},
+		{
+			name:    "missing",
+			targets: []string{prefix + "audit-1," + prefix + "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "ignore_unavailable",
+			targets: []string{prefix + "audit-1," + prefix + "missing"},
+			opts:    meta.IndicesOptions{IgnoreUnavailable: true},
+			want:    []string{names[3]},
+		},
+		{
+			name:    "no match",
+			targets: []string{prefix + "metrics-*"},
+			want:    []string{},
+		},
+		{
+			name:    "allow_no_indices",
+			targets: []string{prefix + "metrics-*"},
+			opts:    meta.IndicesOptions{AllowNoIndices: &allow},
+			wantErr: true,
+		},
+		{
+			name:    "allow_no_indices excluded",
+			targets: []string{prefix + "audit-*,-" + prefix + "audit-1"},
+			opts:    meta.IndicesOptions{AllowNoIndices: &allow},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveIndexNames(tt.targets, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("all", func(t *testing.T) {
+		got, err := ResolveIndexNames([]string{"_all"}, meta.IndicesOptions{})
+		assert.NoError(t, err)
+		assert.Subset(t, got, names)
+		got, err = ResolveIndexNames(nil, meta.IndicesOptions{})
+		assert.NoError(t, err)
+		assert.Subset(t, got, names)
+		got, err = ResolveIndexNames([]string{"-" + prefix + "audit-1"}, meta.IndicesOptions{})
+		assert.NoError(t, err)
+		assert.NotContains(t, got, names[3])
+		assert.Contains(t, got, names[0])
+	})
+
+	t.Run("search with shards breakdown", func(t *testing.T) {
+		resp, err := MultiSearch([]string{prefix + "logs-2022.10.*"}, &meta.ZincQuery{Size: 10}, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Shards.Indices, 2)
+		assert.Equal(t, int64(1), resp.Shards.Indices[names[0]].Total)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := ZINC_INDEX_ALIAS_LIST.DeleteAlias(prefix + "alias")
+		assert.NoError(t, err)
+		for _, name := range names {
+			err := DeleteIndex(name, cfg.DataPath)
+			assert.NoError(t, err)
+		}
+	})
+}
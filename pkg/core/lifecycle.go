@@ -0,0 +1,555 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// ZINC_LIFECYCLE applies the lifecycle policies of the indexes in the background
+var ZINC_LIFECYCLE Lifecycle
+
+type Lifecycle struct {
+	lock     sync.Mutex // one run or rollover at a time
+	errLock  sync.RWMutex
+	failures map[string]string // the last failure of every index, shown by the explain
+}
+
+// NewLifecycle starts the scheduler applying the lifecycle policies every interval, it is disabled if interval is 0
+func NewLifecycle(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go ZINC_LIFECYCLE.Run(interval)
+}
+
+func (t *Lifecycle) Run(interval time.Duration) {
+	tick := time.NewTicker(interval)
+	for range tick.C {
+		t.Apply(time.Now())
+	}
+}
+
+// Apply rolls over the write indexes meeting the rollover conditions, and deletes the indexes
+// or the frozen second layer shards whose newest document is older than the retention.
+func (t *Lifecycle) Apply(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	policies := make(map[string]*lifecyclePolicy)
+	for _, index := range ZINC_INDEX_LIST.List() {
+		lifecycle := index.GetLifecycle()
+		if lifecycle == nil || lifecycle.Name == "" {
+			continue
+		}
+		err := t.apply(index, lifecycle, policies, now)
+		if err != nil {
+			log.Error().Err(err).Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("failed to apply lifecycle policy")
+		}
+		t.setError(index.GetName(), err)
+	}
+}
+
+func (t *Lifecycle) apply(index *Index, lifecycle *meta.IndexLifecycle, policies map[string]*lifecyclePolicy, now time.Time) error {
+	policy, err := loadLifecyclePolicy(lifecycle.Name, policies)
+	if err != nil {
+		return err
+	}
+
+	if policy.rollover != nil && isWriteIndex(lifecycle) {
+		if w, ok := GetWriteIndex(lifecycle.RolloverAlias); ok && w == index {
+			resp, err := rollover(lifecycle.RolloverAlias, policy.rollover, now)
+			if err != nil {
+				return err
+			}
+			if resp.RolledOver {
+				log.Info().Str("alias", lifecycle.RolloverAlias).Str("old_index", resp.OldIndex).Str("new_index", resp.NewIndex).Msg("rolled over index")
+				lifecycle = index.GetLifecycle()
+			}
+		}
+	}
+
+	if policy.deleteAfter == 0 {
+		return nil
+	}
+	cutoff := now.Add(-policy.deleteAfter).UnixNano()
+	if policy.deleteScope == meta.LifecycleDeleteScopeShard {
+		return deleteExpiredShards(index, cutoff)
+	}
+	if isWriteIndex(lifecycle) {
+		return nil
+	}
+	if docTimeMax := index.GetDocTimeMax(); docTimeMax > 0 && docTimeMax < cutoff {
+		log.Info().Str("index", index.GetName()).Str("policy", lifecycle.Name).Msg("deleting expired index")
+		return deleteLifecycleIndex(index.GetName())
+	}
+	return nil
+}
+
+func (t *Lifecycle) setError(name string, err error) {
+	t.errLock.Lock()
+	defer t.errLock.Unlock()
+	if err == nil {
+		delete(t.failures, name)
+		return
+	}
+	if t.failures == nil {
+		t.failures = make(map[string]string)
+	}
+	t.failures[name] = err.Error()
+}
+
+func (t *Lifecycle) getError(name string) string {
+	t.errLock.RLock()
+	defer t.errLock.RUnlock()
+	return t.failures[name]
+}
+
+// ListLifecyclePolicies returns all the lifecycle policies
+func ListLifecyclePolicies() ([]*meta.LifecyclePolicy, error) {
+	policies, err := metadata.LifecyclePolicy.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if policies == nil {
+		policies = make([]*meta.LifecyclePolicy, 0)
+	}
+	return policies, nil
+}
+
+// GetLifecyclePolicy returns the policy, a resource_not_found_exception if it doesn't exist
+func GetLifecyclePolicy(name string) (*meta.LifecyclePolicy, error) {
+	policy, err := metadata.LifecyclePolicy.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("lifecycle policy [%s] is missing", name))
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// PutLifecyclePolicy creates or updates the policy, the conditions are checked before it is stored
+func PutLifecyclePolicy(name string, policy *meta.LifecyclePolicy) error {
+	if err := checkResourceName("lifecycle policy", name); err != nil {
+		return err
+	}
+	if _, err := parseLifecyclePolicy(policy); err != nil {
+		return err
+	}
+	policy.Name = name
+	policy.CreatedAt = time.Now()
+	policy.UpdatedAt = policy.CreatedAt
+	if old, err := metadata.LifecyclePolicy.Get(name); err == nil {
+		policy.CreatedAt = old.CreatedAt
+	}
+	return metadata.LifecyclePolicy.Set(name, *policy)
+}
+
+// DeleteLifecyclePolicy deletes the policy, it can't be deleted while indexes use it
+func DeleteLifecyclePolicy(name string) error {
+	if _, err := GetLifecyclePolicy(name); err != nil {
+		return err
+	}
+	var used []string
+	for _, index := range ZINC_INDEX_LIST.List() {
+		if lifecycle := index.GetLifecycle(); lifecycle != nil && lifecycle.Name == name {
+			used = append(used, index.GetName())
+		}
+	}
+	if len(used) > 0 {
+		sort.Strings(used)
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("cannot delete lifecycle policy [%s], it is in use by the indexes %v", name, used))
+	}
+	return metadata.LifecyclePolicy.Delete(name)
+}
+
+// GetWriteIndex returns the index receiving the writes to a rollover alias
+func GetWriteIndex(alias string) (*Index, bool) {
+	names, ok := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(alias)
+	if !ok {
+		return nil, false
+	}
+	for _, name := range names {
+		index, ok := GetIndex(name)
+		if !ok {
+			continue
+		}
+		if lifecycle := index.GetLifecycle(); isWriteIndex(lifecycle) && lifecycle.RolloverAlias == alias {
+			return index, true
+		}
+	}
+	return nil, false
+}
+
+func isWriteIndex(lifecycle *meta.IndexLifecycle) bool {
+	return lifecycle != nil && lifecycle.RolloverAlias != "" && !lifecycle.IndexingComplete
+}
+
+// Rollover creates the next index of the alias and makes it the write index when any of the conditions is met,
+// without conditions it always rolls over. The index name has to end with a number, which is incremented.
+func Rollover(alias string, conditions *meta.LifecycleRollover, now time.Time) (*meta.RolloverResponse, error) {
+	var c *rolloverConditions
+	if conditions != nil {
+		var err error
+		if c, err = parseRolloverConditions(conditions); err != nil {
+			return nil, err
+		}
+	}
+	ZINC_LIFECYCLE.lock.Lock()
+	defer ZINC_LIFECYCLE.lock.Unlock()
+	return rollover(alias, c, now)
+}
+
+var rolloverIndexNameRe = regexp.MustCompile(`^(.*-)(\d+)$`)
+
+func rollover(alias string, c *rolloverConditions, now time.Time) (*meta.RolloverResponse, error) {
+	index, ok := GetWriteIndex(alias)
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("rollover target [%s] is not an alias with a write index", alias))
+	}
+	resp := &meta.RolloverResponse{
+		Acknowledged: true,
+		OldIndex:     index.GetName(),
+		Conditions:   make(map[string]bool),
+	}
+	ds, err := metadata.DataStream.Get(alias)
+	if err != nil && err != errors.ErrKeyNotFound {
+		return nil, err
+	}
+	if ds != nil {
+		resp.NewIndex = dataStreamIndexName(alias, now, ds.Generation+1)
+	} else {
+		m := rolloverIndexNameRe.FindStringSubmatch(index.GetName())
+		if m == nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("index name [%s] does not match pattern '^.*-\\d+$'", index.GetName()))
+		}
+		n, _ := strconv.ParseUint(m[2], 10, 64)
+		resp.NewIndex = fmt.Sprintf("%s%06d", m[1], n+1)
+	}
+	if c != nil && !c.check(index, now, resp.Conditions) {
+		return resp, nil
+	}
+
+	var newIndex *Index
+	if ds != nil {
+		if newIndex, err = rolloverDataStream(ds, now); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, exists := GetIndex(resp.NewIndex); exists {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("rollover index [%s] already exists", resp.NewIndex))
+		}
+		if newIndex, _, err = ZINC_INDEX_LIST.GetOrCreate(resp.NewIndex, index.GetStorageType(), 0); err != nil {
+			return nil, err
+		}
+	}
+	// the policy of the template wins, the new index continues the policy of the old one otherwise
+	lifecycle := index.GetLifecycle()
+	name := lifecycle.Name
+	if l := newIndex.GetLifecycle(); l != nil && l.Name != "" {
+		name = l.Name
+	}
+	_ = newIndex.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{
+		Name:            name,
+		RolloverAlias:   alias,
+		OriginationDate: now.UnixMilli(),
+	}})
+	if err := StoreIndex(newIndex); err != nil {
+		return nil, err
+	}
+
+	// the new index joins the alias first, the writes never see the alias without a write index
+	if err := ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(alias, []string{newIndex.GetName()}); err != nil {
+		return nil, err
+	}
+	_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{IndexingComplete: true}})
+	if err := StoreIndex(index); err != nil {
+		return nil, err
+	}
+	resp.RolledOver = true
+	return resp, nil
+}
+
+// RemoveLifecycle detaches the lifecycle policy from the indexes, it returns the names of the indexes
+func RemoveLifecycle(target string) ([]string, error) {
+	indexes, err := resolveIndexes(target, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ZINC_LIFECYCLE.lock.Lock()
+	defer ZINC_LIFECYCLE.lock.Unlock()
+	names := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		index.RemoveLifecycle()
+		if err := StoreIndex(index); err != nil {
+			return names, err
+		}
+		ZINC_LIFECYCLE.setError(index.GetName(), nil)
+		names = append(names, index.GetName())
+	}
+	return names, nil
+}
+
+// ExplainLifecycle returns the lifecycle state of the indexes, target is a comma separated list of indexes,
+// aliases or wildcard patterns.
+func ExplainLifecycle(target string, now time.Time) (map[string]*meta.LifecycleExplain, error) {
+	indexes, err := resolveIndexes(target, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]*lifecyclePolicy)
+	resp := make(map[string]*meta.LifecycleExplain, len(indexes))
+	for _, index := range indexes {
+		resp[index.GetName()] = explainLifecycle(index, policies, now)
+	}
+	return resp, nil
+}
+
+func explainLifecycle(index *Index, policies map[string]*lifecyclePolicy, now time.Time) *meta.LifecycleExplain {
+	resp := &meta.LifecycleExplain{Index: index.GetName()}
+	lifecycle := index.GetLifecycle()
+	if lifecycle == nil || lifecycle.Name == "" {
+		return resp
+	}
+	resp.Managed = true
+	resp.Policy = lifecycle.Name
+	resp.RolloverAlias = lifecycle.RolloverAlias
+	resp.IsWriteIndex = isWriteIndex(lifecycle)
+	if lifecycle.OriginationDate > 0 {
+		resp.Age = zutils.FormatDuration(now.Sub(time.UnixMilli(lifecycle.OriginationDate)))
+	}
+	docTimeMax := index.GetDocTimeMax()
+	if docTimeMax > 0 {
+		t := time.Unix(0, docTimeMax)
+		resp.DocTimeMax = &t
+	}
+	frozen := frozenSecondShards(index)
+	var oldest int64
+	for _, shards := range frozen {
+		for _, shard := range shards {
+			if shard.Deleted {
+				resp.DeletedShards++
+				continue
+			}
+			resp.FrozenShards++
+			if shard.Stats.DocTimeMax > 0 && (oldest == 0 || shard.Stats.DocTimeMax < oldest) {
+				oldest = shard.Stats.DocTimeMax
+			}
+		}
+	}
+	resp.Error = ZINC_LIFECYCLE.getError(index.GetName())
+
+	policy, err := loadLifecyclePolicy(lifecycle.Name, policies)
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.Phase = meta.LifecyclePhaseHot
+	if lifecycle.IndexingComplete {
+		resp.Phase = meta.LifecyclePhaseWarm
+	}
+	if policy.deleteAfter == 0 {
+		return resp
+	}
+	if policy.deleteScope == meta.LifecycleDeleteScopeShard {
+		if oldest > 0 {
+			t := time.Unix(0, oldest).Add(policy.deleteAfter)
+			resp.DeleteAfter = &t
+		}
+		return resp
+	}
+	if !resp.IsWriteIndex && docTimeMax > 0 {
+		t := time.Unix(0, docTimeMax).Add(policy.deleteAfter)
+		resp.DeleteAfter = &t
+		if !t.After(now) {
+			resp.Phase = meta.LifecyclePhaseDelete
+		}
+	}
+	return resp
+}
+
+// frozenSecondShards returns a copy of the second layer shards which don't receive writes anymore, by first layer shard
+func frozenSecondShards(index *Index) map[string][]meta.IndexSecondShard {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+	shards := make(map[string][]meta.IndexSecondShard, len(index.ref.Shards))
+	for id, shard := range index.ref.Shards {
+		for i := int64(0); i < shard.ShardNum-1; i++ {
+			shards[id] = append(shards[id], *shard.Shards[i])
+		}
+	}
+	return shards
+}
+
+// deleteExpiredShards deletes the frozen second layer shards whose newest document is older than cutoff
+func deleteExpiredShards(index *Index, cutoff int64) error {
+	for id, shards := range frozenSecondShards(index) {
+		for _, shard := range shards {
+			if shard.Deleted || shard.Stats.DocTimeMax == 0 || shard.Stats.DocTimeMax >= cutoff {
+				continue
+			}
+			if err := index.shards[id].DeleteSecondShard(shard.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteLifecycleIndex deletes the index and removes it from its aliases
+func deleteLifecycleIndex(name string) error {
+	for _, alias := range ZINC_INDEX_ALIAS_LIST.GetAliasesForIndex(name) {
+		if err := ZINC_INDEX_ALIAS_LIST.RemoveIndexesFromAlias(alias, []string{name}); err != nil {
+			return err
+		}
+	}
+	if err := DeleteIndex(name, ZINC_INDEX_LIST.cfg.DataPath); err != nil {
+		return err
+	}
+	ZINC_LIFECYCLE.setError(name, nil)
+	return nil
+}
+
+// lifecyclePolicy is the parsed policy used by the scheduler
+type lifecyclePolicy struct {
+	rollover    *rolloverConditions
+	deleteAfter time.Duration
+	deleteScope string
+}
+
+// loadLifecyclePolicy returns the parsed policy, every policy is loaded once per run
+func loadLifecyclePolicy(name string, policies map[string]*lifecyclePolicy) (*lifecyclePolicy, error) {
+	if policy, ok := policies[name]; ok {
+		return policy, nil
+	}
+	p, err := GetLifecyclePolicy(name)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := parseLifecyclePolicy(p)
+	if err != nil {
+		return nil, err
+	}
+	policies[name] = policy
+	return policy, nil
+}
+
+func parseLifecyclePolicy(p *meta.LifecyclePolicy) (*lifecyclePolicy, error) {
+	if p.Rollover == nil && p.Delete == nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[lifecycle] policy should have a rollover or a delete")
+	}
+	policy := new(lifecyclePolicy)
+	if p.Rollover != nil {
+		var err error
+		if policy.rollover, err = parseRolloverConditions(p.Rollover); err != nil {
+			return nil, err
+		}
+	}
+	if p.Delete != nil {
+		var err error
+		if policy.deleteAfter, err = parseLifecycleDuration("delete.min_age", p.Delete.MinAge); err != nil {
+			return nil, err
+		}
+		switch p.Delete.Scope {
+		case "", meta.LifecycleDeleteScopeIndex:
+			policy.deleteScope = meta.LifecycleDeleteScopeIndex
+		case meta.LifecycleDeleteScopeShard:
+			policy.deleteScope = meta.LifecycleDeleteScopeShard
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("[lifecycle] delete.scope [%s] should be index or shard", p.Delete.Scope))
+		}
+	}
+	return policy, nil
+}
+
+type rolloverConditions struct {
+	maxSize    uint64
+	maxSizeRaw string
+	maxDocs    uint64
+	maxAge     time.Duration
+	maxAgeRaw  string
+}
+
+func parseRolloverConditions(r *meta.LifecycleRollover) (*rolloverConditions, error) {
+	c := &rolloverConditions{maxDocs: r.MaxDocs, maxSizeRaw: r.MaxSize, maxAgeRaw: r.MaxAge}
+	if r.MaxSize != "" {
+		size, err := units.RAMInBytes(r.MaxSize)
+		if err != nil || size <= 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[lifecycle] rollover.max_size [%s] is invalid", r.MaxSize))
+		}
+		c.maxSize = uint64(size)
+	}
+	if r.MaxAge != "" {
+		var err error
+		if c.maxAge, err = parseLifecycleDuration("rollover.max_age", r.MaxAge); err != nil {
+			return nil, err
+		}
+	}
+	if c.maxSize == 0 && c.maxDocs == 0 && c.maxAge == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[lifecycle] rollover should have max_size, max_docs or max_age")
+	}
+	return c, nil
+}
+
+// check reports every condition in results, it returns true if any is met. An empty index is never rolled over.
+func (c *rolloverConditions) check(index *Index, now time.Time, results map[string]bool) bool {
+	stats := index.GetStats()
+	met := false
+	if c.maxDocs > 0 {
+		ok := stats.DocNum >= c.maxDocs
+		results[fmt.Sprintf("[max_docs: %d]", c.maxDocs)] = ok
+		met = met || ok
+	}
+	if c.maxSize > 0 {
+		ok := stats.StorageSize >= c.maxSize
+		results[fmt.Sprintf("[max_size: %s]", c.maxSizeRaw)] = ok
+		met = met || ok
+	}
+	if c.maxAge > 0 {
+		var age time.Duration
+		if lifecycle := index.GetLifecycle(); lifecycle != nil && lifecycle.OriginationDate > 0 {
+			age = now.Sub(time.UnixMilli(lifecycle.OriginationDate))
+		}
+		ok := age >= c.maxAge
+		results[fmt.Sprintf("[max_age: %s]", c.maxAgeRaw)] = ok
+		met = met || ok
+	}
+	return met && stats.DocNum > 0
+}
+
+func parseLifecycleDuration(field, value string) (time.Duration, error) {
+	d, err := zutils.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[lifecycle] %s [%s] is invalid", field, value))
+	}
+	return d, nil
+}
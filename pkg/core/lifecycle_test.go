@@ -0,0 +1,210 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestLifecycle(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+
+	createDocuments := func(t *testing.T, index *Index, from, to int, timestamp time.Time) {
+		for i := from; i < to; i++ {
+			doc := map[string]interface{}{"num": float64(i), meta.TimeFieldName: timestamp.Format(time.RFC3339)}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index, the rollover conditions read the stats of the index
+		waitForRefresh(t, index)
+		assert.NoError(t, index.UpdateMetadata())
+	}
+	count := func(t *testing.T, name string) int {
+		resp, err := MultiSearch([]string{name}, &meta.ZincQuery{Size: 0}, cfg)
+		assert.NoError(t, err)
+		return resp.Hits.Total.Value
+	}
+
+	t.Run("policy", func(t *testing.T) {
+		invalid := []*meta.LifecyclePolicy{
+			{},
+			{Rollover: &meta.LifecycleRollover{}},
+			{Rollover: &meta.LifecycleRollover{MaxSize: "big"}},
+			{Rollover: &meta.LifecycleRollover{MaxAge: "-1h"}},
+			{Delete: &meta.LifecycleDelete{}},
+			{Delete: &meta.LifecycleDelete{MinAge: "30d", Scope: "segment"}},
+		}
+		for _, policy := range invalid {
+			assert.Error(t, PutLifecyclePolicy("lifecycle_invalid", policy))
+		}
+		assert.Error(t, PutLifecyclePolicy("_lifecycle", &meta.LifecyclePolicy{Delete: &meta.LifecycleDelete{MinAge: "1d"}}))
+
+		err := PutLifecyclePolicy("lifecycle_logs", &meta.LifecyclePolicy{
+			Rollover: &meta.LifecycleRollover{MaxDocs: 5, MaxSize: "10gb"},
+			Delete:   &meta.LifecycleDelete{MinAge: "30d"},
+		})
+		assert.NoError(t, err)
+		err = PutLifecyclePolicy("lifecycle_shards", &meta.LifecyclePolicy{
+			Delete: &meta.LifecycleDelete{MinAge: "30d", Scope: meta.LifecycleDeleteScopeShard},
+		})
+		assert.NoError(t, err)
+
+		policy, err := GetLifecyclePolicy("lifecycle_logs")
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(5), policy.Rollover.MaxDocs)
+		_, err = GetLifecyclePolicy("lifecycle_missing")
+		assert.True(t, errors.As(err, new(*errors.Error)))
+	})
+
+	alias := "lifecycle.logs"
+	var first *Index
+	t.Run("write alias", func(t *testing.T) {
+		var err error
+		first, err = NewIndex("lifecycle.logs-000001", "disk", 2, cfg)
+		assert.NoError(t, err)
+		_ = first.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{Name: "lifecycle_logs", RolloverAlias: alias}})
+		assert.NoError(t, StoreIndex(first))
+		assert.NoError(t, ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(alias, []string{first.GetName()}))
+
+		index, exists, err := GetOrCreateIndex(alias, "", 0)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, first, index)
+		createDocuments(t, index, 0, 10, old)
+
+		explain, err := ExplainLifecycle(alias, now)
+		assert.NoError(t, err)
+		assert.True(t, explain[first.GetName()].Managed)
+		assert.True(t, explain[first.GetName()].IsWriteIndex)
+		assert.Equal(t, meta.LifecyclePhaseHot, explain[first.GetName()].Phase)
+		assert.Nil(t, explain[first.GetName()].DeleteAfter)
+
+		err = DeleteLifecyclePolicy("lifecycle_logs")
+		assert.Error(t, err)
+	})
+
+	t.Run("rollover conditions", func(t *testing.T) {
+		resp, err := Rollover(alias, &meta.LifecycleRollover{MaxDocs: 1000}, now)
+		assert.NoError(t, err)
+		assert.False(t, resp.RolledOver)
+		assert.Equal(t, "lifecycle.logs-000002", resp.NewIndex)
+		assert.Equal(t, map[string]bool{"[max_docs: 1000]": false}, resp.Conditions)
+
+		_, err = Rollover("lifecycle.alias_missing", nil, now)
+		assert.Error(t, err)
+	})
+
+	t.Run("apply rollover and delete", func(t *testing.T) {
+		ZINC_LIFECYCLE.Apply(now)
+
+		_, exists := GetIndex(first.GetName())
+		assert.False(t, exists)
+		second, exists := GetIndex("lifecycle.logs-000002")
+		assert.True(t, exists)
+		names, _ := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(alias)
+		assert.Equal(t, []string{second.GetName()}, names)
+
+		index, exists, err := GetOrCreateIndex(alias, "", 0)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, second, index)
+		assert.Equal(t, "lifecycle_logs", second.GetLifecycle().Name)
+
+		explain, err := ExplainLifecycle("lifecycle.logs-*", now)
+		assert.NoError(t, err)
+		assert.Len(t, explain, 1)
+		assert.True(t, explain[second.GetName()].IsWriteIndex)
+		assert.Empty(t, explain[second.GetName()].Error)
+	})
+
+	t.Run("explain expired", func(t *testing.T) {
+		resp, err := Rollover(alias, nil, now)
+		assert.NoError(t, err)
+		assert.True(t, resp.RolledOver)
+
+		second, _ := GetIndex(resp.OldIndex)
+		createDocuments(t, second, 0, 3, old)
+		explain, err := ExplainLifecycle(second.GetName(), now)
+		assert.NoError(t, err)
+		assert.Equal(t, meta.LifecyclePhaseDelete, explain[second.GetName()].Phase)
+		assert.False(t, explain[second.GetName()].IsWriteIndex)
+		assert.NotNil(t, explain[second.GetName()].DeleteAfter)
+
+		_, err = ExplainLifecycle("lifecycle.index_missing", now)
+		assert.Error(t, err)
+	})
+
+	t.Run("delete frozen shards", func(t *testing.T) {
+		index, err := NewIndex("lifecycle.shards", "disk", 1, cfg)
+		assert.NoError(t, err)
+		_ = index.SetSettings(&meta.IndexSettings{Lifecycle: &meta.IndexLifecycle{Name: "lifecycle_shards"}})
+		assert.NoError(t, StoreIndex(index))
+
+		createDocuments(t, index, 0, 5, old)
+		for _, shard := range index.shards {
+			assert.NoError(t, shard.NewShard())
+			assert.Error(t, shard.DeleteSecondShard(shard.GetLatestShardID()))
+		}
+		createDocuments(t, index, 5, 8, now)
+		assert.Equal(t, 8, count(t, index.GetName()))
+
+		explain, err := ExplainLifecycle(index.GetName(), now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, explain[index.GetName()].FrozenShards)
+		assert.True(t, explain[index.GetName()].DeleteAfter.Before(now))
+
+		ZINC_LIFECYCLE.Apply(now)
+		assert.Equal(t, 3, count(t, index.GetName()))
+		// the expired index rolled over before is deleted by the same run
+		_, exists := GetIndex("lifecycle.logs-000002")
+		assert.False(t, exists)
+		explain, err = ExplainLifecycle(index.GetName(), now)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, explain[index.GetName()].FrozenShards)
+		assert.Equal(t, 1, explain[index.GetName()].DeletedShards)
+		assert.Nil(t, explain[index.GetName()].DeleteAfter)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		names, err := RemoveLifecycle("lifecycle.*")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"lifecycle.logs-000003", "lifecycle.shards"}, names)
+		explain, err := ExplainLifecycle("lifecycle.*", now)
+		assert.NoError(t, err)
+		for _, e := range explain {
+			assert.False(t, e.Managed)
+		}
+		assert.NoError(t, DeleteLifecyclePolicy("lifecycle_logs"))
+		assert.NoError(t, DeleteLifecyclePolicy("lifecycle_shards"))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		for _, name := range []string{"lifecycle.logs-000003", "lifecycle.shards"} {
+			assert.NoError(t, DeleteIndex(name, cfg.DataPath))
+		}
+		_ = ZINC_INDEX_ALIAS_LIST.RemoveIndexesFromAlias(alias, []string{"lifecycle.logs-000003"})
+	})
+}
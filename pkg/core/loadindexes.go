@@ -35,13 +35,6 @@ func LoadZincIndexesFromMetadata(version string, cfg *config.Config) error {
 
 	for i := range indexes {
 		readIndex := indexes[i]
-		index := new(Index)
-		index.ref = new(meta.Index)
-		index.ref.Name = readIndex.Name
-		index.ref.StorageType = readIndex.StorageType
-		index.ref.Settings = readIndex.Settings
-		index.ref.Mappings = readIndex.Mappings
-		index.ref.Stats = readIndex.Stats
 
 		// upgrade from old version
 		if readIndex.Version != "" {
@@ -63,67 +56,89 @@ func LoadZincIndexesFromMetadata(version string, cfg *config.Config) error {
 			}
 		}
 
-		// init shards
-		index.ref.ShardNum = readIndex.ShardNum
-		index.ref.Shards = make(map[string]*meta.IndexShard, index.shardNum)
-		for id := range readIndex.Shards {
-			index.ref.Shards[id] = &meta.IndexShard{
-				ID:       readIndex.Shards[id].ID,
-				ShardNum: readIndex.Shards[id].ShardNum,
-				Stats:    readIndex.Shards[id].Stats,
-			}
-			index.ref.Shards[id].Shards = make([]*meta.IndexSecondShard, index.ref.Shards[id].ShardNum)
-			for j := range readIndex.Shards[id].Shards {
-				index.ref.Shards[id].Shards[j] = &meta.IndexSecondShard{
-					ID:    readIndex.Shards[id].Shards[j].ID,
-					Stats: readIndex.Shards[id].Shards[j].Stats,
-				}
-			}
+		index, err := loadIndex(readIndex, cfg)
+		if err != nil {
+			return err
 		}
 
-		// init shards wrapper
-		totalShardNum := 0
-		index.shardNum = index.ref.ShardNum
-		index.shards = make(map[string]*IndexShard, index.shardNum)
-		for id := range index.ref.Shards {
-			index.shards[id] = &IndexShard{
-				root:             index,
-				ref:              index.ref.Shards[id],
-				name:             index.ref.Name + "/" + index.ref.Shards[id].ID,
-				dataPath:         cfg.DataPath,
-				walRedoLogNoSync: cfg.WalRedoLogNoSync,
-				batchSize:        cfg.BatchSize,
-				maxSize:          cfg.Shard.MaxSize,
-			}
-			index.shards[id].shards = make([]*IndexSecondShard, index.ref.Shards[id].ShardNum)
-			for j := range index.ref.Shards[id].Shards {
-				index.shards[id].shards[j] = &IndexSecondShard{
-					root: index,
-					ref:  index.ref.Shards[id].Shards[j],
-				}
-				totalShardNum++
+		// load in memory
+		ZINC_INDEX_LIST.Add(index)
+	}
+
+	return nil
+}
+
+// loadIndex creates the index from the stored metadata, the shards are opened when used
+func loadIndex(readIndex *meta.Index, cfg *config.Config) (*Index, error) {
+	index := new(Index)
+	index.ref = new(meta.Index)
+	index.ref.Name = readIndex.Name
+	index.ref.StorageType = readIndex.StorageType
+	index.ref.Settings = readIndex.Settings
+	index.ref.Mappings = readIndex.Mappings
+	index.ref.Stats = readIndex.Stats
+	index.ref.Stats.OpenPITs = 0
+	index.ref.DataStream = readIndex.DataStream
+
+	// init shards
+	index.ref.ShardNum = readIndex.ShardNum
+	index.ref.Shards = make(map[string]*meta.IndexShard, index.shardNum)
+	for id := range readIndex.Shards {
+		index.ref.Shards[id] = &meta.IndexShard{
+			ID:       readIndex.Shards[id].ID,
+			ShardNum: readIndex.Shards[id].ShardNum,
+			Stats:    readIndex.Shards[id].Stats,
+		}
+		index.ref.Shards[id].Shards = make([]*meta.IndexSecondShard, index.ref.Shards[id].ShardNum)
+		for j := range readIndex.Shards[id].Shards {
+			index.ref.Shards[id].Shards[j] = &meta.IndexSecondShard{
+				ID:      readIndex.Shards[id].Shards[j].ID,
+				Stats:   readIndex.Shards[id].Shards[j].Stats,
+				Deleted: readIndex.Shards[id].Shards[j].Deleted,
 			}
 		}
+	}
 
-		// init shards hashing
-		index.shardHashing = rendezvous.New()
-		for id := range index.shards {
-			index.shardHashing.Add(id)
+	// init shards wrapper
+	totalShardNum := 0
+	index.shardNum = index.ref.ShardNum
+	index.shards = make(map[string]*IndexShard, index.shardNum)
+	for id := range index.ref.Shards {
+		index.shards[id] = &IndexShard{
+			root:             index,
+			ref:              index.ref.Shards[id],
+			name:             index.ref.Name + "/" + index.ref.Shards[id].ID,
+			dataPath:         cfg.DataPath,
+			walRedoLogNoSync: cfg.WalRedoLogNoSync,
+			batchSize:        cfg.BatchSize,
+			maxSize:          cfg.Shard.MaxSize,
 		}
-
-		log.Info().Msgf("Loading  index... [%s:%s] shards[%d:%d]", index.ref.Name, index.ref.StorageType, index.ref.ShardNum, totalShardNum)
-
-		// load index analysis
-		if index.ref.Settings != nil && index.ref.Settings.Analysis != nil {
-			index.analyzers, err = zincanalysis.RequestAnalyzer(index.ref.Settings.Analysis)
-			if err != nil {
-				return errors.New(errors.ErrorTypeRuntimeException, "parse stored analysis error").Cause(err)
+		index.shards[id].shards = make([]*IndexSecondShard, index.ref.Shards[id].ShardNum)
+		for j := range index.ref.Shards[id].Shards {
+			index.shards[id].shards[j] = &IndexSecondShard{
+				root: index,
+				ref:  index.ref.Shards[id].Shards[j],
 			}
+			totalShardNum++
 		}
+	}
 
-		// load in memory
-		ZINC_INDEX_LIST.Add(index)
+	// init shards hashing
+	index.shardHashing = rendezvous.New()
+	for id := range index.shards {
+		index.shardHashing.Add(id)
 	}
 
-	return nil
+	log.Info().Msgf("Loading  index... [%s:%s] shards[%d:%d]", index.ref.Name, index.ref.StorageType, index.ref.ShardNum, totalShardNum)
+
+	// load index analysis
+	if index.ref.Settings != nil && index.ref.Settings.Analysis != nil {
+		var err error
+		index.analyzers, err = zincanalysis.RequestAnalyzer(index.ref.Settings.Analysis)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeRuntimeException, "parse stored analysis error").Cause(err)
+		}
+	}
+
+	return index, nil
 }
@@ -17,7 +17,6 @@ package core
 
 import (
 	"context"
-	"fmt"
 	"strings"
 	"time"
 
@@ -33,55 +32,63 @@ import (
 )
 
 func MultiSearch(indexNames []string, query *meta.ZincQuery, cfg *config.Config) (*meta.SearchResponse, error) {
-	var mappings *meta.Mappings
-	var analyzers map[string]*analysis.Analyzer
-	var readers []*bluge.Reader
-	var shardNum int64
-
 	timeMin, timeMax := timerange.Query(query.Query)
-	isMatched := false
-	hasIndex := false
-	for _, index := range ZINC_INDEX_LIST.List() {
-		if len(indexNames) > 0 {
-			for _, indexName := range indexNames {
-				isMatched = isMatchIndex(index.GetName(), indexName)
-				if isMatched {
-					hasIndex = true
-					break
-				}
-			}
-			if !isMatched {
-				continue
-			}
-		}
+	r, err := getReadersForIndexes(indexNames, timeMin, timeMax, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer r.close()
+
+	return r.search(query, cfg)
+}
 
+// indexReaders is a snapshot of the readers of the matched indexes,
+// the mappings and analyzers are from the first matched index.
+type indexReaders struct {
+	indexes   []*Index
+	readers   []*bluge.Reader
+	shardNum  int64
+	shards    map[string]meta.Shards
+	mappings  *meta.Mappings
+	analyzers map[string]*analysis.Analyzer
+}
+
+// getReadersForIndexes returns the readers of all the indexes resolved from indexNames
+func getReadersForIndexes(indexNames []string, timeMin, timeMax int64, cfg *config.Config) (*indexReaders, error) {
+	names, err := ResolveIndexNames(indexNames, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	r := &indexReaders{shards: make(map[string]meta.Shards, len(names))}
+	for _, name := range names {
+		index, ok := GetIndex(name)
+		if !ok {
+			continue
+		}
 		reader, err := index.GetReaders(timeMin, timeMax, cfg.Shard.GoroutineNum)
 		if err != nil {
+			r.close()
 			return nil, err
 		}
-		readers = append(readers, reader...)
-		shardNum += index.GetShardNum()
-		if mappings == nil {
-			mappings = index.GetMappings()
-			analyzers = index.GetAnalyzers()
+		r.indexes = append(r.indexes, index)
+		r.readers = append(r.readers, reader...)
+		r.shardNum += index.GetShardNum()
+		r.shards[name] = meta.Shards{Total: index.GetShardNum(), Successful: int64(len(reader)), Skipped: index.GetShardNum() - int64(len(reader))}
+		if r.mappings == nil {
+			r.mappings = index.GetMappings()
+			r.analyzers = index.GetAnalyzers()
 		}
-
 	}
+	return r, nil
+}
 
-	if len(readers) == 0 {
-		if !hasIndex {
-			return nil, fmt.Errorf("core.MultiSearchV2: error accessing reader: no index found")
-		}
+// search executes the query on the readers, the readers are kept open
+func (r *indexReaders) search(query *meta.ZincQuery, cfg *config.Config) (*meta.SearchResponse, error) {
+	if len(r.readers) == 0 {
 		return &meta.SearchResponse{}, nil
 	}
 
-	defer func() {
-		for _, reader := range readers {
-			reader.Close()
-		}
-	}()
-
-	_, err := uquery.ParseQueryDSL(query, mappings, analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
+	_, err := uquery.ParseQueryDSL(query, r.mappings, r.analyzers, cfg.MaxResults, cfg.AggregationTermsSize)
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +101,7 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery, cfg *config.Config)
 	}
 
 	// dmi, err := bluge.MultiSearch(ctx, searchRequest, readers...)
-	dmi, err := zincsearch.MultiSearch(ctx, query, mappings, analyzers, cfg, readers...)
+	dmi, err := zincsearch.MultiSearch(ctx, query, r.mappings, r.analyzers, cfg, r.readers...)
 	if err != nil {
 		log.Printf("core.MultiSearchV2: error executing search: %s", err.Error())
 		if err == context.DeadlineExceeded {
@@ -107,12 +114,24 @@ func MultiSearch(indexNames []string, query *meta.ZincQuery, cfg *config.Config)
 		return nil, err
 	}
 
-	return searchV2(shardNum, int64(len(readers)), dmi, query, mappings)
+	resp, err := searchV2(r.shardNum, int64(len(r.readers)), dmi, query, r.mappings)
+	if err == nil && len(r.indexes) > 1 {
+		resp.Shards.Indices = r.shards
+	}
+	return resp, err
+}
+
+func (r *indexReaders) close() {
+	for _, reader := range r.readers {
+		reader.Close()
+	}
+	r.readers = nil
 }
 
 // isMatchIndex("abc", "a")  false
 // isMatchIndex("abc", "a*") true
 // isMatchIndex("abc", "*bc") true
+// isMatchIndex("abc", "a?c") true
 // isMatchIndex("abc", "bc") false
 // isMatchIndex("abc", "abc") true
 func isMatchIndex(zincIndexName, indexName string) bool {
@@ -120,15 +139,37 @@ func isMatchIndex(zincIndexName, indexName string) bool {
 		return true
 	}
 
-	// eg.: *-test
-	if strings.HasPrefix(indexName, "*") {
-		return strings.HasSuffix(zincIndexName, indexName[1:])
-	}
-
-	// eg.: test-*
-	if strings.HasSuffix(indexName, "*") {
-		return strings.HasPrefix(zincIndexName, indexName[:len(indexName)-1])
+	// eg.: test-*, *-test, logs-2022.??.*
+	if strings.ContainsAny(indexName, "*?") {
+		return matchWildcard(indexName, zincIndexName)
 	}
 
 	return zincIndexName == indexName
 }
+
+// matchWildcard matches s against a pattern where * is any sequence and ? is any single character
+func matchWildcard(pattern, s string) bool {
+	p, i := 0, 0
+	star, next := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, i
+			p++
+		case star >= 0:
+			// let the last * eat one more character
+			p = star + 1
+			next++
+			i = next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
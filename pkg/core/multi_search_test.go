@@ -135,4 +135,12 @@ func TestIsMatchIndex(t *testing.T) {
 	assert.False(t, ret)
 	ret = isMatchIndex("abc", "abc") // true
 	assert.True(t, ret)
+	ret = isMatchIndex("abc", "a?c") // true
+	assert.True(t, ret)
+	ret = isMatchIndex("abc", "a*b*c") // true
+	assert.True(t, ret)
+	ret = isMatchIndex("abc", "?c") // false
+	assert.False(t, ret)
+	ret = isMatchIndex("indices:data/write/bulk", "indices:data/*") // true
+	assert.True(t, ret)
 }
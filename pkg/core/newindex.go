@@ -183,6 +183,16 @@ func GetIndex(name string) (*Index, bool) {
 	return ZINC_INDEX_LIST.Get(name)
 }
 
+// GetOrCreateIndex returns the index, or creates it with the templates. The name of a rollover alias
+// or a data stream returns its write index, a name matching a data stream template creates the data stream.
 func GetOrCreateIndex(name, storageType string, shardNum int64) (*Index, bool, error) {
+	if index, ok := GetWriteIndex(name); ok {
+		return index, true, nil
+	}
+	if _, ok := GetIndex(name); !ok {
+		if index, ok, err := autoCreateDataStream(name); ok || err != nil {
+			return index, false, err
+		}
+	}
 	return ZINC_INDEX_LIST.GetOrCreate(name, storageType, shardNum)
 }
@@ -0,0 +1,180 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/ingest"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+)
+
+// PipelineNone disables the default pipeline of the index
+const PipelineNone = "_none"
+
+// ListPipelines returns all the ingest pipelines
+func ListPipelines() ([]*meta.Pipeline, error) {
+	pipelines, err := metadata.Pipeline.List(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if pipelines == nil {
+		pipelines = make([]*meta.Pipeline, 0)
+	}
+	return pipelines, nil
+}
+
+// GetPipeline returns the pipeline, a resource_not_found_exception if it doesn't exist
+func GetPipeline(name string) (*meta.Pipeline, error) {
+	pipeline, err := metadata.Pipeline.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("pipeline [%s] is missing", name))
+		}
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// PutPipeline creates or updates the pipeline, the processors are checked before it is stored
+func PutPipeline(name string, pipeline *meta.Pipeline) error {
+	if err := checkResourceName("pipeline", name); err != nil {
+		return err
+	}
+	if _, err := ingest.New(name, pipeline); err != nil {
+		return err
+	}
+	pipeline.Name = name
+	pipeline.CreatedAt = time.Now()
+	pipeline.UpdatedAt = pipeline.CreatedAt
+	if old, err := metadata.Pipeline.Get(name); err == nil {
+		pipeline.CreatedAt = old.CreatedAt
+	}
+	return metadata.Pipeline.Set(name, *pipeline)
+}
+
+// DeletePipeline deletes the pipeline
+func DeletePipeline(name string) error {
+	if _, err := GetPipeline(name); err != nil {
+		return err
+	}
+	return metadata.Pipeline.Delete(name)
+}
+
+// IngestPipelines runs the ingest pipelines of a write request, every pipeline is loaded once per request
+type IngestPipelines struct {
+	pipeline  string
+	pipelines map[string]*ingest.Pipeline
+}
+
+// NewIngestPipelines returns the pipelines of a request, the pipeline given by the request
+// is used for all the documents, the default pipeline of the index is used if it is empty.
+func NewIngestPipelines(pipeline string) *IngestPipelines {
+	return &IngestPipelines{pipeline: pipeline, pipelines: make(map[string]*ingest.Pipeline)}
+}
+
+// Process runs the pipeline for the document, pipeline overrides the pipeline of the request.
+// It returns the index to write because the pipeline can change the _index, a nil document means it is dropped.
+func (p *IngestPipelines) Process(index *Index, pipeline, docID string, source map[string]interface{}) (*Index, *ingest.Document, error) {
+	doc := ingest.NewDocument(index.GetName(), docID, source)
+	if pipeline == "" {
+		pipeline = p.pipeline
+	}
+	if pipeline == "" {
+		pipeline = index.GetDefaultPipeline()
+	}
+	if pipeline == "" || pipeline == PipelineNone {
+		return index, doc, nil
+	}
+
+	compiled, ok := p.pipelines[pipeline]
+	if !ok {
+		def, err := metadata.Pipeline.Get(pipeline)
+		if err != nil {
+			if err == errors.ErrKeyNotFound {
+				return nil, nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("pipeline with id [%s] does not exist", pipeline))
+			}
+			return nil, nil, err
+		}
+		if compiled, err = ingest.New(pipeline, def); err != nil {
+			return nil, nil, err
+		}
+		p.pipelines[pipeline] = compiled
+	}
+	if err := compiled.Run(doc); err != nil {
+		return nil, nil, err
+	}
+	if doc.Dropped() {
+		return index, nil, nil
+	}
+	if doc.Index != index.GetName() {
+		if err := CheckIndexName(doc.Index); err != nil {
+			return nil, nil, errors.New(errors.ErrorTypeIllegalArgumentException, err.Error())
+		}
+		var err error
+		if index, _, err = GetOrCreateIndex(doc.Index, "", 0); err != nil {
+			return nil, nil, err
+		}
+	}
+	return index, doc, nil
+}
+
+// SimulatePipeline runs the pipeline for the docs without writing them
+func SimulatePipeline(name string, req *meta.PipelineSimulateRequest) (*meta.PipelineSimulateResponse, error) {
+	def := req.Pipeline
+	if name != "" {
+		var err error
+		if def, err = GetPipeline(name); err != nil {
+			return nil, err
+		}
+	}
+	if def == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[pipeline] is required")
+	}
+	pipeline, err := ingest.New(name, def)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &meta.PipelineSimulateResponse{Docs: make([]meta.PipelineSimulateResult, 0, len(req.Docs))}
+	for _, v := range req.Docs {
+		if v.Index == "" {
+			v.Index = "_index"
+		}
+		if v.ID == "" {
+			v.ID = "_id"
+		}
+		doc := ingest.NewDocument(v.Index, v.ID, v.Source)
+		if err := pipeline.Run(doc); err != nil {
+			resp.Docs = append(resp.Docs, meta.PipelineSimulateResult{Error: err})
+			continue
+		}
+		if doc.Dropped() {
+			resp.Docs = append(resp.Docs, meta.PipelineSimulateResult{})
+			continue
+		}
+		resp.Docs = append(resp.Docs, meta.PipelineSimulateResult{Doc: &meta.PipelineSimulateDoc{
+			Index:  doc.Index,
+			ID:     doc.ID,
+			Source: doc.Source,
+			Ingest: doc.Ingest(),
+		}})
+	}
+	return resp, nil
+}
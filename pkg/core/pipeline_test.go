@@ -0,0 +1,90 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestIngestPipelines(t *testing.T) {
+	indexName := "pipeline.index_1"
+	cfg := config.NewGlobalConfig()
+
+	var index *Index
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk", 1, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, index.SetSettings(&meta.IndexSettings{DefaultPipeline: "pipeline.default"}))
+		assert.NoError(t, StoreIndex(index))
+
+		err = PutPipeline("pipeline.default", &meta.Pipeline{Processors: []map[string]interface{}{
+			{"set": map[string]interface{}{"field": "by", "value": "default"}},
+			{"drop": map[string]interface{}{"if": map[string]interface{}{"field": "skip", "exists": true}}},
+		}})
+		assert.NoError(t, err)
+		err = PutPipeline("pipeline.reroute", &meta.Pipeline{Processors: []map[string]interface{}{
+			{"set": map[string]interface{}{"field": "_index", "value": "pipeline.index_{{target}}"}},
+			{"set": map[string]interface{}{"field": "_id", "value": "{{id}}"}},
+		}})
+		assert.NoError(t, err)
+		err = PutPipeline("pipeline.invalid", &meta.Pipeline{Processors: []map[string]interface{}{{"set": map[string]interface{}{}}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("default pipeline", func(t *testing.T) {
+		pipelines := NewIngestPipelines("")
+		target, doc, err := pipelines.Process(index, "", "1", map[string]interface{}{"a": "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, index, target)
+		assert.Equal(t, "default", doc.Source["by"])
+
+		_, doc, err = pipelines.Process(index, "", "2", map[string]interface{}{"skip": true})
+		assert.NoError(t, err)
+		assert.Nil(t, doc)
+
+		// the default pipeline is disabled
+		_, doc, err = NewIngestPipelines(PipelineNone).Process(index, "", "3", map[string]interface{}{"a": "b"})
+		assert.NoError(t, err)
+		assert.Nil(t, doc.Source["by"])
+	})
+
+	t.Run("reroute", func(t *testing.T) {
+		target, doc, err := NewIngestPipelines("pipeline.reroute").Process(index, "", "1", map[string]interface{}{"target": "2", "id": "x"})
+		assert.NoError(t, err)
+		assert.Equal(t, "pipeline.index_2", target.GetName())
+		assert.Equal(t, "x", doc.ID)
+		assert.NoError(t, DeleteIndex("pipeline.index_2", cfg.DataPath))
+	})
+
+	t.Run("missing pipeline", func(t *testing.T) {
+		_, _, err := NewIngestPipelines("").Process(index, "pipeline.missing", "1", map[string]interface{}{})
+		assert.Error(t, err)
+		_, err = GetPipeline("pipeline.missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		assert.NoError(t, DeletePipeline("pipeline.default"))
+		assert.NoError(t, DeletePipeline("pipeline.reroute"))
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+	})
+}
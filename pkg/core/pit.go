@@ -0,0 +1,187 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// MaxPITKeepAlive is the max time a point in time can keep the readers open between two requests
+const MaxPITKeepAlive = 24 * time.Hour
+
+var ZINC_PIT_LIST = &PITList{pits: make(map[string]*PIT)}
+
+type PITList struct {
+	pits map[string]*PIT
+	lock sync.RWMutex
+}
+
+// PIT pins the readers of all the shards of the indexes,
+// every search with the id sees the documents at the time it was opened.
+type PIT struct {
+	*indexReaders
+	id     string
+	timer  *time.Timer
+	closed bool
+	lock   sync.RWMutex
+}
+
+// Open pins the readers of the indexes and returns the id of the point in time
+func (t *PITList) Open(indexNames []string, keepAlive time.Duration, cfg *config.Config) (string, error) {
+	if err := checkPITKeepAlive(keepAlive); err != nil {
+		return "", err
+	}
+	r, err := getReadersForIndexes(indexNames, 0, 0, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	p := &PIT{indexReaders: r, id: newSearchContextID()}
+	for _, index := range r.indexes {
+		index.addOpenPITs(1)
+	}
+
+	p.lock.Lock()
+	t.lock.Lock()
+	t.pits[p.id] = p
+	t.lock.Unlock()
+	p.timer = time.AfterFunc(keepAlive, func() { t.Delete(p.id) })
+	p.lock.Unlock()
+	return p.id, nil
+}
+
+// Search executes the query on the readers of the point in time in query.PIT,
+// a keep alive in the query extends the point in time.
+func (t *PITList) Search(query *meta.ZincQuery, cfg *config.Config) (*meta.SearchResponse, error) {
+	if query.PIT == nil || query.PIT.ID == "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[pit] id is required")
+	}
+	var keepAlive time.Duration
+	if query.PIT.KeepAlive != "" {
+		var err error
+		if keepAlive, err = zutils.ParseDuration(query.PIT.KeepAlive); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[pit] keep_alive "+err.Error())
+		}
+		if err = checkPITKeepAlive(keepAlive); err != nil {
+			return nil, err
+		}
+	}
+
+	t.lock.RLock()
+	p, ok := t.pits[query.PIT.ID]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", query.PIT.ID))
+	}
+
+	// searches on the same point in time run concurrently, closing waits for them
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", query.PIT.ID))
+	}
+	if keepAlive > 0 {
+		p.timer.Reset(keepAlive)
+	}
+
+	resp, err := p.search(query, cfg)
+	if err != nil {
+		return nil, err
+	}
+	resp.PitID = p.id
+	return resp, nil
+}
+
+// IndexNames returns the names of the indexes pinned by the point in time
+func (t *PITList) IndexNames(id string) ([]string, bool) {
+	t.lock.RLock()
+	p, ok := t.pits[id]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	names := make([]string, 0, len(p.indexes))
+	for _, index := range p.indexes {
+		names = append(names, index.GetName())
+	}
+	return names, true
+}
+
+// Delete closes the point in times and returns the number of freed point in times
+func (t *PITList) Delete(ids ...string) int {
+	pits := make([]*PIT, 0, len(ids))
+	t.lock.Lock()
+	for _, id := range ids {
+		if p, ok := t.pits[id]; ok {
+			pits = append(pits, p)
+			delete(t.pits, id)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, p := range pits {
+		p.lock.Lock()
+		p.timer.Stop()
+		p.close()
+		p.lock.Unlock()
+	}
+	return len(pits)
+}
+
+// DeleteAll closes all the point in times and returns the number of freed point in times
+func (t *PITList) DeleteAll() int {
+	t.lock.RLock()
+	ids := make([]string, 0, len(t.pits))
+	for id := range t.pits {
+		ids = append(ids, id)
+	}
+	t.lock.RUnlock()
+	return t.Delete(ids...)
+}
+
+func (t *PITList) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.pits)
+}
+
+func (p *PIT) close() {
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, index := range p.indexes {
+		index.addOpenPITs(-1)
+	}
+	p.indexReaders.close()
+}
+
+func checkPITKeepAlive(keepAlive time.Duration) error {
+	if keepAlive <= 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[keep_alive] should be positive")
+	}
+	if keepAlive > MaxPITKeepAlive {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[keep_alive] [%s] is too large, the max is [%s]", keepAlive, MaxPITKeepAlive))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestPITList(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "PIT.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	t.Run("search point in time", func(t *testing.T) {
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, time.Minute, cfg)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, int64(1), index.GetStats().OpenPITs)
+
+		// documents indexed after opening are not visible in the point in time
+		for i := 10; i < 15; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		waitForRefresh(t, index)
+
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:  []interface{}{"num"},
+			Size:  6,
+			PIT:   &meta.PointInTime{ID: id, KeepAlive: "1m"},
+		}
+		resp, err := ZINC_PIT_LIST.Search(query, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, id, resp.PitID)
+		assert.Equal(t, 10, resp.Hits.Total.Value)
+		assert.Len(t, resp.Hits.Hits, 6)
+
+		// walk the next page with search_after
+		query = &meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"num"},
+			Size:        6,
+			PIT:         &meta.PointInTime{ID: id},
+			SearchAfter: resp.Hits.Hits[5].Sort,
+		}
+		resp, err = ZINC_PIT_LIST.Search(query, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 4)
+		assert.Equal(t, "6", resp.Hits.Hits[0].ID)
+
+		// a normal search sees the new documents
+		resp, err = MultiSearch([]string{indexName}, &meta.ZincQuery{Size: 20}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, 15, resp.Hits.Total.Value)
+
+		assert.Equal(t, 1, ZINC_PIT_LIST.Delete(id))
+		assert.Equal(t, int64(0), index.GetStats().OpenPITs)
+		_, err = ZINC_PIT_LIST.Search(&meta.ZincQuery{Size: 10, PIT: &meta.PointInTime{ID: id}}, cfg)
+		assert.Error(t, err)
+		assert.Equal(t, 0, ZINC_PIT_LIST.Delete(id))
+	})
+
+	t.Run("point in time expired", func(t *testing.T) {
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, 10*time.Millisecond, cfg)
+		assert.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		_, err = ZINC_PIT_LIST.Search(&meta.ZincQuery{Size: 10, PIT: &meta.PointInTime{ID: id}}, cfg)
+		assert.Error(t, err)
+		assert.Equal(t, 0, ZINC_PIT_LIST.Len())
+		assert.Equal(t, int64(0), index.GetStats().OpenPITs)
+	})
+
+	t.Run("point in time with error params", func(t *testing.T) {
+		_, err := ZINC_PIT_LIST.Open([]string{indexName}, 0, cfg)
+		assert.Error(t, err)
+		_, err = ZINC_PIT_LIST.Open([]string{indexName}, MaxPITKeepAlive+time.Second, cfg)
+		assert.Error(t, err)
+		_, err = ZINC_PIT_LIST.Open([]string{"PIT.index_not_exists"}, time.Minute, cfg)
+		assert.Error(t, err)
+		_, err = ZINC_PIT_LIST.Search(&meta.ZincQuery{Size: 10}, cfg)
+		assert.Error(t, err)
+
+		id, err := ZINC_PIT_LIST.Open([]string{indexName}, time.Minute, cfg)
+		assert.NoError(t, err)
+		_, err = ZINC_PIT_LIST.Search(&meta.ZincQuery{Size: 10, PIT: &meta.PointInTime{ID: id, KeepAlive: "xx"}}, cfg)
+		assert.Error(t, err)
+		assert.Equal(t, 1, ZINC_PIT_LIST.DeleteAll())
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,168 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+const (
+	ReindexAction          = "indices:data/write/reindex"
+	ReindexDefaultSize     = 1000
+	reindexCanceledMessage = "by user request"
+)
+
+// Reindex copies the documents matched by the query from the source indexes into the destination index
+type Reindex struct {
+	req               *meta.ReindexRequest
+	sources           []string
+	requestsPerSecond float64
+}
+
+// NewReindex checks the request, requestsPerSecond throttles the copy, zero or negative means unlimited
+func NewReindex(req *meta.ReindexRequest, requestsPerSecond float64) (*Reindex, error) {
+	var sources []string
+	switch v := req.Source.Index.(type) {
+	case string:
+		sources = []string{v}
+	case []interface{}:
+		for _, name := range v {
+			s, ok := name.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[reindex] source.index should be a string or an array of strings")
+			}
+			sources = append(sources, s)
+		}
+	case nil:
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[reindex] source.index should be a string or an array of strings")
+	}
+	if len(sources) == 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[reindex] source.index is missing")
+	}
+	indexes, err := resolveIndexes(strings.Join(sources, ","), meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if req.Dest.Index == "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[reindex] dest.index is missing")
+	}
+
+	r := &Reindex{req: req, requestsPerSecond: requestsPerSecond}
+	dest := req.Dest.Index
+	if index, ok := GetWriteIndex(dest); ok {
+		dest = index.GetName()
+	}
+	for _, index := range indexes {
+		if index.GetName() == dest {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[reindex] cannot reindex from index [%s] into itself", dest))
+		}
+		r.sources = append(r.sources, index.GetName())
+	}
+
+	switch req.Dest.OpType {
+	case "":
+		req.Dest.OpType = meta.ReindexOpTypeIndex
+	case meta.ReindexOpTypeIndex, meta.ReindexOpTypeCreate:
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[reindex] dest.op_type [%s] is invalid, it should be index or create", req.Dest.OpType))
+	}
+	switch req.Conflicts {
+	case "":
+		req.Conflicts = meta.ReindexConflictsAbort
+	case meta.ReindexConflictsAbort, meta.ReindexConflictsProceed:
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[reindex] conflicts [%s] is invalid, it should be abort or proceed", req.Conflicts))
+	}
+	if v, ok := req.Source.Source.(bool); ok && !v {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[reindex] _source can't be disabled")
+	}
+	if req.Source.Size < 0 || req.MaxDocs < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[reindex] source.size and max_docs should be positive")
+	}
+	if req.Source.Size == 0 {
+		req.Source.Size = ReindexDefaultSize
+	}
+	return r, nil
+}
+
+// Description describes the reindex for the task
+func (r *Reindex) Description() string {
+	return fmt.Sprintf("reindex from [%s] to [%s]", strings.Join(r.sources, ","), r.req.Dest.Index)
+}
+
+// Run copies the documents batch by batch, the progress is reported to the task,
+// it stops before the next document when the task is cancelled.
+func (r *Reindex) Run(task *Task, cfg *config.Config) (*meta.ReindexResponse, error) {
+	dest, _, err := GetOrCreateIndex(r.req.Dest.Index, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	pipelines := NewIngestPipelines(r.req.Dest.Pipeline)
+
+	b := &batchTask{
+		indexes: r.sources,
+		query: &meta.ZincQuery{
+			Query:          r.req.Source.Query,
+			Source:         r.req.Source.Source,
+			Size:           r.req.Source.Size,
+			TrackTotalHits: true,
+		},
+		maxDocs:           r.req.MaxDocs,
+		conflicts:         r.req.Conflicts,
+		requestsPerSecond: r.requestsPerSecond,
+	}
+	return b.run(task, cfg, func(hit meta.Hit) (string, string, error) {
+		return r.copyDocument(dest, pipelines, hit, cfg)
+	})
+}
+
+// copyDocument writes the hit into the destination, it returns the result and the index written
+func (r *Reindex) copyDocument(dest *Index, pipelines *IngestPipelines, hit meta.Hit, cfg *config.Config) (string, string, error) {
+	source, _ := hit.Source.(map[string]interface{})
+	if source == nil {
+		source = make(map[string]interface{})
+	}
+	source[meta.TimeFieldName] = hit.Timestamp.Format(time.RFC3339Nano)
+
+	index, doc, err := pipelines.Process(dest, "", hit.ID, source)
+	if err != nil {
+		return "", dest.GetName(), err
+	}
+	if doc == nil {
+		return batchResultNoop, dest.GetName(), nil
+	}
+
+	_, err = index.GetDocument(doc.ID, cfg.Shard.GoroutineNum)
+	exists := err == nil
+	if exists && r.req.Dest.OpType == meta.ReindexOpTypeCreate {
+		return "", index.GetName(), errors.New(errors.ErrorTypeVersionConflictEngineException,
+			fmt.Sprintf("[%s]: version conflict, document already exists", doc.ID))
+	}
+	if err := index.CreateDocument(doc.ID, doc.Source, exists, cfg.EnableTextKeywordMapping); err != nil {
+		return "", index.GetName(), err
+	}
+	if exists {
+		return batchResultUpdated, index.GetName(), nil
+	}
+	return batchResultCreated, index.GetName(), nil
+}
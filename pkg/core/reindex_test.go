@@ -0,0 +1,174 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestReindex(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+
+	run := func(t *testing.T, req *meta.ReindexRequest, requestsPerSecond float64) *meta.ReindexResponse {
+		reindex, err := NewReindex(req, requestsPerSecond)
+		assert.NoError(t, err)
+		task := ZINC_TASK_LIST.Start(ReindexAction, reindex.Description(), func(task *Task) (interface{}, error) {
+			return reindex.Run(task, cfg)
+		})
+		resp, err := task.Result()
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		waitForRefresh(t, ZINC_INDEX_LIST.List()...)
+		return resp.(*meta.ReindexResponse)
+	}
+	get := func(t *testing.T, name, id string) map[string]interface{} {
+		index, ok := GetIndex(name)
+		assert.True(t, ok)
+		hit, err := index.GetDocument(id, cfg.Shard.GoroutineNum)
+		assert.NoError(t, err)
+		return hit.Source.(map[string]interface{})
+	}
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := NewIndex("reindex.src", "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		for i := 0; i < 5; i++ {
+			doc := map[string]interface{}{"num": float64(i), "name": "doc" + strconv.Itoa(i)}
+			assert.NoError(t, index.CreateDocument(strconv.Itoa(i), doc, false, cfg.EnableTextKeywordMapping))
+		}
+		assert.NoError(t, PutPipeline("reindex.copied", &meta.Pipeline{Processors: []map[string]interface{}{
+			{"set": map[string]interface{}{"field": "copied", "value": true}},
+		}}))
+		waitForRefresh(t, index)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := []*meta.ReindexRequest{
+			{Dest: meta.ReindexDest{Index: "reindex.dest"}},
+			{Source: meta.ReindexSource{Index: "reindex.src"}},
+			{Source: meta.ReindexSource{Index: "reindex.missing"}, Dest: meta.ReindexDest{Index: "reindex.dest"}},
+			{Source: meta.ReindexSource{Index: "reindex.src"}, Dest: meta.ReindexDest{Index: "reindex.src"}},
+			{Source: meta.ReindexSource{Index: "reindex.src"}, Dest: meta.ReindexDest{Index: "reindex.dest", OpType: "upsert"}},
+			{Source: meta.ReindexSource{Index: "reindex.src"}, Dest: meta.ReindexDest{Index: "reindex.dest"}, Conflicts: "ignore"},
+			{Source: meta.ReindexSource{Index: "reindex.src", Source: false}, Dest: meta.ReindexDest{Index: "reindex.dest"}},
+		}
+		for _, req := range invalid {
+			_, err := NewReindex(req, -1)
+			assert.Error(t, err)
+		}
+	})
+
+	t.Run("copy all", func(t *testing.T) {
+		resp := run(t, &meta.ReindexRequest{
+			Source: meta.ReindexSource{Index: []interface{}{"reindex.src"}, Size: 2},
+			Dest:   meta.ReindexDest{Index: "reindex.dest", Pipeline: "reindex.copied"},
+		}, -1)
+		assert.Equal(t, int64(5), resp.Total)
+		assert.Equal(t, int64(5), resp.Created)
+		assert.Equal(t, int64(3), resp.Batches)
+		assert.Empty(t, resp.Failures)
+		assert.Equal(t, "doc3", get(t, "reindex.dest", "3")["name"])
+		assert.Equal(t, true, get(t, "reindex.dest", "3")["copied"])
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		req := &meta.ReindexRequest{
+			Source: meta.ReindexSource{Index: "reindex.src", Query: map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"gte": 3}}}},
+			Dest:   meta.ReindexDest{Index: "reindex.dest", OpType: meta.ReindexOpTypeCreate},
+		}
+		resp := run(t, req, -1)
+		assert.Equal(t, int64(0), resp.Created)
+		assert.Equal(t, int64(1), resp.VersionConflicts)
+		assert.Len(t, resp.Failures, 1)
+
+		req.Conflicts = meta.ReindexConflictsProceed
+		resp = run(t, req, -1)
+		assert.Equal(t, int64(2), resp.VersionConflicts)
+		assert.Empty(t, resp.Failures)
+
+		req.Dest.OpType = meta.ReindexOpTypeIndex
+		resp = run(t, req, -1)
+		assert.Equal(t, int64(2), resp.Updated)
+	})
+
+	t.Run("query and source", func(t *testing.T) {
+		resp := run(t, &meta.ReindexRequest{
+			Source: meta.ReindexSource{
+				Index:  "reindex.s*",
+				Query:  map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"lt": 3}}},
+				Source: []interface{}{"num"},
+			},
+			Dest:    meta.ReindexDest{Index: "reindex.dest_2"},
+			MaxDocs: 2,
+		}, -1)
+		assert.Equal(t, int64(2), resp.Total)
+		assert.Equal(t, int64(2), resp.Created)
+		hits := searchAll(t, "reindex.dest_2", cfg)
+		assert.Len(t, hits, 2)
+		for _, hit := range hits {
+			assert.Nil(t, hit.Source.(map[string]interface{})["name"])
+			assert.Less(t, hit.Source.(map[string]interface{})["num"], float64(3))
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		reindex, err := NewReindex(&meta.ReindexRequest{
+			Source: meta.ReindexSource{Index: "reindex.src", Size: 1},
+			Dest:   meta.ReindexDest{Index: "reindex.dest_3"},
+		}, 0.2)
+		assert.NoError(t, err)
+		task := ZINC_TASK_LIST.Start(ReindexAction, reindex.Description(), func(task *Task) (interface{}, error) {
+			return reindex.Run(task, cfg)
+		})
+		assert.False(t, task.Wait(100*time.Millisecond))
+		assert.False(t, task.TaskResult().Completed)
+
+		_, err = ZINC_TASK_LIST.Cancel(task.ID())
+		assert.NoError(t, err)
+		assert.True(t, task.Wait(time.Second))
+		result := task.TaskResult()
+		assert.True(t, result.Completed)
+		assert.True(t, result.Task.Cancelled)
+		resp := result.Response.(*meta.ReindexResponse)
+		assert.Equal(t, "by user request", resp.Canceled)
+		assert.Equal(t, int64(1), resp.Created)
+		assert.Equal(t, int64(1), result.Task.Status.Created)
+		assert.Greater(t, resp.ThrottledMillis, int64(0))
+
+		ZINC_TASK_LIST.Delete(task.ID())
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		for _, name := range []string{"reindex.src", "reindex.dest", "reindex.dest_2", "reindex.dest_3"} {
+			assert.NoError(t, DeleteIndex(name, cfg.DataPath))
+		}
+		assert.NoError(t, DeletePipeline("reindex.copied"))
+	})
+}
+
+func searchAll(t *testing.T, name string, cfg *config.Config) []meta.Hit {
+	resp, err := MultiSearch([]string{name}, &meta.ZincQuery{Size: 100}, cfg)
+	assert.NoError(t, err)
+	return resp.Hits.Hits
+}
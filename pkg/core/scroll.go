@@ -0,0 +1,267 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blugelabs/bluge/search"
+	"github.com/rs/zerolog/log"
+
+	zincsearch "github.com/zinclabs/zincsearch/pkg/bluge/search"
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery"
+	"github.com/zinclabs/zincsearch/pkg/uquery/timerange"
+)
+
+// MaxScrollKeepAlive is the max time a scroll can keep the readers open between two requests
+const MaxScrollKeepAlive = 24 * time.Hour
+
+var ZINC_SCROLL_LIST = &ScrollList{scrolls: make(map[string]*Scroll)}
+
+type ScrollList struct {
+	scrolls map[string]*Scroll
+	lock    sync.RWMutex
+}
+
+// Scroll keeps the readers of the search open, then every page searches on the same point in time,
+// it walks the hits by the sort values of the last hit, so the depth is not limited by ZINC_MAX_RESULTS.
+type Scroll struct {
+	*indexReaders
+	id     string
+	query  *meta.ZincQuery
+	sorted bool     // the query has sort, or hits shouldn't return the sort values
+	after  [][]byte // sort values of the last hit
+	timer  *time.Timer
+	closed bool
+	lock   sync.Mutex
+}
+
+// NewScroll opens the readers of the indexes and returns the first page
+func (t *ScrollList) NewScroll(indexNames []string, query *meta.ZincQuery, keepAlive time.Duration, cfg *config.Config) (*meta.SearchResponse, error) {
+	if err := checkScrollKeepAlive(keepAlive); err != nil {
+		return nil, err
+	}
+	if query.From > 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "using [from] is not allowed in a scroll context")
+	}
+	if query.SearchAfter != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[search_after] cannot be used in a scroll context")
+	}
+
+	s, err := newScroll(indexNames, query, cfg)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.nextPage(cfg)
+	if err != nil {
+		s.close()
+		return nil, err
+	}
+
+	s.lock.Lock()
+	t.lock.Lock()
+	t.scrolls[s.id] = s
+	t.lock.Unlock()
+	s.timer = time.AfterFunc(keepAlive, func() { t.Delete(s.id) })
+	s.lock.Unlock()
+	return resp, nil
+}
+
+// Next returns the next page of the scroll and extends the keep alive
+func (t *ScrollList) Next(id string, keepAlive time.Duration, cfg *config.Config) (*meta.SearchResponse, error) {
+	if err := checkScrollKeepAlive(keepAlive); err != nil {
+		return nil, err
+	}
+	t.lock.RLock()
+	s, ok := t.scrolls[id]
+	t.lock.RUnlock()
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", id))
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil, errors.New(errors.ErrorTypeSearchContextMissingException, fmt.Sprintf("No search context found for id [%s]", id))
+	}
+	s.timer.Reset(keepAlive)
+	return s.nextPage(cfg)
+}
+
+// Delete closes the scrolls and returns the number of freed scrolls
+func (t *ScrollList) Delete(ids ...string) int {
+	scrolls := make([]*Scroll, 0, len(ids))
+	t.lock.Lock()
+	for _, id := range ids {
+		if s, ok := t.scrolls[id]; ok {
+			scrolls = append(scrolls, s)
+			delete(t.scrolls, id)
+		}
+	}
+	t.lock.Unlock()
+
+	for _, s := range scrolls {
+		s.lock.Lock()
+		s.timer.Stop()
+		s.close()
+		s.lock.Unlock()
+	}
+	return len(scrolls)
+}
+
+// DeleteAll closes all the scrolls and returns the number of freed scrolls
+func (t *ScrollList) DeleteAll() int {
+	t.lock.RLock()
+	ids := make([]string, 0, len(t.scrolls))
+	for id := range t.scrolls {
+		ids = append(ids, id)
+	}
+	t.lock.RUnlock()
+	return t.Delete(ids...)
+}
+
+func (t *ScrollList) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.scrolls)
+}
+
+// newScroll opens the readers of the indexes, the scroll isn't added to the list
+func newScroll(indexNames []string, query *meta.ZincQuery, cfg *config.Config) (*Scroll, error) {
+	if query.Collapse != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "cannot use `collapse` in a scroll context")
+	}
+	timeMin, timeMax := timerange.Query(query.Query)
+	r, err := getReadersForIndexes(indexNames, timeMin, timeMax, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scroll{
+		indexReaders: r,
+		id:           newSearchContextID(),
+		query:        query,
+		sorted:       query.Sort != nil,
+	}
+	if len(r.readers) > 0 {
+		if _, err = uquery.ParseQueryDSL(query, r.mappings, r.analyzers, cfg.MaxResults, cfg.AggregationTermsSize); err != nil {
+			s.close()
+			return nil, err
+		}
+		// the hits must be in a total order to walk by the sort values, use _index and _id as the tiebreaker,
+		// the same _id can be in several indexes
+		sorts, _ := query.Sort.(search.SortOrder)
+		if sorts == nil {
+			sorts = search.SortOrder{search.SortBy(search.DocumentScore()).Desc()}
+		}
+		query.Sort = append(sorts.Copy(), search.SortBy(search.Field("_index")), search.SortBy(search.Field("_id")))
+	}
+	return s, nil
+}
+
+// nextPage returns the page after the last hit, the caller should hold the lock
+func (s *Scroll) nextPage(cfg *config.Config) (*meta.SearchResponse, error) {
+	if len(s.readers) == 0 {
+		return &meta.SearchResponse{Hits: meta.Hits{Hits: []meta.Hit{}}, ScrollID: s.id}, nil
+	}
+
+	query := *s.query
+	if s.after != nil {
+		// aggregations only return in the first page
+		query.Aggregations = nil
+		after := make([]interface{}, len(s.after))
+		for i, v := range s.after {
+			after[i] = v
+		}
+		query.SearchAfter = after
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if query.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(query.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	dmi, err := zincsearch.MultiSearch(ctx, &query, s.mappings, s.analyzers, cfg, s.readers...)
+	if err != nil {
+		log.Printf("core.Scroll: error executing search: %s", err.Error())
+		return nil, err
+	}
+	last := &lastMatchIterator{DocumentMatchIterator: dmi}
+	resp, err := searchV2(s.shardNum, int64(len(s.readers)), last, &query, s.mappings)
+	if err != nil {
+		return nil, err
+	}
+	if last.sortValue != nil {
+		s.after = last.sortValue
+	}
+	if !s.sorted {
+		for i := range resp.Hits.Hits {
+			resp.Hits.Hits[i].Sort = nil
+		}
+	}
+	resp.ScrollID = s.id
+	return resp, nil
+}
+
+func (s *Scroll) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.indexReaders.close()
+}
+
+// lastMatchIterator records the sort values of the last hit
+type lastMatchIterator struct {
+	search.DocumentMatchIterator
+	sortValue [][]byte
+}
+
+func (it *lastMatchIterator) Next() (*search.DocumentMatch, error) {
+	next, err := it.DocumentMatchIterator.Next()
+	if err == nil && next != nil {
+		it.sortValue = make([][]byte, len(next.SortValue))
+		for i, v := range next.SortValue {
+			it.sortValue[i] = append([]byte(nil), v...)
+		}
+	}
+	return next, err
+}
+
+func checkScrollKeepAlive(keepAlive time.Duration) error {
+	if keepAlive <= 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[scroll] keep alive should be positive")
+	}
+	if keepAlive > MaxScrollKeepAlive {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[scroll] keep alive [%s] is too large, the max is [%s]", keepAlive, MaxScrollKeepAlive))
+	}
+	return nil
+}
+
+func newSearchContextID() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,163 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestScrollList(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Scroll.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 25; i++ {
+			// duplicated values to check the _id tiebreaker
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i % 5)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	t.Run("scroll all hits", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:  []interface{}{"num"},
+			Size:  10,
+		}
+		resp, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName}, query, time.Minute, cfg)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, resp.ScrollID)
+		assert.Equal(t, 25, resp.Hits.Total.Value)
+
+		ids := make(map[string]struct{})
+		pages := []int{}
+		lastNum := -1.0
+		for len(resp.Hits.Hits) > 0 {
+			pages = append(pages, len(resp.Hits.Hits))
+			for _, hit := range resp.Hits.Hits {
+				ids[hit.ID] = struct{}{}
+				num := hit.Source.(map[string]interface{})["num"].(float64)
+				assert.GreaterOrEqual(t, num, lastNum)
+				lastNum = num
+				assert.Len(t, hit.Sort, 3)
+			}
+			resp, err = ZINC_SCROLL_LIST.Next(resp.ScrollID, time.Minute, cfg)
+			assert.NoError(t, err)
+			assert.Equal(t, 25, resp.Hits.Total.Value)
+		}
+		assert.Equal(t, []int{10, 10, 5}, pages)
+		assert.Len(t, ids, 25)
+
+		assert.Equal(t, 1, ZINC_SCROLL_LIST.Delete(resp.ScrollID))
+		_, err = ZINC_SCROLL_LIST.Next(resp.ScrollID, time.Minute, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("scroll indexes with the same ids", func(t *testing.T) {
+		otherName := "Scroll.index_2"
+		other, err := NewIndex(otherName, "disk", 1, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(other))
+		defer func() { assert.NoError(t, DeleteIndex(otherName, cfg.DataPath)) }()
+		for i := 0; i < 25; i++ {
+			err := other.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i % 5)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		waitForRefresh(t, other)
+
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:  []interface{}{"num"},
+			Size:  7,
+		}
+		resp, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName, otherName}, query, time.Minute, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, 50, resp.Hits.Total.Value)
+
+		hits := make(map[string]struct{})
+		for len(resp.Hits.Hits) > 0 {
+			for _, hit := range resp.Hits.Hits {
+				hits[hit.Index+"/"+hit.ID] = struct{}{}
+			}
+			resp, err = ZINC_SCROLL_LIST.Next(resp.ScrollID, time.Minute, cfg)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, hits, 50)
+		assert.Equal(t, 1, ZINC_SCROLL_LIST.Delete(resp.ScrollID))
+	})
+
+	t.Run("scroll without sort", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Size:  20,
+		}
+		resp, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName}, query, time.Minute, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 20)
+		assert.Nil(t, resp.Hits.Hits[0].Sort)
+		resp, err = ZINC_SCROLL_LIST.Next(resp.ScrollID, time.Minute, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, resp.Hits.Hits, 5)
+		assert.Equal(t, 1, ZINC_SCROLL_LIST.DeleteAll())
+	})
+
+	t.Run("scroll expired", func(t *testing.T) {
+		query := &meta.ZincQuery{
+			Query: map[string]interface{}{"match_all": map[string]interface{}{}},
+			Size:  10,
+		}
+		resp, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName}, query, 10*time.Millisecond, cfg)
+		assert.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		_, err = ZINC_SCROLL_LIST.Next(resp.ScrollID, time.Minute, cfg)
+		assert.Error(t, err)
+		assert.Equal(t, 0, ZINC_SCROLL_LIST.Len())
+	})
+
+	t.Run("scroll with error params", func(t *testing.T) {
+		query := &meta.ZincQuery{Size: 10, From: 10}
+		_, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName}, query, time.Minute, cfg)
+		assert.Error(t, err)
+		query = &meta.ZincQuery{Size: 10, SearchAfter: []interface{}{1}}
+		_, err = ZINC_SCROLL_LIST.NewScroll([]string{indexName}, query, time.Minute, cfg)
+		assert.Error(t, err)
+		_, err = ZINC_SCROLL_LIST.NewScroll([]string{indexName}, &meta.ZincQuery{Size: 10}, 0, cfg)
+		assert.Error(t, err)
+		_, err = ZINC_SCROLL_LIST.NewScroll([]string{"Scroll.index_not_exists"}, &meta.ZincQuery{Size: 10}, time.Minute, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
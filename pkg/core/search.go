@@ -29,6 +29,7 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/uquery"
 	"github.com/zinclabs/zincsearch/pkg/uquery/fields"
+	"github.com/zinclabs/zincsearch/pkg/uquery/sort"
 	"github.com/zinclabs/zincsearch/pkg/uquery/source"
 	"github.com/zinclabs/zincsearch/pkg/uquery/timerange"
 )
@@ -95,61 +96,28 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 	Hits := make([]meta.Hit, 0)
 	next, err := dmi.Next()
 	for err == nil && next != nil {
-		var id string
-		var indexName string
-		var timestamp time.Time
-		var sourceData map[string]interface{}
-		var fieldsData map[string]interface{}
-		var highlightData map[string]interface{}
-		if query.Highlight != nil {
-			highlightData = make(map[string]interface{})
-		}
-		err = next.VisitStoredFields(func(field string, value []byte) bool {
-			switch field {
-			case "_id":
-				id = string(value)
-			case "_index":
-				indexName = string(value)
-			case "@timestamp":
-				timestamp, _ = bluge.DecodeDateTime(value)
-			case "_source":
-				sourceData = source.Response(query.Source.(*meta.Source), value)
-				if query.Fields != nil {
-					fieldsData = fields.Response(query.Fields.([]*meta.Field), value, mappings)
-				}
-			default:
-				// highlight
-				if query.Highlight != nil && query.Highlight.Fields != nil {
-					if options, ok := query.Highlight.Fields[field]; ok {
-						if v, ok := next.Locations[field]; ok {
-							if len(options.PreTags) > 0 && len(options.PostTags) > 0 {
-								highlighter := highlight.NewHTMLHighlighterTags(options.PreTags[0], options.PostTags[0])
-								highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
-							} else {
-								highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
-							}
-						}
-					}
-				}
-			}
-
-			return true
-		})
+		var hit meta.Hit
+		hit, err = newHit(next, query, mappings, highlighter)
 		if err != nil {
 			log.Printf("core.SearchV2: error accessing stored fields: %s", err.Error())
 			continue
 		}
-
-		sourceData["@timestamp"] = timestamp
-		hit := meta.Hit{
-			Index:     indexName,
-			Type:      "_doc",
-			ID:        id,
-			Score:     next.Score,
-			Timestamp: timestamp,
-			Source:    sourceData,
-			Fields:    fieldsData,
-			Highlight: highlightData,
+		if query.Collapse != nil {
+			if hit.Fields == nil {
+				hit.Fields = make(map[string]interface{})
+			}
+			if value, ok := zincsearch.CollapseValue(next, query.Collapse.Field); ok {
+				hit.Fields[query.Collapse.Field] = []interface{}{value}
+			} else {
+				hit.Fields[query.Collapse.Field] = []interface{}{nil}
+			}
+			if list, ok := dmi.(*zincsearch.CollapsedList); ok && list.InnerHits(next) != nil {
+				hits, err := newInnerHits(list.InnerHits(next), query, mappings)
+				if err != nil {
+					return nil, err
+				}
+				hit.InnerHits = map[string]meta.InnerHit{query.Collapse.InnerHits.Name: {Hits: hits}}
+			}
 		}
 		Hits = append(Hits, hit)
 
@@ -167,9 +135,111 @@ func searchV2(shardNum, readerNum int64, dmi search.DocumentMatchIterator, query
 		Hits:     Hits,
 	}
 
-	if err := uquery.FormatResponse(resp, query, dmi.Aggregations()); err != nil {
+	if err := uquery.FormatResponse(resp, query, dmi.Aggregations(), mappings); err != nil {
 		log.Printf("core.SearchV2: error format response: %s", err.Error())
+		return nil, err
 	}
 
 	return resp, nil
 }
+
+// newHit loads the stored fields of the document
+func newHit(next *search.DocumentMatch, query *meta.ZincQuery, mappings *meta.Mappings, highlighter *highlight.SimpleHighlighter) (meta.Hit, error) {
+	var id string
+	var indexName string
+	var timestamp time.Time
+	var seqNo int64
+	var sourceData map[string]interface{}
+	var fieldsData map[string]interface{}
+	var highlightData map[string]interface{}
+	if query.Highlight != nil {
+		highlightData = make(map[string]interface{})
+	}
+	err := next.VisitStoredFields(func(field string, value []byte) bool {
+		switch field {
+		case "_id":
+			id = string(value)
+		case "_index":
+			indexName = string(value)
+		case "@timestamp":
+			timestamp, _ = bluge.DecodeDateTime(value)
+		case "_seq_no":
+			v, _ := bluge.DecodeNumericFloat64(value)
+			seqNo = int64(v)
+		case "_source":
+			sourceData = source.Response(query.Source.(*meta.Source), value)
+			if query.Fields != nil {
+				fieldsData = fields.Response(query.Fields.([]*meta.Field), value, mappings)
+			}
+		default:
+			// highlight
+			if query.Highlight != nil && query.Highlight.Fields != nil {
+				if options, ok := query.Highlight.Fields[field]; ok {
+					if v, ok := next.Locations[field]; ok {
+						if len(options.PreTags) > 0 && len(options.PostTags) > 0 {
+							highlighter := highlight.NewHTMLHighlighterTags(options.PreTags[0], options.PostTags[0])
+							highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
+						} else {
+							highlightData[field] = highlighter.BestFragments(v, value, options.NumberOfFragments)
+						}
+					}
+				}
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return meta.Hit{}, err
+	}
+
+	sourceData["@timestamp"] = timestamp
+	hit := meta.Hit{
+		Index:     indexName,
+		Type:      "_doc",
+		ID:        id,
+		Score:     next.Score,
+		Timestamp: timestamp,
+		Source:    sourceData,
+		Fields:    fieldsData,
+		Highlight: highlightData,
+	}
+	if query.Explain {
+		hit.Explanation = newExplanation(next.Explanation)
+	}
+	if query.SeqNoPrimaryTerm {
+		hit.SeqNo = seqNo
+		hit.PrimaryTerm = meta.PrimaryTerm
+	}
+	if sorts, ok := query.Sort.(search.SortOrder); ok {
+		hit.Sort = sort.Values(next.SortValue, sorts, mappings)
+	}
+	return hit, nil
+}
+
+// newInnerHits returns the inner hits of a collapsed group, they use the source and sort of the inner_hits
+func newInnerHits(dmi search.DocumentMatchIterator, query *meta.ZincQuery, mappings *meta.Mappings) (meta.Hits, error) {
+	innerQuery := &meta.ZincQuery{
+		Source:  query.Collapse.InnerHits.Source,
+		Sort:    query.Collapse.InnerHits.Sort,
+		Explain: query.Explain,
+
+		SeqNoPrimaryTerm: query.SeqNoPrimaryTerm,
+	}
+	hits := meta.Hits{Hits: []meta.Hit{}}
+	next, err := dmi.Next()
+	for err == nil && next != nil {
+		var hit meta.Hit
+		if hit, err = newHit(next, innerQuery, mappings, nil); err != nil {
+			return hits, err
+		}
+		hits.Hits = append(hits.Hits, hit)
+		next, err = dmi.Next()
+	}
+	if err != nil {
+		return hits, err
+	}
+	hits.Total = meta.Total{Value: int(dmi.Aggregations().Count())}
+	hits.MaxScore = dmi.Aggregations().Metric("max_score")
+	return hits, nil
+}
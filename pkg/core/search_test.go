@@ -24,7 +24,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/zinclabs/zincsearch/pkg/bluge/aggregation"
 	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
 )
 
 func TestIndex_Search(t *testing.T) {
@@ -268,7 +270,7 @@ func TestIndex_Search(t *testing.T) {
 		}
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		waitForRefresh(t, index)
 	})
 
 	for _, tt := range tests {
@@ -288,3 +290,1410 @@ func TestIndex_Search(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestIndex_SearchGeo(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *meta.ZincQuery
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name: "geo_distance",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{
+					"geo_distance": map[string]interface{}{
+						"distance": "10km",
+						"location": map[string]interface{}{"lat": 40.7128, "lon": -74.0060},
+					},
+				},
+				Size: 10,
+			},
+			wantIDs: []string{"new-york", "brooklyn"},
+		},
+		{
+			name: "geo_bounding_box",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{
+					"geo_bounding_box": map[string]interface{}{
+						"location": map[string]interface{}{
+							"top_left":     "43,-75",
+							"bottom_right": []interface{}{-70.0, 40.0},
+						},
+					},
+				},
+				Size: 10,
+			},
+			wantIDs: []string{"new-york", "brooklyn", "boston"},
+		},
+		{
+			name: "geo_polygon",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{
+					"geo_polygon": map[string]interface{}{
+						"location": map[string]interface{}{
+							"points": []interface{}{"30,-125", "30,-115", "40,-115", "40,-125"},
+						},
+					},
+				},
+				Size: 10,
+			},
+			wantIDs: []string{"los-angeles"},
+		},
+		{
+			name: "geo_distance sort",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{
+					"match_all": map[string]interface{}{},
+				},
+				Sort: []interface{}{
+					map[string]interface{}{
+						"_geo_distance": map[string]interface{}{
+							"location": []interface{}{-118.2437, 34.0522},
+							"order":    "asc",
+							"unit":     "km",
+						},
+					},
+				},
+				Size: 10,
+			},
+			wantIDs: []string{"los-angeles", "new-york", "brooklyn", "boston", "nowhere"},
+		},
+		{
+			name: "geo_distance on non geo field",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{
+					"geo_distance": map[string]interface{}{
+						"distance": "10km",
+						"name":     "40.7,-74",
+					},
+				},
+				Size: 10,
+			},
+			wantErr: true,
+		},
+	}
+
+	prepareData := map[string]map[string]interface{}{
+		"new-york":    {"name": "New York", "location": map[string]interface{}{"lat": 40.7128, "lon": -74.0060}},
+		"brooklyn":    {"name": "Brooklyn", "location": "40.6782,-73.9442"},
+		"boston":      {"name": "Boston", "location": "drt2yzr"},
+		"los-angeles": {"name": "Los Angeles", "location": []interface{}{-118.2437, 34.0522}},
+		"nowhere":     {"name": "Nowhere"},
+	}
+
+	var err error
+	var index *Index
+	indexName := "Search.geo.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("location", meta.NewProperty("geo_point"))
+
+		for id, d := range prepareData {
+			err := index.CreateDocument(id, d, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		err = index.CreateDocument("invalid", map[string]interface{}{"location": "100,200"}, false, cfg.EnableTextKeywordMapping)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := index.Search(tt.query, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(got.Hits.Hits))
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			if tt.query.Sort != nil {
+				assert.Equal(t, tt.wantIDs, ids)
+			} else {
+				assert.ElementsMatch(t, tt.wantIDs, ids)
+			}
+		})
+	}
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   *meta.ZincQuery
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name: "term",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"term": map[string]interface{}{"addr": "10.1.2.3"}},
+				Size:  10,
+			},
+			wantIDs: []string{"a"},
+		},
+		{
+			name: "term ipv6",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"term": map[string]interface{}{"addr": "2001:0db8::0001"}},
+				Size:  10,
+			},
+			wantIDs: []string{"e"},
+		},
+		{
+			name: "term cidr",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"term": map[string]interface{}{"addr": "10.0.0.0/8"}},
+				Size:  10,
+			},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "term ipv6 cidr",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"term": map[string]interface{}{"addr": "2001:db8::/32"}},
+				Size:  10,
+			},
+			wantIDs: []string{"e"},
+		},
+		{
+			name: "terms",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"terms": map[string]interface{}{"addr": []interface{}{"192.168.0.1", "172.16.0.0/12"}}},
+				Size:  10,
+			},
+			wantIDs: []string{"c", "d"},
+		},
+		{
+			name: "range",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"range": map[string]interface{}{"addr": map[string]interface{}{"gte": "10.1.2.3", "lt": "192.168.0.1"}}},
+				Size:  10,
+			},
+			wantIDs: []string{"a", "b", "d"},
+		},
+		{
+			name: "range unbounded",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"range": map[string]interface{}{"addr": map[string]interface{}{"gt": "192.168.0.1"}}},
+				Size:  10,
+			},
+			wantIDs: []string{"e"},
+		},
+		{
+			name: "term invalid ip",
+			query: &meta.ZincQuery{
+				Query: map[string]interface{}{"term": map[string]interface{}{"addr": "10.0.0.256"}},
+				Size:  10,
+			},
+			wantErr: true,
+		},
+	}
+
+	prepareData := map[string]map[string]interface{}{
+		"a": {"addr": "10.1.2.3"},
+		"b": {"addr": "10.200.0.1"},
+		"c": {"addr": "192.168.0.1"},
+		"d": {"addr": "172.16.5.4"},
+		"e": {"addr": "2001:db8::1"},
+		"f": {"name": "no ip"},
+	}
+
+	var err error
+	var index *Index
+	indexName := "Search.ip.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("addr", meta.NewProperty("ip"))
+
+		for id, d := range prepareData {
+			err := index.CreateDocument(id, d, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		err = index.CreateDocument("invalid", map[string]interface{}{"addr": "not an ip"}, false, cfg.EnableTextKeywordMapping)
+		assert.Error(t, err)
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := index.Search(tt.query, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(got.Hits.Hits))
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			assert.ElementsMatch(t, tt.wantIDs, ids)
+		})
+	}
+
+	t.Run("sort", func(t *testing.T) {
+		got, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"range": map[string]interface{}{"addr": map[string]interface{}{"gte": "0.0.0.0"}}},
+			Sort:  []interface{}{"addr"},
+			Size:  10,
+		}, cfg)
+		assert.NoError(t, err)
+		ids := make([]string, 0, len(got.Hits.Hits))
+		for _, hit := range got.Hits.Hits {
+			ids = append(ids, hit.ID)
+		}
+		assert.Equal(t, []string{"a", "b", "d", "c", "e"}, ids)
+		assert.Equal(t, []interface{}{"10.1.2.3"}, got.Hits.Hits[0].Sort)
+	})
+
+	t.Run("ip_range and terms aggregations", func(t *testing.T) {
+		got, err := index.Search(&meta.ZincQuery{
+			Query: map[string]interface{}{"range": map[string]interface{}{"addr": map[string]interface{}{"gte": "0.0.0.0"}}},
+			Size:  0,
+			Aggregations: map[string]meta.Aggregations{
+				"ranges": {IPRange: &meta.AggregationIPRange{Field: "addr", Ranges: []meta.IPRange{
+					{To: "10.200.0.1"},
+					{From: "10.200.0.1"},
+					{Mask: "10.0.0.0/8"},
+					{Key: "v6", Mask: "2001:db8::/32"},
+				}}},
+				"addrs": {Terms: &meta.AggregationsTerms{Field: "addr"}},
+			},
+		}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, []map[string]interface{}{
+			{"key": "*-10.200.0.1", "to": "10.200.0.1", "doc_count": uint64(1)},
+			{"key": "10.200.0.1-*", "from": "10.200.0.1", "doc_count": uint64(4)},
+			{"key": "10.0.0.0/8", "from": "10.0.0.0", "to": "11.0.0.0", "doc_count": uint64(2)},
+			{"key": "v6", "from": "2001:db8::", "to": "2001:db9::", "doc_count": uint64(1)},
+		}, got.Aggregations["ranges"].Buckets)
+		keys := make([]interface{}, 0)
+		for _, bucket := range got.Aggregations["addrs"].Buckets.([]map[string]interface{}) {
+			keys = append(keys, bucket["key"])
+		}
+		assert.ElementsMatch(t, []interface{}{"10.1.2.3", "10.200.0.1", "192.168.0.1", "172.16.5.4", "2001:db8::1"}, keys)
+
+		_, err = index.Search(&meta.ZincQuery{
+			Aggregations: map[string]meta.Aggregations{
+				"ranges": {IPRange: &meta.AggregationIPRange{Field: "addr", Ranges: []meta.IPRange{{Mask: "10.0.0.0/40"}}}},
+			},
+		}, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchCompoundQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   map[string]interface{}
+		wantIDs []string
+		ordered bool
+		wantErr bool
+	}{
+		{
+			name:    "exists",
+			query:   map[string]interface{}{"exists": map[string]interface{}{"field": "body"}},
+			wantIDs: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:    "exists empty array",
+			query:   map[string]interface{}{"exists": map[string]interface{}{"field": "tags"}},
+			wantIDs: []string{"a", "b", "d"},
+		},
+		{
+			name:    "exists object",
+			query:   map[string]interface{}{"exists": map[string]interface{}{"field": "user"}},
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "exists wildcard",
+			query:   map[string]interface{}{"exists": map[string]interface{}{"field": "user.*"}},
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "exists missing field",
+			query:   map[string]interface{}{"exists": map[string]interface{}{"field": "nothing"}},
+			wantIDs: []string{},
+		},
+		{
+			name:    "exists without field",
+			query:   map[string]interface{}{"exists": map[string]interface{}{}},
+			wantErr: true,
+		},
+		{
+			name: "boosting",
+			query: map[string]interface{}{"boosting": map[string]interface{}{
+				"positive":       map[string]interface{}{"match": map[string]interface{}{"title": "apple"}},
+				"negative":       map[string]interface{}{"match": map[string]interface{}{"body": "electronic"}},
+				"negative_boost": 0.1,
+			}},
+			wantIDs: []string{"a", "b"},
+			ordered: true,
+		},
+		{
+			name: "boosting without negative_boost",
+			query: map[string]interface{}{"boosting": map[string]interface{}{
+				"positive": map[string]interface{}{"match": map[string]interface{}{"title": "apple"}},
+				"negative": map[string]interface{}{"match": map[string]interface{}{"body": "electronic"}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "combined_fields",
+			query: map[string]interface{}{"combined_fields": map[string]interface{}{
+				"query":  "apple pie",
+				"fields": []interface{}{"title", "body"},
+			}},
+			wantIDs: []string{"a", "b", "d"},
+		},
+		{
+			name: "combined_fields and",
+			query: map[string]interface{}{"combined_fields": map[string]interface{}{
+				"query":    "apple pie",
+				"fields":   []interface{}{"title^2", "body"},
+				"operator": "and",
+			}},
+			wantIDs: []string{"a", "d"},
+		},
+		{
+			name: "combined_fields numeric field",
+			query: map[string]interface{}{"combined_fields": map[string]interface{}{
+				"query":  "1",
+				"fields": []interface{}{"title", "required"},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "terms_set field",
+			query: map[string]interface{}{"terms_set": map[string]interface{}{"tags": map[string]interface{}{
+				"terms":                      []interface{}{"x", "y", "z"},
+				"minimum_should_match_field": "required",
+			}}},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "terms_set script",
+			query: map[string]interface{}{"terms_set": map[string]interface{}{"tags": map[string]interface{}{
+				"terms":                       []interface{}{"x", "y", "z"},
+				"minimum_should_match_script": map[string]interface{}{"source": "Math.min(params.num_terms, 2)"},
+			}}},
+			wantIDs: []string{"a", "d"},
+		},
+		{
+			name: "terms_set without minimum",
+			query: map[string]interface{}{"terms_set": map[string]interface{}{"tags": map[string]interface{}{
+				"terms": []interface{}{"x"},
+			}}},
+			wantErr: true,
+		},
+	}
+
+	prepareData := map[string]map[string]interface{}{
+		"a": {"title": "apple pie", "body": "sweet dessert", "tags": []interface{}{"x", "y", "z"}, "required": float64(2), "user": map[string]interface{}{"name": "tom"}},
+		"b": {"title": "apple phone", "body": "electronic device", "tags": []interface{}{"x"}, "required": float64(1)},
+		"c": {"title": "banana", "body": "", "tags": []interface{}{}, "required": float64(3)},
+		"d": {"title": "pie", "body": "apple pie recipe", "tags": []interface{}{"x", "y"}, "required": float64(3)},
+	}
+
+	var err error
+	var index *Index
+	indexName := "Search.compound.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 1, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for id, d := range prepareData {
+			err := index.CreateDocument(id, d, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := index.Search(&meta.ZincQuery{Query: tt.query, Size: 10}, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(got.Hits.Hits))
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			if tt.ordered {
+				assert.Equal(t, tt.wantIDs, ids)
+			} else {
+				assert.ElementsMatch(t, tt.wantIDs, ids)
+			}
+		})
+	}
+
+	t.Run("boosting score", func(t *testing.T) {
+		positive := map[string]interface{}{"match": map[string]interface{}{"title": "apple"}}
+		got, err := index.Search(&meta.ZincQuery{Query: positive, Size: 10}, cfg)
+		assert.NoError(t, err)
+		scores := make(map[string]float64)
+		for _, hit := range got.Hits.Hits {
+			scores[hit.ID] = hit.Score
+		}
+		got, err = index.Search(&meta.ZincQuery{Query: map[string]interface{}{"boosting": map[string]interface{}{
+			"positive":       positive,
+			"negative":       map[string]interface{}{"match": map[string]interface{}{"body": "electronic"}},
+			"negative_boost": 0.5,
+		}}, Size: 10}, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, got.Hits.Hits, 2)
+		for _, hit := range got.Hits.Hits {
+			want := scores[hit.ID]
+			if hit.ID == "b" {
+				want *= 0.5
+			}
+			assert.InDelta(t, want, hit.Score, 1e-9)
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchQueryString(t *testing.T) {
+	qs := func(params map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"query_string": params}
+	}
+	tests := []struct {
+		name    string
+		query   map[string]interface{}
+		wantIDs []string
+		wantErr bool
+	}{
+		{
+			name:    "and",
+			query:   qs(map[string]interface{}{"query": "status:error AND host:web-*"}),
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "default_operator and",
+			query:   qs(map[string]interface{}{"query": "status:error host:web-*", "default_operator": "AND"}),
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "default_operator or",
+			query:   qs(map[string]interface{}{"query": "status:ok host:db-1"}),
+			wantIDs: []string{"b", "c"},
+		},
+		{
+			name:    "not",
+			query:   qs(map[string]interface{}{"query": "status:error AND NOT host:db-1"}),
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "group",
+			query:   qs(map[string]interface{}{"query": "(status:ok OR status:warn) AND host:web-*"}),
+			wantIDs: []string{"c", "d"},
+		},
+		{
+			name:    "field group",
+			query:   qs(map[string]interface{}{"query": "status:(ok OR warn) -host:web-2"}),
+			wantIDs: []string{"d"},
+		},
+		{
+			name:    "fields wildcard",
+			query:   qs(map[string]interface{}{"query": "quick", "fields": []interface{}{"message*"}}),
+			wantIDs: []string{"a", "c"},
+		},
+		{
+			name:    "default_field",
+			query:   qs(map[string]interface{}{"query": "quick", "default_field": "message"}),
+			wantIDs: []string{"c"},
+		},
+		{
+			name:    "exists",
+			query:   qs(map[string]interface{}{"query": "_exists_:message_detail"}),
+			wantIDs: []string{"a", "d"},
+		},
+		{
+			name:    "date range",
+			query:   qs(map[string]interface{}{"query": "day:[2022-01-01 TO 2022-01-05]"}),
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name:    "date range exclusive",
+			query:   qs(map[string]interface{}{"query": "day:{2022-01-01 TO 2022-01-05]"}),
+			wantIDs: []string{"b"},
+		},
+		{
+			name:    "date range unbounded",
+			query:   qs(map[string]interface{}{"query": "day:[2022-01-05 TO *]"}),
+			wantIDs: []string{"b", "c", "d"},
+		},
+		{
+			name:    "date compare",
+			query:   qs(map[string]interface{}{"query": "day:>=2022-01-10"}),
+			wantIDs: []string{"c", "d"},
+		},
+		{
+			name:    "numeric range",
+			query:   qs(map[string]interface{}{"query": "count:[5 TO 10] AND count:>5"}),
+			wantIDs: []string{"b"},
+		},
+		{
+			name:    "invalid date",
+			query:   qs(map[string]interface{}{"query": "day:yesterday"}),
+			wantErr: true,
+		},
+		{
+			name:    "lenient",
+			query:   qs(map[string]interface{}{"query": "day:yesterday OR status:ok", "lenient": true}),
+			wantIDs: []string{"c"},
+		},
+		{
+			name:    "leading wildcard",
+			query:   qs(map[string]interface{}{"query": "host:*-1"}),
+			wantIDs: []string{"a", "b", "d"},
+		},
+		{
+			name:    "leading wildcard not allowed",
+			query:   qs(map[string]interface{}{"query": "host:*-1", "allow_leading_wildcard": false}),
+			wantErr: true,
+		},
+		{
+			name:    "analyze_wildcard",
+			query:   qs(map[string]interface{}{"query": "message:CONN*", "analyze_wildcard": true}),
+			wantIDs: []string{"b"},
+		},
+		{
+			name:    "phrase",
+			query:   qs(map[string]interface{}{"query": `message:"disk full"`}),
+			wantIDs: []string{},
+		},
+		{
+			name:    "phrase_slop",
+			query:   qs(map[string]interface{}{"query": `message:"disk full"`, "phrase_slop": float64(1)}),
+			wantIDs: []string{"a"},
+		},
+		{
+			name:    "fuzziness",
+			query:   qs(map[string]interface{}{"query": "message:conection~"}),
+			wantIDs: []string{"b"},
+		},
+		{
+			name:    "fuzziness default",
+			query:   qs(map[string]interface{}{"query": "message:conection~", "fuzziness": float64(0)}),
+			wantIDs: []string{},
+		},
+		{
+			name:    "boost",
+			query:   qs(map[string]interface{}{"query": "status:error^2 OR host:web-2", "boost": 1.5}),
+			wantIDs: []string{"a", "b", "c"},
+		},
+		{
+			name:    "invalid default_operator",
+			query:   qs(map[string]interface{}{"query": "error", "default_operator": "xor"}),
+			wantErr: true,
+		},
+		{
+			name:    "syntax error",
+			query:   qs(map[string]interface{}{"query": "status:(error"}),
+			wantErr: true,
+		},
+	}
+
+	prepareData := map[string]map[string]interface{}{
+		"a": {"status": "error", "host": "web-1", "message": "disk is full", "message_detail": "quick brown fox", "day": "2022-01-01", "count": float64(5)},
+		"b": {"status": "error", "host": "db-1", "message": "connection lost", "day": "2022-01-05", "count": float64(10)},
+		"c": {"status": "ok", "host": "web-2", "message": "all good quick", "day": "2022-01-10", "count": float64(1)},
+		"d": {"status": "warn", "host": "web-1", "message_detail": "brown dog", "day": "2022-02-01"},
+	}
+
+	var err error
+	var index *Index
+	indexName := "Search.querystring.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 1, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("status", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("host", meta.NewProperty("keyword"))
+		day := meta.NewProperty("date")
+		day.Format = "2006-01-02"
+		index.GetMappings().SetProperty("day", day)
+		for id, d := range prepareData {
+			err := index.CreateDocument(id, d, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := index.Search(&meta.ZincQuery{Query: tt.query, Size: 10}, cfg)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			ids := make([]string, 0, len(got.Hits.Hits))
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			assert.ElementsMatch(t, tt.wantIDs, ids)
+		})
+	}
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchAfter(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.after.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("name", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("date", meta.NewProperty("date"))
+		start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i := 0; i < 10; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"num":  float64(i),
+				"name": "name-" + strconv.Itoa(i),
+				"date": start.Add(time.Duration(i) * time.Hour).Format(time.RFC3339),
+			}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	walk := func(t *testing.T, sort []interface{}) []string {
+		ids := []string{}
+		var after []interface{}
+		for i := 0; i < 10; i++ {
+			got, err := index.Search(&meta.ZincQuery{
+				Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+				Sort:        sort,
+				Size:        3,
+				SearchAfter: after,
+			}, cfg)
+			assert.NoError(t, err)
+			if len(got.Hits.Hits) == 0 {
+				break
+			}
+			for _, hit := range got.Hits.Hits {
+				ids = append(ids, hit.ID)
+			}
+			after = got.Hits.Hits[len(got.Hits.Hits)-1].Sort
+		}
+		return ids
+	}
+
+	t.Run("numeric desc", func(t *testing.T) {
+		ids := walk(t, []interface{}{map[string]interface{}{"num": "desc"}})
+		assert.Equal(t, []string{"9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}, ids)
+	})
+	t.Run("keyword", func(t *testing.T) {
+		ids := walk(t, []interface{}{"name"})
+		assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, ids)
+	})
+	t.Run("date", func(t *testing.T) {
+		ids := walk(t, []interface{}{"-date"})
+		assert.Equal(t, []string{"9", "8", "7", "6", "5", "4", "3", "2", "1", "0"}, ids)
+	})
+	t.Run("date string", func(t *testing.T) {
+		got, err := index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"date"},
+			Size:        10,
+			SearchAfter: []interface{}{"2022-01-01T07:00:00Z"},
+		}, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, got.Hits.Hits, 2)
+	})
+	t.Run("error params", func(t *testing.T) {
+		_, err := index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"num"},
+			Size:        3,
+			From:        3,
+			SearchAfter: []interface{}{1},
+		}, cfg)
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"num"},
+			Size:        3,
+			SearchAfter: []interface{}{1, 2},
+		}, cfg)
+		assert.Error(t, err)
+		_, err = index.Search(&meta.ZincQuery{
+			Query:       map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:        []interface{}{"num"},
+			Size:        3,
+			SearchAfter: []interface{}{"abc"},
+		}, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchNestedAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.aggs.index_1"
+	cfg := config.NewGlobalConfig()
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 3, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("host", meta.NewProperty("keyword"))
+		for i := 0; i < 24; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"num":              float64(i),
+				"host":             "h" + strconv.Itoa(i%3),
+				meta.TimeFieldName: base.Add(time.Duration(i) * 15 * time.Minute).Format(time.RFC3339),
+			}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) map[string]meta.AggregationResponse {
+		resp, err := index.Search(&meta.ZincQuery{Size: 0, Aggregations: aggs}, cfg)
+		assert.NoError(t, err)
+		return resp.Aggregations
+	}
+	sub := func(bucket map[string]interface{}, name string) meta.AggregationResponse {
+		v, ok := bucket[name].(meta.AggregationResponse)
+		assert.True(t, ok, "missing sub aggregation %s", name)
+		return v
+	}
+	bucketsOf := func(agg meta.AggregationResponse) []map[string]interface{} {
+		buckets, ok := agg.Buckets.([]map[string]interface{})
+		assert.True(t, ok)
+		return buckets
+	}
+
+	t.Run("range with terms and sum", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"nums": {
+				Range: &meta.AggregationRange{Field: "num", Ranges: []meta.Range{{From: 0, To: 12}, {From: 12, To: 24}}},
+				Aggregations: map[string]meta.Aggregations{
+					"hosts": {
+						Terms:        &meta.AggregationsTerms{Field: "host"},
+						Aggregations: map[string]meta.Aggregations{"total": {Sum: &meta.AggregationMetric{Field: "num"}}},
+					},
+				},
+			},
+		})
+		buckets := bucketsOf(got["nums"])
+		assert.Len(t, buckets, 2)
+		assert.Equal(t, uint64(12), buckets[0]["doc_count"])
+		hosts := bucketsOf(sub(buckets[0], "hosts"))
+		assert.Len(t, hosts, 3)
+		for _, host := range hosts {
+			assert.Equal(t, uint64(4), host["doc_count"])
+			if host["key"] == "h0" {
+				// 0 + 3 + 6 + 9
+				assert.Equal(t, 18.0, sub(host, "total").Value)
+			}
+		}
+	})
+
+	t.Run("date_range with avg", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"times": {
+				DateRange: &meta.AggregationDateRange{
+					Field:  meta.TimeFieldName,
+					Ranges: []meta.DateRange{{From: base.Format(time.RFC3339), To: base.Add(2 * time.Hour).Format(time.RFC3339)}},
+				},
+				Aggregations: map[string]meta.Aggregations{"avg_num": {Avg: &meta.AggregationMetric{Field: "num"}}},
+			},
+		})
+		buckets := bucketsOf(got["times"])
+		assert.Len(t, buckets, 1)
+		assert.Equal(t, uint64(8), buckets[0]["doc_count"])
+		assert.Equal(t, 3.5, sub(buckets[0], "avg_num").Value)
+	})
+
+	t.Run("date_histogram with terms", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"hours": {
+				DateHistogram: &meta.AggregationDateHistogram{Field: meta.TimeFieldName, FixedInterval: "1h"},
+				Aggregations: map[string]meta.Aggregations{
+					"hosts": {Terms: &meta.AggregationsTerms{Field: "host"}},
+				},
+			},
+		})
+		buckets := bucketsOf(got["hours"])
+		assert.Len(t, buckets, 6)
+		for _, bucket := range buckets {
+			assert.Equal(t, uint64(4), bucket["doc_count"])
+			var count uint64
+			for _, host := range bucketsOf(sub(bucket, "hosts")) {
+				count += host["doc_count"].(uint64)
+			}
+			assert.Equal(t, uint64(4), count)
+		}
+	})
+
+	t.Run("histogram with max", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"nums": {
+				Histogram:    &meta.AggregationHistogram{Field: "num", Interval: 5},
+				Aggregations: map[string]meta.Aggregations{"max_num": {Max: &meta.AggregationMetric{Field: "num"}}},
+			},
+		})
+		buckets := bucketsOf(got["nums"])
+		keys := make([]interface{}, 0, len(buckets))
+		for _, bucket := range buckets {
+			keys = append(keys, bucket["key"])
+		}
+		assert.Equal(t, []interface{}{int64(0), int64(5), int64(10), int64(15), int64(20)}, keys)
+		assert.Equal(t, 9.0, sub(buckets[1], "max_num").Value)
+		assert.Equal(t, 23.0, sub(buckets[4], "max_num").Value)
+	})
+
+	t.Run("auto_date_histogram across shards", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"times": {
+				AutoDateHistogram: &meta.AggregationAutoDateHistogram{Field: meta.TimeFieldName, Buckets: 6, MinimumInterval: "hour"},
+				Aggregations:      map[string]meta.Aggregations{"total": {Sum: &meta.AggregationMetric{Field: "num"}}},
+			},
+		})
+		buckets := bucketsOf(got["times"])
+		assert.Len(t, buckets, 6)
+		var total float64
+		for _, bucket := range buckets {
+			assert.Equal(t, uint64(4), bucket["doc_count"])
+			total += sub(bucket, "total").Value.(float64)
+		}
+		// 0 + 1 + ... + 23
+		assert.Equal(t, 276.0, total)
+	})
+
+	t.Run("terms with date_histogram with sum", func(t *testing.T) {
+		got := search(t, map[string]meta.Aggregations{
+			"hosts": {
+				Terms: &meta.AggregationsTerms{Field: "host"},
+				Aggregations: map[string]meta.Aggregations{
+					"hours": {
+						DateHistogram: &meta.AggregationDateHistogram{Field: meta.TimeFieldName, FixedInterval: "2h"},
+						Aggregations:  map[string]meta.Aggregations{"total": {Sum: &meta.AggregationMetric{Field: "num"}}},
+					},
+				},
+			},
+		})
+		buckets := bucketsOf(got["hosts"])
+		assert.Len(t, buckets, 3)
+		for _, bucket := range buckets {
+			hours := bucketsOf(sub(bucket, "hours"))
+			assert.Len(t, hours, 3)
+			if bucket["key"] == "h1" {
+				// 1 + 4 + 7
+				assert.Equal(t, 12.0, sub(hours[0], "total").Value)
+			}
+		}
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchPipelineAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.pipeline.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 3, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		for i := 0; i < 12; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	// buckets: [0,4) sum 6, [4,8) sum 22, [8,12) sum 38, [12,16) empty
+	histogram := func(subs map[string]meta.Aggregations) meta.Aggregations {
+		subs["total"] = meta.Aggregations{Sum: &meta.AggregationMetric{Field: "num"}}
+		return meta.Aggregations{
+			Histogram: &meta.AggregationHistogram{
+				Field:          "num",
+				Interval:       4,
+				ExtendedBounds: &aggregation.HistogramBound{Min: 0, Max: 15},
+			},
+			Aggregations: subs,
+		}
+	}
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) (map[string]meta.AggregationResponse, error) {
+		resp, err := index.Search(&meta.ZincQuery{Size: 0, Aggregations: aggs}, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Aggregations, nil
+	}
+	bucketsOf := func(agg meta.AggregationResponse) []map[string]interface{} {
+		buckets, ok := agg.Buckets.([]map[string]interface{})
+		assert.True(t, ok)
+		return buckets
+	}
+	valuesOf := func(buckets []map[string]interface{}, name string) []interface{} {
+		values := make([]interface{}, 0, len(buckets))
+		for _, bucket := range buckets {
+			if v, ok := bucket[name].(meta.AggregationResponse); ok {
+				values = append(values, v.Value)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values
+	}
+
+	t.Run("parent and sibling pipelines", func(t *testing.T) {
+		got, err := search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"deriv":     {Derivative: &meta.AggregationPipeline{BucketsPath: "total"}},
+				"cum":       {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "total"}},
+				"cum_count": {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "_count"}},
+				"cum_deriv": {CumulativeSum: &meta.AggregationPipeline{BucketsPath: "deriv"}},
+				"mov": {MovingFn: &meta.AggregationPipeline{
+					BucketsPath: "total",
+					Window:      2,
+					Script:      "MovingFunctions.unweightedAvg(values)",
+				}},
+				"mov_avg": {MovingAvg: &meta.AggregationPipeline{BucketsPath: "total", Window: 2, Model: "linear"}},
+				"avg_num": {BucketScript: &meta.AggregationPipeline{
+					BucketsPath: map[string]interface{}{"s": "total", "c": "_count"},
+					Script:      map[string]interface{}{"source": "params.s / params.c * params.factor", "params": map[string]interface{}{"factor": 2}},
+				}},
+			}),
+			"avg_total":   {AvgBucket: &meta.AggregationPipeline{BucketsPath: "histo>total"}},
+			"max_total":   {MaxBucket: &meta.AggregationPipeline{BucketsPath: "histo>total"}},
+			"min_total":   {MinBucket: &meta.AggregationPipeline{BucketsPath: "histo>total", GapPolicy: "insert_zeros"}},
+			"sum_count":   {SumBucket: &meta.AggregationPipeline{BucketsPath: "histo>_count"}},
+			"sum_avg_num": {SumBucket: &meta.AggregationPipeline{BucketsPath: "histo>avg_num"}},
+		})
+		assert.NoError(t, err)
+		buckets := bucketsOf(got["histo"])
+		assert.Len(t, buckets, 4)
+		assert.Equal(t, []interface{}{nil, 16.0, 16.0, nil}, valuesOf(buckets, "deriv"))
+		assert.Equal(t, []interface{}{6.0, 28.0, 66.0, 66.0}, valuesOf(buckets, "cum"))
+		assert.Equal(t, []interface{}{4.0, 8.0, 12.0, 12.0}, valuesOf(buckets, "cum_count"))
+		assert.Equal(t, []interface{}{0.0, 16.0, 32.0, 32.0}, valuesOf(buckets, "cum_deriv"))
+		assert.Equal(t, []interface{}{nil, 6.0, 14.0, 30.0}, valuesOf(buckets, "mov"))
+		assert.Equal(t, []interface{}{nil, 6.0, 50.0 / 3, 98.0 / 3}, valuesOf(buckets, "mov_avg"))
+		assert.Equal(t, []interface{}{3.0, 11.0, 19.0, nil}, valuesOf(buckets, "avg_num"))
+
+		assert.Equal(t, 22.0, got["avg_total"].Value)
+		assert.Equal(t, 38.0, got["max_total"].Value)
+		assert.Equal(t, []string{"8"}, got["max_total"].Keys)
+		assert.Equal(t, 0.0, got["min_total"].Value)
+		assert.Equal(t, []string{"12"}, got["min_total"].Keys)
+		assert.Equal(t, 12.0, got["sum_count"].Value)
+		assert.Equal(t, 33.0, got["sum_avg_num"].Value)
+	})
+
+	t.Run("bucket_selector and bucket_sort", func(t *testing.T) {
+		got, err := search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"big": {BucketSelector: &meta.AggregationPipeline{
+					BucketsPath: map[string]interface{}{"t": "total"},
+					Script:      "params.t > 10",
+				}},
+				"top": {BucketSort: &meta.AggregationBucketSort{
+					Sort: []interface{}{map[string]interface{}{"total": map[string]interface{}{"order": "desc"}}},
+				}},
+			}),
+		})
+		assert.NoError(t, err)
+		buckets := bucketsOf(got["histo"])
+		// the empty bucket is a gap, kept by bucket_selector and skipped by bucket_sort
+		assert.Equal(t, []interface{}{38.0, 22.0}, valuesOf(buckets, "total"))
+
+		got, err = search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"page": {BucketSort: &meta.AggregationBucketSort{Sort: []interface{}{"_count", "_key"}, From: 1, Size: 2}},
+			}),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []interface{}{6.0, 22.0}, valuesOf(bucketsOf(got["histo"]), "total"))
+	})
+
+	t.Run("pipeline with error params", func(t *testing.T) {
+		_, err := search(t, map[string]meta.Aggregations{
+			"deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "_count"}},
+		})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"deriv": {Derivative: &meta.AggregationPipeline{BucketsPath: "not_exists"}},
+			}),
+		})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"script": {BucketScript: &meta.AggregationPipeline{BucketsPath: map[string]interface{}{"t": "total"}, Script: "params.t +"}},
+			}),
+		})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{
+			"histo": histogram(map[string]meta.Aggregations{
+				"script": {BucketScript: &meta.AggregationPipeline{BucketsPath: map[string]interface{}{"t": "total"}, Script: "params.t > 1"}},
+			}),
+		})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{
+			"histo":     histogram(map[string]meta.Aggregations{}),
+			"max_total": {MaxBucket: &meta.AggregationPipeline{BucketsPath: "total"}},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchMetricAggregations(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.metrics.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 3, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("host", meta.NewProperty("keyword"))
+		for i := 1; i <= 100; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{
+				"num":  float64(i),
+				"host": "h" + strconv.Itoa(i%3),
+				"name": "doc" + strconv.Itoa(i),
+			}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	search := func(t *testing.T, aggs map[string]meta.Aggregations) (map[string]meta.AggregationResponse, error) {
+		resp, err := index.Search(&meta.ZincQuery{Size: 0, Aggregations: aggs}, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Aggregations, nil
+	}
+
+	t.Run("stats and value_count", func(t *testing.T) {
+		got, err := search(t, map[string]meta.Aggregations{
+			"st":         {Stats: &meta.AggregationMetric{Field: "num"}},
+			"ext":        {ExtendedStats: &meta.AggregationMetric{Field: "num"}},
+			"count_num":  {ValueCount: &meta.AggregationMetric{Field: "num"}},
+			"count_host": {ValueCount: &meta.AggregationMetric{Field: "host"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"count": uint64(100), "min": 1.0, "max": 100.0, "avg": 50.5, "sum": 5050.0,
+		}, got["st"].Metrics)
+		assert.Equal(t, 338350.0, got["ext"].Metrics["sum_of_squares"])
+		assert.InDelta(t, 833.25, got["ext"].Metrics["variance"], 0.0001)
+		assert.InDelta(t, 841.6667, got["ext"].Metrics["variance_sampling"], 0.0001)
+		assert.InDelta(t, 28.8661, got["ext"].Metrics["std_deviation"], 0.0001)
+		bounds := got["ext"].Metrics["std_deviation_bounds"].(map[string]interface{})
+		assert.InDelta(t, 50.5+2*28.8661, bounds["upper"], 0.0001)
+		assert.Equal(t, 100.0, got["count_num"].Value)
+		assert.Equal(t, 100.0, got["count_host"].Value)
+
+		// stats are flattened in the response
+		data, err := json.Marshal(got["st"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"count":100,"min":1,"max":100,"avg":50.5,"sum":5050}`, string(data))
+	})
+
+	t.Run("stats without values", func(t *testing.T) {
+		got, err := search(t, map[string]meta.Aggregations{
+			"nums": {
+				Range:        &meta.AggregationRange{Field: "num", Ranges: []meta.Range{{From: 1000, To: 2000}}},
+				Aggregations: map[string]meta.Aggregations{"st": {ExtendedStats: &meta.AggregationMetric{Field: "num"}}},
+			},
+		})
+		assert.NoError(t, err)
+		buckets := got["nums"].Buckets.([]map[string]interface{})
+		st := buckets[0]["st"].(meta.AggregationResponse)
+		assert.Equal(t, uint64(0), st.Metrics["count"])
+		assert.Nil(t, st.Metrics["min"])
+		assert.Nil(t, st.Metrics["avg"])
+		assert.Nil(t, st.Metrics["sum_of_squares"])
+		assert.Nil(t, st.Metrics["std_deviation"])
+	})
+
+	t.Run("percentiles and percentile_ranks", func(t *testing.T) {
+		notKeyed := false
+		got, err := search(t, map[string]meta.Aggregations{
+			"pct":       {Percentiles: &meta.AggregationPercentiles{Field: "num", Percents: []float64{50, 99}}},
+			"pct_list":  {Percentiles: &meta.AggregationPercentiles{Field: "num", Percents: []float64{50}, Keyed: &notKeyed}},
+			"pct_ranks": {PercentileRanks: &meta.AggregationPercentiles{Field: "num", Values: []float64{25, 75}}},
+			"pct_default": {Percentiles: &meta.AggregationPercentiles{
+				Field:   "num",
+				TDigest: &meta.AggregationTDigestOpt{Compression: 200},
+			}},
+		})
+		assert.NoError(t, err)
+		values := got["pct"].Values.(map[string]interface{})
+		assert.InDelta(t, 50.5, values["50.0"], 1)
+		assert.InDelta(t, 99.5, values["99.0"], 1)
+		list := got["pct_list"].Values.([]map[string]interface{})
+		assert.Len(t, list, 1)
+		assert.Equal(t, 50.0, list[0]["key"])
+		assert.InDelta(t, 50.5, list[0]["value"], 1)
+		ranks := got["pct_ranks"].Values.(map[string]interface{})
+		assert.InDelta(t, 25, ranks["25.0"], 1)
+		assert.InDelta(t, 75, ranks["75.0"], 1)
+		assert.Len(t, got["pct_default"].Values, 7)
+	})
+
+	t.Run("top_hits in buckets", func(t *testing.T) {
+		got, err := search(t, map[string]meta.Aggregations{
+			"hosts": {
+				Terms: &meta.AggregationsTerms{Field: "host"},
+				Aggregations: map[string]meta.Aggregations{
+					"top": {TopHits: &meta.AggregationTopHits{
+						Size:   2,
+						Sort:   []interface{}{"-num"},
+						Source: []interface{}{"num"},
+					}},
+					"st": {Stats: &meta.AggregationMetric{Field: "num"}},
+				},
+			},
+			"best":    {TopHits: &meta.AggregationTopHits{From: 1, Size: 2, Sort: []interface{}{"num"}}},
+			"max_avg": {MaxBucket: &meta.AggregationPipeline{BucketsPath: "hosts>st.avg"}},
+		})
+		assert.NoError(t, err)
+		buckets := got["hosts"].Buckets.([]map[string]interface{})
+		assert.Len(t, buckets, 3)
+		for _, bucket := range buckets {
+			if bucket["key"] != "h0" {
+				continue
+			}
+			top := bucket["top"].(meta.AggregationResponse).Hits
+			assert.Equal(t, 33, top.Total.Value)
+			assert.Len(t, top.Hits, 2)
+			assert.Equal(t, "99", top.Hits[0].ID)
+			assert.Equal(t, "96", top.Hits[1].ID)
+			assert.Equal(t, []interface{}{99.0}, top.Hits[0].Sort)
+			source := top.Hits[0].Source.(map[string]interface{})
+			assert.Equal(t, 99.0, source["num"])
+			assert.NotContains(t, source, "name")
+		}
+
+		best := got["best"].Hits
+		assert.Equal(t, 100, best.Total.Value)
+		assert.Len(t, best.Hits, 2)
+		assert.Equal(t, "2", best.Hits[0].ID)
+		assert.Equal(t, "3", best.Hits[1].ID)
+		assert.Equal(t, indexName, best.Hits[0].Index)
+		assert.Equal(t, "doc2", best.Hits[0].Source.(map[string]interface{})["name"])
+
+		// h0: 3, 6, ..., 99 has the max avg 51
+		assert.Equal(t, 51.0, got["max_avg"].Value)
+		assert.Equal(t, []string{"h0"}, got["max_avg"].Keys)
+	})
+
+	t.Run("metrics with error params", func(t *testing.T) {
+		_, err := search(t, map[string]meta.Aggregations{"st": {Stats: &meta.AggregationMetric{Field: "host"}}})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{"pct": {Percentiles: &meta.AggregationPercentiles{Field: "num", Percents: []float64{101}}}})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{"pct": {PercentileRanks: &meta.AggregationPercentiles{Field: "num"}}})
+		assert.Error(t, err)
+		_, err = search(t, map[string]meta.Aggregations{"top": {TopHits: &meta.AggregationTopHits{Size: 101}}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
+
+func TestIndex_SearchCollapse(t *testing.T) {
+	var err error
+	var index *Index
+	indexName := "Search.collapse.index_1"
+	cfg := config.NewGlobalConfig()
+	t.Run("Prepare", func(t *testing.T) {
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NotNil(t, index)
+		err = StoreIndex(index)
+		assert.NoError(t, err)
+
+		index.GetMappings().SetProperty("family", meta.NewProperty("keyword"))
+		index.GetMappings().SetProperty("name", meta.NewProperty("text"))
+		// family a has the 20 most expensive products, then b, c and the products without family
+		for i := 0; i < 30; i++ {
+			doc := map[string]interface{}{"price": float64(100 - i), "name": "product " + strconv.Itoa(i)}
+			switch {
+			case i < 20:
+				doc["family"] = "a"
+			case i < 25:
+				doc["family"] = "b"
+			case i < 28:
+				doc["family"] = "c"
+			}
+			err := index.CreateDocument(strconv.Itoa(i), doc, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	})
+
+	search := func(t *testing.T, from, size int, collapse *meta.Collapse) *meta.SearchResponse {
+		got, err := index.Search(&meta.ZincQuery{
+			Query:    map[string]interface{}{"match_all": map[string]interface{}{}},
+			Sort:     []interface{}{map[string]interface{}{"price": "desc"}},
+			From:     from,
+			Size:     size,
+			Collapse: collapse,
+		}, cfg)
+		assert.NoError(t, err)
+		return got
+	}
+	ids := func(hits []meta.Hit) []string {
+		rv := make([]string, len(hits))
+		for i, hit := range hits {
+			rv[i] = hit.ID
+		}
+		return rv
+	}
+
+	t.Run("best hit per group", func(t *testing.T) {
+		got := search(t, 0, 10, &meta.Collapse{Field: "family"})
+		assert.Equal(t, []string{"0", "20", "25", "28"}, ids(got.Hits.Hits))
+		assert.Equal(t, 30, got.Hits.Total.Value)
+		assert.Equal(t, []interface{}{"a"}, got.Hits.Hits[0].Fields["family"])
+		assert.Equal(t, []interface{}{nil}, got.Hits.Hits[3].Fields["family"])
+	})
+
+	t.Run("paginate groups", func(t *testing.T) {
+		got := search(t, 1, 2, &meta.Collapse{Field: "family"})
+		assert.Equal(t, []string{"20", "25"}, ids(got.Hits.Hits))
+	})
+
+	t.Run("inner hits", func(t *testing.T) {
+		got := search(t, 0, 2, &meta.Collapse{
+			Field: "family",
+			InnerHits: &meta.InnerHits{
+				Name: "cheapest",
+				Size: 2,
+				Sort: []interface{}{map[string]interface{}{"price": "asc"}},
+			},
+			MaxConcurrentGroupSearches: 1,
+		})
+		assert.Equal(t, []string{"0", "20"}, ids(got.Hits.Hits))
+		inner := got.Hits.Hits[0].InnerHits["cheapest"].Hits
+		assert.Equal(t, 20, inner.Total.Value)
+		assert.Equal(t, []string{"19", "18"}, ids(inner.Hits))
+		inner = got.Hits.Hits[1].InnerHits["cheapest"].Hits
+		assert.Equal(t, 5, inner.Total.Value)
+		assert.Equal(t, []string{"24", "23"}, ids(inner.Hits))
+	})
+
+	t.Run("inner hits of missing values", func(t *testing.T) {
+		got := search(t, 3, 1, &meta.Collapse{Field: "family", InnerHits: &meta.InnerHits{}})
+		assert.Equal(t, []string{"28"}, ids(got.Hits.Hits))
+		inner := got.Hits.Hits[0].InnerHits["family"].Hits
+		assert.Equal(t, 2, inner.Total.Value)
+	})
+
+	t.Run("error params", func(t *testing.T) {
+		for _, field := range []string{"name", "unknown", ""} {
+			_, err := index.Search(&meta.ZincQuery{Size: 10, Collapse: &meta.Collapse{Field: field}}, cfg)
+			assert.Error(t, err, field)
+		}
+		_, err := ZINC_SCROLL_LIST.NewScroll([]string{indexName}, &meta.ZincQuery{Size: 10, Collapse: &meta.Collapse{Field: "family"}}, time.Minute, cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		err = DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,976 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blugelabs/bluge"
+	blugeindex "github.com/blugelabs/bluge/index"
+	segment "github.com/blugelabs/bluge_segment_api"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+// The layout of a fs repository:
+//
+//	snapshots/<snapshot>/snapshot.json                          the manifest
+//	snapshots/<snapshot>/indices/<index>/<shard>/<second>.snp    the bluge snapshot of a second shard
+//	snapshots/<snapshot>/indices/<index>/<shard>/wal.json        the WAL entries not in the segments yet
+//	indices/<index>/<shard>/<second>/<segment>-<size>-<crc>.seg  the segments shared by the snapshots
+//
+// Segments are immutable, a snapshot only copies the segments not in the repository yet.
+
+// snapshotLock serializes the snapshot operations of all repositories,
+// deleting a snapshot removes the segments no other snapshot refers to.
+var snapshotLock sync.Mutex
+
+// snapshotPersistTimeout is how long a snapshot waits for a second shard to persist its segments
+const snapshotPersistTimeout = time.Minute
+
+// snapshotOpenRetries and snapshotOpenRetryDelay bound the retries of loading a persisted snapshot,
+// the delay doubles after every attempt to let a merge introduce its snapshot
+const (
+	snapshotOpenRetries    = 5
+	snapshotOpenRetryDelay = 50 * time.Millisecond
+)
+
+type snapshotManifest struct {
+	Snapshot  *meta.Snapshot            `json:"snapshot"`
+	Indexes   map[string]*snapshotIndex `json:"indexes"`
+	Templates []*meta.Template          `json:"templates,omitempty"`
+}
+
+type snapshotIndex struct {
+	Index   *meta.Index               `json:"index"`
+	Aliases []string                  `json:"aliases,omitempty"`
+	Shards  map[string]*snapshotShard `json:"shards"`
+}
+
+type snapshotShard struct {
+	WALPosition uint64                 `json:"wal_position"` // the last WAL entry in the segments
+	WALEntries  int                    `json:"wal_entries"`  // the entries after the position
+	Shards      []*snapshotSecondShard `json:"shards"`
+}
+
+type snapshotSecondShard struct {
+	ID       int64             `json:"id"`
+	Epoch    uint64            `json:"epoch"`
+	Segments []snapshotSegment `json:"segments"`
+}
+
+type snapshotSegment struct {
+	ID   uint64 `json:"id"`
+	Blob string `json:"blob"`
+	Size int64  `json:"size"`
+}
+
+// PutSnapshotRepository registers a fs repository
+func PutSnapshotRepository(name string, repo *meta.SnapshotRepository, cfg *config.Config) error {
+	if err := checkResourceName("repository", name); err != nil {
+		return err
+	}
+	if repo.Type != "fs" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] repository type [%s] doesn't support, only fs", name, repo.Type))
+	}
+	location, err := repositoryLocation(repo.Settings.Location, cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(location, 0755); err != nil {
+		return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[%s] cannot create repository location", name)).Cause(err)
+	}
+	repo.Name = name
+	return metadata.Repository.Set(name, *repo)
+}
+
+// GetSnapshotRepository returns the repository, a repository_missing_exception if it doesn't exist
+func GetSnapshotRepository(name string) (*meta.SnapshotRepository, error) {
+	repo, err := metadata.Repository.Get(name)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, errors.New(errors.ErrorTypeRepositoryMissingException, fmt.Sprintf("[%s] missing", name))
+		}
+		return nil, err
+	}
+	return repo, nil
+}
+
+func ListSnapshotRepositories() ([]*meta.SnapshotRepository, error) {
+	return metadata.Repository.List(0, 0)
+}
+
+// DeleteSnapshotRepository unregisters the repository, the snapshots in it are kept
+func DeleteSnapshotRepository(name string) error {
+	if _, err := GetSnapshotRepository(name); err != nil {
+		return err
+	}
+	return metadata.Repository.Delete(name)
+}
+
+// repositoryLocation resolves the location of a fs repository,
+// a relative location is under ZINC_SNAPSHOT_PATH_REPO and an absolute one must be under it.
+func repositoryLocation(location string, cfg *config.Config) (string, error) {
+	if cfg.SnapshotPathRepo == "" {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, "fs repositories are disabled, ZINC_SNAPSHOT_PATH_REPO is not set")
+	}
+	if location == "" {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, "[location] is required for fs repositories")
+	}
+	root, err := filepath.Abs(cfg.SnapshotPathRepo)
+	if err != nil {
+		return "", errors.New(errors.ErrorTypeRuntimeException, "invalid ZINC_SNAPSHOT_PATH_REPO").Cause(err)
+	}
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(root, location)
+	}
+	location = filepath.Clean(location)
+	rel, err := filepath.Rel(root, location)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("location [%s] doesn't match ZINC_SNAPSHOT_PATH_REPO", location))
+	}
+	return location, nil
+}
+
+func openRepository(name string, cfg *config.Config) (string, error) {
+	repo, err := GetSnapshotRepository(name)
+	if err != nil {
+		return "", err
+	}
+	return repositoryLocation(repo.Settings.Location, cfg)
+}
+
+func checkResourceName(kind, name string) error {
+	if name == "" || strings.HasPrefix(name, "_") || !indexNameRe.MatchString(name) {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("%s name [%s] is invalid, just accept [a-zA-Z0-9_.-] and cannot start with _", kind, name))
+	}
+	return nil
+}
+
+// CreateSnapshot snapshots the indexes into the repository, without wait it returns once the snapshot started.
+// Every first layer shard is captured with the WAL consumer paused, so the segments and the WAL position match.
+func CreateSnapshot(repoName, name string, req *meta.SnapshotRequest, wait bool, cfg *config.Config) (*meta.Snapshot, error) {
+	root, err := openRepository(repoName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResourceName("snapshot", name); err != nil {
+		return nil, err
+	}
+	patterns, err := snapshotIndexPatterns(req.Indices)
+	if err != nil {
+		return nil, err
+	}
+	indexNames, err := matchSnapshotIndexes(patterns, ZINC_INDEX_LIST.ListName(), req.IgnoreUnavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotLock.Lock()
+	if ok, _ := zutils.IsExist(snapshotManifestPath(root, name)); ok {
+		snapshotLock.Unlock()
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] snapshot with the same name already exists", repoName, name))
+	}
+	now := time.Now()
+	manifest := &snapshotManifest{
+		Snapshot: &meta.Snapshot{
+			Snapshot:           name,
+			UUID:               newSearchContextID(),
+			Repository:         repoName,
+			Version:            meta.Version,
+			Indices:            indexNames,
+			IncludeGlobalState: req.IncludeGlobalState == nil || *req.IncludeGlobalState,
+			State:              meta.SnapshotStateInProgress,
+			StartTime:          now,
+			StartTimeInMillis:  now.UnixMilli(),
+		},
+		Indexes: make(map[string]*snapshotIndex, len(indexNames)),
+	}
+	if err := writeSnapshotManifest(root, manifest); err != nil {
+		snapshotLock.Unlock()
+		return nil, err
+	}
+
+	run := func() error {
+		defer snapshotLock.Unlock()
+		err := createSnapshot(root, manifest)
+		snap := manifest.Snapshot
+		snap.State = meta.SnapshotStateSuccess
+		if err != nil {
+			log.Error().Err(err).Str("repository", repoName).Str("snapshot", name).Msg("snapshot failed")
+			snap.State = meta.SnapshotStateFailed
+			snap.Reason = err.Error()
+		}
+		snap.EndTime = time.Now()
+		snap.EndTimeInMillis = snap.EndTime.UnixMilli()
+		snap.DurationInMillis = snap.EndTimeInMillis - snap.StartTimeInMillis
+		if werr := writeSnapshotManifest(root, manifest); werr != nil && err == nil {
+			err = werr
+		}
+		return err
+	}
+	if !wait {
+		snap := *manifest.Snapshot
+		go func() { _ = run() }()
+		return &snap, nil
+	}
+	if err := run(); err != nil {
+		return nil, err
+	}
+	return manifest.Snapshot, nil
+}
+
+func createSnapshot(root string, manifest *snapshotManifest) error {
+	staging := filepath.Join(root, "tmp", manifest.Snapshot.UUID)
+	defer os.RemoveAll(staging)
+
+	for _, name := range manifest.Snapshot.Indices {
+		index, ok := GetIndex(name)
+		if !ok {
+			return fmt.Errorf("index [%s] was deleted during the snapshot", name)
+		}
+		if index.GetStorageType() != "disk" {
+			return fmt.Errorf("index [%s] storage type [%s] doesn't support snapshot", name, index.GetStorageType())
+		}
+		snapIndex, err := snapshotIndexShards(root, staging, manifest, index)
+		if err != nil {
+			return err
+		}
+		snapIndex.Aliases = ZINC_INDEX_ALIAS_LIST.GetAliasesForIndex(name)
+		manifest.Indexes[name] = snapIndex
+	}
+
+	if manifest.Snapshot.IncludeGlobalState {
+		templates, err := ListTemplates("")
+		if err != nil {
+			return err
+		}
+		manifest.Templates = templates
+	}
+	return nil
+}
+
+func snapshotIndexShards(root, staging string, manifest *snapshotManifest, index *Index) (*snapshotIndex, error) {
+	snap := manifest.Snapshot
+	snapIndex := &snapshotIndex{Shards: make(map[string]*snapshotShard, len(index.shards))}
+	for id, shard := range index.shards {
+		snap.Shards.Total++
+		snapShard, err := snapshotShardSegments(root, staging, manifest, shard)
+		if err != nil {
+			snap.Shards.Failed++
+			return nil, fmt.Errorf("index [%s] shard [%s] snapshot error: %s", index.GetName(), id, err.Error())
+		}
+		snap.Shards.Successful++
+		snapIndex.Shards[id] = snapShard
+	}
+
+	// the metadata is read after the shards, it may have more second shards than the captured ones
+	data, err := index.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	snapIndex.Index = new(meta.Index)
+	if err := json.Unmarshal(data, snapIndex.Index); err != nil {
+		return nil, err
+	}
+	for id, snapShard := range snapIndex.Shards {
+		ref := snapIndex.Index.Shards[id]
+		ref.ShardNum = int64(len(snapShard.Shards))
+		ref.Shards = ref.Shards[:ref.ShardNum]
+	}
+	snapIndex.Index.Stats.WALSize = 0
+	snapIndex.Index.Stats.OpenPITs = 0
+	return snapIndex, nil
+}
+
+// snapshotShardSegments stores the segments of every second shard missing in the repository, and the WAL entries
+// not consumed yet
+func snapshotShardSegments(root, staging string, manifest *snapshotManifest, shard *IndexShard) (*snapshotShard, error) {
+	snapshots, position, entries, err := shard.snapshotReaders()
+	defer func() {
+		for _, s := range snapshots {
+			_ = s.Close()
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := manifest.Snapshot
+	shardPath := filepath.Join(shard.GetIndexName(), shard.GetID())
+	snapShard := &snapshotShard{WALPosition: position, WALEntries: len(entries)}
+	for i, s := range snapshots {
+		second := fmt.Sprintf("%06x", i)
+		dir := &snapshotDirectory{
+			root:     root,
+			staging:  staging,
+			segDir:   filepath.Join(shard.dataPath, shardPath, second),
+			blobDir:  filepath.Join(shardPath, second),
+			snapPath: filepath.Join(root, "snapshots", snap.Snapshot, "indices", shardPath, second+".snp"),
+			stats:    &snap.Stats,
+			second:   &snapshotSecondShard{ID: int64(i)},
+		}
+		if err := s.Backup(dir, nil); err != nil {
+			return nil, err
+		}
+		snapShard.Shards = append(snapShard.Shards, dir.second)
+	}
+
+	if len(entries) > 0 {
+		dst := filepath.Join(root, "snapshots", snap.Snapshot, "indices", shardPath, "wal.json")
+		if err := writeSnapshotWAL(dst, entries); err != nil {
+			return nil, err
+		}
+	}
+	return snapShard, nil
+}
+
+// snapshotReaders returns the persisted bluge snapshots of all the second shards, the position of the last WAL entry
+// written to them and the WAL entries after it. The WAL consumer is paused meanwhile.
+func (s *IndexShard) snapshotReaders() ([]*blugeindex.Snapshot, uint64, [][]byte, error) {
+	if err := s.OpenWAL(); err != nil {
+		return nil, 0, nil, err
+	}
+	s.consumeLock.Lock()
+	defer s.consumeLock.Unlock()
+
+	_, position, err := s.readRedoLog(RedoActionWrite)
+	if err != nil && err.Error() != errors.ErrNotFound.Error() {
+		return nil, 0, nil, err
+	}
+	lastID, err := s.wal.LastIndex()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var entries [][]byte
+	for id := position + 1; id <= lastID; id++ {
+		entry, err := s.wal.Read(id)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		entries = append(entries, append([]byte(nil), entry...))
+	}
+
+	snapshots := make([]*blugeindex.Snapshot, 0, s.GetShardNum())
+	for i := int64(0); i < s.GetShardNum(); i++ {
+		w, err := s.GetWriter(i)
+		if err != nil {
+			return snapshots, 0, nil, err
+		}
+		if err := persistWriter(w); err != nil {
+			return snapshots, 0, nil, err
+		}
+		// the snapshot is loaded from the disk, unlike a reader it tells the segments to back up
+		path := filepath.Join(s.dataPath, s.GetIndexName(), s.GetID(), fmt.Sprintf("%06x", i))
+		snapshot, err := openPersistedSnapshot(path)
+		if err != nil {
+			return snapshots, 0, nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, position, entries, nil
+}
+
+// persistWriter blocks until all the changes of the writer are on the disk,
+// the callback of an empty batch is called once the snapshot introducing it is persisted
+func persistWriter(w *bluge.Writer) error {
+	persisted := make(chan error, 1)
+	batch := bluge.NewBatch()
+	batch.SetPersistedCallback(func(err error) {
+		persisted <- err
+	})
+	if err := w.Batch(batch); err != nil {
+		return err
+	}
+	select {
+	case err := <-persisted:
+		return err
+	case <-time.After(snapshotPersistTimeout):
+		return fmt.Errorf("timeout persisting the segments")
+	}
+}
+
+// openPersistedSnapshot loads the last persisted snapshot, a concurrent merge may remove its files while loading
+func openPersistedSnapshot(path string) (*blugeindex.Snapshot, error) {
+	var err error
+	delay := snapshotOpenRetryDelay
+	for i := 0; i < snapshotOpenRetries; i++ {
+		if i > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var snapshot *blugeindex.Snapshot
+		if snapshot, err = blugeindex.OpenReader(blugeindex.DefaultConfig(path)); err == nil {
+			return snapshot, nil
+		}
+		log.Debug().Err(err).Str("path", path).Int("attempt", i+1).Msg("open persisted snapshot failed")
+	}
+	return nil, err
+}
+
+// snapshotDirectory receives the backup of a second shard. The blob name of a segment contains its size and the
+// crc32 of its footer because a recreated index may reuse the segment ids, a segment already in the repository
+// isn't read or written again.
+type snapshotDirectory struct {
+	root     string
+	staging  string
+	segDir   string // the local directory of the second shard
+	blobDir  string
+	snapPath string
+	stats    *meta.SnapshotStats
+	second   *snapshotSecondShard
+}
+
+func (d *snapshotDirectory) Persist(kind string, id uint64, w blugeindex.WriterTo, closeCh chan struct{}) error {
+	if kind == blugeindex.ItemKindSnapshot {
+		d.second.Epoch = id
+		return writeSnapshotItem(d.snapPath, w, closeCh)
+	}
+
+	name, size, err := d.segmentBlobName(id, w, closeCh)
+	if err != nil {
+		return err
+	}
+	blob := filepath.ToSlash(filepath.Join(d.blobDir, name))
+	d.second.Segments = append(d.second.Segments, snapshotSegment{ID: id, Blob: blob, Size: size})
+	d.stats.Total.FileCount++
+	d.stats.Total.SizeInBytes += size
+
+	dst := filepath.Join(d.root, "indices", blob)
+	if ok, _ := zutils.IsExist(dst); ok {
+		return nil
+	}
+	// the segment is staged first, the repository never has a partial segment
+	src := filepath.Join(d.staging, blob)
+	if err := writeSnapshotItem(src, w, closeCh); err != nil {
+		return err
+	}
+	if err := moveFile(src, dst); err != nil {
+		return err
+	}
+	d.stats.Incremental.FileCount++
+	d.stats.Incremental.SizeInBytes += size
+	return nil
+}
+
+// segmentBlobName names the blob by the size and the crc32 in the footer of the local segment file, the content
+// is hashed only if the file is already removed by a merge
+func (d *snapshotDirectory) segmentBlobName(id uint64, w blugeindex.WriterTo, closeCh chan struct{}) (string, int64, error) {
+	size, crc, err := segmentChecksum(filepath.Join(d.segDir, fmt.Sprintf("%012x", id)+blugeindex.ItemKindSegment))
+	if err == nil {
+		return fmt.Sprintf("%012x-%x-%08x.seg", id, size, crc), size, nil
+	}
+	h := sha256.New()
+	if size, err = w.WriteTo(h, closeCh); err != nil {
+		return "", 0, err
+	}
+	return fmt.Sprintf("%012x-%s.seg", id, hex.EncodeToString(h.Sum(nil))[:16]), size, nil
+}
+
+func (d *snapshotDirectory) Setup(readOnly bool) error { return nil }
+
+func (d *snapshotDirectory) List(kind string) ([]uint64, error) { return nil, nil }
+
+func (d *snapshotDirectory) Load(kind string, id uint64) (*segment.Data, io.Closer, error) {
+	return nil, nil, fmt.Errorf("snapshot directory is write only")
+}
+
+func (d *snapshotDirectory) Remove(kind string, id uint64) error { return nil }
+
+func (d *snapshotDirectory) Stats() (numItems uint64, numBytes uint64) { return 0, 0 }
+
+func (d *snapshotDirectory) Sync() error { return nil }
+
+func (d *snapshotDirectory) Lock() error { return nil }
+
+func (d *snapshotDirectory) Unlock() error { return nil }
+
+// segmentChecksum returns the size of the segment file and the crc32 of its content stored in the last bytes
+func segmentChecksum(path string) (int64, uint32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Size() < 4 {
+		return 0, 0, fmt.Errorf("segment [%s] is too short", path)
+	}
+	crc := make([]byte, 4)
+	if _, err := f.ReadAt(crc, info.Size()-4); err != nil {
+		return 0, 0, err
+	}
+	return info.Size(), binary.BigEndian.Uint32(crc), nil
+}
+
+func writeSnapshotItem(path string, w blugeindex.WriterTo, closeCh chan struct{}) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.WriteTo(f, closeCh); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeSnapshotWAL(path string, entries [][]byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		_, _ = w.Write(entry)
+		_ = w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readSnapshotWAL(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<30)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			entries = append(entries, append([]byte(nil), scanner.Bytes()...))
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ListSnapshots returns the snapshots of the repository sorted by start time
+func ListSnapshots(repoName string, cfg *config.Config) ([]*meta.Snapshot, error) {
+	root, err := openRepository(repoName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := listSnapshotManifests(root)
+	if err != nil {
+		return nil, err
+	}
+	snaps := make([]*meta.Snapshot, 0, len(manifests))
+	for _, manifest := range manifests {
+		snaps = append(snaps, manifest.Snapshot)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].StartTimeInMillis < snaps[j].StartTimeInMillis })
+	return snaps, nil
+}
+
+func GetSnapshot(repoName, name string, cfg *config.Config) (*meta.Snapshot, error) {
+	root, err := openRepository(repoName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := readSnapshotManifest(root, repoName, name)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Snapshot, nil
+}
+
+// DeleteSnapshot deletes the snapshot and the segments no other snapshot refers to
+func DeleteSnapshot(repoName, name string, cfg *config.Config) error {
+	root, err := openRepository(repoName, cfg)
+	if err != nil {
+		return err
+	}
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	if _, err := readSnapshotManifest(root, repoName, name); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(root, "snapshots", name)); err != nil {
+		return err
+	}
+
+	manifests, err := listSnapshotManifests(root)
+	if err != nil {
+		return err
+	}
+	used := make(map[string]struct{})
+	for _, manifest := range manifests {
+		for _, snapIndex := range manifest.Indexes {
+			for _, snapShard := range snapIndex.Shards {
+				for _, second := range snapShard.Shards {
+					for _, segment := range second.Segments {
+						used[segment.Blob] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+	blobs := filepath.Join(root, "indices")
+	return filepath.Walk(blobs, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".seg" {
+			return nil
+		}
+		rel, _ := filepath.Rel(blobs, path)
+		if _, ok := used[filepath.ToSlash(rel)]; !ok {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// RestoreSnapshot restores the indexes of the snapshot, the restored index can't exist.
+// The WAL entries captured with the segments are written to the WAL of the restored index again.
+func RestoreSnapshot(repoName, name string, req *meta.RestoreRequest, cfg *config.Config) (*meta.RestoreInfo, error) {
+	root, err := openRepository(repoName, cfg)
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := snapshotIndexPatterns(req.Indices)
+	if err != nil {
+		return nil, err
+	}
+	var rename *regexp.Regexp
+	if req.RenamePattern != "" {
+		if rename, err = regexp.Compile(req.RenamePattern); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[rename_pattern] %s", err.Error()))
+		}
+	}
+
+	snapshotLock.Lock()
+	defer snapshotLock.Unlock()
+	manifest, err := readSnapshotManifest(root, repoName, name)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Snapshot.State != meta.SnapshotStateSuccess {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s:%s] snapshot state is [%s], only successful snapshots can be restored", repoName, name, manifest.Snapshot.State))
+	}
+	indexNames, err := matchSnapshotIndexes(patterns, manifest.Snapshot.Indices, req.IgnoreUnavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(indexNames))
+	for _, indexName := range indexNames {
+		target := indexName
+		if rename != nil {
+			target = rename.ReplaceAllString(indexName, req.RenameReplacement)
+		}
+		if err := CheckIndexName(target); err != nil {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, err.Error())
+		}
+		if _, ok := GetIndex(target); ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot restore index [%s] because an index with the same name already exists", target))
+		}
+		for k, v := range targets {
+			if v == target {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("indices [%s] and [%s] are renamed into the same index [%s]", k, indexName, target))
+			}
+		}
+		targets[indexName] = target
+	}
+
+	info := &meta.RestoreInfo{Snapshot: name, Indices: make([]string, 0, len(indexNames))}
+	for _, indexName := range indexNames {
+		snapIndex := manifest.Indexes[indexName]
+		if err := restoreIndex(root, name, indexName, targets[indexName], snapIndex, cfg); err != nil {
+			return nil, err
+		}
+		info.Indices = append(info.Indices, targets[indexName])
+		info.Shards.Total += len(snapIndex.Shards)
+		info.Shards.Successful += len(snapIndex.Shards)
+
+		if req.IncludeAliases == nil || *req.IncludeAliases {
+			for _, alias := range snapIndex.Aliases {
+				if indexes, _ := ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(alias); zutils.SliceExists(indexes, targets[indexName]) {
+					continue
+				}
+				if err := ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(alias, []string{targets[indexName]}); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if req.IncludeGlobalState == nil || *req.IncludeGlobalState {
+		for _, tpl := range manifest.Templates {
+			if err := metadata.Template.Set(tpl.Name, *tpl); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return info, nil
+}
+
+func restoreIndex(root, snapshot, indexName, target string, snapIndex *snapshotIndex, cfg *config.Config) (err error) {
+	dataPath := filepath.Join(cfg.DataPath, target)
+	if ok, _ := zutils.IsExist(dataPath); ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("cannot restore index [%s] because its data path already exists", target))
+	}
+	stored := false
+	defer func() {
+		if err == nil {
+			return
+		}
+		if stored {
+			_ = DeleteIndex(target, cfg.DataPath)
+		} else {
+			_ = os.RemoveAll(dataPath)
+		}
+	}()
+
+	for id, snapShard := range snapIndex.Shards {
+		snapPath := filepath.Join(root, "snapshots", snapshot, "indices", indexName, id)
+		for _, second := range snapShard.Shards {
+			dir := filepath.Join(dataPath, id, fmt.Sprintf("%06x", second.ID))
+			if err = os.MkdirAll(dir, 0755); err != nil {
+				return err
+			}
+			for _, segment := range second.Segments {
+				src := filepath.Join(root, "indices", filepath.FromSlash(segment.Blob))
+				if err = copyFile(src, filepath.Join(dir, fmt.Sprintf("%012x.seg", segment.ID))); err != nil {
+					return err
+				}
+			}
+			src := filepath.Join(snapPath, fmt.Sprintf("%06x.snp", second.ID))
+			if err = copyFile(src, filepath.Join(dir, fmt.Sprintf("%012x.snp", second.Epoch))); err != nil {
+				return err
+			}
+		}
+	}
+
+	ref := *snapIndex.Index
+	ref.Name = target
+	index, err := loadIndex(&ref, cfg)
+	if err != nil {
+		return err
+	}
+	index.ref.Version = meta.Version
+	if err = StoreIndex(index); err != nil {
+		return err
+	}
+	stored = true
+
+	for id, snapShard := range snapIndex.Shards {
+		if snapShard.WALEntries == 0 {
+			continue
+		}
+		var entries [][]byte
+		entries, err = readSnapshotWAL(filepath.Join(root, "snapshots", snapshot, "indices", indexName, id, "wal.json"))
+		if err != nil {
+			return err
+		}
+		shard := index.shards[id]
+		if err = shard.OpenWAL(); err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err = shard.wal.Write(entry); err != nil {
+				return err
+			}
+		}
+		shard.resetVersions()
+	}
+	return nil
+}
+
+func snapshotManifestPath(root, name string) string {
+	return filepath.Join(root, "snapshots", name, "snapshot.json")
+}
+
+func readSnapshotManifest(root, repoName, name string) (*snapshotManifest, error) {
+	data, err := os.ReadFile(snapshotManifestPath(root, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New(errors.ErrorTypeSnapshotMissingException, fmt.Sprintf("[%s:%s] is missing", repoName, name))
+		}
+		return nil, err
+	}
+	manifest := new(snapshotManifest)
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeSnapshotManifest(root string, manifest *snapshotManifest) error {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	path := snapshotManifestPath(root, manifest.Snapshot.Snapshot)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func listSnapshotManifests(root string) ([]*snapshotManifest, error) {
+	dirs, err := os.ReadDir(filepath.Join(root, "snapshots"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	manifests := make([]*snapshotManifest, 0, len(dirs))
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		manifest, err := readSnapshotManifest(root, "", dir.Name())
+		if err != nil {
+			if errors.As(err, new(*errors.Error)) {
+				continue // not a snapshot
+			}
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// snapshotIndexPatterns accepts "a,b-*" or ["a", "b-*"], empty means all
+func snapshotIndexPatterns(v interface{}) ([]string, error) {
+	var patterns []string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		patterns = strings.Split(v, ",")
+	case []string:
+		patterns = v
+	case []interface{}:
+		for _, p := range v {
+			s, ok := p.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[indices] doesn't support values of type: %T", p))
+			}
+			patterns = append(patterns, s)
+		}
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[indices] doesn't support values of type: %T", v))
+	}
+	rv := patterns[:0]
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" && p != "_all" && p != "*" {
+			rv = append(rv, p)
+		} else if p != "" {
+			return nil, nil
+		}
+	}
+	return rv, nil
+}
+
+// matchSnapshotIndexes returns the sorted names matching the patterns, a missing concrete name is an error
+func matchSnapshotIndexes(patterns, names []string, ignoreUnavailable bool) ([]string, error) {
+	if len(patterns) == 0 {
+		rv := append([]string(nil), names...)
+		sort.Strings(rv)
+		return rv, nil
+	}
+	matched := make(map[string]struct{})
+	for _, pattern := range patterns {
+		found := false
+		for _, name := range names {
+			if isMatchIndex(name, pattern) {
+				matched[name] = struct{}{}
+				found = true
+			}
+		}
+		if !found && !strings.Contains(pattern, "*") && !ignoreUnavailable {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no such index [%s]", pattern))
+		}
+	}
+	rv := make([]string, 0, len(matched))
+	for name := range matched {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv, nil
+}
+
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,200 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestSnapshot(t *testing.T) {
+	indexName := "snapshot.index_1"
+	repoName := "snapshot_repo"
+	cfg := config.NewGlobalConfig()
+	cfg.SnapshotPathRepo = t.TempDir()
+
+	var index *Index
+	createDocuments := func(t *testing.T, from, to int) {
+		for i := from; i < to; i++ {
+			err := index.CreateDocument(strconv.Itoa(i), map[string]interface{}{"num": float64(i)}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		// wait for WAL write to index
+		waitForRefresh(t, index)
+	}
+	count := func(t *testing.T, name string) int {
+		resp, err := MultiSearch([]string{name}, &meta.ZincQuery{Size: 0}, cfg)
+		assert.NoError(t, err)
+		return resp.Hits.Total.Value
+	}
+
+	t.Run("prepare", func(t *testing.T) {
+		var err error
+		index, err = NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		assert.NoError(t, ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias("snapshot.alias", []string{indexName}))
+		createDocuments(t, 0, 10)
+	})
+
+	t.Run("repository", func(t *testing.T) {
+		err := PutSnapshotRepository(repoName, &meta.SnapshotRepository{Type: "s3"}, cfg)
+		assert.Error(t, err)
+		err = PutSnapshotRepository(repoName, &meta.SnapshotRepository{Type: "fs", Settings: meta.SnapshotRepositorySettings{Location: "../outside"}}, cfg)
+		assert.Error(t, err)
+		err = PutSnapshotRepository(repoName, &meta.SnapshotRepository{Type: "fs", Settings: meta.SnapshotRepositorySettings{Location: "repo"}}, cfg)
+		assert.NoError(t, err)
+
+		repo, err := GetSnapshotRepository(repoName)
+		assert.NoError(t, err)
+		assert.Equal(t, "repo", repo.Settings.Location)
+		_, err = GetSnapshotRepository("snapshot_repo_missing")
+		assert.True(t, errors.As(err, new(*errors.Error)))
+	})
+
+	var first, second *meta.Snapshot
+	t.Run("create snapshot", func(t *testing.T) {
+		var err error
+		first, err = CreateSnapshot(repoName, "snap_1", &meta.SnapshotRequest{Indices: indexName}, true, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, meta.SnapshotStateSuccess, first.State)
+		assert.Equal(t, []string{indexName}, first.Indices)
+		assert.Equal(t, 2, first.Shards.Successful)
+		assert.Greater(t, first.Stats.Total.FileCount, 0)
+		assert.Equal(t, first.Stats.Total, first.Stats.Incremental)
+
+		// nothing changed, all the segments are in the repository already and aren't written again
+		blobs := func() map[string]time.Time {
+			files := make(map[string]time.Time)
+			_ = filepath.Walk(filepath.Join(cfg.SnapshotPathRepo, "repo", "indices"), func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					files[path] = info.ModTime()
+				}
+				return nil
+			})
+			return files
+		}
+		firstBlobs := blobs()
+		assert.Len(t, firstBlobs, first.Stats.Total.FileCount)
+		// the blobs are named by the size and the checksum of the segment files
+		for path := range firstBlobs {
+			assert.Regexp(t, `^[0-9a-f]{12}-[0-9a-f]+-[0-9a-f]{8}\.seg$`, filepath.Base(path))
+		}
+		second, err = CreateSnapshot(repoName, "snap_2", &meta.SnapshotRequest{Indices: []interface{}{"snapshot.*"}}, true, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, first.Stats.Total, second.Stats.Total)
+		assert.Equal(t, 0, second.Stats.Incremental.FileCount)
+		assert.Equal(t, firstBlobs, blobs())
+
+		createDocuments(t, 10, 15)
+		third, err := CreateSnapshot(repoName, "snap_3", &meta.SnapshotRequest{Indices: indexName}, true, cfg)
+		assert.NoError(t, err)
+		assert.Greater(t, third.Stats.Incremental.FileCount, 0)
+
+		_, err = CreateSnapshot(repoName, "snap_1", &meta.SnapshotRequest{}, true, cfg)
+		assert.Error(t, err)
+		_, err = CreateSnapshot(repoName, "snap_4", &meta.SnapshotRequest{Indices: "snapshot.index_missing"}, true, cfg)
+		assert.Error(t, err)
+
+		snaps, err := ListSnapshots(repoName, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, snaps, 3)
+		assert.Equal(t, "snap_1", snaps[0].Snapshot)
+	})
+
+	t.Run("restore renamed", func(t *testing.T) {
+		info, err := RestoreSnapshot(repoName, "snap_1", &meta.RestoreRequest{
+			RenamePattern:     "snapshot.index_(.+)",
+			RenameReplacement: "snapshot.restored_$1",
+		}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"snapshot.restored_1"}, info.Indices)
+		assert.Equal(t, 10, count(t, "snapshot.restored_1"))
+		assert.Contains(t, ZINC_INDEX_ALIAS_LIST.GetAliasesForIndex("snapshot.restored_1"), "snapshot.alias")
+
+		// the index exists
+		_, err = RestoreSnapshot(repoName, "snap_1", &meta.RestoreRequest{}, cfg)
+		assert.Error(t, err)
+		assert.NoError(t, DeleteIndex("snapshot.restored_1", cfg.DataPath))
+	})
+
+	t.Run("restore global state", func(t *testing.T) {
+		tplName := "snapshot.template"
+		assert.NoError(t, NewTemplate(tplName, &meta.IndexTemplate{IndexPatterns: []string{"snapshot.tpl_*"}}))
+		_, err := CreateSnapshot(repoName, "snap_tpl", &meta.SnapshotRequest{Indices: indexName}, true, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, DeleteTemplate(tplName))
+		defer func() {
+			_ = DeleteTemplate(tplName)
+			assert.NoError(t, DeleteSnapshot(repoName, "snap_tpl", cfg))
+		}()
+		restore := func(t *testing.T, includeGlobalState *bool) bool {
+			_, err := RestoreSnapshot(repoName, "snap_tpl", &meta.RestoreRequest{
+				IncludeGlobalState: includeGlobalState,
+				RenamePattern:      "index",
+				RenameReplacement:  "restored",
+			}, cfg)
+			assert.NoError(t, err)
+			assert.NoError(t, DeleteIndex("snapshot.restored_1", cfg.DataPath))
+			_, exists, err := LoadTemplate(tplName)
+			assert.NoError(t, err)
+			return exists
+		}
+
+		excluded := false
+		assert.False(t, restore(t, &excluded))
+		// the templates are restored by default like they are snapshotted by default
+		assert.True(t, restore(t, nil))
+	})
+
+	t.Run("delete snapshot", func(t *testing.T) {
+		assert.NoError(t, DeleteSnapshot(repoName, "snap_1", cfg))
+		_, err := GetSnapshot(repoName, "snap_1", cfg)
+		e := new(errors.Error)
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, errors.ErrorTypeSnapshotMissingException, e.Type)
+
+		// the segments are still used by snap_2
+		snap, err := GetSnapshot(repoName, "snap_2", cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, second.UUID, snap.UUID)
+		_, err = RestoreSnapshot(repoName, "snap_2", &meta.RestoreRequest{RenamePattern: "index", RenameReplacement: "restored"}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, count(t, "snapshot.restored_1"))
+		assert.NoError(t, DeleteIndex("snapshot.restored_1", cfg.DataPath))
+	})
+
+	t.Run("restore deleted index", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+		_, err := RestoreSnapshot(repoName, "snap_3", &meta.RestoreRequest{Indices: indexName}, cfg)
+		assert.NoError(t, err)
+		assert.Equal(t, 15, count(t, indexName))
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex(indexName, cfg.DataPath))
+		assert.NoError(t, DeleteSnapshotRepository(repoName))
+	})
+}
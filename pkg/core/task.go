@@ -0,0 +1,367 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+)
+
+const (
+	DeleteByQueryAction = "indices:data/write/delete/byquery"
+	DeleteIndexAction   = "indices:admin/delete"
+)
+
+var ZINC_TASK_LIST = &TaskList{node: newSearchContextID(), tasks: make(map[string]*Task)}
+
+// NewTaskCleaner starts deleting the task results older than ttl every hour, or every ttl if shorter,
+// the results are kept forever if ttl is 0
+func NewTaskCleaner(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	interval := time.Hour
+	if ttl < interval {
+		interval = ttl
+	}
+	go func() {
+		tick := time.NewTicker(interval)
+		for range tick.C {
+			ZINC_TASK_LIST.DeleteExpired(time.Now().Add(-ttl))
+		}
+	}()
+}
+
+// TaskList keeps the running tasks, the tasks are stored in the metadata, so the result of a task
+// can be got after it is completed, even after a restart, until it expires.
+type TaskList struct {
+	node  string // the tasks are identified by node:sequence like Elasticsearch
+	seq   int64
+	tasks map[string]*Task
+	lock  sync.RWMutex
+}
+
+// TaskFunc runs the task, it should stop when the context of the task is done
+type TaskFunc func(task *Task) (interface{}, error)
+
+// Task is a long-running operation executed in the background
+type Task struct {
+	id          string
+	node        string
+	action      string
+	description string
+	start       time.Time
+	end         time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+	done        chan struct{}
+	status      *meta.TaskStatus
+	response    interface{}
+	err         error
+	cancelled   bool
+	lock        sync.RWMutex
+	storeLock   sync.Mutex // orders the stores, a late store can't overwrite a newer state
+}
+
+// Start runs fn in the background, the progress and the result of the task are stored in the metadata
+// whether the caller waits for it or not.
+func (t *TaskList) Start(action, description string, fn TaskFunc) *Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	task := &Task{
+		id:          fmt.Sprintf("%s:%d", t.node, atomic.AddInt64(&t.seq, 1)),
+		node:        t.node,
+		action:      action,
+		description: description,
+		start:       time.Now(),
+		ctx:         ctx,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+	t.lock.Lock()
+	t.tasks[task.id] = task
+	t.lock.Unlock()
+	task.store()
+
+	go func() {
+		response, err := fn(task)
+		task.lock.Lock()
+		task.response, task.err = response, err
+		task.end = time.Now()
+		task.lock.Unlock()
+		cancel()
+		close(task.done)
+		task.store()
+		t.lock.Lock()
+		delete(t.tasks, task.id)
+		t.lock.Unlock()
+	}()
+	return task
+}
+
+// Get returns the running task
+func (t *TaskList) Get(id string) (*Task, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	task, ok := t.tasks[id]
+	return task, ok
+}
+
+// GetResult returns the state of a running task or the stored result of a completed task,
+// a task stored as running was interrupted by a restart, it is reported as failed.
+func (t *TaskList) GetResult(id string) (*meta.TaskResult, error) {
+	if task, ok := t.Get(id); ok {
+		return task.TaskResult(), nil
+	}
+	result, err := metadata.Task.Get(id)
+	if err != nil {
+		if err == errors.ErrKeyNotFound {
+			return nil, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("task [%s] isn't running and its result isn't found or expired", id))
+		}
+		return nil, err
+	}
+	if !result.Completed {
+		// check again, it may complete between the two lookups
+		if task, ok := t.Get(id); ok {
+			return task.TaskResult(), nil
+		}
+		if result.Task.Node != t.node {
+			result.Completed = true
+			result.Error = &meta.TaskError{Type: errors.ErrorTypeRuntimeException, Reason: "the task was interrupted by a restart before it completed"}
+			if err := metadata.Task.Set(id, *result); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// List returns the running tasks matching the comma separated actions, wildcards are supported, all if empty
+func (t *TaskList) List(actions string) []*meta.TaskInfo {
+	t.lock.RLock()
+	tasks := make([]*Task, 0, len(t.tasks))
+	for _, task := range t.tasks {
+		if matchTaskAction(task.action, actions) {
+			tasks = append(tasks, task)
+		}
+	}
+	t.lock.RUnlock()
+
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].start.Before(tasks[j].start)
+	})
+	infos := make([]*meta.TaskInfo, 0, len(tasks))
+	for _, task := range tasks {
+		infos = append(infos, task.Info())
+	}
+	return infos
+}
+
+// Cancel asks the running task to stop, the task stops at its next check of the context.
+// Cancelling a completed task does nothing.
+func (t *TaskList) Cancel(id string) (*meta.TaskInfo, error) {
+	if task, ok := t.Get(id); ok {
+		task.Cancel()
+		return task.Info(), nil
+	}
+	result, err := t.GetResult(id)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Task, nil
+}
+
+// CancelAll cancels the running tasks matching the comma separated actions, all if empty
+func (t *TaskList) CancelAll(actions string) []*meta.TaskInfo {
+	infos := make([]*meta.TaskInfo, 0)
+	for _, info := range t.List(actions) {
+		if task, ok := t.Get(info.ID); ok {
+			task.Cancel()
+			infos = append(infos, task.Info())
+		}
+	}
+	return infos
+}
+
+// Delete forgets the tasks and their stored results, running tasks are not stopped
+func (t *TaskList) Delete(ids ...string) {
+	t.lock.Lock()
+	for _, id := range ids {
+		delete(t.tasks, id)
+	}
+	t.lock.Unlock()
+	for _, id := range ids {
+		if err := metadata.Task.Delete(id); err != nil && err != errors.ErrKeyNotFound {
+			log.Error().Err(err).Str("task", id).Msg("failed to delete task result")
+		}
+	}
+}
+
+// DeleteExpired deletes the stored results of the tasks completed before cutoff and returns their number,
+// a task interrupted by a restart expires by its start time.
+func (t *TaskList) DeleteExpired(cutoff time.Time) int {
+	results, err := metadata.Task.List(0, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list task results")
+		return 0
+	}
+	ids := make([]string, 0)
+	for _, result := range results {
+		if !result.Completed && result.Task.Node == t.node {
+			continue // still running
+		}
+		end := time.UnixMilli(result.Task.StartTimeInMillis).Add(time.Duration(result.Task.RunningTimeInNanos))
+		if end.Before(cutoff) {
+			ids = append(ids, result.Task.ID)
+		}
+	}
+	t.Delete(ids...)
+	return len(ids)
+}
+
+func matchTaskAction(action, actions string) bool {
+	if actions == "" {
+		return true
+	}
+	for _, pattern := range strings.Split(actions, ",") {
+		if isMatchIndex(action, strings.TrimSpace(pattern)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (task *Task) ID() string {
+	return task.id
+}
+
+// Context is done when the task is cancelled
+func (task *Task) Context() context.Context {
+	return task.ctx
+}
+
+// Cancel asks the task to stop
+func (task *Task) Cancel() {
+	if task.Completed() {
+		return
+	}
+	task.lock.Lock()
+	task.cancelled = true
+	task.lock.Unlock()
+	task.cancel()
+	task.store()
+}
+
+// SetStatus reports the progress of the task
+func (task *Task) SetStatus(status meta.TaskStatus) {
+	task.lock.Lock()
+	task.status = &status
+	task.lock.Unlock()
+	task.store()
+}
+
+func (task *Task) Completed() bool {
+	select {
+	case <-task.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait waits for the completion of the task, it returns false if the timeout is reached first
+func (task *Task) Wait(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-task.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Result returns the response and the error of the completed task
+func (task *Task) Result() (interface{}, error) {
+	<-task.done
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+	return task.response, task.err
+}
+
+func (task *Task) Info() *meta.TaskInfo {
+	task.lock.RLock()
+	defer task.lock.RUnlock()
+	running := time.Since(task.start)
+	if !task.end.IsZero() {
+		running = task.end.Sub(task.start)
+	}
+	info := &meta.TaskInfo{
+		Node:               task.node,
+		ID:                 task.id,
+		Type:               "transport",
+		Action:             task.action,
+		Description:        task.description,
+		StartTimeInMillis:  task.start.UnixMilli(),
+		RunningTimeInNanos: running.Nanoseconds(),
+		Cancellable:        true,
+		Cancelled:          task.cancelled,
+	}
+	if task.status != nil {
+		status := *task.status
+		info.Status = &status
+	}
+	return info
+}
+
+// TaskResult returns the state of the task with the response or the error if it is completed
+func (task *Task) TaskResult() *meta.TaskResult {
+	result := &meta.TaskResult{Task: *task.Info()}
+	if !task.Completed() {
+		return result
+	}
+	result.Completed = true
+	response, err := task.Result()
+	if err != nil {
+		result.Error = &meta.TaskError{Type: errors.ErrorTypeRuntimeException, Reason: err.Error()}
+		var e *errors.Error
+		if errors.As(err, &e) {
+			result.Error = &meta.TaskError{Type: e.Type, Reason: e.Reason}
+		}
+		return result
+	}
+	result.Response = response
+	return result
+}
+
+// store saves the state of the task to the metadata
+func (task *Task) store() {
+	task.storeLock.Lock()
+	defer task.storeLock.Unlock()
+	if err := metadata.Task.Set(task.id, *task.TaskResult()); err != nil {
+		log.Error().Err(err).Str("task", task.id).Msg("failed to store task")
+	}
+}
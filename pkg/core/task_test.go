@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+)
+
+func TestTaskList(t *testing.T) {
+	block := func(task *Task) (interface{}, error) {
+		task.SetStatus(meta.TaskStatus{Total: 2, Deleted: 1})
+		<-task.Context().Done()
+		return nil, errors.New(errors.ErrorTypeRuntimeException, "stopped")
+	}
+	stored := ZINC_TASK_LIST.Start("indices:data/write/test", "stored task", block)
+	other := ZINC_TASK_LIST.Start("indices:admin/test", "other task", block)
+	// wait for the tasks to report their status
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("running", func(t *testing.T) {
+		infos := ZINC_TASK_LIST.List("indices:data/*")
+		assert.Len(t, infos, 1)
+		assert.Equal(t, stored.ID(), infos[0].ID)
+		assert.Len(t, ZINC_TASK_LIST.List("*test"), 2)
+
+		result, err := ZINC_TASK_LIST.GetResult(stored.ID())
+		assert.NoError(t, err)
+		assert.False(t, result.Completed)
+		assert.Equal(t, "stored task", result.Task.Description)
+
+		// the progress is stored while the task is running
+		saved, err := metadata.Task.Get(stored.ID())
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), saved.Task.Status.Deleted)
+		_, err = metadata.Task.Get(other.ID())
+		assert.NoError(t, err)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		infos := ZINC_TASK_LIST.CancelAll("indices:admin/*")
+		assert.Len(t, infos, 1)
+		assert.True(t, infos[0].Cancelled)
+		assert.True(t, other.Wait(time.Second))
+
+		info, err := ZINC_TASK_LIST.Cancel(stored.ID())
+		assert.NoError(t, err)
+		assert.True(t, info.Cancelled)
+		assert.True(t, stored.Wait(time.Second))
+		// wait for the task to be removed from the running tasks
+		time.Sleep(10 * time.Millisecond)
+		assert.Empty(t, ZINC_TASK_LIST.List("*test"))
+	})
+
+	t.Run("stored result", func(t *testing.T) {
+		result, err := ZINC_TASK_LIST.GetResult(stored.ID())
+		assert.NoError(t, err)
+		assert.True(t, result.Completed)
+		assert.True(t, result.Task.Cancelled)
+		assert.Equal(t, "stopped", result.Error.Reason)
+		saved, err := metadata.Task.Get(stored.ID())
+		assert.NoError(t, err)
+		assert.True(t, saved.Completed)
+
+		// every task keeps its result, the caller may wait for it
+		result, err = ZINC_TASK_LIST.GetResult(other.ID())
+		assert.NoError(t, err)
+		assert.True(t, result.Completed)
+		info, err := ZINC_TASK_LIST.Cancel(other.ID())
+		assert.NoError(t, err)
+		assert.True(t, info.Cancelled)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		ZINC_TASK_LIST.DeleteExpired(time.Now().Add(-time.Hour))
+		_, err := ZINC_TASK_LIST.GetResult(other.ID())
+		assert.NoError(t, err)
+
+		assert.GreaterOrEqual(t, ZINC_TASK_LIST.DeleteExpired(time.Now().Add(time.Second)), 2)
+		_, err = ZINC_TASK_LIST.GetResult(other.ID())
+		assert.Error(t, err)
+		_, err = ZINC_TASK_LIST.GetResult(stored.ID())
+		assert.Error(t, err)
+	})
+
+	t.Run("interrupted by restart", func(t *testing.T) {
+		id := "restarted:1"
+		err := metadata.Task.Set(id, meta.TaskResult{Task: meta.TaskInfo{Node: "restarted", ID: id, Action: "indices:data/write/test"}})
+		assert.NoError(t, err)
+		result, err := ZINC_TASK_LIST.GetResult(id)
+		assert.NoError(t, err)
+		assert.True(t, result.Completed)
+		assert.NotNil(t, result.Error)
+		ZINC_TASK_LIST.Delete(id)
+	})
+
+	ZINC_TASK_LIST.Delete(stored.ID())
+	_, err := ZINC_TASK_LIST.GetResult(stored.ID())
+	assert.Error(t, err)
+}
@@ -115,6 +115,19 @@ func DeleteTemplate(name string) error {
 
 // UseTemplate use a specific template for new index
 func UseTemplate(indexName string) (*meta.IndexTemplate, error) {
+	tpl, err := useTemplate(indexName)
+	if err != nil || tpl == nil {
+		return nil, err
+	}
+	return tpl.IndexTemplate, nil
+}
+
+// useTemplate returns the template with the highest priority matching the index name,
+// the backing indexes of a data stream are matched by the name of the data stream.
+func useTemplate(indexName string) (*meta.Template, error) {
+	if m := dataStreamIndexRe.FindStringSubmatch(indexName); m != nil {
+		indexName = m[1]
+	}
 	templates, err := ListTemplates("")
 	if err != nil {
 		return nil, err
@@ -144,7 +157,7 @@ func UseTemplate(indexName string) (*meta.IndexTemplate, error) {
 			pattern := strings.TrimRight(strings.ReplaceAll(pattern, "*", ".*"), "$") + "$"
 			re := regexp.MustCompile(pattern)
 			if re.MatchString(indexName) {
-				return tpl.IndexTemplate, nil
+				return tpl, nil
 			}
 		}
 	}
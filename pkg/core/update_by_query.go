@@ -0,0 +1,214 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/script"
+)
+
+const UpdateByQueryAction = "indices:data/write/update/byquery"
+
+// UpdateByQuery updates the documents matched by the query in place, with a partial document or a script
+type UpdateByQuery struct {
+	req               *meta.UpdateByQueryRequest
+	indexes           []string
+	script            *script.Script
+	params            map[string]interface{}
+	requestsPerSecond float64
+}
+
+// NewUpdateByQuery checks the request, target is an index, alias or wildcard pattern,
+// requestsPerSecond throttles the updates, zero or negative means unlimited
+func NewUpdateByQuery(target string, req *meta.UpdateByQueryRequest, requestsPerSecond float64) (*UpdateByQuery, error) {
+	indexes, err := resolveIndexes(target, meta.IndicesOptions{})
+	if err != nil {
+		return nil, err
+	}
+	u := &UpdateByQuery{req: req, requestsPerSecond: requestsPerSecond}
+	for _, index := range indexes {
+		if name := index.GetDataStream(); name != "" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("[update_by_query] data stream [%s] is append-only, documents can't be updated", name))
+		}
+		u.indexes = append(u.indexes, index.GetName())
+	}
+
+	if req.Doc != nil && req.Script != nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[update_by_query] can't provide both doc and script")
+	}
+	if req.Script != nil {
+		if u.script, u.params, err = script.FromRequest(req.Script); err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, "[update_by_query] "+err.Error())
+		}
+	}
+	switch req.Conflicts {
+	case "":
+		req.Conflicts = meta.ReindexConflictsAbort
+	case meta.ReindexConflictsAbort, meta.ReindexConflictsProceed:
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[update_by_query] conflicts [%s] is invalid, it should be abort or proceed", req.Conflicts))
+	}
+	if req.ScrollSize < 0 || req.MaxDocs < 0 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[update_by_query] scroll_size and max_docs should be positive")
+	}
+	if req.ScrollSize == 0 {
+		req.ScrollSize = ReindexDefaultSize
+	}
+	return u, nil
+}
+
+// Description describes the update for the task
+func (u *UpdateByQuery) Description() string {
+	return fmt.Sprintf("update-by-query [%s]", strings.Join(u.indexes, ","))
+}
+
+// Run updates the documents batch by batch, the progress is reported to the task,
+// it stops before the next document when the task is cancelled.
+func (u *UpdateByQuery) Run(task *Task, cfg *config.Config) (*meta.UpdateByQueryResponse, error) {
+	b := &batchTask{
+		indexes: u.indexes,
+		query: &meta.ZincQuery{
+			Query:            u.req.Query,
+			Size:             u.req.ScrollSize,
+			TrackTotalHits:   true,
+			SeqNoPrimaryTerm: true,
+		},
+		maxDocs:           u.req.MaxDocs,
+		conflicts:         u.req.Conflicts,
+		requestsPerSecond: u.requestsPerSecond,
+	}
+	resp, err := b.run(task, cfg, func(hit meta.Hit) (string, string, error) {
+		return u.updateDocument(hit, cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &meta.UpdateByQueryResponse{
+		Took:       resp.Took,
+		TaskStatus: resp.TaskStatus,
+		Failures:   resp.Failures,
+		Canceled:   resp.Canceled,
+	}, nil
+}
+
+// updateDocument applies the update to the hit, it returns the result and the index name. The write fails
+// with a version conflict if the document is changed or deleted by someone else since the query ran.
+func (u *UpdateByQuery) updateDocument(hit meta.Hit, cfg *config.Config) (string, string, error) {
+	index, ok := GetIndex(hit.Index)
+	if !ok {
+		return "", hit.Index, errors.New(errors.ErrorTypeResourceNotFoundException, fmt.Sprintf("no such index [%s]", hit.Index))
+	}
+	cond := hitVersionCondition(hit)
+
+	source, _ := hit.Source.(map[string]interface{})
+	if source == nil {
+		source = make(map[string]interface{})
+	}
+	op, source, err := u.apply(index.GetName(), hit.ID, source)
+	if err != nil {
+		return "", index.GetName(), err
+	}
+
+	switch op {
+	case meta.UpdateByQueryOpNoop:
+		return batchResultNoop, index.GetName(), nil
+	case meta.UpdateByQueryOpDelete:
+		if _, err := index.DeleteDocumentVersioned(hit.ID, cond, cfg.Shard.GoroutineNum); err != nil {
+			return "", index.GetName(), err
+		}
+		return batchResultDeleted, index.GetName(), nil
+	default:
+		source[meta.TimeFieldName] = hit.Timestamp.Format(time.RFC3339Nano)
+		if _, err := index.UpdateDocumentVersioned(hit.ID, source, false, cond, cfg.Shard.GoroutineNum, cfg.EnableTextKeywordMapping); err != nil {
+			return "", index.GetName(), err
+		}
+		return batchResultUpdated, index.GetName(), nil
+	}
+}
+
+// apply changes the source with the partial document or the script, it returns the operation
+// for the document: index, noop or delete, and the new source. A partial document which changes nothing is a noop.
+func (u *UpdateByQuery) apply(indexName, id string, source map[string]interface{}) (string, map[string]interface{}, error) {
+	if u.req.Doc != nil {
+		if !mergeDocument(source, u.req.Doc) {
+			return meta.UpdateByQueryOpNoop, source, nil
+		}
+		return meta.UpdateByQueryOpIndex, source, nil
+	}
+	if u.script == nil {
+		return meta.UpdateByQueryOpIndex, source, nil
+	}
+
+	ctx := map[string]interface{}{
+		"_index":  indexName,
+		"_id":     id,
+		"_source": source,
+		"op":      meta.UpdateByQueryOpIndex,
+	}
+	vars := map[string]interface{}{"ctx": ctx}
+	if u.params != nil {
+		vars["params"] = u.params
+	}
+	if _, err := u.script.Run(vars); err != nil {
+		return "", nil, errors.New(errors.ErrorTypeIllegalArgumentException, err.Error())
+	}
+	source, ok := ctx["_source"].(map[string]interface{})
+	if !ok {
+		return "", nil, errors.New(errors.ErrorTypeIllegalArgumentException, "ctx._source should be an object")
+	}
+	switch op := ctx["op"]; op {
+	case meta.UpdateByQueryOpIndex, meta.UpdateByQueryOpNoop, meta.UpdateByQueryOpDelete:
+		return op.(string), source, nil
+	default:
+		return "", nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("ctx.op [%v] is invalid, it should be index, noop or delete", op))
+	}
+}
+
+// hitVersionCondition returns the condition writing the hit only if the document is not changed since the query ran
+func hitVersionCondition(hit meta.Hit) *meta.VersionCondition {
+	seqNo, primaryTerm := hit.SeqNo, hit.PrimaryTerm
+	return &meta.VersionCondition{IfSeqNo: &seqNo, IfPrimaryTerm: &primaryTerm}
+}
+
+// mergeDocument merges the partial document into the source, the objects are merged recursively,
+// it returns false if the source is not changed.
+func mergeDocument(source, doc map[string]interface{}) bool {
+	changed := false
+	for k, v := range doc {
+		if sub, ok := v.(map[string]interface{}); ok {
+			if old, ok := source[k].(map[string]interface{}); ok {
+				if mergeDocument(old, sub) {
+					changed = true
+				}
+				continue
+			}
+		}
+		if old, ok := source[k]; ok && reflect.DeepEqual(old, v) {
+			continue
+		}
+		source[k] = v
+		changed = true
+	}
+	return changed
+}
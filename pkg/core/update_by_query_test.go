@@ -0,0 +1,176 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package core
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+func TestUpdateByQuery(t *testing.T) {
+	cfg := config.NewGlobalConfig()
+
+	run := func(t *testing.T, target string, req *meta.UpdateByQueryRequest) *meta.UpdateByQueryResponse {
+		u, err := NewUpdateByQuery(target, req, -1)
+		assert.NoError(t, err)
+		task := ZINC_TASK_LIST.Start(UpdateByQueryAction, u.Description(), func(task *Task) (interface{}, error) {
+			return u.Run(task, cfg)
+		})
+		resp, err := task.Result()
+		assert.NoError(t, err)
+		// wait for WAL write to index
+		waitForRefresh(t, ZINC_INDEX_LIST.List()...)
+		return resp.(*meta.UpdateByQueryResponse)
+	}
+	get := func(t *testing.T, id string) map[string]interface{} {
+		index, ok := GetIndex("update_by_query.index")
+		assert.True(t, ok)
+		hit, err := index.GetDocument(id, cfg.Shard.GoroutineNum)
+		if err != nil {
+			return nil
+		}
+		return hit.Source.(map[string]interface{})
+	}
+	lt := func(n int) map[string]interface{} {
+		return map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"lt": n}}}
+	}
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := NewIndex("update_by_query.index", "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, StoreIndex(index))
+		for i := 0; i < 5; i++ {
+			doc := map[string]interface{}{
+				"num":    float64(i + 1),
+				"status": "open",
+				"meta":   map[string]interface{}{"owner": "zinc", "level": float64(1)},
+			}
+			assert.NoError(t, index.CreateDocument(strconv.Itoa(i), doc, false, cfg.EnableTextKeywordMapping))
+		}
+		waitForRefresh(t, index)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		invalid := map[string]*meta.UpdateByQueryRequest{
+			"update_by_query.missing": {},
+			"update_by_query.index":   {Doc: map[string]interface{}{"a": 1}, Script: "ctx._source.a = 1"},
+			"update_by_query.inde*":   {Script: "ctx._source.a = "},
+			"update_by_query.i*":      {Conflicts: "ignore"},
+			"update_by_query.index,*": {MaxDocs: -1},
+		}
+		for target, req := range invalid {
+			_, err := NewUpdateByQuery(target, req, -1)
+			assert.Error(t, err, target)
+		}
+	})
+
+	t.Run("partial document", func(t *testing.T) {
+		req := &meta.UpdateByQueryRequest{
+			Query:      lt(4),
+			Doc:        map[string]interface{}{"status": "closed", "meta": map[string]interface{}{"level": float64(2)}},
+			ScrollSize: 2,
+		}
+		resp := run(t, "update_by_query.index", req)
+		assert.Equal(t, int64(3), resp.Total)
+		assert.Equal(t, int64(3), resp.Updated)
+		assert.Equal(t, int64(2), resp.Batches)
+		assert.Empty(t, resp.Failures)
+		doc := get(t, "1")
+		assert.Equal(t, "closed", doc["status"])
+		assert.Equal(t, map[string]interface{}{"owner": "zinc", "level": float64(2)}, doc["meta"])
+		assert.Equal(t, "open", get(t, "4")["status"])
+
+		// nothing changes the second time
+		resp = run(t, "update_by_query.index", req)
+		assert.Equal(t, int64(0), resp.Updated)
+		assert.Equal(t, int64(3), resp.Noops)
+	})
+
+	t.Run("script", func(t *testing.T) {
+		resp := run(t, "update_by_query.*", &meta.UpdateByQueryRequest{
+			Query: lt(3),
+			Script: map[string]interface{}{
+				"source": "ctx._source.num += params.step; ctx._source.status = 'hot'",
+				"params": map[string]interface{}{"step": 10},
+			},
+		})
+		assert.Equal(t, int64(2), resp.Updated)
+		assert.Equal(t, float64(11), get(t, "0")["num"])
+		assert.Equal(t, float64(12), get(t, "1")["num"])
+		assert.Equal(t, "hot", get(t, "1")["status"])
+	})
+
+	t.Run("script operations", func(t *testing.T) {
+		resp := run(t, "update_by_query.index", &meta.UpdateByQueryRequest{
+			Script: "if (ctx._source.num == 3) { ctx.op = 'delete' } else if (ctx._source.num == 4) { ctx._source.count = 1 } else { ctx.op = 'noop' }",
+		})
+		assert.Equal(t, int64(5), resp.Total)
+		assert.Equal(t, int64(1), resp.Deleted)
+		assert.Equal(t, int64(1), resp.Updated)
+		assert.Equal(t, int64(3), resp.Noops)
+		assert.Nil(t, get(t, "2"))
+		assert.Equal(t, float64(1), get(t, "3")["count"])
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		resp := run(t, "update_by_query.index", &meta.UpdateByQueryRequest{
+			Script:  "ctx.op = 'upsert'",
+			MaxDocs: 2,
+		})
+		assert.Equal(t, int64(2), resp.Total)
+		assert.Equal(t, int64(2), resp.Failed)
+		assert.Len(t, resp.Failures, 2)
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		// the update is throttled to a document per second, the last match is rewritten with the same content meanwhile
+		u, err := NewUpdateByQuery("update_by_query.index", &meta.UpdateByQueryRequest{
+			Query:      map[string]interface{}{"range": map[string]interface{}{"num": map[string]interface{}{"gte": 11}}},
+			Doc:        map[string]interface{}{"status": "cold"},
+			Conflicts:  meta.ReindexConflictsProceed,
+			ScrollSize: 1,
+		}, 1)
+		assert.NoError(t, err)
+		task := ZINC_TASK_LIST.Start(UpdateByQueryAction, u.Description(), func(task *Task) (interface{}, error) {
+			return u.Run(task, cfg)
+		})
+		index, _ := GetIndex("update_by_query.index")
+		hit, err := index.GetDocument("1", cfg.Shard.GoroutineNum)
+		assert.NoError(t, err)
+		source := hit.Source.(map[string]interface{})
+		source[meta.TimeFieldName] = hit.Timestamp.Format(time.RFC3339Nano)
+		assert.NoError(t, index.UpdateDocument("1", source, false, cfg.Shard.GoroutineNum, cfg.EnableTextKeywordMapping))
+
+		resp, err := task.Result()
+		assert.NoError(t, err)
+		result := resp.(*meta.UpdateByQueryResponse)
+		assert.Equal(t, int64(1), result.Updated)
+		assert.Equal(t, int64(1), result.VersionConflicts)
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, "cold", get(t, "0")["status"])
+		assert.Equal(t, "hot", get(t, "1")["status"])
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		assert.NoError(t, DeleteIndex("update_by_query.index", cfg.DataPath))
+	})
+}
@@ -18,7 +18,8 @@ package errors
 import (
 	"errors"
 	"fmt"
-	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
 )
 
 const (
@@ -28,6 +29,15 @@ const (
 	ErrorTypeRuntimeException         = "runtime_exception"
 	ErrorTypeNotImplemented           = "not_implemented"
 	ErrorTypeInvalidArgument          = "invalid_argument"
+
+	ErrorTypeSearchContextMissingException  = "search_context_missing_exception"
+	ErrorTypeRepositoryMissingException     = "repository_missing_exception"
+	ErrorTypeSnapshotMissingException       = "snapshot_missing_exception"
+	ErrorTypeResourceNotFoundException      = "resource_not_found_exception"
+	ErrorTypeVersionConflictEngineException = "version_conflict_engine_exception"
+	ErrorTypeDocumentMissingException       = "document_missing_exception"
+	ErrorTypeIndexNotFoundException         = "index_not_found_exception"
+	ErrorTypeInvalidIndexNameException      = "invalid_index_name_exception"
 )
 
 var (
@@ -58,12 +68,15 @@ func (e *Error) Cause(err error) *Error {
 }
 
 func (e *Error) MarshalJSON() ([]byte, error) {
-	reason := strings.ReplaceAll(e.Reason, "\"", "\\\"")
+	v := struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+		Cause  string `json:"cause,omitempty"`
+	}{Type: e.Type, Reason: e.Reason}
 	if e.CausedBy != nil {
-		cause := strings.ReplaceAll(e.CausedBy.Error(), "\"", "\\\"")
-		return []byte(fmt.Sprintf(`{"type":"%s","reason":"%s","cause":"%s"}`, e.Type, reason, cause)), nil
+		v.Cause = e.CausedBy.Error()
 	}
-	return []byte(fmt.Sprintf(`{"type":"%s","reason":"%s"}`, e.Type, reason)), nil
+	return json.Marshal(v)
 }
 
 func (e *Error) Error() string {
@@ -62,3 +62,29 @@ func TestError_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestError_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{
+			name: "error",
+			err:  New(ErrorTypeRuntimeException, `error "message"`),
+			want: `{"type":"runtime_exception","reason":"error \"message\""}`,
+		},
+		{
+			name: "error caused by",
+			err:  New(ErrorTypeParsingException, "error message").Cause(errors.New("line 1:\n\t{\\")),
+			want: `{"type":"parsing_exception","reason":"error message","cause":"line 1:\n\t{\\"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.err.MarshalJSON()
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
@@ -25,6 +25,15 @@ func HandleError(c *gin.Context, err error) {
 	if err != nil {
 		switch v := err.(type) {
 		case *Error:
+			switch v.Type {
+			case ErrorTypeSearchContextMissingException, ErrorTypeRepositoryMissingException, ErrorTypeSnapshotMissingException,
+				ErrorTypeResourceNotFoundException, ErrorTypeDocumentMissingException, ErrorTypeIndexNotFoundException:
+				c.JSON(http.StatusNotFound, gin.H{"error": v})
+				return
+			case ErrorTypeVersionConflictEngineException:
+				c.JSON(http.StatusConflict, gin.H{"error": v})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": v})
 		default:
 			c.JSON(http.StatusBadRequest, gin.H{"error": v.Error()})
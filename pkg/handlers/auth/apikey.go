@@ -0,0 +1,150 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/auth"
+	"github.com/zinclabs/zincsearch/pkg/ider"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id CreateAPIKey
+// @Summary Create api key
+// @security BasicAuth
+// @Tags    APIKey
+// @Accept  json
+// @Produce json
+// @Param   key body CreateAPIKeyRequest true "API key data"
+// @Success 200 {object} CreateAPIKeyResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 403 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/apikey [post]
+func CreateAPIKey(c *gin.Context) {
+	var req CreateAPIKeyRequest
+	if err := zutils.GinBindJSON(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	userID := c.GetString(auth.ContextKeyUserID)
+	userRole := c.GetString(auth.ContextKeyRole)
+	if req.Role == "" {
+		req.Role = userRole
+	}
+	// only admin can create keys for other roles, or anyone could escalate the privileges
+	if req.Role != userRole && userRole != "admin" {
+		c.JSON(http.StatusForbidden, meta.HTTPResponseError{Error: "api key can only be created for your own role"})
+		return
+	}
+
+	var expiration time.Duration
+	if req.Expiration != "" {
+		var err error
+		if expiration, err = zutils.ParseDuration(req.Expiration); err != nil || expiration <= 0 {
+			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "api key expiration [" + req.Expiration + "] is invalid"})
+			return
+		}
+	}
+
+	key, secret, err := auth.CreateAPIKey(req.Name, req.Role, userID, expiration, ider.GetNode(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, CreateAPIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Role:      key.Role,
+		APIKey:    secret,
+		Encoded:   auth.EncodeAPIKey(key.ID, secret),
+		ExpiresAt: key.ExpiresAt,
+	})
+}
+
+// @Id ListAPIKeys
+// @Summary List api keys, only admin can list the keys of the other users
+// @security BasicAuth
+// @Tags    APIKey
+// @Produce json
+// @Success 200 {object} []meta.APIKey
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/apikey [get]
+func ListAPIKey(c *gin.Context) {
+	keys, err := auth.GetAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	userID := c.GetString(auth.ContextKeyUserID)
+	isAdmin := c.GetString(auth.ContextKeyRole) == "admin"
+	owned := make([]*meta.APIKey, 0, len(keys))
+	for _, k := range keys {
+		if !isAdmin && k.CreatedBy != userID {
+			continue
+		}
+		// remove hash from response
+		k.Hash = ""
+		owned = append(owned, k)
+	}
+	c.JSON(http.StatusOK, owned)
+}
+
+// @Id DeleteAPIKey
+// @Summary Revoke api key, only admin can revoke the keys of the other users
+// @security BasicAuth
+// @Tags    APIKey
+// @Produce json
+// @Param   id  path  string  true  "API key id"
+// @Success 200 {object} meta.HTTPResponseID
+// @Failure 403 {object} meta.HTTPResponseError
+// @Failure 500 {object} meta.HTTPResponseError
+// @Router /api/apikey/{id} [delete]
+func DeleteAPIKey(c *gin.Context) {
+	id := c.Param("id")
+	if key, ok := auth.GetAPIKey(id); ok && c.GetString(auth.ContextKeyRole) != "admin" {
+		if key.CreatedBy != c.GetString(auth.ContextKeyUserID) {
+			c.JSON(http.StatusForbidden, meta.HTTPResponseError{Error: "api key can only be revoked by its creator"})
+			return
+		}
+	}
+	if err := auth.DeleteAPIKey(id); err != nil {
+		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, meta.HTTPResponseID{Message: "deleted", ID: id})
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	Expiration string `json:"expiration"` // eg.: 12h, 30d, empty means never expires
+}
+
+type CreateAPIKeyResponse struct {
+	ID        string     `json:"_id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	APIKey    string     `json:"api_key"`
+	Encoded   string     `json:"encoded"` // use it as: Authorization: ApiKey <encoded>
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/zinclabs/zincsearch/pkg/auth"
+	"github.com/zinclabs/zincsearch/pkg/config"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
@@ -44,16 +45,25 @@ func Login(c *gin.Context) {
 
 	loggedInUser, validationResult := auth.VerifyCredentials(loginInput.ID, loginInput.Password)
 	var resUser LoginUser
+	var token string
 	if validationResult {
 		resUser = LoginUser{
 			ID:   loggedInUser.ID,
 			Name: loggedInUser.Name,
 			Role: loggedInUser.Role,
 		}
+		cfg := config.GetConfig(c)
+		var err error
+		token, err = auth.CreateSessionToken(loggedInUser.ID, cfg.SessionSecret, cfg.SessionTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
 	}
 	c.JSON(http.StatusOK, LoginResponse{
 		Validated: validationResult,
 		User:      resUser,
+		Token:     token,
 	})
 }
 
@@ -71,4 +81,5 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Validated bool      `json:"validated"`
 	User      LoginUser `json:"user"`
+	Token     string    `json:"token,omitempty"` // session token, use it as: Authorization: Bearer <token>
 }
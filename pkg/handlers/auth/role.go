@@ -47,7 +47,7 @@ func CreateUpdateRole(c *gin.Context) {
 		return
 	}
 
-	newRole, err := auth.CreateRole(role.ID, role.Name, role.Permission)
+	newRole, err := auth.CreateRole(role.ID, role.Name, role.Permission, role.Indices)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
@@ -0,0 +1,108 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package datastream
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id PutDataStream
+// @Summary Create data stream, the name has to match an index template with data_stream
+// @security BasicAuth
+// @Tags    DataStream
+// @Produce json
+// @Param   target  path  string  true  "Data stream"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_data_stream/{target} [put]
+func PutDataStream(c *gin.Context) {
+	if _, err := core.CreateDataStream(c.Param("target"), time.Now()); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id GetDataStream
+// @Summary Get data streams
+// @security BasicAuth
+// @Tags    DataStream
+// @Produce json
+// @Param   target  path  string  false  "Data streams or wildcard patterns, comma separated, default all"
+// @Success 200 {object} GetResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_data_stream/{target} [get]
+func GetDataStream(c *gin.Context) {
+	streams, err := core.ListDataStreams(c.Param("target"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, GetResponse{DataStreams: streams})
+}
+
+// @Id DeleteDataStream
+// @Summary Delete data stream with all its backing indexes
+// @security BasicAuth
+// @Tags    DataStream
+// @Produce json
+// @Param   target  path  string  true  "Data stream"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_data_stream/{target} [delete]
+func DeleteDataStream(c *gin.Context) {
+	cfg := config.GetConfig(c)
+	if err := core.DeleteDataStream(c.Param("target"), cfg.DataPath); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id DataStreamStats
+// @Summary Get storage stats of data streams
+// @security BasicAuth
+// @Tags    DataStream
+// @Produce json
+// @Param   target  path  string  false  "Data streams or wildcard patterns, comma separated, default all"
+// @Success 200 {object} meta.DataStreamStatsResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_data_stream/{target}/_stats [get]
+func DataStreamStats(c *gin.Context) {
+	resp, err := core.DataStreamStats(c.Param("target"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+type AcknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+type GetResponse struct {
+	DataStreams []*meta.DataStream `json:"data_streams"`
+}
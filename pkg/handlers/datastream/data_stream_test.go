@@ -0,0 +1,117 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package datastream
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/test/utils"
+)
+
+func TestDataStream(t *testing.T) {
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+	metadata.NewStorager(cfg)
+	core.NewIndexList(cfg)
+
+	err := core.NewTemplate("TestDataStream.template", &meta.IndexTemplate{
+		IndexPatterns: []string{"testdatastream.*"},
+		Priority:      514,
+		DataStream:    &meta.DataStreamTemplate{},
+	})
+	assert.NoError(t, err)
+
+	t.Run("put", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			target string
+			code   int
+			result string
+		}{
+			{
+				name:   "normal",
+				target: "testdatastream.logs",
+				code:   http.StatusOK,
+				result: `"acknowledged":true`,
+			},
+			{
+				name:   "exists",
+				target: "testdatastream.logs",
+				code:   http.StatusBadRequest,
+				result: `already exists`,
+			},
+			{
+				name:   "no template",
+				target: "TestDataStream.logs",
+				code:   http.StatusBadRequest,
+				result: `no matching index template`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestParams(c, map[string]string{"target": tt.target})
+				PutDataStream(c)
+				assert.Equal(t, tt.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.result)
+			})
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "testdatastream.*"})
+		GetDataStream(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"testdatastream.logs"`)
+		assert.Contains(t, w.Body.String(), `"timestamp_field":{"name":"@timestamp"}`)
+		assert.Contains(t, w.Body.String(), `"index_name":".ds-testdatastream.logs-`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "testdatastream.missing"})
+		GetDataStream(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "testdatastream.logs"})
+		DataStreamStats(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"data_stream_count":1`)
+		assert.Contains(t, w.Body.String(), `"backing_indices":1`)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "testdatastream.logs"})
+		DeleteDataStream(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "testdatastream.logs"})
+		DeleteDataStream(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	assert.NoError(t, core.DeleteTemplate("TestDataStream.template"))
+}
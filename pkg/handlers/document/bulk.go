@@ -17,17 +17,17 @@ package document
 
 import (
 	"bufio"
-	"errors"
+	"bytes"
+	"fmt"
 	"github.com/zinclabs/zincsearch/pkg/config"
 	"io"
 	"net/http"
-	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/rs/zerolog/log"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/ider"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
@@ -42,7 +42,9 @@ import (
 // @Tags    Document
 // @Accept  plain
 // @Produce json
-// @Param   query  body  string  true  "Query"
+// @Param   pipeline  query  string  false  "Ingest pipeline"
+// @Param   refresh   query  string  false  "Wait for the documents to be visible: true, false or wait_for"
+// @Param   query     body   string  true   "Query"
 // @Success 200 {object} meta.HTTPResponseRecordCount
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /api/_bulk [post]
@@ -51,13 +53,25 @@ func Bulk(c *gin.Context) {
 
 	defer c.Request.Body.Close()
 
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
 	cfg := config.GetConfig(c)
 	node := ider.GetNode(c)
-	ret, err := BulkWorker(target, c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
+	ret, err := BulkWorker(target, c.Query("pipeline"), c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
 	if err != nil {
 		zutils.GinRenderJSON(c, http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	if refresh {
+		if err = waitForRefresh(ret.Indexes()...); err != nil {
+			zutils.GinRenderJSON(c, http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
 
 	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseRecordCount{Message: "bulk data inserted", RecordCount: ret.Count})
 }
@@ -70,7 +84,9 @@ func Bulk(c *gin.Context) {
 // @Tags    Document
 // @Accept  plain
 // @Produce json
-// @Param   query  body  string  true  "Query"
+// @Param   pipeline  query  string  false  "Ingest pipeline"
+// @Param   refresh   query  string  false  "Wait for the documents to be visible: true, false or wait_for"
+// @Param   query     body   string  true   "Query"
 // @Success 200 {object} map[string]interface{}
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /es/_bulk [post]
@@ -79,25 +95,31 @@ func ESBulk(c *gin.Context) {
 
 	defer c.Request.Body.Close()
 
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	startTime := time.Now()
 	cfg := config.GetConfig(c)
 	node := ider.GetNode(c)
-	ret, err := BulkWorker(target, c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
+	ret, err := BulkWorker(target, c.Query("pipeline"), c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
 	if err != nil {
 		ret.Error = err.Error()
+	} else if refresh {
+		if err = waitForRefresh(ret.Indexes()...); err != nil {
+			ret.Error = err.Error()
+		}
 	}
-
-	startTime := time.Now()
 	ret.Took = int(time.Since(startTime) / time.Millisecond)
-	// update seqNo
-	atomic.AddInt64(&globalSeqNo, int64(ret.Count))
-
 	zutils.GinRenderJSON(c, http.StatusOK, ret)
 }
 
 func BulkWorker(
-	target string, body io.Reader, maxDocumentSize int, enableTextKeywordMapping bool, goroutineNum int, node *ider.Node,
+	target, pipeline string, body io.Reader, maxDocumentSize int, enableTextKeywordMapping bool, goroutineNum int, node *ider.Node,
 ) (*BulkResponse, error) {
-	bulkRes := &BulkResponse{Items: []map[string]BulkResponseItem{}}
+	bulkRes := &BulkResponse{Items: []map[string]BulkResponseItem{}, indexes: make(map[string]*core.Index)}
 
 	// Prepare to read the entire raw text of the body
 	scanner := bufio.NewScanner(body)
@@ -108,130 +130,305 @@ func BulkWorker(
 	buf := make([]byte, maxCapacityPerLine)
 	scanner.Buffer(buf, maxCapacityPerLine)
 
-	nextLineIsData := false
-	lastLineMetaData := make(map[string]interface{})
+	w := &bulkWorker{
+		res:                      bulkRes,
+		target:                   target,
+		pipelines:                core.NewIngestPipelines(pipeline),
+		enableTextKeywordMapping: enableTextKeywordMapping,
+		goroutineNum:             goroutineNum,
+		node:                     node,
+	}
 
-	var doc map[string]interface{}
-	var err error
-	for scanner.Scan() { // Read each line
-		for k := range doc {
-			delete(doc, k)
+	action, err := readBulk(scanner, target, func(action *bulkAction, source []byte, err error) error {
+		switch {
+		case err != nil:
+			bulkRes.Count++
+			bulkRes.addError("index", "", "", err)
+			return nil
+		case action.name == "delete":
+			return w.delete(action)
+		default:
+			return w.write(action, source)
 		}
-		if err = json.Unmarshal(scanner.Bytes(), &doc); err != nil {
-			log.Error().Msgf("bulk.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
-			continue
+	})
+	if err != nil {
+		return bulkRes, err
+	}
+	if action != nil {
+		bulkRes.Count++
+		bulkRes.addError(action.name, action.index, action.id, errors.New(errors.ErrorTypeIllegalArgumentException,
+			"the bulk request must be terminated by a newline"))
+	}
+
+	return bulkRes, nil
+}
+
+// BulkIndexNames returns the indexes the actions of a bulk body write to,
+// the lines are paired like BulkWorker does so the authorized actions are the executed ones.
+func BulkIndexNames(target string, body []byte) []string {
+	names := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, len(body)+1)
+	_, _ = readBulk(scanner, target, func(action *bulkAction, _ []byte, err error) error {
+		if err == nil {
+			names = append(names, action.index)
 		}
+		return nil
+	})
+	if len(names) == 0 {
+		names = append(names, target)
+	}
+	return names
+}
 
-		// This will process the data line in the request. Each data line is preceded by a metadata line.
-		// Docs at https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
-		if nextLineIsData {
-			bulkRes.Count++
-			nextLineIsData = false
-			update := false
+// readBulk calls fn for every action with its source line, the source is nil for a delete.
+// Each action takes a metadata line, the actions except delete are followed by a source line.
+// A bad metadata line calls fn with the error, then if the next line is not an action either,
+// it's taken as the source of the bad one and skipped. An action missing its source is returned.
+// Docs at https://www.elastic.co/guide/en/elasticsearch/reference/current/docs-bulk.html
+func readBulk(scanner *bufio.Scanner, target string, fn func(action *bulkAction, source []byte, err error) error) (*bulkAction, error) {
+	var action *bulkAction
+	afterBadAction := false
+	for scanner.Scan() { // Read each line
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
 
-			var docID = ""
-			if val, ok := lastLineMetaData["_id"]; ok && val != nil {
-				docID = val.(string)
-			}
-			if docID == "" {
-				docID = node.Generate()
-			} else {
-				update = true
-			}
-			suppliedIndexName := lastLineMetaData["_index"]
-			if suppliedIndexName == nil && len(target) > 0 {
-				suppliedIndexName = target
+		// This will process the source line of the action
+		if action != nil {
+			if err := fn(action, line, nil); err != nil {
+				return nil, err
 			}
-			suppliedOperation := lastLineMetaData["operation"]
-			if suppliedOperation == nil && len(target) > 0 {
-				suppliedOperation = "create"
+			action = nil
+			continue
+		}
+
+		// This will process the metadata line
+		var err error
+		if action, err = parseBulkAction(line, target); err != nil {
+			if !afterBadAction {
+				if err := fn(nil, nil, err); err != nil {
+					return nil, err
+				}
 			}
-			indexName := suppliedIndexName.(string)
-			operation := suppliedOperation.(string)
-			switch operation {
-			case "index":
-				bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
-					"index": NewBulkResponseItem(bulkRes.Count, indexName, docID, "created", nil),
-				})
-			case "create":
-				bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
-					"index": NewBulkResponseItem(bulkRes.Count, indexName, docID, "created", nil),
-				})
-			case "update":
-				bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
-					"index": NewBulkResponseItem(bulkRes.Count, indexName, docID, "updated", nil),
-				})
-			default:
+			afterBadAction = !afterBadAction
+			continue
+		}
+		afterBadAction = false
+		if action.name == "delete" {
+			if err := fn(action, nil, nil); err != nil {
+				return nil, err
 			}
+			action = nil
+		}
+	}
+	return action, scanner.Err()
+}
 
-			newIndex, _, err := core.GetOrCreateIndex(indexName, "", 0)
-			if err != nil {
-				return bulkRes, err
-			}
+// bulkAction is the metadata line of a bulk action
+type bulkAction struct {
+	name     string // index, create, update or delete
+	index    string
+	id       string
+	pipeline string
+	metadata map[string]interface{}
+}
 
-			err = newIndex.CreateDocument(docID, doc, update, enableTextKeywordMapping)
-			if err != nil {
-				return bulkRes, err
-			}
+// parseBulkAction reads the metadata line, the index in the metadata overtakes the target in the path
+func parseBulkAction(line []byte, target string) (*bulkAction, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(line, &doc); err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "malformed action/metadata line").Cause(err)
+	}
+	if len(doc) != 1 {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "malformed action/metadata line, expected a single action")
+	}
+	action := new(bulkAction)
+	for k, v := range doc {
+		switch k {
+		case "index", "create", "update", "delete":
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("malformed action/metadata line, unknown action [%s]", k))
+		}
+		vm, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("malformed action/metadata line, [%s] should be an object", k))
+		}
+		action.name, action.metadata = k, vm
+	}
 
-		} else { // This branch will process the metadata line in the request. Each metadata line is preceded by a data line.
+	var ok bool
+	if action.index, ok = action.metadata["_index"].(string); !ok || action.index == "" {
+		action.index = target
+	}
+	if v, exists := action.metadata["_id"]; exists && v != nil {
+		if action.id, ok = v.(string); !ok {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[_id] should be a string")
+		}
+	}
+	action.pipeline, _ = action.metadata["pipeline"].(string)
+	if action.index == "" {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] index is missing", action.name))
+	}
+	if action.id == "" && (action.name == "update" || action.name == "delete") {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] id is missing", action.name))
+	}
+	return action, nil
+}
 
-			for k, v := range doc {
-				vm, ok := v.(map[string]interface{})
-				if !ok {
-					return nil, errors.New("bulk index data format error")
-				}
-				for k := range lastLineMetaData {
-					delete(lastLineMetaData, k)
-				}
-				if k == "index" || k == "create" || k == "update" {
-					nextLineIsData = true
-					lastLineMetaData["operation"] = k
-
-					if vm["_index"] != "" { // if index is specified in metadata then it overtakes the index in the query path
-						lastLineMetaData["_index"] = vm["_index"]
-					} else {
-						lastLineMetaData["_index"] = target
-					}
-					if lastLineMetaData["_index"] == "" {
-						return nil, errors.New("bulk index data format error")
-					}
-					lastLineMetaData["_id"] = vm["_id"]
-				} else if k == "delete" {
-					nextLineIsData = false
-					docID := vm["_id"].(string)
-					indexName := target
-					if vm["_index"] != "" { // if index is specified in metadata then it overtakes the index in the query path
-						indexName = vm["_index"].(string)
-					}
-					if indexName == "" {
-						return nil, errors.New("bulk index data format error")
-					}
-
-					newIndex, _, err := core.GetOrCreateIndex(indexName, "", 0)
-					if err != nil {
-						return bulkRes, err
-					}
-
-					// delete
-					err = newIndex.DeleteDocument(docID, goroutineNum)
-					bulkRes.Count++
-					bulkRes.Items = append(bulkRes.Items, map[string]BulkResponseItem{
-						"delete": NewBulkResponseItem(bulkRes.Count, indexName, docID, "deleted", err),
-					})
-				} else {
-					lastLineMetaData["_index"] = target
-					lastLineMetaData["operation"] = "index"
-				}
-			}
+// bulkWorker writes the actions of a bulk request, the errors of the documents are reported per item,
+// only the errors of the indexes abort the request.
+type bulkWorker struct {
+	res                      *BulkResponse
+	target                   string
+	pipelines                *core.IngestPipelines
+	enableTextKeywordMapping bool
+	goroutineNum             int
+	node                     *ider.Node
+}
+
+// getIndex returns the index of the action, it's created if not exists. The error only fails the action.
+func (w *bulkWorker) getIndex(action *bulkAction) (*core.Index, error) {
+	index, _, err := core.GetOrCreateIndex(action.index, "", 0)
+	if err != nil {
+		if core.CheckIndexName(action.index) != nil {
+			return nil, errors.New(errors.ErrorTypeInvalidIndexNameException, err.Error())
 		}
+		return nil, err
 	}
+	w.res.indexes[index.GetName()] = index
+	return index, nil
+}
 
-	if err := scanner.Err(); err != nil {
-		return bulkRes, err
+// write runs an index, create or update action with its source line
+func (w *bulkWorker) write(action *bulkAction, line []byte) error {
+	w.res.Count++
+	var doc map[string]interface{}
+	if err := json.Unmarshal(line, &doc); err != nil {
+		w.res.addError(action.name, action.index, action.id, errors.New(errors.ErrorTypeParsingException, "failed to parse the source").Cause(err))
+		return nil
+	}
+	cond, err := versionCondition(action.metadata)
+	if err != nil {
+		w.res.addError(action.name, action.index, action.id, err)
+		return nil
+	}
+	index, err := w.getIndex(action)
+	if err != nil {
+		w.res.addError(action.name, action.index, action.id, err)
+		return nil
 	}
 
-	return bulkRes, nil
+	if action.name == "update" {
+		version, err := w.update(index, action.id, doc, cond)
+		if err != nil {
+			w.res.addError(action.name, index.GetName(), action.id, err)
+			return nil
+		}
+		w.res.addItem(action.name, NewBulkResponseItem(index.GetName(), action.id, version, nil))
+		return nil
+	}
+
+	docID, update := action.id, true
+	if docID == "" {
+		docID, update = w.node.Generate(), false
+	}
+	index, ingested, err := w.pipelines.Process(index, action.pipeline, docID, doc)
+	if err != nil {
+		w.res.addError(action.name, action.index, docID, err)
+		return nil
+	}
+	if ingested == nil {
+		item := NewBulkResponseItem(index.GetName(), docID, nil, nil)
+		item.Result = "noop"
+		w.res.addItem(action.name, item)
+		return nil
+	}
+	if ingested.ID != docID {
+		docID, update = ingested.ID, true
+	}
+	w.res.indexes[index.GetName()] = index
+	if action.name == "create" {
+		if cond == nil {
+			cond = new(meta.VersionCondition)
+		}
+		cond.Create = true
+	}
+
+	version, err := index.CreateDocumentVersioned(docID, ingested.Source, update, cond, w.enableTextKeywordMapping)
+	if err != nil {
+		w.res.addError(action.name, index.GetName(), docID, err)
+		return nil
+	}
+	w.res.addItem(action.name, NewBulkResponseItem(index.GetName(), docID, version, nil))
+	return nil
+}
+
+// update merges the partial document of the update action like ES, the pipelines don't run for updates
+func (w *bulkWorker) update(
+	index *core.Index, docID string, body map[string]interface{}, cond *meta.VersionCondition,
+) (*meta.DocumentVersion, error) {
+	if _, ok := body["script"]; ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[script] is not supported by the bulk update, use [doc] instead")
+	}
+	doc, err := bulkUpdateObject(body, "doc")
+	if err != nil {
+		return nil, err
+	}
+	upsert, err := bulkUpdateObject(body, "upsert")
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[doc] is missing")
+	}
+	if docAsUpsert, _ := body["doc_as_upsert"].(bool); docAsUpsert {
+		upsert = doc
+	}
+	return index.MergeDocument(docID, doc, upsert, cond, w.goroutineNum, w.enableTextKeywordMapping)
+}
+
+// bulkUpdateObject returns the object field of the update action, nil if it's not set
+func bulkUpdateObject(body map[string]interface{}, field string) (map[string]interface{}, error) {
+	v, ok := body[field]
+	if !ok || v == nil {
+		return nil, nil
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] should be an object", field))
+	}
+	return obj, nil
+}
+
+// delete runs a delete action, deleting a missing document isn't an error
+func (w *bulkWorker) delete(action *bulkAction) error {
+	w.res.Count++
+	cond, err := versionCondition(action.metadata)
+	if err != nil {
+		w.res.addError(action.name, action.index, action.id, err)
+		return nil
+	}
+	index, err := w.getIndex(action)
+	if err != nil {
+		w.res.addError(action.name, action.index, action.id, err)
+		return nil
+	}
+
+	version, err := index.DeleteDocumentVersioned(action.id, cond, w.goroutineNum)
+	if err == errors.ErrorIDNotFound {
+		item := NewBulkResponseItem(index.GetName(), action.id, nil, nil)
+		item.Status, item.Result = http.StatusNotFound, "not_found"
+		w.res.addItem(action.name, item)
+		return nil
+	}
+	if err != nil {
+		w.res.addError(action.name, index.GetName(), action.id, err)
+		return nil
+	}
+	w.res.addItem(action.name, NewBulkResponseItem(index.GetName(), action.id, version, nil))
+	return nil
 }
 
 // DoesExistInThisRequest takes a slice and looks for an element in it. If found it will
@@ -245,33 +442,72 @@ func DoesExistInThisRequest(slice []string, val string) int {
 	return -1
 }
 
-func NewBulkResponseItem(seqNo int64, index, id, result string, err error) BulkResponseItem {
-	return BulkResponseItem{
-		Index:   index,
-		Type:    "_doc",
-		ID:      id,
-		Version: 1,
-		Result:  result,
+// NewBulkResponseItem returns the item of a document written with the version, or the item of a failed document
+func NewBulkResponseItem(index, id string, version *meta.DocumentVersion, err error) BulkResponseItem {
+	item := BulkResponseItem{
+		Index: index,
+		Type:  "_doc",
+		ID:    id,
 		Shards: BulkResponseItemShard{
 			Total:      1,
 			Successful: 1,
 			Failed:     0,
 		},
-		Status:      200,
-		SeqNo:       globalSeqNo + seqNo,
-		PrimaryTerm: 1,
-		Error:       err,
+		Status: http.StatusOK,
 	}
+	if version != nil {
+		item.Version = version.Version
+		item.Result = version.Result
+		item.SeqNo = version.SeqNo
+		item.PrimaryTerm = version.PrimaryTerm
+		if version.Result == "created" {
+			item.Status = http.StatusCreated
+		}
+	}
+	if err != nil {
+		status, ok := writeErrorStatus(err)
+		if !ok {
+			status = http.StatusInternalServerError
+		}
+		item.Status = status
+		item.Shards.Successful, item.Shards.Failed = 0, 1
+		if item.Error, ok = err.(*errors.Error); !ok {
+			item.Error = errors.New(errors.ErrorTypeRuntimeException, err.Error())
+		}
+	}
+	return item
 }
 
-var globalSeqNo int64
-
 type BulkResponse struct {
 	Took   int                           `json:"took"`
 	Errors bool                          `json:"errors"`
 	Error  string                        `json:"error,omitempty"`
 	Items  []map[string]BulkResponseItem `json:"items"`
 	Count  int64                         `json:"-"`
+
+	indexes map[string]*core.Index // the indexes written
+}
+
+// addItem adds the item of an action, the response has errors if the item has
+func (r *BulkResponse) addItem(action string, item BulkResponseItem) {
+	if item.Error != nil {
+		r.Errors = true
+	}
+	r.Items = append(r.Items, map[string]BulkResponseItem{action: item})
+}
+
+// addError adds the item of a failed action
+func (r *BulkResponse) addError(action, index, id string, err error) {
+	r.addItem(action, NewBulkResponseItem(index, id, nil, err))
+}
+
+// Indexes returns the indexes written by the bulk request
+func (r *BulkResponse) Indexes() []*core.Index {
+	indexes := make([]*core.Index, 0, len(r.indexes))
+	for _, index := range r.indexes {
+		indexes = append(indexes, index)
+	}
+	return indexes
 }
 
 type BulkResponseItem struct {
@@ -283,8 +519,8 @@ type BulkResponseItem struct {
 	Status      int                   `json:"status"`
 	Shards      BulkResponseItemShard `json:"_shards"`
 	SeqNo       int64                 `json:"_seq_no"`
-	PrimaryTerm int                   `json:"_primary_term"`
-	Error       error                 `json:"error,omitempty"`
+	PrimaryTerm int64                 `json:"_primary_term"`
+	Error       *errors.Error         `json:"error,omitempty"`
 }
 
 type BulkResponseItemShard struct {
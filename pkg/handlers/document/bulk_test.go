@@ -20,10 +20,12 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/pkg/metadata"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
 	"github.com/zinclabs/zincsearch/test/utils"
 )
 
@@ -119,6 +121,30 @@ func TestESBulk(t *testing.T) {
 				result: "",
 			},
 		},
+		{
+			name: "version conflict",
+			args: args{
+				code: http.StatusOK,
+				data: `{ "index" : { "_index" : "document.esbulk", "_id": "occ" } }
+				{"Athlete": "HAJOS, Alfred"}
+				{ "index" : { "_index" : "document.esbulk", "_id": "occ", "if_seq_no": 100, "if_primary_term": 1 } }
+				{"Athlete": "HERSCHMANN, Otto"}`,
+				params: map[string]string{"target": "document.esbulk"},
+				result: `"status":409`,
+			},
+		},
+		{
+			name: "external version after conflict",
+			args: args{
+				code: http.StatusOK,
+				data: `{ "index" : { "_index" : "document.esbulk", "_id": "occ", "if_seq_no": 100, "if_primary_term": 1 } }
+				{"Athlete": "HERSCHMANN, Otto"}
+				{ "index" : { "_index" : "document.esbulk", "_id": "ext", "version": 5, "version_type": "external_gte" } }
+				{"Athlete": "HAJOS, Alfred"}`,
+				params: map[string]string{"target": "document.esbulk"},
+				result: `"_id":"ext","_version":5`,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -131,3 +157,134 @@ func TestESBulk(t *testing.T) {
 		})
 	}
 }
+
+func TestESBulkItems(t *testing.T) {
+	type item struct {
+		action string
+		status int
+		result string
+	}
+	indexName := "document.esbulk_items"
+	tests := []struct {
+		name       string
+		data       []string
+		items      []item
+		wantErrors bool
+	}{
+		{
+			name: "create and index",
+			data: []string{
+				`{"create": {"_id": "1"}}`, `{"a": 1}`,
+				`{"index": {"_id": "1"}}`, `{"a": 2}`,
+				`{"create": {"_id": "1"}}`, `{"a": 3}`,
+			},
+			items: []item{
+				{"create", http.StatusCreated, "created"},
+				{"index", http.StatusOK, "updated"},
+				{"create", http.StatusConflict, ""},
+			},
+			wantErrors: true,
+		},
+		{
+			name: "update",
+			data: []string{
+				`{"update": {"_id": "1"}}`, `{"doc": {"b": {"c": 1}}}`,
+				`{"update": {"_id": "1"}}`, `{"doc": {"b": {"c": 1}}}`,
+				`{"update": {"_id": "2"}}`, `{"doc": {"b": 1}}`,
+				`{"update": {"_id": "2"}}`, `{"doc": {"b": 1}, "doc_as_upsert": true}`,
+				`{"update": {"_id": "3"}}`, `{"doc": {"b": 1}, "upsert": {"c": 1}}`,
+				`{"update": {"_id": "3"}}`, `{"script": "ctx._source.c++"}`,
+			},
+			items: []item{
+				{"update", http.StatusOK, "updated"},
+				{"update", http.StatusOK, "noop"},
+				{"update", http.StatusNotFound, ""},
+				{"update", http.StatusCreated, "created"},
+				{"update", http.StatusCreated, "created"},
+				{"update", http.StatusBadRequest, ""},
+			},
+			wantErrors: true,
+		},
+		{
+			name: "bad lines",
+			data: []string{
+				`{"index": {"_id": "4"}}`, `{"a": `,
+				`not json`, `{"Year": 1896}`,
+				`{"index": {"_id": "5"}}`, `{"a": 5}`,
+				`{"delete": {"_id": "4"}}`,
+			},
+			items: []item{
+				{"index", http.StatusBadRequest, ""},
+				{"index", http.StatusBadRequest, ""},
+				{"index", http.StatusCreated, "created"},
+				{"delete", http.StatusNotFound, "not_found"},
+			},
+			wantErrors: true,
+		},
+		{
+			name: "invalid index name",
+			data: []string{
+				`{"index": {"_index": "bad index", "_id": "7"}}`, `{"a": 7}`,
+				`{"delete": {"_index": "_bad", "_id": "7"}}`,
+				`{"index": {"_id": "7"}}`, `{"a": 7}`,
+			},
+			items: []item{
+				{"index", http.StatusBadRequest, ""},
+				{"delete", http.StatusBadRequest, ""},
+				{"index", http.StatusCreated, "created"},
+			},
+			wantErrors: true,
+		},
+		{
+			name: "no errors",
+			data: []string{
+				`{"index": {"_id": "6"}}`, `{"a": 6}`,
+				`{"delete": {"_id": "6"}}`,
+			},
+			items: []item{
+				{"index", http.StatusCreated, "created"},
+				{"delete", http.StatusOK, "deleted"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, strings.Join(tt.data, "\n")+"\n")
+			utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+			ESBulk(c)
+			assert.Equal(t, http.StatusOK, w.Code)
+
+			resp := new(BulkResponse)
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+			assert.Equal(t, tt.wantErrors, resp.Errors)
+			if !assert.Len(t, resp.Items, len(tt.items)) {
+				return
+			}
+			for i, want := range tt.items {
+				got, ok := resp.Items[i][want.action]
+				if assert.True(t, ok, "item %d should be %s", i, want.action) {
+					assert.Equal(t, want.status, got.Status, "item %d", i)
+					assert.Equal(t, want.result, got.Result, "item %d", i)
+					assert.Equal(t, want.status >= http.StatusBadRequest && want.result == "", got.Error != nil, "item %d", i)
+				}
+			}
+		})
+	}
+
+	t.Run("merged documents", func(t *testing.T) {
+		index, ok := core.GetIndex(indexName)
+		assert.True(t, ok)
+		hit, err := index.GetDocument("1", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"a": float64(2), "b": map[string]interface{}{"c": float64(1)}}, hit.Source)
+		hit, err = index.GetDocument("3", 1)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"c": float64(1)}, hit.Source)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		cfg := config.NewGlobalConfig()
+		assert.NoError(t, core.DeleteIndex(indexName, cfg.DataPath))
+	})
+}
@@ -36,6 +36,8 @@ import (
 // @Tags    Document
 // @Accept  json
 // @Produce json
+// @Param   pipeline  query string  false "Ingest pipeline"
+// @Param   refresh   query string  false "Wait for the documents to be visible: true, false or wait_for"
 // @Param   query  body  meta.JSONIngest  true  "Query"
 // @Success 200 {object} meta.HTTPResponseRecordCount
 // @Failure 400 {object} meta.HTTPResponseError
@@ -55,19 +57,31 @@ func Bulkv2(c *gin.Context) {
 		target = body.Index
 	}
 
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
 	defer c.Request.Body.Close()
 	cfg := config.GetConfig(c)
-	count, err := Bulkv2Worker(target, body, cfg.EnableTextKeywordMapping, ider.GetNode(c))
+	count, err := Bulkv2Worker(target, c.Query("pipeline"), body, cfg.EnableTextKeywordMapping, ider.GetNode(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	if index, ok := core.GetIndex(target); ok && refresh {
+		if err = waitForRefresh(index); err != nil {
+			c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, meta.HTTPResponseRecordCount{Message: "v2 data inserted", RecordCount: count})
 }
 
 // Bulkv2Worker accept JSONIngest json documents. It provides a simpler format to ingest data.
-func Bulkv2Worker(indexName string, body meta.JSONIngest, enableTextKeywordMapping bool, node *ider.Node) (int64, error) {
+func Bulkv2Worker(indexName, pipeline string, body meta.JSONIngest, enableTextKeywordMapping bool, node *ider.Node) (int64, error) {
 	var err error
 	var count int64
 	newIndex, _, err := core.GetOrCreateIndex(indexName, "", 0)
@@ -75,6 +89,7 @@ func Bulkv2Worker(indexName string, body meta.JSONIngest, enableTextKeywordMappi
 		return count, err
 	}
 
+	pipelines := core.NewIngestPipelines(pipeline)
 	for _, doc := range body.Records { // Read each line
 		update := false
 
@@ -88,7 +103,18 @@ func Bulkv2Worker(indexName string, body meta.JSONIngest, enableTextKeywordMappi
 			update = true
 		}
 
-		err = newIndex.CreateDocument(docID, doc, update, enableTextKeywordMapping)
+		index, ingested, err := pipelines.Process(newIndex, "", docID, doc)
+		if err != nil {
+			return count, err
+		}
+		if ingested == nil {
+			continue
+		}
+		if ingested.ID != docID {
+			docID, update = ingested.ID, true
+		}
+
+		err = index.CreateDocument(docID, ingested.Source, update, enableTextKeywordMapping)
 		if err != nil {
 			return count, err
 		}
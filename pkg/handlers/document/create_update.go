@@ -22,6 +22,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/ider"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
@@ -34,6 +35,7 @@ import (
 // @Accept  json
 // @Produce json
 // @Param   index     path  string  true  "Index"
+// @Param   pipeline  query string  false "Ingest pipeline"
 // @Param   document  body  map[string]interface{}  true  "Document"
 // @Success 200 {object} meta.HTTPResponseID
 // @Failure 400 {object} meta.HTTPResponseError
@@ -49,6 +51,16 @@ func CreateUpdate(c *gin.Context) {
 		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	cond, err := queryVersionCondition(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
 
 	update := false
 	// If id field is present then use it, else create a new UUID and use it
@@ -67,21 +79,44 @@ func CreateUpdate(c *gin.Context) {
 		zutils.GinRenderJSON(c, http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	index, ingested, err := core.NewIngestPipelines(c.Query("pipeline")).Process(index, "", docID, doc)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	if ingested == nil {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseESID{Message: "ok", ID: docID, ESID: docID, Index: indexName, Result: "noop"})
+		return
+	}
+	if ingested.ID != docID {
+		docID, update = ingested.ID, true
+	}
+
 	cfg := config.GetConfig(c)
-	err = index.CreateDocument(docID, doc, update, cfg.EnableTextKeywordMapping)
+	version, err := index.CreateDocumentVersioned(docID, ingested.Source, update, cond, cfg.EnableTextKeywordMapping)
 	if err != nil {
+		if _, ok := err.(*errors.Error); ok {
+			errors.HandleError(c, err)
+			return
+		}
 		zutils.GinRenderJSON(c, http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	if refresh {
+		if err = waitForRefresh(index); err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+	}
 	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseESID{
 		Message:     "ok",
 		ID:          docID,
 		ESID:        docID,
-		Index:       indexName,
-		Version:     1,
-		SeqNo:       0,
-		PrimaryTerm: 0,
-		Result:      "created",
+		Index:       index.GetName(),
+		Version:     version.Version,
+		SeqNo:       version.SeqNo,
+		PrimaryTerm: version.PrimaryTerm,
+		Result:      version.Result,
 	})
 }
 
@@ -93,9 +128,16 @@ func CreateUpdate(c *gin.Context) {
 // @Produce json
 // @Param   index     path  string  true  "Index"
 // @Param   id        path  string  true  "ID"
+// @Param   if_seq_no        query int64   false "Only write if the document has this sequence number"
+// @Param   if_primary_term  query int64   false "Only write if the document has this primary term"
+// @Param   version          query int64   false "Explicit version number for external versioning"
+// @Param   version_type     query string  false "Version type: internal, external or external_gte"
+// @Param   refresh          query string  false "Wait for the document to be visible: true, false or wait_for"
+// @Param   pipeline  query string  false "Ingest pipeline"
 // @Param   document  body  map[string]interface{}  true  "Document"
 // @Success 200 {object} meta.HTTPResponseID
 // @Failure 400 {object} meta.HTTPResponseError
+// @Failure 409 {object} meta.HTTPResponseError
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /api/{index}/_doc/{id} [put]
 func CreateWithIDForSDK() {}
@@ -32,6 +32,7 @@ func TestCreateUpdate(t *testing.T) {
 		data    map[string]interface{}
 		rawData string
 		params  map[string]string
+		query   map[string]string
 		result  string
 	}
 	cfg := config.NewGlobalConfig()
@@ -98,6 +99,42 @@ func TestCreateUpdate(t *testing.T) {
 				result: `"id":`,
 			},
 		},
+		{
+			name: "refresh wait_for",
+			args: args{
+				code: http.StatusOK,
+				data: map[string]interface{}{
+					"name": "user",
+					"role": "refresh",
+				},
+				params: map[string]string{
+					"target": "TestDocumentCreateUpdate.index_1",
+					"id":     "2",
+				},
+				query: map[string]string{
+					"refresh": "wait_for",
+				},
+				result: `"result":"created"`,
+			},
+		},
+		{
+			name: "invalid refresh",
+			args: args{
+				code: http.StatusBadRequest,
+				data: map[string]interface{}{
+					"name": "user",
+					"role": "refresh",
+				},
+				params: map[string]string{
+					"target": "TestDocumentCreateUpdate.index_1",
+					"id":     "2",
+				},
+				query: map[string]string{
+					"refresh": "soon",
+				},
+				result: `[refresh] should be true, false or wait_for`,
+			},
+		},
 		{
 			name: "error json",
 			args: args{
@@ -131,6 +168,9 @@ func TestCreateUpdate(t *testing.T) {
 			if tt.args.params != nil {
 				utils.SetGinRequestParams(c, tt.args.params)
 			}
+			if tt.args.query != nil {
+				utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"]+"/_doc/"+tt.args.params["id"], tt.args.query)
+			}
 			CreateUpdate(c)
 			assert.Equal(t, tt.args.code, w.Code)
 			assert.Contains(t, w.Body.String(), tt.args.result)
@@ -22,6 +22,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
@@ -32,8 +33,14 @@ import (
 // @Produce json
 // @Param   index  path  string  true  "Index"
 // @Param   id     path  string  true  "ID"
+// @Param   if_seq_no        query int64   false "Only write if the document has this sequence number"
+// @Param   if_primary_term  query int64   false "Only write if the document has this primary term"
+// @Param   version          query int64   false "Explicit version number for external versioning"
+// @Param   version_type     query string  false "Version type: internal, external or external_gte"
+// @Param   refresh          query string  false "Wait for the document to be visible: true, false or wait_for"
 // @Success 200 {object} meta.HTTPResponseDocument
 // @Failure 400 {object} meta.HTTPResponseError
+// @Failure 409 {object} meta.HTTPResponseError
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /api/{index}/_doc/{id} [delete]
 func Delete(c *gin.Context) {
@@ -49,11 +56,39 @@ func Delete(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "index does not exists"})
 		return
 	}
+	cond, err := queryVersionCondition(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
 	cfg := config.GetConfig(c)
-	err := index.DeleteDocument(docID, cfg.Shard.GoroutineNum)
+	version, err := index.DeleteDocumentVersioned(docID, cond, cfg.Shard.GoroutineNum)
 	if err != nil {
+		if _, ok := err.(*errors.Error); ok {
+			errors.HandleError(c, err)
+			return
+		}
 		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, meta.HTTPResponseDocument{Message: "deleted", Index: indexName, ID: docID})
+	if refresh {
+		if err = waitForRefresh(index); err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, meta.HTTPResponseDocument{
+		Message:     "deleted",
+		Index:       indexName,
+		ID:          docID,
+		Version:     version.Version,
+		SeqNo:       version.SeqNo,
+		PrimaryTerm: version.PrimaryTerm,
+		Result:      version.Result,
+	})
 }
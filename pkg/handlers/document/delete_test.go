@@ -19,18 +19,19 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/config"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestDelete(t *testing.T) {
 	type args struct {
 		code   int
 		params map[string]string
+		query  map[string]string
 		result string
 	}
 	cfg := config.NewGlobalConfig()
@@ -38,6 +39,21 @@ func TestDelete(t *testing.T) {
 		name string
 		args args
 	}{
+		{
+			name: "version conflict",
+			args: args{
+				code: http.StatusConflict,
+				params: map[string]string{
+					"target": "TestDocumentDelete.index_1",
+					"id":     "1",
+				},
+				query: map[string]string{
+					"if_seq_no":       "5",
+					"if_primary_term": "1",
+				},
+				result: `version_conflict_engine_exception`,
+			},
+		},
 		{
 			name: "normal",
 			args: args{
@@ -46,7 +62,7 @@ func TestDelete(t *testing.T) {
 					"target": "TestDocumentDelete.index_1",
 					"id":     "1",
 				},
-				result: `"id":"1"`,
+				result: `"_version":2,"_seq_no":2,"_primary_term":1,"result":"deleted"`,
 			},
 		},
 		{
@@ -103,13 +119,16 @@ func TestDelete(t *testing.T) {
 		assert.Contains(t, w.Body.String(), `"id":"1"`)
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		wait.ForRefresh(t, indexName)
 	})
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c, w := utils.NewGinContext()
 			utils.SetGinRequestParams(c, tt.args.params)
+			if tt.args.query != nil {
+				utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"]+"/_doc/"+tt.args.params["id"], tt.args.query)
+			}
 			Delete(c)
 			assert.Equal(t, tt.args.code, w.Code)
 			assert.Contains(t, w.Body.String(), tt.args.result)
@@ -19,12 +19,12 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/config"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestGet(t *testing.T) {
@@ -49,6 +49,17 @@ func TestGet(t *testing.T) {
 				result: `"_id":"1"`,
 			},
 		},
+		{
+			name: "versions",
+			args: args{
+				code: http.StatusOK,
+				params: map[string]string{
+					"target": "TestDocumentGet.index_1",
+					"id":     "1",
+				},
+				result: `"_version":1,"_seq_no":1,"_primary_term":1`,
+			},
+		},
 		{
 			name: "empty id",
 			args: args{
@@ -103,7 +114,7 @@ func TestGet(t *testing.T) {
 		assert.Contains(t, w.Body.String(), `"id":"1"`)
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		wait.ForRefresh(t, indexName)
 	})
 
 	for _, tt := range tests {
@@ -39,8 +39,10 @@ import (
 // @Tags    Document
 // @Accept  plain
 // @Produce json
-// @Param   index  path  string  true  "Index"
-// @Param   query  body  string  true  "Query"
+// @Param   index     path   string  true   "Index"
+// @Param   pipeline  query  string  false  "Ingest pipeline"
+// @Param   refresh   query  string  false  "Wait for the documents to be visible: true, false or wait_for"
+// @Param   query     body   string  true   "Query"
 // @Success 200 {object} meta.HTTPResponseRecordCount
 // @Failure 400 {object} meta.HTTPResponseError
 // @Failure 500 {object} meta.HTTPResponseError
@@ -52,19 +54,31 @@ func Multi(c *gin.Context) {
 		return
 	}
 
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
 	defer c.Request.Body.Close()
 	cfg := config.GetConfig(c)
-	count, err := MultiWorker(target, c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, ider.GetNode(c))
+	count, err := MultiWorker(target, c.Query("pipeline"), c.Request.Body, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, ider.GetNode(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
+	if index, ok := core.GetIndex(target); ok && refresh {
+		if err = waitForRefresh(index); err != nil {
+			c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, meta.HTTPResponseRecordCount{Message: "multiple data inserted", RecordCount: count})
 }
 
 func MultiWorker(
-	indexName string, body io.Reader, maxDocumentSize int, enableTextKeywordMapping bool, node *ider.Node,
+	indexName, pipeline string, body io.Reader, maxDocumentSize int, enableTextKeywordMapping bool, node *ider.Node,
 ) (int64, error) {
 	// Prepare to read the entire raw text of the body
 	scanner := bufio.NewScanner(body)
@@ -83,6 +97,7 @@ func MultiWorker(
 		return count, err
 	}
 
+	pipelines := core.NewIngestPipelines(pipeline)
 	for scanner.Scan() { // Read each line
 		for k := range doc {
 			delete(doc, k)
@@ -104,7 +119,18 @@ func MultiWorker(
 			update = true
 		}
 
-		err = newIndex.CreateDocument(docID, doc, update, enableTextKeywordMapping)
+		index, ingested, err := pipelines.Process(newIndex, "", docID, doc)
+		if err != nil {
+			return count, err
+		}
+		if ingested == nil {
+			continue
+		}
+		if ingested.ID != docID {
+			docID, update = ingested.ID, true
+		}
+
+		err = index.CreateDocument(docID, ingested.Source, update, enableTextKeywordMapping)
 		if err != nil {
 			return count, err
 		}
@@ -0,0 +1,59 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+)
+
+// refreshTimeout limits how long a write waits for its documents to be visible
+const refreshTimeout = 30 * time.Second
+
+// queryRefresh returns if the write should wait for its documents to be visible to the searches, true and wait_for
+// are the same as the documents are visible once the WAL is consumed
+func queryRefresh(c *gin.Context) (bool, error) {
+	switch v := c.Query("refresh"); v {
+	case "", "false":
+		_, ok := c.GetQuery("refresh")
+		return ok && v == "", nil
+	case "true", "wait_for":
+		return true, nil
+	default:
+		return false, errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("[refresh] should be true, false or wait_for, got [%s]", v))
+	}
+}
+
+// waitForRefresh blocks until the documents written to the indexes are visible to the searches
+func waitForRefresh(indexes ...*core.Index) error {
+	for _, index := range indexes {
+		ok, err := index.WaitForRefresh(refreshTimeout)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New(errors.ErrorTypeRuntimeException,
+				fmt.Sprintf("timed out waiting for the refresh of index [%s]", index.GetName()))
+		}
+	}
+	return nil
+}
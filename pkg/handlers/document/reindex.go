@@ -0,0 +1,75 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id Reindex
+// @Summary Copy documents from the source indexes into the destination index
+// @security BasicAuth
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   wait_for_completion  query  bool                 false  "Wait for the reindex to finish, default true"
+// @Param   requests_per_second  query  number               false  "Documents per second, default unlimited"
+// @Param   data                 body   meta.ReindexRequest  true   "Reindex request"
+// @Success 200 {object} meta.ReindexResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_reindex [post]
+func Reindex(c *gin.Context) {
+	req := new(meta.ReindexRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "true"))
+	requestsPerSecond, err := strconv.ParseFloat(c.DefaultQuery("requests_per_second", "-1"), 64)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "requests_per_second should be a number"})
+		return
+	}
+
+	reindex, err := core.NewReindex(req, requestsPerSecond)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	cfg := config.GetConfig(c)
+	task := core.ZINC_TASK_LIST.Start(core.ReindexAction, reindex.Description(), func(task *core.Task) (interface{}, error) {
+		return reindex.Run(task, cfg)
+	})
+	if !wait {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.TaskStartedResponse{Task: task.ID()})
+		return
+	}
+	resp, err := task.Result()
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
@@ -0,0 +1,94 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
+)
+
+func TestReindex(t *testing.T) {
+	// the storage and the index list are opened by TestBulk
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+
+	index, _, err := core.GetOrCreateIndex("document.reindex_src", "", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"name": "reindex"}, false, cfg.EnableTextKeywordMapping))
+	wait.ForRefresh(t, "document.reindex_src")
+
+	tests := []struct {
+		name   string
+		data   string
+		query  map[string]string
+		code   int
+		result string
+	}{
+		{
+			name:   "normal",
+			data:   `{"source":{"index":"document.reindex_src"},"dest":{"index":"document.reindex_dest"}}`,
+			code:   http.StatusOK,
+			result: `"created":1`,
+		},
+		{
+			name:   "background",
+			data:   `{"source":{"index":"document.reindex_src"},"dest":{"index":"document.reindex_dest"}}`,
+			query:  map[string]string{"wait_for_completion": "false"},
+			code:   http.StatusOK,
+			result: `"task":"`,
+		},
+		{
+			name:   "missing source",
+			data:   `{"source":{"index":"document.reindex_missing"},"dest":{"index":"document.reindex_dest"}}`,
+			code:   http.StatusNotFound,
+			result: `no such index [document.reindex_missing]`,
+		},
+		{
+			name:   "invalid requests_per_second",
+			data:   `{"source":{"index":"document.reindex_src"},"dest":{"index":"document.reindex_dest"}}`,
+			query:  map[string]string{"requests_per_second": "fast"},
+			code:   http.StatusBadRequest,
+			result: `requests_per_second should be a number`,
+		},
+		{
+			name:   "invalid body",
+			data:   `{"source":`,
+			code:   http.StatusBadRequest,
+			result: `error`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.data)
+			utils.SetGinRequestURL(c, "/es/_reindex", tt.query)
+			Reindex(c)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.result)
+		})
+	}
+
+	wait.ForTasks(t)
+	for _, name := range []string{"document.reindex_src", "document.reindex_dest"} {
+		assert.NoError(t, core.DeleteIndex(name, cfg.DataPath))
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
@@ -34,9 +35,15 @@ import (
 // @Produce json
 // @Param   index  path  string  true  "Index"
 // @Param   id     path  string  true  "ID"
+// @Param   if_seq_no        query int64   false "Only write if the document has this sequence number"
+// @Param   if_primary_term  query int64   false "Only write if the document has this primary term"
+// @Param   version          query int64   false "Explicit version number for external versioning"
+// @Param   version_type     query string  false "Version type: internal, external or external_gte"
+// @Param   refresh          query string  false "Wait for the document to be visible: true, false or wait_for"
 // @Param   document  body  map[string]interface{}  true  "Document"
-// @Success 200 {object} meta.HTTPResponseID
+// @Success 200 {object} meta.HTTPResponseESID
 // @Failure 400 {object} meta.HTTPResponseError
+// @Failure 409 {object} meta.HTTPResponseError
 // @Failure 500 {object} meta.HTTPResponseError
 // @Router /api/{index}/_update/{id} [post]
 func Update(c *gin.Context) {
@@ -60,6 +67,16 @@ func Update(c *gin.Context) {
 		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "id is empty"})
 		return
 	}
+	cond, err := queryVersionCondition(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	refresh, err := queryRefresh(c)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
 
 	// If the index does not exist, then create it
 	index, _, err := core.GetOrCreateIndex(indexName, "", 0)
@@ -68,10 +85,29 @@ func Update(c *gin.Context) {
 		return
 	}
 	cfg := config.GetConfig(c)
-	err = index.UpdateDocument(docID, doc, insertBool, cfg.Shard.GoroutineNum, cfg.EnableTextKeywordMapping)
+	version, err := index.UpdateDocumentVersioned(docID, doc, insertBool, cond, cfg.Shard.GoroutineNum, cfg.EnableTextKeywordMapping)
 	if err != nil {
+		if _, ok := err.(*errors.Error); ok {
+			errors.HandleError(c, err)
+			return
+		}
 		zutils.GinRenderJSON(c, http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
-	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseID{Message: "ok", ID: docID})
+	if refresh {
+		if err = waitForRefresh(index); err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseESID{
+		Message:     "ok",
+		ID:          docID,
+		ESID:        docID,
+		Index:       index.GetName(),
+		Version:     version.Version,
+		SeqNo:       version.SeqNo,
+		PrimaryTerm: version.PrimaryTerm,
+		Result:      version.Result,
+	})
 }
@@ -0,0 +1,84 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id UpdateByQuery
+// @Summary Update the documents matched by the query with a partial document or a script
+// @security BasicAuth
+// @Tags    Document
+// @Accept  json
+// @Produce json
+// @Param   target               path   string                     true   "Index, alias or wildcard pattern"
+// @Param   wait_for_completion  query  bool                       false  "Wait for the update to finish, default true"
+// @Param   requests_per_second  query  number                     false  "Documents per second, default unlimited"
+// @Param   scroll_size          query  integer                    false  "Documents per batch, default 1000"
+// @Param   data                 body   meta.UpdateByQueryRequest  true   "Update by query request"
+// @Success 200 {object} meta.UpdateByQueryResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{target}/_update_by_query [post]
+func UpdateByQuery(c *gin.Context) {
+	req := new(meta.UpdateByQueryRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "true"))
+	requestsPerSecond, err := strconv.ParseFloat(c.DefaultQuery("requests_per_second", "-1"), 64)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "requests_per_second should be a number"})
+		return
+	}
+	if req.ScrollSize, err = strconv.Atoi(c.DefaultQuery("scroll_size", "0")); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll_size should be an integer"})
+		return
+	}
+	if v := c.Query("conflicts"); v != "" {
+		req.Conflicts = v
+	}
+
+	update, err := core.NewUpdateByQuery(c.Param("target"), req, requestsPerSecond)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	cfg := config.GetConfig(c)
+	task := core.ZINC_TASK_LIST.Start(core.UpdateByQueryAction, update.Description(), func(task *core.Task) (interface{}, error) {
+		return update.Run(task, cfg)
+	})
+	if !wait {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.TaskStartedResponse{Task: task.ID()})
+		return
+	}
+	resp, err := task.Result()
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
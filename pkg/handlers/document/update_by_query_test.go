@@ -0,0 +1,119 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
+)
+
+func TestUpdateByQuery(t *testing.T) {
+	// the storage and the index list are opened by TestBulk
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+
+	index, _, err := core.GetOrCreateIndex("document.update_by_query", "", 1)
+	assert.NoError(t, err)
+	assert.NoError(t, index.CreateDocument("1", map[string]interface{}{"name": "update", "count": 1}, false, cfg.EnableTextKeywordMapping))
+	wait.ForRefresh(t, "document.update_by_query")
+
+	tests := []struct {
+		name   string
+		target string
+		data   string
+		query  map[string]string
+		code   int
+		result string
+	}{
+		{
+			name:   "partial document",
+			target: "document.update_by_query",
+			data:   `{"query":{"match":{"name":"update"}},"doc":{"status":"closed"}}`,
+			code:   http.StatusOK,
+			result: `"updated":1`,
+		},
+		{
+			name:   "script",
+			target: "document.update_by_*",
+			data:   `{"script":"ctx._source.count += 1"}`,
+			query:  map[string]string{"scroll_size": "10", "conflicts": "proceed"},
+			code:   http.StatusOK,
+			result: `"total":1`,
+		},
+		{
+			name:   "background",
+			target: "document.update_by_query",
+			data:   `{"script":{"source":"ctx._source.count = params.count","params":{"count":5}}}`,
+			query:  map[string]string{"wait_for_completion": "false"},
+			code:   http.StatusOK,
+			result: `"task":"`,
+		},
+		{
+			name:   "missing index",
+			target: "document.update_by_query_missing",
+			data:   `{"doc":{"status":"closed"}}`,
+			code:   http.StatusNotFound,
+			result: `no such index [document.update_by_query_missing]`,
+		},
+		{
+			name:   "invalid script",
+			target: "document.update_by_query",
+			data:   `{"script":"ctx._source.count +="}`,
+			code:   http.StatusBadRequest,
+			result: `parsing_exception`,
+		},
+		{
+			name:   "invalid scroll_size",
+			target: "document.update_by_query",
+			data:   `{}`,
+			query:  map[string]string{"scroll_size": "many"},
+			code:   http.StatusBadRequest,
+			result: `scroll_size should be an integer`,
+		},
+		{
+			name:   "invalid body",
+			target: "document.update_by_query",
+			data:   `{"doc":`,
+			code:   http.StatusBadRequest,
+			result: `error`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.data)
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.target})
+			utils.SetGinRequestURL(c, "/es/"+tt.target+"/_update_by_query", tt.query)
+			UpdateByQuery(c)
+			assert.Equal(t, tt.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.result)
+			wait.ForTasks(t)
+			wait.ForRefresh(t, "document.update_by_query")
+		})
+	}
+
+	hit, err := index.GetDocument("1", cfg.Shard.GoroutineNum)
+	assert.NoError(t, err)
+	assert.Equal(t, "closed", hit.Source.(map[string]interface{})["status"])
+	assert.Equal(t, float64(5), hit.Source.(map[string]interface{})["count"])
+	assert.NoError(t, core.DeleteIndex("document.update_by_query", cfg.DataPath))
+}
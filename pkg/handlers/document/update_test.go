@@ -19,12 +19,12 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/config"
 	"net/http"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestUpdate(t *testing.T) {
@@ -33,6 +33,7 @@ func TestUpdate(t *testing.T) {
 		data    map[string]interface{}
 		rawData string
 		params  map[string]string
+		query   map[string]string
 		result  string
 	}
 	cfg := config.NewGlobalConfig()
@@ -56,7 +57,61 @@ func TestUpdate(t *testing.T) {
 					"target": "TestDocumentUpdate.index_1",
 					"id":     "1",
 				},
-				result: `"id":"1"`,
+				result: `"_version":2`,
+			},
+		},
+		{
+			name: "if_seq_no",
+			args: args{
+				code: http.StatusOK,
+				data: map[string]interface{}{
+					"name": "userUpdate2",
+				},
+				params: map[string]string{
+					"target": "TestDocumentUpdate.index_1",
+					"id":     "1",
+				},
+				query: map[string]string{
+					"if_seq_no":       "2",
+					"if_primary_term": "1",
+				},
+				result: `"_seq_no":3`,
+			},
+		},
+		{
+			name: "version conflict",
+			args: args{
+				code: http.StatusConflict,
+				data: map[string]interface{}{
+					"name": "userUpdate3",
+				},
+				params: map[string]string{
+					"target": "TestDocumentUpdate.index_1",
+					"id":     "1",
+				},
+				query: map[string]string{
+					"if_seq_no":       "2",
+					"if_primary_term": "1",
+				},
+				result: `version_conflict_engine_exception`,
+			},
+		},
+		{
+			name: "invalid if_seq_no",
+			args: args{
+				code: http.StatusBadRequest,
+				data: map[string]interface{}{
+					"name": "userUpdate3",
+				},
+				params: map[string]string{
+					"target": "TestDocumentUpdate.index_1",
+					"id":     "1",
+				},
+				query: map[string]string{
+					"if_seq_no":       "two",
+					"if_primary_term": "1",
+				},
+				result: `[if_seq_no] should be an integer`,
 			},
 		},
 		{
@@ -103,7 +158,7 @@ func TestUpdate(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "")
 
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		wait.ForRefresh(t, "TestDocumentUpdate.index_1")
 	})
 
 	for _, tt := range tests {
@@ -118,6 +173,9 @@ func TestUpdate(t *testing.T) {
 			if tt.args.params != nil {
 				utils.SetGinRequestParams(c, tt.args.params)
 			}
+			if tt.args.query != nil {
+				utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"]+"/_update/"+tt.args.params["id"], tt.args.query)
+			}
 			Update(c)
 			assert.Equal(t, tt.args.code, w.Code)
 			assert.Contains(t, w.Body.String(), tt.args.result)
@@ -0,0 +1,96 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package document
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// versionParams are the parameters of the optimistic concurrency control
+var versionParams = []string{"if_seq_no", "if_primary_term", "version", "version_type"}
+
+// queryVersionCondition reads the version condition from the query string, nil if no condition is given
+func queryVersionCondition(c *gin.Context) (*meta.VersionCondition, error) {
+	values := make(map[string]interface{})
+	for _, k := range versionParams {
+		if v, ok := c.GetQuery(k); ok {
+			values[k] = v
+		}
+	}
+	return versionCondition(values)
+}
+
+// versionCondition reads the version condition from the values, the values are strings from the query string
+// or numbers from the metadata line of a bulk item. It returns nil if no condition is given.
+func versionCondition(values map[string]interface{}) (*meta.VersionCondition, error) {
+	var cond *meta.VersionCondition
+	for _, k := range versionParams {
+		v, ok := values[k]
+		if !ok || v == nil {
+			continue
+		}
+		if cond == nil {
+			cond = new(meta.VersionCondition)
+		}
+		if k == "version_type" {
+			vt, err := zutils.ToString(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[version_type] should be a string")
+			}
+			cond.VersionType = vt
+			continue
+		}
+		f, err := zutils.ToFloat64(v)
+		if err != nil || f != math.Trunc(f) || math.IsInf(f, 0) {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] should be an integer", k))
+		}
+		n := int64(f)
+		switch k {
+		case "if_seq_no":
+			cond.IfSeqNo = &n
+		case "if_primary_term":
+			cond.IfPrimaryTerm = &n
+		case "version":
+			cond.Version = &n
+		}
+	}
+	return cond, nil
+}
+
+// writeErrorStatus returns the status of a failed document write, ok is false if the error isn't caused by the document
+func writeErrorStatus(err error) (status int, ok bool) {
+	if err == errors.ErrorIDNotFound {
+		return http.StatusNotFound, true
+	}
+	if v, isError := err.(*errors.Error); isError {
+		switch v.Type {
+		case errors.ErrorTypeVersionConflictEngineException:
+			return http.StatusConflict, true
+		case errors.ErrorTypeDocumentMissingException:
+			return http.StatusNotFound, true
+		}
+		return http.StatusBadRequest, true
+	}
+	return 0, false
+}
@@ -16,14 +16,16 @@
 package index
 
 import (
-	"github.com/zinclabs/zincsearch/pkg/config"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/zinclabs/zincsearch/pkg/config"
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
@@ -35,7 +37,8 @@ import (
 // @security BasicAuth
 // @Tags    Index
 // @Produce json
-// @Param   index  path  string  true  "Index"
+// @Param   index                path   string  true   "Index"
+// @Param   wait_for_completion  query  bool    false  "Wait for the deletion to finish, default true"
 // @Success 200 {object} meta.HTTPResponseIndex
 // @Failure 400 {object} meta.HTTPResponseError
 // @Failure 500 {object} meta.HTTPResponseError
@@ -47,30 +50,59 @@ func Delete(c *gin.Context) {
 		return
 	}
 
-	indexList := core.ZINC_INDEX_LIST.List()
-
 	cfg := config.GetConfig(c)
+	wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "true"))
+	task := core.ZINC_TASK_LIST.Start(core.DeleteIndexAction, "delete index ["+indexNames+"]", func(task *core.Task) (interface{}, error) {
+		if err := deleteIndexes(task, indexNames, cfg.DataPath); err != nil {
+			return nil, err
+		}
+		return meta.HTTPResponse{Message: "deleted"}, nil
+	})
+	if !wait {
+		c.JSON(http.StatusOK, meta.TaskStartedResponse{Task: task.ID()})
+		return
+	}
+	resp, err := task.Result()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteIndexes deletes the comma separated indexes or wildcard patterns one by one,
+// it stops before the next index when the task is cancelled.
+func deleteIndexes(task *core.Task, indexNames, dataPath string) error {
+	indexList := core.ZINC_INDEX_LIST.List()
+	names := make([]string, 0)
 	for _, indexName := range strings.Split(indexNames, ",") {
 		if strings.Contains(indexName, "*") { // check for wildcard
-			err := deleteIndexWithWildcard(indexName, indexList, cfg.DataPath)
+			matched, err := matchIndexWithWildcard(indexName, indexList)
 			if err != nil {
-				c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
-				return
+				return err
 			}
+			names = append(names, matched...)
 			continue
 		}
-		if err := core.DeleteIndex(indexName, cfg.DataPath); err != nil {
-			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
-			return
-		}
+		names = append(names, indexName)
 	}
 
-	c.JSON(http.StatusOK, meta.HTTPResponse{
-		Message: "deleted",
-	})
+	status := meta.TaskStatus{Total: int64(len(names)), RequestsPerSecond: -1}
+	task.SetStatus(status)
+	for _, name := range names {
+		if task.Context().Err() != nil {
+			return errors.New(errors.ErrorTypeRuntimeException, "task cancelled before deleting index ["+name+"]")
+		}
+		if err := core.DeleteIndex(name, dataPath); err != nil {
+			return err
+		}
+		status.Deleted++
+		task.SetStatus(status)
+	}
+	return nil
 }
 
-func deleteIndexWithWildcard(indexName string, indexList []*core.Index, dataPath string) error {
+func matchIndexWithWildcard(indexName string, indexList []*core.Index) ([]string, error) {
 	parts := strings.Split(indexName, "*")
 	pattern := ""
 	for i, part := range parts {
@@ -82,16 +114,15 @@ func deleteIndexWithWildcard(indexName string, indexList []*core.Index, dataPath
 
 	p, err := regexp.Compile(pattern)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	names := make([]string, 0)
 	for _, i := range indexList {
 		if p.MatchString(i.GetName()) {
-			if err := core.DeleteIndex(i.GetName(), dataPath); err != nil {
-				return err
-			}
+			names = append(names, i.GetName())
 		}
 	}
 
-	return nil
+	return names, nil
 }
@@ -31,6 +31,7 @@ func TestDelete(t *testing.T) {
 	type args struct {
 		code   int
 		params map[string]string
+		query  map[string]string
 		result string
 	}
 	tests := []struct {
@@ -56,6 +57,16 @@ func TestDelete(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "in the background",
+			args: args{
+				code:   http.StatusOK,
+				params: map[string]string{"target": "TestIndexDelete.index_2"},
+				query:  map[string]string{"wait_for_completion": "false"},
+				result: `"task":"`,
+			},
+			wantErr: false,
+		},
 		{
 			name: "empty",
 			args: args{
@@ -78,6 +89,7 @@ func TestDelete(t *testing.T) {
 	cfg := config.NewGlobalConfig()
 	t.Run("prepare", func(t *testing.T) {
 		prepareIndex(t, "TestIndexDelete.index_1", "disk", cfg)
+		prepareIndex(t, "TestIndexDelete.index_2", "disk", cfg)
 		prepareIndex(t, "log-3342-44-TestIndexDelete", "disk", cfg)
 		prepareIndex(t, "log-3122-44-TestIndexDelete", "disk", cfg)
 		prepareIndex(t, "log-vvs323-44-TestIndexDelete", "disk", cfg)
@@ -87,6 +99,9 @@ func TestDelete(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			c, w := utils.NewGinContext()
 			utils.SetGinRequestParams(c, tt.args.params)
+			if tt.args.query != nil {
+				utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"], tt.args.query)
+			}
 			Delete(c)
 			assert.Equal(t, tt.args.code, w.Code)
 			assert.Contains(t, w.Body.String(), tt.args.result)
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/meta/elastic"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
@@ -23,14 +24,20 @@ import (
 // @Router /es/{index}/_mapping [get]
 func GetESMapping(c *gin.Context) {
 	indexName := c.Param("target")
-	index, exists := core.GetIndex(indexName)
-	if !exists {
+	if indexName == "" {
 		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "index " + indexName + " does not exists"})
 		return
 	}
-
-	// format mappings
-	mappings := index.GetMappings()
+	opts, err := core.ParseIndicesOptions(c.Query("ignore_unavailable"), c.Query("allow_no_indices"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	indexNames, err := core.ResolveIndexNames([]string{indexName}, opts)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
 
 	// NOTE: Zinc currently "converts" object array fields to "field.index.sub_field"
 	// Example Input Document:
@@ -50,9 +57,14 @@ func GetESMapping(c *gin.Context) {
 	//   * field.1.sub_field
 	// Which is not compatible with ES – to provide the best compatibility, the index number will be
 	// kept in the resulting mapping.
-	es := convertToESMapping(mappings)
+	resp := gin.H{}
+	for _, name := range indexNames {
+		if index, ok := core.GetIndex(name); ok {
+			resp[name] = gin.H{"mappings": convertToESMapping(index.GetMappings())}
+		}
+	}
 
-	zutils.GinRenderJSON(c, http.StatusOK, gin.H{index.GetName(): gin.H{"mappings": es}})
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
 }
 
 // convertToESMapping converts the given Zinc mappings to the ElasticSearch representation.
@@ -108,6 +108,9 @@ func SetSettings(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, meta.HTTPResponseError{Error: "can't update analyzer for existing index"})
 			return
 		}
+		if settings.DefaultPipeline != "" || settings.Lifecycle != nil {
+			_ = index.SetSettings(&meta.IndexSettings{DefaultPipeline: settings.DefaultPipeline, Lifecycle: settings.Lifecycle})
+		}
 		// store index
 		if err := core.StoreIndex(index); err != nil {
 			c.JSON(http.StatusInternalServerError, meta.HTTPResponseError{Error: err.Error()})
@@ -0,0 +1,133 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id GetPipeline
+// @Summary Get ingest pipelines for compatible ES
+// @security BasicAuth
+// @Tags    Ingest
+// @Produce json
+// @Param   id  path  string  false  "Pipeline, comma separated, default all"
+// @Success 200 {object} map[string]meta.Pipeline
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [get]
+func GetPipeline(c *gin.Context) {
+	resp := make(map[string]*meta.Pipeline)
+	names := c.Param("id")
+	if names == "" || names == "_all" || names == "*" {
+		pipelines, err := core.ListPipelines()
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		for _, p := range pipelines {
+			resp[p.Name] = p
+		}
+		zutils.GinRenderJSON(c, http.StatusOK, resp)
+		return
+	}
+	for _, name := range strings.Split(names, ",") {
+		p, err := core.GetPipeline(name)
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		resp[name] = p
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// @Id PutPipeline
+// @Summary Create or update ingest pipeline for compatible ES
+// @security BasicAuth
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   id    path  string         true  "Pipeline"
+// @Param   data  body  meta.Pipeline  true  "Pipeline data"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [put]
+func PutPipeline(c *gin.Context) {
+	pipeline := new(meta.Pipeline)
+	if err := zutils.GinBindJSON(c, pipeline); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.PutPipeline(c.Param("id"), pipeline); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id DeletePipeline
+// @Summary Delete ingest pipeline for compatible ES
+// @security BasicAuth
+// @Tags    Ingest
+// @Produce json
+// @Param   id  path  string  true  "Pipeline"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id} [delete]
+func DeletePipeline(c *gin.Context) {
+	if err := core.DeletePipeline(c.Param("id")); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id SimulatePipeline
+// @Summary Simulate ingest pipeline for compatible ES, the documents are not written
+// @security BasicAuth
+// @Tags    Ingest
+// @Accept  json
+// @Produce json
+// @Param   id    path  string                        false  "Pipeline, use the pipeline in the body if empty"
+// @Param   data  body  meta.PipelineSimulateRequest  true   "Documents"
+// @Success 200 {object} meta.PipelineSimulateResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ingest/pipeline/{id}/_simulate [post]
+func SimulatePipeline(c *gin.Context) {
+	req := new(meta.PipelineSimulateRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	resp, err := core.SimulatePipeline(c.Param("id"), req)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+type AcknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
@@ -0,0 +1,116 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/test/utils"
+)
+
+func TestPipeline(t *testing.T) {
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+	metadata.NewStorager(cfg)
+
+	t.Run("put pipeline", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			id     string
+			data   string
+			code   int
+			result string
+		}{
+			{
+				name:   "normal",
+				id:     "TestPipeline.pipeline_1",
+				data:   `{"description":"lowercase the level","processors":[{"lowercase":{"field":"level"}},{"drop":{"if":{"field":"level","equals":"debug"}}}]}`,
+				code:   http.StatusOK,
+				result: `"acknowledged":true`,
+			},
+			{
+				name:   "unknown processor",
+				id:     "TestPipeline.pipeline_2",
+				data:   `{"processors":[{"foo":{}}]}`,
+				code:   http.StatusBadRequest,
+				result: `no processor type exists with name [foo]`,
+			},
+			{
+				name:   "invalid name",
+				id:     "_pipeline",
+				data:   `{"processors":[]}`,
+				code:   http.StatusBadRequest,
+				result: `is invalid`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestData(c, tt.data)
+				utils.SetGinRequestParams(c, map[string]string{"id": tt.id})
+				PutPipeline(c)
+				assert.Equal(t, tt.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.result)
+			})
+		}
+	})
+
+	t.Run("get pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		GetPipeline(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"lowercase the level"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_2"})
+		GetPipeline(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("simulate pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"docs":[{"_source":{"level":"INFO"}},{"_source":{"level":"DEBUG"}},{"_source":{"level":1}}]}`)
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		SimulatePipeline(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"_source":{"level":"info"}`)
+		assert.Contains(t, w.Body.String(), `{},`)
+		assert.Contains(t, w.Body.String(), `cannot be cast to string`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"pipeline":{"processors":[{"set":{"field":"a","value":"{{b}}"}}]},"docs":[{"_source":{"b":"x"}}]}`)
+		SimulatePipeline(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"a":"x"`)
+	})
+
+	t.Run("delete pipeline", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		DeletePipeline(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestPipeline.pipeline_1"})
+		DeletePipeline(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,181 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package lifecycle
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id GetLifecyclePolicy
+// @Summary Get index lifecycle policies
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Produce json
+// @Param   id  path  string  false  "Policy, comma separated, default all"
+// @Success 200 {object} map[string]meta.LifecyclePolicy
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [get]
+func GetPolicy(c *gin.Context) {
+	resp := make(map[string]*meta.LifecyclePolicy)
+	names := c.Param("id")
+	if names == "" || names == "_all" || names == "*" {
+		policies, err := core.ListLifecyclePolicies()
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		for _, p := range policies {
+			resp[p.Name] = p
+		}
+		zutils.GinRenderJSON(c, http.StatusOK, resp)
+		return
+	}
+	for _, name := range strings.Split(names, ",") {
+		p, err := core.GetLifecyclePolicy(name)
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		resp[name] = p
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// @Id PutLifecyclePolicy
+// @Summary Create or update index lifecycle policy
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Accept  json
+// @Produce json
+// @Param   id    path  string                true  "Policy"
+// @Param   data  body  meta.LifecyclePolicy  true  "Policy data"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [put]
+func PutPolicy(c *gin.Context) {
+	policy := new(meta.LifecyclePolicy)
+	if err := zutils.GinBindJSON(c, policy); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.PutLifecyclePolicy(c.Param("id"), policy); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id DeleteLifecyclePolicy
+// @Summary Delete index lifecycle policy, it can't be deleted while indexes use it
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Produce json
+// @Param   id  path  string  true  "Policy"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_ilm/policy/{id} [delete]
+func DeletePolicy(c *gin.Context) {
+	if err := core.DeleteLifecyclePolicy(c.Param("id")); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id ExplainLifecycle
+// @Summary Explain the lifecycle phase of the indexes
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Produce json
+// @Param   target  path  string  true  "Indexes, aliases or wildcard patterns, comma separated"
+// @Success 200 {object} ExplainResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/{target}/_ilm/explain [get]
+func Explain(c *gin.Context) {
+	indices, err := core.ExplainLifecycle(c.Param("target"), time.Now())
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, ExplainResponse{Indices: indices})
+}
+
+// @Id RemoveLifecycle
+// @Summary Detach the lifecycle policy from the indexes
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Produce json
+// @Param   target  path  string  true  "Indexes, aliases or wildcard patterns, comma separated"
+// @Success 200 {object} RemoveResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/{target}/_ilm/remove [post]
+func Remove(c *gin.Context) {
+	if _, err := core.RemoveLifecycle(c.Param("target")); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, RemoveResponse{FailedIndexes: []string{}})
+}
+
+// @Id Rollover
+// @Summary Roll the write index of an alias over to a new index, always if no condition is given
+// @security BasicAuth
+// @Tags    Lifecycle
+// @Accept  json
+// @Produce json
+// @Param   target  path  string                true   "Rollover alias"
+// @Param   data    body  meta.RolloverRequest  false  "Conditions"
+// @Success 200 {object} meta.RolloverResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{target}/_rollover [post]
+func Rollover(c *gin.Context) {
+	req := new(meta.RolloverRequest)
+	if c.Request.ContentLength != 0 {
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+	resp, err := core.Rollover(c.Param("target"), req.Conditions, time.Now())
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+type AcknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+type ExplainResponse struct {
+	Indices map[string]*meta.LifecycleExplain `json:"indices"`
+}
+
+type RemoveResponse struct {
+	HasFailures   bool     `json:"has_failures"`
+	FailedIndexes []string `json:"failed_indexes"`
+}
@@ -0,0 +1,114 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package lifecycle
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/test/utils"
+)
+
+func TestLifecycle(t *testing.T) {
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+	metadata.NewStorager(cfg)
+
+	t.Run("put policy", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			id     string
+			data   string
+			code   int
+			result string
+		}{
+			{
+				name:   "normal",
+				id:     "TestLifecycle.policy_1",
+				data:   `{"rollover":{"max_size":"50gb","max_age":"1d"},"delete":{"min_age":"30d"}}`,
+				code:   http.StatusOK,
+				result: `"acknowledged":true`,
+			},
+			{
+				name:   "invalid duration",
+				id:     "TestLifecycle.policy_2",
+				data:   `{"delete":{"min_age":"forever"}}`,
+				code:   http.StatusBadRequest,
+				result: `delete.min_age [forever] is invalid`,
+			},
+			{
+				name:   "empty",
+				id:     "TestLifecycle.policy_2",
+				data:   `{}`,
+				code:   http.StatusBadRequest,
+				result: `should have a rollover or a delete`,
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				c, w := utils.NewGinContext()
+				utils.SetGinRequestData(c, tt.data)
+				utils.SetGinRequestParams(c, map[string]string{"id": tt.id})
+				PutPolicy(c)
+				assert.Equal(t, tt.code, w.Code)
+				assert.Contains(t, w.Body.String(), tt.result)
+			})
+		}
+	})
+
+	t.Run("get policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		GetPolicy(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"max_size":"50gb"`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_2"})
+		GetPolicy(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestLifecycle.index_missing"})
+		Explain(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("rollover", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"target": "TestLifecycle.alias_missing"})
+		Rollover(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `is not an alias with a write index`)
+	})
+
+	t.Run("delete policy", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		DeletePolicy(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"id": "TestLifecycle.policy_1"})
+		DeletePolicy(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
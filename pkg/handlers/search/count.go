@@ -0,0 +1,103 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+// Count returns the number of documents matched by the query
+//
+// @Id Count
+// @Summary Count the documents matched by the query
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   target              path   string          true   "Index"
+// @Param   q                   query  string          false  "Query in the query_string syntax"
+// @Param   ignore_unavailable  query  bool            false  "Ignore the missing indexes"
+// @Param   allow_no_indices    query  bool            false  "Allow the wildcards without matched index"
+// @Param   query               body   meta.ZincQuery  false  "Query"
+// @Success 200 {object} meta.CountResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{target}/_count [post]
+func Count(c *gin.Context) {
+	query, err := bindQuery(c)
+	if err != nil {
+		log.Printf("handlers.search.Count: %s", err.Error())
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	indexNames, err := resolveIndexNames(c, []string{c.Param("target")}, nil)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	if len(indexNames) == 0 {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.CountResponse{})
+		return
+	}
+
+	resp, err := core.Count(indexNames, query, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// bindQuery reads the optional query of the body, the q param replaces it with a query_string query
+func bindQuery(c *gin.Context) (*meta.ZincQuery, error) {
+	query := new(meta.ZincQuery)
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Request.Body.Close()
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if err := json.Unmarshal(body, query); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if q := c.Query("q"); q != "" {
+		queryString := map[string]interface{}{"query": q}
+		if v := c.Query("df"); v != "" {
+			queryString["default_field"] = v
+		}
+		if v := c.Query("default_operator"); v != "" {
+			queryString["default_operator"] = v
+		}
+		query.Query = map[string]interface{}{"query_string": queryString}
+	}
+	return query, nil
+}
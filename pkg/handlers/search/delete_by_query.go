@@ -1,13 +1,13 @@
 package search
 
 import (
-	"github.com/zinclabs/zincsearch/pkg/config"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 
+	"github.com/zinclabs/zincsearch/pkg/config"
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
@@ -22,54 +22,73 @@ import (
 // @Tags    Search
 // @Accept  json
 // @Produce json
-// @Param   index  path  string  true  "Index"
-// @Param   query  body  meta.ZincQueryForSDK true  "Query"
+// @Param   index                path   string                     true   "Index"
+// @Param   wait_for_completion  query  bool                       false  "Wait for the deletion to finish, default true"
+// @Param   conflicts            query  string                     false  "abort or proceed on version conflicts, default abort"
+// @Param   max_docs             query  integer                    false  "Documents to delete at most, default all"
+// @Param   requests_per_second  query  number                     false  "Documents per second, default unlimited"
+// @Param   scroll_size          query  integer                    false  "Documents per batch, default 1000"
+// @Param   slices               query  string                     false  "Parallel deletions of a batch, a number or auto, default 1"
+// @Param   query                body   meta.DeleteByQueryRequest  true   "Query"
 // @Success 200 {object} meta.HTTPResponseDeleteByQuery
 // @Failure 400 {object} meta.HTTPResponseError
 // @Router /es/{index}/_delete_by_query [post]
 func DeleteByQuery(c *gin.Context) {
-	start := time.Now()
-	query := &meta.ZincQuery{Size: 10}
-	if err := zutils.GinBindJSON(c, query); err != nil {
-		log.Printf("handlers.search.searchDSL: %s", err.Error())
+	req := new(meta.DeleteByQueryRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		log.Printf("handlers.search.DeleteByQuery: %s", err.Error())
 		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
 		return
 	}
 
-	cfg := config.GetConfig(c)
-
-	indexName := c.Param("target")
-	resp, err := searchIndex([]string{indexName}, query, cfg)
+	wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "true"))
+	requestsPerSecond, err := strconv.ParseFloat(c.DefaultQuery("requests_per_second", "-1"), 64)
 	if err != nil {
-		errors.HandleError(c, err)
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "requests_per_second should be a number"})
 		return
 	}
-
-	failures := []string{}
-	for _, hit := range resp.Hits.Hits {
-		index, _ := core.GetIndex(hit.Index)
-		err := index.DeleteDocument(hit.ID, cfg.Shard.GoroutineNum)
-		if err != nil {
-			failures = append(failures, hit.ID)
+	if req.ScrollSize, err = strconv.Atoi(c.DefaultQuery("scroll_size", "0")); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "scroll_size should be an integer"})
+		return
+	}
+	if v := c.Query("max_docs"); v != "" {
+		if req.MaxDocs, err = strconv.ParseInt(v, 10, 64); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "max_docs should be an integer"})
+			return
 		}
 	}
+	if v := c.Query("conflicts"); v != "" {
+		req.Conflicts = v
+	}
+	if req.Indices, err = core.ParseIndicesOptions(c.Query("ignore_unavailable"), c.Query("allow_no_indices")); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	slices := 1
+	if v := c.DefaultQuery("slices", "1"); v == "auto" {
+		slices = 0
+	} else if slices, err = strconv.Atoi(v); err != nil || slices < 1 {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "slices should be a positive integer or auto"})
+		return
+	}
 
-	totalDeletes := resp.Hits.Total.Value - len(failures)
-	zutils.GinRenderJSON(c, http.StatusOK, meta.HTTPResponseDeleteByQuery{
-		Took:             time.Since(start).Milliseconds(),
-		TimedOut:         false,
-		Total:            totalDeletes,
-		Deleted:          totalDeletes,
-		Batches:          0,
-		VersionConflicts: 0,
-		Noops:            0,
-		Failures:         failures,
-		Retries: meta.HttpRetriesResponse{
-			Bulk:   0,
-			Search: 0,
-		},
-		ThrottledMillis:      0,
-		RequestsPerSecond:    -1,
-		ThrottledUntilMillis: 0,
+	deletion, err := core.NewDeleteByQuery(c.Param("target"), req, requestsPerSecond, slices)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	cfg := config.GetConfig(c)
+	task := core.ZINC_TASK_LIST.Start(core.DeleteByQueryAction, deletion.Description(), func(task *core.Task) (interface{}, error) {
+		return deletion.Run(task, cfg)
 	})
+	if !wait {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.TaskStartedResponse{Task: task.ID()})
+		return
+	}
+	resp, err := task.Result()
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
 }
@@ -5,7 +5,6 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/metadata"
 	"net/http/httptest"
 	"testing"
-	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
@@ -14,12 +13,14 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/ider"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 type arg struct {
-	doc    map[string]interface{}
-	query  string
-	params map[string]string
+	doc      map[string]interface{}
+	query    string
+	params   map[string]string
+	urlQuery map[string]string
 }
 
 type body struct {
@@ -70,7 +71,80 @@ func TestDeleteByQuery(t *testing.T) {
 					outcome:    true,
 					statusCode: 200,
 					body: body{
-						contains: `"time_out":false,"total":1,"deleted":1,"batches":0,"version_conflicts":0,"noops":0,"failures":[],"retries":{"bulk":0,"search":0},"throttled_millis":0,"requests_per_second":-1,"throttled_until_millis":0}`,
+						contains: `"time_out":false,"total":1,"deleted":1,"batches":1,"version_conflicts":0,"noops":0,"failures":[],"retries":{"bulk":0,"search":0},"throttled_millis":0,"requests_per_second":-1,"throttled_until_millis":0}`,
+					},
+				},
+			},
+		},
+		{
+			name: "should delete matched documents in the background",
+			arg: arg{
+				doc: map[string]interface{}{
+					"name": "zinc",
+				},
+				query: `{"query":{"match":{"name":"zinc"}},"size":10}`,
+				params: map[string]string{
+					"target": "TestDeleteByQuery.index",
+				},
+				urlQuery: map[string]string{
+					"wait_for_completion": "false",
+				},
+			},
+			want: want{
+				success: success{
+					outcome:    true,
+					statusCode: 200,
+					body: body{
+						contains: `{"task":"`,
+					},
+				},
+			},
+		},
+		{
+			name: "should delete matched documents with slices",
+			arg: arg{
+				doc: map[string]interface{}{
+					"name": "zinc",
+				},
+				query: `{"query":{"match":{"name":"zinc"}}}`,
+				params: map[string]string{
+					"target": "TestDeleteByQuery.index",
+				},
+				urlQuery: map[string]string{
+					"slices":      "auto",
+					"scroll_size": "1",
+					"conflicts":   "proceed",
+				},
+			},
+			want: want{
+				success: success{
+					outcome:    true,
+					statusCode: 200,
+					body: body{
+						contains: `"total":1,"deleted":1,"batches":1,`,
+					},
+				},
+			},
+		},
+		{
+			name: "should return bad request with invalid slices",
+			arg: arg{
+				doc: map[string]interface{}{
+					"name": "zinc",
+				},
+				query: `{"query":{"match":{"name":"zinc"}}}`,
+				params: map[string]string{
+					"target": "TestDeleteByQuery.index",
+				},
+				urlQuery: map[string]string{
+					"slices": "0",
+				},
+			},
+			want: want{
+				failure: failure{
+					statusCode: 400,
+					body: body{
+						is: `{"error":"slices should be a positive integer or auto"}`,
 					},
 				},
 			},
@@ -96,7 +170,7 @@ func TestDeleteByQuery(t *testing.T) {
 			},
 		},
 		{
-			name: "should return bad request when no matching indices are found",
+			name: "should return not found when no matching indices are found",
 			arg: arg{
 				doc: map[string]interface{}{
 					"name": "zinc",
@@ -108,9 +182,9 @@ func TestDeleteByQuery(t *testing.T) {
 			},
 			want: want{
 				failure: failure{
-					statusCode: 400,
+					statusCode: 404,
 					body: body{
-						is: `{"error":"index noneMatchingIndex does not exists"}`,
+						contains: `no such index [noneMatchingIndex]`,
 					},
 				},
 			},
@@ -124,15 +198,19 @@ func TestDeleteByQuery(t *testing.T) {
 			assert.NoError(t, core.StoreIndex(index))
 			id := node.Generate()
 			assert.NoError(t, index.CreateDocument(id, test.arg.doc, false, cfg.EnableTextKeywordMapping))
-			time.Sleep(time.Second)
+			wait.ForRefresh(t, index.GetName())
 
 			c, w := utils.NewGinContext()
 			utils.SetGinRequestData(c, test.arg.query)
 			utils.SetGinRequestParams(c, test.arg.params)
+			if test.arg.urlQuery != nil {
+				utils.SetGinRequestURL(c, "/es/"+test.arg.params["target"]+"/_delete_by_query", test.arg.urlQuery)
+			}
 			DeleteByQuery(c)
 
 			if test.want.success.outcome {
-				time.Sleep(time.Second)
+				wait.ForTasks(t)
+				wait.ForRefresh(t, index.GetName())
 				assertHTTPResponse(t, w, test.want.success.statusCode, test.want.success.body)
 				assertZeruResultQuery(t, index, test.arg.query, cfg)
 			} else {
@@ -0,0 +1,66 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// Explain returns how the query scores a document
+//
+// @Id Explain
+// @Summary Explain the score of a document for the query
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   target  path   string          true   "Index"
+// @Param   id      path   string          true   "ID"
+// @Param   q       query  string          false  "Query in the query_string syntax"
+// @Param   query   body   meta.ZincQuery  false  "Query"
+// @Success 200 {object} meta.ExplainResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.ExplainResponse
+// @Router /es/{target}/_explain/{id} [post]
+func Explain(c *gin.Context) {
+	query, err := bindQuery(c)
+	if err != nil {
+		log.Printf("handlers.search.Explain: %s", err.Error())
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+
+	target, id := c.Param("target"), c.Param("id")
+	resp, err := core.Explain(target, id, query, config.GetConfig(c))
+	if err == errors.ErrorIDNotFound {
+		zutils.GinRenderJSON(c, http.StatusNotFound, meta.ExplainResponse{Index: target, ID: id})
+		return
+	}
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
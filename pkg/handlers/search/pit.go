@@ -0,0 +1,107 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// OpenPIT opens a point in time on the indexes
+//
+// @Id OpenPIT
+// @Summary Open point in time for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Produce json
+// @Param   index       path   string  true  "Index"
+// @Param   keep_alive  query  string  true  "Keep alive, eg: 1m"
+// @Success 200 {object} OpenPITResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{index}/_pit [post]
+func OpenPIT(c *gin.Context) {
+	indexName := c.Param("target")
+	keepAlive := c.Query("keep_alive")
+	if keepAlive == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[keep_alive] is required"})
+		return
+	}
+	d, err := zutils.ParseDuration(keepAlive)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[keep_alive] " + err.Error()})
+		return
+	}
+
+	id, err := core.ZINC_PIT_LIST.Open(strings.Split(indexName, ","), d, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, OpenPITResponse{ID: id})
+}
+
+// ClosePIT closes the point in time
+//
+// @Id ClosePIT
+// @Summary Close point in time for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  ClosePITRequest true  "Point in time id"
+// @Success 200 {object} ClosePITResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} ClosePITResponse
+// @Router /es/_pit [delete]
+func ClosePIT(c *gin.Context) {
+	req := new(ClosePITRequest)
+	if err := zutils.GinBindJSON(c, req); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if req.ID == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[id] is required"})
+		return
+	}
+
+	freed := core.ZINC_PIT_LIST.Delete(req.ID)
+	code := http.StatusOK
+	if freed == 0 {
+		code = http.StatusNotFound
+	}
+	zutils.GinRenderJSON(c, code, ClosePITResponse{Succeeded: true, NumFreed: freed})
+}
+
+type OpenPITResponse struct {
+	ID string `json:"id"`
+}
+
+type ClosePITRequest struct {
+	ID string `json:"id"`
+}
+
+type ClosePITResponse struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
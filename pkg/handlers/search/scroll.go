@@ -0,0 +1,140 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package search
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// Scroll returns the next page of a scroll search
+//
+// @Id Scroll
+// @Summary Scroll search for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  ScrollRequest true  "Scroll"
+// @Success 200 {object} meta.SearchResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [post]
+func Scroll(c *gin.Context) {
+	req := new(ScrollRequest)
+	if c.Request.ContentLength != 0 {
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+	if v := c.Query("scroll_id"); v != "" {
+		req.ScrollID = v
+	}
+	if v := c.Param("scroll_id"); v != "" {
+		req.ScrollID = v
+	}
+	if v := c.Query("scroll"); v != "" {
+		req.Scroll = v
+	}
+	if req.ScrollID == "" {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll_id] is required"})
+		return
+	}
+	if req.Scroll == "" {
+		req.Scroll = "5m"
+	}
+	keepAlive, err := zutils.ParseDuration(req.Scroll)
+	if err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll] " + err.Error()})
+		return
+	}
+
+	resp, err := core.ZINC_SCROLL_LIST.Next(req.ScrollID, keepAlive, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// ClearScroll closes the scroll searches
+//
+// @Id ClearScroll
+// @Summary Clear scroll for compatible ES
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   query  body  ClearScrollRequest true  "Scroll ids"
+// @Success 200 {object} ClearScrollResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_search/scroll [delete]
+func ClearScroll(c *gin.Context) {
+	var ids []string
+	if v := c.Param("scroll_id"); v != "" {
+		ids = append(ids, v)
+	} else if c.Request.ContentLength != 0 {
+		req := new(ClearScrollRequest)
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+		switch v := req.ScrollID.(type) {
+		case string:
+			ids = append(ids, v)
+		case []interface{}:
+			for _, id := range v {
+				if id, ok := id.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	var freed int
+	if len(ids) == 1 && ids[0] == "_all" {
+		freed = core.ZINC_SCROLL_LIST.DeleteAll()
+	} else {
+		freed = core.ZINC_SCROLL_LIST.Delete(ids...)
+	}
+
+	code := http.StatusOK
+	if freed == 0 && len(ids) > 0 && ids[0] != "_all" {
+		code = http.StatusNotFound
+	}
+	zutils.GinRenderJSON(c, code, ClearScrollResponse{Succeeded: true, NumFreed: freed})
+}
+
+type ScrollRequest struct {
+	Scroll   string `json:"scroll"`
+	ScrollID string `json:"scroll_id"`
+}
+
+type ClearScrollRequest struct {
+	ScrollID interface{} `json:"scroll_id"` // string or []string
+}
+
+type ClearScrollResponse struct {
+	Succeeded bool `json:"succeeded"`
+	NumFreed  int  `json:"num_freed"`
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -55,25 +56,55 @@ func SearchDSL(c *gin.Context) {
 		return
 	}
 
-	resp, err := searchIndex(strings.Split(indexName, ","), query, config.GetConfig(c))
+	var resp *meta.SearchResponse
+	var err error
+	var indexNames []string
+	if query.PIT != nil {
+		if indexName != "" {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[indices] cannot be used with point in time, do not specify any index with point in time"})
+			return
+		}
+		if c.Query("scroll") != "" {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll] cannot be used with point in time"})
+			return
+		}
+		resp, err = core.ZINC_PIT_LIST.Search(query, config.GetConfig(c))
+	} else if indexNames, err = resolveIndexNames(c, []string{indexName}, nil); err != nil {
+		errors.HandleError(c, err)
+		return
+	} else if len(indexNames) == 0 {
+		resp = &meta.SearchResponse{Hits: meta.Hits{Hits: []meta.Hit{}}}
+	} else if scroll := c.Query("scroll"); scroll != "" {
+		var keepAlive time.Duration
+		if keepAlive, err = zutils.ParseDuration(scroll); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "[scroll] " + err.Error()})
+			return
+		}
+		resp, err = core.ZINC_SCROLL_LIST.NewScroll(indexNames, query, keepAlive, config.GetConfig(c))
+	} else {
+		resp, err = searchIndex(indexNames, query, config.GetConfig(c))
+	}
 	if err != nil {
 		errors.HandleError(c, err)
 		return
 	}
 
-	if indexName != "" {
-		// TODO: adapt this to allow strings.Split(indexName, ",") slice
-		idx, ok := core.ZINC_INDEX_LIST.Get(indexName)
-		if ok {
-			storageSize := idx.GetStats().StorageSize
-			eventData := make(map[string]interface{})
-			eventData["search_type"] = "query_dsl"
-			eventData["search_index_storage"] = idx.GetStorageType()
-			eventData["search_index_size_in_mb"] = storageSize / 1024 / 1024
-			eventData["time_taken_to_search_in_ms"] = resp.Took
-			eventData["aggregations_count"] = len(query.Aggregations)
-			core.GetTelemetry(c).Event("search", eventData)
+	if indexName != "" && len(indexNames) > 0 {
+		var storageSize uint64
+		var storageType string
+		for _, name := range indexNames {
+			if idx, ok := core.ZINC_INDEX_LIST.Get(name); ok {
+				storageSize += idx.GetStats().StorageSize
+				storageType = idx.GetStorageType()
+			}
 		}
+		eventData := make(map[string]interface{})
+		eventData["search_type"] = "query_dsl"
+		eventData["search_index_storage"] = storageType
+		eventData["search_index_size_in_mb"] = storageSize / 1024 / 1024
+		eventData["time_taken_to_search_in_ms"] = resp.Took
+		eventData["aggregations_count"] = len(query.Aggregations)
+		core.GetTelemetry(c).Event("search", eventData)
 	}
 
 	zutils.GinRenderJSON(c, http.StatusOK, resp)
@@ -109,11 +140,17 @@ func MultipleSearch(c *gin.Context) {
 	buf := make([]byte, maxCapacityPerLine)
 	scanner.Buffer(buf, maxCapacityPerLine)
 
+	defaultOptions, err := core.ParseIndicesOptions(c.Query("ignore_unavailable"), c.Query("allow_no_indices"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+
 	indexNames := make([]string, 0)
+	var options meta.IndicesOptions
 	nextLineIsData := false
 
 	var doc map[string]interface{}
-	var err error
 	for scanner.Scan() { // Read each line
 		if nextLineIsData {
 			nextLineIsData = false
@@ -124,7 +161,16 @@ func MultipleSearch(c *gin.Context) {
 				continue
 			}
 			// search query
-			resp, err := searchIndex(indexNames, query, cfg)
+			names, err := resolveIndexNames(c, indexNames, &options)
+			if err != nil {
+				log.Error().Msgf("handlers.search.MultipleSearch.resolveIndexNames: err %s", err.Error())
+				responses = append(responses, &meta.SearchResponse{Error: err.Error()})
+				continue
+			}
+			resp := &meta.SearchResponse{Hits: meta.Hits{Hits: []meta.Hit{}}}
+			if len(names) > 0 {
+				resp, err = searchIndex(names, query, cfg)
+			}
 			if err != nil {
 				log.Error().Msgf("handlers.search.MultipleSearch.searchIndex: err %s", err.Error())
 				responses = append(responses, &meta.SearchResponse{Error: err.Error()})
@@ -134,6 +180,13 @@ func MultipleSearch(c *gin.Context) {
 		} else {
 			nextLineIsData = true
 			indexNames = indexNames[:0]
+			// the header can override the params, the default allow_no_indices isn't shared with it
+			options = meta.IndicesOptions{IgnoreUnavailable: defaultOptions.IgnoreUnavailable}
+			if defaultOptions.AllowNoIndices != nil {
+				allowNoIndices := *defaultOptions.AllowNoIndices
+				options.AllowNoIndices = &allowNoIndices
+			}
+			_ = json.Unmarshal(scanner.Bytes(), &options)
 			if err = json.Unmarshal(scanner.Bytes(), &doc); err != nil {
 				log.Error().Msgf("handlers.search.MultipleSearch.json.Unmarshal: %s, err %s", scanner.Text(), err.Error())
 				continue
@@ -156,21 +209,31 @@ func MultipleSearch(c *gin.Context) {
 	zutils.GinRenderJSON(c, http.StatusOK, gin.H{"responses": responses})
 }
 
+// resolveIndexNames resolves the targets with the ignore_unavailable and allow_no_indices params,
+// or with opts if it is given. A missing index is still a bad request for the search endpoints.
+func resolveIndexNames(c *gin.Context, targets []string, opts *meta.IndicesOptions) ([]string, error) {
+	if opts == nil {
+		params, err := core.ParseIndicesOptions(c.Query("ignore_unavailable"), c.Query("allow_no_indices"))
+		if err != nil {
+			return nil, err
+		}
+		opts = &params
+	}
+	names, err := core.ResolveIndexNames(targets, *opts)
+	if e := new(errors.Error); errors.As(err, &e) && e.Type == errors.ErrorTypeIndexNotFoundException {
+		return nil, fmt.Errorf("%s does not exists", strings.TrimPrefix(e.Reason, "no such "))
+	}
+	return names, err
+}
+
+// searchIndex searches the resolved indexes, the results of several indexes are merged
 func searchIndex(indexNames []string, query *meta.ZincQuery, cfg *config.Config) (*meta.SearchResponse, error) {
-	indexName := ""
-	if len(indexNames) > 0 {
-		indexName = indexNames[0]
+	if len(indexNames) != 1 {
+		return core.MultiSearch(indexNames, query, cfg)
 	}
-	var err error
-	var resp *meta.SearchResponse
-	if indexName == "" || strings.HasSuffix(indexName, "*") || strings.HasPrefix(indexName, "*") || len(indexNames) > 1 {
-		resp, err = core.MultiSearch(indexNames, query, cfg)
-	} else {
-		index, exists := core.GetIndex(indexName)
-		if !exists {
-			return nil, fmt.Errorf("index %s does not exists", indexName)
-		}
-		resp, err = index.Search(query, cfg)
+	index, exists := core.GetIndex(indexNames[0])
+	if !exists {
+		return nil, fmt.Errorf("index %s does not exists", indexNames[0])
 	}
-	return resp, err
+	return index.Search(query, cfg)
 }
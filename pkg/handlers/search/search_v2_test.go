@@ -24,6 +24,7 @@ import (
 
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/test/utils"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestSearchDSL(t *testing.T) {
@@ -32,6 +33,7 @@ func TestSearchDSL(t *testing.T) {
 		code   int
 		data   string
 		params map[string]string
+		query  map[string]string
 		result string
 	}
 	cfg := config.NewGlobalConfig()
@@ -65,6 +67,53 @@ func TestSearchDSL(t *testing.T) {
 				result: "does not exists",
 			},
 		},
+		{
+			name: "comma list with missing index",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   `{"query":{"match_all":{}},"size":10}`,
+				params: map[string]string{"target": indexName + ",NotExist" + indexName},
+				result: "does not exists",
+			},
+		},
+		{
+			name: "ignore_unavailable",
+			args: args{
+				code:   http.StatusOK,
+				data:   `{"query":{"match_all":{}},"size":10}`,
+				params: map[string]string{"target": indexName + ",NotExist" + indexName},
+				query:  map[string]string{"ignore_unavailable": "true"},
+				result: "successful",
+			},
+		},
+		{
+			name: "wildcard without match",
+			args: args{
+				code:   http.StatusOK,
+				data:   `{"query":{"match_all":{}},"size":10}`,
+				params: map[string]string{"target": "NotExist*"},
+				result: `"hits":[]`,
+			},
+		},
+		{
+			name: "allow_no_indices",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   `{"query":{"match_all":{}},"size":10}`,
+				params: map[string]string{"target": "NotExist*"},
+				query:  map[string]string{"allow_no_indices": "false"},
+				result: "does not exists",
+			},
+		},
+		{
+			name: "exclusion",
+			args: args{
+				code:   http.StatusOK,
+				data:   `{"query":{"match_all":{}},"size":10}`,
+				params: map[string]string{"target": "TestSearchDSL.*,-" + indexName},
+				result: `"hits":[]`,
+			},
+		},
 		{
 			name: "query jsone error",
 			args: args{
@@ -89,6 +138,7 @@ func TestSearchDSL(t *testing.T) {
 			c, w := utils.NewGinContext()
 			utils.SetGinRequestData(c, tt.args.data)
 			utils.SetGinRequestParams(c, tt.args.params)
+			utils.SetGinRequestURL(c, "/es/_search", tt.args.query)
 			SearchDSL(c)
 			assert.Equal(t, tt.args.code, w.Code)
 			assert.Contains(t, w.Body.String(), tt.args.result)
@@ -168,3 +218,126 @@ func TestMultipleSearch(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestCount(t *testing.T) {
+	indexName := "TestCount.index_1"
+	type args struct {
+		code   int
+		data   string
+		params map[string]string
+		query  map[string]string
+		result string
+	}
+	cfg := config.NewGlobalConfig()
+	tests := []struct {
+		name string
+		args args
+	}{
+		{
+			name: "without body",
+			args: args{
+				code:   http.StatusOK,
+				params: map[string]string{"target": indexName},
+				result: `"count":3,`,
+			},
+		},
+		{
+			name: "query",
+			args: args{
+				code:   http.StatusOK,
+				data:   `{"query":{"match":{"name":"zinc"}}}`,
+				params: map[string]string{"target": indexName},
+				result: `"count":2,`,
+			},
+		},
+		{
+			name: "q param",
+			args: args{
+				code:   http.StatusOK,
+				params: map[string]string{"target": indexName},
+				query:  map[string]string{"q": "name:search"},
+				result: `"count":1,`,
+			},
+		},
+		{
+			name: "missing index",
+			args: args{
+				code:   http.StatusBadRequest,
+				params: map[string]string{"target": indexName + "_missing"},
+				result: "does not exists",
+			},
+		},
+		{
+			name: "wildcard without index",
+			args: args{
+				code:   http.StatusOK,
+				params: map[string]string{"target": indexName + "_missing*"},
+				result: `"count":0,`,
+			},
+		},
+		{
+			name: "invalid body",
+			args: args{
+				code:   http.StatusBadRequest,
+				data:   `{"query":{"match_all":{x}}}`,
+				params: map[string]string{"target": indexName},
+				result: "invalid character",
+			},
+		},
+	}
+
+	t.Run("prepare", func(t *testing.T) {
+		index, err := core.NewIndex(indexName, "disk", 2, cfg)
+		assert.NoError(t, err)
+		assert.NoError(t, core.StoreIndex(index))
+		for id, name := range map[string]string{"1": "zinc", "2": "zinc labs", "3": "search"} {
+			err := index.CreateDocument(id, map[string]interface{}{"name": name}, false, cfg.EnableTextKeywordMapping)
+			assert.NoError(t, err)
+		}
+		wait.ForRefresh(t, indexName)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.args.data)
+			utils.SetGinRequestParams(c, tt.args.params)
+			utils.SetGinRequestURL(c, "/es/"+tt.args.params["target"]+"/_count", tt.args.query)
+			Count(c)
+			assert.Equal(t, tt.args.code, w.Code)
+			assert.Contains(t, w.Body.String(), tt.args.result)
+		})
+	}
+
+	t.Run("explain", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match":{"name":"zinc"}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "1"})
+		Explain(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"matched":true,"explanation":{"value":`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"match":{"name":"zinc"}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName, "id": "10"})
+		Explain(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"matched":false`)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestData(c, `{"query":{"unknown":{}}}`)
+		utils.SetGinRequestParams(c, map[string]string{"target": indexName})
+		utils.SetGinRequestURL(c, "/es/"+indexName+"/_validate/query", map[string]string{"explain": "true"})
+		ValidateQuery(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"valid":false`)
+		assert.Contains(t, w.Body.String(), `"error":"[`+indexName+`]`)
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		err := core.DeleteIndex(indexName, cfg.DataPath)
+		assert.NoError(t, err)
+	})
+}
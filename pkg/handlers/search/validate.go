@@ -0,0 +1,72 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package search
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// ValidateQuery checks the query without executing it
+//
+// @Id ValidateQuery
+// @Summary Validate the query, with explain it returns the rewritten query
+// @security BasicAuth
+// @Tags    Search
+// @Accept  json
+// @Produce json
+// @Param   target   path   string          true   "Index"
+// @Param   explain  query  bool            false  "Return the rewritten query of every index"
+// @Param   q        query  string          false  "Query in the query_string syntax"
+// @Param   query    body   meta.ZincQuery  false  "Query"
+// @Success 200 {object} meta.ValidateQueryResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/{target}/_validate/query [post]
+func ValidateQuery(c *gin.Context) {
+	query, err := bindQuery(c)
+	if err != nil {
+		log.Printf("handlers.search.ValidateQuery: %s", err.Error())
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	explain, _ := strconv.ParseBool(c.DefaultQuery("explain", "false"))
+
+	indexNames, err := resolveIndexNames(c, []string{c.Param("target")}, nil)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	if len(indexNames) == 0 {
+		zutils.GinRenderJSON(c, http.StatusOK, meta.ValidateQueryResponse{Valid: true})
+		return
+	}
+
+	resp, err := core.ValidateQuery(indexNames, query, explain, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
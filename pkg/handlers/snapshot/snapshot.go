@@ -0,0 +1,245 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package snapshot
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// @Id PutRepository
+// @Summary Register snapshot repository for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository  path  string                   true  "Repository"
+// @Param   data        body  meta.SnapshotRepository  true  "Repository settings"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [put]
+func PutRepository(c *gin.Context) {
+	repo := new(meta.SnapshotRepository)
+	if err := zutils.GinBindJSON(c, repo); err != nil {
+		zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+		return
+	}
+	if err := core.PutSnapshotRepository(c.Param("repository"), repo, config.GetConfig(c)); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id GetRepository
+// @Summary Get snapshot repositories for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  false  "Repository, comma separated, default all"
+// @Success 200 {object} map[string]meta.SnapshotRepository
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [get]
+func GetRepository(c *gin.Context) {
+	resp := make(map[string]*meta.SnapshotRepository)
+	names := c.Param("repository")
+	if names == "" || names == "_all" || names == "*" {
+		repos, err := core.ListSnapshotRepositories()
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		for _, repo := range repos {
+			resp[repo.Name] = repo
+		}
+		zutils.GinRenderJSON(c, http.StatusOK, resp)
+		return
+	}
+	for _, name := range strings.Split(names, ",") {
+		repo, err := core.GetSnapshotRepository(name)
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		resp[name] = repo
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// @Id DeleteRepository
+// @Summary Unregister snapshot repository for compatible ES, the snapshots are kept
+// @security BasicAuth
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository} [delete]
+func DeleteRepository(c *gin.Context) {
+	if err := core.DeleteSnapshotRepository(c.Param("repository")); err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id CreateSnapshot
+// @Summary Create snapshot for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository           path   string                true   "Repository"
+// @Param   snapshot             path   string                true   "Snapshot"
+// @Param   wait_for_completion  query  bool                  false  "Wait for the snapshot to finish"
+// @Param   data                 body   meta.SnapshotRequest  false  "Snapshot options"
+// @Success 200 {object} CreateSnapshotResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [put]
+func CreateSnapshot(c *gin.Context) {
+	req := new(meta.SnapshotRequest)
+	if c.Request.ContentLength != 0 {
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+	wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "false"))
+	snap, err := core.CreateSnapshot(c.Param("repository"), c.Param("snapshot"), req, wait, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	if !wait {
+		zutils.GinRenderJSON(c, http.StatusOK, CreateSnapshotResponse{Accepted: true})
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, CreateSnapshotResponse{Snapshot: snap})
+}
+
+// @Id GetSnapshot
+// @Summary Get snapshots for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Param   snapshot    path  string  true  "Snapshot, comma separated, _all or * for all"
+// @Success 200 {object} GetSnapshotResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [get]
+func GetSnapshot(c *gin.Context) {
+	cfg := config.GetConfig(c)
+	repoName := c.Param("repository")
+	names := c.Param("snapshot")
+	resp := GetSnapshotResponse{Snapshots: make([]*meta.Snapshot, 0)}
+	if names == "_all" || names == "*" {
+		snaps, err := core.ListSnapshots(repoName, cfg)
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		resp.Snapshots = append(resp.Snapshots, snaps...)
+		resp.Total = len(snaps)
+		zutils.GinRenderJSON(c, http.StatusOK, resp)
+		return
+	}
+	for _, name := range strings.Split(names, ",") {
+		snap, err := core.GetSnapshot(repoName, name, cfg)
+		if err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+		resp.Snapshots = append(resp.Snapshots, snap)
+	}
+	resp.Total = len(resp.Snapshots)
+	zutils.GinRenderJSON(c, http.StatusOK, resp)
+}
+
+// @Id DeleteSnapshot
+// @Summary Delete snapshot for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Produce json
+// @Param   repository  path  string  true  "Repository"
+// @Param   snapshot    path  string  true  "Snapshot, comma separated"
+// @Success 200 {object} AcknowledgedResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot} [delete]
+func DeleteSnapshot(c *gin.Context) {
+	cfg := config.GetConfig(c)
+	for _, name := range strings.Split(c.Param("snapshot"), ",") {
+		if err := core.DeleteSnapshot(c.Param("repository"), name, cfg); err != nil {
+			errors.HandleError(c, err)
+			return
+		}
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, AcknowledgedResponse{Acknowledged: true})
+}
+
+// @Id RestoreSnapshot
+// @Summary Restore snapshot for compatible ES
+// @security BasicAuth
+// @Tags    Snapshot
+// @Accept  json
+// @Produce json
+// @Param   repository  path  string               true   "Repository"
+// @Param   snapshot    path  string               true   "Snapshot"
+// @Param   data        body  meta.RestoreRequest  false  "Restore options"
+// @Success 200 {object} RestoreSnapshotResponse
+// @Failure 400 {object} meta.HTTPResponseError
+// @Router /es/_snapshot/{repository}/{snapshot}/_restore [post]
+func RestoreSnapshot(c *gin.Context) {
+	req := new(meta.RestoreRequest)
+	if c.Request.ContentLength != 0 {
+		if err := zutils.GinBindJSON(c, req); err != nil {
+			zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: err.Error()})
+			return
+		}
+	}
+	info, err := core.RestoreSnapshot(c.Param("repository"), c.Param("snapshot"), req, config.GetConfig(c))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, RestoreSnapshotResponse{Snapshot: info})
+}
+
+type AcknowledgedResponse struct {
+	Acknowledged bool `json:"acknowledged"`
+}
+
+type CreateSnapshotResponse struct {
+	Accepted bool           `json:"accepted,omitempty"`
+	Snapshot *meta.Snapshot `json:"snapshot,omitempty"`
+}
+
+type GetSnapshotResponse struct {
+	Snapshots []*meta.Snapshot `json:"snapshots"`
+	Total     int              `json:"total"`
+}
+
+type RestoreSnapshotResponse struct {
+	Snapshot *meta.RestoreInfo `json:"snapshot"`
+}
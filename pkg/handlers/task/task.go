@@ -0,0 +1,121 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package task
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// DefaultWaitTimeout is how long getting a task waits for its completion by default
+const DefaultWaitTimeout = 30 * time.Second
+
+// @Id ListTasks
+// @Summary List the running tasks
+// @security BasicAuth
+// @Tags    Task
+// @Produce json
+// @Param   actions  query  string  false  "Actions, comma separated, wildcards supported, eg: *reindex"
+// @Success 200 {object} meta.TaskListResponse
+// @Router /es/_tasks [get]
+func ListTasks(c *gin.Context) {
+	zutils.GinRenderJSON(c, http.StatusOK, newTaskListResponse(core.ZINC_TASK_LIST.List(c.Query("actions"))))
+}
+
+// @Id GetTask
+// @Summary Get the progress of a task, or its result if it is completed
+// @security BasicAuth
+// @Tags    Task
+// @Produce json
+// @Param   task_id              path   string  true   "Task ID"
+// @Param   wait_for_completion  query  bool    false  "Wait for the task to finish"
+// @Param   timeout              query  string  false  "Max time to wait, default 30s"
+// @Success 200 {object} meta.TaskResult
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_tasks/{task_id} [get]
+func GetTask(c *gin.Context) {
+	id := c.Param("task_id")
+	if wait, _ := strconv.ParseBool(c.DefaultQuery("wait_for_completion", "false")); wait {
+		timeout := DefaultWaitTimeout
+		if v := c.Query("timeout"); v != "" {
+			var err error
+			if timeout, err = zutils.ParseDuration(v); err != nil || timeout <= 0 {
+				zutils.GinRenderJSON(c, http.StatusBadRequest, meta.HTTPResponseError{Error: "timeout [" + v + "] is invalid"})
+				return
+			}
+		}
+		if task, ok := core.ZINC_TASK_LIST.Get(id); ok {
+			if !task.Wait(timeout) {
+				errors.HandleError(c, errors.New(errors.ErrorTypeRuntimeException, "timed out waiting for completion of task ["+id+"]"))
+				return
+			}
+			zutils.GinRenderJSON(c, http.StatusOK, task.TaskResult())
+			return
+		}
+	}
+	result, err := core.ZINC_TASK_LIST.GetResult(id)
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, result)
+}
+
+// @Id CancelTask
+// @Summary Cancel a running task, it stops at its next batch
+// @security BasicAuth
+// @Tags    Task
+// @Produce json
+// @Param   task_id  path  string  true  "Task ID"
+// @Success 200 {object} meta.TaskListResponse
+// @Failure 404 {object} meta.HTTPResponseError
+// @Router /es/_tasks/{task_id}/_cancel [post]
+func CancelTask(c *gin.Context) {
+	info, err := core.ZINC_TASK_LIST.Cancel(c.Param("task_id"))
+	if err != nil {
+		errors.HandleError(c, err)
+		return
+	}
+	zutils.GinRenderJSON(c, http.StatusOK, newTaskListResponse([]*meta.TaskInfo{info}))
+}
+
+// @Id CancelTasks
+// @Summary Cancel the running tasks matching the actions
+// @security BasicAuth
+// @Tags    Task
+// @Produce json
+// @Param   actions  query  string  false  "Actions, comma separated, wildcards supported, default all"
+// @Success 200 {object} meta.TaskListResponse
+// @Router /es/_tasks/_cancel [post]
+func CancelTasks(c *gin.Context) {
+	zutils.GinRenderJSON(c, http.StatusOK, newTaskListResponse(core.ZINC_TASK_LIST.CancelAll(c.Query("actions"))))
+}
+
+func newTaskListResponse(infos []*meta.TaskInfo) meta.TaskListResponse {
+	resp := meta.TaskListResponse{Tasks: make(map[string]*meta.TaskInfo, len(infos))}
+	for _, info := range infos {
+		resp.Tasks[info.ID] = info
+	}
+	return resp
+}
@@ -0,0 +1,113 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package task
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/config"
+	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/metadata"
+	"github.com/zinclabs/zincsearch/test/utils"
+)
+
+func TestTask(t *testing.T) {
+	cfg := config.NewEnvFileGlobalConfig([]string{"../../../.env"})
+	metadata.NewStorager(cfg)
+
+	task := core.ZINC_TASK_LIST.Start("indices:data/write/test", "test task", func(task *core.Task) (interface{}, error) {
+		task.SetStatus(meta.TaskStatus{Total: 10, Created: 1})
+		<-task.Context().Done()
+		return map[string]string{"result": "stopped"}, nil
+	})
+
+	t.Run("list", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/_tasks", map[string]string{"actions": "*test"})
+		ListTasks(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"`+task.ID()+`":{`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/_tasks", map[string]string{"actions": "*reindex"})
+		ListTasks(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"tasks":{}}`, w.Body.String())
+	})
+
+	t.Run("get running", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": task.ID()})
+		utils.SetGinRequestURL(c, "/es/_tasks/"+task.ID(), map[string]string{"wait_for_completion": "true", "timeout": "100ms"})
+		GetTask(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `timed out waiting for completion`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": task.ID()})
+		GetTask(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"completed":false`)
+		assert.Contains(t, w.Body.String(), `"description":"test task"`)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": task.ID()})
+		CancelTask(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"cancelled":true`)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": task.ID()})
+		utils.SetGinRequestURL(c, "/es/_tasks/"+task.ID(), map[string]string{"wait_for_completion": "true"})
+		GetTask(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"completed":true`)
+		assert.Contains(t, w.Body.String(), `"response":{"result":"stopped"}`)
+	})
+
+	t.Run("cancel by actions", func(t *testing.T) {
+		other := core.ZINC_TASK_LIST.Start("indices:data/write/other", "other task", func(task *core.Task) (interface{}, error) {
+			<-task.Context().Done()
+			return nil, nil
+		})
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestURL(c, "/es/_tasks/_cancel", map[string]string{"actions": "*other"})
+		CancelTasks(c)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"`+other.ID()+`":{`)
+		assert.True(t, other.Wait(DefaultWaitTimeout))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		c, w := utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": "missing:1"})
+		GetTask(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		c, w = utils.NewGinContext()
+		utils.SetGinRequestParams(c, map[string]string{"task_id": "missing:1"})
+		CancelTask(c)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	core.ZINC_TASK_LIST.Delete(task.ID())
+}
@@ -0,0 +1,274 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// Condition decides whether a processor runs, it is a plain object instead of a script:
+//
+//	{"field": "level", "equals": "debug"}
+//	{"field": "status", "in": [500, 503]}
+//	{"field": "user.name", "exists": true}
+//	{"field": "bytes", "gte": 1024, "lt": 4096}
+//	{"field": "path", "regex": "^/api/"}
+//	{"field": "tags", "contains": "beta"}
+//	{"all": [...]}, {"any": [...]}, {"not": {...}}
+//
+// All the predicates of one condition must match.
+type Condition struct {
+	field     string
+	exists    *bool
+	equals    interface{}
+	hasEquals bool
+	in        []interface{}
+	contains  interface{}
+	hasCont   bool
+	regex     *regexp.Regexp
+	ranges    []rangePredicate
+	all       []*Condition
+	any       []*Condition
+	not       *Condition
+}
+
+type rangePredicate struct {
+	op    string
+	value interface{}
+}
+
+// NewCondition parses the condition
+func NewCondition(v interface{}) (*Condition, error) {
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("condition should be an object")
+	}
+	c := new(Condition)
+	var err error
+	for k, v := range data {
+		switch strings.ToLower(k) {
+		case "field":
+			if c.field, ok = v.(string); !ok || c.field == "" {
+				return nil, fmt.Errorf("[field] should be a non empty string")
+			}
+		case "exists":
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("[exists] should be a boolean")
+			}
+			c.exists = &b
+		case "equals":
+			c.equals, c.hasEquals = v, true
+		case "in":
+			if c.in, ok = v.([]interface{}); !ok {
+				return nil, fmt.Errorf("[in] should be an array")
+			}
+		case "contains":
+			c.contains, c.hasCont = v, true
+		case "regex":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("[regex] should be a string")
+			}
+			if c.regex, err = regexp.Compile(s); err != nil {
+				return nil, fmt.Errorf("[regex] %s", err.Error())
+			}
+		case "gt", "gte", "lt", "lte":
+			switch v.(type) {
+			case float64, string:
+			default:
+				return nil, fmt.Errorf("[%s] should be a number or a string", k)
+			}
+			c.ranges = append(c.ranges, rangePredicate{op: strings.ToLower(k), value: v})
+		case "all", "any":
+			vs, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("[%s] should be an array of conditions", k)
+			}
+			for _, v := range vs {
+				sub, err := NewCondition(v)
+				if err != nil {
+					return nil, err
+				}
+				if k == "all" {
+					c.all = append(c.all, sub)
+				} else {
+					c.any = append(c.any, sub)
+				}
+			}
+		case "not":
+			if c.not, err = NewCondition(v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unknown field [%s]", k)
+		}
+	}
+
+	hasPredicate := c.exists != nil || c.hasEquals || c.in != nil || c.hasCont || c.regex != nil || len(c.ranges) > 0
+	if hasPredicate && c.field == "" {
+		return nil, fmt.Errorf("[field] is required")
+	}
+	if c.field != "" && !hasPredicate {
+		return nil, fmt.Errorf("[%s] needs one of [exists, equals, in, contains, regex, gt, gte, lt, lte]", c.field)
+	}
+	if !hasPredicate && c.all == nil && c.any == nil && c.not == nil {
+		return nil, fmt.Errorf("condition is empty")
+	}
+	return c, nil
+}
+
+// Match returns true if the document matches all the predicates
+func (c *Condition) Match(doc *Document) bool {
+	if c.field != "" && !c.matchField(doc) {
+		return false
+	}
+	for _, sub := range c.all {
+		if !sub.Match(doc) {
+			return false
+		}
+	}
+	if len(c.any) > 0 {
+		matched := false
+		for _, sub := range c.any {
+			if sub.Match(doc) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.not != nil && c.not.Match(doc) {
+		return false
+	}
+	return true
+}
+
+func (c *Condition) matchField(doc *Document) bool {
+	v, ok := doc.Get(c.field)
+	ok = ok && v != nil
+	if c.exists != nil && *c.exists != ok {
+		return false
+	}
+	if !ok {
+		// only exists: false matches a missing field
+		return c.exists != nil && !c.hasEquals && c.in == nil && !c.hasCont && c.regex == nil && len(c.ranges) == 0
+	}
+	if c.hasEquals && !equalValues(v, c.equals) {
+		return false
+	}
+	if c.in != nil {
+		found := false
+		for _, want := range c.in {
+			if equalValues(v, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if c.hasCont && !containsValue(v, c.contains) {
+		return false
+	}
+	if c.regex != nil {
+		s, ok := v.(string)
+		if !ok || !c.regex.MatchString(s) {
+			return false
+		}
+	}
+	for _, r := range c.ranges {
+		if !r.match(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r rangePredicate) match(v interface{}) bool {
+	var cmp int
+	switch want := r.value.(type) {
+	case float64:
+		f, err := zutils.ToFloat64(v)
+		if err != nil {
+			return false
+		}
+		switch {
+		case f < want:
+			cmp = -1
+		case f > want:
+			cmp = 1
+		}
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(s, want)
+	}
+	switch r.op {
+	case "gt":
+		return cmp > 0
+	case "gte":
+		return cmp >= 0
+	case "lt":
+		return cmp < 0
+	default:
+		return cmp <= 0
+	}
+}
+
+// containsValue checks the element of an array or the substring of a string
+func containsValue(v, want interface{}) bool {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, e := range v {
+			if equalValues(e, want) {
+				return true
+			}
+		}
+	case string:
+		if s, ok := want.(string); ok {
+			return strings.Contains(v, s)
+		}
+	}
+	return false
+}
+
+func equalValues(a, b interface{}) bool {
+	if isNumber(a) && isNumber(b) {
+		fa, _ := zutils.ToFloat64(a)
+		fb, _ := zutils.ToFloat64(b)
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32, int, int64, int32, uint64:
+		return true
+	}
+	return false
+}
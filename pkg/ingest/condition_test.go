@@ -0,0 +1,73 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+func TestCondition(t *testing.T) {
+	source := map[string]interface{}{
+		"level": "error",
+		"code":  float64(503),
+		"tags":  []interface{}{"a", "b"},
+		"user":  map[string]interface{}{"name": "zinc"},
+		"path":  "/api/search",
+	}
+	doc := NewDocument("index1", "1", source)
+	tests := []struct {
+		cond    string
+		want    bool
+		wantErr bool
+	}{
+		{cond: `{"field":"level","equals":"error"}`, want: true},
+		{cond: `{"field":"level","equals":"info"}`, want: false},
+		{cond: `{"field":"code","in":[500,503]}`, want: true},
+		{cond: `{"field":"code","gte":500,"lt":600}`, want: true},
+		{cond: `{"field":"code","gt":503}`, want: false},
+		{cond: `{"field":"user.name","exists":true}`, want: true},
+		{cond: `{"field":"user.age","exists":false}`, want: true},
+		{cond: `{"field":"user.age","equals":1}`, want: false},
+		{cond: `{"field":"tags","contains":"b"}`, want: true},
+		{cond: `{"field":"path","contains":"search"}`, want: true},
+		{cond: `{"field":"path","regex":"^/api/"}`, want: true},
+		{cond: `{"all":[{"field":"level","equals":"error"},{"field":"code","equals":503}]}`, want: true},
+		{cond: `{"any":[{"field":"level","equals":"info"},{"field":"code","equals":404}]}`, want: false},
+		{cond: `{"not":{"field":"level","equals":"info"}}`, want: true},
+		{cond: `{}`, wantErr: true},
+		{cond: `{"field":"level"}`, wantErr: true},
+		{cond: `{"equals":"x"}`, wantErr: true},
+		{cond: `{"field":"level","regex":"("}`, wantErr: true},
+		{cond: `{"field":"level","foo":1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cond, func(t *testing.T) {
+			var v interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tt.cond), &v))
+			c, err := NewCondition(v)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, c.Match(doc))
+		})
+	}
+}
@@ -0,0 +1,153 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// convertProcessor converts the value of the field to another type, the elements of an array are converted one by one
+type convertProcessor struct {
+	fieldOptions
+	typ string
+}
+
+func newConvertProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(convertProcessor)
+	for k, v := range options {
+		ok, err := p.parse("convert", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		if k != "type" {
+			return nil, unknownOption("convert", k)
+		}
+		if p.typ, err = stringOption("convert", k, v); err != nil {
+			return nil, err
+		}
+	}
+	switch p.typ {
+	case "integer", "long", "float", "double", "string", "boolean", "ip", "auto":
+	case "":
+		return nil, requiredOption("convert", "type")
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[convert] type [%s] not supported, cannot convert field", p.typ))
+	}
+	if err := p.check("convert"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *convertProcessor) Process(doc *Document) error {
+	v, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	if vs, ok := v.([]interface{}); ok {
+		rv := make([]interface{}, len(vs))
+		for i := range vs {
+			if rv[i], err = p.convert(vs[i]); err != nil {
+				return err
+			}
+		}
+		return doc.Set(p.targetField, rv)
+	}
+	if v, err = p.convert(v); err != nil {
+		return err
+	}
+	return doc.Set(p.targetField, v)
+}
+
+func (p *convertProcessor) convert(v interface{}) (interface{}, error) {
+	switch p.typ {
+	case "integer", "long":
+		switch v := v.(type) {
+		case float64:
+			if v != math.Trunc(v) {
+				return nil, fmt.Errorf("unable to convert [%v] to %s", v, p.typ)
+			}
+			return int64(v), nil
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 0, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to convert [%s] to %s", v, p.typ)
+			}
+			return n, nil
+		}
+	case "float", "double":
+		switch v := v.(type) {
+		case float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, fmt.Errorf("unable to convert [%s] to %s", v, p.typ)
+			}
+			return f, nil
+		}
+	case "string":
+		return zutils.ToString(v)
+	case "boolean":
+		switch v := v.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(v) {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+			return nil, fmt.Errorf("[%s] is not a boolean value, cannot convert to boolean", v)
+		}
+	case "ip":
+		if s, ok := v.(string); ok {
+			if _, err := zutils.ParseIP(s); err != nil {
+				return nil, fmt.Errorf("[%s] is not a valid ip address", s)
+			}
+			return s, nil
+		}
+	case "auto":
+		s, ok := v.(string)
+		if !ok {
+			return v, nil
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		switch strings.ToLower(s) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unable to convert [%v] of type [%T] to %s", v, v, p.typ)
+}
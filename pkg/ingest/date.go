@@ -0,0 +1,124 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// dateProcessor parses the date in the field and writes it to the target_field, @timestamp by default.
+// The formats are tried in order, they are Go layouts or one of ISO8601, UNIX, UNIX_MS.
+type dateProcessor struct {
+	fieldOptions
+	formats      []string
+	timezone     *time.Location
+	outputFormat string
+}
+
+func newDateProcessor(options map[string]interface{}) (Processor, error) {
+	p := &dateProcessor{timezone: time.UTC, outputFormat: "2006-01-02T15:04:05.000Z07:00"}
+	for k, v := range options {
+		ok, err := p.parse("date", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		switch k {
+		case "formats":
+			p.formats, err = stringsOption("date", k, v)
+		case "timezone":
+			var tz string
+			if tz, err = stringOption("date", k, v); err == nil {
+				if p.timezone, err = zutils.ParseTimeZone(tz); err != nil {
+					err = errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[date] invalid timezone [%s]", tz))
+				}
+			}
+		case "output_format":
+			p.outputFormat, err = stringOption("date", k, v)
+		default:
+			return nil, unknownOption("date", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.formats) == 0 {
+		return nil, requiredOption("date", "formats")
+	}
+	if p.targetField == "" {
+		p.targetField = meta.TimeFieldName
+	}
+	if err := p.check("date"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *dateProcessor) Process(doc *Document) error {
+	v, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	for _, format := range p.formats {
+		if t, err := p.parseTime(v, format); err == nil {
+			return doc.Set(p.targetField, t.In(p.timezone).Format(p.outputFormat))
+		}
+	}
+	return fmt.Errorf("unable to parse date [%v] with formats %v", v, p.formats)
+}
+
+func (p *dateProcessor) parseTime(v interface{}, format string) (time.Time, error) {
+	switch format {
+	case "UNIX", "UNIX_MS", "epoch_millis":
+		f, err := zutils.ToFloat64(v)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if format == "UNIX" {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)), nil
+		}
+		return time.UnixMilli(int64(f)), nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("value [%v] is not a string", v)
+	}
+	s = strings.TrimSpace(s)
+	if format == "ISO8601" {
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.ParseInLocation(layout, s, p.timezone); err == nil {
+				return t, nil
+			}
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.UnixMilli(n), nil
+		}
+		return time.Time{}, fmt.Errorf("value [%s] is not ISO8601", s)
+	}
+	return time.ParseInLocation(format, s, p.timezone)
+}
@@ -0,0 +1,210 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+)
+
+// dissectProcessor splits a string field by the delimiters of the pattern, it is much cheaper than grok.
+//
+//	%{key}      the value between the delimiters
+//	%{} %{?key} skip the value
+//	%{+key}     append the value to the key, %{+key/2} appends in the order 2
+//	%{*k} %{&k} the value of *k is the field name of the value of &k
+//	%{key->}    skip the repeated delimiters after the value
+type dissectProcessor struct {
+	fieldOptions
+	prefix          string
+	keys            []dissectKey
+	appendSeparator string
+}
+
+type dissectKey struct {
+	name      string
+	modifier  byte // '?', '+', '*', '&' or 0
+	order     int
+	padding   bool
+	delimiter string // the delimiter after the value, empty for the last key
+}
+
+var dissectKeyRe = regexp.MustCompile(`%\{([^}]*)\}`)
+
+func newDissectProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(dissectProcessor)
+	var pattern string
+	for k, v := range options {
+		ok, err := p.parse("dissect", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		switch k {
+		case "pattern":
+			pattern, err = stringOption("dissect", k, v)
+		case "append_separator":
+			p.appendSeparator, err = stringOption("dissect", k, v)
+		default:
+			return nil, unknownOption("dissect", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if pattern == "" {
+		return nil, requiredOption("dissect", "pattern")
+	}
+	if p.targetField != "" {
+		return nil, unknownOption("dissect", "target_field")
+	}
+	if err := p.check("dissect"); err != nil {
+		return nil, err
+	}
+	if err := p.compile(pattern); err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[dissect] invalid pattern [%s]: %s", pattern, err.Error()))
+	}
+	return p, nil
+}
+
+func (p *dissectProcessor) compile(pattern string) error {
+	locs := dissectKeyRe.FindAllStringSubmatchIndex(pattern, -1)
+	if len(locs) == 0 {
+		return fmt.Errorf("unable to find any keys")
+	}
+	p.prefix = pattern[:locs[0][0]]
+	for i, loc := range locs {
+		key := dissectKey{}
+		name := pattern[loc[2]:loc[3]]
+		if strings.HasSuffix(name, "->") {
+			key.padding = true
+			name = strings.TrimSuffix(name, "->")
+		}
+		if name != "" {
+			switch name[0] {
+			case '?', '+', '*', '&':
+				key.modifier = name[0]
+				name = name[1:]
+			}
+		}
+		if key.modifier == '+' {
+			if j := strings.LastIndexByte(name, '/'); j > 0 {
+				n, err := strconv.Atoi(name[j+1:])
+				if err != nil {
+					return fmt.Errorf("invalid append order of key [%s]", name)
+				}
+				key.order = n
+				name = name[:j]
+			}
+		}
+		if name == "" && key.modifier != 0 && key.modifier != '?' {
+			return fmt.Errorf("the key with modifier [%c] needs a name", key.modifier)
+		}
+		key.name = name
+		if i+1 < len(locs) {
+			key.delimiter = pattern[loc[1]:locs[i+1][0]]
+			if key.delimiter == "" {
+				return fmt.Errorf("the keys need a delimiter between them")
+			}
+		} else {
+			key.delimiter = pattern[loc[1]:]
+		}
+		p.keys = append(p.keys, key)
+	}
+	return nil
+}
+
+func (p *dissectProcessor) Process(doc *Document) error {
+	s, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	fail := fmt.Errorf("unable to find match for dissect pattern against source [%s]", s)
+	if !strings.HasPrefix(s, p.prefix) {
+		return fail
+	}
+	pos := len(p.prefix)
+	values := make([]string, len(p.keys))
+	for i, key := range p.keys {
+		if key.delimiter == "" {
+			values[i] = s[pos:]
+			pos = len(s)
+			break
+		}
+		j := strings.Index(s[pos:], key.delimiter)
+		if j < 0 {
+			return fail
+		}
+		values[i] = s[pos : pos+j]
+		pos += j + len(key.delimiter)
+		if key.padding {
+			for strings.HasPrefix(s[pos:], key.delimiter) {
+				pos += len(key.delimiter)
+			}
+		}
+	}
+
+	type appendValue struct {
+		order int
+		value string
+	}
+	var fields []string
+	appends := make(map[string][]appendValue)
+	refNames := make(map[string]string)
+	refValues := make(map[string]string)
+	for i, key := range p.keys {
+		switch {
+		case key.name == "" || key.modifier == '?':
+		case key.modifier == '*':
+			refNames[key.name] = values[i]
+		case key.modifier == '&':
+			refValues[key.name] = values[i]
+		default:
+			if _, ok := appends[key.name]; !ok {
+				fields = append(fields, key.name)
+			}
+			if key.modifier == '+' {
+				appends[key.name] = append(appends[key.name], appendValue{order: key.order, value: values[i]})
+			} else {
+				appends[key.name] = []appendValue{{value: values[i]}}
+			}
+		}
+	}
+	for _, name := range fields {
+		vs := appends[name]
+		sort.SliceStable(vs, func(i, j int) bool { return vs[i].order < vs[j].order })
+		parts := make([]string, len(vs))
+		for i := range vs {
+			parts[i] = vs[i].value
+		}
+		if err := doc.Set(name, strings.Join(parts, p.appendSeparator)); err != nil {
+			return err
+		}
+	}
+	for ref, name := range refNames {
+		if err := doc.Set(name, refValues[ref]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDissectProcessor(t *testing.T) {
+	tests := []struct {
+		name      string
+		pattern   string
+		separator string
+		message   string
+		want      map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			name:    "keys",
+			pattern: "[%{ts}] %{level} %{msg}",
+			message: "[2022-10-01] INFO service started",
+			want:    map[string]interface{}{"ts": "2022-10-01", "level": "INFO", "msg": "service started"},
+		},
+		{
+			name:    "skip and padding",
+			pattern: "%{a->} %{?skip} %{} %{b}",
+			message: "x     y z w",
+			want:    map[string]interface{}{"a": "x", "b": "w"},
+		},
+		{
+			name:      "append",
+			pattern:   "%{+name/2} %{+name/1} %{age}",
+			separator: " ",
+			message:   "smith john 42",
+			want:      map[string]interface{}{"name": "john smith", "age": "42"},
+		},
+		{
+			name:    "reference",
+			pattern: "%{*key}=%{&key} %{other.field}",
+			message: "color=red rest",
+			want:    map[string]interface{}{"color": "red", "other": map[string]interface{}{"field": "rest"}},
+		},
+		{
+			name:    "no match",
+			pattern: "%{a}|%{b}",
+			message: "a,b",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newDissectProcessor(map[string]interface{}{"field": "message", "pattern": tt.pattern, "append_separator": tt.separator})
+			assert.NoError(t, err)
+			doc := NewDocument("index1", "1", map[string]interface{}{"message": tt.message})
+			err = p.Process(doc)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tt.want["message"] = tt.message
+			assert.Equal(t, tt.want, doc.Source)
+		})
+	}
+
+	_, err := newDissectProcessor(map[string]interface{}{"field": "message", "pattern": "%{a}%{b}"})
+	assert.Error(t, err)
+	_, err = newDissectProcessor(map[string]interface{}{"field": "message", "pattern": "no keys"})
+	assert.Error(t, err)
+}
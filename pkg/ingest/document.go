@@ -0,0 +1,204 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// Document is a document running through a pipeline, besides the source
+// the processors can access the metadata fields _index, _id and _ingest.
+type Document struct {
+	Index   string
+	ID      string
+	Source  map[string]interface{}
+	ingest  map[string]interface{}
+	dropped bool
+}
+
+func NewDocument(index, id string, source map[string]interface{}) *Document {
+	if source == nil {
+		source = make(map[string]interface{})
+	}
+	return &Document{
+		Index:  index,
+		ID:     id,
+		Source: source,
+		ingest: map[string]interface{}{"timestamp": time.Now().UTC().Format(time.RFC3339Nano)},
+	}
+}
+
+// Dropped returns true if a drop processor dropped the document
+func (d *Document) Dropped() bool {
+	return d.dropped
+}
+
+// Ingest returns the _ingest metadata
+func (d *Document) Ingest() map[string]interface{} {
+	return d.ingest
+}
+
+// Get returns the value of the field, the path of an object field is joined with dots
+func (d *Document) Get(field string) (interface{}, bool) {
+	switch field {
+	case "_index":
+		return d.Index, true
+	case "_id":
+		return d.ID, true
+	}
+	if path, ok := cutPrefix(field, "_ingest."); ok {
+		return getPath(d.ingest, path)
+	}
+	if path, ok := cutPrefix(field, "_source."); ok {
+		field = path
+	}
+	return getPath(d.Source, field)
+}
+
+// Set sets the value of the field, the missing objects in the path are created
+func (d *Document) Set(field string, value interface{}) error {
+	switch field {
+	case "_index", "_id":
+		s, err := zutils.ToString(value)
+		if err != nil || s == "" {
+			return fmt.Errorf("field [%s] should be a non empty string", field)
+		}
+		if field == "_index" {
+			d.Index = s
+		} else {
+			d.ID = s
+		}
+		return nil
+	}
+	if path, ok := cutPrefix(field, "_ingest."); ok {
+		return setPath(d.ingest, path, value)
+	}
+	if path, ok := cutPrefix(field, "_source."); ok {
+		field = path
+	}
+	return setPath(d.Source, field, value)
+}
+
+// Remove removes the field, returns false if it doesn't exist
+func (d *Document) Remove(field string) bool {
+	if path, ok := cutPrefix(field, "_ingest."); ok {
+		return removePath(d.ingest, path)
+	}
+	if path, ok := cutPrefix(field, "_source."); ok {
+		field = path
+	}
+	return removePath(d.Source, field)
+}
+
+func cutPrefix(s, prefix string) (string, bool) {
+	if strings.HasPrefix(s, prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+// getPath finds the field in the nested objects, a key contains dots is matched as a whole first
+func getPath(m map[string]interface{}, path string) (interface{}, bool) {
+	if v, ok := m[path]; ok {
+		return v, true
+	}
+	for i := strings.IndexByte(path, '.'); i > 0; i = nextDot(path, i) {
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if v, ok := getPath(sub, path[i+1:]); ok {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func setPath(m map[string]interface{}, path string, value interface{}) error {
+	if path == "" {
+		return fmt.Errorf("field name is empty")
+	}
+	if _, ok := m[path]; ok {
+		m[path] = value
+		return nil
+	}
+	for i := strings.IndexByte(path, '.'); i > 0; i = nextDot(path, i) {
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if _, ok := getPath(sub, path[i+1:]); ok {
+				return setPath(sub, path[i+1:], value)
+			}
+		}
+	}
+	i := strings.IndexByte(path, '.')
+	if i <= 0 {
+		m[path] = value
+		return nil
+	}
+	switch sub := m[path[:i]].(type) {
+	case map[string]interface{}:
+		return setPath(sub, path[i+1:], value)
+	case nil:
+		obj := make(map[string]interface{})
+		m[path[:i]] = obj
+		return setPath(obj, path[i+1:], value)
+	default:
+		return fmt.Errorf("cannot set [%s], [%s] is not an object", path, path[:i])
+	}
+}
+
+func removePath(m map[string]interface{}, path string) bool {
+	if _, ok := m[path]; ok {
+		delete(m, path)
+		return true
+	}
+	for i := strings.IndexByte(path, '.'); i > 0; i = nextDot(path, i) {
+		if sub, ok := m[path[:i]].(map[string]interface{}); ok {
+			if removePath(sub, path[i+1:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func nextDot(path string, i int) int {
+	j := strings.IndexByte(path[i+1:], '.')
+	if j < 0 {
+		return -1
+	}
+	return i + 1 + j
+}
+
+var templateRe = regexp.MustCompile(`\{\{\{?\s*([^{}\s]+)\s*\}?\}\}`)
+
+// renderTemplate replaces {{field}} with the value of the field, eg: {{_ingest.timestamp}}
+func renderTemplate(doc *Document, s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return templateRe.ReplaceAllStringFunc(s, func(m string) string {
+		field := templateRe.FindStringSubmatch(m)[1]
+		v, ok := doc.Get(field)
+		if !ok || v == nil {
+			return ""
+		}
+		s, _ := zutils.ToString(v)
+		return s
+	})
+}
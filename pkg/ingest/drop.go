@@ -13,34 +13,19 @@
 * limitations under the License.
  */
 
-package elastic
+package ingest
 
-import (
-	"net/http"
+// dropProcessor drops the document, it is usually used with a condition
+type dropProcessor struct{}
 
-	"github.com/gin-gonic/gin"
-
-	"github.com/zinclabs/zincsearch/pkg/zutils"
-)
-
-func GetDataStream(c *gin.Context) {
-	target := c.Param("target")
-	zutils.GinRenderJSON(c, http.StatusOK, gin.H{
-		"data_streams": []gin.H{
-			{
-				"name": target,
-				"timestamp_field": gin.H{
-					"name": "@timestamp",
-				},
-			},
-		},
-	})
+func newDropProcessor(options map[string]interface{}) (Processor, error) {
+	for k := range options {
+		return nil, unknownOption("drop", k)
+	}
+	return dropProcessor{}, nil
 }
 
-func PutDataStream(c *gin.Context) {
-	target := c.Param("target")
-	zutils.GinRenderJSON(c, http.StatusOK, gin.H{
-		"name":    target,
-		"message": "ok",
-	})
+func (dropProcessor) Process(doc *Document) error {
+	doc.dropped = true
+	return nil
 }
@@ -0,0 +1,242 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+)
+
+// grokProcessor extracts the fields from a string field with the grok patterns,
+// %{NUMBER:bytes:int} captures the NUMBER pattern into the field bytes as an integer.
+type grokProcessor struct {
+	fieldOptions
+	patterns   []*grokPattern
+	traceMatch bool
+}
+
+type grokPattern struct {
+	re       *regexp.Regexp
+	captures []grokCapture // by the group index - 1
+}
+
+type grokCapture struct {
+	field string
+	typ   string
+}
+
+var grokSyntaxRe = regexp.MustCompile(`%\{(\w+)(?::([^:{}]+))?(?::(\w+))?\}`)
+
+func newGrokProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(grokProcessor)
+	var patterns []string
+	definitions := grokPatterns
+	for k, v := range options {
+		ok, err := p.parse("grok", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		switch k {
+		case "patterns":
+			if patterns, err = stringsOption("grok", k, v); err != nil {
+				return nil, err
+			}
+		case "pattern_definitions":
+			vm, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[grok] [pattern_definitions] should be an object")
+			}
+			definitions = make(map[string]string, len(grokPatterns)+len(vm))
+			for name, def := range grokPatterns {
+				definitions[name] = def
+			}
+			for name, def := range vm {
+				if definitions[name], ok = def.(string); !ok {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[grok] [pattern_definitions] [%s] should be a string", name))
+				}
+			}
+		case "trace_match":
+			if p.traceMatch, err = boolOption("grok", k, v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, unknownOption("grok", k)
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, requiredOption("grok", "patterns")
+	}
+	if p.targetField != "" {
+		return nil, unknownOption("grok", "target_field")
+	}
+	if err := p.check("grok"); err != nil {
+		return nil, err
+	}
+	for _, pattern := range patterns {
+		gp, err := compileGrok(pattern, definitions)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[grok] invalid pattern [%s]: %s", pattern, err.Error()))
+		}
+		p.patterns = append(p.patterns, gp)
+	}
+	return p, nil
+}
+
+// compileGrok expands the pattern into a regexp, the groups are named by their order
+// because the field names can contain the characters which a group name can't.
+func compileGrok(pattern string, definitions map[string]string) (*grokPattern, error) {
+	gp := new(grokPattern)
+	var expand func(pattern string, stack []string) (string, error)
+	expand = func(pattern string, stack []string) (string, error) {
+		var err error
+		expr := grokSyntaxRe.ReplaceAllStringFunc(pattern, func(m string) string {
+			if err != nil {
+				return ""
+			}
+			sub := grokSyntaxRe.FindStringSubmatch(m)
+			name, field, typ := sub[1], sub[2], sub[3]
+			def, ok := definitions[name]
+			if !ok {
+				err = fmt.Errorf("unable to find pattern [%s] in grok's pattern dictionary", name)
+				return ""
+			}
+			for _, s := range stack {
+				if s == name {
+					err = fmt.Errorf("circular reference in pattern [%s]", name)
+					return ""
+				}
+			}
+			switch typ {
+			case "", "int", "long", "float", "double", "boolean":
+			default:
+				err = fmt.Errorf("unsupported type [%s] of field [%s]", typ, field)
+				return ""
+			}
+			var group string
+			if field != "" {
+				gp.captures = append(gp.captures, grokCapture{field: field, typ: typ})
+				group = fmt.Sprintf("(?P<g%d>", len(gp.captures))
+			} else {
+				group = "(?:"
+			}
+			var inner string
+			if inner, err = expand(def, append(stack, name)); err != nil {
+				return ""
+			}
+			return group + inner + ")"
+		})
+		return expr, err
+	}
+
+	expr, err := expand(pattern, nil)
+	if err != nil {
+		return nil, err
+	}
+	if gp.re, err = regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+	return gp, nil
+}
+
+func (p *grokProcessor) Process(doc *Document) error {
+	s, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	for i, gp := range p.patterns {
+		fields, ok, err := gp.match(s)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if err := doc.Set(field.name, field.value); err != nil {
+				return err
+			}
+		}
+		if p.traceMatch {
+			doc.ingest["_grok_match_index"] = strconv.Itoa(i)
+		}
+		return nil
+	}
+	return fmt.Errorf("provided grok expressions do not match field value: [%s]", s)
+}
+
+type grokField struct {
+	name  string
+	value interface{}
+}
+
+func (gp *grokPattern) match(s string) ([]grokField, bool, error) {
+	loc := gp.re.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return nil, false, nil
+	}
+	names := gp.re.SubexpNames()
+	fields := make([]grokField, 0, len(gp.captures))
+	seen := make(map[string]int, len(gp.captures))
+	for i := 1; i < len(names); i++ {
+		if !strings.HasPrefix(names[i], "g") || loc[2*i] < 0 {
+			continue
+		}
+		n, _ := strconv.Atoi(names[i][1:])
+		capture := gp.captures[n-1]
+		value, err := capture.convert(s[loc[2*i]:loc[2*i+1]])
+		if err != nil {
+			return nil, false, err
+		}
+		// the same field in the alternatives, keeps the one matched
+		if j, ok := seen[capture.field]; ok {
+			if fields[j].value == "" {
+				fields[j].value = value
+			}
+			continue
+		}
+		seen[capture.field] = len(fields)
+		fields = append(fields, grokField{name: capture.field, value: value})
+	}
+	return fields, true, nil
+}
+
+func (c grokCapture) convert(s string) (interface{}, error) {
+	switch c.typ {
+	case "int", "long":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert [%s] of field [%s] to %s", s, c.field, c.typ)
+		}
+		return n, nil
+	case "float", "double":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unable to convert [%s] of field [%s] to %s", s, c.field, c.typ)
+		}
+		return f, nil
+	case "boolean":
+		return strings.EqualFold(s, "true"), nil
+	default:
+		return s, nil
+	}
+}
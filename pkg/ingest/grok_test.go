@@ -0,0 +1,102 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrokProcessor(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		message string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:    "typed fields",
+			options: map[string]interface{}{"patterns": []interface{}{"%{IP:client.ip} %{WORD:method} %{URIPATHPARAM:request} %{NUMBER:bytes:int} %{NUMBER:duration:float}"}},
+			message: "55.3.244.1 GET /index.html 15824 0.043",
+			want: map[string]interface{}{
+				"client":   map[string]interface{}{"ip": "55.3.244.1"},
+				"method":   "GET",
+				"request":  "/index.html",
+				"bytes":    int64(15824),
+				"duration": 0.043,
+			},
+		},
+		{
+			name:    "second pattern",
+			options: map[string]interface{}{"patterns": []interface{}{"%{INT:code:int} only", "%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level} %{GREEDYDATA:msg}"}},
+			message: "2022-10-01T08:00:00Z WARN disk is almost full",
+			want:    map[string]interface{}{"ts": "2022-10-01T08:00:00Z", "level": "WARN", "msg": "disk is almost full"},
+		},
+		{
+			name: "pattern definitions",
+			options: map[string]interface{}{
+				"patterns":            []interface{}{"%{USERID:user} logged in"},
+				"pattern_definitions": map[string]interface{}{"USERID": "u-%{INT}"},
+			},
+			message: "u-42 logged in",
+			want:    map[string]interface{}{"user": "u-42"},
+		},
+		{
+			name:    "apache log",
+			options: map[string]interface{}{"patterns": []interface{}{"%{COMMONAPACHELOG}"}},
+			message: `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`,
+			want: map[string]interface{}{
+				"clientip": "127.0.0.1", "ident": "-", "auth": "frank", "timestamp": "10/Oct/2000:13:55:36 -0700",
+				"verb": "GET", "request": "/apache_pb.gif", "httpversion": "1.0", "response": "200", "bytes": "2326",
+			},
+		},
+		{
+			name:    "no match",
+			options: map[string]interface{}{"patterns": []interface{}{"%{IP:ip}"}},
+			message: "not an ip",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options["field"] = "message"
+			p, err := newGrokProcessor(tt.options)
+			assert.NoError(t, err)
+			doc := NewDocument("index1", "1", map[string]interface{}{"message": tt.message})
+			err = p.Process(doc)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			tt.want["message"] = tt.message
+			assert.Equal(t, tt.want, doc.Source)
+		})
+	}
+}
+
+func TestGrokProcessor_InvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"%{NOTEXISTS:a}", "%{INT:a:date}", "%{A}", "("} {
+		_, err := newGrokProcessor(map[string]interface{}{
+			"field":               "message",
+			"patterns":            []interface{}{pattern},
+			"pattern_definitions": map[string]interface{}{"A": "%{B}", "B": "%{A}"},
+		})
+		assert.Error(t, err, pattern)
+	}
+}
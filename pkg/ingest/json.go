@@ -0,0 +1,77 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+// jsonProcessor parses a string field as json, the object can be merged into the root of the document
+type jsonProcessor struct {
+	fieldOptions
+	addToRoot bool
+}
+
+func newJSONProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(jsonProcessor)
+	for k, v := range options {
+		ok, err := p.parse("json", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		if k != "add_to_root" {
+			return nil, unknownOption("json", k)
+		}
+		if p.addToRoot, err = boolOption("json", k, v); err != nil {
+			return nil, err
+		}
+	}
+	if p.addToRoot && p.targetField != "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[json] cannot set a [target_field] while also setting [add_to_root] to true")
+	}
+	if err := p.check("json"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *jsonProcessor) Process(doc *Document) error {
+	s, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return fmt.Errorf("field [%s] is not a valid json: %s", p.field, err.Error())
+	}
+	if !p.addToRoot {
+		return doc.Set(p.targetField, v)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot add non-map fields to root of document")
+	}
+	for k, v := range m {
+		doc.Source[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// stringProcessor changes a string field, the strings of an array are changed one by one
+type stringProcessor struct {
+	fieldOptions
+	fn func(string) string
+}
+
+func newLowercaseProcessor(options map[string]interface{}) (Processor, error) {
+	return newStringProcessor("lowercase", options, strings.ToLower)
+}
+
+func newUppercaseProcessor(options map[string]interface{}) (Processor, error) {
+	return newStringProcessor("uppercase", options, strings.ToUpper)
+}
+
+func newTrimProcessor(options map[string]interface{}) (Processor, error) {
+	return newStringProcessor("trim", options, strings.TrimSpace)
+}
+
+func newStringProcessor(typ string, options map[string]interface{}, fn func(string) string) (Processor, error) {
+	p := &stringProcessor{fn: fn}
+	for k, v := range options {
+		ok, err := p.parse(typ, k, v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, unknownOption(typ, k)
+		}
+	}
+	if err := p.check(typ); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *stringProcessor) Process(doc *Document) error {
+	v, ok, err := p.value(doc)
+	if !ok {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		return doc.Set(p.targetField, p.fn(v))
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i := range v {
+			s, ok := v[i].(string)
+			if !ok {
+				return fmt.Errorf("field [%s] of type [%T] cannot be cast to string", p.field, v[i])
+			}
+			rv[i] = p.fn(s)
+		}
+		return doc.Set(p.targetField, rv)
+	default:
+		return fmt.Errorf("field [%s] of type [%T] cannot be cast to string", p.field, v)
+	}
+}
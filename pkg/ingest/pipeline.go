@@ -0,0 +1,315 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package ingest runs the ingest pipelines, a pipeline is a list of processors
+// which transform the documents before they are written to the index.
+package ingest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+)
+
+// Processor transforms the document
+type Processor interface {
+	Process(doc *Document) error
+}
+
+type processorFactory func(options map[string]interface{}) (Processor, error)
+
+var processorFactories map[string]processorFactory
+
+func init() {
+	processorFactories = map[string]processorFactory{
+		"set":       newSetProcessor,
+		"remove":    newRemoveProcessor,
+		"rename":    newRenameProcessor,
+		"convert":   newConvertProcessor,
+		"lowercase": newLowercaseProcessor,
+		"uppercase": newUppercaseProcessor,
+		"trim":      newTrimProcessor,
+		"grok":      newGrokProcessor,
+		"dissect":   newDissectProcessor,
+		"date":      newDateProcessor,
+		"split":     newSplitProcessor,
+		"json":      newJSONProcessor,
+		"drop":      newDropProcessor,
+	}
+}
+
+// Pipeline is a compiled ingest pipeline
+type Pipeline struct {
+	name       string
+	processors []*processor
+	onFailure  []*processor
+}
+
+// processor is a processor with the options shared by all the processors
+type processor struct {
+	Processor
+	typ           string
+	tag           string
+	cond          *Condition
+	ignoreFailure bool
+	onFailure     []*processor
+}
+
+// New compiles the pipeline
+func New(name string, pipeline *meta.Pipeline) (*Pipeline, error) {
+	var err error
+	p := &Pipeline{name: name}
+	if p.processors, err = newProcessors(pipeline.Processors); err != nil {
+		return nil, err
+	}
+	if p.onFailure, err = newProcessors(pipeline.OnFailure); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Run runs the processors in order, it stops when the document is dropped
+func (p *Pipeline) Run(doc *Document) error {
+	if err := runProcessors(p.processors, p.onFailure, doc); err != nil {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("pipeline [%s] %s", p.name, err.Error()))
+	}
+	return nil
+}
+
+func runProcessors(processors, onFailure []*processor, doc *Document) error {
+	for _, proc := range processors {
+		if err := proc.run(doc); err != nil {
+			if len(onFailure) == 0 {
+				return err
+			}
+			setFailure(doc, proc, err)
+			return runProcessors(onFailure, nil, doc)
+		}
+		if doc.dropped {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *processor) run(doc *Document) error {
+	if p.cond != nil && !p.cond.Match(doc) {
+		return nil
+	}
+	err := p.Process(doc)
+	if err == nil || p.ignoreFailure {
+		return nil
+	}
+	err = fmt.Errorf("processor [%s] %s", p.typ, err.Error())
+	if len(p.onFailure) == 0 {
+		return err
+	}
+	setFailure(doc, p, err)
+	return runProcessors(p.onFailure, nil, doc)
+}
+
+// setFailure records the failure in the _ingest metadata, the on_failure processors can read it
+func setFailure(doc *Document, p *processor, err error) {
+	doc.ingest["on_failure_message"] = err.Error()
+	doc.ingest["on_failure_processor_type"] = p.typ
+	doc.ingest["on_failure_processor_tag"] = p.tag
+}
+
+func newProcessors(data []map[string]interface{}) ([]*processor, error) {
+	processors := make([]*processor, 0, len(data))
+	for _, v := range data {
+		p, err := newProcessor(v)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, p)
+	}
+	return processors, nil
+}
+
+func newProcessor(data map[string]interface{}) (*processor, error) {
+	if len(data) != 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[processors] every processor should be an object with a single key")
+	}
+	p := new(processor)
+	var options map[string]interface{}
+	for k, v := range data {
+		p.typ = strings.ToLower(k)
+		var ok bool
+		if options, ok = v.(map[string]interface{}); !ok {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] processor should be an object", k))
+		}
+	}
+	factory, ok := processorFactories[p.typ]
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("no processor type exists with name [%s]", p.typ))
+	}
+
+	rest := make(map[string]interface{}, len(options))
+	var err error
+	for k, v := range options {
+		switch k {
+		case "tag":
+			if p.tag, err = stringOption(p.typ, k, v); err != nil {
+				return nil, err
+			}
+		case "description":
+			if _, err = stringOption(p.typ, k, v); err != nil {
+				return nil, err
+			}
+		case "if":
+			if p.cond, err = NewCondition(v); err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [if] %s", p.typ, err.Error()))
+			}
+		case "ignore_failure":
+			if p.ignoreFailure, err = boolOption(p.typ, k, v); err != nil {
+				return nil, err
+			}
+		case "on_failure":
+			vs, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [on_failure] should be an array of processors", p.typ))
+			}
+			for _, v := range vs {
+				vm, ok := v.(map[string]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [on_failure] should be an array of processors", p.typ))
+				}
+				sub, err := newProcessor(vm)
+				if err != nil {
+					return nil, err
+				}
+				p.onFailure = append(p.onFailure, sub)
+			}
+		default:
+			rest[k] = v
+		}
+	}
+	if p.Processor, err = factory(rest); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func unknownOption(typ, k string) error {
+	return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] unknown field [%s]", typ, k))
+}
+
+func requiredOption(typ, k string) error {
+	return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [%s] is required", typ, k))
+}
+
+func stringOption(typ, k string, v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [%s] should be a string", typ, k))
+	}
+	return s, nil
+}
+
+func boolOption(typ, k string, v interface{}) (bool, error) {
+	b, ok := v.(bool)
+	if !ok {
+		return false, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [%s] should be a boolean", typ, k))
+	}
+	return b, nil
+}
+
+// stringsOption accepts a string or an array of strings
+func stringsOption(typ, k string, v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, v := range v {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [%s] should be an array of strings", typ, k))
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] [%s] should be a string or an array of strings", typ, k))
+	}
+}
+
+// fieldOptions are the options of the processors which change a field in place or write it to the target_field
+type fieldOptions struct {
+	field         string
+	targetField   string
+	ignoreMissing bool
+}
+
+// parse parses the option if it is one of the field options
+func (o *fieldOptions) parse(typ, k string, v interface{}) (bool, error) {
+	var err error
+	switch k {
+	case "field":
+		o.field, err = stringOption(typ, k, v)
+	case "target_field":
+		o.targetField, err = stringOption(typ, k, v)
+	case "ignore_missing":
+		o.ignoreMissing, err = boolOption(typ, k, v)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func (o *fieldOptions) check(typ string) error {
+	if o.field == "" {
+		return requiredOption(typ, "field")
+	}
+	if o.targetField == "" {
+		o.targetField = o.field
+	}
+	return nil
+}
+
+// value returns the value of the field, the document is skipped if it returns false without error
+func (o *fieldOptions) value(doc *Document) (interface{}, bool, error) {
+	v, ok := doc.Get(o.field)
+	if !ok || v == nil {
+		if o.ignoreMissing {
+			return nil, false, nil
+		}
+		if !ok {
+			return nil, false, fmt.Errorf("field [%s] doesn't exist", o.field)
+		}
+		return nil, false, fmt.Errorf("field [%s] is null, cannot be processed", o.field)
+	}
+	return v, true, nil
+}
+
+// stringValue is the value of the field which should be a string
+func (o *fieldOptions) stringValue(doc *Document) (string, bool, error) {
+	v, ok, err := o.value(doc)
+	if !ok {
+		return "", false, err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", false, fmt.Errorf("field [%s] of type [%T] cannot be cast to string", o.field, v)
+	}
+	return s, true, nil
+}
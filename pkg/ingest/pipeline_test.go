@@ -0,0 +1,202 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+func runPipeline(t *testing.T, processors string, source string) (*Document, error) {
+	pipeline := new(meta.Pipeline)
+	assert.NoError(t, json.Unmarshal([]byte(`{"processors":`+processors+`}`), pipeline))
+	p, err := New("test", pipeline)
+	if err != nil {
+		return nil, err
+	}
+	src := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal([]byte(source), &src))
+	doc := NewDocument("index1", "1", src)
+	return doc, p.Run(doc)
+}
+
+func TestPipeline_Processors(t *testing.T) {
+	tests := []struct {
+		name       string
+		processors string
+		source     string
+		want       map[string]interface{}
+		wantErr    bool
+	}{
+		{
+			name:       "set",
+			processors: `[{"set":{"field":"a.b","value":"{{name}}-x"}},{"set":{"field":"name","value":"no","override":false}}]`,
+			source:     `{"name":"zinc"}`,
+			want:       map[string]interface{}{"name": "zinc", "a": map[string]interface{}{"b": "zinc-x"}},
+		},
+		{
+			name:       "set copy_from",
+			processors: `[{"set":{"field":"copy","copy_from":"obj"}}]`,
+			source:     `{"obj":{"x":1}}`,
+			want:       map[string]interface{}{"obj": map[string]interface{}{"x": float64(1)}, "copy": map[string]interface{}{"x": float64(1)}},
+		},
+		{
+			name:       "remove",
+			processors: `[{"remove":{"field":["a","b.c"]}},{"remove":{"field":"missing","ignore_missing":true}}]`,
+			source:     `{"a":1,"b":{"c":2,"d":3}}`,
+			want:       map[string]interface{}{"b": map[string]interface{}{"d": float64(3)}},
+		},
+		{
+			name:       "remove missing",
+			processors: `[{"remove":{"field":"missing"}}]`,
+			source:     `{}`,
+			wantErr:    true,
+		},
+		{
+			name:       "rename",
+			processors: `[{"rename":{"field":"old","target_field":"new.name"}}]`,
+			source:     `{"old":"v"}`,
+			want:       map[string]interface{}{"new": map[string]interface{}{"name": "v"}},
+		},
+		{
+			name:       "convert",
+			processors: `[{"convert":{"field":"n","type":"integer"}},{"convert":{"field":"f","type":"float","target_field":"g"}},{"convert":{"field":"b","type":"boolean"}},{"convert":{"field":"l","type":"auto"}}]`,
+			source:     `{"n":"12","f":"1.5","b":"TRUE","l":["1","x"]}`,
+			want:       map[string]interface{}{"n": int64(12), "f": "1.5", "g": 1.5, "b": true, "l": []interface{}{int64(1), "x"}},
+		},
+		{
+			name:       "convert error",
+			processors: `[{"convert":{"field":"n","type":"integer"}}]`,
+			source:     `{"n":"abc"}`,
+			wantErr:    true,
+		},
+		{
+			name:       "lowercase uppercase trim",
+			processors: `[{"lowercase":{"field":"a"}},{"uppercase":{"field":"b","target_field":"c"}},{"trim":{"field":"d"}}]`,
+			source:     `{"a":"HeLLo","b":["x","y"],"d":"  z "}`,
+			want:       map[string]interface{}{"a": "hello", "b": []interface{}{"x", "y"}, "c": []interface{}{"X", "Y"}, "d": "z"},
+		},
+		{
+			name:       "split",
+			processors: `[{"split":{"field":"tags","separator":"\\s*,\\s*"}}]`,
+			source:     `{"tags":"a, b ,c,"}`,
+			want:       map[string]interface{}{"tags": []interface{}{"a", "b", "c"}},
+		},
+		{
+			name:       "json",
+			processors: `[{"json":{"field":"raw","target_field":"parsed"}},{"json":{"field":"root","add_to_root":true}}]`,
+			source:     `{"raw":"{\"a\":[1]}","root":"{\"b\":\"c\"}"}`,
+			want: map[string]interface{}{
+				"raw": `{"a":[1]}`, "parsed": map[string]interface{}{"a": []interface{}{float64(1)}},
+				"root": `{"b":"c"}`, "b": "c",
+			},
+		},
+		{
+			name:       "date",
+			processors: `[{"date":{"field":"ts","formats":["UNIX_MS","2006-01-02 15:04:05"],"timezone":"Asia/Shanghai"}}]`,
+			source:     `{"ts":"2022-10-01 08:00:00"}`,
+			want:       map[string]interface{}{"ts": "2022-10-01 08:00:00", "@timestamp": "2022-10-01T08:00:00.000+08:00"},
+		},
+		{
+			name:       "date unix",
+			processors: `[{"date":{"field":"ts","formats":["UNIX"],"target_field":"t","output_format":"2006-01-02T15:04:05Z07:00"}}]`,
+			source:     `{"ts":1664611200}`,
+			want:       map[string]interface{}{"ts": float64(1664611200), "t": "2022-10-01T08:00:00Z"},
+		},
+		{
+			name:       "if",
+			processors: `[{"set":{"field":"hot","value":true,"if":{"field":"n","gte":10}}},{"set":{"field":"cold","value":true,"if":{"not":{"field":"n","gte":10}}}}]`,
+			source:     `{"n":12}`,
+			want:       map[string]interface{}{"n": float64(12), "hot": true},
+		},
+		{
+			name:       "ignore_failure",
+			processors: `[{"rename":{"field":"missing","target_field":"x","ignore_failure":true}},{"set":{"field":"ok","value":1}}]`,
+			source:     `{}`,
+			want:       map[string]interface{}{"ok": float64(1)},
+		},
+		{
+			name:       "on_failure",
+			processors: `[{"convert":{"field":"n","type":"long","on_failure":[{"set":{"field":"error","value":"{{_ingest.on_failure_processor_type}}"}}]}},{"set":{"field":"next","value":1}}]`,
+			source:     `{"n":"x"}`,
+			want:       map[string]interface{}{"n": "x", "error": "convert", "next": float64(1)},
+		},
+		{
+			name:       "unknown processor",
+			processors: `[{"foo":{}}]`,
+			wantErr:    true,
+		},
+		{
+			name:       "unknown option",
+			processors: `[{"set":{"field":"a","value":1,"foo":1}}]`,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			if source == "" {
+				source = "{}"
+			}
+			doc, err := runPipeline(t, tt.processors, source)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, doc.Source)
+		})
+	}
+}
+
+func TestPipeline_Drop(t *testing.T) {
+	processors := `[{"drop":{"if":{"field":"level","in":["debug","trace"]}}},{"set":{"field":"kept","value":true}}]`
+	doc, err := runPipeline(t, processors, `{"level":"debug"}`)
+	assert.NoError(t, err)
+	assert.True(t, doc.Dropped())
+	assert.Nil(t, doc.Source["kept"])
+
+	doc, err = runPipeline(t, processors, `{"level":"info"}`)
+	assert.NoError(t, err)
+	assert.False(t, doc.Dropped())
+	assert.Equal(t, true, doc.Source["kept"])
+}
+
+func TestPipeline_Metadata(t *testing.T) {
+	doc, err := runPipeline(t, `[{"set":{"field":"_id","value":"{{user}}"}},{"set":{"field":"_index","value":"logs-{{app}}"}}]`, `{"user":"u1","app":"web"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "u1", doc.ID)
+	assert.Equal(t, "logs-web", doc.Index)
+
+	_, err = runPipeline(t, `[{"remove":{"field":"_id"}}]`, `{}`)
+	assert.Error(t, err)
+}
+
+func TestPipeline_OnFailure(t *testing.T) {
+	pipeline := &meta.Pipeline{
+		Processors: []map[string]interface{}{{"rename": map[string]interface{}{"field": "a", "target_field": "b"}}},
+		OnFailure:  []map[string]interface{}{{"set": map[string]interface{}{"field": "failed", "value": "{{_ingest.on_failure_message}}"}}},
+	}
+	p, err := New("test", pipeline)
+	assert.NoError(t, err)
+	doc := NewDocument("index1", "1", map[string]interface{}{})
+	assert.NoError(t, p.Run(doc))
+	assert.Contains(t, doc.Source["failed"], "field [a] doesn't exist")
+}
@@ -0,0 +1,60 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+)
+
+// removeProcessor removes the fields
+type removeProcessor struct {
+	fields        []string
+	ignoreMissing bool
+}
+
+func newRemoveProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(removeProcessor)
+	var err error
+	for k, v := range options {
+		switch k {
+		case "field":
+			p.fields, err = stringsOption("remove", k, v)
+		case "ignore_missing":
+			p.ignoreMissing, err = boolOption("remove", k, v)
+		default:
+			return nil, unknownOption("remove", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.fields) == 0 {
+		return nil, requiredOption("remove", "field")
+	}
+	return p, nil
+}
+
+func (p *removeProcessor) Process(doc *Document) error {
+	for _, field := range p.fields {
+		if field == "_index" || field == "_id" {
+			return fmt.Errorf("cannot remove the metadata field [%s]", field)
+		}
+		if !doc.Remove(field) && !p.ignoreMissing {
+			return fmt.Errorf("field [%s] doesn't exist", field)
+		}
+	}
+	return nil
+}
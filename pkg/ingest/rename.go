@@ -0,0 +1,60 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+)
+
+// renameProcessor moves the value of the field to the target_field
+type renameProcessor struct {
+	fieldOptions
+}
+
+func newRenameProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(renameProcessor)
+	for k, v := range options {
+		ok, err := p.parse("rename", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, unknownOption("rename", k)
+		}
+	}
+	if p.targetField == "" {
+		return nil, requiredOption("rename", "target_field")
+	}
+	if err := p.check("rename"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *renameProcessor) Process(doc *Document) error {
+	v, ok := doc.Get(p.field)
+	if !ok {
+		if p.ignoreMissing {
+			return nil
+		}
+		return fmt.Errorf("field [%s] doesn't exist", p.field)
+	}
+	if _, ok := doc.Get(p.targetField); ok {
+		return fmt.Errorf("field [%s] already exists", p.targetField)
+	}
+	doc.Remove(p.field)
+	return doc.Set(p.targetField, v)
+}
@@ -0,0 +1,130 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+)
+
+// setProcessor sets the field to the value or copies it from another field,
+// the string values can refer to other fields like {{_ingest.timestamp}}.
+type setProcessor struct {
+	field            string
+	value            interface{}
+	hasValue         bool
+	copyFrom         string
+	override         bool
+	ignoreEmptyValue bool
+}
+
+func newSetProcessor(options map[string]interface{}) (Processor, error) {
+	p := &setProcessor{override: true}
+	var err error
+	for k, v := range options {
+		switch k {
+		case "field":
+			p.field, err = stringOption("set", k, v)
+		case "value":
+			p.value, p.hasValue = v, true
+		case "copy_from":
+			p.copyFrom, err = stringOption("set", k, v)
+		case "override":
+			p.override, err = boolOption("set", k, v)
+		case "ignore_empty_value":
+			p.ignoreEmptyValue, err = boolOption("set", k, v)
+		default:
+			return nil, unknownOption("set", k)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.field == "" {
+		return nil, requiredOption("set", "field")
+	}
+	if p.hasValue == (p.copyFrom != "") {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[set] one of [value] or [copy_from] is required")
+	}
+	return p, nil
+}
+
+func (p *setProcessor) Process(doc *Document) error {
+	if !p.override {
+		if v, ok := doc.Get(p.field); ok && v != nil {
+			return nil
+		}
+	}
+	value := p.value
+	if p.copyFrom != "" {
+		v, ok := doc.Get(p.copyFrom)
+		if !ok {
+			if p.ignoreEmptyValue {
+				return nil
+			}
+			return fmt.Errorf("field [%s] doesn't exist", p.copyFrom)
+		}
+		value = deepCopy(v)
+	} else {
+		value = renderValue(doc, value)
+	}
+	if p.ignoreEmptyValue && (value == nil || value == "") {
+		return nil
+	}
+	return doc.Set(p.field, value)
+}
+
+// renderValue renders the templates in the strings of the value
+func renderValue(doc *Document, v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return renderTemplate(doc, v)
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i := range v {
+			rv[i] = renderValue(doc, v[i])
+		}
+		return rv
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(v))
+		for k := range v {
+			rv[k] = renderValue(doc, v[k])
+		}
+		return rv
+	default:
+		return v
+	}
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		rv := make([]interface{}, len(v))
+		for i := range v {
+			rv[i] = deepCopy(v[i])
+		}
+		return rv
+	case map[string]interface{}:
+		rv := make(map[string]interface{}, len(v))
+		for k := range v {
+			rv[k] = deepCopy(v[k])
+		}
+		return rv
+	default:
+		return v
+	}
+}
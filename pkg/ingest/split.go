@@ -0,0 +1,84 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+)
+
+// splitProcessor splits a string field into an array by the separator regexp
+type splitProcessor struct {
+	fieldOptions
+	separator        *regexp.Regexp
+	preserveTrailing bool
+}
+
+func newSplitProcessor(options map[string]interface{}) (Processor, error) {
+	p := new(splitProcessor)
+	for k, v := range options {
+		ok, err := p.parse("split", k, v)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		switch k {
+		case "separator":
+			s, err := stringOption("split", k, v)
+			if err != nil {
+				return nil, err
+			}
+			if p.separator, err = regexp.Compile(s); err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[split] [separator] %s", err.Error()))
+			}
+		case "preserve_trailing":
+			if p.preserveTrailing, err = boolOption("split", k, v); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, unknownOption("split", k)
+		}
+	}
+	if p.separator == nil {
+		return nil, requiredOption("split", "separator")
+	}
+	if err := p.check("split"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *splitProcessor) Process(doc *Document) error {
+	s, ok, err := p.stringValue(doc)
+	if !ok {
+		return err
+	}
+	parts := p.separator.Split(s, -1)
+	if !p.preserveTrailing {
+		for len(parts) > 0 && parts[len(parts)-1] == "" {
+			parts = parts[:len(parts)-1]
+		}
+	}
+	rv := make([]interface{}, len(parts))
+	for i := range parts {
+		rv[i] = parts[i]
+	}
+	return doc.Set(p.targetField, rv)
+}
@@ -0,0 +1,64 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+const DataStreamStatusGreen = "GREEN"
+
+// DataStream is an append-only target backed by hidden indexes, the last one receives the writes.
+// The backing indexes are named .ds-<name>-<yyyy.MM.dd>-<generation>.
+type DataStream struct {
+	Name           string                   `json:"name"`
+	TimestampField DataStreamTimestampField `json:"timestamp_field"`
+	Indices        []DataStreamIndex        `json:"indices"`
+	Generation     int64                    `json:"generation"`
+	Status         string                   `json:"status"`
+	Template       string                   `json:"template"`
+	CreatedAt      time.Time                `json:"created_at"`
+}
+
+type DataStreamTimestampField struct {
+	Name string `json:"name"`
+}
+
+type DataStreamIndex struct {
+	IndexName string `json:"index_name"`
+}
+
+// DataStreamTemplate enables the data streams in an index template, it has no options yet
+type DataStreamTemplate struct{}
+
+type DataStreamStats struct {
+	DataStream       string `json:"data_stream"`
+	BackingIndices   int    `json:"backing_indices"`
+	StoreSizeBytes   uint64 `json:"store_size_bytes"`
+	MaximumTimestamp int64  `json:"maximum_timestamp"` // unix milliseconds of the newest document
+}
+
+type DataStreamStatsResponse struct {
+	Shards              DataStreamStatsShards `json:"_shards"`
+	DataStreamCount     int                   `json:"data_stream_count"`
+	BackingIndices      int                   `json:"backing_indices"`
+	TotalStoreSizeBytes uint64                `json:"total_store_size_bytes"`
+	DataStreams         []DataStreamStats     `json:"data_streams"`
+}
+
+type DataStreamStatsShards struct {
+	Total      int64 `json:"total"`
+	Successful int64 `json:"successful"`
+	Failed     int64 `json:"failed"`
+}
@@ -0,0 +1,25 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+type DeleteByQueryRequest struct {
+	Query      interface{}    `json:"query"`     // delete all the documents by default
+	MaxDocs    int64          `json:"max_docs"`  // delete all the matched documents by default
+	Size       int64          `json:"size"`      // the old name of max_docs
+	Conflicts  string         `json:"conflicts"` // abort or proceed, abort by default
+	ScrollSize int            `json:"-"`         // documents per batch, 1000 by default
+	Indices    IndicesOptions `json:"-"`         // from the ignore_unavailable and allow_no_indices params
+}
@@ -0,0 +1,43 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+// PrimaryTerm is always 1, there are no replicas to promote
+const PrimaryTerm = 1
+
+const (
+	VersionTypeInternal    = "internal"
+	VersionTypeExternal    = "external"
+	VersionTypeExternalGTE = "external_gte"
+)
+
+// VersionCondition is the optimistic concurrency control of a write, the write fails with a conflict
+// if the current document doesn't match it
+type VersionCondition struct {
+	IfSeqNo       *int64
+	IfPrimaryTerm *int64
+	Version       *int64
+	VersionType   string // internal, external or external_gte, internal by default
+	Create        bool   // the write fails if the document exists
+}
+
+// DocumentVersion is the version of a document after a write
+type DocumentVersion struct {
+	Version     int64
+	SeqNo       int64
+	PrimaryTerm int64
+	Result      string // created, updated or deleted
+}
@@ -25,9 +25,13 @@ type HTTPResponseID struct {
 }
 
 type HTTPResponseDocument struct {
-	Message string `json:"message"`
-	Index   string `json:"index"`
-	ID      string `json:"id,omitempty"`
+	Message     string `json:"message"`
+	Index       string `json:"index"`
+	ID          string `json:"id,omitempty"`
+	Version     int64  `json:"_version,omitempty"`
+	SeqNo       int64  `json:"_seq_no,omitempty"`
+	PrimaryTerm int64  `json:"_primary_term,omitempty"`
+	Result      string `json:"result,omitempty"`
 }
 
 type HTTPResponseIndex struct {
@@ -55,9 +59,9 @@ type HTTPResponseESID struct {
 	ID          string `json:"id"`
 	ESID        string `json:"_id"`
 	Index       string `json:"_index"`
-	Version     int    `json:"_version"`
-	SeqNo       int    `json:"_seq_no"`
-	PrimaryTerm int    `json:"_primary_term"`
+	Version     int64  `json:"_version"`
+	SeqNo       int64  `json:"_seq_no"`
+	PrimaryTerm int64  `json:"_primary_term"`
 	Result      string `json:"result"` // created, updated, deleted
 }
 
@@ -69,14 +73,15 @@ type HttpRetriesResponse struct {
 type HTTPResponseDeleteByQuery struct {
 	Took                 int64               `json:"took"`
 	TimedOut             bool                `json:"time_out"`
-	Total                int                 `json:"total"`
-	Deleted              int                 `json:"deleted"`
-	Batches              int                 `json:"batches"`
-	VersionConflicts     int                 `json:"version_conflicts"`
-	Noops                int                 `json:"noops"`
-	Failures             []string            `json:"failures"`
+	Total                int64               `json:"total"`
+	Deleted              int64               `json:"deleted"`
+	Batches              int64               `json:"batches"`
+	VersionConflicts     int64               `json:"version_conflicts"`
+	Noops                int64               `json:"noops"`
+	Failures             []ReindexFailure    `json:"failures"`
 	Retries              HttpRetriesResponse `json:"retries"`
-	ThrottledMillis      int                 `json:"throttled_millis"`
-	RequestsPerSecond    int                 `json:"requests_per_second"`
-	ThrottledUntilMillis int                 `json:"throttled_until_millis"`
+	ThrottledMillis      int64               `json:"throttled_millis"`
+	RequestsPerSecond    float64             `json:"requests_per_second"`
+	ThrottledUntilMillis int64               `json:"throttled_until_millis"`
+	Canceled             string              `json:"canceled,omitempty"`
 }
@@ -24,6 +24,7 @@ type Index struct {
 	Shards      map[string]*IndexShard `json:"shards"`
 	Stats       IndexStat              `json:"stats"`
 	Version     string                 `json:"version"`
+	DataStream  string                 `json:"data_stream,omitempty"` // the data stream the index is backing
 }
 
 type IndexShard struct {
@@ -35,8 +36,9 @@ type IndexShard struct {
 }
 
 type IndexSecondShard struct {
-	ID    int64     `json:"id"`
-	Stats IndexStat `json:"stats"`
+	ID      int64     `json:"id"`
+	Stats   IndexStat `json:"stats"`
+	Deleted bool      `json:"deleted,omitempty"` // removed by the lifecycle retention, the slot is kept because the ids are positions
 }
 
 type IndexStat struct {
@@ -45,6 +47,7 @@ type IndexStat struct {
 	DocNum      uint64 `json:"doc_num"`
 	StorageSize uint64 `json:"storage_size"`
 	WALSize     uint64 `json:"wal_size"`
+	OpenPITs    int64  `json:"open_pits"` // point in times pinning the readers of the index, reset on load
 }
 
 type IndexSimple struct {
@@ -56,9 +59,18 @@ type IndexSimple struct {
 }
 
 type IndexSettings struct {
-	NumberOfShards   int64          `json:"number_of_shards,omitempty"`
-	NumberOfReplicas int64          `json:"number_of_replicas,omitempty"`
-	Analysis         *IndexAnalysis `json:"analysis,omitempty"`
+	NumberOfShards   int64           `json:"number_of_shards,omitempty"`
+	NumberOfReplicas int64           `json:"number_of_replicas,omitempty"`
+	Analysis         *IndexAnalysis  `json:"analysis,omitempty"`
+	DefaultPipeline  string          `json:"default_pipeline,omitempty"` // the ingest pipeline of the writes without a pipeline
+	Lifecycle        *IndexLifecycle `json:"lifecycle,omitempty"`
+}
+
+type IndexLifecycle struct {
+	Name             string `json:"name,omitempty"` // the lifecycle policy
+	RolloverAlias    string `json:"rollover_alias,omitempty"`
+	OriginationDate  int64  `json:"origination_date,omitempty"`  // unix milliseconds the age of the index is counted from
+	IndexingComplete bool   `json:"indexing_complete,omitempty"` // the index is rolled over and isn't the write index anymore
 }
 
 type IndexAnalysis struct {
@@ -68,3 +80,9 @@ type IndexAnalysis struct {
 	TokenFilter map[string]interface{} `json:"token_filter,omitempty"`
 	Filter      map[string]interface{} `json:"filter,omitempty"` // compatibility with es, alias for TokenFilter
 }
+
+// IndicesOptions controls how a target with missing indexes is resolved
+type IndicesOptions struct {
+	IgnoreUnavailable bool  `json:"ignore_unavailable,omitempty"` // skip the missing indexes instead of failing
+	AllowNoIndices    *bool `json:"allow_no_indices,omitempty"`   // true(default), a target matching no index is not an error
+}
@@ -0,0 +1,78 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+const (
+	LifecycleDeleteScopeIndex = "index" // delete the whole index
+	LifecycleDeleteScopeShard = "shard" // delete the frozen second layer shards, the index is kept
+
+	LifecyclePhaseHot    = "hot"    // the index receives the writes
+	LifecyclePhaseWarm   = "warm"   // the index is rolled over, it waits for the retention
+	LifecyclePhaseDelete = "delete" // the index is deleted by the next run
+)
+
+// LifecyclePolicy is attached to the indexes by the lifecycle.name setting,
+// the scheduler rolls over the write index of the alias and deletes the expired data.
+type LifecyclePolicy struct {
+	Name      string             `json:"name"`
+	Rollover  *LifecycleRollover `json:"rollover,omitempty"`
+	Delete    *LifecycleDelete   `json:"delete,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// LifecycleRollover rolls the index over when any of the conditions is met
+type LifecycleRollover struct {
+	MaxSize string `json:"max_size,omitempty"` // storage size, eg.: 50gb
+	MaxDocs uint64 `json:"max_docs,omitempty"`
+	MaxAge  string `json:"max_age,omitempty"` // since the origination date of the index, eg.: 7d
+}
+
+// LifecycleDelete deletes the data once the newest document is older than the retention
+type LifecycleDelete struct {
+	MinAge string `json:"min_age"`
+	Scope  string `json:"scope,omitempty"` // index or shard, default index
+}
+
+// RolloverRequest rolls over the write index of an alias, always if no condition is given
+type RolloverRequest struct {
+	Conditions *LifecycleRollover `json:"conditions,omitempty"`
+}
+
+type RolloverResponse struct {
+	Acknowledged bool            `json:"acknowledged"`
+	OldIndex     string          `json:"old_index"`
+	NewIndex     string          `json:"new_index"`
+	RolledOver   bool            `json:"rolled_over"`
+	Conditions   map[string]bool `json:"conditions"`
+}
+
+type LifecycleExplain struct {
+	Index         string     `json:"index"`
+	Managed       bool       `json:"managed"`
+	Policy        string     `json:"policy,omitempty"`
+	Phase         string     `json:"phase,omitempty"`
+	Age           string     `json:"age,omitempty"`
+	RolloverAlias string     `json:"rollover_alias,omitempty"`
+	IsWriteIndex  bool       `json:"is_write_index,omitempty"`
+	DocTimeMax    *time.Time `json:"doc_time_max,omitempty"`
+	DeleteAfter   *time.Time `json:"delete_after,omitempty"` // when the index or the oldest frozen shard expires
+	FrozenShards  int        `json:"frozen_shards,omitempty"`
+	DeletedShards int        `json:"deleted_shards,omitempty"`
+	Error         string     `json:"error,omitempty"` // the last failure of the scheduler
+}
@@ -28,7 +28,7 @@ type Mappings struct {
 }
 
 type Property struct {
-	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point
+	Type           string `json:"type"` // text, keyword, date, numeric, boolean, geo_point, ip
 	Analyzer       string `json:"analyzer,omitempty"`
 	SearchAnalyzer string `json:"search_analyzer,omitempty"`
 	Format         string `json:"format,omitempty"`    // date format yyyy-MM-dd HH:mm:ss || yyyy-MM-dd || epoch_millis
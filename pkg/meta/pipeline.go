@@ -0,0 +1,57 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+type Pipeline struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Version     int64                    `json:"version,omitempty"`
+	Processors  []map[string]interface{} `json:"processors"`
+	OnFailure   []map[string]interface{} `json:"on_failure,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	UpdatedAt   time.Time                `json:"updated_at"`
+}
+
+// PipelineSimulateRequest runs the pipeline for the docs without writing them,
+// the pipeline in the request is used when the pipeline name is not given.
+type PipelineSimulateRequest struct {
+	Pipeline *Pipeline                `json:"pipeline,omitempty"`
+	Docs     []PipelineSimulateSource `json:"docs"`
+}
+
+type PipelineSimulateSource struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+type PipelineSimulateResponse struct {
+	Docs []PipelineSimulateResult `json:"docs"`
+}
+
+type PipelineSimulateResult struct {
+	Doc   *PipelineSimulateDoc `json:"doc,omitempty"`
+	Error interface{}          `json:"error,omitempty"`
+}
+
+type PipelineSimulateDoc struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+	Ingest map[string]interface{} `json:"_ingest"`
+}
@@ -30,6 +30,11 @@ type ZincQuery struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	SearchAfter    []interface{}           `json:"search_after"` // the sort values of the last hit from the previous page
+	PIT            *PointInTime            `json:"pit"`          // search the readers pinned by the point in time instead of the indexes
+	Collapse       *Collapse               `json:"collapse"`     // keeps the best hit of each value of a keyword field
+
+	SeqNoPrimaryTerm bool `json:"seq_no_primary_term"` // returns the sequence number and the primary term of the hits
 }
 
 type ZincQueryForSDK struct {
@@ -44,6 +49,29 @@ type ZincQueryForSDK struct {
 	Size           int                     `json:"size"`
 	Timeout        int                     `json:"timeout"`
 	TrackTotalHits bool                    `json:"track_total_hits"`
+	SearchAfter    []interface{}           `json:"search_after"`
+	PIT            *PointInTime            `json:"pit"`
+	Collapse       *Collapse               `json:"collapse"`
+}
+
+type Collapse struct {
+	Field                      string     `json:"field"`
+	InnerHits                  *InnerHits `json:"inner_hits"`
+	MaxConcurrentGroupSearches int        `json:"max_concurrent_group_searches"` // the number of inner_hits searches running at once
+}
+
+// InnerHits returns the top documents of each collapsed group
+type InnerHits struct {
+	Name   string      `json:"name"`
+	From   int         `json:"from"`
+	Size   int         `json:"size"`
+	Sort   interface{} `json:"sort"`
+	Source interface{} `json:"_source"`
+}
+
+type PointInTime struct {
+	ID        string `json:"id"`
+	KeepAlive string `json:"keep_alive"` // extends the keep alive of the point in time, eg: 1m
 }
 
 type Query struct {
@@ -69,9 +97,9 @@ type Query struct {
 	Term              map[string]*TermQuery              `json:"term,omitempty"`                // simple, TermQuery
 	Terms             map[string]*TermsQuery             `json:"terms,omitempty"`               // .
 	TermsSet          map[string]*TermsSetQuery          `json:"terms_set,omitempty"`           // TODO: not implemented
-	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // TODO: not implemented
-	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // TODO: not implemented
-	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // TODO: not implemented
+	GeoBoundingBox    interface{}                        `json:"geo_bounding_box,omitempty"`    // .
+	GeoDistance       interface{}                        `json:"geo_distance,omitempty"`        // .
+	GeoPolygon        interface{}                        `json:"geo_polygon,omitempty"`         // .
 	GeoShape          interface{}                        `json:"geo_shape,omitempty"`           // TODO: not implemented
 }
 
@@ -166,15 +194,21 @@ type CombinedFieldsQuery struct {
 	Fields             []string `json:"fields,omitempty"`
 	Operator           string   `json:"operator,omitempty"` // or(default), and
 	MinimumShouldMatch float64  `json:"minimum_should_match,omitempty"`
+	Boost              float64  `json:"boost,omitempty"`
 }
 
 type QueryStringQuery struct {
-	Query           string   `json:"query,omitempty"`
-	Analyzer        string   `json:"analyzer,omitempty"`
-	Fields          []string `json:"fields,omitempty"`
-	DefaultField    string   `json:"default_field,omitempty"`
-	DefaultOperator string   `json:"default_operator,omitempty"` // or(default), and
-	Boost           float64  `json:"boost,omitempty"`
+	Query                string      `json:"query,omitempty"`
+	Analyzer             string      `json:"analyzer,omitempty"`
+	Fields               []string    `json:"fields,omitempty"`           // field^boost, field*
+	DefaultField         string      `json:"default_field,omitempty"`    // _all(default)
+	DefaultOperator      string      `json:"default_operator,omitempty"` // or(default), and
+	Boost                float64     `json:"boost,omitempty"`
+	Lenient              bool        `json:"lenient,omitempty"`
+	AllowLeadingWildcard *bool       `json:"allow_leading_wildcard,omitempty"` // true(default)
+	AnalyzeWildcard      bool        `json:"analyze_wildcard,omitempty"`
+	Fuzziness            interface{} `json:"fuzziness,omitempty"` // AUTO(default), 0, 1, 2
+	PhraseSlop           int         `json:"phrase_slop,omitempty"`
 }
 
 type SimpleQueryStringQuery struct {
@@ -189,7 +223,8 @@ type SimpleQueryStringQuery struct {
 // ExistsQuery
 // {"exists":{"field":"field_name"}}
 type ExistsQuery struct {
-	Field string `json:"field,omitempty"`
+	Field string  `json:"field,omitempty"`
+	Boost float64 `json:"boost,omitempty"`
 }
 
 // IdsQuery
@@ -273,8 +308,29 @@ type TermQueryForSDK struct {
 // {"terms": {"field": ["value1", "value2"], "boost": 1.0}}
 type TermsQuery map[string]interface{}
 
-// TermsSetQuery ...
-type TermsSetQuery struct{}
+// TermsSetQuery
+// {"terms_set":{"field":{"terms":["a","b"],"minimum_should_match_field":"required_matches"}}}
+type TermsSetQuery struct {
+	Terms                    []interface{} `json:"terms,omitempty"`
+	MinimumShouldMatchField  string        `json:"minimum_should_match_field,omitempty"`
+	MinimumShouldMatchScript interface{}   `json:"minimum_should_match_script,omitempty"`
+	Boost                    float64       `json:"boost,omitempty"`
+}
+
+// GeoDistanceQuery
+// {"geo_distance":{"distance":"200km","field":{"lat":40,"lon":-70}}}
+type GeoDistanceQuery struct {
+	Distance string      `json:"distance,omitempty"` // 12km, 100m, 1mi
+	Location interface{} `json:"-"`                  // {"lat":40,"lon":-70}, "40,-70", "drm3btev3e86", [-70,40]
+	Boost    float64     `json:"boost,omitempty"`
+}
+
+// GeoPolygonQuery
+// {"geo_polygon":{"field":{"points":[{"lat":40,"lon":-70},{"lat":30,"lon":-80},{"lat":20,"lon":-90}]}}}
+type GeoPolygonQuery struct {
+	Points []interface{} `json:"points,omitempty"`
+	Boost  float64       `json:"boost,omitempty"`
+}
 
 type Aggregations struct {
 	Avg               *AggregationMetric            `json:"avg"`
@@ -284,19 +340,58 @@ type Aggregations struct {
 	Sum               *AggregationMetric            `json:"sum"`
 	Count             *AggregationMetric            `json:"count"`
 	Cardinality       *AggregationMetric            `json:"cardinality"`
+	ValueCount        *AggregationMetric            `json:"value_count"`
+	Stats             *AggregationMetric            `json:"stats"`
+	ExtendedStats     *AggregationMetric            `json:"extended_stats"`
+	Percentiles       *AggregationPercentiles       `json:"percentiles"`
+	PercentileRanks   *AggregationPercentiles       `json:"percentile_ranks"`
+	TopHits           *AggregationTopHits           `json:"top_hits"`
 	Terms             *AggregationsTerms            `json:"terms"`
 	Range             *AggregationRange             `json:"range"`
 	DateRange         *AggregationDateRange         `json:"date_range"`
 	Histogram         *AggregationHistogram         `json:"histogram"`
 	DateHistogram     *AggregationDateHistogram     `json:"date_histogram"`
 	AutoDateHistogram *AggregationAutoDateHistogram `json:"auto_date_histogram"`
-	IPRange           *AggregationIPRange           `json:"ip_range"` // TODO: not implemented
-	Aggregations      map[string]Aggregations       `json:"aggs"`     // nested aggregations
+	IPRange           *AggregationIPRange           `json:"ip_range"`
+	Aggregations      map[string]Aggregations       `json:"aggs"` // nested aggregations
+
+	// pipeline aggregations, they run on the merged result of other aggregations
+	Derivative     *AggregationPipeline   `json:"derivative"`
+	CumulativeSum  *AggregationPipeline   `json:"cumulative_sum"`
+	MovingFn       *AggregationPipeline   `json:"moving_fn"`
+	MovingAvg      *AggregationPipeline   `json:"moving_avg"`
+	BucketScript   *AggregationPipeline   `json:"bucket_script"`
+	BucketSelector *AggregationPipeline   `json:"bucket_selector"`
+	BucketSort     *AggregationBucketSort `json:"bucket_sort"`
+	AvgBucket      *AggregationPipeline   `json:"avg_bucket"`
+	MaxBucket      *AggregationPipeline   `json:"max_bucket"`
+	MinBucket      *AggregationPipeline   `json:"min_bucket"`
+	SumBucket      *AggregationPipeline   `json:"sum_bucket"`
 }
 
 type AggregationMetric struct {
-	Field       string `json:"field"`
-	WeightField string `json:"weight_field"` // Field name to be used for setting weight for primary field for weighted average aggregation
+	Field       string  `json:"field"`
+	WeightField string  `json:"weight_field"` // Field name to be used for setting weight for primary field for weighted average aggregation
+	Sigma       float64 `json:"sigma"`        // standard deviations of the std_deviation_bounds for extended_stats aggregation, default is 2
+}
+
+type AggregationPercentiles struct {
+	Field    string                 `json:"field"`
+	Percents []float64              `json:"percents"` // percentiles, default is [1, 5, 25, 50, 75, 95, 99]
+	Values   []float64              `json:"values"`   // percentile_ranks
+	Keyed    *bool                  `json:"keyed"`    // default is true
+	TDigest  *AggregationTDigestOpt `json:"tdigest"`
+}
+
+type AggregationTDigestOpt struct {
+	Compression float64 `json:"compression"` // default is 100
+}
+
+type AggregationTopHits struct {
+	From   int         `json:"from"`
+	Size   int         `json:"size"`    // default is 3
+	Sort   interface{} `json:"sort"`    // same as the query sort, default is _score
+	Source interface{} `json:"_source"` // true, false, or the fields
 }
 
 type AggregationsTerms struct {
@@ -339,8 +434,10 @@ type AggregationIPRange struct {
 }
 
 type IPRange struct {
+	Key  string `json:"key"`
 	To   string `json:"to"`
 	From string `json:"from"`
+	Mask string `json:"mask"` // CIDR, such as: 10.0.0.0/25
 }
 
 type AggregationHistogram struct {
@@ -377,6 +474,26 @@ type AggregationAutoDateHistogram struct {
 	Keyed           bool   `json:"keyed"`
 }
 
+// AggregationPipeline
+// buckets_path refer to:
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/search-aggregations-pipeline.html#buckets-path-syntax
+type AggregationPipeline struct {
+	BucketsPath interface{}            `json:"buckets_path"` // string, or map of script variable to path for bucket_script and bucket_selector
+	GapPolicy   string                 `json:"gap_policy"`   // skip, insert_zeros, keep_values, default is skip
+	Script      interface{}            `json:"script"`       // string or {"source": "", "params": {}}
+	Window      int                    `json:"window"`       // moving_fn, moving_avg
+	Shift       int                    `json:"shift"`        // moving_fn
+	Model       string                 `json:"model"`        // moving_avg: simple, linear, ewma
+	Settings    map[string]interface{} `json:"settings"`     // moving_avg: {"alpha": 0.3}
+}
+
+type AggregationBucketSort struct {
+	Sort      []interface{} `json:"sort"` // ["_key", {"the_sum": {"order": "desc"}}]
+	From      int           `json:"from"`
+	Size      int           `json:"size"`
+	GapPolicy string        `json:"gap_policy"`
+}
+
 type Highlight struct {
 	NumberOfFragments int                   `json:"number_of_fragments"`
 	FragmentSize      int                   `json:"fragment_size"`
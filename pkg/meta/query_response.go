@@ -15,7 +15,11 @@
 
 package meta
 
-import "time"
+import (
+	"time"
+
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
 
 // SearchResponse for a query
 type SearchResponse struct {
@@ -25,13 +29,16 @@ type SearchResponse struct {
 	Hits         Hits                           `json:"hits"`
 	Aggregations map[string]AggregationResponse `json:"aggregations,omitempty"`
 	Error        string                         `json:"error,omitempty"`
+	ScrollID     string                         `json:"_scroll_id,omitempty"`
+	PitID        string                         `json:"pit_id,omitempty"`
 }
 
 type Shards struct {
-	Total      int64 `json:"total"`
-	Successful int64 `json:"successful"`
-	Skipped    int64 `json:"skipped"`
-	Failed     int64 `json:"failed"`
+	Total      int64             `json:"total"`
+	Successful int64             `json:"successful"`
+	Skipped    int64             `json:"skipped"`
+	Failed     int64             `json:"failed"`
+	Indices    map[string]Shards `json:"indices,omitempty"` // the breakdown of a search on several indexes
 }
 
 type Hits struct {
@@ -49,6 +56,55 @@ type Hit struct {
 	Source    interface{}            `json:"_source,omitempty"`
 	Fields    map[string]interface{} `json:"fields,omitempty"`
 	Highlight map[string]interface{} `json:"highlight,omitempty"`
+	Sort      []interface{}          `json:"sort,omitempty"`
+
+	Explanation *Explanation        `json:"_explanation,omitempty"` // only returned when explain is true
+	InnerHits   map[string]InnerHit `json:"inner_hits,omitempty"`   // the top documents of the collapsed group
+
+	// the versions are returned by getting a document, the search returns the sequence number with seq_no_primary_term
+	Version     int64 `json:"_version,omitempty"`
+	SeqNo       int64 `json:"_seq_no,omitempty"`
+	PrimaryTerm int64 `json:"_primary_term,omitempty"`
+}
+
+type InnerHit struct {
+	Hits Hits `json:"hits"`
+}
+
+// Explanation describes how the score of a document was computed
+type Explanation struct {
+	Value       float64        `json:"value"`
+	Description string         `json:"description"`
+	Details     []*Explanation `json:"details"`
+}
+
+// CountResponse for a _count request
+type CountResponse struct {
+	Count  int64  `json:"count"`
+	Shards Shards `json:"_shards"`
+}
+
+// ValidateQueryResponse for a _validate/query request
+type ValidateQueryResponse struct {
+	Valid        bool                       `json:"valid"`
+	Shards       Shards                     `json:"_shards"`
+	Explanations []ValidateQueryExplanation `json:"explanations,omitempty"`
+	Error        string                     `json:"error,omitempty"`
+}
+
+type ValidateQueryExplanation struct {
+	Index       string `json:"index"`
+	Valid       bool   `json:"valid"`
+	Explanation string `json:"explanation,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ExplainResponse for a _explain request of a single document
+type ExplainResponse struct {
+	Index       string       `json:"_index"`
+	ID          string       `json:"_id"`
+	Matched     bool         `json:"matched"`
+	Explanation *Explanation `json:"explanation,omitempty"`
 }
 
 type Total struct {
@@ -56,7 +112,25 @@ type Total struct {
 }
 
 type AggregationResponse struct {
-	Value    interface{} `json:"value,omitempty"`
-	Buckets  interface{} `json:"buckets,omitempty"`  // slice or map
-	Interval string      `json:"interval,omitempty"` // support for auto_date_histogram_aggregation
+	Value    interface{}            `json:"value,omitempty"`
+	Values   interface{}            `json:"values,omitempty"`   // support for percentiles and percentile_ranks aggregation, map or slice
+	Buckets  interface{}            `json:"buckets,omitempty"`  // slice or map
+	Interval string                 `json:"interval,omitempty"` // support for auto_date_histogram_aggregation
+	Keys     []string               `json:"keys,omitempty"`     // support for max_bucket and min_bucket aggregation
+	Hits     *Hits                  `json:"hits,omitempty"`     // support for top_hits aggregation
+	Metrics  map[string]interface{} `json:"-"`                  // support for stats and extended_stats aggregation
+}
+
+// MarshalJSON puts the metrics of stats at the top level of the aggregation, such as:
+// {"count": 10, "min": 1, "max": 10, "avg": 5.5, "sum": 55}
+func (r AggregationResponse) MarshalJSON() ([]byte, error) {
+	type response AggregationResponse
+	if len(r.Metrics) == 0 {
+		return json.Marshal(response(r))
+	}
+	data := make(map[string]interface{}, len(r.Metrics))
+	for k, v := range r.Metrics {
+		data[k] = v
+	}
+	return json.Marshal(data)
 }
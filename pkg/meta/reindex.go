@@ -0,0 +1,58 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+const (
+	ReindexOpTypeIndex  = "index"
+	ReindexOpTypeCreate = "create"
+
+	ReindexConflictsAbort   = "abort"
+	ReindexConflictsProceed = "proceed"
+)
+
+type ReindexRequest struct {
+	Source    ReindexSource `json:"source"`
+	Dest      ReindexDest   `json:"dest"`
+	MaxDocs   int64         `json:"max_docs"`
+	Conflicts string        `json:"conflicts"` // abort or proceed, abort by default
+}
+
+type ReindexSource struct {
+	Index  interface{} `json:"index"`   // index, alias or wildcard pattern, a string or an array
+	Query  interface{} `json:"query"`   // copy all the documents by default
+	Source interface{} `json:"_source"` // the fields to copy, all by default
+	Size   int         `json:"size"`    // documents per batch, 1000 by default
+}
+
+type ReindexDest struct {
+	Index    string `json:"index"`
+	OpType   string `json:"op_type"`  // index or create, index by default
+	Pipeline string `json:"pipeline"` // transforms the documents before writing them
+}
+
+type ReindexResponse struct {
+	Took     int64 `json:"took"`
+	TimedOut bool  `json:"timed_out"`
+	TaskStatus
+	Failures []ReindexFailure `json:"failures"`
+	Canceled string           `json:"canceled,omitempty"`
+}
+
+type ReindexFailure struct {
+	Index string    `json:"index"`
+	ID    string    `json:"id"`
+	Cause TaskError `json:"cause"`
+}
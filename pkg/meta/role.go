@@ -18,10 +18,25 @@ package meta
 import "time"
 
 type Role struct {
-	ID         string    `json:"_id"`
-	Name       string    `json:"name"`
-	Role       string    `json:"role"`
-	Permission []string  `json:"permission"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID         string        `json:"_id"`
+	Name       string        `json:"name"`
+	Role       string        `json:"role"`
+	Permission []string      `json:"permission"`
+	Indices    []RoleIndices `json:"indices,omitempty"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
 }
+
+// RoleIndices grants the privileges on the indexes matching the names,
+// names support wildcard patterns, eg.: {"names":["logs-*"],"privileges":["read"]}
+type RoleIndices struct {
+	Names      []string `json:"names"`
+	Privileges []string `json:"privileges"`
+}
+
+const (
+	IndexPrivilegeRead   = "read"
+	IndexPrivilegeWrite  = "write"
+	IndexPrivilegeManage = "manage"
+	IndexPrivilegeAll    = "all"
+)
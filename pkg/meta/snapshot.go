@@ -0,0 +1,92 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+import "time"
+
+const (
+	SnapshotStateInProgress = "IN_PROGRESS"
+	SnapshotStateSuccess    = "SUCCESS"
+	SnapshotStateFailed     = "FAILED"
+)
+
+// SnapshotRepository is where the snapshots are stored, only the fs type is supported
+type SnapshotRepository struct {
+	Name     string                     `json:"name"`
+	Type     string                     `json:"type"`
+	Settings SnapshotRepositorySettings `json:"settings"`
+}
+
+type SnapshotRepositorySettings struct {
+	Location string `json:"location"` // relative to ZINC_SNAPSHOT_PATH_REPO, or an absolute path under it
+}
+
+type Snapshot struct {
+	Snapshot           string        `json:"snapshot"`
+	UUID               string        `json:"uuid"`
+	Repository         string        `json:"repository"`
+	Version            string        `json:"version"`
+	Indices            []string      `json:"indices"`
+	IncludeGlobalState bool          `json:"include_global_state"`
+	State              string        `json:"state"`
+	Reason             string        `json:"reason,omitempty"`
+	StartTime          time.Time     `json:"start_time"`
+	StartTimeInMillis  int64         `json:"start_time_in_millis"`
+	EndTime            time.Time     `json:"end_time"`
+	EndTimeInMillis    int64         `json:"end_time_in_millis"`
+	DurationInMillis   int64         `json:"duration_in_millis"`
+	Shards             SnapshotShard `json:"shards"`
+	Stats              SnapshotStats `json:"stats"`
+}
+
+type SnapshotShard struct {
+	Total      int `json:"total"`
+	Failed     int `json:"failed"`
+	Successful int `json:"successful"`
+}
+
+// SnapshotStats the incremental files are the segments copied by this snapshot,
+// the others are shared with the previous snapshots in the repository.
+type SnapshotStats struct {
+	Incremental SnapshotFileStats `json:"incremental"`
+	Total       SnapshotFileStats `json:"total"`
+}
+
+type SnapshotFileStats struct {
+	FileCount   int   `json:"file_count"`
+	SizeInBytes int64 `json:"size_in_bytes"`
+}
+
+type SnapshotRequest struct {
+	Indices            interface{} `json:"indices"` // "index-*,other" or ["index-*", "other"], default is all
+	IncludeGlobalState *bool       `json:"include_global_state"`
+	IgnoreUnavailable  bool        `json:"ignore_unavailable"`
+}
+
+type RestoreRequest struct {
+	Indices            interface{} `json:"indices"`
+	IgnoreUnavailable  bool        `json:"ignore_unavailable"`
+	IncludeGlobalState *bool       `json:"include_global_state"` // restores the templates, default is true like the snapshot
+	IncludeAliases     *bool       `json:"include_aliases"`
+	RenamePattern      string      `json:"rename_pattern"`
+	RenameReplacement  string      `json:"rename_replacement"`
+}
+
+type RestoreInfo struct {
+	Snapshot string        `json:"snapshot"`
+	Indices  []string      `json:"indices"`
+	Shards   SnapshotShard `json:"shards"`
+}
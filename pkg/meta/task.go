@@ -0,0 +1,65 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+// TaskStatus is the progress of a task that walks the documents by batches
+type TaskStatus struct {
+	Total             int64   `json:"total"`
+	Created           int64   `json:"created"`
+	Updated           int64   `json:"updated"`
+	Deleted           int64   `json:"deleted"`
+	Failed            int64   `json:"failed"`
+	Batches           int64   `json:"batches"`
+	VersionConflicts  int64   `json:"version_conflicts"`
+	Noops             int64   `json:"noops"`
+	ThrottledMillis   int64   `json:"throttled_millis"`
+	RequestsPerSecond float64 `json:"requests_per_second"` // -1 means unlimited
+}
+
+type TaskInfo struct {
+	Node               string      `json:"node"`
+	ID                 string      `json:"id"`
+	Type               string      `json:"type"`
+	Action             string      `json:"action"`
+	Description        string      `json:"description"`
+	StartTimeInMillis  int64       `json:"start_time_in_millis"`
+	RunningTimeInNanos int64       `json:"running_time_in_nanos"`
+	Cancellable        bool        `json:"cancellable"`
+	Cancelled          bool        `json:"cancelled"`
+	Status             *TaskStatus `json:"status,omitempty"`
+}
+
+// TaskResult is the state of a task, the response or the error is set when it is completed
+type TaskResult struct {
+	Completed bool        `json:"completed"`
+	Task      TaskInfo    `json:"task"`
+	Response  interface{} `json:"response,omitempty"`
+	Error     *TaskError  `json:"error,omitempty"`
+}
+
+type TaskError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+type TaskListResponse struct {
+	Tasks map[string]*TaskInfo `json:"tasks"`
+}
+
+// TaskStartedResponse is returned instead of the response when the request doesn't wait for the completion
+type TaskStartedResponse struct {
+	Task string `json:"task"`
+}
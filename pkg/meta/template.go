@@ -23,11 +23,12 @@ type Template struct {
 }
 
 type IndexTemplate struct {
-	IndexPatterns []string         `json:"index_patterns"`
-	Priority      int              `json:"priority"` // highest priority is chosen
-	Template      TemplateTemplate `json:"template"`
-	CreatedAt     time.Time        `json:"created_at"`
-	UpdatedAt     time.Time        `json:"updated_at"`
+	IndexPatterns []string            `json:"index_patterns"`
+	Priority      int                 `json:"priority"` // highest priority is chosen
+	Template      TemplateTemplate    `json:"template"`
+	DataStream    *DataStreamTemplate `json:"data_stream,omitempty"` // the matching names are created as data streams
+	CreatedAt     time.Time           `json:"created_at"`
+	UpdatedAt     time.Time           `json:"updated_at"`
 }
 
 type TemplateTemplate struct {
@@ -17,13 +17,18 @@ package meta
 
 // Default field name
 const (
-	TimeFieldName   = "@timestamp"
-	IDFieldName     = "@_id"
-	ActionFieldName = "@_action"
-	ShardFieldName  = "@_shard"
-	SourceFieldName = "@_source"
+	TimeFieldName    = "@timestamp"
+	IDFieldName      = "@_id"
+	ActionFieldName  = "@_action"
+	ShardFieldName   = "@_shard"
+	SourceFieldName  = "@_source"
+	VersionFieldName = "@_version"
+	SeqNoFieldName   = "@_seq_no"
 )
 
+// FieldNamesFieldName indexes the names of the fields present in a document
+const FieldNamesFieldName = "_field_names"
+
 const (
 	ActionTypeInsert = "insert"
 	ActionTypeUpdate = "update"
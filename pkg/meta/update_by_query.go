@@ -0,0 +1,39 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package meta
+
+const (
+	UpdateByQueryOpIndex  = "index"
+	UpdateByQueryOpNoop   = "noop"
+	UpdateByQueryOpDelete = "delete"
+)
+
+type UpdateByQueryRequest struct {
+	Query      interface{}            `json:"query"`     // update all the documents by default
+	Doc        map[string]interface{} `json:"doc"`       // merged into the matched documents
+	Script     interface{}            `json:"script"`    // a string or {"source": "...", "params": {...}}, ctx._source is the document
+	MaxDocs    int64                  `json:"max_docs"`  // update all the matched documents by default
+	Conflicts  string                 `json:"conflicts"` // abort or proceed, abort by default
+	ScrollSize int                    `json:"-"`         // documents per batch, 1000 by default
+}
+
+type UpdateByQueryResponse struct {
+	Took     int64 `json:"took"`
+	TimedOut bool  `json:"timed_out"`
+	TaskStatus
+	Failures []ReindexFailure `json:"failures"`
+	Canceled string           `json:"canceled,omitempty"`
+}
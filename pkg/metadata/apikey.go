@@ -0,0 +1,67 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package metadata
+
+import (
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type apiKey struct{}
+
+var APIKey = new(apiKey)
+
+func (t *apiKey) List(offset, limit int) ([]*meta.APIKey, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*meta.APIKey, 0, len(data))
+	for _, d := range data {
+		k := new(meta.APIKey)
+		err = json.Unmarshal(d, k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (t *apiKey) Get(id string) (*meta.APIKey, error) {
+	data, err := db.Get(t.key(id))
+	if err != nil {
+		return nil, err
+	}
+	k := new(meta.APIKey)
+	err = json.Unmarshal(data, k)
+	return k, err
+}
+
+func (t *apiKey) Set(id string, val meta.APIKey) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(id), data)
+}
+
+func (t *apiKey) Delete(id string) error {
+	return db.Delete(t.key(id))
+}
+
+func (t *apiKey) key(id string) string {
+	return "/apikey/" + id
+}
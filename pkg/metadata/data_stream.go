@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type dataStream struct{}
+
+var DataStream = new(dataStream)
+
+func (t *dataStream) List(offset, limit int) ([]*meta.DataStream, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	dataStreams := make([]*meta.DataStream, 0, len(data))
+	for _, d := range data {
+		p := new(meta.DataStream)
+		err = json.Unmarshal(d, p)
+		if err != nil {
+			return nil, err
+		}
+		dataStreams = append(dataStreams, p)
+	}
+	return dataStreams, nil
+}
+
+func (t *dataStream) Get(name string) (*meta.DataStream, error) {
+	data, err := db.Get(t.key(name))
+	if err != nil {
+		return nil, err
+	}
+	p := new(meta.DataStream)
+	err = json.Unmarshal(data, p)
+	return p, err
+}
+
+func (t *dataStream) Set(name string, val meta.DataStream) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(name), data)
+}
+
+func (t *dataStream) Delete(name string) error {
+	return db.Delete(t.key(name))
+}
+
+func (t *dataStream) key(name string) string {
+	return "/data_stream/" + name
+}
@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type lifecyclePolicy struct{}
+
+var LifecyclePolicy = new(lifecyclePolicy)
+
+func (t *lifecyclePolicy) List(offset, limit int) ([]*meta.LifecyclePolicy, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	policies := make([]*meta.LifecyclePolicy, 0, len(data))
+	for _, d := range data {
+		p := new(meta.LifecyclePolicy)
+		err = json.Unmarshal(d, p)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+func (t *lifecyclePolicy) Get(name string) (*meta.LifecyclePolicy, error) {
+	data, err := db.Get(t.key(name))
+	if err != nil {
+		return nil, err
+	}
+	p := new(meta.LifecyclePolicy)
+	err = json.Unmarshal(data, p)
+	return p, err
+}
+
+func (t *lifecyclePolicy) Set(name string, val meta.LifecyclePolicy) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(name), data)
+}
+
+func (t *lifecyclePolicy) Delete(name string) error {
+	return db.Delete(t.key(name))
+}
+
+func (t *lifecyclePolicy) key(name string) string {
+	return "/lifecycle_policy/" + name
+}
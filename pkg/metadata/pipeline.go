@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type pipeline struct{}
+
+var Pipeline = new(pipeline)
+
+func (t *pipeline) List(offset, limit int) ([]*meta.Pipeline, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	pipelines := make([]*meta.Pipeline, 0, len(data))
+	for _, d := range data {
+		p := new(meta.Pipeline)
+		err = json.Unmarshal(d, p)
+		if err != nil {
+			return nil, err
+		}
+		pipelines = append(pipelines, p)
+	}
+	return pipelines, nil
+}
+
+func (t *pipeline) Get(name string) (*meta.Pipeline, error) {
+	data, err := db.Get(t.key(name))
+	if err != nil {
+		return nil, err
+	}
+	p := new(meta.Pipeline)
+	err = json.Unmarshal(data, p)
+	return p, err
+}
+
+func (t *pipeline) Set(name string, val meta.Pipeline) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(name), data)
+}
+
+func (t *pipeline) Delete(name string) error {
+	return db.Delete(t.key(name))
+}
+
+func (t *pipeline) key(name string) string {
+	return "/ingest_pipeline/" + name
+}
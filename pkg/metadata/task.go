@@ -0,0 +1,68 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package metadata
+
+import (
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+)
+
+type task struct{}
+
+var Task = new(task)
+
+func (t *task) List(offset, limit int) ([]*meta.TaskResult, error) {
+	data, err := db.List(t.key(""), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*meta.TaskResult, 0, len(data))
+	for _, d := range data {
+		p := new(meta.TaskResult)
+		err = json.Unmarshal(d, p)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, p)
+	}
+	return tasks, nil
+}
+
+func (t *task) Get(id string) (*meta.TaskResult, error) {
+	data, err := db.Get(t.key(id))
+	if err != nil {
+		return nil, err
+	}
+	p := new(meta.TaskResult)
+	err = json.Unmarshal(data, p)
+	return p, err
+}
+
+func (t *task) Set(id string, val meta.TaskResult) error {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	return db.Set(t.key(id), data)
+}
+
+func (t *task) Delete(id string) error {
+	return db.Delete(t.key(id))
+}
+
+func (t *task) key(id string) string {
+	return "/task/" + id
+}
@@ -16,38 +16,88 @@
 package routes
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/zinclabs/zincsearch/pkg/auth"
+	"github.com/zinclabs/zincsearch/pkg/config"
 	"github.com/zinclabs/zincsearch/pkg/core"
+	"github.com/zinclabs/zincsearch/pkg/handlers/document"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils/json"
 )
 
 func AuthMiddleware(permission string) func(c *gin.Context) {
 	auth.AddPermission(permission)
 	return func(c *gin.Context) {
-		c.Next()
-		return
-		// Get the Basic Authentication credentials
-		user, password, hasAuth := c.Request.BasicAuth()
-		if hasAuth {
-			if u, ok := auth.VerifyCredentials(user, password); ok {
-				if auth.VerifyRoleHasPermission(u.Role, permission) {
-					c.Next()
-				} else {
-					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No permission:" + permission})
-					return
+		userID, role, errMsg := authenticate(c)
+		if errMsg != "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"auth": errMsg})
+			return
+		}
+		if !auth.VerifyRoleHasPermission(role, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No permission:" + permission})
+			return
+		}
+		if auth.NeedVerifyIndexPermission(role, permission) {
+			indexNames, err := requestIndexNames(c, permission)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if name, ok := auth.VerifyRoleHasIndexPermission(role, permission, indexNames); !ok {
+				if name == "" {
+					name = "*"
 				}
-			} else {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"auth": "Invalid credentials"})
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "No permission:" + permission + " on index:" + name})
 				return
 			}
-		} else {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"auth": "Missing credentials"})
-			return
 		}
+		c.Set(auth.ContextKeyUserID, userID)
+		c.Set(auth.ContextKeyRole, role)
+		c.Next()
+	}
+}
+
+// authenticate checks the credentials of the Authorization header, it supports:
+// Basic base64(user:password), ApiKey base64(id:secret), Bearer base64(id:secret) and Bearer session token
+func authenticate(c *gin.Context) (userID, role, errMsg string) {
+	scheme, credential, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	credential = strings.TrimSpace(credential)
+	switch strings.ToLower(scheme) {
+	case "":
+		return "", "", "Missing credentials"
+	case "basic":
+		user, password, ok := c.Request.BasicAuth()
+		if !ok {
+			return "", "", "Invalid credentials"
+		}
+		u, ok := auth.VerifyCredentials(user, password)
+		if !ok {
+			return "", "", "Invalid credentials"
+		}
+		return u.ID, u.Role, ""
+	case "bearer":
+		if strings.Contains(credential, ".") {
+			u, ok := auth.VerifySessionToken(credential, config.GetConfig(c).SessionSecret)
+			if !ok {
+				return "", "", "Invalid session token"
+			}
+			return u.ID, u.Role, ""
+		}
+		fallthrough
+	case "apikey":
+		key, ok := auth.VerifyAPIKey(credential)
+		if !ok {
+			return "", "", "Invalid api key"
+		}
+		return key.CreatedBy, key.Role, ""
+	default:
+		return "", "", "Unsupported authorization scheme: " + scheme
 	}
 }
 
@@ -87,3 +137,186 @@ func IndexAliasMiddleware(c *gin.Context) {
 	}
 	c.Next()
 }
+
+// requestIndexNames returns the indexes the request will access, aliases are expanded to their indexes
+// like IndexAliasMiddleware does. An empty name means all the indexes.
+func requestIndexNames(c *gin.Context, permission string) ([]string, error) {
+	target := c.Param("target")
+	switch permission {
+	case "document.Bulk", "document.ESBulk", "search.MultipleSearch":
+		body, err := peekRequestBody(c)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		switch {
+		case permission == "search.MultipleSearch":
+			names = msearchIndexNames(target, body)
+		case strings.HasSuffix(c.FullPath(), "_bulkv2"):
+			names = []string{target}
+			if target == "" {
+				data := struct {
+					Index string `json:"index"`
+				}{}
+				_ = json.Unmarshal(body, &data)
+				names = []string{data.Index}
+			}
+		default:
+			names = document.BulkIndexNames(target, body)
+		}
+		return resolveIndexNames(names), nil
+	case "document.Reindex":
+		// reindex needs the write privilege on the sources too, the permission has only one privilege
+		body, err := peekRequestBody(c)
+		if err != nil {
+			return nil, err
+		}
+		return resolveIndexNames(reindexIndexNames(body)), nil
+	case "search.Scroll", "search.ClosePIT":
+		// the indexes are checked when the scroll or point in time is opened
+		return nil, nil
+	case "search.SearchDSL":
+		if target != "" {
+			break
+		}
+		// searching a point in time uses the indexes pinned by it
+		body, err := peekRequestBody(c)
+		if err != nil {
+			return nil, err
+		}
+		data := struct {
+			PIT *meta.PointInTime `json:"pit"`
+		}{}
+		_ = json.Unmarshal(body, &data)
+		if data.PIT != nil {
+			if names, ok := core.ZINC_PIT_LIST.IndexNames(data.PIT.ID); ok {
+				return names, nil
+			}
+		}
+	case "index.Analyze":
+		// analyze without an index only uses the builtin analyzers
+		if target == "" {
+			return nil, nil
+		}
+	}
+	return resolveIndexNames([]string{target}), nil
+}
+
+// resolveIndexNames splits the comma separated names and expands the aliases like core.ResolveIndexNames,
+// the exclusions are skipped as they never grant access to more indexes. The backing indexes of the data
+// streams are returned as their data stream, the grants of a data stream cover its backing indexes.
+func resolveIndexNames(targets []string) []string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if target == "" {
+			names = append(names, "")
+			continue
+		}
+		for i, name := range strings.Split(target, ",") {
+			name = strings.TrimSpace(name)
+			if len(name) > 1 && name[0] == '-' {
+				if i == 0 {
+					// a leading exclusion excludes from all the indexes
+					names = append(names, "")
+				}
+				continue
+			}
+			if name == "_all" {
+				name = ""
+			}
+			if indexes, ok := core.ZINC_INDEX_ALIAS_LIST.GetIndexesForAlias(name); ok {
+				names = append(names, indexes...)
+				continue
+			}
+			if strings.ContainsAny(name, "*?") {
+				// a pattern matches the aliases too, their indexes need the permission as well
+				names = append(names, core.ZINC_INDEX_ALIAS_LIST.GetIndexesForAliasPattern(name)...)
+			}
+			names = append(names, name)
+		}
+	}
+	for i, name := range names {
+		names[i] = dataStreamName(name)
+	}
+	return names
+}
+
+// dataStreamName returns the data stream of a backing index, other names are returned as they are
+func dataStreamName(name string) string {
+	if index, ok := core.GetIndex(name); ok {
+		if stream := index.GetDataStream(); stream != "" {
+			return stream
+		}
+	}
+	return name
+}
+
+// peekRequestBody reads the body and puts it back for the handler
+func peekRequestBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
+
+// msearchIndexNames returns the indexes of the header lines in a _msearch body
+func msearchIndexNames(target string, body []byte) []string {
+	names := []string{}
+	nextLineIsData := false
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if nextLineIsData {
+			nextLineIsData = false
+			continue
+		}
+		nextLineIsData = true
+		var doc map[string]interface{}
+		if err := json.Unmarshal(line, &doc); err != nil {
+			continue
+		}
+		switch v := doc["index"].(type) {
+		case string:
+			names = append(names, v)
+		case []interface{}:
+			for _, v := range v {
+				if v, ok := v.(string); ok {
+					names = append(names, v)
+				}
+			}
+		default:
+			names = append(names, target)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, target)
+	}
+	return names
+}
+
+// reindexIndexNames returns the source and the destination indexes of a _reindex body
+func reindexIndexNames(body []byte) []string {
+	data := struct {
+		Source struct {
+			Index interface{} `json:"index"`
+		} `json:"source"`
+		Dest struct {
+			Index string `json:"index"`
+		} `json:"dest"`
+	}{}
+	_ = json.Unmarshal(body, &data)
+	names := []string{data.Dest.Index}
+	switch v := data.Source.Index.(type) {
+	case string:
+		names = append(names, v)
+	case []interface{}:
+		for _, v := range v {
+			if v, ok := v.(string); ok {
+				names = append(names, v)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,88 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/test/utils"
+)
+
+func TestRequestIndexNames(t *testing.T) {
+	tests := []struct {
+		name       string
+		permission string
+		target     string
+		body       string
+		want       []string
+	}{
+		{
+			name:       "bulk",
+			permission: "document.ESBulk",
+			target:     "app",
+			body: `{"index": {"_id": "1"}}
+{"a": 1}
+{"delete": {"_index": "logs", "_id": "1"}}
+{"create": {"_index": "other", "_id": "2"}}
+{"a": 2}
+`,
+			want: []string{"app", "logs", "other"},
+		},
+		{
+			name:       "bulk action after an unknown action",
+			permission: "document.ESBulk",
+			target:     "app",
+			body: `{"foo": {}}
+{"index": {"_index": "secret"}}
+{"a": 1}
+`,
+			want: []string{"secret"},
+		},
+		{
+			name:       "bulk source of a bad action",
+			permission: "document.ESBulk",
+			target:     "app",
+			body: `{"foo": {}}
+{"a": 1}
+{"index": {"_id": "1"}}
+{"a": 1}
+`,
+			want: []string{"app"},
+		},
+		{
+			name:       "msearch",
+			permission: "search.MultipleSearch",
+			body: `{"index": "logs"}
+{"query": {"match_all": {}}}
+{"index": ["app", "other"]}
+{"query": {"match_all": {}}}
+`,
+			want: []string{"logs", "app", "other"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := utils.NewGinContext()
+			utils.SetGinRequestData(c, tt.body)
+			utils.SetGinRequestParams(c, map[string]string{"target": tt.target})
+			got, err := requestIndexNames(c, tt.permission)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -30,9 +30,14 @@ import (
 
 	"github.com/zinclabs/zincsearch"
 	"github.com/zinclabs/zincsearch/pkg/handlers/auth"
+	"github.com/zinclabs/zincsearch/pkg/handlers/datastream"
 	"github.com/zinclabs/zincsearch/pkg/handlers/document"
 	"github.com/zinclabs/zincsearch/pkg/handlers/index"
+	"github.com/zinclabs/zincsearch/pkg/handlers/ingest"
+	"github.com/zinclabs/zincsearch/pkg/handlers/lifecycle"
 	"github.com/zinclabs/zincsearch/pkg/handlers/search"
+	"github.com/zinclabs/zincsearch/pkg/handlers/snapshot"
+	"github.com/zinclabs/zincsearch/pkg/handlers/task"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/meta/elastic"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
@@ -93,6 +98,9 @@ func SetRoutes(r *gin.Engine) {
 	r.POST("/api/role", AuthMiddleware("auth.CreateUpdateRole"), auth.CreateUpdateRole)
 	r.PUT("/api/role", AuthMiddleware("auth.CreateUpdateRole"), auth.CreateUpdateRole)
 	r.DELETE("/api/role/:id", AuthMiddleware("auth.DeleteRole"), auth.DeleteRole)
+	r.GET("/api/apikey", AuthMiddleware("auth.ListAPIKey"), auth.ListAPIKey)
+	r.POST("/api/apikey", AuthMiddleware("auth.CreateAPIKey"), auth.CreateAPIKey)
+	r.DELETE("/api/apikey/:id", AuthMiddleware("auth.DeleteAPIKey"), auth.DeleteAPIKey)
 
 	// index
 	r.GET("/api/index", AuthMiddleware("index.List"), index.List)
@@ -151,9 +159,28 @@ func SetRoutes(r *gin.Engine) {
 
 	r.POST("/es/_search", AuthMiddleware("search.SearchDSL"), ESMiddleware, IndexAliasMiddleware, search.SearchDSL)
 	r.POST("/es/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
+	r.GET("/es/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
+	r.POST("/es/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
+	r.GET("/es/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
+	r.POST("/es/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
+	r.GET("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.GET("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.POST("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.Scroll)
+	r.DELETE("/es/_search/scroll", AuthMiddleware("search.Scroll"), ESMiddleware, search.ClearScroll)
+	r.DELETE("/es/_search/scroll/:scroll_id", AuthMiddleware("search.Scroll"), ESMiddleware, search.ClearScroll)
+	r.DELETE("/es/_pit", AuthMiddleware("search.ClosePIT"), ESMiddleware, search.ClosePIT)
+	r.POST("/es/:target/_pit", AuthMiddleware("search.OpenPIT"), ESMiddleware, IndexAliasMiddleware, search.OpenPIT)
 	r.POST("/es/:target/_search", AuthMiddleware("search.SearchDSL"), ESMiddleware, IndexAliasMiddleware, search.SearchDSL)
 	r.POST("/es/:target/_msearch", AuthMiddleware("search.MultipleSearch"), ESMiddleware, IndexAliasMiddleware, search.MultipleSearch)
+	r.GET("/es/:target/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
+	r.POST("/es/:target/_count", AuthMiddleware("search.Count"), ESMiddleware, IndexAliasMiddleware, search.Count)
+	r.GET("/es/:target/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
+	r.POST("/es/:target/_validate/query", AuthMiddleware("search.ValidateQuery"), ESMiddleware, IndexAliasMiddleware, search.ValidateQuery)
+	r.GET("/es/:target/_explain/:id", AuthMiddleware("search.Explain"), ESMiddleware, search.Explain)
+	r.POST("/es/:target/_explain/:id", AuthMiddleware("search.Explain"), ESMiddleware, search.Explain)
 	r.POST("/es/:target/_delete_by_query", AuthMiddleware("search.DeleteByQuery"), IndexAliasMiddleware, search.DeleteByQuery)
+	r.POST("/es/:target/_update_by_query", AuthMiddleware("document.UpdateByQuery"), ESMiddleware, document.UpdateByQuery)
 
 	r.GET("/es/_index_template", AuthMiddleware("index.ListTemplate"), ESMiddleware, index.ListTemplate)
 	r.POST("/es/_index_template", AuthMiddleware("index.CreateTemplate"), ESMiddleware, index.CreateTemplate)
@@ -161,10 +188,48 @@ func SetRoutes(r *gin.Engine) {
 	r.GET("/es/_index_template/:target", AuthMiddleware("index.GetTemplate"), ESMiddleware, index.GetTemplate)
 	r.HEAD("/es/_index_template/:target", AuthMiddleware("index.GetTemplate"), ESMiddleware, index.GetTemplate)
 	r.DELETE("/es/_index_template/:target", AuthMiddleware("index.DeleteTemplate"), ESMiddleware, index.DeleteTemplate)
+	// ES Compatible ingest pipeline
+	r.GET("/es/_ingest/pipeline", AuthMiddleware("ingest.GetPipeline"), ESMiddleware, ingest.GetPipeline)
+	r.GET("/es/_ingest/pipeline/:id", AuthMiddleware("ingest.GetPipeline"), ESMiddleware, ingest.GetPipeline)
+	r.PUT("/es/_ingest/pipeline/:id", AuthMiddleware("ingest.PutPipeline"), ESMiddleware, ingest.PutPipeline)
+	r.DELETE("/es/_ingest/pipeline/:id", AuthMiddleware("ingest.DeletePipeline"), ESMiddleware, ingest.DeletePipeline)
+	r.GET("/es/_ingest/pipeline/_simulate", AuthMiddleware("ingest.SimulatePipeline"), ESMiddleware, ingest.SimulatePipeline)
+	r.POST("/es/_ingest/pipeline/_simulate", AuthMiddleware("ingest.SimulatePipeline"), ESMiddleware, ingest.SimulatePipeline)
+	r.GET("/es/_ingest/pipeline/:id/_simulate", AuthMiddleware("ingest.SimulatePipeline"), ESMiddleware, ingest.SimulatePipeline)
+	r.POST("/es/_ingest/pipeline/:id/_simulate", AuthMiddleware("ingest.SimulatePipeline"), ESMiddleware, ingest.SimulatePipeline)
+	// ES Compatible snapshot
+	r.GET("/es/_snapshot", AuthMiddleware("snapshot.GetRepository"), ESMiddleware, snapshot.GetRepository)
+	r.GET("/es/_snapshot/:repository", AuthMiddleware("snapshot.GetRepository"), ESMiddleware, snapshot.GetRepository)
+	r.PUT("/es/_snapshot/:repository", AuthMiddleware("snapshot.PutRepository"), ESMiddleware, snapshot.PutRepository)
+	r.POST("/es/_snapshot/:repository", AuthMiddleware("snapshot.PutRepository"), ESMiddleware, snapshot.PutRepository)
+	r.DELETE("/es/_snapshot/:repository", AuthMiddleware("snapshot.DeleteRepository"), ESMiddleware, snapshot.DeleteRepository)
+	r.GET("/es/_snapshot/:repository/:snapshot", AuthMiddleware("snapshot.GetSnapshot"), ESMiddleware, snapshot.GetSnapshot)
+	r.PUT("/es/_snapshot/:repository/:snapshot", AuthMiddleware("snapshot.CreateSnapshot"), ESMiddleware, snapshot.CreateSnapshot)
+	r.POST("/es/_snapshot/:repository/:snapshot", AuthMiddleware("snapshot.CreateSnapshot"), ESMiddleware, snapshot.CreateSnapshot)
+	r.DELETE("/es/_snapshot/:repository/:snapshot", AuthMiddleware("snapshot.DeleteSnapshot"), ESMiddleware, snapshot.DeleteSnapshot)
+	r.POST("/es/_snapshot/:repository/:snapshot/_restore", AuthMiddleware("snapshot.RestoreSnapshot"), ESMiddleware, snapshot.RestoreSnapshot)
+	// ES Compatible index lifecycle
+	r.GET("/es/_ilm/policy", AuthMiddleware("lifecycle.GetPolicy"), ESMiddleware, lifecycle.GetPolicy)
+	r.GET("/es/_ilm/policy/:id", AuthMiddleware("lifecycle.GetPolicy"), ESMiddleware, lifecycle.GetPolicy)
+	r.PUT("/es/_ilm/policy/:id", AuthMiddleware("lifecycle.PutPolicy"), ESMiddleware, lifecycle.PutPolicy)
+	r.DELETE("/es/_ilm/policy/:id", AuthMiddleware("lifecycle.DeletePolicy"), ESMiddleware, lifecycle.DeletePolicy)
+	r.GET("/es/:target/_ilm/explain", AuthMiddleware("lifecycle.Explain"), ESMiddleware, lifecycle.Explain)
+	r.POST("/es/:target/_ilm/remove", AuthMiddleware("lifecycle.Remove"), ESMiddleware, lifecycle.Remove)
+	r.POST("/es/:target/_rollover", AuthMiddleware("lifecycle.Rollover"), ESMiddleware, lifecycle.Rollover)
 	// ES Compatible data stream
-	r.PUT("/es/_data_stream/:target", AuthMiddleware("elastic.PutDataStream"), ESMiddleware, elastic.PutDataStream)
-	r.GET("/es/_data_stream/:target", AuthMiddleware("elastic.GetDataStream"), ESMiddleware, elastic.GetDataStream)
-	r.HEAD("/es/_data_stream/:target", AuthMiddleware("elastic.GetDataStream"), ESMiddleware, elastic.GetDataStream)
+	r.GET("/es/_data_stream", AuthMiddleware("datastream.GetDataStream"), ESMiddleware, datastream.GetDataStream)
+	r.GET("/es/_data_stream/_stats", AuthMiddleware("datastream.DataStreamStats"), ESMiddleware, datastream.DataStreamStats)
+	r.PUT("/es/_data_stream/:target", AuthMiddleware("datastream.PutDataStream"), ESMiddleware, datastream.PutDataStream)
+	r.GET("/es/_data_stream/:target", AuthMiddleware("datastream.GetDataStream"), ESMiddleware, datastream.GetDataStream)
+	r.HEAD("/es/_data_stream/:target", AuthMiddleware("datastream.GetDataStream"), ESMiddleware, datastream.GetDataStream)
+	r.DELETE("/es/_data_stream/:target", AuthMiddleware("datastream.DeleteDataStream"), ESMiddleware, datastream.DeleteDataStream)
+	r.GET("/es/_data_stream/:target/_stats", AuthMiddleware("datastream.DataStreamStats"), ESMiddleware, datastream.DataStreamStats)
+	// ES Compatible reindex and tasks
+	r.POST("/es/_reindex", AuthMiddleware("document.Reindex"), ESMiddleware, document.Reindex)
+	r.GET("/es/_tasks", AuthMiddleware("task.ListTasks"), ESMiddleware, task.ListTasks)
+	r.GET("/es/_tasks/:task_id", AuthMiddleware("task.GetTask"), ESMiddleware, task.GetTask)
+	r.POST("/es/_tasks/_cancel", AuthMiddleware("task.CancelTasks"), ESMiddleware, task.CancelTasks)
+	r.POST("/es/_tasks/:task_id/_cancel", AuthMiddleware("task.CancelTask"), ESMiddleware, task.CancelTask)
 
 	r.PUT("/es/:target", AuthMiddleware("index.CreateES"), ESMiddleware, index.CreateES)
 	r.HEAD("/es/:target", AuthMiddleware("index.Exists"), ESMiddleware, index.Exists)
@@ -192,6 +257,8 @@ func SetRoutes(r *gin.Engine) {
 	// ES Document
 	r.POST("/es/:target/_doc", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate)        // create
 	r.PUT("/es/:target/_doc/:id", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate)     // create or update
+	r.HEAD("/es/:target/_doc/:id", AuthMiddleware("document.Get"), ESMiddleware, document.Get)                      // get
+	r.GET("/es/:target/_doc/:id", AuthMiddleware("document.Get"), ESMiddleware, document.Get)                       // get
 	r.PUT("/es/:target/_create/:id", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate)  // create
 	r.POST("/es/:target/_create/:id", AuthMiddleware("document.CreateUpdate"), ESMiddleware, document.CreateUpdate) // create
 	r.POST("/es/:target/_update/:id", AuthMiddleware("document.Update"), ESMiddleware, document.Update)             // update part of document
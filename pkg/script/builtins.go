@@ -0,0 +1,67 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package script
+
+import (
+	"fmt"
+	"math"
+)
+
+// builtins are the global names, variables passed to Run shadow them
+var builtins = map[string]interface{}{
+	"Math": map[string]interface{}{
+		"E":     math.E,
+		"PI":    math.Pi,
+		"abs":   mathFunc1("abs", math.Abs),
+		"sqrt":  mathFunc1("sqrt", math.Sqrt),
+		"log":   mathFunc1("log", math.Log),
+		"log10": mathFunc1("log10", math.Log10),
+		"exp":   mathFunc1("exp", math.Exp),
+		"floor": mathFunc1("floor", math.Floor),
+		"ceil":  mathFunc1("ceil", math.Ceil),
+		"round": mathFunc1("round", math.Round),
+		"pow":   mathFunc2("pow", math.Pow),
+		"max":   mathFunc2("max", math.Max),
+		"min":   mathFunc2("min", math.Min),
+	},
+}
+
+func mathFunc1(name string, fn func(float64) float64) Func {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("Math.%s expects 1 argument but got %d", name, len(args))
+		}
+		v, ok := ToFloat(args[0])
+		if !ok {
+			return nil, fmt.Errorf("Math.%s expects a number but got [%v]", name, args[0])
+		}
+		return fn(v), nil
+	}
+}
+
+func mathFunc2(name string, fn func(float64, float64) float64) Func {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("Math.%s expects 2 arguments but got %d", name, len(args))
+		}
+		a, aok := ToFloat(args[0])
+		b, bok := ToFloat(args[1])
+		if !aok || !bok {
+			return nil, fmt.Errorf("Math.%s expects numbers but got [%v] and [%v]", name, args[0], args[1])
+		}
+		return fn(a, b), nil
+	}
+}
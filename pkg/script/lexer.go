@@ -0,0 +1,131 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package script
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	num   float64
+	start int
+}
+
+// punctuations sorted by length, the longest one matches first
+var punctuations = []string{
+	"===", "!==",
+	"==", "!=", "<=", ">=", "&&", "||", "+=", "-=", "*=", "/=", "%=", "++", "--",
+	"+", "-", "*", "/", "%", "<", ">", "!", "=", "?", ":", "(", ")", "[", "]", "{", "}", ".", ",", ";",
+}
+
+func tokenize(source string) ([]token, error) {
+	tokens := make([]token, 0, len(source)/2)
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(source) && source[i+1] == '/':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case isDigit(c) || (c == '.' && i+1 < len(source) && isDigit(source[i+1])):
+			j := i
+			for j < len(source) && (isDigit(source[j]) || source[j] == '.' || source[j] == 'e' || source[j] == 'E' ||
+				((source[j] == '+' || source[j] == '-') && (source[j-1] == 'e' || source[j-1] == 'E'))) {
+				j++
+			}
+			text := source[i:j]
+			// painless number suffixes: 1L, 1.0d, 1.0f
+			if j < len(source) && strings.ContainsRune("lLdDfF", rune(source[j])) {
+				j++
+			}
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number [%s] at %d", text, i)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, num: num, start: i})
+			i = j
+		case c == '\'' || c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(source) && source[j] != c; j++ {
+				if source[j] == '\\' && j+1 < len(source) {
+					j++
+					switch source[j] {
+					case 'n':
+						sb.WriteByte('\n')
+					case 't':
+						sb.WriteByte('\t')
+					default:
+						sb.WriteByte(source[j])
+					}
+					continue
+				}
+				sb.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: sb.String(), start: i})
+			i = j + 1
+		case isIdentStart(c):
+			j := i
+			for j < len(source) && (isIdentStart(source[j]) || isDigit(source[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[i:j], start: i})
+			i = j
+		default:
+			matched := false
+			for _, p := range punctuations {
+				if strings.HasPrefix(source[i:], p) {
+					tokens = append(tokens, token{kind: tokenPunct, text: p, start: i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character [%c] at %d", c, i)
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, start: len(source)})
+	return tokens, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
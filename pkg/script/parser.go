@@ -0,0 +1,471 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package script
+
+import (
+	"fmt"
+)
+
+type node interface{}
+
+type (
+	literalNode struct{ value interface{} }
+	identNode   struct{ name string }
+	memberNode  struct {
+		target node
+		name   string
+	}
+	indexNode struct {
+		target node
+		index  node
+	}
+	callNode struct {
+		fn   node
+		args []node
+	}
+	unaryNode struct {
+		op      string
+		operand node
+	}
+	binaryNode struct {
+		op          string
+		left, right node
+	}
+	ternaryNode struct {
+		cond, then, els node
+	}
+	listNode struct{ items []node }
+	mapNode  struct {
+		keys   []node
+		values []node
+	}
+	// incDecNode is ++ or -- on an assignable target, prefix returns the new value
+	incDecNode struct {
+		op     string
+		target node
+		prefix bool
+	}
+	assignNode struct {
+		op     string // =, +=, -=, *=, /=, %=
+		target node
+		value  node
+	}
+	defNode struct {
+		name  string
+		value node
+	}
+	ifNode struct {
+		cond node
+		then []node
+		els  []node
+	}
+	returnNode struct{ value node }
+)
+
+// binary operators precedence, higher binds tighter
+var precedences = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "===": 3, "!==": 3,
+	"<": 4, "<=": 4, ">": 4, ">=": 4,
+	"+": 5, "-": 5,
+	"*": 6, "/": 6, "%": 6,
+}
+
+var assignOps = map[string]bool{"=": true, "+=": true, "-=": true, "*=": true, "/=": true, "%=": true}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(text string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.text == text
+}
+
+func (p *parser) isKeyword(text string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && t.text == text
+}
+
+func (p *parser) expect(text string) error {
+	t := p.next()
+	if t.kind != tokenPunct || t.text != text {
+		return p.errorf(t, "expected [%s]", text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	found := t.text
+	if t.kind == tokenEOF {
+		found = "end of script"
+	}
+	return fmt.Errorf("%s but found [%s] at %d", fmt.Sprintf(format, args...), found, t.start)
+}
+
+func (p *parser) parseStatements(end string) ([]node, error) {
+	var stmts []node
+	for {
+		for p.isPunct(";") {
+			p.next()
+		}
+		t := p.peek()
+		if t.kind == tokenEOF || (end != "" && p.isPunct(end)) {
+			return stmts, nil
+		}
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+		if !p.isPunct(";") && !p.isPunct(end) && p.peek().kind != tokenEOF {
+			if _, ok := stmt.(*ifNode); !ok {
+				return nil, p.errorf(p.peek(), "expected [;]")
+			}
+		}
+	}
+}
+
+func (p *parser) parseStatement() (node, error) {
+	switch {
+	case p.isKeyword("if"):
+		return p.parseIf()
+	case p.isKeyword("return"):
+		p.next()
+		if p.isPunct(";") || p.isPunct("}") || p.peek().kind == tokenEOF {
+			return &returnNode{}, nil
+		}
+		value, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &returnNode{value: value}, nil
+	case p.isKeyword("def") || p.isKeyword("var"):
+		p.next()
+		t := p.next()
+		if t.kind != tokenIdent {
+			return nil, p.errorf(t, "expected variable name")
+		}
+		stmt := &defNode{name: t.text}
+		if p.isPunct("=") {
+			p.next()
+			value, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			stmt.value = value
+		}
+		return stmt, nil
+	}
+
+	expr, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind == tokenPunct && assignOps[t.text] {
+		p.next()
+		if !assignable(expr) {
+			return nil, p.errorf(t, "invalid assignment target")
+		}
+		value, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		return &assignNode{op: t.text, target: expr, value: value}, nil
+	}
+	return expr, nil
+}
+
+func (p *parser) parseIf() (node, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(")"); err != nil {
+		return nil, err
+	}
+	stmt := &ifNode{cond: cond}
+	if stmt.then, err = p.parseBlock(); err != nil {
+		return nil, err
+	}
+	if p.isKeyword("else") {
+		p.next()
+		if p.isKeyword("if") {
+			elseIf, err := p.parseIf()
+			if err != nil {
+				return nil, err
+			}
+			stmt.els = []node{elseIf}
+		} else if stmt.els, err = p.parseBlock(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseBlock() ([]node, error) {
+	if !p.isPunct("{") {
+		stmt, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		return []node{stmt}, nil
+	}
+	p.next()
+	stmts, err := p.parseStatements("}")
+	if err != nil {
+		return nil, err
+	}
+	return stmts, p.expect("}")
+}
+
+func (p *parser) parseExpression(minPrecedence int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenPunct {
+			return left, nil
+		}
+		if t.text == "?" && minPrecedence == 0 {
+			p.next()
+			then, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			els, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			left = &ternaryNode{cond: left, then: then, els: els}
+			continue
+		}
+		precedence, ok := precedences[t.text]
+		if !ok || precedence <= minPrecedence {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpression(precedence)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: t.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t.kind == tokenPunct {
+		switch t.text {
+		case "!", "-", "+":
+			p.next()
+			operand, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			return &unaryNode{op: t.text, operand: operand}, nil
+		case "++", "--":
+			p.next()
+			target, err := p.parseUnary()
+			if err != nil {
+				return nil, err
+			}
+			if !assignable(target) {
+				return nil, p.errorf(t, "invalid %s target", t.text)
+			}
+			return &incDecNode{op: t.text, target: target, prefix: true}, nil
+		}
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokenPunct {
+			return expr, nil
+		}
+		switch t.text {
+		case ".":
+			p.next()
+			name := p.next()
+			if name.kind != tokenIdent {
+				return nil, p.errorf(name, "expected field name")
+			}
+			expr = &memberNode{target: expr, name: name.text}
+		case "[":
+			p.next()
+			index, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			expr = &indexNode{target: expr, index: index}
+		case "(":
+			p.next()
+			args, err := p.parseList(")")
+			if err != nil {
+				return nil, err
+			}
+			expr = &callNode{fn: expr, args: args}
+		case "++", "--":
+			if !assignable(expr) {
+				return expr, nil
+			}
+			p.next()
+			expr = &incDecNode{op: t.text, target: expr}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return &literalNode{value: t.num}, nil
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		return &identNode{name: t.text}, nil
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			expr, err := p.parseExpression(0)
+			if err != nil {
+				return nil, err
+			}
+			return expr, p.expect(")")
+		case "[":
+			return p.parseCollection()
+		}
+	}
+	return nil, p.errorf(t, "unexpected token")
+}
+
+// parseCollection parses [1, 2] as a list, [:] or ['a': 1] as a map
+func (p *parser) parseCollection() (node, error) {
+	if p.isPunct(":") {
+		p.next()
+		return &mapNode{}, p.expect("]")
+	}
+	if p.isPunct("]") {
+		p.next()
+		return &listNode{}, nil
+	}
+	first, err := p.parseExpression(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isPunct(":") {
+		list := &listNode{items: []node{first}}
+		if p.isPunct(",") {
+			p.next()
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, items...)
+			return list, nil
+		}
+		return list, p.expect("]")
+	}
+
+	m := &mapNode{}
+	key := first
+	for {
+		if err = p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+		if key, err = p.parseExpression(0); err != nil {
+			return nil, err
+		}
+	}
+	return m, p.expect("]")
+}
+
+// parseList parses the comma separated items until the end punctuation
+func (p *parser) parseList(end string) ([]node, error) {
+	var items []node
+	for !p.isPunct(end) {
+		item, err := p.parseExpression(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.isPunct(",") {
+			break
+		}
+		p.next()
+	}
+	return items, p.expect(end)
+}
+
+func assignable(n node) bool {
+	switch n.(type) {
+	case *identNode, *memberNode, *indexNode:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,693 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package script is a small expression language looks like painless, it is safe to run user scripts:
+// there are no loops and no access to anything except the variables passed in.
+//
+//	params.a / params.b
+//	ctx._source.count += 1; if (ctx._source.count > 10) { ctx._source.status = 'hot' }
+package script
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Func is a function can be called in the script
+type Func func(args ...interface{}) (interface{}, error)
+
+type Script struct {
+	source string
+	stmts  []node
+}
+
+// Compile parses the source into a script which can run many times
+func Compile(source string) (*Script, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("script: %s", err.Error())
+	}
+	p := &parser{tokens: tokens}
+	stmts, err := p.parseStatements("")
+	if err != nil {
+		return nil, fmt.Errorf("script: %s", err.Error())
+	}
+	if len(stmts) == 0 {
+		return nil, fmt.Errorf("script: empty script")
+	}
+	return &Script{source: source, stmts: stmts}, nil
+}
+
+// FromRequest compiles the script of a request, it is a string or an object like
+// {"source": "params.a * 2", "params": {"a": 1}}, the params are returned separately.
+func FromRequest(v interface{}) (*Script, map[string]interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		s, err := Compile(v)
+		return s, nil, err
+	case map[string]interface{}:
+		if lang, ok := v["lang"]; ok && lang != "painless" && lang != "" {
+			return nil, nil, fmt.Errorf("script: lang [%v] is not supported", lang)
+		}
+		source, ok := v["source"].(string)
+		if !ok {
+			if source, ok = v["inline"].(string); !ok {
+				return nil, nil, fmt.Errorf("script: source should be a string")
+			}
+		}
+		var params map[string]interface{}
+		if p, ok := v["params"]; ok && p != nil {
+			if params, ok = p.(map[string]interface{}); !ok {
+				return nil, nil, fmt.Errorf("script: params should be an object")
+			}
+		}
+		s, err := Compile(source)
+		return s, params, err
+	case nil:
+		return nil, nil, fmt.Errorf("script: script is required")
+	}
+	return nil, nil, fmt.Errorf("script: script should be a string or an object but got [%v]", v)
+}
+
+func (s *Script) String() string {
+	return s.source
+}
+
+// Run executes the script with the variables, the variables can be modified by the script.
+// It returns the value of the return statement or the value of the last statement.
+func (s *Script) Run(vars map[string]interface{}) (interface{}, error) {
+	if vars == nil {
+		vars = make(map[string]interface{})
+	}
+	e := &env{vars: vars}
+	v, _, err := e.execStatements(s.stmts)
+	if err != nil {
+		return nil, fmt.Errorf("script: %s", err.Error())
+	}
+	return v, nil
+}
+
+// RunBool executes the script and requires a boolean result
+func (s *Script) RunBool(vars map[string]interface{}) (bool, error) {
+	v, err := s.Run(vars)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("script: [%s] should return a boolean but got [%v]", s.source, v)
+	}
+	return b, nil
+}
+
+type env struct {
+	vars map[string]interface{}
+}
+
+func (e *env) execStatements(stmts []node) (interface{}, bool, error) {
+	var last interface{}
+	for _, stmt := range stmts {
+		v, returned, err := e.exec(stmt)
+		if err != nil {
+			return nil, false, err
+		}
+		if returned {
+			return v, true, nil
+		}
+		last = v
+	}
+	return last, false, nil
+}
+
+func (e *env) exec(stmt node) (interface{}, bool, error) {
+	switch n := stmt.(type) {
+	case *returnNode:
+		if n.value == nil {
+			return nil, true, nil
+		}
+		v, err := e.eval(n.value)
+		return v, true, err
+	case *ifNode:
+		cond, err := e.eval(n.cond)
+		if err != nil {
+			return nil, false, err
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return nil, false, fmt.Errorf("if condition should be a boolean but got [%v]", cond)
+		}
+		if b {
+			return e.execStatements(n.then)
+		}
+		return e.execStatements(n.els)
+	case *defNode:
+		var v interface{}
+		if n.value != nil {
+			var err error
+			if v, err = e.eval(n.value); err != nil {
+				return nil, false, err
+			}
+		}
+		e.vars[n.name] = v
+		return v, false, nil
+	case *assignNode:
+		v, err := e.eval(n.value)
+		if err != nil {
+			return nil, false, err
+		}
+		if n.op != "=" {
+			old, err := e.eval(n.target)
+			if err != nil {
+				return nil, false, err
+			}
+			if v, err = binary(n.op[:1], old, v); err != nil {
+				return nil, false, err
+			}
+		}
+		return v, false, e.assign(n.target, v)
+	default:
+		v, err := e.eval(stmt)
+		return v, false, err
+	}
+}
+
+func (e *env) eval(expr node) (interface{}, error) {
+	switch n := expr.(type) {
+	case *literalNode:
+		return n.value, nil
+	case *identNode:
+		if v, ok := e.vars[n.name]; ok {
+			return normalize(v), nil
+		}
+		if v, ok := builtins[n.name]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("variable [%s] is not defined", n.name)
+	case *memberNode:
+		target, err := e.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		return member(target, n.name)
+	case *indexNode:
+		target, err := e.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		index, err := e.eval(n.index)
+		if err != nil {
+			return nil, err
+		}
+		return lookup(target, index)
+	case *callNode:
+		return e.call(n)
+	case *unaryNode:
+		v, err := e.eval(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		switch n.op {
+		case "!":
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("cannot apply [!] to [%v]", v)
+			}
+			return !b, nil
+		default:
+			f, ok := ToFloat(v)
+			if !ok {
+				return nil, fmt.Errorf("cannot apply [%s] to [%v]", n.op, v)
+			}
+			if n.op == "-" {
+				return -f, nil
+			}
+			return f, nil
+		}
+	case *binaryNode:
+		left, err := e.eval(n.left)
+		if err != nil {
+			return nil, err
+		}
+		// short circuit
+		switch n.op {
+		case "&&", "||":
+			l, ok := left.(bool)
+			if !ok {
+				return nil, fmt.Errorf("cannot apply [%s] to [%v]", n.op, left)
+			}
+			if (n.op == "&&" && !l) || (n.op == "||" && l) {
+				return l, nil
+			}
+			right, err := e.eval(n.right)
+			if err != nil {
+				return nil, err
+			}
+			r, ok := right.(bool)
+			if !ok {
+				return nil, fmt.Errorf("cannot apply [%s] to [%v]", n.op, right)
+			}
+			return r, nil
+		}
+		right, err := e.eval(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return binary(n.op, left, right)
+	case *ternaryNode:
+		cond, err := e.eval(n.cond)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := cond.(bool)
+		if !ok {
+			return nil, fmt.Errorf("condition should be a boolean but got [%v]", cond)
+		}
+		if b {
+			return e.eval(n.then)
+		}
+		return e.eval(n.els)
+	case *listNode:
+		items := make([]interface{}, 0, len(n.items))
+		for _, item := range n.items {
+			v, err := e.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case *mapNode:
+		m := make(map[string]interface{}, len(n.keys))
+		for i := range n.keys {
+			k, err := e.eval(n.keys[i])
+			if err != nil {
+				return nil, err
+			}
+			v, err := e.eval(n.values[i])
+			if err != nil {
+				return nil, err
+			}
+			m[toString(k)] = v
+		}
+		return m, nil
+	case *incDecNode:
+		old, err := e.eval(n.target)
+		if err != nil {
+			return nil, err
+		}
+		f, ok := ToFloat(old)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply [%s] to [%v]", n.op, old)
+		}
+		v := f + 1
+		if n.op == "--" {
+			v = f - 1
+		}
+		if err = e.assign(n.target, v); err != nil {
+			return nil, err
+		}
+		if n.prefix {
+			return v, nil
+		}
+		return f, nil
+	case *assignNode, *defNode, *ifNode, *returnNode:
+		return nil, fmt.Errorf("statement is not allowed in an expression")
+	}
+	return nil, fmt.Errorf("unknown expression %T", expr)
+}
+
+func (e *env) assign(target node, v interface{}) error {
+	switch n := target.(type) {
+	case *identNode:
+		e.vars[n.name] = v
+		return nil
+	case *memberNode:
+		t, err := e.eval(n.target)
+		if err != nil {
+			return err
+		}
+		m, ok := t.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set field [%s] on [%v]", n.name, t)
+		}
+		m[n.name] = v
+		return nil
+	case *indexNode:
+		t, err := e.eval(n.target)
+		if err != nil {
+			return err
+		}
+		index, err := e.eval(n.index)
+		if err != nil {
+			return err
+		}
+		switch t := t.(type) {
+		case map[string]interface{}:
+			t[toString(index)] = v
+			return nil
+		case []interface{}:
+			i, err := listIndex(t, index)
+			if err != nil {
+				return err
+			}
+			t[i] = v
+			return nil
+		}
+		return fmt.Errorf("cannot set [%v] on [%v]", index, t)
+	}
+	return fmt.Errorf("invalid assignment target")
+}
+
+func (e *env) call(n *callNode) (interface{}, error) {
+	args := make([]interface{}, 0, len(n.args))
+	for _, arg := range n.args {
+		v, err := e.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	// method call on a value, eg: ctx._source.containsKey('a')
+	if m, ok := n.fn.(*memberNode); ok {
+		target, err := e.eval(m.target)
+		if err != nil {
+			return nil, err
+		}
+		if obj, ok := target.(map[string]interface{}); ok {
+			if fn, ok := obj[m.name].(Func); ok {
+				return fn(args...)
+			}
+		}
+		return method(target, m.name, args)
+	}
+	fn, err := e.eval(n.fn)
+	if err != nil {
+		return nil, err
+	}
+	if fn, ok := fn.(Func); ok {
+		return fn(args...)
+	}
+	return nil, fmt.Errorf("[%v] is not a function", fn)
+}
+
+func member(target interface{}, name string) (interface{}, error) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		return normalize(t[name]), nil
+	case []interface{}:
+		if name == "length" {
+			return float64(len(t)), nil
+		}
+	case nil:
+		return nil, fmt.Errorf("cannot access field [%s] of null", name)
+	}
+	return nil, fmt.Errorf("cannot access field [%s] of [%v]", name, target)
+}
+
+func lookup(target, index interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		return normalize(t[toString(index)]), nil
+	case []interface{}:
+		i, err := listIndex(t, index)
+		if err != nil {
+			return nil, err
+		}
+		return normalize(t[i]), nil
+	case string:
+		f, ok := ToFloat(index)
+		if !ok || int(f) < 0 || int(f) >= len(t) {
+			return nil, fmt.Errorf("index [%v] out of bounds", index)
+		}
+		return t[int(f) : int(f)+1], nil
+	case nil:
+		return nil, fmt.Errorf("cannot access [%v] of null", index)
+	}
+	return nil, fmt.Errorf("cannot access [%v] of [%v]", index, target)
+}
+
+func listIndex(list []interface{}, index interface{}) (int, error) {
+	f, ok := ToFloat(index)
+	if !ok {
+		return 0, fmt.Errorf("list index should be a number but got [%v]", index)
+	}
+	i := int(f)
+	if i < 0 {
+		i += len(list)
+	}
+	if i < 0 || i >= len(list) {
+		return 0, fmt.Errorf("index [%v] out of bounds for length %d", index, len(list))
+	}
+	return i, nil
+}
+
+func method(target interface{}, name string, args []interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case map[string]interface{}:
+		switch name {
+		case "containsKey":
+			if len(args) == 1 {
+				_, ok := t[toString(args[0])]
+				return ok, nil
+			}
+		case "get":
+			if len(args) == 1 {
+				return normalize(t[toString(args[0])]), nil
+			}
+		case "getOrDefault":
+			if len(args) == 2 {
+				if v, ok := t[toString(args[0])]; ok && v != nil {
+					return normalize(v), nil
+				}
+				return args[1], nil
+			}
+		case "put":
+			if len(args) == 2 {
+				old := t[toString(args[0])]
+				t[toString(args[0])] = args[1]
+				return old, nil
+			}
+		case "remove":
+			if len(args) == 1 {
+				old := t[toString(args[0])]
+				delete(t, toString(args[0]))
+				return normalize(old), nil
+			}
+		case "size":
+			return float64(len(t)), nil
+		case "isEmpty":
+			return len(t) == 0, nil
+		}
+	case []interface{}:
+		switch name {
+		case "size":
+			return float64(len(t)), nil
+		case "isEmpty":
+			return len(t) == 0, nil
+		case "contains":
+			if len(args) == 1 {
+				for _, v := range t {
+					if equal(normalize(v), args[0]) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+		case "get":
+			if len(args) == 1 {
+				return lookup(t, args[0])
+			}
+		}
+	case string:
+		switch name {
+		case "length":
+			return float64(len(t)), nil
+		case "isEmpty":
+			return len(t) == 0, nil
+		case "toLowerCase":
+			return strings.ToLower(t), nil
+		case "toUpperCase":
+			return strings.ToUpper(t), nil
+		case "trim":
+			return strings.TrimSpace(t), nil
+		case "contains", "startsWith", "endsWith":
+			if len(args) == 1 {
+				s := toString(args[0])
+				switch name {
+				case "contains":
+					return strings.Contains(t, s), nil
+				case "startsWith":
+					return strings.HasPrefix(t, s), nil
+				default:
+					return strings.HasSuffix(t, s), nil
+				}
+			}
+		case "substring":
+			if len(args) == 1 || len(args) == 2 {
+				start, _ := ToFloat(args[0])
+				end := float64(len(t))
+				if len(args) == 2 {
+					end, _ = ToFloat(args[1])
+				}
+				if start < 0 || end > float64(len(t)) || start > end {
+					return nil, fmt.Errorf("substring [%v, %v] out of bounds for length %d", start, end, len(t))
+				}
+				return t[int(start):int(end)], nil
+			}
+		}
+	case nil:
+		return nil, fmt.Errorf("cannot call [%s] on null", name)
+	}
+	return nil, fmt.Errorf("unknown method [%s] with %d arguments on [%v]", name, len(args), target)
+}
+
+func binary(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==", "===":
+		return equal(left, right), nil
+	case "!=", "!==":
+		return !equal(left, right), nil
+	case "+":
+		// string concatenation
+		if ls, ok := left.(string); ok {
+			return ls + toString(right), nil
+		}
+		if rs, ok := right.(string); ok {
+			return toString(left) + rs, nil
+		}
+	case "<", "<=", ">", ">=":
+		if ls, ok := left.(string); ok {
+			if rs, ok := right.(string); ok {
+				return compare(op, strings.Compare(ls, rs)), nil
+			}
+		}
+	}
+
+	l, lok := ToFloat(left)
+	r, rok := ToFloat(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot apply [%s] to [%v] and [%v]", op, left, right)
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	case "%":
+		return math.Mod(l, r), nil
+	case "<", "<=", ">", ">=":
+		c := 0
+		if l < r {
+			c = -1
+		} else if l > r {
+			c = 1
+		}
+		return compare(op, c), nil
+	}
+	return nil, fmt.Errorf("unknown operator [%s]", op)
+}
+
+func compare(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+func equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if l, ok := ToFloat(left); ok {
+		r, ok := ToFloat(right)
+		return ok && l == r
+	}
+	switch l := left.(type) {
+	case string:
+		r, ok := right.(string)
+		return ok && l == r
+	case bool:
+		r, ok := right.(bool)
+		return ok && l == r
+	}
+	return false
+}
+
+// normalize converts the numbers from go code to float64
+func normalize(v interface{}) interface{} {
+	if _, ok := v.(bool); ok {
+		return v
+	}
+	if f, ok := ToFloat(v); ok {
+		return f
+	}
+	return v
+}
+
+// ToFloat converts the numbers to float64, false means v is not a number
+func ToFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "null"
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+			return fmt.Sprintf("%d", int64(v))
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,191 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package script
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScript_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		vars       map[string]interface{}
+		want       interface{}
+		wantErr    bool
+		compileErr bool
+	}{
+		{
+			name:   "arithmetic",
+			source: "1 + 2 * 3 - 4 / 2",
+			want:   float64(5),
+		},
+		{
+			name:   "params",
+			source: "params.a / params.b",
+			vars:   map[string]interface{}{"params": map[string]interface{}{"a": 10, "b": uint64(4)}},
+			want:   2.5,
+		},
+		{
+			name:   "comparison and logic",
+			source: "params.a > 1 && params.a <= 3 || false",
+			vars:   map[string]interface{}{"params": map[string]interface{}{"a": 2}},
+			want:   true,
+		},
+		{
+			name:   "ternary",
+			source: "params.a > 1 ? 'big' : 'small'",
+			vars:   map[string]interface{}{"params": map[string]interface{}{"a": 0}},
+			want:   "small",
+		},
+		{
+			name:   "string concat and methods",
+			source: "'Hello ' + params['name'].toUpperCase() + params.name.length()",
+			vars:   map[string]interface{}{"params": map[string]interface{}{"name": "zinc"}},
+			want:   "Hello ZINC4",
+		},
+		{
+			name:   "statements and return",
+			source: "def x = 1; x += 2; x++; if (x > 3) { return x * 10 } else { return 0 }",
+			want:   float64(40),
+		},
+		{
+			name:   "else if",
+			source: "if (params.a == 1) { 'one' } else if (params.a == 2) { 'two' } else { 'other' }",
+			vars:   map[string]interface{}{"params": map[string]interface{}{"a": 2}},
+			want:   "two",
+		},
+		{
+			name:   "modify source",
+			source: "ctx._source.count += params.n; ctx._source.tags.contains('b') ? ctx._source.remove('old') : null; ctx._source.count",
+			vars: map[string]interface{}{
+				"params": map[string]interface{}{"n": 2},
+				"ctx": map[string]interface{}{"_source": map[string]interface{}{
+					"count": 1, "tags": []interface{}{"a", "b"}, "old": true,
+				}},
+			},
+			want: float64(3),
+		},
+		{
+			name:   "collections",
+			source: "def m = ['a': [1, 2, 3], 'b': [:]]; m.b.c = m.a[-1]; m.b.c + m.a.size()",
+			want:   float64(6),
+		},
+		{
+			name:   "math",
+			source: "Math.max(Math.abs(-3), Math.sqrt(4)) + Math.round(1.6)",
+			want:   float64(5),
+		},
+		{
+			name:   "null equality",
+			source: "params.x == null",
+			vars:   map[string]interface{}{"params": map[string]interface{}{}},
+			want:   true,
+		},
+		{
+			name:    "undefined variable",
+			source:  "foo + 1",
+			wantErr: true,
+		},
+		{
+			name:    "condition is not boolean",
+			source:  "if (1) { 2 }",
+			wantErr: true,
+		},
+		{
+			name:    "field of null",
+			source:  "params.a.b",
+			vars:    map[string]interface{}{"params": map[string]interface{}{}},
+			wantErr: true,
+		},
+		{
+			name:       "syntax error",
+			source:     "1 + ",
+			compileErr: true,
+		},
+		{
+			name:       "invalid assignment",
+			source:     "1 = 2",
+			compileErr: true,
+		},
+		{
+			name:       "unterminated string",
+			source:     "'abc",
+			compileErr: true,
+		},
+		{
+			name:       "empty",
+			source:     " // comment only",
+			compileErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Compile(tt.source)
+			if tt.compileErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			got, err := s.Run(tt.vars)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestScript_RunBool(t *testing.T) {
+	s, err := Compile("params.count > 10")
+	assert.NoError(t, err)
+	ok, err := s.RunBool(map[string]interface{}{"params": map[string]interface{}{"count": 11}})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	s, err = Compile("params.count")
+	assert.NoError(t, err)
+	_, err = s.RunBool(map[string]interface{}{"params": map[string]interface{}{"count": 11}})
+	assert.Error(t, err)
+}
+
+func TestFromRequest(t *testing.T) {
+	s, params, err := FromRequest("1 + 1")
+	assert.NoError(t, err)
+	assert.Nil(t, params)
+	assert.Equal(t, "1 + 1", s.String())
+
+	s, params, err = FromRequest(map[string]interface{}{
+		"lang":   "painless",
+		"source": "params.a * 2",
+		"params": map[string]interface{}{"a": 3},
+	})
+	assert.NoError(t, err)
+	got, err := s.Run(map[string]interface{}{"params": params})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(6), got)
+
+	_, _, err = FromRequest(map[string]interface{}{"lang": "expression", "source": "1"})
+	assert.Error(t, err)
+	_, _, err = FromRequest(map[string]interface{}{"params": map[string]interface{}{}})
+	assert.Error(t, err)
+	_, _, err = FromRequest(nil)
+	assert.Error(t, err)
+}
@@ -27,6 +27,8 @@ import (
 	zincaggregation "github.com/zinclabs/zincsearch/pkg/bluge/aggregation"
 	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery/sort"
+	"github.com/zinclabs/zincsearch/pkg/uquery/source"
 	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
 
@@ -61,6 +63,81 @@ func Request(
 			req.AddAggregation(name, aggregations.CountMatches())
 		case agg.Cardinality != nil:
 			req.AddAggregation(name, aggregations.Cardinality(search.Field(agg.Cardinality.Field)))
+		case agg.ValueCount != nil:
+			valueType := zincaggregation.TextValuesSource
+			prop, _ := mappings.GetProperty(agg.ValueCount.Field)
+			switch prop.Type {
+			case "numeric", "date", "time":
+				valueType = zincaggregation.NumericValuesSource
+			}
+			req.AddAggregation(name, zincaggregation.NewValueCountAggregation(search.Field(agg.ValueCount.Field), valueType))
+		case agg.Stats != nil:
+			if err := checkNumericField(mappings, "stats", agg.Stats.Field); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewStatsAggregation(search.Field(agg.Stats.Field), false, 0))
+		case agg.ExtendedStats != nil:
+			if err := checkNumericField(mappings, "extended_stats", agg.ExtendedStats.Field); err != nil {
+				return err
+			}
+			if agg.ExtendedStats.Sigma < 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[extended_stats] aggregation sigma must be a non-negative number")
+			}
+			if agg.ExtendedStats.Sigma == 0 {
+				agg.ExtendedStats.Sigma = 2
+			}
+			req.AddAggregation(name, zincaggregation.NewStatsAggregation(search.Field(agg.ExtendedStats.Field), true, agg.ExtendedStats.Sigma))
+		case agg.Percentiles != nil:
+			if err := checkNumericField(mappings, "percentiles", agg.Percentiles.Field); err != nil {
+				return err
+			}
+			if len(agg.Percentiles.Percents) == 0 {
+				agg.Percentiles.Percents = []float64{1, 5, 25, 50, 75, 95, 99}
+			}
+			subreq, err := zincaggregation.NewPercentilesAggregation(
+				search.Field(agg.Percentiles.Field),
+				agg.Percentiles.Percents,
+				tdigestCompression(agg.Percentiles),
+			)
+			if err != nil {
+				return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percentiles] aggregation %s", err.Error()))
+			}
+			req.AddAggregation(name, subreq)
+		case agg.PercentileRanks != nil:
+			if err := checkNumericField(mappings, "percentile_ranks", agg.PercentileRanks.Field); err != nil {
+				return err
+			}
+			if len(agg.PercentileRanks.Values) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[percentile_ranks] aggregation needs values")
+			}
+			subreq, err := zincaggregation.NewPercentileRanksAggregation(
+				search.Field(agg.PercentileRanks.Field),
+				agg.PercentileRanks.Values,
+				tdigestCompression(agg.PercentileRanks),
+			)
+			if err != nil {
+				return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[percentile_ranks] aggregation %s", err.Error()))
+			}
+			req.AddAggregation(name, subreq)
+		case agg.TopHits != nil:
+			if agg.TopHits.Size == 0 {
+				agg.TopHits.Size = 3
+			}
+			if agg.TopHits.From < 0 || agg.TopHits.Size < 0 || agg.TopHits.From+agg.TopHits.Size > maxTopHitsWindow {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[top_hits] aggregation from + size must be in [0, %d]", maxTopHitsWindow),
+				)
+			}
+			sorts, err := sort.Request(agg.TopHits.Sort)
+			if err != nil {
+				return err
+			}
+			agg.TopHits.Sort = sorts
+			if agg.TopHits.Source, err = source.Request(agg.TopHits.Source); err != nil {
+				return err
+			}
+			req.AddAggregation(name, zincaggregation.NewTopHitsAggregation(agg.TopHits.From, agg.TopHits.Size, sorts))
 		case agg.Terms != nil:
 			if agg.Terms.Size == 0 {
 				agg.Terms.Size = aggregationTermsSize
@@ -68,7 +145,7 @@ func Request(
 			var subreq *zincaggregation.TermsAggregation
 			prop, _ := mappings.GetProperty(agg.Terms.Field)
 			switch prop.Type {
-			case "text", "keyword":
+			case "text", "keyword", "ip":
 				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.TextValueSource, agg.Terms.Size)
 			case "numeric":
 				subreq = zincaggregation.NewTermsAggregation(search.Field(agg.Terms.Field), zincaggregation.NumericValueSource, agg.Terms.Size)
@@ -90,23 +167,28 @@ func Request(
 			if len(agg.Range.Ranges) == 0 {
 				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation needs ranges")
 			}
-			var subreq *aggregations.RangeAggregation
+			var subreq *zincaggregation.RangeAggregation
 			prop, _ := mappings.GetProperty(agg.Range.Field)
 			switch prop.Type {
 			case "numeric":
-				subreq = aggregations.Ranges(search.Field(agg.Range.Field))
+				subreq = zincaggregation.NewRangeAggregation(search.Field(agg.Range.Field))
 				for _, v := range agg.Range.Ranges {
-					subreq.AddRange(aggregations.Range(v.From, v.To))
+					subreq.AddRange(v.From, v.To)
 				}
-				req.AddAggregation(name, subreq)
 			default:
 				return errors.New(errors.ErrorTypeParsingException, "[range] aggregation only support type numeric")
 			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, aggregationTermsSize); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
 		case agg.DateRange != nil:
 			if len(agg.DateRange.Ranges) == 0 {
 				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation needs ranges")
 			}
-			var subreq *aggregations.DateRangeAggregation
+			var subreq *zincaggregation.DateRangeAggregation
 			format := time.RFC3339
 			prop, ok := mappings.GetProperty(agg.DateRange.Field)
 			if ok {
@@ -126,7 +208,7 @@ func Request(
 			}
 			switch prop.Type {
 			case "date", "time":
-				subreq = aggregations.DateRanges(search.Field(agg.DateRange.Field))
+				subreq = zincaggregation.NewDateRangeAggregation(search.Field(agg.DateRange.Field))
 				for _, v := range agg.DateRange.Ranges {
 					from := time.Time{}
 					to := time.Time{}
@@ -142,12 +224,17 @@ func Request(
 							return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[date_range] range value to parse err %s", err.Error()))
 						}
 					}
-					subreq.AddRange(aggregations.NewDateRange(from, to))
+					subreq.AddRange(from, to)
 				}
-				req.AddAggregation(name, subreq)
 			default:
 				return errors.New(errors.ErrorTypeParsingException, "[date_range] aggregation only support type datetime")
 			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, aggregationTermsSize); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
 		case agg.Histogram != nil:
 			if agg.Histogram.Size == 0 {
 				agg.Histogram.Size = aggregationTermsSize
@@ -326,7 +413,32 @@ func Request(
 			}
 			req.AddAggregation(name, subreq)
 		case agg.IPRange != nil:
-			return errors.New(errors.ErrorTypeNotImplemented, "[ip_range] aggregation doesn't support")
+			if len(agg.IPRange.Ranges) == 0 {
+				return errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation needs ranges")
+			}
+			prop, _ := mappings.GetProperty(agg.IPRange.Field)
+			if prop.Type != "ip" {
+				return errors.New(errors.ErrorTypeParsingException, "[ip_range] aggregation only support type ip")
+			}
+			subreq := zincaggregation.NewIPRangeAggregation(search.Field(agg.IPRange.Field))
+			for _, v := range agg.IPRange.Ranges {
+				key, from, to, err := ipRangeBounds(v)
+				if err != nil {
+					return err
+				}
+				subreq.AddRange(key, from, to)
+			}
+			if len(agg.Aggregations) > 0 {
+				if err := Request(subreq, agg.Aggregations, mappings, aggregationTermsSize); err != nil {
+					return err
+				}
+			}
+			req.AddAggregation(name, subreq)
+		case isPipeline(agg):
+			// pipeline aggregations run after the search, see Pipeline
+			if err := checkPipeline(req, aggs, name, agg); err != nil {
+				return err
+			}
 		default:
 			// nothing
 		}
@@ -335,11 +447,86 @@ func Request(
 	return nil
 }
 
-func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error) {
+// maxTopHitsWindow is the max from + size of top_hits aggregation
+const maxTopHitsWindow = 100
+
+// ipRangeBounds returns the key and the bounds [from, to) of an ip range as indexed terms,
+// a mask is converted to the range of the CIDR.
+func ipRangeBounds(r meta.IPRange) (key string, from, to []byte, err error) {
+	if r.Mask != "" {
+		first, last, err := zutils.ParseCIDR(r.Mask)
+		if err != nil {
+			return "", nil, nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] range mask %s", err.Error()))
+		}
+		key = r.Mask
+		if r.Key != "" {
+			key = r.Key
+		}
+		// to is exclusive, the address after the last one, unbounded when last is the max address
+		to = append([]byte(nil), last...)
+		for i := len(to) - 1; i >= 0; i-- {
+			to[i]++
+			if to[i] != 0 {
+				return key, []byte(zutils.IPTerm(first)), []byte(zutils.IPTerm(to)), nil
+			}
+		}
+		return key, []byte(zutils.IPTerm(first)), nil, nil
+	}
+
+	fromKey, toKey := "*", "*"
+	if r.From != "" {
+		ip, err := zutils.ParseIP(r.From)
+		if err != nil {
+			return "", nil, nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] range value from %s", err.Error()))
+		}
+		from = []byte(zutils.IPTerm(ip))
+		fromKey = r.From
+	}
+	if r.To != "" {
+		ip, err := zutils.ParseIP(r.To)
+		if err != nil {
+			return "", nil, nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[ip_range] range value to %s", err.Error()))
+		}
+		to = []byte(zutils.IPTerm(ip))
+		toKey = r.To
+	}
+	key = fromKey + "-" + toKey
+	if r.Key != "" {
+		key = r.Key
+	}
+	return key, from, to, nil
+}
+
+func checkNumericField(mappings *meta.Mappings, typ, field string) error {
+	prop, _ := mappings.GetProperty(field)
+	if prop.Type != "numeric" {
+		return errors.New(
+			errors.ErrorTypeParsingException,
+			fmt.Sprintf("[%s] aggregation doesn't support values of type: [%s:[%s]]", typ, field, prop.Type),
+		)
+	}
+	return nil
+}
+
+func tdigestCompression(agg *meta.AggregationPercentiles) float64 {
+	if agg.TDigest == nil {
+		return 0
+	}
+	return agg.TDigest.Compression
+}
+
+// Response formats the aggregations of the bucket, aggs are the requested aggregations
+func Response(bucket *search.Bucket, aggs map[string]meta.Aggregations, mappings *meta.Mappings) (map[string]meta.AggregationResponse, error) {
 	resp := make(map[string]meta.AggregationResponse)
-	aggs := bucket.Aggregations()
-	for name, v := range aggs {
+	calculators := bucket.Aggregations()
+	for name, v := range calculators {
 		switch v := v.(type) {
+		case *zincaggregation.PercentilesCalculator:
+			resp[name] = percentilesResponse(v, aggs[name])
+		case *zincaggregation.StatsCalculator:
+			resp[name] = statsResponse(v)
+		case *zincaggregation.TopHitsCalculator:
+			resp[name] = topHitsResponse(v, aggs[name], mappings)
 		case search.MetricCalculator:
 			f := v.Value()
 			if math.IsNaN(f) {
@@ -352,15 +539,32 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			buckets := v.Buckets()
 			aggResp := meta.AggregationResponse{Buckets: make([]map[string]interface{}, 0)}
 			aggRespBuckets := make([]map[string]interface{}, 0)
-			for _, bucket := range buckets {
+			ipRanges, _ := v.(*zincaggregation.IPRangeCalculator)
+			ipTerms := false
+			if terms := aggs[name].Terms; terms != nil && mappings != nil {
+				prop, _ := mappings.GetProperty(terms.Field)
+				ipTerms = prop.Type == "ip"
+			}
+			for i, bucket := range buckets {
 				aggBucket := map[string]interface{}{"key": bucket.Name(), "doc_count": bucket.Count()}
-				if zutils.IsNumeric(bucket.Name()) {
+				if ipTerms {
+					aggBucket["key"] = zutils.FormatIPTerm([]byte(bucket.Name()))
+				} else if bucket.Name() != "" && zutils.IsNumeric(bucket.Name()) {
 					key, _ := strconv.ParseInt(bucket.Name(), 10, 64)
 					aggBucket["key"] = key
 					aggBucket["key_as_string"] = bucket.Name()
 				}
+				if ipRanges != nil {
+					from, to := ipRanges.Range(i)
+					if from != nil {
+						aggBucket["from"] = zutils.FormatIPTerm(from)
+					}
+					if to != nil {
+						aggBucket["to"] = zutils.FormatIPTerm(to)
+					}
+				}
 				if subAggs := bucket.Aggregations(); len(subAggs) > 1 {
-					subResp, err := Response(bucket)
+					subResp, err := Response(bucket, aggs[name].Aggregations, mappings)
 					if err != nil {
 						return nil, err
 					}
@@ -374,7 +578,7 @@ func Response(bucket *search.Bucket) (map[string]meta.AggregationResponse, error
 			aggResp.Buckets = aggRespBuckets
 
 			// hack: auto_date_histogram aggregation
-			if v, ok := aggs[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
+			if v, ok := calculators[name].(*zincaggregation.AutoDateHistogramCalculator); ok {
 				aggResp.Interval = v.Interval()
 			}
 
@@ -0,0 +1,144 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/search"
+
+	zincaggregation "github.com/zinclabs/zincsearch/pkg/bluge/aggregation"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery/sort"
+	"github.com/zinclabs/zincsearch/pkg/uquery/source"
+)
+
+// percentilesResponse returns {"values": {"95.0": 60}} or {"values": [{"key": 95, "value": 60}]} when not keyed
+func percentilesResponse(calc *zincaggregation.PercentilesCalculator, agg meta.Aggregations) meta.AggregationResponse {
+	def := agg.Percentiles
+	if def == nil {
+		def = agg.PercentileRanks
+	}
+	keyed := def == nil || def.Keyed == nil || *def.Keyed
+
+	keys, values := calc.Keys(), calc.Values()
+	if keyed {
+		rv := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			rv[percentileKey(key)] = nanToNil(values[i])
+		}
+		return meta.AggregationResponse{Values: rv}
+	}
+	rv := make([]map[string]interface{}, 0, len(keys))
+	for i, key := range keys {
+		rv = append(rv, map[string]interface{}{"key": key, "value": nanToNil(values[i])})
+	}
+	return meta.AggregationResponse{Values: rv}
+}
+
+// percentileKey formats the key like 95.0, 99.9
+func percentileKey(key float64) string {
+	s := strconv.FormatFloat(key, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func statsResponse(calc *zincaggregation.StatsCalculator) meta.AggregationResponse {
+	metrics := map[string]interface{}{
+		"count": calc.Count(),
+		"min":   nanToNil(calc.Min()),
+		"max":   nanToNil(calc.Max()),
+		"avg":   nanToNil(calc.Avg()),
+		"sum":   calc.Sum(),
+	}
+	if !calc.Extended() {
+		return meta.AggregationResponse{Metrics: metrics}
+	}
+
+	avg, sigma := calc.Avg(), calc.Sigma()
+	variance, varianceSampling := calc.Variance(), calc.VarianceSampling()
+	stdDeviation, stdDeviationSampling := math.Sqrt(variance), math.Sqrt(varianceSampling)
+	metrics["sum_of_squares"] = nil
+	if calc.Count() > 0 {
+		metrics["sum_of_squares"] = calc.SumOfSquares()
+	}
+	metrics["variance"] = nanToNil(variance)
+	metrics["variance_population"] = nanToNil(variance)
+	metrics["variance_sampling"] = nanToNil(varianceSampling)
+	metrics["std_deviation"] = nanToNil(stdDeviation)
+	metrics["std_deviation_population"] = nanToNil(stdDeviation)
+	metrics["std_deviation_sampling"] = nanToNil(stdDeviationSampling)
+	metrics["std_deviation_bounds"] = map[string]interface{}{
+		"upper":            nanToNil(avg + sigma*stdDeviation),
+		"lower":            nanToNil(avg - sigma*stdDeviation),
+		"upper_population": nanToNil(avg + sigma*stdDeviation),
+		"lower_population": nanToNil(avg - sigma*stdDeviation),
+		"upper_sampling":   nanToNil(avg + sigma*stdDeviationSampling),
+		"lower_sampling":   nanToNil(avg - sigma*stdDeviationSampling),
+	}
+	return meta.AggregationResponse{Metrics: metrics}
+}
+
+func topHitsResponse(calc *zincaggregation.TopHitsCalculator, agg meta.Aggregations, mappings *meta.Mappings) meta.AggregationResponse {
+	sourceFilter := &meta.Source{Enable: true}
+	var sorts search.SortOrder
+	if agg.TopHits != nil {
+		if v, ok := agg.TopHits.Source.(*meta.Source); ok {
+			sourceFilter = v
+		}
+		sorts, _ = agg.TopHits.Sort.(search.SortOrder)
+	}
+
+	maxScore := calc.MaxScore()
+	if math.IsNaN(maxScore) {
+		maxScore = 0
+	}
+	hits := &meta.Hits{
+		Total:    meta.Total{Value: int(calc.Total())},
+		MaxScore: maxScore,
+		Hits:     make([]meta.Hit, 0, len(calc.Hits())),
+	}
+	for _, v := range calc.Hits() {
+		hit := meta.Hit{
+			Index:     v.Index,
+			Type:      "_doc",
+			ID:        v.ID,
+			Score:     v.Score,
+			Timestamp: v.Timestamp,
+		}
+		if data := source.Response(sourceFilter, v.Source); data != nil {
+			data["@timestamp"] = v.Timestamp
+			hit.Source = data
+		}
+		// the sort of the response is the requested sort, the default _score is not returned
+		if len(sorts) > 0 {
+			hit.Sort = sort.Values(v.SortValue, sorts, mappings)
+		}
+		hits.Hits = append(hits.Hits, hit)
+	}
+	return meta.AggregationResponse{Hits: hits}
+}
+
+func nanToNil(v float64) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return v
+}
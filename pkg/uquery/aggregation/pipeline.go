@@ -0,0 +1,986 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package aggregation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	zincaggregation "github.com/zinclabs/zincsearch/pkg/bluge/aggregation"
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/script"
+)
+
+// Pipeline aggregations don't collect documents, they run on the response after the shards merged.
+// Parent pipelines (derivative, cumulative_sum, moving_fn, moving_avg, bucket_script, bucket_selector, bucket_sort)
+// add a value to every bucket of the multi-bucket aggregation they are nested in,
+// sibling pipelines (avg_bucket, max_bucket, min_bucket, sum_bucket) compute a value from the buckets of a sibling aggregation.
+func Pipeline(aggs map[string]meta.Aggregations, resp map[string]meta.AggregationResponse) error {
+	if !hasPipeline(aggs) {
+		return nil
+	}
+	level := make(map[string]interface{}, len(resp))
+	for name, v := range resp {
+		level[name] = v
+	}
+	if err := pipelineLevel(aggs, level); err != nil {
+		return err
+	}
+	for name, v := range level {
+		if v, ok := v.(meta.AggregationResponse); ok {
+			resp[name] = v
+		}
+	}
+	return nil
+}
+
+const (
+	gapPolicySkip        = "skip"
+	gapPolicyInsertZeros = "insert_zeros"
+	gapPolicyKeepValues  = "keep_values"
+)
+
+func pipelineType(agg meta.Aggregations) (string, *meta.AggregationPipeline) {
+	switch {
+	case agg.Derivative != nil:
+		return "derivative", agg.Derivative
+	case agg.CumulativeSum != nil:
+		return "cumulative_sum", agg.CumulativeSum
+	case agg.MovingFn != nil:
+		return "moving_fn", agg.MovingFn
+	case agg.MovingAvg != nil:
+		return "moving_avg", agg.MovingAvg
+	case agg.BucketScript != nil:
+		return "bucket_script", agg.BucketScript
+	case agg.BucketSelector != nil:
+		return "bucket_selector", agg.BucketSelector
+	case agg.BucketSort != nil:
+		return "bucket_sort", nil
+	case agg.AvgBucket != nil:
+		return "avg_bucket", agg.AvgBucket
+	case agg.MaxBucket != nil:
+		return "max_bucket", agg.MaxBucket
+	case agg.MinBucket != nil:
+		return "min_bucket", agg.MinBucket
+	case agg.SumBucket != nil:
+		return "sum_bucket", agg.SumBucket
+	}
+	return "", nil
+}
+
+func isPipeline(agg meta.Aggregations) bool {
+	typ, _ := pipelineType(agg)
+	return typ != ""
+}
+
+func isSiblingPipeline(typ string) bool {
+	switch typ {
+	case "avg_bucket", "max_bucket", "min_bucket", "sum_bucket":
+		return true
+	}
+	return false
+}
+
+func hasPipeline(aggs map[string]meta.Aggregations) bool {
+	for _, agg := range aggs {
+		if isPipeline(agg) || hasPipeline(agg.Aggregations) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPipeline validates a pipeline aggregation when parsing the request,
+// parent is the aggregation it is nested in, aggs are its siblings.
+func checkPipeline(parent zincaggregation.SearchAggregation, aggs map[string]meta.Aggregations, name string, agg meta.Aggregations) error {
+	typ, pipeline := pipelineType(agg)
+	switch typ {
+	case "derivative", "cumulative_sum", "moving_fn", "moving_avg":
+		switch parent.(type) {
+		case *zincaggregation.HistogramAggregation, *zincaggregation.DateHistogramAggregation, *zincaggregation.AutoDateHistogramAggregation:
+		default:
+			return errors.New(
+				errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("[%s] aggregation [%s] must have a histogram, date_histogram or auto_date_histogram as parent", typ, name),
+			)
+		}
+	case "bucket_script", "bucket_selector", "bucket_sort":
+		if !isMultiBucket(parent) {
+			return errors.New(
+				errors.ErrorTypeIllegalArgumentException,
+				fmt.Sprintf("[%s] aggregation [%s] must be declared inside of another multi-bucket aggregation", typ, name),
+			)
+		}
+	}
+
+	if typ == "bucket_sort" {
+		if agg.BucketSort.From < 0 || agg.BucketSort.Size < 0 {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[bucket_sort] aggregation [%s] from and size must be non-negative", name))
+		}
+		if err := checkGapPolicy(typ, agg.BucketSort.GapPolicy); err != nil {
+			return err
+		}
+		sorts, err := bucketSortFields(agg.BucketSort.Sort)
+		if err != nil {
+			return err
+		}
+		for _, s := range sorts {
+			if err := checkBucketPath(aggs, typ, s.path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := checkGapPolicy(typ, pipeline.GapPolicy); err != nil {
+		return err
+	}
+	paths, err := bucketsPaths(typ, pipeline.BucketsPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if isSiblingPipeline(typ) {
+			elems := strings.SplitN(path, ">", 2)
+			sibling, ok := aggs[elems[0]]
+			if len(elems) != 2 || !ok || !isMultiBucketRequest(sibling) {
+				return errors.New(
+					errors.ErrorTypeIllegalArgumentException,
+					fmt.Sprintf("[%s] aggregation buckets_path [%s] must point to a multi-bucket aggregation and its metric, such as: agg>metric", typ, path),
+				)
+			}
+			if err = checkBucketPath(sibling.Aggregations, typ, elems[1]); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = checkBucketPath(aggs, typ, path); err != nil {
+			return err
+		}
+	}
+
+	switch typ {
+	case "bucket_script", "bucket_selector", "moving_fn":
+		if _, _, err := script.FromRequest(pipeline.Script); err != nil {
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation [%s] %s", typ, name, err.Error()))
+		}
+	}
+	switch typ {
+	case "moving_fn", "moving_avg":
+		if pipeline.Window < 0 || (typ == "moving_fn" && pipeline.Window == 0) {
+			return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation [%s] window must be a positive integer", typ, name))
+		}
+	}
+	if typ == "moving_avg" {
+		if _, err := movingAvgModel(pipeline); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkGapPolicy(typ, gapPolicy string) error {
+	switch gapPolicy {
+	case "", gapPolicySkip, gapPolicyInsertZeros, gapPolicyKeepValues:
+		return nil
+	}
+	return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation gap_policy must be one of: skip, insert_zeros, keep_values", typ))
+}
+
+// checkBucketPath checks the path relative to a bucket points to a sibling aggregation
+func checkBucketPath(aggs map[string]meta.Aggregations, typ, path string) error {
+	if path == "_count" || path == "_key" {
+		return nil
+	}
+	name := path
+	if i := strings.IndexByte(path, '.'); i > 0 {
+		name = path[:i]
+	}
+	if _, ok := aggs[name]; !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation no aggregation found for buckets_path [%s]", typ, path))
+	}
+	return nil
+}
+
+func isMultiBucket(req zincaggregation.SearchAggregation) bool {
+	switch req.(type) {
+	case *zincaggregation.TermsAggregation,
+		*zincaggregation.RangeAggregation,
+		*zincaggregation.DateRangeAggregation,
+		*zincaggregation.HistogramAggregation,
+		*zincaggregation.DateHistogramAggregation,
+		*zincaggregation.AutoDateHistogramAggregation:
+		return true
+	}
+	return false
+}
+
+func isMultiBucketRequest(agg meta.Aggregations) bool {
+	return agg.Terms != nil || agg.Range != nil || agg.DateRange != nil ||
+		agg.Histogram != nil || agg.DateHistogram != nil || agg.AutoDateHistogram != nil
+}
+
+// bucketsPaths returns the paths sorted by the variable names,
+// a string path is the variable _value for bucket_script and bucket_selector
+func bucketsPaths(typ string, v interface{}) ([]string, error) {
+	_, paths, err := bucketsPathVars(typ, v)
+	return paths, err
+}
+
+func bucketsPathVars(typ string, v interface{}) ([]string, []string, error) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			break
+		}
+		return []string{"_value"}, []string{v}, nil
+	case map[string]interface{}:
+		if typ != "bucket_script" && typ != "bucket_selector" {
+			return nil, nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation buckets_path must be a string", typ))
+		}
+		if len(v) == 0 {
+			break
+		}
+		vars := make([]string, 0, len(v))
+		for name := range v {
+			vars = append(vars, name)
+		}
+		sort.Strings(vars)
+		paths := make([]string, 0, len(v))
+		for _, name := range vars {
+			path, ok := v[name].(string)
+			if !ok {
+				return nil, nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation buckets_path [%s] must be a string", typ, name))
+			}
+			paths = append(paths, path)
+		}
+		return vars, paths, nil
+	}
+	return nil, nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] aggregation buckets_path is required", typ))
+}
+
+type bucketSortField struct {
+	path string
+	desc bool
+}
+
+// bucketSortFields parses ["_key", {"the_sum": {"order": "desc"}}, {"_count": "asc"}]
+func bucketSortFields(v []interface{}) ([]bucketSortField, error) {
+	fields := make([]bucketSortField, 0, len(v))
+	for _, item := range v {
+		switch item := item.(type) {
+		case string:
+			fields = append(fields, bucketSortField{path: item})
+		case map[string]interface{}:
+			for path, order := range item {
+				field := bucketSortField{path: path}
+				if o, ok := order.(map[string]interface{}); ok {
+					order = o["order"]
+				}
+				switch order {
+				case "asc", nil:
+				case "desc":
+					field.desc = true
+				default:
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_sort] aggregation unknown order [%v] for [%s]", order, path))
+				}
+				fields = append(fields, field)
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_sort] aggregation unknown sort [%v]", item))
+		}
+	}
+	return fields, nil
+}
+
+// pipelineLevel runs the pipelines of one level, a level is the top response or a bucket
+func pipelineLevel(aggs map[string]meta.Aggregations, level map[string]interface{}) error {
+	for _, name := range sortedNames(aggs) {
+		agg := aggs[name]
+		if !hasPipeline(agg.Aggregations) {
+			continue
+		}
+		resp, ok := level[name].(meta.AggregationResponse)
+		if !ok {
+			continue
+		}
+		buckets, ok := resp.Buckets.([]map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, bucket := range buckets {
+			if err := pipelineLevel(agg.Aggregations, bucket); err != nil {
+				return err
+			}
+		}
+		buckets, err := parentPipelines(agg.Aggregations, buckets)
+		if err != nil {
+			return err
+		}
+		resp.Buckets = buckets
+		level[name] = resp
+	}
+
+	for _, name := range sortedNames(aggs) {
+		typ, pipeline := pipelineType(aggs[name])
+		if !isSiblingPipeline(typ) {
+			continue
+		}
+		resp, err := siblingPipeline(typ, pipeline, level)
+		if err != nil {
+			return err
+		}
+		level[name] = resp
+	}
+	return nil
+}
+
+// parentPipelines runs the parent pipelines declared in aggs on the buckets,
+// the pipelines computing values run first in the order of their references,
+// then bucket_selector filters the buckets, bucket_sort runs last.
+func parentPipelines(aggs map[string]meta.Aggregations, buckets []map[string]interface{}) ([]map[string]interface{}, error) {
+	var valued, selectors, sorts []string
+	for _, name := range sortedNames(aggs) {
+		switch typ, _ := pipelineType(aggs[name]); typ {
+		case "derivative", "cumulative_sum", "moving_fn", "moving_avg", "bucket_script":
+			valued = append(valued, name)
+		case "bucket_selector":
+			selectors = append(selectors, name)
+		case "bucket_sort":
+			sorts = append(sorts, name)
+		}
+	}
+	valued, err := pipelineOrder(aggs, valued)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range valued {
+		typ, pipeline := pipelineType(aggs[name])
+		switch typ {
+		case "derivative":
+			err = derivative(name, pipeline, buckets)
+		case "cumulative_sum":
+			err = cumulativeSum(name, pipeline, buckets)
+		case "moving_fn", "moving_avg":
+			err = movingFunction(typ, name, pipeline, buckets)
+		case "bucket_script":
+			err = bucketScript(name, pipeline, buckets)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range selectors {
+		if buckets, err = bucketSelector(aggs[name].BucketSelector, buckets); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range sorts {
+		if buckets, err = bucketSort(aggs[name].BucketSort, buckets); err != nil {
+			return nil, err
+		}
+	}
+	return buckets, nil
+}
+
+// pipelineOrder sorts the pipelines so that a pipeline runs after the pipelines its buckets_path refers to
+func pipelineOrder(aggs map[string]meta.Aggregations, names []string) ([]string, error) {
+	pending := make(map[string]bool, len(names))
+	for _, name := range names {
+		pending[name] = true
+	}
+	ordered := make([]string, 0, len(names))
+	for len(ordered) < len(names) {
+		progress := false
+		for _, name := range names {
+			if !pending[name] {
+				continue
+			}
+			typ, pipeline := pipelineType(aggs[name])
+			paths, err := bucketsPaths(typ, pipeline.BucketsPath)
+			if err != nil {
+				return nil, err
+			}
+			ready := true
+			for _, path := range paths {
+				if pending[bucketPathName(path)] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				pending[name] = false
+				ordered = append(ordered, name)
+				progress = true
+			}
+		}
+		if !progress {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "pipeline aggregations have cyclic buckets_path")
+		}
+	}
+	return ordered, nil
+}
+
+func derivative(name string, pipeline *meta.AggregationPipeline, buckets []map[string]interface{}) error {
+	path, err := bucketsPaths("derivative", pipeline.BucketsPath)
+	if err != nil {
+		return err
+	}
+	var last *float64
+	for _, bucket := range buckets {
+		v, ok := bucketValue(bucket, path[0], pipeline.GapPolicy)
+		if !ok {
+			last = nil
+			continue
+		}
+		if last != nil {
+			bucket[name] = meta.AggregationResponse{Value: v - *last}
+		}
+		last = &v
+	}
+	return nil
+}
+
+func cumulativeSum(name string, pipeline *meta.AggregationPipeline, buckets []map[string]interface{}) error {
+	path, err := bucketsPaths("cumulative_sum", pipeline.BucketsPath)
+	if err != nil {
+		return err
+	}
+	sum := 0.0
+	for _, bucket := range buckets {
+		if v, ok := bucketValue(bucket, path[0], pipeline.GapPolicy); ok {
+			sum += v
+		}
+		bucket[name] = meta.AggregationResponse{Value: sum}
+	}
+	return nil
+}
+
+// movingFunction runs the function on the values of the window before every bucket,
+// shift moves the window forward, shift = 1 includes the current bucket.
+func movingFunction(typ, name string, pipeline *meta.AggregationPipeline, buckets []map[string]interface{}) error {
+	path, err := bucketsPaths(typ, pipeline.BucketsPath)
+	if err != nil {
+		return err
+	}
+	window, shift := pipeline.Window, pipeline.Shift
+	var fn func(values []interface{}) (float64, error)
+	if typ == "moving_avg" {
+		if window == 0 {
+			window = 5
+		}
+		if fn, err = movingAvgModel(pipeline); err != nil {
+			return err
+		}
+		shift = 0
+	} else {
+		s, params, err := script.FromRequest(pipeline.Script)
+		if err != nil {
+			return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[moving_fn] aggregation [%s] %s", name, err.Error()))
+		}
+		fn = func(values []interface{}) (float64, error) {
+			if params == nil {
+				params = make(map[string]interface{})
+			}
+			v, err := s.Run(map[string]interface{}{
+				"values":          values,
+				"params":          params,
+				"MovingFunctions": movingFunctions,
+			})
+			if err != nil {
+				return 0, err
+			}
+			f, ok := script.ToFloat(v)
+			if !ok {
+				return 0, fmt.Errorf("[moving_fn] script should return a number but got [%v]", v)
+			}
+			return f, nil
+		}
+	}
+
+	values := make([]*float64, len(buckets))
+	for i, bucket := range buckets {
+		if v, ok := bucketValue(bucket, path[0], pipeline.GapPolicy); ok {
+			values[i] = &v
+		}
+	}
+	for i, bucket := range buckets {
+		start, end := i-window+shift, i+shift
+		if start < 0 {
+			start = 0
+		}
+		if end > len(values) {
+			end = len(values)
+		}
+		windowValues := make([]interface{}, 0, window)
+		for j := start; j < end; j++ {
+			if values[j] != nil {
+				windowValues = append(windowValues, *values[j])
+			}
+		}
+		v, err := fn(windowValues)
+		if err != nil {
+			return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[%s] aggregation [%s] %s", typ, name, err.Error()))
+		}
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			bucket[name] = meta.AggregationResponse{Value: v}
+		}
+	}
+	return nil
+}
+
+func movingAvgModel(pipeline *meta.AggregationPipeline) (func(values []interface{}) (float64, error), error) {
+	var fn script.Func
+	switch pipeline.Model {
+	case "", "simple":
+		fn = movingFunctions["unweightedAvg"].(script.Func)
+	case "linear":
+		fn = movingFunctions["linearWeightedAvg"].(script.Func)
+	case "ewma":
+		alpha := 0.3
+		if v, ok := script.ToFloat(pipeline.Settings["alpha"]); ok {
+			alpha = v
+		}
+		return func(values []interface{}) (float64, error) {
+			return ewma(toFloats(values), alpha), nil
+		}, nil
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[moving_avg] aggregation unknown model [%s], such as: simple, linear, ewma", pipeline.Model))
+	}
+	return func(values []interface{}) (float64, error) {
+		v, err := fn(values)
+		if err != nil {
+			return 0, err
+		}
+		return v.(float64), nil
+	}, nil
+}
+
+func bucketScript(name string, pipeline *meta.AggregationPipeline, buckets []map[string]interface{}) error {
+	s, params, err := script.FromRequest(pipeline.Script)
+	if err != nil {
+		return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_script] aggregation [%s] %s", name, err.Error()))
+	}
+	vars, paths, err := bucketsPathVars("bucket_script", pipeline.BucketsPath)
+	if err != nil {
+		return err
+	}
+	for _, bucket := range buckets {
+		scriptParams, ok := bucketScriptParams(bucket, vars, paths, params, pipeline.GapPolicy)
+		if !ok {
+			continue
+		}
+		v, err := s.Run(map[string]interface{}{"params": scriptParams})
+		if err != nil {
+			return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[bucket_script] aggregation [%s] %s", name, err.Error()))
+		}
+		f, ok := script.ToFloat(v)
+		if !ok {
+			return errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[bucket_script] aggregation [%s] script should return a number but got [%v]", name, v))
+		}
+		bucket[name] = meta.AggregationResponse{Value: f}
+	}
+	return nil
+}
+
+func bucketSelector(pipeline *meta.AggregationPipeline, buckets []map[string]interface{}) ([]map[string]interface{}, error) {
+	s, params, err := script.FromRequest(pipeline.Script)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[bucket_selector] aggregation %s", err.Error()))
+	}
+	vars, paths, err := bucketsPathVars("bucket_selector", pipeline.BucketsPath)
+	if err != nil {
+		return nil, err
+	}
+	selected := buckets[:0]
+	for _, bucket := range buckets {
+		scriptParams, ok := bucketScriptParams(bucket, vars, paths, params, pipeline.GapPolicy)
+		if !ok {
+			// gaps are kept
+			selected = append(selected, bucket)
+			continue
+		}
+		keep, err := s.RunBool(map[string]interface{}{"params": scriptParams})
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeRuntimeException, fmt.Sprintf("[bucket_selector] aggregation %s", err.Error()))
+		}
+		if keep {
+			selected = append(selected, bucket)
+		}
+	}
+	return selected, nil
+}
+
+// bucketScriptParams returns the script params with the buckets_path variables, false means a gap should skip
+func bucketScriptParams(bucket map[string]interface{}, vars, paths []string, params map[string]interface{}, gapPolicy string) (map[string]interface{}, bool) {
+	scriptParams := make(map[string]interface{}, len(params)+len(vars))
+	for k, v := range params {
+		scriptParams[k] = v
+	}
+	for i, path := range paths {
+		v, ok := bucketValue(bucket, path, gapPolicy)
+		if !ok {
+			return nil, false
+		}
+		scriptParams[vars[i]] = v
+	}
+	return scriptParams, true
+}
+
+func bucketSort(pipeline *meta.AggregationBucketSort, buckets []map[string]interface{}) ([]map[string]interface{}, error) {
+	fields, err := bucketSortFields(pipeline.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) > 0 {
+		type sortedBucket struct {
+			bucket map[string]interface{}
+			values []interface{}
+		}
+		sorted := make([]sortedBucket, 0, len(buckets))
+		for _, bucket := range buckets {
+			item := sortedBucket{bucket: bucket, values: make([]interface{}, len(fields))}
+			gap := false
+			for i, field := range fields {
+				if field.path == "_key" {
+					item.values[i] = bucket["key"]
+					continue
+				}
+				v, ok := bucketValue(bucket, field.path, pipeline.GapPolicy)
+				if !ok {
+					gap = true
+					break
+				}
+				item.values[i] = v
+			}
+			// the buckets with gaps are skipped
+			if !gap {
+				sorted = append(sorted, item)
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for k, field := range fields {
+				c := compareValues(sorted[i].values[k], sorted[j].values[k])
+				if c == 0 {
+					continue
+				}
+				if field.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+		buckets = make([]map[string]interface{}, 0, len(sorted))
+		for _, item := range sorted {
+			buckets = append(buckets, item.bucket)
+		}
+	}
+
+	from := pipeline.From
+	if from > len(buckets) {
+		from = len(buckets)
+	}
+	buckets = buckets[from:]
+	if pipeline.Size > 0 && pipeline.Size < len(buckets) {
+		buckets = buckets[:pipeline.Size]
+	}
+	return buckets, nil
+}
+
+func compareValues(a, b interface{}) int {
+	af, aok := script.ToFloat(a)
+	bf, bok := script.ToFloat(b)
+	if aok && bok {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func siblingPipeline(typ string, pipeline *meta.AggregationPipeline, level map[string]interface{}) (meta.AggregationResponse, error) {
+	paths, err := bucketsPaths(typ, pipeline.BucketsPath)
+	if err != nil {
+		return meta.AggregationResponse{}, err
+	}
+	elems := strings.SplitN(paths[0], ">", 2)
+	if len(elems) != 2 {
+		return meta.AggregationResponse{}, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] aggregation buckets_path [%s] must be agg>metric", typ, paths[0]))
+	}
+	resp, _ := level[elems[0]].(meta.AggregationResponse)
+	buckets, _ := resp.Buckets.([]map[string]interface{})
+
+	var count int
+	var sum float64
+	var result meta.AggregationResponse
+	for _, bucket := range buckets {
+		v, ok := bucketValue(bucket, elems[1], pipeline.GapPolicy)
+		if !ok {
+			continue
+		}
+		count++
+		sum += v
+		switch typ {
+		case "max_bucket", "min_bucket":
+			key := bucketKey(bucket)
+			if result.Value == nil {
+				result = meta.AggregationResponse{Value: v, Keys: []string{key}}
+				continue
+			}
+			current := result.Value.(float64)
+			switch {
+			case v == current:
+				result.Keys = append(result.Keys, key)
+			case (typ == "max_bucket" && v > current) || (typ == "min_bucket" && v < current):
+				result = meta.AggregationResponse{Value: v, Keys: []string{key}}
+			}
+		}
+	}
+	switch typ {
+	case "avg_bucket":
+		if count > 0 {
+			result.Value = sum / float64(count)
+		}
+	case "sum_bucket":
+		result.Value = sum
+	}
+	return result, nil
+}
+
+func bucketKey(bucket map[string]interface{}) string {
+	if v, ok := bucket["key_as_string"].(string); ok {
+		return v
+	}
+	return fmt.Sprint(bucket["key"])
+}
+
+// bucketValue resolves the path relative to the bucket, false means it is a gap should skip.
+// Empty buckets are gaps unless the policy is keep_values.
+func bucketValue(bucket map[string]interface{}, path string, gapPolicy string) (float64, bool) {
+	v, ok := resolveBucketPath(bucket, path)
+	if ok && gapPolicy != gapPolicyKeepValues && path != "_count" && path != "_key" {
+		if count, _ := script.ToFloat(bucket["doc_count"]); count == 0 {
+			ok = false
+		}
+	}
+	if !ok && gapPolicy == gapPolicyInsertZeros {
+		return 0, true
+	}
+	return v, ok
+}
+
+// resolveBucketPath supports _count, _key, agg, agg.value, agg.metric, agg.percent and agg._bucket_count
+func resolveBucketPath(bucket map[string]interface{}, path string) (float64, bool) {
+	var v interface{}
+	switch path {
+	case "_count":
+		v = bucket["doc_count"]
+	case "_key":
+		v = bucket["key"]
+	default:
+		name, metric := path, "value"
+		if i := strings.IndexByte(path, '.'); i > 0 {
+			name, metric = path[:i], path[i+1:]
+		}
+		resp, ok := bucket[name].(meta.AggregationResponse)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case metric == "_bucket_count":
+			buckets, _ := resp.Buckets.([]map[string]interface{})
+			v = len(buckets)
+		case metric == "value":
+			v = resp.Value
+		case resp.Metrics != nil:
+			// stats.avg
+			v = resp.Metrics[metric]
+		default:
+			// percentiles.99
+			if values, ok := resp.Values.(map[string]interface{}); ok {
+				v = values[metric]
+				if f, err := strconv.ParseFloat(metric, 64); err == nil && v == nil {
+					v = values[percentileKey(f)]
+				}
+			}
+		}
+	}
+	f, ok := script.ToFloat(v)
+	if !ok || math.IsNaN(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+func bucketPathName(path string) string {
+	if i := strings.IndexAny(path, ".>"); i > 0 {
+		return path[:i]
+	}
+	return path
+}
+
+func sortedNames(aggs map[string]meta.Aggregations) []string {
+	names := make([]string, 0, len(aggs))
+	for name := range aggs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// movingFunctions are the functions can be used in moving_fn script, such as: MovingFunctions.unweightedAvg(values)
+var movingFunctions = map[string]interface{}{
+	"max": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("max", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return math.NaN(), nil
+		}
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max, nil
+	}),
+	"min": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("min", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return math.NaN(), nil
+		}
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min, nil
+	}),
+	"sum": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("sum", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum, nil
+	}),
+	"unweightedAvg": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("unweightedAvg", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return math.NaN(), nil
+		}
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values)), nil
+	}),
+	// linearWeightedAvg weights the older values less, the oldest is 1, the newest is len(values)
+	"linearWeightedAvg": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("linearWeightedAvg", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			return math.NaN(), nil
+		}
+		sum, weights := 0.0, 0.0
+		for i, v := range values {
+			sum += v * float64(i+1)
+			weights += float64(i + 1)
+		}
+		return sum / weights, nil
+	}),
+	"stdDev": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("stdDev", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		avg, ok := script.ToFloat(args[1])
+		if !ok {
+			return nil, fmt.Errorf("MovingFunctions.stdDev avg should be a number")
+		}
+		if len(values) == 0 {
+			return math.NaN(), nil
+		}
+		sum := 0.0
+		for _, v := range values {
+			sum += (v - avg) * (v - avg)
+		}
+		return math.Sqrt(sum / float64(len(values))), nil
+	}),
+	"ewma": script.Func(func(args ...interface{}) (interface{}, error) {
+		values, err := movingFunctionValues("ewma", args, 2)
+		if err != nil {
+			return nil, err
+		}
+		alpha, ok := script.ToFloat(args[1])
+		if !ok {
+			return nil, fmt.Errorf("MovingFunctions.ewma alpha should be a number")
+		}
+		return ewma(values, alpha), nil
+	}),
+}
+
+func ewma(values []float64, alpha float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	avg := values[0]
+	for _, v := range values[1:] {
+		avg = alpha*v + (1-alpha)*avg
+	}
+	return avg
+}
+
+func movingFunctionValues(name string, args []interface{}, n int) ([]float64, error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("MovingFunctions.%s expects %d arguments but got %d", name, n, len(args))
+	}
+	values, ok := args[0].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("MovingFunctions.%s expects values but got [%v]", name, args[0])
+	}
+	return toFloats(values), nil
+}
+
+func toFloats(values []interface{}) []float64 {
+	rv := make([]float64, 0, len(values))
+	for _, v := range values {
+		if f, ok := script.ToFloat(v); ok {
+			rv = append(rv, f)
+		}
+	}
+	return rv
+}
@@ -0,0 +1,70 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package collapse
+
+import (
+	"fmt"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/uquery/sort"
+	"github.com/zinclabs/zincsearch/pkg/uquery/source"
+)
+
+// Request checks the collapse field and parses the sort and source of the inner hits
+func Request(c *meta.Collapse, mappings *meta.Mappings) error {
+	if c.Field == "" {
+		return errors.New(errors.ErrorTypeParsingException, "[collapse] must be provided with a [field]")
+	}
+	prop, ok := mappings.GetProperty(c.Field)
+	if !ok {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("no mapping found for `%s` in order to collapse on", c.Field))
+	}
+	if prop.Type != "keyword" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("unknown type for collapse field `%s`, only keywords are supported", c.Field))
+	}
+	if !prop.Sortable && !prop.Aggregatable {
+		return errors.New(errors.ErrorTypeIllegalArgumentException,
+			fmt.Sprintf("cannot collapse on field `%s` without doc values", c.Field))
+	}
+	if c.MaxConcurrentGroupSearches < 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[max_concurrent_group_searches] must be positive")
+	}
+
+	if c.InnerHits == nil {
+		return nil
+	}
+	if c.InnerHits.Name == "" {
+		c.InnerHits.Name = c.Field
+	}
+	if c.InnerHits.From < 0 || c.InnerHits.Size < 0 {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, "[inner_hits] from and size must be positive")
+	}
+	if c.InnerHits.Size == 0 {
+		c.InnerHits.Size = 3 // the default of elasticsearch
+	}
+	var err error
+	if c.InnerHits.Sort != nil {
+		if c.InnerHits.Sort, err = sort.Request(c.InnerHits.Sort); err != nil {
+			return err
+		}
+	}
+	if c.InnerHits.Source, err = source.Request(c.InnerHits.Source); err != nil {
+		return err
+	}
+	return nil
+}
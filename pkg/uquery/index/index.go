@@ -50,7 +50,7 @@ func Request(data map[string]interface{}, enableTextKeywordMapping bool) (*meta.
 		if analyzers, err = zincanalysis.RequestAnalyzer(settings.Analysis); err != nil {
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[index] settings.analysis parse error: %s", err.Error()))
 		}
-		if settings != nil && (settings.NumberOfShards > 0 || settings.NumberOfReplicas > 0 || settings.Analysis != nil) {
+		if settings != nil && (settings.NumberOfShards > 0 || settings.NumberOfReplicas > 0 || settings.Analysis != nil || settings.DefaultPipeline != "" || settings.Lifecycle != nil) {
 			index.Settings = settings
 		}
 	}
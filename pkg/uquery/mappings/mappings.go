@@ -94,7 +94,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 				p := meta.NewProperty("keyword")
 				newProp.AddField("keyword", p)
 			}
-		case "keyword", "numeric", "bool", "date":
+		case "keyword", "numeric", "bool", "date", "geo_point", "ip":
 			newProp = meta.NewProperty(propTypeStr)
 		case "constant_keyword":
 			newProp = meta.NewProperty("keyword")
@@ -106,7 +106,7 @@ func Request(analyzers map[string]*analysis.Analyzer, data map[string]interface{
 			newProp = meta.NewProperty("bool")
 		case "time", "datetime":
 			newProp = meta.NewProperty("date")
-		case "flattened", "object", "nested", "wildcard", "byte", "alias", "geo_point", "ip", "ip_range", "scaled_float":
+		case "flattened", "object", "nested", "wildcard", "byte", "alias", "ip_range", "scaled_float":
 			// ignore
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[mappings] properties [%s] doesn't support type [%s]", field, propTypeStr))
@@ -16,11 +16,68 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 
+	zincquery "github.com/zinclabs/zincsearch/pkg/bluge/query"
 	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
-func BoostingQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[boosting] query doesn't support")
+func BoostingQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	var positive, negative bluge.Query
+	negativeBoost := -1.0
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "positive", "negative":
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] %s doesn't support values of type: %T", k, v))
+			}
+			subq, err := Query(vv, mappings, analyzers)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[%s] failed to parse field", k)).Cause(err)
+			}
+			if k == "positive" {
+				positive = subq
+			} else {
+				negative = subq
+			}
+		case "negative_boost":
+			vv, ok := v.(float64)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] %s doesn't support values of type: %T", k, v))
+			}
+			negativeBoost = vv
+		case "boost":
+			vv, ok := v.(float64)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[boosting] %s doesn't support values of type: %T", k, v))
+			}
+			boost = vv
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[boosting] unknown field [%s]", k))
+		}
+	}
+
+	if positive == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [positive] query to be set")
+	}
+	if negative == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [negative] query to be set")
+	}
+	if negativeBoost < 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[boosting] query requires [negative_boost] to be set to be a positive value")
+	}
+
+	subq := zincquery.NewBoostingQuery(positive, negative, negativeBoost)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
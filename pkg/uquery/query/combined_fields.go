@@ -16,11 +16,127 @@
 package query
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/analysis"
 
+	zincquery "github.com/zinclabs/zincsearch/pkg/bluge/query"
 	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	zincanalysis "github.com/zinclabs/zincsearch/pkg/uquery/analysis"
 )
 
-func CombinedFieldsQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[combined_fields] query doesn't support")
+func CombinedFieldsQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
+	value := new(meta.CombinedFieldsQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "query":
+			vv, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s doesn't support values of type: %T", k, v))
+			}
+			value.Query = vv
+		case "analyzer":
+			value.Analyzer, _ = v.(string)
+		case "fields":
+			vv, ok := v.([]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s doesn't support values of type: %T", k, v))
+			}
+			for _, vvv := range vv {
+				field, ok := vvv.(string)
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s doesn't support values of type: %T", k, vvv))
+				}
+				value.Fields = append(value.Fields, field)
+			}
+		case "boost":
+			value.Boost, _ = v.(float64)
+		case "operator":
+			value.Operator, _ = v.(string)
+		case "minimum_should_match":
+			switch v := v.(type) {
+			case string:
+				if strings.Contains(v, "%") || strings.Contains(v, "<") {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s value only support integer", k))
+				}
+				vi, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s type string convert to int error: %s", k, err))
+				}
+				value.MinimumShouldMatch = float64(vi)
+			case float64:
+				value.MinimumShouldMatch = v
+			default:
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] %s doesn't support values of type: %T", k, v))
+			}
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[combined_fields] unknown field [%s]", k))
+		}
+	}
+	if len(value.Fields) == 0 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[combined_fields] requires [fields] to be set")
+	}
+
+	subq := zincquery.NewCombinedFieldsQuery(value.Query)
+	for _, field := range value.Fields {
+		weight := 1.0
+		if i := strings.LastIndex(field, "^"); i > 0 {
+			w, err := strconv.ParseFloat(field[i+1:], 64)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[combined_fields] field [%s] has an invalid weight", field))
+			}
+			if w < 1.0 {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] field [%s] weight must be greater or equal to 1", field))
+			}
+			field, weight = field[:i], w
+		}
+		if prop, ok := mappings.GetProperty(field); ok && prop.Type != "text" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] field [%s] of type [%s] does not support [combined_fields] queries", field, prop.Type))
+		}
+		subq.AddField(field, weight)
+	}
+
+	// all the fields are combined into one, so they share the analyzer of the first one
+	var zer *analysis.Analyzer
+	if value.Analyzer != "" {
+		var err error
+		if zer, err = zincanalysis.QueryAnalyzer(analyzers, value.Analyzer); err != nil {
+			return nil, err
+		}
+	} else {
+		field := strings.SplitN(value.Fields[0], "^", 2)[0]
+		indexZer, searchZer := zincanalysis.QueryAnalyzerForField(analyzers, mappings, field)
+		if zer = searchZer; zer == nil {
+			zer = indexZer
+		}
+	}
+	if zer != nil {
+		subq.SetAnalyzer(zer)
+	}
+
+	if value.Operator != "" {
+		op := strings.ToUpper(value.Operator)
+		switch op {
+		case "OR":
+			subq.SetOperator(bluge.MatchQueryOperatorOr)
+		case "AND":
+			subq.SetOperator(bluge.MatchQueryOperatorAnd)
+		default:
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[combined_fields] unknown operator %s", op))
+		}
+	}
+	if value.MinimumShouldMatch > 0 {
+		subq.SetMinShould(int(value.MinimumShouldMatch)) // lgtm[go/hardcoded-credentials]
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
 }
@@ -16,11 +16,53 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
 
 	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
 )
 
+// ExistsQuery matches the documents which have an indexed value for the field,
+// the field can be a wildcard pattern like user.*
 func ExistsQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[exists] query doesn't support")
+	value := new(meta.ExistsQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "field":
+			field, ok := v.(string)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[exists] %s doesn't support values of type: %T", k, v))
+			}
+			value.Field = field
+		case "boost":
+			boost, ok := v.(float64)
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[exists] %s doesn't support values of type: %T", k, v))
+			}
+			value.Boost = boost
+		default:
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[exists] unknown field [%s]", k))
+		}
+	}
+	if value.Field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[exists] must be provided with a [field]")
+	}
+
+	if strings.ContainsAny(value.Field, "*?") {
+		subq := bluge.NewWildcardQuery(value.Field).SetField(meta.FieldNamesFieldName)
+		if value.Boost >= 0 {
+			subq.SetBoost(value.Boost)
+		}
+		return subq, nil
+	}
+	subq := bluge.NewTermQuery(value.Field).SetField(meta.FieldNamesFieldName)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	return subq, nil
 }
@@ -16,23 +16,196 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
+	"github.com/blugelabs/bluge/numeric/geo"
 
 	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
 
-func GeoBoundingBoxQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_bounding_box] query doesn't support")
+// GeoBoundingBoxQuery
+// {"geo_bounding_box":{"field":{"top_left":{"lat":40.73,"lon":-74.1},"bottom_right":{"lat":40.01,"lon":-71.12}}}}
+// {"geo_bounding_box":{"field":{"top":40.73,"left":-74.1,"bottom":40.01,"right":-71.12}}}
+func GeoBoundingBoxQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	boost := -1.0
+	var top, left, bottom, right *float64
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		case "validation_method", "ignore_unmapped", "type", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s doesn't support values of type: %T", k, v))
+			}
+			for k, v := range vv {
+				k := strings.ToLower(k)
+				switch k {
+				case "top_left", "bottom_right", "top_right", "bottom_left":
+					lon, lat, err := zutils.ParseGeoPoint(v)
+					if err != nil {
+						return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s %s", k, err.Error()))
+					}
+					switch k {
+					case "top_left":
+						top, left = &lat, &lon
+					case "bottom_right":
+						bottom, right = &lat, &lon
+					case "top_right":
+						top, right = &lat, &lon
+					case "bottom_left":
+						bottom, left = &lat, &lon
+					}
+				case "top", "left", "bottom", "right":
+					f, err := zutils.ToFloat64(v)
+					if err != nil {
+						return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] %s should be a number", k))
+					}
+					switch k {
+					case "top":
+						top = &f
+					case "left":
+						left = &f
+					case "bottom":
+						bottom = &f
+					case "right":
+						right = &f
+					}
+				default:
+					return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_bounding_box] unknown field [%s]", k))
+				}
+			}
+		}
+	}
+
+	if err := checkGeoField("geo_bounding_box", field, mappings); err != nil {
+		return nil, err
+	}
+	if top == nil || left == nil || bottom == nil || right == nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_bounding_box] should define the top, left, bottom and right of the box")
+	}
+
+	subq := bluge.NewGeoBoundingBoxQuery(*left, *top, *right, *bottom).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
 }
 
-func GeoDistanceQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_distance] query doesn't support")
+// GeoDistanceQuery
+// {"geo_distance":{"distance":"200km","field":{"lat":40,"lon":-70}}}
+func GeoDistanceQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	value := new(meta.GeoDistanceQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "distance":
+			value.Distance, _ = zutils.ToString(v)
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		case "distance_type", "validation_method", "ignore_unmapped", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] query doesn't support multiple fields")
+			}
+			field = k
+			value.Location = v
+		}
+	}
+
+	if err := checkGeoField("geo_distance", field, mappings); err != nil {
+		return nil, err
+	}
+	if value.Distance == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_distance] distance should be defined")
+	}
+	if _, err := geo.ParseDistance(value.Distance); err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] distance [%s] parse err: %s", value.Distance, err.Error()))
+	}
+	lon, lat, err := zutils.ParseGeoPoint(value.Location)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_distance] %s %s", field, err.Error()))
+	}
+
+	subq := bluge.NewGeoDistanceQuery(lon, lat, value.Distance).SetField(field)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	return subq, nil
 }
 
-func GeoPolygonQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_polygon] query doesn't support")
+// GeoPolygonQuery
+// {"geo_polygon":{"field":{"points":[{"lat":40,"lon":-70},"30,-80",[-90,20]]}}}
+func GeoPolygonQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	field := ""
+	value := new(meta.GeoPolygonQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		switch strings.ToLower(k) {
+		case "boost":
+			value.Boost, _ = zutils.ToFloat64(v)
+		case "validation_method", "ignore_unmapped", "_name":
+			// ignore
+		default:
+			if field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] query doesn't support multiple fields")
+			}
+			field = k
+			vv, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] %s doesn't support values of type: %T", k, v))
+			}
+			if value.Points, ok = vv["points"].([]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] points should be an array")
+			}
+		}
+	}
+
+	if err := checkGeoField("geo_polygon", field, mappings); err != nil {
+		return nil, err
+	}
+	if len(value.Points) < 3 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[geo_polygon] too few points defined for polygon, at least 3 points")
+	}
+	points := make([]geo.Point, 0, len(value.Points))
+	for _, point := range value.Points {
+		lon, lat, err := zutils.ParseGeoPoint(point)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[geo_polygon] points %s", err.Error()))
+		}
+		points = append(points, geo.Point{Lon: lon, Lat: lat})
+	}
+
+	subq := bluge.NewGeoBoundingPolygonQuery(points).SetField(field)
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+	return subq, nil
 }
 
 func GeoShapeQuery(query map[string]interface{}) (bluge.Query, error) {
 	return nil, errors.New(errors.ErrorTypeNotImplemented, "[geo_shape] query doesn't support")
 }
+
+func checkGeoField(name, field string, mappings *meta.Mappings) error {
+	if field == "" {
+		return errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[%s] field should be defined", name))
+	}
+	if prop, ok := mappings.GetProperty(field); ok && prop.Type != "geo_point" {
+		return errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[%s] field [%s] is not a geo_point field", name, field))
+	}
+	return nil
+}
@@ -67,7 +67,7 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[bool] failed to parse field").Cause(err)
 			}
 		case "boosting":
-			if subq, err = BoostingQuery(v); err != nil {
+			if subq, err = BoostingQuery(v, mappings, analyzers); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[boosting] failed to parse field").Cause(err)
 			}
 		case "match":
@@ -99,7 +99,7 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[match_none] failed to parse field").Cause(err)
 			}
 		case "combined_fields":
-			if subq, err = CombinedFieldsQuery(v); err != nil {
+			if subq, err = CombinedFieldsQuery(v, mappings, analyzers); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[combined_fields] failed to parse field").Cause(err)
 			}
 		case "query_string":
@@ -147,19 +147,19 @@ func Query(query interface{}, mappings *meta.Mappings, analyzers map[string]*ana
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[terms] failed to parse field").Cause(err)
 			}
 		case "terms_set":
-			if subq, err = TermsSetQuery(v); err != nil {
+			if subq, err = TermsSetQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[terms_set] failed to parse field").Cause(err)
 			}
 		case "geo_bounding_box":
-			if subq, err = GeoBoundingBoxQuery(v); err != nil {
+			if subq, err = GeoBoundingBoxQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_bounding_box] failed to parse field").Cause(err)
 			}
 		case "geo_distance":
-			if subq, err = GeoDistanceQuery(v); err != nil {
+			if subq, err = GeoDistanceQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_distance] failed to parse field").Cause(err)
 			}
 		case "geo_polygon":
-			if subq, err = GeoPolygonQuery(v); err != nil {
+			if subq, err = GeoPolygonQuery(v, mappings); err != nil {
 				return nil, errors.New(errors.ErrorTypeXContentParseException, "[geo_polygon] failed to parse field").Cause(err)
 			}
 		case "geo_shape":
@@ -17,84 +17,442 @@ package query
 
 import (
 	"fmt"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/blugelabs/bluge"
 	"github.com/blugelabs/bluge/analysis"
 	"github.com/blugelabs/bluge/analysis/analyzer"
-	querystr "github.com/blugelabs/query_string"
 
 	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	zincanalysis "github.com/zinclabs/zincsearch/pkg/uquery/analysis"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
 )
 
 func QueryStringQuery(query map[string]interface{}, mappings *meta.Mappings, analyzers map[string]*analysis.Analyzer) (bluge.Query, error) {
 	value := new(meta.QueryStringQuery)
+	value.Boost = -1.0
+	value.PhraseSlop = 0
 	for k, v := range query {
 		k := strings.ToLower(k)
+		var ok bool
 		switch k {
 		case "query":
-			value.Query = v.(string)
+			value.Query, ok = v.(string)
 		case "analyzer":
-			value.Analyzer = v.(string)
+			value.Analyzer, ok = v.(string)
 		case "fields":
-			if vv, ok := v.([]interface{}); ok {
+			var vv []interface{}
+			if vv, ok = v.([]interface{}); ok {
 				for _, vvv := range vv {
-					value.Fields = append(value.Fields, vvv.(string))
+					field, isString := vvv.(string)
+					if !isString {
+						ok = false
+						break
+					}
+					value.Fields = append(value.Fields, field)
 				}
 			}
 		case "default_field":
-			value.DefaultField = v.(string)
+			value.DefaultField, ok = v.(string)
 		case "default_operator":
-			value.DefaultOperator = v.(string)
+			value.DefaultOperator, ok = v.(string)
 		case "boost":
-			value.Boost = v.(float64)
+			value.Boost, ok = v.(float64)
+		case "lenient":
+			value.Lenient, ok = v.(bool)
+		case "allow_leading_wildcard":
+			var allow bool
+			allow, ok = v.(bool)
+			value.AllowLeadingWildcard = &allow
 		case "analyze_wildcard":
-			// noop
+			value.AnalyzeWildcard, ok = v.(bool)
+		case "fuzziness":
+			value.Fuzziness, ok = v, true
+		case "phrase_slop":
+			var slop float64
+			slop, ok = v.(float64)
+			value.PhraseSlop = int(slop)
 		default:
 			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] unsupported children %s", k))
 		}
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] %s doesn't support values of type: %T", k, v))
+		}
+	}
+
+	b := &queryStringBuilder{
+		value:                value,
+		mappings:             mappings,
+		analyzers:            analyzers,
+		allowLeadingWildcard: value.AllowLeadingWildcard == nil || *value.AllowLeadingWildcard,
+		operator:             bluge.MatchQueryOperatorOr,
+		fuzziness:            value.Fuzziness,
+	}
+	if b.fuzziness == nil {
+		b.fuzziness = "AUTO"
+	}
+	switch strings.ToUpper(value.DefaultOperator) {
+	case "", "OR":
+	case "AND":
+		b.operator = bluge.MatchQueryOperatorAnd
+	default:
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[query_string] unknown default_operator %s", value.DefaultOperator))
+	}
+	if value.Analyzer != "" {
+		zer, err := zincanalysis.QueryAnalyzer(analyzers, value.Analyzer)
+		if err != nil {
+			return nil, err
+		}
+		b.analyzer = zer
+	}
+
+	// the terms without a field search the fields, the default_field or all the fields
+	fields := value.Fields
+	if len(fields) == 0 && value.DefaultField != "" {
+		fields = []string{value.DefaultField}
+	}
+	for _, field := range fields {
+		boost := -1.0
+		if i := strings.LastIndex(field, "^"); i > 0 {
+			v, err := strconv.ParseFloat(field[i+1:], 64)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] has an invalid boost", field))
+			}
+			field, boost = field[:i], v
+		}
+		for _, name := range b.expandField(field) {
+			b.defaultFields = append(b.defaultFields, queryStringField{name: name, boost: boost})
+		}
+	}
+	if len(fields) == 0 {
+		b.defaultFields = []queryStringField{{name: "_all", boost: -1.0}}
+	}
+
+	node, err := parseQueryStringSyntax(value.Query, b.operator == bluge.MatchQueryOperatorAnd)
+	if err != nil {
+		return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] failed to parse query [%s]: %s", value.Query, err.Error()))
+	}
+	if len(node.clauses) == 0 {
+		return bluge.NewMatchNoneQuery(), nil
+	}
+	subq, err := b.buildBool(node, "")
+	if err != nil {
+		return nil, err
+	}
+	return queryStringBoost(subq, value.Boost), nil
+}
+
+type queryStringField struct {
+	name  string
+	boost float64
+}
+
+type queryStringBuilder struct {
+	value                *meta.QueryStringQuery
+	mappings             *meta.Mappings
+	analyzers            map[string]*analysis.Analyzer
+	analyzer             *analysis.Analyzer
+	defaultFields        []queryStringField
+	allowLeadingWildcard bool
+	operator             bluge.MatchQueryOperator
+	fuzziness            interface{}
+}
+
+// expandField returns the mapped fields matching a pattern like message*, * means all the fields
+func (b *queryStringBuilder) expandField(field string) []string {
+	if field == "*" {
+		return []string{"_all"}
+	}
+	if !strings.ContainsAny(field, "*?") {
+		return []string{field}
+	}
+	var names []string
+	for name, prop := range b.mappings.ListProperty() {
+		if !prop.Index || name == meta.TimeFieldName {
+			continue
+		}
+		if ok, _ := path.Match(field, name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func (b *queryStringBuilder) buildBool(node *qsBoolNode, field string) (bluge.Query, error) {
+	if len(node.clauses) == 1 && node.clauses[0].occur != qsMustNot {
+		return b.build(node.clauses[0].node, field)
+	}
+	boolQuery := bluge.NewBooleanQuery()
+	for _, clause := range node.clauses {
+		subq, err := b.build(clause.node, field)
+		if err != nil {
+			return nil, err
+		}
+		switch clause.occur {
+		case qsMust:
+			boolQuery.AddMust(subq)
+		case qsMustNot:
+			boolQuery.AddMustNot(subq)
+		default:
+			boolQuery.AddShould(subq)
+		}
+	}
+	return boolQuery, nil
+}
+
+func (b *queryStringBuilder) build(node qsNode, field string) (bluge.Query, error) {
+	switch n := node.(type) {
+	case *qsGroupNode:
+		if n.field != "" {
+			field = n.field
+		}
+		subq, err := b.buildBool(n.query, field)
+		if err != nil {
+			return nil, err
+		}
+		return queryStringBoost(subq, n.boost), nil
+	case *qsTermNode:
+		if n.field != "" {
+			field = n.field
+		}
+		if field == "_exists_" {
+			subq, err := ExistsQuery(map[string]interface{}{"field": n.text})
+			if err != nil {
+				return nil, err
+			}
+			return queryStringBoost(subq, n.boost), nil
+		}
+		return b.eachField(field, n.boost, func(field string) (bluge.Query, error) {
+			return b.termQuery(field, n)
+		})
+	case *qsRangeNode:
+		if n.field != "" {
+			field = n.field
+		}
+		return b.eachField(field, n.boost, func(field string) (bluge.Query, error) {
+			return b.rangeQuery(field, n)
+		})
+	}
+	return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] unknown node %T", node))
+}
+
+// eachField builds the query for every field the name stands for and matches any of them
+func (b *queryStringBuilder) eachField(name string, boost float64, fn func(field string) (bluge.Query, error)) (bluge.Query, error) {
+	var fields []queryStringField
+	if name == "" {
+		fields = b.defaultFields
+	} else {
+		for _, field := range b.expandField(name) {
+			fields = append(fields, queryStringField{name: field, boost: -1.0})
+		}
+	}
+	if len(fields) == 0 {
+		return bluge.NewMatchNoneQuery(), nil
+	}
+
+	queries := make([]bluge.Query, 0, len(fields))
+	for _, field := range fields {
+		subq, err := fn(field.name)
+		if err != nil {
+			if !b.value.Lenient {
+				return nil, err
+			}
+			subq = bluge.NewMatchNoneQuery()
+		}
+		queries = append(queries, queryStringBoost(subq, field.boost))
+	}
+	if len(queries) == 1 {
+		return queryStringBoost(queries[0], boost), nil
 	}
+	return queryStringBoost(bluge.NewBooleanQuery().AddShould(queries...), boost), nil
+}
 
-	options := querystr.DefaultOptions()
+func (b *queryStringBuilder) fieldType(field string) (meta.Property, string) {
+	switch field {
+	case "_all":
+		return meta.Property{}, "text"
+	case "_id":
+		return meta.Property{}, "keyword"
+	}
+	prop, ok := b.mappings.GetProperty(field)
+	if !ok {
+		return prop, "text"
+	}
+	return prop, prop.Type
+}
 
-	// TODO fields
-	// TODO default_field
-	// TODO default_operator
-	// TODO boost
+func (b *queryStringBuilder) fieldAnalyzer(field string) *analysis.Analyzer {
+	if b.analyzer != nil {
+		return b.analyzer
+	}
+	indexZer, searchZer := zincanalysis.QueryAnalyzerForField(b.analyzers, b.mappings, field)
+	if searchZer != nil {
+		return searchZer
+	}
+	if indexZer != nil {
+		return indexZer
+	}
+	return analyzer.NewStandardAnalyzer()
+}
 
-	zer, _ := zincanalysis.QueryAnalyzer(analyzers, value.Analyzer)
-	if zer == nil {
-		zer = analyzer.NewStandardAnalyzer()
+func (b *queryStringBuilder) termQuery(field string, n *qsTermNode) (bluge.Query, error) {
+	prop, typ := b.fieldType(field)
+	if n.regexp {
+		return bluge.NewRegexpQuery(n.text).SetField(field), nil
+	}
+	if n.wildcard {
+		if n.text == "*" {
+			if field == "_all" {
+				return bluge.NewMatchAllQuery(), nil
+			}
+			return ExistsQuery(map[string]interface{}{"field": field})
+		}
+		if !b.allowLeadingWildcard && strings.IndexAny(n.text, "*?") == 0 {
+			return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[query_string] leading wildcard is not allowed: %s", n.text))
+		}
+		text := n.text
+		if typ == "text" {
+			text = b.wildcardText(field, text)
+		}
+		return bluge.NewWildcardQuery(text).SetField(field), nil
 	}
-	options.WithDefaultAnalyzer(zer)
 
-	if len(value.Fields) == 0 {
-		for field, prop := range mappings.ListProperty() {
-			if prop.Type == "text" || prop.Type == "keyword" {
-				value.Fields = append(value.Fields, field)
+	switch typ {
+	case "text":
+		zer := b.fieldAnalyzer(field)
+		if n.phrase {
+			slop := n.slop
+			if slop < 0 {
+				slop = b.value.PhraseSlop
 			}
+			return bluge.NewMatchPhraseQuery(n.text).SetField(field).SetAnalyzer(zer).SetSlop(slop), nil
+		}
+		subq := bluge.NewMatchQuery(n.text).SetField(field).SetAnalyzer(zer).SetOperator(b.operator)
+		if n.fuzzy {
+			subq.SetFuzziness(b.fuzzinessOf(n, zer))
+		}
+		return subq, nil
+	case "keyword":
+		if n.fuzzy {
+			return bluge.NewFuzzyQuery(n.text).SetField(field).SetFuzziness(b.fuzzinessOf(n, nil)), nil
+		}
+		return bluge.NewTermQuery(n.text).SetField(field), nil
+	case "numeric":
+		return TermQueryNumeric(field, &meta.TermQuery{Value: n.text, Boost: -1})
+	case "bool":
+		return TermQueryBool(field, &meta.TermQuery{Value: n.text, Boost: -1})
+	case "ip":
+		return TermQueryIP(field, &meta.TermQuery{Value: n.text, Boost: -1})
+	case "date", "time":
+		t, err := zutils.ParseTime(n.text, prop.Format, prop.TimeZone)
+		if err != nil {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] value [%s] parse err: %s", field, n.text, err.Error()))
 		}
+		return bluge.NewDateRangeInclusiveQuery(t, t, true, true).SetField(field), nil
 	}
-	for _, field := range value.Fields {
-		var zer *analysis.Analyzer
-		prop, _ := mappings.GetProperty(field)
-		if prop.Type == "keyword" {
-			zer = analyzer.NewKeywordAnalyzer()
+	return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[query_string] field [%s] of type [%s] doesn't support term queries", field, typ))
+}
+
+func (b *queryStringBuilder) fuzzinessOf(n *qsTermNode, zer *analysis.Analyzer) int {
+	var fuzziness interface{} = n.fuzziness
+	if n.fuzziness == "" {
+		fuzziness = b.fuzziness
+	}
+	v := ParseFuzziness(fuzziness, n.text, zer)
+	if v > 2 {
+		v = 2
+	}
+	return v
+}
+
+// wildcardText normalizes the text parts of a wildcard term for a text field,
+// they are analyzed with analyze_wildcard and only lowercased without it.
+func (b *queryStringBuilder) wildcardText(field, text string) string {
+	if !b.value.AnalyzeWildcard {
+		return strings.ToLower(text)
+	}
+	zer := b.fieldAnalyzer(field)
+	var sb strings.Builder
+	start := 0
+	flush := func(end int) {
+		if start >= end {
+			return
+		}
+		chunk := text[start:end]
+		if tokens := zer.Analyze([]byte(chunk)); len(tokens) == 1 {
+			sb.Write(tokens[0].Term)
 		} else {
-			indexZer, searchZer := zincanalysis.QueryAnalyzerForField(analyzers, mappings, field)
-			if zer == nil && searchZer != nil {
-				zer = searchZer
+			sb.WriteString(strings.ToLower(chunk))
+		}
+	}
+	for i, c := range text {
+		if c == '*' || c == '?' {
+			flush(i)
+			sb.WriteRune(c)
+			start = i + 1
+		}
+	}
+	flush(len(text))
+	return sb.String()
+}
+
+func (b *queryStringBuilder) rangeQuery(field string, n *qsRangeNode) (bluge.Query, error) {
+	prop, typ := b.fieldType(field)
+	switch typ {
+	case "numeric":
+		min, max := bluge.MinNumeric, bluge.MaxNumeric
+		var err error
+		if n.from != "" {
+			if min, err = strconv.ParseFloat(n.from, 64); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] value [%s] is not a number", field, n.from))
+			}
+		}
+		if n.to != "" {
+			if max, err = strconv.ParseFloat(n.to, 64); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] value [%s] is not a number", field, n.to))
 			}
-			if zer == nil && indexZer != nil {
-				zer = indexZer
+		}
+		return bluge.NewNumericRangeInclusiveQuery(min, max, n.includeFrom, n.includeTo).SetField(field), nil
+	case "date", "time":
+		var min, max time.Time
+		var err error
+		if n.from != "" {
+			if min, err = zutils.ParseTime(n.from, prop.Format, prop.TimeZone); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] value [%s] parse err: %s", field, n.from, err.Error()))
 			}
 		}
-		if zer != nil {
-			options.WithAnalyzerForField(field, zer)
+		if n.to != "" {
+			if max, err = zutils.ParseTime(n.to, prop.Format, prop.TimeZone); err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[query_string] field [%s] value [%s] parse err: %s", field, n.to, err.Error()))
+			}
 		}
+		return bluge.NewDateRangeInclusiveQuery(min.UTC(), max.UTC(), n.includeFrom, n.includeTo).SetField(field), nil
+	case "ip":
+		query := make(map[string]interface{})
+		if n.from != "" {
+			query[map[bool]string{true: "gte", false: "gt"}[n.includeFrom]] = n.from
+		}
+		if n.to != "" {
+			query[map[bool]string{true: "lte", false: "lt"}[n.includeTo]] = n.to
+		}
+		return RangeQueryIP(field, query)
+	case "text", "keyword":
+		return bluge.NewTermRangeInclusiveQuery(n.from, n.to, n.includeFrom, n.includeTo).SetField(field), nil
 	}
+	return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[query_string] field [%s] of type [%s] doesn't support range queries", field, typ))
+}
 
-	return querystr.ParseQueryString(value.Query, options)
+// queryStringBoost boosts any query, a negative boost keeps it as is
+func queryStringBoost(q bluge.Query, boost float64) bluge.Query {
+	if boost < 0 {
+		return q
+	}
+	if bq, ok := q.(*bluge.BooleanQuery); ok {
+		return bq.SetBoost(boost)
+	}
+	return bluge.NewBooleanQuery().AddMust(q).SetBoost(boost)
 }
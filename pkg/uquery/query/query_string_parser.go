@@ -0,0 +1,569 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the lucene query syntax used by query_string, e.g.
+// status:error AND host:web-* -level:(debug OR trace) date:[2022-01-01 TO *] "quick fox"~2 name:jon~1^2
+
+type qsTokenType int
+
+const (
+	qsTokenEOF qsTokenType = iota
+	qsTokenTerm
+	qsTokenPhrase
+	qsTokenRegexp
+	qsTokenRange
+	qsTokenCompare
+	qsTokenColon
+	qsTokenLParen
+	qsTokenRParen
+	qsTokenPlus
+	qsTokenMinus
+	qsTokenNot
+	qsTokenAnd
+	qsTokenOr
+	qsTokenBoost
+	qsTokenTilde
+)
+
+type qsToken struct {
+	typ      qsTokenType
+	text     string
+	wildcard bool // the term has an unescaped * or ?
+
+	// range and compare
+	op          string
+	from, to    string
+	includeFrom bool
+	includeTo   bool
+}
+
+type qsLexer struct {
+	input  []rune
+	pos    int
+	tokens []qsToken
+}
+
+func qsTokenize(query string) ([]qsToken, error) {
+	l := &qsLexer{input: []rune(query)}
+	for {
+		l.skipSpaces()
+		if l.pos >= len(l.input) {
+			break
+		}
+		if err := l.lexToken(); err != nil {
+			return nil, err
+		}
+	}
+	return l.tokens, nil
+}
+
+func (l *qsLexer) skipSpaces() {
+	for l.pos < len(l.input) && unicode.IsSpace(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+func (l *qsLexer) emit(t qsToken) {
+	l.tokens = append(l.tokens, t)
+}
+
+// afterValue reports if the last token can be followed by a boost or a fuzziness
+func (l *qsLexer) afterValue() bool {
+	if len(l.tokens) == 0 {
+		return false
+	}
+	switch l.tokens[len(l.tokens)-1].typ {
+	case qsTokenTerm, qsTokenPhrase, qsTokenRegexp, qsTokenRange, qsTokenCompare, qsTokenRParen, qsTokenBoost, qsTokenTilde:
+		return true
+	}
+	return false
+}
+
+func (l *qsLexer) lexToken() error {
+	c := l.input[l.pos]
+	switch {
+	case c == '(':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenLParen})
+	case c == ')':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenRParen})
+	case c == ':':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenColon})
+		return l.lexCompare()
+	case c == '+':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenPlus})
+	case c == '-':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenMinus})
+	case c == '!':
+		l.pos++
+		l.emit(qsToken{typ: qsTokenNot})
+	case c == '&' && l.peekRune(1) == '&':
+		l.pos += 2
+		l.emit(qsToken{typ: qsTokenAnd})
+	case c == '|' && l.peekRune(1) == '|':
+		l.pos += 2
+		l.emit(qsToken{typ: qsTokenOr})
+	case (c == '^' || c == '~') && l.afterValue():
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.input) && (unicode.IsDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+			l.pos++
+		}
+		text := string(l.input[start:l.pos])
+		if c == '^' {
+			if text == "" {
+				return fmt.Errorf("boost requires a number at position %d", start)
+			}
+			l.emit(qsToken{typ: qsTokenBoost, text: text})
+		} else {
+			l.emit(qsToken{typ: qsTokenTilde, text: text})
+		}
+	case c == '"':
+		text, err := l.lexQuoted('"')
+		if err != nil {
+			return err
+		}
+		l.emit(qsToken{typ: qsTokenPhrase, text: text})
+	case c == '/':
+		text, err := l.lexQuoted('/')
+		if err != nil {
+			return err
+		}
+		l.emit(qsToken{typ: qsTokenRegexp, text: text})
+	case c == '[' || c == '{':
+		return l.lexRange()
+	default:
+		start := l.pos
+		t := l.lexTerm()
+		if l.pos == start {
+			return fmt.Errorf("unexpected [%c] at position %d", c, start)
+		}
+		if !t.wildcard {
+			switch t.text {
+			case "AND":
+				t = qsToken{typ: qsTokenAnd}
+			case "OR":
+				t = qsToken{typ: qsTokenOr}
+			case "NOT":
+				t = qsToken{typ: qsTokenNot}
+			}
+		}
+		l.emit(t)
+	}
+	return nil
+}
+
+func (l *qsLexer) peekRune(n int) rune {
+	if l.pos+n < len(l.input) {
+		return l.input[l.pos+n]
+	}
+	return 0
+}
+
+func (l *qsLexer) lexTerm() qsToken {
+	var sb strings.Builder
+	t := qsToken{typ: qsTokenTerm}
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == '\\' && l.pos+1 < len(l.input) {
+			sb.WriteRune(l.input[l.pos+1])
+			l.pos += 2
+			continue
+		}
+		if unicode.IsSpace(c) || strings.ContainsRune(`():^~"[]{}`, c) {
+			break
+		}
+		if c == '*' || c == '?' {
+			t.wildcard = true
+		}
+		sb.WriteRune(c)
+		l.pos++
+	}
+	t.text = sb.String()
+	return t
+}
+
+// lexQuoted reads a phrase or a regexp, the escaped delimiters are kept without the backslash
+func (l *qsLexer) lexQuoted(delim rune) (string, error) {
+	start := l.pos
+	l.pos++
+	var sb strings.Builder
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		if c == '\\' && l.pos+1 < len(l.input) {
+			if l.input[l.pos+1] != delim {
+				sb.WriteRune(c)
+			}
+			sb.WriteRune(l.input[l.pos+1])
+			l.pos += 2
+			continue
+		}
+		l.pos++
+		if c == delim {
+			return sb.String(), nil
+		}
+		sb.WriteRune(c)
+	}
+	return "", fmt.Errorf("missing closing [%c] for the one at position %d", delim, start)
+}
+
+// lexRange reads [from TO to] or {from TO to}, * is an unbounded end
+func (l *qsLexer) lexRange() error {
+	start := l.pos
+	t := qsToken{typ: qsTokenRange, includeFrom: l.input[l.pos] == '['}
+	l.pos++
+	var parts []string
+	var sb strings.Builder
+	closed := false
+	for l.pos < len(l.input) && !closed {
+		c := l.input[l.pos]
+		switch {
+		case c == '"':
+			text, err := l.lexQuoted('"')
+			if err != nil {
+				return err
+			}
+			sb.WriteString(text)
+			continue
+		case c == '\\' && l.pos+1 < len(l.input):
+			sb.WriteRune(l.input[l.pos+1])
+			l.pos += 2
+			continue
+		case c == ']' || c == '}':
+			t.includeTo = c == ']'
+			closed = true
+		case unicode.IsSpace(c):
+			if sb.Len() > 0 {
+				parts = append(parts, sb.String())
+				sb.Reset()
+			}
+		default:
+			sb.WriteRune(c)
+		}
+		l.pos++
+	}
+	if sb.Len() > 0 {
+		parts = append(parts, sb.String())
+	}
+	if !closed || len(parts) != 3 || parts[1] != "TO" {
+		return fmt.Errorf("malformed range at position %d, expected [from TO to]", start)
+	}
+	t.from, t.to = parts[0], parts[2]
+	if t.from == "*" {
+		t.from = ""
+	}
+	if t.to == "*" {
+		t.to = ""
+	}
+	l.emit(t)
+	return nil
+}
+
+// lexCompare reads the value of field:>value, field:>=value, field:<value or field:<=value
+func (l *qsLexer) lexCompare() error {
+	if l.pos >= len(l.input) || (l.input[l.pos] != '>' && l.input[l.pos] != '<') {
+		return nil
+	}
+	op := string(l.input[l.pos])
+	l.pos++
+	if l.pos < len(l.input) && l.input[l.pos] == '=' {
+		op += "="
+		l.pos++
+	}
+	var value string
+	if l.pos < len(l.input) && l.input[l.pos] == '"' {
+		text, err := l.lexQuoted('"')
+		if err != nil {
+			return err
+		}
+		value = text
+	} else {
+		start := l.pos
+		for l.pos < len(l.input) && !unicode.IsSpace(l.input[l.pos]) && l.input[l.pos] != ')' && l.input[l.pos] != '^' {
+			l.pos++
+		}
+		value = string(l.input[start:l.pos])
+	}
+	if value == "" {
+		return fmt.Errorf("missing value after [%s]", op)
+	}
+	t := qsToken{typ: qsTokenCompare, op: op}
+	switch op {
+	case ">", ">=":
+		t.from, t.includeFrom = value, op == ">="
+	default:
+		t.to, t.includeTo = value, op == "<="
+	}
+	l.emit(t)
+	return nil
+}
+
+type qsOccur int
+
+const (
+	qsShould qsOccur = iota
+	qsMust
+	qsMustNot
+)
+
+type qsNode interface{}
+
+type qsClause struct {
+	occur qsOccur
+	node  qsNode
+}
+
+type qsBoolNode struct {
+	clauses []qsClause
+}
+
+type qsGroupNode struct {
+	field string
+	query *qsBoolNode
+	boost float64
+}
+
+type qsTermNode struct {
+	field     string
+	text      string
+	phrase    bool
+	regexp    bool
+	wildcard  bool
+	fuzzy     bool
+	fuzziness string // empty uses the default fuzziness
+	slop      int    // -1 uses the default phrase_slop
+	boost     float64
+}
+
+type qsRangeNode struct {
+	field       string
+	from, to    string
+	includeFrom bool
+	includeTo   bool
+	boost       float64
+}
+
+type qsParser struct {
+	tokens     []qsToken
+	pos        int
+	defaultAnd bool
+}
+
+// parseQueryStringSyntax parses the query, defaultAnd makes the clauses without operator required
+func parseQueryStringSyntax(query string, defaultAnd bool) (*qsBoolNode, error) {
+	tokens, err := qsTokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &qsParser{tokens: tokens, defaultAnd: defaultAnd}
+	node, err := p.parseQuery(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().typ != qsTokenEOF {
+		return nil, fmt.Errorf("unexpected [)]")
+	}
+	return node, nil
+}
+
+func (p *qsParser) peek() qsToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return qsToken{typ: qsTokenEOF}
+}
+
+func (p *qsParser) next() qsToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *qsParser) parseQuery(inGroup bool) (*qsBoolNode, error) {
+	node := new(qsBoolNode)
+	for {
+		t := p.peek()
+		if t.typ == qsTokenEOF {
+			if inGroup {
+				return nil, fmt.Errorf("missing [)]")
+			}
+			return node, nil
+		}
+		if t.typ == qsTokenRParen {
+			return node, nil
+		}
+
+		conj := qsTokenEOF
+		if t.typ == qsTokenAnd || t.typ == qsTokenOr {
+			conj = t.typ
+			p.next()
+		}
+		prohibited, required := false, false
+		switch p.peek().typ {
+		case qsTokenNot, qsTokenMinus:
+			prohibited = true
+			p.next()
+		case qsTokenPlus:
+			required = true
+			p.next()
+		}
+		clause, err := p.parseClause()
+		if err != nil {
+			return nil, err
+		}
+		node.add(conj, prohibited, required, p.defaultAnd, clause)
+	}
+}
+
+// add follows the rules of the lucene classic query parser for mixing AND, OR and the default operator
+func (b *qsBoolNode) add(conj qsTokenType, prohibited, required, defaultAnd bool, node qsNode) {
+	if n := len(b.clauses); n > 0 {
+		prev := &b.clauses[n-1]
+		if conj == qsTokenAnd && prev.occur != qsMustNot {
+			prev.occur = qsMust
+		}
+		if conj == qsTokenOr && defaultAnd && prev.occur != qsMustNot {
+			prev.occur = qsShould
+		}
+	}
+
+	occur := qsShould
+	switch {
+	case prohibited:
+		occur = qsMustNot
+	case required, conj == qsTokenAnd:
+		occur = qsMust
+	case defaultAnd && conj != qsTokenOr:
+		occur = qsMust
+	}
+	b.clauses = append(b.clauses, qsClause{occur: occur, node: node})
+}
+
+func (p *qsParser) parseClause() (qsNode, error) {
+	t := p.next()
+	field := ""
+	if t.typ == qsTokenTerm && p.peek().typ == qsTokenColon {
+		field = t.text
+		p.next()
+		t = p.next()
+	}
+
+	var node qsNode
+	switch t.typ {
+	case qsTokenLParen:
+		query, err := p.parseQuery(true)
+		if err != nil {
+			return nil, err
+		}
+		p.next()
+		node = &qsGroupNode{field: field, query: query}
+	case qsTokenTerm, qsTokenPhrase, qsTokenRegexp:
+		node = &qsTermNode{
+			field:    field,
+			text:     t.text,
+			phrase:   t.typ == qsTokenPhrase,
+			regexp:   t.typ == qsTokenRegexp,
+			wildcard: t.typ == qsTokenTerm && t.wildcard,
+			slop:     -1,
+		}
+	case qsTokenRange, qsTokenCompare:
+		node = &qsRangeNode{field: field, from: t.from, to: t.to, includeFrom: t.includeFrom, includeTo: t.includeTo}
+	case qsTokenEOF:
+		return nil, fmt.Errorf("unexpected end of query")
+	default:
+		return nil, fmt.Errorf("unexpected token [%s]", t.String())
+	}
+
+	// the suffixes can be in any order, like term~1^2 or term^2~1
+	boost := -1.0
+	for {
+		switch p.peek().typ {
+		case qsTokenBoost:
+			v, err := strconv.ParseFloat(p.next().text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid boost: %s", err.Error())
+			}
+			boost = v
+			continue
+		case qsTokenTilde:
+			tilde := p.next()
+			n, ok := node.(*qsTermNode)
+			if !ok || n.regexp {
+				return nil, fmt.Errorf("unexpected token [%s]", tilde.String())
+			}
+			if !n.phrase {
+				n.fuzzy, n.fuzziness = true, tilde.text
+			} else if tilde.text != "" {
+				slop, err := strconv.Atoi(tilde.text)
+				if err != nil {
+					return nil, fmt.Errorf("invalid phrase slop [%s]", tilde.text)
+				}
+				n.slop = slop
+			}
+			continue
+		}
+		break
+	}
+	switch n := node.(type) {
+	case *qsGroupNode:
+		n.boost = boost
+	case *qsTermNode:
+		n.boost = boost
+	case *qsRangeNode:
+		n.boost = boost
+	}
+	return node, nil
+}
+
+func (t qsToken) String() string {
+	switch t.typ {
+	case qsTokenColon:
+		return ":"
+	case qsTokenLParen:
+		return "("
+	case qsTokenRParen:
+		return ")"
+	case qsTokenPlus:
+		return "+"
+	case qsTokenMinus:
+		return "-"
+	case qsTokenNot:
+		return "NOT"
+	case qsTokenAnd:
+		return "AND"
+	case qsTokenOr:
+		return "OR"
+	case qsTokenBoost:
+		return "^" + t.text
+	case qsTokenTilde:
+		return "~" + t.text
+	}
+	return t.text
+}
@@ -18,6 +18,7 @@ package query
 import (
 	"fmt"
 	"math"
+	"net"
 	"strings"
 	"time"
 
@@ -44,9 +45,11 @@ func RangeQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Qu
 			return RangeQueryNumeric(field, vv, mappings)
 		case "date", "time":
 			return RangeQueryTime(field, vv, mappings)
+		case "ip":
+			return RangeQueryIP(field, vv)
 		default:
 			return nil, errors.New(errors.ErrorTypeXContentParseException,
-				fmt.Sprintf("[range] %s only support values of [numeric, time], got %q", field, prop.Type))
+				fmt.Sprintf("[range] %s only support values of [numeric, time, ip], got %q", field, prop.Type))
 		}
 	}
 
@@ -218,3 +221,38 @@ func RangeQueryTime(field string, query map[string]interface{}, mappings *meta.M
 
 	return subq, nil
 }
+
+func RangeQueryIP(field string, query map[string]interface{}) (bluge.Query, error) {
+	// the lowest address is the unbounded min, a term range needs one bound at least
+	min := zutils.IPTerm(make(net.IP, net.IPv6len))
+	max := ""
+	minInclusive := true
+	maxInclusive := false
+	boost := -1.0
+	for k, v := range query {
+		k := strings.ToLower(k)
+		switch k {
+		case "gt", "gte", "lt", "lte":
+			ip, err := zutils.ParseIP(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[range] %s range.%s format err %s", field, k, err.Error()))
+			}
+			switch k {
+			case "gt", "gte":
+				min, minInclusive = zutils.IPTerm(ip), k == "gte"
+			default:
+				max, maxInclusive = zutils.IPTerm(ip), k == "lte"
+			}
+		case "boost":
+			boost, _ = zutils.ToFloat64(v)
+		default:
+			// return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[range] unknown field [%s]", k))
+		}
+	}
+
+	subq := bluge.NewTermRangeInclusiveQuery(min, max, minInclusive, maxInclusive).SetField(field)
+	if boost >= 0 {
+		subq.SetBoost(boost)
+	}
+	return subq, nil
+}
@@ -16,11 +16,115 @@
 package query
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/blugelabs/bluge"
 
+	zincquery "github.com/zinclabs/zincsearch/pkg/bluge/query"
 	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/script"
 )
 
-func TermsSetQuery(query map[string]interface{}) (bluge.Query, error) {
-	return nil, errors.New(errors.ErrorTypeNotImplemented, "[terms_set] query doesn't support")
+func TermsSetQuery(query map[string]interface{}, mappings *meta.Mappings) (bluge.Query, error) {
+	if len(query) > 1 {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] query doesn't support multiple fields")
+	}
+
+	field := ""
+	value := new(meta.TermsSetQuery)
+	value.Boost = -1.0
+	for k, v := range query {
+		field = k
+		vv, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] doesn't support values of type: %T", v))
+		}
+		for k, v := range vv {
+			k := strings.ToLower(k)
+			switch k {
+			case "terms":
+				terms, ok := v.([]interface{})
+				if !ok {
+					return nil, errors.New(errors.ErrorTypeXContentParseException, fmt.Sprintf("[terms_set] %s doesn't support values of type: %T", k, v))
+				}
+				value.Terms = terms
+			case "minimum_should_match_field":
+				value.MinimumShouldMatchField, _ = v.(string)
+			case "minimum_should_match_script":
+				value.MinimumShouldMatchScript = v
+			case "boost":
+				value.Boost, _ = v.(float64)
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] unknown field [%s]", k))
+			}
+		}
+	}
+
+	prop, _ := mappings.GetProperty(field)
+	queries := make([]bluge.Query, 0, len(value.Terms))
+	for _, term := range value.Terms {
+		var subq bluge.Query
+		var err error
+		switch prop.Type {
+		case "numeric":
+			subq, err = TermQueryNumeric(field, &meta.TermQuery{Value: term})
+		case "bool":
+			subq, err = TermQueryBool(field, &meta.TermQuery{Value: term})
+		case "ip":
+			subq, err = TermQueryIP(field, &meta.TermQuery{Value: term})
+		default:
+			subq, err = TermQueryText(field, &meta.TermQuery{Value: term})
+		}
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, subq)
+	}
+
+	subq := zincquery.NewTermsSetQuery(queries...)
+	switch {
+	case value.MinimumShouldMatchField != "":
+		if prop, ok := mappings.GetProperty(value.MinimumShouldMatchField); ok && prop.Type != "numeric" {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] minimum_should_match_field [%s] should be numeric but got [%s]", value.MinimumShouldMatchField, prop.Type))
+		}
+		subq.SetMinimumShouldMatchField(value.MinimumShouldMatchField)
+	case value.MinimumShouldMatchScript != nil:
+		minimum, err := termsSetMinimumFromScript(value.MinimumShouldMatchScript, len(value.Terms))
+		if err != nil {
+			return nil, err
+		}
+		subq.SetMinimumShouldMatch(minimum)
+	default:
+		return nil, errors.New(errors.ErrorTypeParsingException, "[terms_set] requires [minimum_should_match_field] or [minimum_should_match_script] to be set")
+	}
+	if value.Boost >= 0 {
+		subq.SetBoost(value.Boost)
+	}
+
+	return subq, nil
+}
+
+// termsSetMinimumFromScript runs the script once with params.num_terms,
+// the values of the document are not available to the script.
+func termsSetMinimumFromScript(v interface{}, numTerms int) (int, error) {
+	s, params, err := script.FromRequest(v)
+	if err != nil {
+		return 0, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[terms_set] minimum_should_match_script %s", err.Error()))
+	}
+	vars := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		vars[k] = v
+	}
+	vars["num_terms"] = float64(numTerms)
+	rv, err := s.Run(map[string]interface{}{"params": vars})
+	if err != nil {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] minimum_should_match_script %s", err.Error()))
+	}
+	f, ok := script.ToFloat(rv)
+	if !ok {
+		return 0, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[terms_set] minimum_should_match_script should return a number but got [%v]", rv))
+	}
+	return int(f), nil
 }
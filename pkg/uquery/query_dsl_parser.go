@@ -25,6 +25,7 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/errors"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/uquery/aggregation"
+	"github.com/zinclabs/zincsearch/pkg/uquery/collapse"
 	"github.com/zinclabs/zincsearch/pkg/uquery/fields"
 	"github.com/zinclabs/zincsearch/pkg/uquery/highlight"
 	"github.com/zinclabs/zincsearch/pkg/uquery/query"
@@ -104,8 +105,24 @@ func ParseQueryDSL(
 		}
 	}
 
-	// pagenation
-	// TODO: search after PIT support
+	// parse collapse
+	if q.Collapse != nil {
+		if err := collapse.Request(q.Collapse, mappings); err != nil {
+			return nil, err
+		}
+	}
+
+	// parse search after
+	if q.SearchAfter != nil {
+		if q.From > 0 {
+			return nil, errors.New(errors.ErrorTypeIllegalArgumentException, "[from] parameter must be set to 0 when [search_after] is used")
+		}
+		after, err := sort.SearchAfter(q.SearchAfter, request.SortOrder(), mappings)
+		if err != nil {
+			return nil, err
+		}
+		request.After(after)
+	}
 
 	return request, nil
 }
@@ -23,14 +23,18 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/uquery/aggregation"
 )
 
-func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *search.Bucket) error {
+func FormatResponse(resp *meta.SearchResponse, q *meta.ZincQuery, buckets *search.Bucket, mappings *meta.Mappings) error {
 	var err error
 	// format aggregations
 	if len(q.Aggregations) > 0 {
-		resp.Aggregations, err = aggregation.Response(buckets)
+		resp.Aggregations, err = aggregation.Response(buckets, q.Aggregations, mappings)
 		if err != nil {
 			return errors.New(errors.ErrorTypeParsingException, err.Error())
 		}
+		// pipeline aggregations run on the merged buckets
+		if err = aggregation.Pipeline(q.Aggregations, resp.Aggregations); err != nil {
+			return err
+		}
 		if len(resp.Aggregations) > 0 {
 			delete(resp.Aggregations, "count")
 			delete(resp.Aggregations, "duration")
@@ -0,0 +1,137 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sort
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/numeric/geo"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// GeoDistance sort by the distance to a geo point
+// {"_geo_distance": {"pin.location": [-70, 40], "order": "asc", "unit": "km", "mode": "min"}}
+func GeoDistance(v interface{}) (*search.Sort, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[sort] _geo_distance should be an object")
+	}
+
+	source := &geoDistanceSource{unit: geo.Meter, mode: "min"}
+	desc := false
+	for k, v := range m {
+		switch strings.ToLower(k) {
+		case "order":
+			order, _ := zutils.ToString(v)
+			desc = strings.ToLower(order) == "desc"
+		case "unit":
+			unit, _ := zutils.ToString(v)
+			u, ok := geoDistanceUnits[strings.ToLower(unit)]
+			if !ok {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[sort] _geo_distance unknown unit [%s]", unit))
+			}
+			source.unit = u
+		case "mode":
+			mode, _ := zutils.ToString(v)
+			mode = strings.ToLower(mode)
+			switch mode {
+			case "min", "max", "avg":
+				source.mode = mode
+			default:
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[sort] _geo_distance unknown mode [%s]", mode))
+			}
+		case "distance_type", "ignore_unmapped":
+			// ignore
+		default:
+			if source.field != "" {
+				return nil, errors.New(errors.ErrorTypeParsingException, "[sort] _geo_distance doesn't support multiple fields")
+			}
+			lon, lat, err := zutils.ParseGeoPoint(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeParsingException, fmt.Sprintf("[sort] _geo_distance %s %s", k, err.Error()))
+			}
+			source.field = k
+			source.point = geo.Point{Lon: lon, Lat: lat}
+		}
+	}
+	if source.field == "" {
+		return nil, errors.New(errors.ErrorTypeParsingException, "[sort] _geo_distance field should be defined")
+	}
+
+	sort := search.SortBy(source)
+	if desc {
+		sort.Desc()
+	}
+	return sort, nil
+}
+
+var geoDistanceUnits = map[string]geo.DistanceUnit{
+	"in": geo.Inch, "yd": geo.Yard, "ft": geo.Feet,
+	"km": geo.Kilometer, "nmi": geo.NauticalMile, "nm": geo.NauticalMile,
+	"mm": geo.Millimeter, "cm": geo.Centimeter, "mi": geo.Mile, "m": geo.Meter,
+}
+
+// geoDistanceSource returns the distance between the document and the point,
+// documents without the field returns nil and will be sorted as missing.
+type geoDistanceSource struct {
+	field string
+	point geo.Point
+	unit  geo.DistanceUnit
+	mode  string
+}
+
+func (s *geoDistanceSource) Fields() []string {
+	return []string{s.field}
+}
+
+func (s *geoDistanceSource) Value(match *search.DocumentMatch) []byte {
+	dist, ok := s.Number(match)
+	if !ok {
+		return nil
+	}
+	return numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(dist), 0)
+}
+
+// Number returns the distance depends on the mode, if the document has multiple points
+func (s *geoDistanceSource) Number(match *search.DocumentMatch) (float64, bool) {
+	points := search.Field(s.field).GeoPoints(match)
+	if len(points) == 0 {
+		return 0, false
+	}
+	var dist float64
+	for i, p := range points {
+		d := geo.Convert(geo.Haversin(p.Lon, p.Lat, s.point.Lon, s.point.Lat), geo.Kilometer, s.unit)
+		switch {
+		case i == 0:
+			dist = d
+		case s.mode == "min" && d < dist:
+			dist = d
+		case s.mode == "max" && d > dist:
+			dist = d
+		case s.mode == "avg":
+			dist += d
+		}
+	}
+	if s.mode == "avg" {
+		dist /= float64(len(points))
+	}
+	return dist, true
+}
@@ -0,0 +1,158 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+package sort
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/blugelabs/bluge/numeric"
+	"github.com/blugelabs/bluge/search"
+
+	"github.com/zinclabs/zincsearch/pkg/errors"
+	"github.com/zinclabs/zincsearch/pkg/meta"
+	"github.com/zinclabs/zincsearch/pkg/zutils"
+)
+
+// SearchAfter encodes the search_after values to the sort keys of the sort order,
+// the values are the `sort` of the last hit from the previous page.
+// The raw sort keys ([]byte) from a previous search are passed through.
+func SearchAfter(values []interface{}, sorts search.SortOrder, mappings *meta.Mappings) ([][]byte, error) {
+	if len(values) != len(sorts) {
+		return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] has %d value(s) but sort has %d", len(values), len(sorts)))
+	}
+
+	after := make([][]byte, len(values))
+	for i, v := range values {
+		if raw, ok := v.([]byte); ok {
+			after[i] = raw
+			continue
+		}
+		if v == nil {
+			after[i] = missingValue(sorts[i])
+			continue
+		}
+		switch sortType(sorts[i], mappings) {
+		case "numeric":
+			f, err := zutils.ToFloat64(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] value [%v] should be a number", v))
+			}
+			after[i] = numeric.MustNewPrefixCodedInt64(numeric.Float64ToInt64(f), 0)
+		case "date":
+			var ns int64
+			switch v := v.(type) {
+			case string:
+				prop, _ := mappings.GetProperty(sorts[i].Fields()[0]) // mappings is not nil for date
+				t, err := zutils.ParseTime(v, prop.Format, prop.TimeZone)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] value [%v] parse err: %s", v, err.Error()))
+				}
+				ns = t.UnixNano()
+			default:
+				f, err := zutils.ToFloat64(v)
+				if err != nil {
+					return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] value [%v] should be epoch_millis or a date string", v))
+				}
+				ns = int64(f) * 1e6
+			}
+			after[i] = numeric.MustNewPrefixCodedInt64(ns, 0)
+		case "ip":
+			ip, err := zutils.ParseIP(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] value [%v] should be an ip", v))
+			}
+			after[i] = []byte(zutils.IPTerm(ip))
+		default:
+			s, err := zutils.ToString(v)
+			if err != nil {
+				return nil, errors.New(errors.ErrorTypeIllegalArgumentException, fmt.Sprintf("[search_after] value [%v] should be a string", v))
+			}
+			after[i] = []byte(s)
+		}
+	}
+	return after, nil
+}
+
+// Values decodes the sort keys of a hit, they can be sent back as search_after.
+// Dates are returned as epoch_millis and missing values as null.
+func Values(sortValue [][]byte, sorts search.SortOrder, mappings *meta.Mappings) []interface{} {
+	values := make([]interface{}, 0, len(sortValue))
+	for i, v := range sortValue {
+		if i >= len(sorts) {
+			break
+		}
+		if fields := sorts[i].Fields(); len(fields) > 0 && string(v) == string(missingValue(sorts[i])) {
+			values = append(values, nil)
+			continue
+		}
+		switch sortType(sorts[i], mappings) {
+		case "numeric":
+			i64, err := numeric.PrefixCoded(v).Int64()
+			if err != nil {
+				values = append(values, nil)
+				continue
+			}
+			values = append(values, numeric.Int64ToFloat64(i64))
+		case "date":
+			i64, err := numeric.PrefixCoded(v).Int64()
+			if err != nil {
+				values = append(values, nil)
+				continue
+			}
+			values = append(values, i64/1e6)
+		case "bool":
+			b, _ := strconv.ParseBool(string(v))
+			values = append(values, b)
+		case "ip":
+			values = append(values, zutils.FormatIPTerm(v))
+		default:
+			values = append(values, string(v))
+		}
+	}
+	return values
+}
+
+// sortType returns how the sort keys are encoded: numeric, date, bool, ip or keyword
+func sortType(sort *search.Sort, mappings *meta.Mappings) string {
+	fields := sort.Fields()
+	if len(fields) == 0 {
+		return "numeric" // _score
+	}
+	if mappings == nil {
+		return "keyword"
+	}
+	prop, ok := mappings.GetProperty(fields[0])
+	if !ok {
+		return "keyword"
+	}
+	switch prop.Type {
+	case "numeric", "geo_point":
+		return "numeric"
+	case "date", "time":
+		return "date"
+	case "bool":
+		return "bool"
+	case "ip":
+		return "ip"
+	default:
+		return "keyword"
+	}
+}
+
+// missingValue returns the sort key of the documents without the field
+func missingValue(sort *search.Sort) []byte {
+	return sort.Value(&search.DocumentMatch{})
+}
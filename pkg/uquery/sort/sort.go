@@ -42,6 +42,14 @@ func Request(v interface{}) (search.SortOrder, error) {
 			case string:
 				sorts = append(sorts, search.ParseSearchSortString(v))
 			case map[string]interface{}:
+				if geoDistance, ok := v["_geo_distance"]; ok {
+					sort, err := GeoDistance(geoDistance)
+					if err != nil {
+						return nil, err
+					}
+					sorts = append(sorts, sort)
+					continue
+				}
 				if len(v) > 1 {
 					return nil, errors.New(errors.ErrorTypeParsingException, "[sort] field doesn't support multiple values")
 				}
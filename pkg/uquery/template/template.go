@@ -38,8 +38,13 @@ func Request(data map[string]interface{}, enableTextKeywordMapping bool) (*meta.
 	for k, v := range data {
 		k = strings.ToLower(k)
 		switch k {
-		case "name", "data_stream":
+		case "name":
 			// ignore
+		case "data_stream":
+			if _, ok := v.(map[string]interface{}); !ok {
+				return nil, errors.New(errors.ErrorTypeXContentParseException, "[template] data_stream value should be an object")
+			}
+			template.DataStream = new(meta.DataStreamTemplate)
 		case "index_patterns":
 			patterns, ok := v.([]interface{})
 			if !ok {
@@ -0,0 +1,58 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blugelabs/bluge/numeric/geo"
+)
+
+const geoHashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// ParseGeoPoint parse a geo point, compatible with the ES formats:
+// {"lat": 41.12, "lon": -71.34}, "41.12,-71.34", geohash "drm3btev3e86" and [-71.34, 41.12]
+func ParseGeoPoint(v interface{}) (lon, lat float64, err error) {
+	if s, ok := v.(string); ok {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, ",") {
+			if s == "" || len(s) > 12 || strings.Trim(strings.ToLower(s), geoHashAlphabet) != "" {
+				return 0, 0, fmt.Errorf("ParseGeoPoint: invalid geohash [%s]", s)
+			}
+			v = strings.ToLower(s)
+		}
+	}
+
+	var ok bool
+	lon, lat, ok = geo.ExtractGeoPoint(v)
+	if !ok {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: unsupported geo point [%v]", v)
+	}
+	if lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: latitude [%v] out of range", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("ParseGeoPoint: longitude [%v] out of range", lon)
+	}
+	return lon, lat, nil
+}
+
+// FormatGeoPoint returns the geo point as "lat,lon"
+func FormatGeoPoint(lon, lat float64) string {
+	return strconv.FormatFloat(lat, 'f', -1, 64) + "," + strconv.FormatFloat(lon, 'f', -1, 64)
+}
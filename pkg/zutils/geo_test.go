@@ -0,0 +1,88 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGeoPoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		lon     float64
+		lat     float64
+		wantErr bool
+	}{
+		{
+			name: "object",
+			v:    map[string]interface{}{"lat": 41.12, "lon": -71.34},
+			lon:  -71.34,
+			lat:  41.12,
+		},
+		{
+			name: "string",
+			v:    "41.12,-71.34",
+			lon:  -71.34,
+			lat:  41.12,
+		},
+		{
+			name: "array",
+			v:    []interface{}{-71.34, 41.12},
+			lon:  -71.34,
+			lat:  41.12,
+		},
+		{
+			name: "geohash",
+			v:    "drm3btev3e86",
+			lon:  -71.34,
+			lat:  41.12,
+		},
+		{
+			name:    "invalid geohash",
+			v:       "hello world",
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			v:       "141.12,-71.34",
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			v:       true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lon, lat, err := ParseGeoPoint(tt.v)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.lon, lon, 0.0001)
+			assert.InDelta(t, tt.lat, lat, 0.0001)
+		})
+	}
+}
+
+func TestFormatGeoPoint(t *testing.T) {
+	assert.Equal(t, "41.12,-71.34", FormatGeoPoint(-71.34, 41.12))
+}
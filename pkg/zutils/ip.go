@@ -0,0 +1,83 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseIP parse an IPv4 or IPv6 address into the 16 bytes form,
+// IPv4 is mapped to ::ffff:a.b.c.d so the bytes of both versions are sortable together
+func ParseIP(v interface{}) (net.IP, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("ParseIP: unsupported ip [%v]", v)
+	}
+	ip := net.ParseIP(strings.TrimSpace(s))
+	if ip == nil {
+		return nil, fmt.Errorf("ParseIP: invalid ip [%s]", s)
+	}
+	return ip.To16(), nil
+}
+
+// ParseCIDR returns the first and the last address of a CIDR like 10.0.0.0/8, in the 16 bytes form
+func ParseCIDR(s string) (first, last net.IP, err error) {
+	_, ipnet, err := net.ParseCIDR(strings.TrimSpace(s))
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParseCIDR: invalid cidr [%s]", s)
+	}
+	first = ipnet.IP.To16()
+	last = make(net.IP, net.IPv6len)
+	mask := ipnet.Mask
+	if len(mask) == net.IPv4len {
+		mask = append(net.CIDRMask(96, 128)[:12], mask...)
+	}
+	for i := range first {
+		last[i] = first[i] | ^mask[i]
+	}
+	return first, last, nil
+}
+
+// IsCIDR reports whether the value is in the CIDR notation
+func IsCIDR(s string) bool {
+	return strings.Contains(s, "/")
+}
+
+// FormatIP returns the string of the 16 bytes form, IPv4 mapped addresses return as IPv4
+func FormatIP(b []byte) string {
+	if len(b) != net.IPv6len {
+		return string(b)
+	}
+	return net.IP(b).String()
+}
+
+// IPTerm returns the indexed term of an ip, the hex of the 16 bytes form keeps the order of the ips,
+// raw bytes can't be used because the doc values of a field are separated by 0xff
+func IPTerm(ip net.IP) string {
+	return hex.EncodeToString(ip.To16())
+}
+
+// FormatIPTerm returns the ip string of an indexed term
+func FormatIPTerm(term []byte) string {
+	b := make([]byte, hex.DecodedLen(len(term)))
+	if _, err := hex.Decode(b, term); err != nil || len(b) != net.IPv6len {
+		return string(term)
+	}
+	return FormatIP(b)
+}
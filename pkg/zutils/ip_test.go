@@ -0,0 +1,87 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package zutils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "ipv4", v: "192.168.1.1", want: "192.168.1.1"},
+		{name: "ipv6", v: "2001:db8::1", want: "2001:db8::1"},
+		{name: "ipv6 full", v: "2001:0db8:0000:0000:0000:0000:0000:0001", want: "2001:db8::1"},
+		{name: "invalid", v: "192.168.1.256", wantErr: true},
+		{name: "number", v: 1.0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIP(tt.v)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, got, 16)
+			assert.Equal(t, tt.want, FormatIP(got))
+			assert.Equal(t, tt.want, FormatIPTerm([]byte(IPTerm(got))))
+		})
+	}
+
+	// the terms sort IPv4 before IPv6 and by value
+	a, _ := ParseIP("9.255.255.255")
+	b, _ := ParseIP("10.0.0.0")
+	c, _ := ParseIP("2001:db8::1")
+	assert.Equal(t, -1, strings.Compare(IPTerm(a), IPTerm(b)))
+	assert.Equal(t, -1, strings.Compare(IPTerm(b), IPTerm(c)))
+	assert.NotContains(t, IPTerm(a), "\xff")
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		first   string
+		last    string
+		wantErr bool
+	}{
+		{name: "ipv4", s: "10.0.0.0/8", first: "10.0.0.0", last: "10.255.255.255"},
+		{name: "ipv4 host bits", s: "192.168.1.77/25", first: "192.168.1.0", last: "192.168.1.127"},
+		{name: "ipv4 single", s: "192.168.1.1/32", first: "192.168.1.1", last: "192.168.1.1"},
+		{name: "ipv6", s: "2001:db8::/32", first: "2001:db8::", last: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+		{name: "invalid", s: "10.0.0.0/33", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, last, err := ParseCIDR(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.first, FormatIP(first))
+			assert.Equal(t, tt.last, FormatIP(last))
+		})
+	}
+}
@@ -152,4 +152,210 @@ func TestAuth(t *testing.T) {
 			assert.Equal(t, "admin", data[0].ID)
 		})
 	})
+
+	t.Run("test index permissions", func(t *testing.T) {
+		roleID := "perm-role"
+		userID := "perm-user"
+		userPassword := "Complexpass#123"
+		requestAs := func(method, api, body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, api, bytes.NewBufferString(body))
+			req.SetBasicAuth(userID, userPassword)
+			resp := httptest.NewRecorder()
+			server().ServeHTTP(resp, req)
+			return resp
+		}
+
+		// prepare indexes, alias, role and user as admin
+		resp := request("POST", "/es/_bulk", bytes.NewBufferString(`{"index": {"_index": "perm-logs-1", "_id": "1"}}
+{"message": "logs"}
+{"index": {"_index": "perm-app-1", "_id": "1"}}
+{"message": "app"}
+`))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = request("POST", "/es/_aliases", bytes.NewBufferString(`{"actions":[{"add":{"index":"perm-app-1","alias":"perm-alias"}}]}`))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = request("PUT", "/api/role", bytes.NewBufferString(fmt.Sprintf(`{"_id":"%s","name":"%s","permission":[],
+"indices":[{"names":["perm-logs-*"],"privileges":["read"]},{"names":["perm-app-*"],"privileges":["write"]}]}`, roleID, roleID)))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		resp = request("PUT", "/api/user", bytes.NewBufferString(fmt.Sprintf(`{"_id":"%s","name":"%s","password":"%s","role":"%s"}`, userID, userID, userPassword, roleID)))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		defer func() {
+			request("DELETE", "/api/user/"+userID, nil)
+			request("DELETE", "/api/role/"+roleID, nil)
+		}()
+
+		t.Run("create role with error privilege", func(t *testing.T) {
+			resp := request("PUT", "/api/role", bytes.NewBufferString(`{"_id":"perm-role-err","indices":[{"names":["a"],"privileges":["xxx"]}]}`))
+			assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		})
+		t.Run("permission not granted", func(t *testing.T) {
+			resp := requestAs("GET", "/api/user", "")
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("search granted index", func(t *testing.T) {
+			resp := requestAs("POST", "/es/perm-logs-1/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestAs("POST", "/es/perm-logs-*/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+		t.Run("search not granted index", func(t *testing.T) {
+			resp := requestAs("POST", "/es/perm-app-1/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			resp = requestAs("POST", "/es/perm-logs-1,perm-app-1/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			resp = requestAs("POST", "/es/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("search alias of not granted index", func(t *testing.T) {
+			resp := requestAs("POST", "/es/perm-alias/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("search pattern matching alias of not granted index", func(t *testing.T) {
+			resp := request("POST", "/es/_aliases", bytes.NewBufferString(`{"actions":[{"add":{"index":"perm-app-1","alias":"perm-logs-all"}}]}`))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			defer request("POST", "/es/_aliases", bytes.NewBufferString(`{"actions":[{"remove":{"index":"perm-app-1","alias":"perm-logs-all"}}]}`))
+
+			resp = requestAs("POST", "/es/perm-logs-*/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			resp = requestAs("POST", "/es/perm-logs-1/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+		t.Run("msearch", func(t *testing.T) {
+			resp := requestAs("POST", "/es/_msearch", `{"index":"perm-logs-1"}
+{"query":{"match_all":{}}}
+`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestAs("POST", "/es/_msearch", `{"index":"perm-logs-1"}
+{"query":{"match_all":{}}}
+{"index":"perm-app-1"}
+{"query":{"match_all":{}}}
+`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("bulk", func(t *testing.T) {
+			resp := requestAs("POST", "/es/_bulk", `{"index": {"_index": "perm-app-1", "_id": "2"}}
+{"message": "app"}
+{"delete": {"_index": "perm-app-1", "_id": "2"}}
+`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestAs("POST", "/es/perm-app-1/_bulk", `{"index": {"_id": "3"}}
+{"message": "app"}
+{"index": {"_index": "perm-logs-1", "_id": "3"}}
+{"message": "logs"}
+`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("manage not granted", func(t *testing.T) {
+			resp := requestAs("DELETE", "/api/index/perm-app-1", "")
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+		t.Run("data stream granted by pattern", func(t *testing.T) {
+			resp := request("PUT", "/es/_index_template/perm-ds-template", bytes.NewBufferString(`{"index_patterns":["perm-logs-ds*","perm-ds-*"],"priority":514,"template":{"settings":{}},"data_stream":{}}`))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			defer request("DELETE", "/es/_index_template/perm-ds-template", nil)
+			for _, name := range []string{"perm-logs-ds", "perm-ds-app"} {
+				resp = request("PUT", "/es/_data_stream/"+name, nil)
+				assert.Equal(t, http.StatusOK, resp.Code)
+				defer request("DELETE", "/es/_data_stream/"+name, nil)
+			}
+
+			resp = requestAs("POST", "/es/perm-logs-ds/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestAs("POST", "/es/perm-ds-app/_search", `{"query":{"match_all":{}}}`)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+		})
+	})
+
+	t.Run("test api key and session token", func(t *testing.T) {
+		requestWith := func(method, api, authorization string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(method, api, nil)
+			req.Header.Set("Authorization", authorization)
+			resp := httptest.NewRecorder()
+			server().ServeHTTP(resp, req)
+			return resp
+		}
+
+		key := struct {
+			ID      string `json:"_id"`
+			Encoded string `json:"encoded"`
+		}{}
+		resp := request("POST", "/api/apikey", bytes.NewBufferString(`{"name":"shipper","expiration":"1d"}`))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &key))
+
+		t.Run("create api key with error expiration", func(t *testing.T) {
+			resp := request("POST", "/api/apikey", bytes.NewBufferString(`{"name":"shipper","expiration":"xxx"}`))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+		t.Run("list api keys", func(t *testing.T) {
+			resp := request("GET", "/api/apikey", nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Contains(t, resp.Body.String(), key.ID)
+			assert.NotContains(t, resp.Body.String(), "hash")
+		})
+		t.Run("ApiKey", func(t *testing.T) {
+			resp := requestWith("GET", "/api/index", "ApiKey "+key.Encoded)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestWith("GET", "/api/index", "ApiKey xxx")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+		t.Run("Bearer api key", func(t *testing.T) {
+			resp := requestWith("GET", "/api/index", "Bearer "+key.Encoded)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+		t.Run("Bearer session token", func(t *testing.T) {
+			body := bytes.NewBufferString(fmt.Sprintf(`{"_id": "%s", "password": "%s"}`, username, password))
+			resp := request("POST", "/api/login", body)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			data := struct {
+				Token string `json:"token"`
+			}{}
+			assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &data))
+			assert.NotEmpty(t, data.Token)
+
+			resp = requestWith("GET", "/api/index", "Bearer "+data.Token)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestWith("GET", "/api/index", "Bearer "+data.Token+"x")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+		t.Run("api keys of another user", func(t *testing.T) {
+			roleID := "apikey-role"
+			userID := "apikey-user"
+			userPassword := "Complexpass#123"
+			resp := request("PUT", "/api/role", bytes.NewBufferString(fmt.Sprintf(`{"_id":"%s","name":"%s",
+"permission":["auth.ListAPIKey","auth.DeleteAPIKey"]}`, roleID, roleID)))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = request("PUT", "/api/user", bytes.NewBufferString(fmt.Sprintf(`{"_id":"%s","name":"%s","password":"%s","role":"%s"}`, userID, userID, userPassword, roleID)))
+			assert.Equal(t, http.StatusOK, resp.Code)
+			defer func() {
+				request("DELETE", "/api/user/"+userID, nil)
+				request("DELETE", "/api/role/"+roleID, nil)
+			}()
+			requestAs := func(method, api string) *httptest.ResponseRecorder {
+				req, _ := http.NewRequest(method, api, nil)
+				req.SetBasicAuth(userID, userPassword)
+				resp := httptest.NewRecorder()
+				server().ServeHTTP(resp, req)
+				return resp
+			}
+
+			resp = requestAs("GET", "/api/apikey")
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.NotContains(t, resp.Body.String(), key.ID)
+			resp = requestAs("DELETE", "/api/apikey/"+key.ID)
+			assert.Equal(t, http.StatusForbidden, resp.Code)
+			resp = requestWith("GET", "/api/index", "ApiKey "+key.Encoded)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+		t.Run("revoke api key", func(t *testing.T) {
+			resp := request("DELETE", "/api/apikey/"+key.ID, nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			resp = requestWith("GET", "/api/index", "ApiKey "+key.Encoded)
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+		t.Run("unsupported scheme", func(t *testing.T) {
+			resp := requestWith("GET", "/api/index", "Digest xxx")
+			assert.Equal(t, http.StatusUnauthorized, resp.Code)
+		})
+	})
 }
@@ -20,10 +20,10 @@ import (
 	"net/http"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestDocument(t *testing.T) {
@@ -101,7 +101,7 @@ func TestDocument(t *testing.T) {
 			assert.Equal(t, http.StatusBadRequest, resp.Code)
 		})
 		// wait for WAL write to index
-		time.Sleep(time.Second)
+		wait.ForRefresh(t, indexName)
 	})
 
 	t.Run("DELETE /api/:target/_doc/:id", func(t *testing.T) {
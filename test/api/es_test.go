@@ -20,10 +20,10 @@ import (
 	"net/http"
 	"strings"
 	"testing"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestApiES(t *testing.T) {
@@ -169,7 +169,7 @@ func TestApiES(t *testing.T) {
 			})
 			t.Run("delete document with exist indexName and exist id", func(t *testing.T) {
 				// wait for WAL write to index
-				time.Sleep(time.Second)
+				wait.ForRefresh(t, indexName)
 				resp := request("DELETE", "/es/"+indexName+"/_doc/1111", nil)
 				assert.Equal(t, http.StatusOK, resp.Code)
 			})
@@ -250,7 +250,7 @@ func TestApiES(t *testing.T) {
 			})
 			t.Run("update document with exist indexName", func(t *testing.T) {
 				// wait for WAL write to index
-				time.Sleep(time.Second)
+				wait.ForRefresh(t, indexName)
 				body := bytes.NewBuffer(nil)
 				body.WriteString(indexData)
 				resp := request("POST", "/es/"+indexName+"/_update/1111", body)
@@ -26,6 +26,7 @@ import (
 
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestSearchV1(t *testing.T) {
@@ -34,6 +35,9 @@ func TestSearchV1(t *testing.T) {
 		body.WriteString(indexData)
 		resp := request("PUT", "/api/"+indexName+"/_doc", body)
 		assert.Equal(t, http.StatusOK, resp.Code)
+
+		// wait for WAL write to index
+		wait.ForRefresh(t, indexName)
 	})
 
 	t.Run("POST /api/:target/_search", func(t *testing.T) {
@@ -131,7 +135,8 @@ func TestSearchV1(t *testing.T) {
 				}
 			}`,
 				time.Now().UTC().Add(time.Hour*-24).Format("2006-01-02T15:04:05Z"),
-				time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+				// round up the fraction of second of the documents indexed just now
+				time.Now().UTC().Add(time.Second).Format("2006-01-02T15:04:05Z"),
 			))
 			resp := request("POST", "/api/"+indexName+"/_search", body)
 			assert.Equal(t, http.StatusOK, resp.Code)
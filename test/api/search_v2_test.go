@@ -27,6 +27,7 @@ import (
 	"github.com/zinclabs/zincsearch/pkg/core"
 	"github.com/zinclabs/zincsearch/pkg/meta"
 	"github.com/zinclabs/zincsearch/pkg/zutils/json"
+	"github.com/zinclabs/zincsearch/test/wait"
 )
 
 func TestSearchV2(t *testing.T) {
@@ -36,6 +37,9 @@ func TestSearchV2(t *testing.T) {
 		resp := request("PUT", "/api/"+indexName+"/_doc", body)
 		assert.NoError(t, core.ZINC_INDEX_ALIAS_LIST.AddIndexesToAlias(indexAlias, []string{indexName}))
 		assert.Equal(t, http.StatusOK, resp.Code)
+
+		// wait for WAL write to index
+		wait.ForRefresh(t, indexName)
 	})
 
 	t.Run("POST /es/:target/_search", func(t *testing.T) {
@@ -134,7 +138,8 @@ func TestSearchV2(t *testing.T) {
 			body.WriteString(
 				fmt.Sprintf(`{"query": {"range": {"@timestamp": { "gte": "%s", "lt": "%s"}}}, "size":10}`,
 					time.Now().UTC().Add(time.Hour*-24).Format("2006-01-02T15:04:05Z"),
-					time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+					// round up the fraction of second of the documents indexed just now
+					time.Now().UTC().Add(time.Second).Format("2006-01-02T15:04:05Z"),
 				))
 			resp := request("POST", "/es/"+indexName+"/_search", body)
 			assert.Equal(t, http.StatusOK, resp.Code)
@@ -237,4 +242,88 @@ func TestSearchV2(t *testing.T) {
 			assert.GreaterOrEqual(t, len(data.Aggregations), 1)
 		})
 	})
+
+	t.Run("POST /es/:target/_search?scroll", func(t *testing.T) {
+		body := bytes.NewBufferString(`{"query":{"match_all":{}},"size":1}`)
+		resp := request("POST", "/es/"+indexName+"/_search?scroll=1m", body)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		data := new(meta.SearchResponse)
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), data))
+		assert.NotEmpty(t, data.ScrollID)
+		assert.Len(t, data.Hits.Hits, 1)
+
+		t.Run("next page", func(t *testing.T) {
+			body := bytes.NewBufferString(fmt.Sprintf(`{"scroll":"1m","scroll_id":"%s"}`, data.ScrollID))
+			resp := request("POST", "/es/_search/scroll", body)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			page := new(meta.SearchResponse)
+			assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), page))
+			assert.Equal(t, data.ScrollID, page.ScrollID)
+			if len(page.Hits.Hits) > 0 {
+				assert.NotEqual(t, data.Hits.Hits[0].ID, page.Hits.Hits[0].ID)
+			}
+		})
+		t.Run("error scroll", func(t *testing.T) {
+			resp := request("POST", "/es/"+indexName+"/_search?scroll=xxx", bytes.NewBufferString(`{}`))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			resp = request("POST", "/es/_search/scroll", bytes.NewBufferString(`{"scroll":"1m"}`))
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+		})
+		t.Run("clear scroll", func(t *testing.T) {
+			body := bytes.NewBufferString(fmt.Sprintf(`{"scroll_id":["%s"]}`, data.ScrollID))
+			resp := request("DELETE", "/es/_search/scroll", body)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Contains(t, resp.Body.String(), `"num_freed":1`)
+
+			resp = request("GET", "/es/_search/scroll/"+data.ScrollID, nil)
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+			resp = request("DELETE", "/es/_search/scroll/"+data.ScrollID, nil)
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+			resp = request("DELETE", "/es/_search/scroll/_all", nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+		})
+	})
+
+	t.Run("POST /es/:target/_pit", func(t *testing.T) {
+		resp := request("POST", "/es/"+indexName+"/_pit?keep_alive=1m", nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		pit := struct {
+			ID string `json:"id"`
+		}{}
+		assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), &pit))
+		assert.NotEmpty(t, pit.ID)
+
+		t.Run("search point in time", func(t *testing.T) {
+			body := bytes.NewBufferString(fmt.Sprintf(`{"query":{"match_all":{}},"size":1,"pit":{"id":"%s","keep_alive":"1m"}}`, pit.ID))
+			resp := request("POST", "/es/_search", body)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			data := new(meta.SearchResponse)
+			assert.NoError(t, json.Unmarshal(resp.Body.Bytes(), data))
+			assert.Equal(t, pit.ID, data.PitID)
+			assert.Len(t, data.Hits.Hits, 1)
+
+			resp = request("GET", "/api/index/"+indexName, nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Contains(t, resp.Body.String(), `"open_pits":1`)
+		})
+		t.Run("error point in time", func(t *testing.T) {
+			resp := request("POST", "/es/"+indexName+"/_pit", nil)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			body := bytes.NewBufferString(fmt.Sprintf(`{"pit":{"id":"%s"}}`, pit.ID))
+			resp = request("POST", "/es/"+indexName+"/_search", body)
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			resp = request("POST", "/es/_search", bytes.NewBufferString(`{"pit":{"id":"not_exists"}}`))
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+		t.Run("close point in time", func(t *testing.T) {
+			body := bytes.NewBufferString(fmt.Sprintf(`{"id":"%s"}`, pit.ID))
+			resp := request("DELETE", "/es/_pit", body)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Contains(t, resp.Body.String(), `"num_freed":1`)
+
+			body = bytes.NewBufferString(fmt.Sprintf(`{"id":"%s"}`, pit.ID))
+			resp = request("DELETE", "/es/_pit", body)
+			assert.Equal(t, http.StatusNotFound, resp.Code)
+		})
+	})
 }
@@ -41,7 +41,7 @@ func BenchmarkBulk(b *testing.B) {
 	cfg := config.NewEnvFileGlobalConfig([]string{"../../.env"})
 	node, _ := ider.NewNode(cfg.NodeID)
 	for i := 0; i < b.N; i++ {
-		_, err = document.BulkWorker(target, f, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
+		_, err = document.BulkWorker(target, "", f, cfg.MaxDocumentSize, cfg.EnableTextKeywordMapping, cfg.Shard.GoroutineNum, node)
 		if err != nil {
 			b.Error(err)
 		}
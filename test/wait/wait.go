@@ -0,0 +1,49 @@
+/* Copyright 2022 Zinc Labs Inc. and Contributors
+*
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+*
+*     http://www.apache.org/licenses/LICENSE-2.0
+*
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package wait
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zinclabs/zincsearch/pkg/core"
+)
+
+const timeout = 10 * time.Second
+
+// ForRefresh waits until the WAL of the indexes is written to the index.
+func ForRefresh(t *testing.T, names ...string) {
+	for _, name := range names {
+		index, ok := core.GetIndex(name)
+		if !assert.True(t, ok, "index [%s] not found", name) {
+			continue
+		}
+		ok, err := index.WaitForRefresh(timeout)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+}
+
+// ForTasks waits until the running tasks are completed.
+func ForTasks(t *testing.T) {
+	for _, info := range core.ZINC_TASK_LIST.List("") {
+		if task, ok := core.ZINC_TASK_LIST.Get(info.ID); ok {
+			assert.True(t, task.Wait(timeout))
+		}
+	}
+}
@@ -8,7 +8,9 @@ const http = () => {
     // timeout: 10000,
     baseURL: store.state.API_ENDPOINT,
     headers: {
-      Authorization: "Basic " + store.state.user.base64encoded,
+      Authorization: store.state.user.token
+        ? "Bearer " + store.state.user.token
+        : "Basic " + store.state.user.base64encoded,
     },
   });
 
@@ -8,6 +8,7 @@ export default createStore({
       _id: "",
       password: "",
       base64encoded: "",
+      token: "",
       name: "",
       email: "",
       role: "",
@@ -15,12 +16,13 @@ export default createStore({
   },
   mutations: {
     login(state, payload) {
-      if (payload && payload._id && payload.base64encoded) {
+      if (payload && payload._id && (payload.token || payload.base64encoded)) {
         state.user.isLoggedIn = true;
         state.user._id = payload._id;
         state.user.name = payload.name || payload._id;
         state.user.role = payload.role;
-        state.user.base64encoded = payload.base64encoded;
+        state.user.base64encoded = payload.base64encoded || "";
+        state.user.token = payload.token || "";
       }
     },
     logout(state) {
@@ -29,6 +31,7 @@ export default createStore({
       state.user.name = "";
       state.user.role = "";
       state.user.base64encoded = "";
+      state.user.token = "";
     },
     endpoint(state, payload) {
       state.API_ENDPOINT = payload;